@@ -6,6 +6,8 @@ import (
 	"errors"
 	"io"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -55,8 +57,18 @@ type Address interface {
 	// of the end of the receiver and the end of the argument.
 	Between(AdditiveAddress) Address
 
+	// At returns an Address identifying the argument Address
+	// evaluated with dot set to the receiver Address.
+	// Unlike Then, the string is only that of the argument.
+	At(AdditiveAddress) Address
+
 	// Where returns the Span of the Address evaluated on a Text.
 	Where(Text) (Span, error)
+
+	// WhereFrom returns the Span of the Address evaluated on a Text
+	// with dot set to the given Span.
+	// The Text's . mark is neither read nor modified.
+	WhereFrom(Text, Span) (Span, error)
 }
 
 type to struct {
@@ -68,6 +80,7 @@ func (a to) String() string                    { return a.left.String() + "," +
 func (a to) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a to) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a to) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a to) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a to) Where(text Text) (Span, error) {
 	left, err := a.left.Where(text)
@@ -81,6 +94,10 @@ func (a to) Where(text Text) (Span, error) {
 	return Span{left[0], right[1]}, nil
 }
 
+func (a to) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 type then struct {
 	left  Address
 	right AdditiveAddress
@@ -90,6 +107,7 @@ func (a then) String() string                    { return a.left.String() + ";"
 func (a then) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a then) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a then) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a then) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 type withDot struct {
 	Text
@@ -115,6 +133,10 @@ func (a then) Where(text Text) (Span, error) {
 	return Span{left[0], right[1]}, nil
 }
 
+func (a then) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 type between struct {
 	left  Address
 	right AdditiveAddress
@@ -124,6 +146,7 @@ func (a between) String() string                    { return a.left.String() + "
 func (a between) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a between) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a between) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a between) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a between) Where(text Text) (Span, error) {
 	left, err := a.left.Where(text)
@@ -145,6 +168,33 @@ func (a between) Where(text Text) (Span, error) {
 	return Span{l, r}, nil
 }
 
+func (a between) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
+type at struct {
+	left  Address
+	right AdditiveAddress
+}
+
+func (a at) String() string                    { return a.left.String() + "@" + a.right.String() }
+func (a at) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
+func (a at) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
+func (a at) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a at) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
+
+func (a at) Where(text Text) (Span, error) {
+	left, err := a.left.Where(text)
+	if err != nil {
+		return Span{}, err
+	}
+	return a.right.Where(withDot{Text: text, dot: left})
+}
+
+func (a at) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 // A AdditiveAddress identifies a Span within a Text.
 // AdditiveAddress can be composed
 // using the methods of the Address interface,
@@ -166,11 +216,16 @@ func (a plus) String() string                    { return a.left.String() + "+"
 func (a plus) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a plus) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a plus) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a plus) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a plus) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a plus) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
 func (a plus) Where(text Text) (Span, error)         { return a.where(0, text) }
 
+func (a plus) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a plus) where(from int64, text Text) (Span, error) {
 	left, err := a.left.where(from, text)
 	if err != nil {
@@ -184,15 +239,28 @@ type minus struct {
 	right SimpleAddress
 }
 
-func (a minus) String() string                    { return a.left.String() + "-" + a.right.String() }
+func (a minus) String() string {
+	if r, ok := unsigned(a.right); ok {
+		// The reverse of a backward address is forward,
+		// which is written as a forward address from #0.
+		return a.left.String() + "-#0+" + r.String()
+	}
+	return a.left.String() + "-" + a.right.String()
+}
+
 func (a minus) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a minus) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a minus) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a minus) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a minus) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a minus) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
 func (a minus) Where(text Text) (Span, error)         { return a.where(0, text) }
 
+func (a minus) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a minus) where(from int64, text Text) (Span, error) {
 	left, err := a.left.where(from, text)
 	if err != nil {
@@ -210,6 +278,31 @@ type SimpleAddress interface {
 	reverse() SimpleAddress
 }
 
+// Unsigned returns the forward form of a backward Rune or Line address,
+// possibly clamped, and true.
+// If a is not a backward Rune or Line address, unsigned returns a and false.
+//
+// There is no syntax for negative rune or line numbers,
+// so the Strings of backward addresses are written in terms of their forward form.
+func unsigned(a SimpleAddress) (SimpleAddress, bool) {
+	switch a := a.(type) {
+	case runeAddr:
+		if a < 0 {
+			return -a, true
+		}
+	case line:
+		if a.rev {
+			a.rev = false
+			return a, true
+		}
+	case clamp:
+		if u, ok := unsigned(a.addr); ok {
+			return clamp{u}, true
+		}
+	}
+	return a, false
+}
+
 type clamp struct{ addr SimpleAddress }
 
 // Clamp returns the SimpleAddress, a,
@@ -217,17 +310,29 @@ type clamp struct{ addr SimpleAddress }
 // Where a would return a RangeError,
 // Clamp(a) returns the empty Address
 // at the beginning or end of the text.
-func Clamp(a SimpleAddress) SimpleAddress         { return clamp{a} }
-func (a clamp) String() string                    { return "!" + a.addr.String() }
+func Clamp(a SimpleAddress) SimpleAddress { return clamp{a} }
+
+func (a clamp) String() string {
+	if u, ok := unsigned(a.addr); ok {
+		return "#0-!" + u.String()
+	}
+	return "!" + a.addr.String()
+}
+
 func (a clamp) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a clamp) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a clamp) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a clamp) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a clamp) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a clamp) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
 func (a clamp) reverse() SimpleAddress                { return Clamp(a.addr.reverse()) }
 func (a clamp) Where(text Text) (Span, error)         { return a.where(0, text) }
 
+func (a clamp) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a clamp) where(from int64, text Text) (Span, error) {
 	s, err := a.addr.where(from, text)
 	if r, ok := err.(RangeError); ok {
@@ -242,12 +347,17 @@ func (a end) String() string                    { return "$" }
 func (a end) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a end) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a end) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a end) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a end) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a end) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
 func (a end) reverse() SimpleAddress                { return a }
 func (a end) Where(text Text) (Span, error)         { return a.where(0, text) }
 
+func (a end) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a end) where(from int64, text Text) (Span, error) {
 	size := text.Size()
 	return Span{size, size}, nil
@@ -259,18 +369,29 @@ type line struct {
 }
 
 // Line returns the Address of the nth full line.
-// A negative n is interpreted as n=0.
+// A negative n counts lines backward from where the Address is evaluated,
+// as if Line(-n) were the right-hand operand of Minus.
+// For example, Dot.Plus(Line(-1)) is the line before the end of dot.
+// A \r\n line ending is a single newline;
+// a line Address is never evaluated from between the \r and the \n.
 func Line(n int) SimpleAddress {
 	if n < 0 {
-		return line{}
+		return line{n: -n, rev: true}
 	}
 	return line{n: n}
 }
 
-func (a line) String() string                    { return strconv.Itoa(int(a.n)) }
+func (a line) String() string {
+	if a.rev {
+		return "#0-" + strconv.Itoa(a.n)
+	}
+	return strconv.Itoa(a.n)
+}
+
 func (a line) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a line) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a line) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a line) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a line) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a line) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
@@ -282,14 +403,67 @@ func (a line) reverse() SimpleAddress {
 
 func (a line) Where(text Text) (Span, error) { return a.where(0, text) }
 
+func (a line) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a line) where(from int64, text Text) (Span, error) {
+	from, err := beforeCRLF(from, text)
+	if err != nil {
+		return Span{}, err
+	}
 	if a.rev {
 		return lineBackward(a.n, from, text)
 	}
 	return lineForward(a.n, from, text)
 }
 
+// BeforeCRLF returns the offset of the \r
+// if offs is between the \r and \n of a \r\n line ending,
+// and otherwise returns offs.
+// Line addresses treat \r\n as a single line ending,
+// so they never identify a Span that ends between the two.
+func beforeCRLF(offs int64, text Text) (int64, error) {
+	if offs <= 0 || offs >= text.Size() {
+		return offs, nil
+	}
+	rr := text.RuneReader(Span{offs - 1, offs + 1})
+	for _, want := range []rune{'\r', '\n'} {
+		switch r, _, err := rr.ReadRune(); {
+		case err != nil:
+			return 0, err
+		case r != want:
+			return offs, nil
+		}
+	}
+	return offs - 1, nil
+}
+
+// Newlines returns the newline index of the Text
+// and whether the Text has one.
+func newlines(text Text) ([]int64, bool) {
+	switch text := text.(type) {
+	case *Buffer:
+		return text.newlines, true
+	case withDot:
+		return newlines(text.Text)
+	case ignoreApply:
+		return newlines(text.Editor)
+	case *batchEditor:
+		return newlines(text.Editor)
+	}
+	return nil, false
+}
+
+// SearchNewlines returns the index of the first newline at or after offs.
+func searchNewlines(nls []int64, offs int64) int {
+	return sort.Search(len(nls), func(i int) bool { return nls[i] >= offs })
+}
+
 func lineForward(n int, from int64, text Text) (Span, error) {
+	if nls, ok := newlines(text); ok {
+		return lineForwardIndex(n, from, text.Size(), nls)
+	}
 	s := Span{from, from}
 	if from > 0 {
 		// Position s1 at the beginning of the next full line.
@@ -344,7 +518,48 @@ func lineForward(n int, from int64, text Text) (Span, error) {
 	return s, nil
 }
 
+// LineForwardIndex is lineForward using a newline index.
+func lineForwardIndex(n int, from, size int64, nls []int64) (Span, error) {
+	s := Span{from, from}
+	if from > 0 {
+		// Position s1 at the beginning of the next full line,
+		// or at from if it is already at the beginning of a full line.
+		if k := searchNewlines(nls, from-1); k < len(nls) {
+			s[1] = nls[k] + 1
+		} else {
+			s[1] = size
+		}
+		if n > 0 {
+			s[0] = s[1]
+		}
+	}
+	if n > 0 && s[1] < size {
+		k := searchNewlines(nls, s[1])
+		switch avail := len(nls) - k; {
+		case n <= avail:
+			if n > 1 {
+				s[0] = nls[k+n-2] + 1
+			}
+			s[1] = nls[k+n-1] + 1
+			n = 0
+		default:
+			if avail > 0 {
+				s[0] = nls[len(nls)-1] + 1
+			}
+			s[1] = size
+			n -= avail
+		}
+	}
+	if n > 1 || n == 1 && s[1] < size {
+		return Span{}, RangeError(size)
+	}
+	return s, nil
+}
+
 func lineBackward(n int, from int64, text Text) (Span, error) {
+	if nls, ok := newlines(text); ok {
+		return lineBackwardIndex(n, from, text.Size(), nls)
+	}
 	s := Span{from, from}
 	if s[0] < text.Size() {
 		rr := text.RuneReader(Span{from, 0})
@@ -395,6 +610,117 @@ func lineBackward(n int, from int64, text Text) (Span, error) {
 	return s, nil
 }
 
+// LineBackwardIndex is lineBackward using a newline index.
+func lineBackwardIndex(n int, from, size int64, nls []int64) (Span, error) {
+	// LineStart returns the start of the line containing offs.
+	lineStart := func(offs int64) int64 {
+		if k := searchNewlines(nls, offs) - 1; k >= 0 {
+			return nls[k] + 1
+		}
+		return 0
+	}
+	s := Span{from, from}
+	if s[0] < size {
+		s[0] = lineStart(s[0])
+	}
+	if n == 0 {
+		s[0] = lineStart(s[0])
+		return s, nil
+	}
+	// K is the index of the last newline before s0.
+	k := searchNewlines(nls, s[0]) - 1
+	if n > k+1 {
+		if n-(k+1) > 1 {
+			return Span{}, RangeError(0)
+		}
+		return Span{0, 0}, nil
+	}
+	nl := nls[k-n+1]
+	return Span{lineStart(nl), nl + 1}, nil
+}
+
+type col struct {
+	n   int
+	rev bool
+}
+
+// Col returns the Address of the empty Span before rune n of a line,
+// counting from 1.
+// A non-positive n is interpreted as n=1.
+// If the line has fewer than n runes,
+// the Span is clamped to the end of the line,
+// before its terminating newline or \r\n.
+//
+// If Col is the right-hand operand of Plus,
+// the line is the one containing the end of the left-hand operand,
+// or the line ending at it, if it is at the beginning of a line.
+// So, for example, Line(12).Plus(Col(5)) is before the 5th rune of line 12.
+// If Col is the right-hand operand of Minus,
+// the line is the one containing the start of the left-hand operand.
+// Otherwise, the line is the first line.
+func Col(n int) SimpleAddress {
+	if n < 1 {
+		n = 1
+	}
+	return col{n: n}
+}
+
+func (a col) String() string                    { return ":" + strconv.Itoa(a.n) }
+func (a col) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
+func (a col) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
+func (a col) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a col) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
+
+func (a col) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
+func (a col) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
+
+func (a col) reverse() SimpleAddress {
+	a.rev = !a.rev
+	return a
+}
+
+func (a col) Where(text Text) (Span, error) { return a.where(0, text) }
+
+func (a col) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
+func (a col) where(from int64, text Text) (Span, error) {
+	start := from
+	if !a.rev && start > 0 {
+		// Start in the line of the rune before from,
+		// which ends at from if from is at the beginning of a line.
+		_, w, err := text.RuneReader(Span{start, 0}).ReadRune()
+		if err != nil && err != io.EOF {
+			return Span{}, err
+		}
+		start -= int64(w)
+	}
+	rr := text.RuneReader(Span{start, 0})
+	for {
+		r, w, err := rr.ReadRune()
+		if err == io.EOF || err == nil && r == '\n' {
+			break
+		}
+		if err != nil {
+			return Span{}, err
+		}
+		start -= int64(w)
+	}
+	rr = &crlfReader{rr: text.RuneReader(Span{start, text.Size()})}
+	for n := a.n; n > 1; n-- {
+		r, w, err := rr.ReadRune()
+		if err == io.EOF || err == nil && r == '\n' {
+			break
+		}
+		if err != nil {
+			return Span{}, err
+		}
+		start += int64(w)
+	}
+	return Span{start, start}, nil
+}
+
 type mark rune
 
 // Mark returns the Address of the named mark rune.
@@ -416,6 +742,7 @@ func (a mark) String() string {
 func (a mark) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a mark) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a mark) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a mark) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a mark) Plus(b SimpleAddress) AdditiveAddress   { return plus{left: a, right: b} }
 func (a mark) Minus(b SimpleAddress) AdditiveAddress  { return minus{left: a, right: b} }
@@ -423,6 +750,10 @@ func (a mark) reverse() SimpleAddress                 { return a }
 func (a mark) Where(text Text) (Span, error)          { return a.where(0, text) }
 func (a mark) where(_ int64, text Text) (Span, error) { return text.Mark(rune(a)), nil }
 
+func (a mark) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 type regexpAddr struct {
 	regexp string
 	rev    bool
@@ -440,6 +771,10 @@ type regexpAddr struct {
 // The regular expression syntax is that of the standard library regexp package.
 // The syntax is documented here: https://github.com/google/re2/wiki/Syntax.
 // All regular expressions are wrapped in (?m:<re>), making them multi-line by default.
+// Unless the regular expression has a literal \r,
+// a \r\n line ending is matched as a single \n,
+// so, for example, $ matches before the \r\n
+// and . does not match the \r.
 // In a forward search, the relative start location
 // (the . mark or the right-hand operand of +)
 // is considered to be the beginning of text.
@@ -455,6 +790,7 @@ func (a regexpAddr) String() string                    { return "/" + Escape(a.r
 func (a regexpAddr) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a regexpAddr) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a regexpAddr) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a regexpAddr) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a regexpAddr) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a regexpAddr) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
@@ -466,6 +802,10 @@ func (a regexpAddr) reverse() SimpleAddress {
 
 func (a regexpAddr) Where(text Text) (Span, error) { return a.where(text.Mark('.')[1], text) }
 
+func (a regexpAddr) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a regexpAddr) where(from int64, text Text) (Span, error) {
 	re, err := regexpCompile(a.regexp)
 	if err != nil {
@@ -483,15 +823,19 @@ func (a regexpAddr) where(from int64, text Text) (Span, error) {
 	return Span{int64(m[0]), int64(m[1])}, nil
 }
 
-func match(re *regexp.Regexp, s Span, text Text) []int {
-	m := re.FindReaderSubmatchIndex(text.RuneReader(s))
+func match(re *textRegexp, s Span, text Text) []int {
+	rr := text.RuneReader(s)
+	if !re.matchesCR {
+		rr = &crlfReader{rr: rr}
+	}
+	m := re.FindReaderSubmatchIndex(rr)
 	for i := range m {
 		m[i] += int(s[0])
 	}
 	return m
 }
 
-func nextMatch(re *regexp.Regexp, from int64, text Text, wrap bool) []int {
+func nextMatch(re *textRegexp, from int64, text Text, wrap bool) []int {
 	m := match(re, Span{from, text.Size()}, text)
 	if len(m) >= 2 && m[0] <= m[1] {
 		return m
@@ -502,7 +846,7 @@ func nextMatch(re *regexp.Regexp, from int64, text Text, wrap bool) []int {
 	return nil
 }
 
-func prevMatch(re *regexp.Regexp, from int64, text Text, wrap bool) []int {
+func prevMatch(re *textRegexp, from int64, text Text, wrap bool) []int {
 	var prev []int
 	for {
 		span := Span{0, from}
@@ -531,35 +875,108 @@ func prevMatch(re *regexp.Regexp, from int64, text Text, wrap bool) []int {
 	return nil
 }
 
-func regexpCompile(re string) (*regexp.Regexp, error) {
+func hasCR(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpLiteral {
+		for _, r := range re.Rune {
+			if r == '\r' {
+				return true
+			}
+		}
+	}
+	for _, sub := range re.Sub {
+		if hasCR(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// A crlfReader is a RuneReader that reads \r\n as a single \n
+// with the combined width of the two runes.
+type crlfReader struct {
+	rr io.RuneReader
+	// If peeked is true, r, w, and err are the results
+	// of a ReadRune following a \r that was not a \n.
+	peeked bool
+	r      rune
+	w      int
+	err    error
+}
+
+func (cr *crlfReader) ReadRune() (rune, int, error) {
+	var r rune
+	var w int
+	var err error
+	if cr.peeked {
+		r, w, err = cr.r, cr.w, cr.err
+		cr.peeked = false
+	} else {
+		r, w, err = cr.rr.ReadRune()
+	}
+	if err != nil || r != '\r' {
+		return r, w, err
+	}
+	cr.r, cr.w, cr.err = cr.rr.ReadRune()
+	if cr.err == nil && cr.r == '\n' {
+		return '\n', w + cr.w, nil
+	}
+	cr.peeked = true
+	return r, w, nil
+}
+
+// A textRegexp is a compiled regular expression
+// for matching against a Text.
+type textRegexp struct {
+	*regexp.Regexp
+	// MatchesCR is whether the regular expression has a literal \r.
+	// If not, it matches a \r\n line ending as a single \n.
+	matchesCR bool
+}
+
+func regexpCompile(re string) (*textRegexp, error) {
 	if re == "\\" || len(re) > 2 && re[len(re)-1] == '\\' && re[len(re)-2] != '\\' {
 		// Escape a trailing, unescaped \.
 		re = re + "\\"
 	}
-	return regexp.Compile("(?m:" + re + ")")
+	re = "(?m:" + re + ")"
+	compiled, err := regexp.Compile(re)
+	if err != nil {
+		return nil, err
+	}
+	// The expression compiled, so it parses.
+	parsed, _ := syntax.Parse(re, syntax.Perl)
+	return &textRegexp{Regexp: compiled, matchesCR: hasCR(parsed)}, nil
 }
 
 type runeAddr int64
 
 // Rune returns the Address of the empty Span after rune n.
-// A negative n is interpreted as n=0.
-func Rune(n int64) SimpleAddress {
-	if n < 0 {
-		return runeAddr(0)
+// A negative n counts runes backward from where the Address is evaluated.
+// For example, Dot.Plus(Rune(-2)) is the empty Span
+// two runes before the end of dot.
+func Rune(n int64) SimpleAddress { return runeAddr(n) }
+
+func (a runeAddr) String() string {
+	if a < 0 {
+		return "#0-#" + strconv.FormatInt(int64(-a), 10)
 	}
-	return runeAddr(n)
+	return "#" + strconv.FormatInt(int64(a), 10)
 }
 
-func (a runeAddr) String() string                    { return "#" + strconv.FormatInt(int64(a), 10) }
 func (a runeAddr) To(b AdditiveAddress) Address      { return to{left: a, right: b} }
 func (a runeAddr) Then(b AdditiveAddress) Address    { return then{left: a, right: b} }
 func (a runeAddr) Between(b AdditiveAddress) Address { return between{left: a, right: b} }
+func (a runeAddr) At(b AdditiveAddress) Address      { return at{left: a, right: b} }
 
 func (a runeAddr) Plus(b SimpleAddress) AdditiveAddress  { return plus{left: a, right: b} }
 func (a runeAddr) reverse() SimpleAddress                { return runeAddr(-a) }
 func (a runeAddr) Minus(b SimpleAddress) AdditiveAddress { return minus{left: a, right: b} }
 func (a runeAddr) Where(text Text) (Span, error)         { return a.where(0, text) }
 
+func (a runeAddr) WhereFrom(text Text, dot Span) (Span, error) {
+	return a.Where(withDot{Text: text, dot: dot})
+}
+
 func (a runeAddr) where(from int64, text Text) (Span, error) {
 	delta := 1
 	s := Span{from, text.Size()}
@@ -589,18 +1006,18 @@ func (a runeAddr) where(from int64, text Text) (Span, error) {
 
 const (
 	digits      = "0123456789"
-	simpleFirst = "!#/$.'" + digits
+	simpleFirst = "!#/$.':" + digits
 )
 
 // Addr parses and returns an address.
 //
 // The address syntax for address a is:
-// 	a: {a} , {aa} | {a} ; {aa} | {aa}
+// 	a: {a} , {aa} | {a} ; {aa} | {a} ~ {aa} | {a} @ {aa} | {aa}
 // 	aa: {aa} + {sa} | {aa} - {sa} | {aa} {sa} | {!} {sa}
-// 	sa: $ | . | 'r | #{n} | n | / regexp {/}
+// 	sa: $ | . | 'r | #{n} | n | :{n} | / regexp {/}
 // 	n: [0-9]+
 // 	r: any non-space rune
-// 	regexp: any valid re1 regular expression
+// 	regexp: any valid regular expression
 // All operators are left-associative.
 //
 // Production sa describes a simple addresse:
@@ -609,6 +1026,12 @@ const (
 //	'{r} is the address of the non-space rune, r. If r is missing, . is used.
 //	#{n} is the empty string after rune number n. If n is missing then 1 is used.
 //	n is the nth line in the buffer. 0 is the string before the first full line.
+//	:{n} is the empty string before rune number n of a line, counting from 1.
+//		If n is missing then 1 is used.
+//		If the line is shorter, it is the empty string at the end of the line.
+//		The line is that of the end of the first operand of +,
+//		or of the start of the first operand of -.
+//		For example, 12:5 is before the 5th rune of line 12.
 //	'/' regexp {'/'} is the first match of the regular expression.
 // 		The regexp uses the syntax of the standard library regexp package,
 // 		except that \, raw newlines, and / must be escaped with \.
@@ -622,6 +1045,10 @@ const (
 // 	.+25 is the 25th line after dot, or an error if there are fewer than 25 lines after dot.
 // 	.+!25 is the 25th line after dot, or $ if there are fewer than 25 lines after dot.
 //
+// There is no syntax for negative rune or line numbers.
+// The Address Rune(-n) is written #0-#{n},
+// and the Address Line(-n) is written #0-{n}.
+//
 // Production aa describes an additive address:
 //	{aa} '+' {sa} is the second address evaluated from the end of the first.
 //		If the first address is missing, . is used.
@@ -648,6 +1075,10 @@ const (
 // 		of the end of the first and the end of the second.
 //		If the first address is missing, 0 is used.
 //		If the second address is missing, $ is used.
+//	{a} '@' {aa} is the second address evaluated with dot set to the first.
+// 		For example, 'm@/abc/ is the next match of abc after mark m.
+//		If the first address is missing, 0 is used.
+//		If the second address is missing, . is used.
 //
 // Addresses are terminated by a newline, end of input,
 // or end of the address.
@@ -683,7 +1114,7 @@ func parseAddressTail(left Address, rs io.RuneScanner) (Address, error) {
 		break
 	case err != nil:
 		return nil, err
-	case r == ',' || r == ';' || r == '~':
+	case r == ',' || r == ';' || r == '~' || r == '@':
 		if left == nil {
 			left = Line(0)
 		}
@@ -691,7 +1122,10 @@ func parseAddressTail(left Address, rs io.RuneScanner) (Address, error) {
 		if err != nil {
 			return nil, err
 		}
-		if right == nil {
+		switch {
+		case right == nil && r == '@':
+			right = Dot
+		case right == nil:
 			right = End
 		}
 		var a Address
@@ -700,6 +1134,8 @@ func parseAddressTail(left Address, rs io.RuneScanner) (Address, error) {
 			a = left.To(right)
 		case ';':
 			a = left.Then(right)
+		case '@':
+			a = left.At(right)
 		default:
 			a = left.Between(right)
 		}
@@ -785,6 +1221,8 @@ func parseSimpleAddress(rs io.RuneScanner) (SimpleAddress, error) {
 		return parseRuneAddr(rs)
 	case strings.ContainsRune(digits, r):
 		return parseLineAddr(r, rs)
+	case r == ':':
+		return parseColAddr(rs)
 	case r == '/':
 		re, err := parseDelimited(r, rs)
 		if err != nil {
@@ -847,6 +1285,18 @@ func parseLineAddr(r rune, rs io.RuneScanner) (SimpleAddress, error) {
 	return Line(l), err
 }
 
+func parseColAddr(rs io.RuneScanner) (SimpleAddress, error) {
+	s, err := scanDigits(rs)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		s = "1"
+	}
+	c, err := strconv.Atoi(s)
+	return Col(c), err
+}
+
 func scanDigits(rs io.RuneScanner) (string, error) {
 	var s []rune
 	for {
@@ -136,6 +136,16 @@ func TestAddr(t *testing.T) {
 		{a: "~-#5", want: Line(0).Between(Dot.Minus(Rune(5)))},
 		{a: " ~ - #5", want: Line(0).Between(Dot.Minus(Rune(5)))},
 
+		{a: "@", want: Line(0).At(Dot)},
+		{a: "@xyz", left: "xyz", want: Line(0).At(Dot)},
+		{a: " @ ", want: Line(0).At(Dot)},
+		{a: "@\n1", left: "\n1", want: Line(0).At(Dot)},
+		{a: "@1", want: Line(0).At(Line(1))},
+		{a: "1@", want: Line(1).At(Dot)},
+		{a: "'m@/abc/", want: Mark('m').At(Regexp("abc"))},
+		{a: " 'm @ / abc", want: Mark('m').At(Regexp(" abc"))},
+		{a: "1@+#5", want: Line(1).At(Dot.Plus(Rune(5)))},
+
 		// Right associative.
 		{a: "#0+#1+#2", want: Rune(0).Plus(Rune(1)).Plus(Rune(2))},
 		{a: "#0+#1-#2", want: Rune(0).Plus(Rune(1)).Minus(Rune(2))},
@@ -216,10 +226,13 @@ func TestAddressString(t *testing.T) {
 		{addr: All},
 		{addr: Rune(0)},
 		{addr: Rune(100)},
-		{addr: Rune(-100), want: Rune(0)},
+		{addr: Rune(-100), want: Rune(0).Minus(Rune(100))},
 		{addr: Line(0)},
 		{addr: Line(100)},
-		{addr: Line(-100), want: Line(0)},
+		{addr: Line(-100), want: Rune(0).Minus(Line(100))},
+		{addr: Col(1)},
+		{addr: Col(100)},
+		{addr: Col(-100), want: Col(1)},
 		{addr: Mark('a')},
 		{addr: Mark('z')},
 		{addr: Mark(' ')},
@@ -227,8 +240,17 @@ func TestAddressString(t *testing.T) {
 		{addr: Dot.Plus(Line(1))},
 		{addr: Dot.Minus(Line(1))},
 		{addr: Dot.Minus(Line(1)).Plus(Line(1))},
+		{addr: Dot.Plus(Rune(-1)), want: Dot.Plus(Rune(0)).Minus(Rune(1))},
+		{addr: Dot.Minus(Rune(-1)), want: Dot.Minus(Rune(0)).Plus(Rune(1))},
+		{addr: Dot.Minus(Line(-1)), want: Dot.Minus(Rune(0)).Plus(Line(1))},
+		{addr: Clamp(Rune(-1)), want: Rune(0).Minus(Clamp(Rune(1)))},
+		{addr: Dot.Minus(Clamp(Line(-1))), want: Dot.Minus(Rune(0)).Plus(Clamp(Line(1)))},
+		{addr: Line(12).Plus(Col(5))},
+		{addr: Line(12).Minus(Col(5))},
 		{addr: Rune(1).To(Rune(2))},
 		{addr: Rune(1).Then(Rune(2))},
+		{addr: Rune(1).Between(Rune(2))},
+		{addr: Mark('m').At(Regexp("abc"))},
 		{addr: Regexp("func").Plus(Regexp("[(]"))},
 	}
 	for _, test := range tests {
@@ -303,8 +325,10 @@ func TestIOErrors(t *testing.T) {
 		}
 
 		// All subsequent reads will be errors.
+		// The Buffer is wrapped to hide its line index,
+		// so that line addresses must read the text.
 		f.error = errors.New("read error")
-		if a, err := addr.Where(buf); !matchesError(test.error, err) {
+		if a, err := addr.Where(struct{ Text }{buf}); !matchesError(test.error, err) {
 			t.Errorf("Addr(%q).addr()=%v,%v, want addr{},%q", test, a, err, test.error)
 			continue
 		}
@@ -529,16 +553,41 @@ var runeTests = []editTest{
 		want:  "{..}abc{aa}",
 	},
 	{
-		name:  "negative is zero",
+		name:  "negative out of range",
 		given: "abc{..}",
 		do:    address(Rune(-1)),
+		want:  "abc{..}",
+		error: "out of range",
+	},
+	{
+		name:  "clamped negative",
+		given: "abc{..}",
+		do:    address(Clamp(Rune(-1))),
 		want:  "{aa}abc{..}",
 	},
 	{
 		name:  "plus negative rune",
 		given: "{..}abcdefg",
-		do:    address(Rune(3).Plus(Rune(-2))), // #3+#0
-		want:  "{..}abc{aa}defg",
+		do:    address(Rune(3).Plus(Rune(-2))), // #3+#0-#2
+		want:  "{..}a{aa}bcdefg",
+	},
+	{
+		name:  "minus negative rune",
+		given: "{..}abcdefg",
+		do:    address(Rune(3).Minus(Rune(-2))), // #3-#0+#2
+		want:  "{..}abcde{aa}fg",
+	},
+	{
+		name:  "minus clamped negative rune",
+		given: "{..}abcdefg",
+		do:    address(Rune(3).Minus(Clamp(Rune(-10)))), // #3-#0+!#10
+		want:  "{..}abcdefg{aa}",
+	},
+	{
+		name:  "plus negative rune plus line",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Rune(5).Plus(Rune(-2)).Plus(Line(1))), // #5+#0-#2+1
+		want:  "{..}abc\n{a}def\n{a}ghi",
 	},
 	{
 		name:  "between",
@@ -628,11 +677,18 @@ var lineTests = []editTest{
 		want:  "{..}abc\n{a}xyz\n{a}",
 	},
 	{
-		name:  "negative is zero",
+		name:  "negative from the beginning",
 		given: "{..}abc",
 		do:    address(Line(-1)),
 		want:  "{..aa}abc",
 	},
+	{
+		name:  "negative out of range",
+		given: "{..}abc",
+		do:    address(Line(-2)),
+		want:  "{..}abc",
+		error: "out of range",
+	},
 	{
 		name:  "plus line to EOF",
 		given: "abc\n{..}αβξ",
@@ -648,8 +704,14 @@ var lineTests = []editTest{
 	{
 		name:  "plus negative line",
 		given: "{..}abc\ndef\nghi",
-		do:    address(Line(2).Plus(Line(-2))), // 2+0
-		want:  "{..}abc\ndef\n{aa}ghi",
+		do:    address(Line(2).Plus(Line(-2))), // 2+#0-2
+		want:  "{..a}abc\n{a}def\nghi",
+	},
+	{
+		name:  "minus negative line",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(1).Minus(Line(-2))), // 1-#0+2
+		want:  "{..}abc\n{a}def\n{a}ghi",
 	},
 	{
 		name:  "between",
@@ -657,6 +719,42 @@ var lineTests = []editTest{
 		do:    address(Line(2).Between(Line(1))),
 		want:  "{..}{a}abc\ndef\n{a}ghi",
 	},
+	{
+		name:  "CRLF line",
+		given: "{..}abc\r\ndef\r\nghi",
+		do:    address(Line(2)),
+		want:  "{..}abc\r\n{a}def\r\n{a}ghi",
+	},
+	{
+		name:  "CRLF last line",
+		given: "{..}abc\r\ndef\r\n",
+		do:    address(End.Minus(Line(1))),
+		want:  "{..}abc\r\n{a}def\r\n{a}",
+	},
+	{
+		name:  "CRLF line 0 from between CR and LF",
+		given: "{..}abc\r\ndef",
+		do:    address(Rune(4).Plus(Line(0))),
+		want:  "{..}abc{a}\r\n{a}def",
+	},
+	{
+		name:  "CRLF minus line 0 from between CR and LF",
+		given: "{..}abc\r\ndef",
+		do:    address(Rune(4).Minus(Line(0))),
+		want:  "{..a}abc{a}\r\ndef",
+	},
+	{
+		name:  "CRLF line then $",
+		given: "{..}abc\r\ndef\r\nghi",
+		do:    address(Line(1).Plus(Regexp("$"))),
+		want:  "{..}abc\r\ndef{aa}\r\nghi",
+	},
+	{
+		name:  "CRLF line to $",
+		given: "{..}abc\r\ndef\r\nghi",
+		do:    address(Line(2).To(Line(1).Plus(Regexp("$")))),
+		want:  "{..}abc\r\n{a}def{a}\r\nghi",
+	},
 	// BUG(eaburns): This should be an out of range error.
 	{
 		name:  "plus to out of range",
@@ -667,6 +765,111 @@ var lineTests = []editTest{
 	},
 }
 
+var colTests = []editTest{
+	{
+		name:  "empty buffer",
+		given: "{..}",
+		do:    address(Col(5)),
+		want:  "{..aa}",
+	},
+	{
+		name:  "first line",
+		given: "{..}abc\ndef",
+		do:    address(Col(2)),
+		want:  "{..}a{aa}bc\ndef",
+	},
+	{
+		name:  "col 0 is col 1",
+		given: "{..}abc\ndef",
+		do:    address(Col(0)),
+		want:  "{..aa}abc\ndef",
+	},
+	{
+		name:  "line plus col",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(2).Plus(Col(3))), // 2:3
+		want:  "{..}abc\nde{aa}f\nghi",
+	},
+	{
+		name:  "line minus col",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(2).Minus(Col(3))),
+		want:  "{..}abc\nde{aa}f\nghi",
+	},
+	{
+		name:  "last line plus col",
+		given: "{..}abc\ndef",
+		do:    address(Line(2).Plus(Col(2))),
+		want:  "{..}abc\nd{aa}ef",
+	},
+	{
+		name:  "clamp to end of line",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(2).Plus(Col(100))),
+		want:  "{..}abc\ndef{aa}\nghi",
+	},
+	{
+		name:  "clamp to end of CRLF line",
+		given: "{..}abc\r\ndef\r\n",
+		do:    address(Line(1).Plus(Col(100))),
+		want:  "{..}abc{aa}\r\ndef\r\n",
+	},
+	{
+		name:  "lone CR is not a line end",
+		given: "{..}a\rbc\n",
+		do:    address(Line(1).Plus(Col(3))),
+		want:  "{..}a\r{aa}bc\n",
+	},
+	{
+		name:  "clamp to end of text",
+		given: "{..}abc\ndef",
+		do:    address(Line(2).Plus(Col(100))),
+		want:  "{..}abc\ndef{aa}",
+	},
+	{
+		name:  "empty last line",
+		given: "{..}abc\n",
+		do:    address(End.Minus(Col(3))),
+		want:  "{..}abc\n{aa}",
+	},
+	{
+		name:  "from the middle of a line",
+		given: "abc\nd{..}ef\nghi",
+		do:    address(Dot.Plus(Col(3))),
+		want:  "abc\nd{..}e{aa}f\nghi",
+	},
+	{
+		name:  "rune offset in the line",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(2).Plus(Col(2)).Plus(Rune(1))), // 2:2+#1
+		want:  "{..}abc\nde{aa}f\nghi",
+	},
+	{
+		name:  "regexp plus col",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Regexp("e").Minus(Col(1))),
+		want:  "{..}abc\n{aa}def\nghi",
+	},
+	{
+		name:  "between",
+		given: "{..}abc\ndef\nghi",
+		do:    address(Line(2).Plus(Col(2)).Between(Line(3).Plus(Col(2)))),
+		want:  "{..}abc\nd{a}ef\ng{a}hi",
+	},
+}
+
+func TestAddressCol(t *testing.T) {
+	for _, test := range colTests {
+		test.run(t)
+	}
+}
+
+func TestAddressColFromString(t *testing.T) {
+	for _, test := range colTests {
+		test.runFromString(t)
+	}
+}
+
 func TestAddressLine(t *testing.T) {
 	for _, test := range lineTests {
 		test.run(t)
@@ -679,6 +882,61 @@ func TestAddressLineFromString(t *testing.T) {
 	}
 }
 
+// TestAddressLineIndex tests that line addresses
+// evaluated using the Buffer's line index
+// match those evaluated by reading the text.
+func TestAddressLineIndex(t *testing.T) {
+	texts := []string{
+		"",
+		"\n",
+		"\n\n",
+		"abc",
+		"abc\n",
+		"abc\ndef",
+		"abc\ndef\n",
+		"\nabc\n\ndef\n\n",
+		"a\nb\nc\nd\ne",
+	}
+	for _, str := range texts {
+		buf := NewBuffer()
+		defer buf.Close()
+		if _, err := buf.Change(Span{}, strings.NewReader(str)); err != nil {
+			t.Fatalf("buf.Change(Span{}, %q)=_,%v, want _,nil", str, err)
+		}
+		if err := buf.Apply(); err != nil {
+			t.Fatalf("buf.Apply()=%v, want nil", err)
+		}
+		// Wrapping the Buffer hides its line index.
+		slow := struct{ Editor }{buf}
+		size := buf.Size()
+		for s0 := int64(0); s0 <= size; s0++ {
+			for s1 := s0; s1 <= size; s1++ {
+				dot := Span{s0, s1}
+				for n := 0; n <= 7; n++ {
+					for _, a := range []Address{
+						Line(n),
+						Dot.Plus(Line(n)),
+						Dot.Minus(Line(n)),
+					} {
+						got, gotErr := a.WhereFrom(buf, dot)
+						want, wantErr := a.WhereFrom(slow, dot)
+						if got != want || !reflect.DeepEqual(gotErr, wantErr) {
+							t.Errorf("%q: %s.WhereFrom(%v)=%v,%v, want %v,%v",
+								str, a, dot, got, gotErr, want, wantErr)
+						}
+					}
+				}
+				gotL0, gotL1, gotErr := lines(buf, dot)
+				wantL0, wantL1, wantErr := lines(slow, dot)
+				if gotL0 != wantL0 || gotL1 != wantL1 || gotErr != wantErr {
+					t.Errorf("%q: lines(%v)=%d,%d,%v, want %d,%d,%v",
+						str, dot, gotL0, gotL1, gotErr, wantL0, wantL1, wantErr)
+				}
+			}
+		}
+	}
+}
+
 var regexpTests = []editTest{
 	{
 		name:  "bad regexp",
@@ -990,6 +1248,42 @@ var regexpTests = []editTest{
 		do:    address(Dot.Plus(Regexp(`$`))),
 		want:  "a{..}bc{aa}\nxyz",
 	},
+	{
+		name:  "next $ before CRLF",
+		given: "a{..}bc\r\nxyz",
+		do:    address(Dot.Plus(Regexp(`$`))),
+		want:  "a{..}bc{aa}\r\nxyz",
+	},
+	{
+		name:  "$ before CRLF",
+		given: "{..}abc\r\nxyz",
+		do:    address(Regexp(`c$`)),
+		want:  "{..}ab{a}c{a}\r\nxyz",
+	},
+	{
+		name:  "dot does not match CR of CRLF",
+		given: "{..}abc\r\nxyz",
+		do:    address(Regexp(`.*`)),
+		want:  "{..a}abc{a}\r\nxyz",
+	},
+	{
+		name:  "newline matches CRLF",
+		given: "{..}abc\r\nxyz",
+		do:    address(Regexp(`c\n`)),
+		want:  "{..}ab{a}c\r\n{a}xyz",
+	},
+	{
+		name:  "lone CR",
+		given: "{..}abc\rxyz",
+		do:    address(Regexp(`c.x`)),
+		want:  "{..}ab{a}c\rx{a}yz",
+	},
+	{
+		name:  "literal CR",
+		given: "{..}abc\r\nxyz",
+		do:    address(Regexp(`\r$`)),
+		want:  "{..}abc{a}\r{a}\nxyz",
+	},
 	//	{
 	//		name:  "previous ^",
 	//		given: "abc\nxy{..}z",
@@ -1519,6 +1813,101 @@ func TestAddressBetweenFromString(t *testing.T) {
 	}
 }
 
+var atTests = []editTest{
+	{
+		name:  "no match",
+		given: "{..}abc",
+		do:    address(Regexp("xyz").At(Dot)),
+		want:  "{..}abc",
+		error: "no match",
+	},
+	{
+		name:  "at dot",
+		given: "a{..}bc",
+		do:    address(Dot.At(Dot)),
+		want:  "a{..aa}bc",
+	},
+	{
+		name:  "regexp at mark",
+		given: "{..}abc{bb}abc",
+		do:    address(Mark('b').At(Regexp("abc"))),
+		want:  "{..}abc{bba}abc{a}",
+	},
+	{
+		name:  "regexp at mark wraps",
+		given: "abc{..}abc{bb}",
+		do:    address(Mark('b').At(Regexp("abc"))),
+		want:  "{a}abc{a}{..}abc{bb}",
+	},
+	{
+		name:  "reverse regexp at mark",
+		given: "abc{bb}abc{..}",
+		do:    address(Mark('b').At(Dot.Minus(Regexp("abc")))),
+		want:  "{a}abc{abb}abc{..}",
+	},
+	{
+		name:  "line at mark",
+		given: "1{..}\n2{bb}\n3\n",
+		do:    address(Mark('b').At(Dot.Plus(Line(1)))),
+		want:  "1{..}\n2{bb}\n{a}3\n{a}",
+	},
+	{
+		name:  "at range",
+		given: "{..}abc abc abc",
+		do:    address(Rune(0).To(Rune(4)).At(Regexp("abc"))),
+		want:  "{..}abc {a}abc{a} abc",
+	},
+	{
+		name:  "to",
+		given: "{..}abc abc abc",
+		do:    address(Rune(4).At(Regexp("abc")).To(End)),
+		want:  "{..}abc {a}abc abc{a}",
+	},
+}
+
+func TestAddressAt(t *testing.T) {
+	for _, test := range atTests {
+		test.run(t)
+	}
+}
+
+func TestAddressAtFromString(t *testing.T) {
+	for _, test := range atTests {
+		test.runFromString(t)
+	}
+}
+
+func TestWhereFrom(t *testing.T) {
+	tests := []struct {
+		addr   Address
+		origin Span
+		want   Span
+	}{
+		{addr: Dot, origin: Span{1, 2}, want: Span{1, 2}},
+		{addr: End, origin: Span{1, 2}, want: Span{11, 11}},
+		{addr: Rune(3), origin: Span{1, 2}, want: Span{3, 3}},
+		{addr: Regexp("abc"), origin: Span{0, 0}, want: Span{0, 3}},
+		{addr: Regexp("abc"), origin: Span{0, 1}, want: Span{4, 7}},
+		{addr: Regexp("abc"), origin: Span{8, 10}, want: Span{0, 3}},
+		{addr: Dot.Minus(Regexp("abc")), origin: Span{8, 8}, want: Span{4, 7}},
+		{addr: Dot.Plus(Rune(2)), origin: Span{4, 7}, want: Span{9, 9}},
+		{addr: Dot.To(End), origin: Span{4, 7}, want: Span{4, 11}},
+		{addr: Rune(8).Then(Dot), origin: Span{4, 7}, want: Span{8, 8}},
+		{addr: Mark('m').At(Regexp("abc")), origin: Span{0, 0}, want: Span{8, 11}},
+	}
+	for _, test := range tests {
+		buf := newTestBuffer("{..}abc abc{mm} abc")
+		got, err := test.addr.WhereFrom(buf, test.origin)
+		if err != nil || got != test.want {
+			t.Errorf("%s.WhereFrom(buf, %v)=%v,%v, want %v,nil", test.addr, test.origin, got, err, test.want)
+		}
+		if dot := buf.Mark('.'); dot != (Span{}) {
+			t.Errorf("%s.WhereFrom(buf, %v) changed dot to %v", test.addr, test.origin, dot)
+		}
+		buf.Close()
+	}
+}
+
 var clampTests = []editTest{
 	{
 		name:  "clamp line",
@@ -4,7 +4,11 @@ package edit
 
 import (
 	"bufio"
+	"errors"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/eaburns/T/edit/runes"
 )
@@ -16,10 +20,119 @@ type Buffer struct {
 	pending, undo, redo *log
 	seq                 int32
 	marks               map[rune]Span
+	limits              Limits
+	// Newlines are the offsets of the newline runes, in ascending order.
+	newlines []int64
+
+	applyHooks  []func([]StagedChange) ([]StagedChange, error)
+	changeHooks []func([]AppliedChange)
+	// Changes are the changes made since changeHooks were last called.
+	// They are only recorded if there are changeHooks.
+	changes []AppliedChange
+
+	// Checkpoint, if non-nil, is the state saved by Checkpoint.
+	checkpoint *checkpoint
+}
+
+// A checkpoint is a saved state of a Buffer.
+type checkpoint struct {
+	// Undo is the size of the undo log.
+	undo  int64
+	seq   int32
+	marks map[rune]Span
+	// ClearRedo is whether a change was applied since the checkpoint.
+	// Apply sets clearRedo instead of resetting the redo log,
+	// so that Rollback can keep the redo history.
+	clearRedo bool
+}
+
+// A StagedChange is a change staged with the Change method of a Buffer.
+type StagedChange struct {
+	// Span is the Span of the text to change,
+	// in the text before any of the staged changes are made.
+	Span Span
+	// Text is the new text of the Span.
+	Text string
+}
+
+// An AppliedChange is a change made to the text of a Buffer.
+type AppliedChange struct {
+	// Span is the Span of the text that changed,
+	// in the text just before the change was made.
+	Span Span
+	// NewSize is the size of the Span after the change.
+	NewSize int64
+}
+
+// Limits are limits on the resources used by a Buffer.
+type Limits struct {
+	// MaxRunes is the maximum number of runes in the Buffer.
+	// If MaxRunes is 0, the number of runes is not limited.
+	MaxRunes int64
+
+	// CacheBytes is the number of bytes of runes
+	// that the Buffer caches in memory.
+	// The remainder are stored in temporary files.
+	// If CacheBytes is 0, DefaultCacheBytes is used.
+	CacheBytes int
+
+	// CacheBlocks is the number of blocks
+	// into which the cache of the Buffer's text is divided.
+	// The least recently used block is evicted
+	// to make room for another.
+	// More, smaller blocks help when accesses alternate
+	// between different parts of the text.
+	// If CacheBlocks is 0, 1 is used.
+	CacheBlocks int
+}
+
+// DefaultCacheBytes is the default number of bytes of runes
+// that a Buffer caches in memory.
+const DefaultCacheBytes = 4 * defaultBlockSize * runeBytes
+
+const (
+	defaultBlockSize = 1 << 12
+	runeBytes        = 4
+)
+
+// A SizeError is returned if a change would exceed
+// the MaxRunes limit of a Buffer.
+// The value of the error is the limit.
+type SizeError int64
+
+func (err SizeError) Error() string {
+	return "buffer size limit of " + strconv.FormatInt(int64(err), 10) + " runes exceeded"
 }
 
 // NewBuffer returns a new, empty Buffer.
-func NewBuffer() *Buffer { return newBuffer(runes.NewBuffer(1 << 12)) }
+func NewBuffer() *Buffer { return NewBufferLimits(Limits{}) }
+
+// NewBufferLimits returns a new, empty Buffer with the given Limits.
+func NewBufferLimits(l Limits) *Buffer {
+	if l.CacheBytes == 0 {
+		l.CacheBytes = DefaultCacheBytes
+	}
+	if l.CacheBlocks == 0 {
+		l.CacheBlocks = 1
+	}
+	// The cache is split evenly between the text and the three logs.
+	blockSize := l.CacheBytes / (4 * runeBytes)
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	textBlockSize := blockSize / l.CacheBlocks
+	if textBlockSize < 1 {
+		textBlockSize = 1
+	}
+	return &Buffer{
+		runes:   runes.NewBufferCache(textBlockSize, l.CacheBlocks),
+		undo:    newLogBlockSize(blockSize),
+		redo:    newLogBlockSize(blockSize),
+		pending: newLogBlockSize(blockSize),
+		marks:   make(map[rune]Span),
+		limits:  l,
+	}
+}
 
 func newBuffer(rs *runes.Buffer) *Buffer {
 	return &Buffer{
@@ -31,6 +144,55 @@ func newBuffer(rs *runes.Buffer) *Buffer {
 	}
 }
 
+// BufferStats are statistics about a Buffer's use of resources.
+type BufferStats struct {
+	// Runes is the number of runes in the Buffer.
+	Runes int64
+	// LogRunes is the number of runes in the Buffer's
+	// undo, redo, and staged change logs.
+	LogRunes int64
+	// Blocks is the number of blocks holding the runes
+	// of the Buffer and its logs.
+	Blocks int
+	// CacheBytes is the number of bytes of runes cached in memory.
+	CacheBytes int
+	// CacheHits is the number of accesses that were served by the cache.
+	// CacheMisses is the number of accesses that loaded a block into the cache.
+	CacheHits, CacheMisses int64
+	// CacheEvictions is the number of blocks removed from the cache
+	// to make room for other blocks.
+	CacheEvictions int64
+}
+
+// HitRate returns the fraction of accesses served by the cache.
+// If there have been no accesses, HitRate returns 0.
+func (s BufferStats) HitRate() float64 {
+	return runes.Stats{CacheHits: s.CacheHits, CacheMisses: s.CacheMisses}.HitRate()
+}
+
+// Stats returns statistics about the Buffer's use of resources.
+func (buf *Buffer) Stats() BufferStats {
+	text := buf.runes.Stats()
+	s := BufferStats{
+		Runes:          text.Runes,
+		Blocks:         text.Blocks,
+		CacheBytes:     text.CacheBytes,
+		CacheHits:      text.CacheHits,
+		CacheMisses:    text.CacheMisses,
+		CacheEvictions: text.CacheEvictions,
+	}
+	for _, l := range []*log{buf.pending, buf.undo, buf.redo} {
+		ls := l.buf.Stats()
+		s.LogRunes += ls.Runes
+		s.Blocks += ls.Blocks
+		s.CacheBytes += ls.CacheBytes
+		s.CacheHits += ls.CacheHits
+		s.CacheMisses += ls.CacheMisses
+		s.CacheEvictions += ls.CacheEvictions
+	}
+	return s
+}
+
 // Close closes the Buffer and releases its resources.
 func (buf *Buffer) Close() error {
 	errs := []error{
@@ -59,12 +221,151 @@ func (buf *Buffer) change(s Span, src runes.Reader) error {
 	if err != nil {
 		return err
 	}
+	if err := buf.updateNewlines(s, n); err != nil {
+		return err
+	}
+	if len(buf.changeHooks) > 0 {
+		buf.changes = append(buf.changes, AppliedChange{Span: s, NewSize: n})
+	}
 	for m := range buf.marks {
 		buf.marks[m] = buf.marks[m].Update(s, n)
 	}
 	return nil
 }
 
+// UpdateNewlines updates the newline index
+// for a change of the Span s to n runes.
+func (buf *Buffer) updateNewlines(s Span, n int64) error {
+	var added []int64
+	var rs [1 << 12]rune
+	rr := runes.LimitReader(buf.runes.Reader(s[0]), n)
+	for offs := s[0]; offs < s[0]+n; {
+		k, err := rr.Read(rs[:])
+		if err != nil {
+			return err
+		}
+		for i, r := range rs[:k] {
+			if r == '\n' {
+				added = append(added, offs+int64(i))
+			}
+		}
+		offs += int64(k)
+	}
+
+	nls := buf.newlines
+	i := sort.Search(len(nls), func(i int) bool { return nls[i] >= s[0] })
+	j := sort.Search(len(nls), func(i int) bool { return nls[i] >= s[1] })
+	old := len(nls)
+	m := i + len(added) + old - j
+	if m > old {
+		nls = append(nls, make([]int64, m-old)...)
+	}
+	copy(nls[i+len(added):], nls[j:old])
+	copy(nls[i:], added)
+	nls = nls[:m]
+	d := n - s.Size()
+	for k := i + len(added); k < m; k++ {
+		nls[k] += d
+	}
+	buf.newlines = nls
+	return nil
+}
+
+// LineCount returns the number of lines in the Buffer.
+// The last line is counted even if it does not end in a newline.
+func (buf *Buffer) LineCount() int64 {
+	n := int64(len(buf.newlines))
+	if size := buf.Size(); size > 0 && (n == 0 || buf.newlines[n-1] != size-1) {
+		n++
+	}
+	return n
+}
+
+// BeforeApply registers a function to be called by Apply
+// before it changes the text.
+// The function is given the staged changes, in the order they were staged,
+// and it returns the changes to make in their place.
+// A function that does not rewrite the changes returns them unmodified.
+//
+// If the function returns an error, or if the returned changes
+// are out of sequence, the staged changes are canceled
+// and Apply returns the error without changing the text.
+//
+// Functions are called in the order that they were registered,
+// each given the changes returned by the one before.
+func (buf *Buffer) BeforeApply(f func([]StagedChange) ([]StagedChange, error)) {
+	buf.applyHooks = append(buf.applyHooks, f)
+}
+
+// OnChange registers a function to be called
+// after Apply, Undo, Redo, or Rollback changes the text.
+// The function is given the changes made, in the order they were made.
+//
+// If an error occurs part way through,
+// the function is given the changes made before the error.
+// It is not called if no changes were made.
+func (buf *Buffer) OnChange(f func([]AppliedChange)) {
+	buf.changeHooks = append(buf.changeHooks, f)
+}
+
+// RunApplyHooks calls the BeforeApply functions
+// and replaces the staged changes with the changes that they return.
+func (buf *Buffer) runApplyHooks() error {
+	if len(buf.applyHooks) == 0 {
+		return nil
+	}
+	var staged []StagedChange
+	for e := logFirst(buf.pending); !e.end(); e = e.next() {
+		rs, err := runes.ReadAll(e.data())
+		if err != nil {
+			return err
+		}
+		staged = append(staged, StagedChange{Span: e.span, Text: string(rs)})
+	}
+	for _, f := range buf.applyHooks {
+		var err error
+		if staged, err = f(staged); err != nil {
+			return err
+		}
+	}
+	buf.pending.reset()
+	for _, c := range staged {
+		if _, err := buf.Change(c.Span, strings.NewReader(c.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSize returns a SizeError if applying the staged changes
+// would exceed the MaxRunes limit.
+func (buf *Buffer) checkSize() error {
+	if buf.limits.MaxRunes <= 0 {
+		return nil
+	}
+	size := buf.Size()
+	for e := logFirst(buf.pending); !e.end(); e = e.next() {
+		size += e.size - e.span.Size()
+	}
+	if size > buf.limits.MaxRunes {
+		return SizeError(buf.limits.MaxRunes)
+	}
+	return nil
+}
+
+// RunChangeHooks calls the OnChange functions
+// with the changes made since they were last called.
+func (buf *Buffer) runChangeHooks() {
+	changes := buf.changes
+	buf.changes = nil
+	if len(changes) == 0 {
+		return
+	}
+	for _, f := range buf.changeHooks {
+		f(changes)
+	}
+}
+
 // Size implements the Size method of the Text interface.
 //
 // It returns the number of Runes in the Buffer.
@@ -122,6 +423,14 @@ func (buf *Buffer) Reader(s Span) io.Reader {
 	return runes.UTF8Reader(rr)
 }
 
+// Change implements the Change method of the Editor interface.
+//
+// The text is copied from r to the Buffer's log of staged changes
+// in chunks of a full block,
+// reusing scratch space to encode each block.
+// If there are BeforeApply functions,
+// Apply reads the text of each staged change into memory
+// in order to call them.
 func (buf *Buffer) Change(s Span, r io.Reader) (n int64, err error) {
 	if prev := logLast(buf.pending); !prev.end() && s[0] < prev.span[1] {
 		err = ErrOutOfSequence
@@ -136,6 +445,16 @@ func (buf *Buffer) Change(s Span, r io.Reader) (n int64, err error) {
 }
 
 func (buf *Buffer) Apply() error {
+	if err := buf.runApplyHooks(); err != nil {
+		buf.pending.reset()
+		return err
+	}
+	if err := buf.checkSize(); err != nil {
+		buf.pending.reset()
+		return err
+	}
+	defer buf.runChangeHooks()
+
 	for e := logFirst(buf.pending); !e.end(); e = e.next() {
 		undoSpan := Span{e.span[0], e.span[0] + e.size}
 		undoSrc := buf.runes.Reader(e.span[0])
@@ -166,13 +485,20 @@ func (buf *Buffer) Apply() error {
 		}
 	}
 	buf.pending.reset()
-	buf.redo.reset()
+	if buf.checkpoint != nil {
+		buf.checkpoint.clearRedo = true
+	} else {
+		buf.redo.reset()
+	}
 	buf.marks['.'] = dot
 	buf.seq++
 	return nil
 }
 
 func (buf *Buffer) Undo() error {
+	buf.Commit()
+	defer buf.runChangeHooks()
+
 	marks0 := make(map[rune]Span, len(buf.marks))
 	for r, s := range buf.marks {
 		marks0[r] = s
@@ -207,6 +533,9 @@ func (buf *Buffer) Undo() error {
 }
 
 func (buf *Buffer) Redo() error {
+	buf.Commit()
+	defer buf.runChangeHooks()
+
 	marks0 := make(map[rune]Span, len(buf.marks))
 	for r, s := range buf.marks {
 		marks0[r] = s
@@ -245,6 +574,72 @@ func (buf *Buffer) Redo() error {
 	return start.pop()
 }
 
+// ErrNoCheckpoint is returned by Rollback
+// if the Buffer has no Checkpoint.
+var ErrNoCheckpoint = errors.New("no checkpoint")
+
+// Checkpoint saves the state of the Buffer's text, marks, and history,
+// so that Rollback can return to it.
+// A Buffer has at most one Checkpoint;
+// Checkpoint discards the previous one, as by Commit.
+func (buf *Buffer) Checkpoint() {
+	buf.Commit()
+	marks := make(map[rune]Span, len(buf.marks))
+	for m, s := range buf.marks {
+		marks[m] = s
+	}
+	buf.checkpoint = &checkpoint{
+		undo:  buf.undo.buf.Size(),
+		seq:   buf.seq,
+		marks: marks,
+	}
+}
+
+// Commit discards the Checkpoint, if any,
+// keeping the changes applied since it was saved.
+// Undo and Redo also discard the Checkpoint.
+func (buf *Buffer) Commit() {
+	if buf.checkpoint != nil && buf.checkpoint.clearRedo {
+		buf.redo.reset()
+	}
+	buf.checkpoint = nil
+}
+
+// Rollback returns the Buffer to the state saved by Checkpoint,
+// and discards the Checkpoint.
+// Staged changes are canceled,
+// and the changes applied since the Checkpoint are reverted
+// without adding to the undo or redo history.
+//
+// If there is no Checkpoint, Rollback returns ErrNoCheckpoint.
+func (buf *Buffer) Rollback() error {
+	c := buf.checkpoint
+	if c == nil {
+		return ErrNoCheckpoint
+	}
+	buf.checkpoint = nil
+	buf.pending.reset()
+	defer buf.runChangeHooks()
+
+	for {
+		start := logLastFrame(buf.undo)
+		if start.end() || start.offs < c.undo {
+			break
+		}
+		for e := start; !e.end(); e = e.next() {
+			if err := buf.change(e.span, e.data()); err != nil {
+				return err
+			}
+		}
+		if err := start.pop(); err != nil {
+			return err
+		}
+	}
+	buf.marks = c.marks
+	buf.seq = c.seq
+	return nil
+}
+
 // A log holds a record of changes made to a buffer.
 // It consists of an unbounded number of entries.
 // Each entry has a header and zero or more runes of data.
@@ -261,7 +656,9 @@ type log struct {
 	last int64
 }
 
-func newLog() *log { return &log{buf: runes.NewBuffer(1 << 12)} }
+func newLog() *log { return newLogBlockSize(defaultBlockSize) }
+
+func newLogBlockSize(blockSize int) *log { return &log{buf: runes.NewBuffer(blockSize)} }
 
 func (l *log) close() error { return l.buf.Close() }
 
@@ -4,7 +4,10 @@ package edit
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 	"unicode/utf8"
@@ -108,6 +111,485 @@ func TestBufferChangeSize(t *testing.T) {
 	}
 }
 
+func TestBufferNewlines(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+
+	check := func(op string) {
+		want := []int64{}
+		for i, r := range []rune(buf.String()) {
+			if r == '\n' {
+				want = append(want, int64(i))
+			}
+		}
+		if got := append([]int64{}, buf.newlines...); !reflect.DeepEqual(got, want) {
+			t.Errorf("after %s, buf.newlines=%v, want %v", op, got, want)
+		}
+	}
+	changes := []struct {
+		at  Span
+		str string
+	}{
+		{Span{0, 0}, "a\nb\nc\n"},
+		{Span{2, 2}, "\n\nx"},
+		{Span{0, 3}, ""},
+		{Span{1, 4}, "世\n界"},
+		{Span{6, 6}, "\n"},
+		{Span{0, 3}, "d\ne"},
+	}
+	for _, c := range changes {
+		if _, err := buf.Change(c.at, strings.NewReader(c.str)); err != nil {
+			t.Fatalf("buf.Change(%v, %q)=_,%v, want _,nil", c.at, c.str, err)
+		}
+		if err := buf.Apply(); err != nil {
+			t.Fatalf("buf.Apply()=%v, want nil", err)
+		}
+		check(fmt.Sprintf("Change(%v, %q)", c.at, c.str))
+	}
+	for range changes {
+		if err := buf.Undo(); err != nil {
+			t.Fatalf("buf.Undo()=%v, want nil", err)
+		}
+		check("Undo")
+	}
+	for range changes {
+		if err := buf.Redo(); err != nil {
+			t.Fatalf("buf.Redo()=%v, want nil", err)
+		}
+		check("Redo")
+	}
+}
+
+func TestBufferLineCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int64
+	}{
+		{text: "", want: 0},
+		{text: "a", want: 1},
+		{text: "\n", want: 1},
+		{text: "a\n", want: 1},
+		{text: "a\nb", want: 2},
+		{text: "a\nb\n", want: 2},
+		{text: "\n\n\n", want: 3},
+	}
+	for _, test := range tests {
+		buf := NewBuffer()
+		defer buf.Close()
+		if _, err := buf.Change(Span{}, strings.NewReader(test.text)); err != nil {
+			t.Fatalf("buf.Change(Span{}, %q)=_,%v, want _,nil", test.text, err)
+		}
+		if err := buf.Apply(); err != nil {
+			t.Fatalf("buf.Apply()=%v, want nil", err)
+		}
+		if got := buf.LineCount(); got != test.want {
+			t.Errorf("LineCount() of %q=%d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestBufferBeforeApply(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "Hello, World")
+
+	// Text before offset 5 is read-only.
+	errReadOnly := errors.New("read only")
+	buf.BeforeApply(func(cs []StagedChange) ([]StagedChange, error) {
+		for _, c := range cs {
+			if c.Span[0] < 5 {
+				return nil, errReadOnly
+			}
+		}
+		return cs, nil
+	})
+	// Changes are upper-cased.
+	var got []StagedChange
+	buf.BeforeApply(func(cs []StagedChange) ([]StagedChange, error) {
+		got = append([]StagedChange{}, cs...)
+		for i := range cs {
+			cs[i].Text = strings.ToUpper(cs[i].Text)
+		}
+		return cs, nil
+	})
+
+	if _, err := buf.Change(Span{0, 5}, strings.NewReader("Bye")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != errReadOnly {
+		t.Errorf("buf.Apply()=%v, want %v", err, errReadOnly)
+	}
+	if s := buf.String(); s != "Hello, World" {
+		t.Errorf("buf.String()=%q, want %q", s, "Hello, World")
+	}
+	// The vetoed changes were canceled.
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "Hello, World" {
+		t.Errorf("buf.String()=%q, want %q", s, "Hello, World")
+	}
+
+	for _, c := range []StagedChange{{Span{5, 6}, "!"}, {Span{7, 12}, "there"}} {
+		if _, err := buf.Change(c.Span, strings.NewReader(c.Text)); err != nil {
+			t.Fatalf("buf.Change(%v, %q)=_,%v, want _,nil", c.Span, c.Text, err)
+		}
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	want := []StagedChange{{Span{5, 6}, "!"}, {Span{7, 12}, "there"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("staged changes=%v, want %v", got, want)
+	}
+	if s := buf.String(); s != "Hello! THERE" {
+		t.Errorf("buf.String()=%q, want %q", s, "Hello! THERE")
+	}
+}
+
+func TestBufferBeforeApplyOutOfSequence(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "abc")
+
+	buf.BeforeApply(func(cs []StagedChange) ([]StagedChange, error) {
+		return append(cs, StagedChange{Span{0, 1}, "x"}), nil
+	})
+	if _, err := buf.Change(Span{1, 2}, strings.NewReader("y")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != ErrOutOfSequence {
+		t.Errorf("buf.Apply()=%v, want %v", err, ErrOutOfSequence)
+	}
+	if s := buf.String(); s != "abc" {
+		t.Errorf("buf.String()=%q, want %q", s, "abc")
+	}
+}
+
+func TestBufferOnChange(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "Hello, World")
+
+	var got [][]AppliedChange
+	buf.OnChange(func(cs []AppliedChange) { got = append(got, cs) })
+
+	if _, err := buf.Change(Span{0, 5}, strings.NewReader("Hi")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if _, err := buf.Change(Span{7, 12}, strings.NewReader("Earth!")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	// An empty Apply makes no changes.
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+	if err := buf.Redo(); err != nil {
+		t.Fatalf("buf.Redo()=%v, want nil", err)
+	}
+	want := [][]AppliedChange{
+		// Apply
+		{{Span{0, 5}, 2}, {Span{4, 9}, 6}},
+		// Undo
+		{{Span{0, 2}, 5}, {Span{7, 13}, 5}},
+		// Redo
+		{{Span{7, 12}, 6}, {Span{0, 5}, 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes=%v, want %v", got, want)
+	}
+	if s := buf.String(); s != "Hi, Earth!" {
+		t.Errorf("buf.String()=%q, want %q", s, "Hi, Earth!")
+	}
+}
+
+func TestBufferMaxRunes(t *testing.T) {
+	buf := NewBufferLimits(Limits{MaxRunes: 5})
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "abc")
+
+	if _, err := buf.Change(Span{3, 3}, strings.NewReader("def")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != SizeError(5) {
+		t.Errorf("buf.Apply()=%v, want %v", err, SizeError(5))
+	}
+	if s := buf.String(); s != "abc" {
+		t.Errorf("buf.String()=%q, want %q", s, "abc")
+	}
+	// The changes were canceled.
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "abc" {
+		t.Errorf("buf.String()=%q, want %q", s, "abc")
+	}
+
+	// Changes that shrink the text offset those that grow it.
+	if _, err := buf.Change(Span{0, 1}, strings.NewReader("")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if _, err := buf.Change(Span{3, 3}, strings.NewReader("def")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "bcdef" {
+		t.Errorf("buf.String()=%q, want %q", s, "bcdef")
+	}
+}
+
+func TestBufferRollback(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "Hello, World")
+	applyChange(t, buf, Span{0, 5}, "Hi")
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+	if err := buf.SetMark('m', Span{7, 12}); err != nil {
+		t.Fatalf("buf.SetMark('m', {7, 12})=%v, want nil", err)
+	}
+
+	var got [][]AppliedChange
+	buf.OnChange(func(cs []AppliedChange) { got = append(got, cs) })
+
+	buf.Checkpoint()
+	applyChange(t, buf, Span{7, 12}, "Earth")
+	if _, err := buf.Change(Span{0, 0}, strings.NewReader("¡")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if _, err := buf.Change(Span{12, 12}, strings.NewReader("!")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	// This change is staged, but not applied.
+	if _, err := buf.Change(Span{0, 1}, strings.NewReader("")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if s := buf.String(); s != "¡Hello, Earth!" {
+		t.Fatalf("buf.String()=%q, want %q", s, "¡Hello, Earth!")
+	}
+	got = nil
+	if err := buf.Rollback(); err != nil {
+		t.Fatalf("buf.Rollback()=%v, want nil", err)
+	}
+	want := [][]AppliedChange{{
+		{Span{0, 1}, 0},
+		{Span{12, 13}, 0},
+		{Span{7, 12}, 5},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes=%v, want %v", got, want)
+	}
+	if s := buf.String(); s != "Hello, World" {
+		t.Errorf("buf.String()=%q, want %q", s, "Hello, World")
+	}
+	if m := buf.Mark('m'); m != (Span{7, 12}) {
+		t.Errorf("buf.Mark('m')=%v, want {7, 12}", m)
+	}
+	if err := buf.Rollback(); err != ErrNoCheckpoint {
+		t.Errorf("buf.Rollback()=%v, want %v", err, ErrNoCheckpoint)
+	}
+
+	// The undo and redo history are as before the Checkpoint.
+	if err := buf.Redo(); err != nil {
+		t.Fatalf("buf.Redo()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "Hi, World" {
+		t.Errorf("after Redo buf.String()=%q, want %q", s, "Hi, World")
+	}
+	for i := 0; i < 2; i++ {
+		if err := buf.Undo(); err != nil {
+			t.Fatalf("buf.Undo()=%v, want nil", err)
+		}
+	}
+	if s := buf.String(); s != "" {
+		t.Errorf("after Undo buf.String()=%q, want %q", s, "")
+	}
+}
+
+func TestBufferCommit(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	applyChange(t, buf, Span{}, "Hello, World")
+	applyChange(t, buf, Span{0, 5}, "Hi")
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+
+	buf.Checkpoint()
+	applyChange(t, buf, Span{7, 12}, "Earth")
+	buf.Commit()
+	if err := buf.Rollback(); err != ErrNoCheckpoint {
+		t.Errorf("buf.Rollback()=%v, want %v", err, ErrNoCheckpoint)
+	}
+	// The Apply cleared the redo history.
+	if err := buf.Redo(); err != nil {
+		t.Fatalf("buf.Redo()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "Hello, Earth" {
+		t.Errorf("after Redo buf.String()=%q, want %q", s, "Hello, Earth")
+	}
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+	if s := buf.String(); s != "Hello, World" {
+		t.Errorf("after Undo buf.String()=%q, want %q", s, "Hello, World")
+	}
+}
+
+// TestBufferChangeStreams tests that a large change
+// is spooled to disk, not held in memory.
+func TestBufferChangeStreams(t *testing.T) {
+	const size = 1 << 22
+	buf := NewBuffer()
+	defer buf.Close()
+
+	r := &countReader{Reader: &repeatReader{n: size}}
+	if _, err := buf.Change(Span{}, r); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	if s := buf.Size(); s != size {
+		t.Fatalf("buf.Size()=%d, want %d", s, size)
+	}
+	// The text is read a chunk at a time,
+	// not all at once.
+	const maxRead = 1 << 16
+	if r.bytes != size || r.max > maxRead {
+		t.Errorf("read %d bytes in %d reads of at most %d bytes, want %d bytes in reads of at most %d bytes",
+			r.bytes, r.reads, r.max, size, maxRead)
+	}
+}
+
+// A countReader counts the reads from an io.Reader.
+type countReader struct {
+	io.Reader
+	// Reads is the number of calls to Read.
+	reads int
+	// Max is the largest buffer passed to Read.
+	max int
+	// Bytes is the number of bytes read.
+	bytes int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	r.reads++
+	if len(p) > r.max {
+		r.max = len(p)
+	}
+	n, err := r.Reader.Read(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// A repeatReader reads n bytes of the alphabet, repeated.
+type repeatReader struct{ n int }
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = byte('a' + (r.n-i)%26)
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func TestBufferStats(t *testing.T) {
+	const cacheBytes = 64
+	buf := NewBufferLimits(Limits{CacheBytes: cacheBytes})
+	defer buf.Close()
+	if s := buf.Stats(); s.Runes != 0 || s.LogRunes != 0 || s.Blocks != 0 || s.CacheBytes != cacheBytes {
+		t.Errorf("empty buf.Stats()=%+v, want Runes=0, LogRunes=0, Blocks=0, CacheBytes=%d",
+			s, cacheBytes)
+	}
+
+	const str = "Hello, World"
+	applyChange(t, buf, Span{}, str)
+	if _, err := ioutil.ReadAll(buf.Reader(Span{0, buf.Size()})); err != nil {
+		t.Fatalf("ioutil.ReadAll(…)=_,%v, want _,nil", err)
+	}
+	s := buf.Stats()
+	if s.Runes != int64(len(str)) || s.LogRunes == 0 || s.Blocks == 0 || s.CacheBytes != cacheBytes {
+		t.Errorf("buf.Stats()=%+v, want Runes=%d, LogRunes>0, Blocks>0, CacheBytes=%d",
+			s, len(str), cacheBytes)
+	}
+	if s.CacheHits == 0 || s.CacheMisses == 0 {
+		t.Errorf("buf.Stats()=%+v, want CacheHits>0, CacheMisses>0", s)
+	}
+	if r := s.HitRate(); r <= 0 || r >= 1 {
+		t.Errorf("buf.Stats().HitRate()=%v, want between 0 and 1", r)
+	}
+}
+
+func TestBufferCacheBlocks(t *testing.T) {
+	const cacheBytes = 64
+	buf := NewBufferLimits(Limits{CacheBytes: cacheBytes, CacheBlocks: 2})
+	defer buf.Close()
+	// The text's 16 bytes are 2 blocks of 2 runes.
+	applyChange(t, buf, Span{}, "abcdefgh")
+	if s := buf.Stats(); s.CacheBytes != cacheBytes {
+		t.Errorf("buf.Stats().CacheBytes=%d, want %d", s.CacheBytes, cacheBytes)
+	}
+
+	// Alternating between the first and last runes
+	// only misses the cache the first time.
+	read := func(s Span) {
+		if _, err := ioutil.ReadAll(buf.Reader(s)); err != nil {
+			t.Fatalf("ioutil.ReadAll(buf.Reader(%v))=_,%v, want _,nil", s, err)
+		}
+	}
+	read(Span{0, 1})
+	read(Span{7, 8})
+	s0 := buf.Stats()
+	for i := 0; i < 10; i++ {
+		read(Span{0, 1})
+		read(Span{7, 8})
+	}
+	s1 := buf.Stats()
+	if n := s1.CacheMisses - s0.CacheMisses; n != 0 {
+		t.Errorf("%d misses, want 0", n)
+	}
+	if s1.CacheEvictions == 0 {
+		t.Errorf("buf.Stats().CacheEvictions=0, want >0")
+	}
+}
+
+func TestBufferDefaultCacheBytes(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	if s := buf.Stats(); s.CacheBytes != DefaultCacheBytes {
+		t.Errorf("buf.Stats().CacheBytes=%d, want %d", s.CacheBytes, DefaultCacheBytes)
+	}
+}
+
+// ApplyChange changes the Span of the Buffer to str and applies the change.
+func applyChange(t *testing.T, buf *Buffer, s Span, str string) {
+	if _, err := buf.Change(s, strings.NewReader(str)); err != nil {
+		t.Fatalf("buf.Change(%v, %q)=_,%v, want _,nil", s, str, err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+}
+
 func TestLogEntryEmpty(t *testing.T) {
 	l := newLog()
 	defer l.close()
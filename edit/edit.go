@@ -83,6 +83,20 @@
 // or a combination of the above of the above.
 // It all depends on the Edit being performed.
 //
+// As in Sam, the changes of a group of Edits are simultaneous.
+// The sub-edits of a Block or Loop are evaluated on the original text,
+// before any of their changes are applied,
+// and their changes are then applied together.
+// For example, the Edit
+// 	{
+// 		1d
+// 		3d
+// 	}
+// deletes the original first and third lines.
+// Without the block, the second d would delete
+// the line that was fourth before the first deletion.
+// The DoAll function performs a sequence of Edits in the same way.
+//
 // Buffer
 //
 // The Buffer type provides an implementation of the Editor interface.
@@ -90,18 +104,22 @@
 package edit
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/eaburns/T/edit/runes"
 )
 
 // An Edit is an operation that can be made on a Buffer by an Editor.
@@ -343,7 +361,43 @@ func (e where) Do(ed Editor, print io.Writer) error {
 	return err
 }
 
+type eval struct{ Address }
+
+// Eval returns an Edit that prints
+// the rune location of a, followed by a newline,
+// and then the string at a to an io.Writer.
+// Unlike other Edits, it does not set dot or any other mark.
+func Eval(a Address) Edit { return eval{a} }
+
+func (e eval) String() string { return e.Address.String() + "E" }
+
+func (e eval) Do(ed Editor, print io.Writer) error {
+	s, err := e.Where(ed)
+	if err != nil {
+		return err
+	}
+	if s.Size() == 0 {
+		_, err = fmt.Fprintf(print, "#%d\n", s[0])
+	} else {
+		_, err = fmt.Fprintf(print, "#%d,#%d\n", s[0], s[1])
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(print, ed.Reader(s))
+	return err
+}
+
 func lines(ed Editor, s Span) (l0, l1 int64, err error) {
+	if nls, ok := newlines(ed); ok {
+		// Line numbers are 1 based.
+		l0 = int64(searchNewlines(nls, s[0])) + 1
+		l1 = l0
+		if s[1]-1 > s[0] {
+			l1 = int64(searchNewlines(nls, s[1]-1)) + 1
+		}
+		return l0, l1, nil
+	}
 	var i int64
 	l0 = int64(1) // line numbers are 1 based.
 	rr := ed.RuneReader(Span{0, ed.Size()})
@@ -391,6 +445,35 @@ type Substitute struct {
 	// described here: https://golang.org/pkg/regexp/#Regexp.Expand.
 	With string
 
+	// Func, if non-nil, is called to compute the replacement of each match,
+	// and With is ignored.
+	// The argument is the text of the match followed by the text of each sub-match,
+	// in the order of their opening parentheses.
+	// Sub-matches that did not participate in the match are the empty string.
+	//
+	// An Edit with a non-nil Func has no representation in the Edit language.
+	// Its String method returns the string of the edit as if Func were nil.
+	Func func(match []string) string
+
+	// Confirm, if non-nil, is called for each match that would be replaced,
+	// in order from the beginning of the Address,
+	// and its result determines whether the match is replaced.
+	// The arguments are the Span of the match
+	// in the text before any substitution is made,
+	// and the text with which it would be replaced.
+	// Confirm may block, for example, to wait for a user's answer.
+	// However, it is called while the Edit is performed,
+	// with the Editor held for the whole Edit.
+	// If the Editor is a buffer of an editor server,
+	// a blocking Confirm holds the server's lock on the buffer,
+	// stalling every other client of the buffer until it returns.
+	//
+	// If Global is false, the first accepted match is replaced.
+	//
+	// An Edit with a non-nil Confirm has no representation in the Edit language.
+	// Its String method returns the string of the edit as if Confirm were nil.
+	Confirm func(match Span, repl string) Confirmation
+
 	// Global is whether to replace all matches, or just one.
 	// If Global is false, only one match is replaced.
 	// If Global is true, all matches are replaced.
@@ -409,6 +492,27 @@ type Substitute struct {
 	From int
 }
 
+// A Confirmation is the answer to a Substitute Confirm call.
+type Confirmation int
+
+const (
+	// Accept replaces the match.
+	Accept Confirmation = iota
+	// Skip leaves the match unchanged.
+	Skip
+	// Stop leaves the match and all following matches unchanged.
+	// Matches accepted before the Stop are replaced.
+	Stop
+	// Abort leaves the text and dot unchanged,
+	// including matches accepted before the Abort,
+	// and the Substitute Edit returns ErrAborted.
+	Abort
+)
+
+// ErrAborted is returned by a Substitute Edit
+// when its Confirm function returns Abort.
+var ErrAborted = errors.New("aborted")
+
 // Sub returns a Substitute Edit
 // that substitutes the first occurrence
 // of the regular expression within a
@@ -425,6 +529,27 @@ func SubGlobal(a Address, re, with string) Edit {
 	return Substitute{Address: a, Regexp: re, With: with, Global: true, From: 1}
 }
 
+// SubFunc returns a Substitute Edit
+// that substitutes all occurrences
+// of the regular expression within a
+// with the result of calling repl on the match,
+// and sets dot to the modified Address a.
+// All substitutions are applied together, with a single change to the Editor.
+func SubFunc(a Address, re string, repl func(match []string) string) Edit {
+	return Substitute{Address: a, Regexp: re, Func: repl, Global: true, From: 1}
+}
+
+// SubConfirm returns a Substitute Edit
+// that substitutes all occurrences
+// of the regular expression within a
+// for which confirm returns Accept,
+// and sets dot to the modified Address a.
+// The substitutions are applied together, with a single change to the Editor,
+// after confirm has been called for each match.
+func SubConfirm(a Address, re, with string, confirm func(match Span, repl string) Confirmation) Edit {
+	return Substitute{Address: a, Regexp: re, With: with, Confirm: confirm, Global: true, From: 1}
+}
+
 func (e Substitute) String() string {
 	var n string
 	if e.From > 1 {
@@ -446,10 +571,11 @@ func (e Substitute) Do(ed Editor, _ io.Writer) error {
 	if err != nil {
 		return err
 	}
-	setDot(ed, s)
 
+	var subs []replacement
 	var prev []int
 	from := s[0]
+search:
 	for from <= s[1] { // Allow one run on an empty input.
 		m := match(re, Span{from, s[1]}, ed)
 		if len(m) < 2 {
@@ -467,23 +593,51 @@ func (e Substitute) Do(ed Editor, _ io.Writer) error {
 		}
 		prev = m
 		e.From--
-		if e.From <= 0 {
-			if err := regexpSub(re, m, e.With, ed); err != nil {
-				return nil
-			}
-			if !e.Global {
-				break
+		if e.From > 0 {
+			continue
+		}
+		r, err := regexpSub(re.Regexp, m, e.With, e.Func, ed)
+		if err != nil {
+			return err
+		}
+		if e.Confirm != nil {
+			switch e.Confirm(r.span, string(r.text)) {
+			case Skip:
+				continue
+			case Stop:
+				break search
+			case Abort:
+				return ErrAborted
 			}
 		}
+		subs = append(subs, r)
+		if !e.Global {
+			break
+		}
+	}
+	setDot(ed, s)
+	for _, r := range subs {
+		if _, err := ed.Change(r.span, bytes.NewReader(r.text)); err != nil {
+			return err
+		}
 	}
 	return ed.Apply()
 }
 
-func regexpSub(re *regexp.Regexp, match []int, with string, ed Editor) error {
+// A replacement is the replacement text of a regexp match.
+type replacement struct {
+	span Span
+	text []byte
+}
+
+// regexpSub returns the replacement of a regexp match,
+// either the expansion of the template with, or, if f is non-nil,
+// the result of calling f with the text of the match and its sub-matches.
+func regexpSub(re *regexp.Regexp, match []int, with string, f func([]string) string, ed Editor) (replacement, error) {
 	dst := Span{int64(match[0]), int64(match[1])}
 	src, err := ioutil.ReadAll(ed.Reader(dst))
 	if err != nil {
-		return err
+		return replacement{}, err
 	}
 
 	matchSrc := make([]int, len(match))
@@ -502,9 +656,19 @@ func regexpSub(re *regexp.Regexp, match []int, with string, ed Editor) error {
 		ri++
 	}
 
-	repl := re.Expand(nil, []byte(with), src, matchSrc)
-	_, err = ed.Change(dst, bytes.NewReader(repl))
-	return err
+	var repl []byte
+	if f != nil {
+		strs := make([]string, len(match)/2)
+		for i := range strs {
+			if match[2*i] >= 0 && match[2*i+1] >= 0 {
+				strs[i] = string(src[matchSrc[2*i]:matchSrc[2*i+1]])
+			}
+		}
+		repl = []byte(f(strs))
+	} else {
+		repl = re.Expand(nil, []byte(with), src, matchSrc)
+	}
+	return replacement{span: dst, text: repl}, nil
 }
 
 type loop struct {
@@ -579,6 +743,7 @@ type pipe struct {
 	Address
 	cmd      string
 	to, from bool
+	opts     PipeOptions
 }
 
 // Pipe returns an Edit
@@ -595,6 +760,14 @@ type pipe struct {
 // The shell is either the value of
 // the SHELL environment variable
 // or DefaultShell if SHELL is unset.
+// On Windows, if SHELL is unset,
+// the shell is the value of the ComSpec environment variable,
+// and the arguments to the shell depend on the shell:
+// cmd.exe is run with /S /C, PowerShell with -Command,
+// and any other shell, such as a POSIX sh.exe, with -c.
+// The command runs in the current directory
+// with the environment of the current process;
+// see WithPipeOptions to change that.
 func Pipe(a Address, cmd string) Edit {
 	return pipe{Address: a, cmd: cmd, to: true, from: true}
 }
@@ -624,6 +797,53 @@ func (e pipe) String() string {
 	return e.Address.String() + pipe + escNewlines(e.cmd) + "\n"
 }
 
+// PipeOptions are options for running the command of a pipe Edit.
+type PipeOptions struct {
+	// Dir is the working directory of the command.
+	// If Dir is empty, the command runs in the current directory.
+	Dir string
+
+	// Env are environment variables of the command,
+	// each of the form "key=value",
+	// in addition to the environment of the current process.
+	// They override variables of the current process with the same key.
+	Env []string
+
+	// Timeout, if positive, is the duration after which the command is killed.
+	Timeout time.Duration
+
+	// Context, if non-nil, kills the command if it is done
+	// before the command completes.
+	Context context.Context
+}
+
+// WithPipeOptions returns the Edit with the options
+// set for the commands of its Pipe, PipeTo, and PipeFrom Edits,
+// including those in the bodies of Block and Loop Edits.
+// Other Edits are returned unchanged.
+//
+// The options have no representation in the Edit language.
+// The String method of the returned Edit
+// is the same as that of the original Edit.
+func WithPipeOptions(e Edit, opts PipeOptions) Edit {
+	switch e := e.(type) {
+	case pipe:
+		e.opts = opts
+		return e
+	case loop:
+		e.body = WithPipeOptions(e.body, opts)
+		return e
+	case block:
+		body := make([]Edit, len(e.body))
+		for i, b := range e.body {
+			body[i] = WithPipeOptions(b, opts)
+		}
+		e.body = body
+		return e
+	}
+	return e
+}
+
 func escNewlines(s string) string {
 	var esc []rune
 	for _, r := range s {
@@ -636,19 +856,6 @@ func escNewlines(s string) string {
 	return string(esc)
 }
 
-// DefaultShell is the default shell
-// which is used to execute commands
-// if the SHELL environment variable
-// is not set.
-const DefaultShell = "/bin/sh"
-
-func shell() string {
-	if sh := os.Getenv("SHELL"); sh != "" {
-		return sh
-	}
-	return DefaultShell
-}
-
 func (e pipe) Do(ed Editor, print io.Writer) error {
 	s, err := e.Where(ed)
 	if err != nil {
@@ -656,7 +863,20 @@ func (e pipe) Do(ed Editor, print io.Writer) error {
 	}
 	setDot(ed, s)
 
-	cmd := exec.Command(shell(), "-c", e.cmd)
+	ctx := e.opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if e.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.opts.Timeout)
+		defer cancel()
+	}
+	cmd := shellCommand(ctx, shell(), e.cmd)
+	cmd.Dir = e.opts.Dir
+	if len(e.opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), e.opts.Env...)
+	}
 	cmd.Stderr = print
 
 	if e.to {
@@ -666,7 +886,7 @@ func (e pipe) Do(ed Editor, print io.Writer) error {
 	if !e.from {
 		cmd.Stdout = print
 		if err := cmd.Run(); err != nil {
-			return err
+			return cmdError(ctx, err)
 		}
 		return nil
 	}
@@ -680,7 +900,7 @@ func (e pipe) Do(ed Editor, print io.Writer) error {
 	}
 	_, changeErr := ed.Change(s, r)
 	if err = cmd.Wait(); err != nil {
-		return err
+		return cmdError(ctx, err)
 	}
 	if changeErr != nil {
 		return changeErr
@@ -688,6 +908,17 @@ func (e pipe) Do(ed Editor, print io.Writer) error {
 	return ed.Apply()
 }
 
+// CmdError returns the error of a command run with a Context.
+// If the command was killed because the Context is done,
+// the Context's error is returned
+// instead of the less descriptive error from the killed command.
+func cmdError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
 type undo int
 
 // Undo returns an Edit
@@ -782,6 +1013,61 @@ func (e block) Do(ed Editor, print io.Writer) error {
 	return ed.Apply()
 }
 
+// DoAll performs a sequence of Edits on an Editor,
+// applying all of their changes with a single call to Apply,
+// as a single batch of changes on the Undo stack.
+// Anything printed by the Edits is written to the Writer.
+//
+// Like the sub-edits of a Block,
+// the Edits do not see the modifications made by previous Edits.
+// Each sees the text in the original state, before any changes are made.
+// It is an error if the Edits make changes that are not in ascending order.
+//
+// If an Edit returns an error, DoAll returns the error,
+// and none of the changes of the Edits are made.
+func DoAll(ed Editor, print io.Writer, edits ...Edit) (err error) {
+	batch := &batchEditor{Editor: ed, pending: newLog()}
+	defer func() {
+		if closeErr := batch.pending.close(); err == nil {
+			err = closeErr
+		}
+	}()
+	for _, e := range edits {
+		if err := e.Do(batch, print); err != nil {
+			return err
+		}
+	}
+	for e := logFirst(batch.pending); !e.end(); e = e.next() {
+		// On error, the Editor cancels the previously staged changes.
+		if _, err := ed.Change(e.span, runes.UTF8Reader(e.data())); err != nil {
+			return err
+		}
+	}
+	return ed.Apply()
+}
+
+// A batchEditor is an Editor that stages changes in its own log,
+// to be made on the underlying Editor only if all Edits succeed,
+// and that ignores calls to Apply.
+type batchEditor struct {
+	Editor
+	pending *log
+}
+
+func (ed *batchEditor) Change(s Span, r io.Reader) (int64, error) {
+	if prev := logLast(ed.pending); !prev.end() && s[0] < prev.span[1] {
+		ed.pending.reset()
+		return 0, ErrOutOfSequence
+	}
+	n, err := ed.pending.append(0, s, runes.RunesReader(bufio.NewReader(r)))
+	if err != nil {
+		ed.pending.reset()
+	}
+	return n, err
+}
+
+func (*batchEditor) Apply() error { return nil }
+
 // Ed parses and returns an Edit.
 //
 // Edits are terminated by a newline, end of input, or the end of the edit.
@@ -877,6 +1163,11 @@ func (e block) Do(ed Editor, print io.Writer) error {
 //		With '#' returns the rune offsets of the address.
 //		If an address is not supplied, dot is used.
 //		Dot is set to the address.
+//	[addr] E
+//		Returns the rune offsets of the address, as with =#,
+//		followed by the runes identified by the address.
+//		If an address is not supplied, dot is used.
+//		Neither dot nor any other mark is set.
 //	[addr] | cmd
 //	[addr] < cmd
 //	[addr] > cmd
@@ -892,6 +1183,8 @@ func (e block) Do(ed Editor, print io.Writer) error {
 //	 	The command is passed as the argument of -c
 //		to the shell in the SHELL environment variable.
 //		If SHELL is unset, the value of DefaultShell is used.
+//		On Windows, cmd.exe and PowerShell are also supported;
+//		see Pipe for details.
 //
 //		Parsing of cmd is termiated by
 //		either a newline or the end of input.
@@ -987,6 +1280,8 @@ func Ed(rs io.RuneScanner) (Edit, error) {
 		return Set(a, m), nil
 	case r == 'p':
 		return Print(a), nil
+	case r == 'E':
+		return Eval(a), nil
 	case r == '=':
 		switch r, _, err := rs.ReadRune(); {
 		case err == io.EOF:
@@ -4,14 +4,19 @@ package edit
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/eaburns/T/edit/edittest"
 )
@@ -182,6 +187,11 @@ func TestEd(t *testing.T) {
 		{str: "#1+1=#", edit: Where(Rune(1).Plus(Line(1)))},
 		{str: " #1 + 1 =#", edit: Where(Rune(1).Plus(Line(1)))},
 
+		{str: "E", edit: Eval(Dot)},
+		{str: "Exyz", left: "xyz", edit: Eval(Dot)},
+		{str: "#1+1E", edit: Eval(Rune(1).Plus(Line(1)))},
+		{str: " #1 + 1 E", edit: Eval(Rune(1).Plus(Line(1)))},
+
 		{str: "s/a/b", edit: Sub(Dot, "a", "b")},
 		{str: "s;a;b", edit: Sub(Dot, "a", "b")},
 		{str: "s/a*|b*//", edit: Sub(Dot, "a*|b*", "")},
@@ -432,6 +442,11 @@ func TestEditString(t *testing.T) {
 		{WhereLine(Regexp("a*")), `/a*/=`},
 		{WhereLine(Regexp("/*")), `/\/*/=`},
 
+		{Eval(All), `0,$E`},
+		{Eval(Dot), `.E`},
+		{Eval(Regexp("a*")), `/a*/E`},
+		{Eval(Regexp("/*")), `/\/*/E`},
+
 		{Undo(1), "u1"},
 		{Undo(2), "u2"},
 		{Undo(0), "u1"},
@@ -1092,6 +1107,68 @@ func TestEditWhereFromString(t *testing.T) {
 	}
 }
 
+var evalTests = []editTest{
+	{
+		name:  "out of range",
+		do:    []Edit{Eval(Rune(1))},
+		error: "out of range",
+	},
+	{
+		name:  "no match",
+		given: "ab{..}c",
+		do:    []Edit{Eval(Regexp("xyz"))},
+		want:  "ab{..}c",
+		error: "no match",
+	},
+	{
+		name:  "empty buffer",
+		given: "{..}",
+		do:    []Edit{Eval(All)},
+		want:  "{..}",
+		print: "#0\n",
+	},
+	{
+		name:  "point",
+		given: "{..}abcxyz",
+		do:    []Edit{Eval(Rune(3))},
+		want:  "{..}abcxyz",
+		print: "#3\n",
+	},
+	{
+		name:  "range",
+		given: "{..}abc\nxyz",
+		do:    []Edit{Eval(Line(2))},
+		want:  "{..}abc\nxyz",
+		print: "#4,#7\nxyz",
+	},
+	{
+		name:  "relative to dot",
+		given: "a{.}bc{.}\nxyz",
+		do:    []Edit{Eval(Dot.Plus(Regexp("y")))},
+		want:  "a{.}bc{.}\nxyz",
+		print: "#5,#6\ny",
+	},
+	{
+		name:  "marks unchanged",
+		given: "{a}a{a}b{..}c",
+		do:    []Edit{Eval(Mark('a').To(End))},
+		want:  "{a}a{a}b{..}c",
+		print: "#0,#3\nabc",
+	},
+}
+
+func TestEditEval(t *testing.T) {
+	for _, test := range evalTests {
+		test.run(t)
+	}
+}
+
+func TestEditEvalFromString(t *testing.T) {
+	for _, test := range evalTests {
+		test.runFromString(t)
+	}
+}
+
 var whereLineTests = []editTest{
 	{
 		name:  "out of range",
@@ -1452,6 +1529,18 @@ var substituteTests = []editTest{
 		do:    []Edit{Sub(All, `abc`, `xyz\\n`)},
 		want:  `{.}xyz\\n{.}`,
 	},
+	{
+		name:  "trailing space before CRLF",
+		given: "{..}abc  \r\nxyz \r\n",
+		do:    []Edit{SubGlobal(All, ` +$`, "")},
+		want:  "{.}abc\r\nxyz\r\n{.}",
+	},
+	{
+		name:  "remove CR",
+		given: "{..}abc\r\nxyz\r\n",
+		do:    []Edit{SubGlobal(All, `\r$`, "")},
+		want:  "{.}abc\nxyz\n{.}",
+	},
 }
 
 func TestEditSubstitute(t *testing.T) {
@@ -1466,6 +1555,144 @@ func TestEditSubstituteFromString(t *testing.T) {
 	}
 }
 
+func TestEditSubFunc(t *testing.T) {
+	incr := func(m []string) string {
+		n, err := strconv.Atoi(m[0])
+		if err != nil {
+			panic(err)
+		}
+		return strconv.Itoa(n + 1)
+	}
+	swap := func(m []string) string { return m[2] + m[1] }
+	tests := []editTest{
+		{
+			name:  "no match",
+			given: "{..}abc",
+			do:    []Edit{SubFunc(All, "xyz", incr)},
+			want:  "{.}abc{.}",
+		},
+		{
+			name:  "increment numbers",
+			given: "{..}a1b9c99",
+			do:    []Edit{SubFunc(All, "[0-9]+", incr)},
+			want:  "{.}a2b10c100{.}",
+		},
+		{
+			name:  "sub-matches",
+			given: "{..}abcdαβ",
+			do:    []Edit{SubFunc(All, "(.)(.)", swap)},
+			want:  "{.}badcβα{.}",
+		},
+		{
+			name:  "unmatched sub-match",
+			given: "{..}ab",
+			do:    []Edit{SubFunc(All, "(x)?(.)", swap)},
+			want:  "{.}ab{.}",
+		},
+		{
+			name:  "only within address",
+			given: "{..}1 2 3",
+			do:    []Edit{SubFunc(Rune(1).To(Rune(4)), "[0-9]", incr)},
+			want:  "1{.} 3 {.}3",
+		},
+		{
+			name:  "bad regexp",
+			do:    []Edit{SubFunc(All, "*", incr)},
+			error: "missing",
+		},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
+
+func TestEditSubConfirm(t *testing.T) {
+	type call struct {
+		match Span
+		repl  string
+	}
+	tests := []struct {
+		name, given string
+		global      bool
+		answers     []Confirmation
+		want, error string
+		calls       []call
+	}{
+		{
+			name:    "accept all",
+			given:   "{..}a1b2c3",
+			global:  true,
+			answers: []Confirmation{Accept, Accept, Accept},
+			want:    "{.}a<1>b<2>c<3>{.}",
+			calls:   []call{{Span{1, 2}, "<1>"}, {Span{3, 4}, "<2>"}, {Span{5, 6}, "<3>"}},
+		},
+		{
+			name:    "skip",
+			given:   "{..}a1b2c3",
+			global:  true,
+			answers: []Confirmation{Skip, Accept, Skip},
+			want:    "{.}a1b<2>c3{.}",
+			calls:   []call{{Span{1, 2}, "<1>"}, {Span{3, 4}, "<2>"}, {Span{5, 6}, "<3>"}},
+		},
+		{
+			name:    "stop",
+			given:   "{..}a1b2c3",
+			global:  true,
+			answers: []Confirmation{Accept, Stop},
+			want:    "{.}a<1>b2c3{.}",
+			calls:   []call{{Span{1, 2}, "<1>"}, {Span{3, 4}, "<2>"}},
+		},
+		{
+			name:    "abort",
+			given:   "{..}a1b2c3",
+			global:  true,
+			answers: []Confirmation{Accept, Abort},
+			want:    "{..}a1b2c3",
+			error:   "aborted",
+			calls:   []call{{Span{1, 2}, "<1>"}, {Span{3, 4}, "<2>"}},
+		},
+		{
+			name:    "not global replaces the first accepted",
+			given:   "{..}a1b2c3",
+			answers: []Confirmation{Skip, Accept},
+			want:    "{.}a1b<2>c3{.}",
+			calls:   []call{{Span{1, 2}, "<1>"}, {Span{3, 4}, "<2>"}},
+		},
+		{
+			name:   "no match",
+			given:  "{..}abc",
+			global: true,
+			want:   "{.}abc{.}",
+		},
+	}
+	for _, test := range tests {
+		var calls []call
+		answers := test.answers
+		confirm := func(m Span, r string) Confirmation {
+			calls = append(calls, call{match: m, repl: r})
+			a := answers[0]
+			answers = answers[1:]
+			return a
+		}
+		editTest{
+			name:  test.name,
+			given: test.given,
+			do: []Edit{Substitute{
+				Address: All,
+				Regexp:  "[0-9]",
+				With:    "<$0>",
+				Confirm: confirm,
+				Global:  test.global,
+			}},
+			want:  test.want,
+			error: test.error,
+		}.run(t)
+		if !reflect.DeepEqual(calls, test.calls) {
+			t.Errorf("%s: confirm calls=%v, want %v", test.name, calls, test.calls)
+		}
+	}
+}
+
 var loopTests = []editTest{
 	{
 		name:  "out of range",
@@ -1724,6 +1951,71 @@ func TestPipeDefaultShell(t *testing.T) {
 	}
 }
 
+func TestPipeOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edit_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatalf("filepath.EvalSymlinks(…)=_,%v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []editTest{
+		{
+			name:  "dir",
+			given: "{..}",
+			do:    []Edit{WithPipeOptions(PipeFrom(All, "pwd"), PipeOptions{Dir: dir})},
+			want:  "{.}" + dir + "\n{.}",
+		},
+		{
+			name:  "env",
+			given: "{..}",
+			do: []Edit{WithPipeOptions(PipeFrom(All, "echo -n $T_FILE $HOME"), PipeOptions{
+				Env: []string{"T_FILE=file.go", "HOME=/home/T"},
+			})},
+			want: "{.}file.go /home/T{.}",
+		},
+		{
+			name:  "timeout",
+			given: "{..}abc",
+			do: []Edit{WithPipeOptions(Pipe(All, "exec sleep 10"), PipeOptions{
+				Timeout: 10 * time.Millisecond,
+			})},
+			want:  "{.}abc{.}",
+			error: "deadline exceeded",
+		},
+		{
+			name:  "canceled",
+			given: "{..}abc",
+			do:    []Edit{WithPipeOptions(PipeTo(All, "exec sleep 10"), PipeOptions{Context: canceled})},
+			want:  "{.}abc{.}",
+			error: "canceled",
+		},
+		{
+			name:  "block",
+			given: "{..}",
+			do: []Edit{WithPipeOptions(Block(All, Block(All, PipeFrom(All, "pwd"))), PipeOptions{
+				Dir: dir,
+			})},
+			want: "{.}" + dir + "\n{.}",
+		},
+		{
+			name:  "loop",
+			given: "{..}a\nb\n",
+			do: []Edit{WithPipeOptions(Loop(All, "", PipeFrom(Dot, "echo $X")), PipeOptions{
+				Env: []string{"X=x"},
+			})},
+			want: "x\n{.}x\n{.}",
+		},
+	}
+	for _, test := range tests {
+		test.run(t)
+	}
+}
+
 var undoTests = []editTest{
 	{
 		name:  "empty undo 1",
@@ -2036,6 +2328,26 @@ var blockTests = []editTest{
 		error: "sequence",
 		want:  "a{.}b{.}c",
 	},
+	{
+		name:  "simultaneous line addresses",
+		given: "{..}1\n2\n3\n4\n",
+		do: []Edit{
+			Block(All,
+				Delete(Line(1)),
+				Delete(Line(3))),
+		},
+		want: "{.}2\n4\n{.}",
+	},
+	{
+		name:  "simultaneous insert and append",
+		given: "{..}abc abc",
+		do: []Edit{
+			Loop(All, "abc", Block(Dot,
+				Insert(Dot, "<"),
+				Append(Dot, ">"))),
+		},
+		want: "<abc> {.}<abc>{.}",
+	},
 	{
 		name:  "nested",
 		given: "{.}AabcABCXYZxyzZ{.}",
@@ -2061,6 +2373,151 @@ func TestEditBlockFromString(t *testing.T) {
 	}
 }
 
+func TestDoAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		given, want string
+		print       string
+		error       string
+		do          []Edit
+	}{
+		{
+			name:  "no edits",
+			given: "{..}abc",
+			want:  "{..}abc",
+		},
+		{
+			name:  "original addresses",
+			given: "{..}abc",
+			do: []Edit{
+				Change(Regexp("a"), "xyz"),
+				Insert(Rune(2), "!"),
+				Append(Regexp("c"), "d"),
+			},
+			want: "xyzb!c{.}d{.}",
+		},
+		{
+			name:  "print original text",
+			given: "{..}abc",
+			do: []Edit{
+				Delete(Regexp("b")),
+				Print(All),
+			},
+			print: "abc",
+			want:  "{.}ac{.}",
+		},
+		{
+			name:  "move",
+			given: "{..}abc",
+			do: []Edit{
+				Move(Regexp("a"), Rune(2)),
+				Change(Regexp("c"), "C"),
+			},
+			want: "b{.}aC{.}",
+		},
+		{
+			name:  "error cancels changes",
+			given: "{..}abc",
+			do: []Edit{
+				Change(Regexp("a"), "x"),
+				Change(Regexp("z"), "y"),
+			},
+			error: "no match",
+			want:  "{.}a{.}bc",
+		},
+		{
+			name:  "out of sequence",
+			given: "{..}abc",
+			do: []Edit{
+				Change(Regexp("c"), "z"),
+				Change(Regexp("a"), "x"),
+			},
+			error: "sequence",
+			want:  "{.}a{.}bc",
+		},
+	}
+	for _, test := range tests {
+		buf := newTestBuffer(test.given)
+		print := bytes.NewBuffer(nil)
+		if err := DoAll(buf, print, test.do...); !matchesError(test.error, err) {
+			t.Errorf("%s: DoAll(buf, print, %v...)=%v, want %q", test.name, test.do, err, test.error)
+		}
+		// Changes canceled by an error are not applied later.
+		if err := buf.Apply(); err != nil {
+			t.Errorf("%s: buf.Apply()=%v, want nil", test.name, err)
+		}
+		if !hasState(buf, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, stateString(buf), test.want)
+		}
+		if got := print.String(); got != test.print {
+			t.Errorf("%s: printed %q, want %q", test.name, got, test.print)
+		}
+		buf.Close()
+	}
+}
+
+// A changeCounter is an Editor that counts calls to Change.
+type changeCounter struct {
+	Editor
+	n int
+}
+
+func (ed *changeCounter) Change(s Span, r io.Reader) (int64, error) {
+	ed.n++
+	return ed.Editor.Change(s, r)
+}
+
+func TestDoAll_ErrorMakesNoChanges(t *testing.T) {
+	buf := newTestBuffer("{..}abc")
+	defer buf.Close()
+	ed := &changeCounter{Editor: buf}
+	edits := []Edit{
+		Change(Regexp("a"), "x"),
+		Change(Regexp("b"), "y"),
+		Change(Regexp("z"), "z"),
+	}
+	if err := DoAll(ed, ioutil.Discard, edits...); err == nil {
+		t.Fatalf("DoAll(ed, ioutil.Discard, %v...)=nil, want error", edits)
+	}
+	if ed.n != 0 {
+		t.Errorf("DoAll(ed, ioutil.Discard, %v...) called Change %d times, want 0", edits, ed.n)
+	}
+
+	ed.n = 0
+	edits = edits[:2]
+	if err := DoAll(ed, ioutil.Discard, edits...); err != nil {
+		t.Fatalf("DoAll(ed, ioutil.Discard, %v...)=%v, want nil", edits, err)
+	}
+	if ed.n != 2 {
+		t.Errorf("DoAll(ed, ioutil.Discard, %v...) called Change %d times, want 2", edits, ed.n)
+	}
+	if got := buf.String(); got != "xyc" {
+		t.Errorf("buf.String()=%q, want \"xyc\"", got)
+	}
+}
+
+func TestDoAll_Undo(t *testing.T) {
+	buf := newTestBuffer("{..}abc")
+	defer buf.Close()
+	edits := []Edit{
+		Change(Regexp("a"), "x"),
+		Change(Regexp("b"), "y"),
+		Change(Regexp("c"), "z"),
+	}
+	if err := DoAll(buf, ioutil.Discard, edits...); err != nil {
+		t.Fatalf("DoAll(buf, ioutil.Discard, %v...)=%v, want nil", edits, err)
+	}
+	if got := buf.String(); got != "xyz" {
+		t.Fatalf("buf.String()=%q, want \"xyz\"", got)
+	}
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+	if got := buf.String(); got != "abc" {
+		t.Errorf("after one Undo, buf.String()=%q, want \"abc\"", got)
+	}
+}
+
 var updateMarkTests = []editTest{
 	{
 		name:  "delete after mark",
@@ -2196,6 +2653,49 @@ func TestUpdateMarks(t *testing.T) {
 	}
 }
 
+// TestRandomEdits tests random sequences of edits on a Buffer
+// against the reference implementation of edittest.Model.
+func TestRandomEdits(t *testing.T) {
+	const nSeeds = 200
+	for seed := int64(0); seed < nSeeds; seed++ {
+		randomEdits(t, seed)
+	}
+}
+
+// RandomEdits checks that random edits to a Buffer
+// match the same edits to an edittest.Model.
+func randomEdits(t *testing.T, seed int64) {
+	const nOps = 100
+	rnd := rand.New(rand.NewSource(seed))
+	buf := NewBuffer()
+	defer buf.Close()
+	setDot(buf, Span{})
+	model := edittest.NewModel("")
+
+	var done []string
+	for i := 0; i < nOps; i++ {
+		op := model.RandomOp(rnd)
+		done = append(done, op.String())
+		e, err := Ed(strings.NewReader(op.String()))
+		if err != nil {
+			t.Fatalf("seed %d: Ed(%q)=_,%v, want _,nil", seed, op, err)
+		}
+		if err := e.Do(buf, ioutil.Discard); err != nil {
+			t.Fatalf("seed %d: after %q, Do(%q)=%v, want nil", seed, done[:i], e, err)
+		}
+		if err := model.Do(op); err != nil {
+			t.Fatalf("seed %d: after %q, model.Do(%q)=%v, want nil", seed, done[:i], op, err)
+		}
+		marks := make(map[rune][2]int64)
+		for k, v := range buf.marks {
+			marks[k] = v
+		}
+		if d := edittest.Diff(buf.String(), marks, string(model.Text), model.Marks); d != "" {
+			t.Fatalf("seed %d: after %q:\n%s", seed, done, d)
+		}
+	}
+}
+
 type editTest struct {
 	name string
 	// Given is the initial editor state description,
@@ -0,0 +1,345 @@
+// Copyright © 2016, The T Authors.
+
+package edittest
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// An OpKind is the kind of an Op.
+type OpKind int
+
+const (
+	// ChangeOp changes the Span to Text.
+	ChangeOp OpKind = iota
+	// MoveOp moves the Span to At.
+	MoveOp
+	// CopyOp copies the Span to At.
+	CopyOp
+	// MarkOp sets Mark to the Span.
+	MarkOp
+	// UndoOp undoes the most recent change.
+	UndoOp
+	// RedoOp redoes the most recently undone change.
+	RedoOp
+)
+
+// An Op is an operation on a text,
+// made by the Edit with the String of the Op
+// in the edit language of the edit package.
+type Op struct {
+	Kind OpKind
+	// Span is the span of runes that are changed, moved, or copied,
+	// or to which the mark is set.
+	Span [2]int64
+	// At is the rune offset to which runes are moved or copied.
+	At int64
+	// Text is the new text of a ChangeOp.
+	Text string
+	// Mark is the mark set by a MarkOp.
+	Mark rune
+}
+
+func (op Op) String() string {
+	addr := "#" + strconv.FormatInt(op.Span[0], 10) + ",#" + strconv.FormatInt(op.Span[1], 10)
+	switch op.Kind {
+	case ChangeOp:
+		return addr + "c/" + escape(op.Text) + "/"
+	case MoveOp:
+		return addr + "m#" + strconv.FormatInt(op.At, 10)
+	case CopyOp:
+		return addr + "t#" + strconv.FormatInt(op.At, 10)
+	case MarkOp:
+		return addr + "k" + string(op.Mark)
+	case UndoOp:
+		return "u1"
+	case RedoOp:
+		return "r1"
+	}
+	panic("bad op kind: " + strconv.Itoa(int(op.Kind)))
+}
+
+// Escape returns the text of a ChangeOp
+// with \ inserted before all occurrences of \ and /,
+// and with raw newlines replaced by \n.
+// It is like edit.Escape(text, '/'),
+// but the edit package's tests import this package.
+func escape(text string) string {
+	var rs []rune
+	for _, r := range text {
+		switch r {
+		case '\n':
+			rs = append(rs, '\\', 'n')
+		case '\\', '/':
+			rs = append(rs, '\\', r)
+		default:
+			rs = append(rs, r)
+		}
+	}
+	return string(rs)
+}
+
+// A Model is a simple, in-memory reference implementation of a text and its marks.
+// Its state after a sequence of Ops
+// is the state expected of an edit.Buffer after the equivalent Edits.
+type Model struct {
+	// Text is the text.
+	Text []rune
+	// Marks are the marks of the text.
+	// Dot, the . mark, is always set.
+	Marks map[rune][2]int64
+
+	undo, redo [][]modelChange
+}
+
+// A modelChange is a change to the text.
+type modelChange struct {
+	// Span is the span of the change,
+	// in the text before any change of its batch is made.
+	span [2]int64
+	// Text is the new text of the span.
+	text []rune
+}
+
+// NewModel returns a new Model with the given text and with dot at the beginning.
+func NewModel(text string) *Model {
+	return &Model{
+		Text:  []rune(text),
+		Marks: map[rune][2]int64{'.': {}},
+	}
+}
+
+// Do performs an Op on the Model.
+// It returns an error if the Op is invalid.
+func (m *Model) Do(op Op) error {
+	size := int64(len(m.Text))
+	if op.Kind != UndoOp && op.Kind != RedoOp {
+		if op.Span[0] < 0 || op.Span[0] > op.Span[1] || op.Span[1] > size {
+			return fmt.Errorf("span out of range: %v", op.Span)
+		}
+	}
+	if (op.Kind == MoveOp || op.Kind == CopyOp) && (op.At < 0 || op.At > size) {
+		return fmt.Errorf("destination out of range: %d", op.At)
+	}
+	switch op.Kind {
+	case ChangeOp:
+		m.Marks['.'] = op.Span
+		m.apply([]modelChange{{span: op.Span, text: []rune(op.Text)}})
+	case MoveOp:
+		if op.At > op.Span[0] && op.At < op.Span[1] {
+			return fmt.Errorf("move overlaps: %v to %d", op.Span, op.At)
+		}
+		m.Marks['.'] = [2]int64{op.At, op.At}
+		text := m.slice(op.Span)
+		del := modelChange{span: op.Span}
+		ins := modelChange{span: [2]int64{op.At, op.At}, text: text}
+		if op.At >= op.Span[1] {
+			m.apply([]modelChange{del, ins})
+		} else {
+			m.apply([]modelChange{ins, del})
+		}
+	case CopyOp:
+		m.Marks['.'] = [2]int64{op.At, op.At}
+		m.apply([]modelChange{{span: [2]int64{op.At, op.At}, text: m.slice(op.Span)}})
+	case MarkOp:
+		m.Marks[op.Mark] = op.Span
+	case UndoOp:
+		m.undoRedo(&m.undo, &m.redo, false)
+	case RedoOp:
+		m.undoRedo(&m.redo, &m.undo, true)
+	default:
+		return fmt.Errorf("bad op kind: %d", op.Kind)
+	}
+	return nil
+}
+
+func (m *Model) slice(s [2]int64) []rune {
+	return append([]rune{}, m.Text[s[0]:s[1]]...)
+}
+
+// Apply makes a batch of changes, in ascending order,
+// logs their inverse to the undo stack, and clears the redo stack.
+// Dot grows to include text inserted at its beginning.
+func (m *Model) apply(batch []modelChange) {
+	var inverse []modelChange
+	dot := m.Marks['.']
+	var d int64
+	for _, c := range batch {
+		s := [2]int64{c.span[0] + d, c.span[1] + d}
+		old := m.slice(s)
+		n := int64(len(c.text))
+		if s[0] == dot[0] {
+			dot[1] = update(dot, s, n)[1]
+		} else {
+			dot = update(dot, s, n)
+		}
+		m.change(s, c.text)
+		d += n - (s[1] - s[0])
+		inverse = append(inverse, modelChange{span: [2]int64{c.span[0], c.span[0] + n}, text: old})
+	}
+	m.Marks['.'] = dot
+	m.undo = append(m.undo, inverse)
+	m.redo = nil
+}
+
+// UndoRedo pops a batch of changes from the from stack,
+// makes them, and pushes their inverse to the to stack.
+// Dot is set to the span covering the changes.
+//
+// Undo makes the changes in order, and redo in reverse order.
+// Either way, each change is made to text that precedes it
+// in the state before any change of the batch,
+// so the original spans are used.
+func (m *Model) undoRedo(from, to *[][]modelChange, reverse bool) {
+	if len(*from) == 0 {
+		return
+	}
+	batch := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	inverse := make([]modelChange, len(batch))
+	for i := range batch {
+		j := i
+		if reverse {
+			j = len(batch) - 1 - i
+		}
+		c := batch[j]
+		n := int64(len(c.text))
+		inverse[j] = modelChange{span: [2]int64{c.span[0], c.span[0] + n}, text: m.slice(c.span)}
+		m.change(c.span, c.text)
+	}
+	if len(batch) > 0 {
+		// The covering span ends at the end of the last change.
+		// When undoing, the changes before it are already made,
+		// so its span is in the final text.
+		// When redoing, it is offset by the changes before it.
+		var d int64
+		if reverse {
+			for _, c := range batch[:len(batch)-1] {
+				d += int64(len(c.text)) - (c.span[1] - c.span[0])
+			}
+		}
+		last := batch[len(batch)-1]
+		m.Marks['.'] = [2]int64{batch[0].span[0], last.span[0] + d + int64(len(last.text))}
+	}
+	*to = append(*to, inverse)
+}
+
+// Change changes the span to the text and updates the marks.
+func (m *Model) change(s [2]int64, text []rune) {
+	n := int64(len(text))
+	m.Text = append(m.Text[:s[0]:s[0]], append(append([]rune{}, text...), m.Text[s[1]:]...)...)
+	for r, t := range m.Marks {
+		m.Marks[r] = update(t, s, n)
+	}
+}
+
+// Update returns the mark s updated to account for t changing to size n.
+// A mark containing the change, other than at its start,
+// grows or shrinks to cover the new text.
+// Otherwise, a mark keeps the runes it covered that were not changed.
+// A mark covering no unchanged runes
+// is an empty mark, after the new text if it was within the change.
+func update(s, t [2]int64, n int64) [2]int64 {
+	d := n - (t[1] - t[0])
+	switch {
+	case s[0] < t[0] && t[1] <= s[1]:
+		return [2]int64{s[0], s[1] + d}
+	case s[0] < s[1] && s[0] < t[0]:
+		// The runes before the change are kept.
+		end := s[1]
+		if end > t[0] {
+			end = t[0]
+		}
+		return [2]int64{s[0], end}
+	case s[0] < s[1] && s[1] > t[1]:
+		// The runes after the change are kept.
+		start := s[0]
+		if start < t[1] {
+			start = t[1]
+		}
+		return [2]int64{start + d, s[1] + d}
+	case s[1] < t[0]:
+		return s
+	case s[0] > t[1]:
+		return [2]int64{s[0] + d, s[1] + d}
+	default:
+		return [2]int64{t[0] + n, t[0] + n}
+	}
+}
+
+// RandomOp returns a random, valid Op for the current state of the Model.
+// Marks set by the Op are one of ., a, or b.
+func (m *Model) RandomOp(rnd *rand.Rand) Op {
+	size := int64(len(m.Text))
+	span := func() [2]int64 {
+		s := [2]int64{rnd.Int63n(size + 1), rnd.Int63n(size + 1)}
+		if s[0] > s[1] {
+			s[0], s[1] = s[1], s[0]
+		}
+		return s
+	}
+	switch k := rnd.Intn(20); {
+	case k < 8:
+		const alphabet = "ab\tαβ/\\\n"
+		rs := []rune(alphabet)
+		text := make([]rune, rnd.Intn(5))
+		for i := range text {
+			text[i] = rs[rnd.Intn(len(rs))]
+		}
+		return Op{Kind: ChangeOp, Span: span(), Text: string(text)}
+	case k < 11:
+		s := span()
+		// The destination is outside of the span.
+		n := s[1] - s[0]
+		at := rnd.Int63n(size - n + 1)
+		if at > s[0] {
+			at += n
+		}
+		return Op{Kind: MoveOp, Span: s, At: at}
+	case k < 13:
+		return Op{Kind: CopyOp, Span: span(), At: rnd.Int63n(size + 1)}
+	case k < 16:
+		return Op{Kind: MarkOp, Span: span(), Mark: []rune(".ab")[rnd.Intn(3)]}
+	case k < 18:
+		return Op{Kind: UndoOp}
+	default:
+		return Op{Kind: RedoOp}
+	}
+}
+
+// Diff returns a description of the differences
+// between the state of a text and marks
+// and the wanted state.
+// If the states are equal, Diff returns "".
+func Diff(text string, marks map[rune][2]int64, wantText string, wantMarks map[rune][2]int64) string {
+	var diff string
+	if text != wantText {
+		diff += fmt.Sprintf("text: got %q, want %q\n", text, wantText)
+	}
+	var ms RuneSlice
+	for r := range marks {
+		ms = append(ms, r)
+	}
+	for r := range wantMarks {
+		if _, ok := marks[r]; !ok {
+			ms = append(ms, r)
+		}
+	}
+	sort.Sort(ms)
+	for _, r := range ms {
+		got, gotOK := marks[r]
+		want, wantOK := wantMarks[r]
+		switch {
+		case !gotOK:
+			diff += fmt.Sprintf("mark %c: missing, want %v\n", r, want)
+		case !wantOK:
+			diff += fmt.Sprintf("mark %c: got %v, want none\n", r, got)
+		case got != want:
+			diff += fmt.Sprintf("mark %c: got %v, want %v\n", r, got, want)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,269 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import "io"
+
+// A Fold is a foldable Span of text
+// and the Folds nested within it.
+type Fold struct {
+	// Span is the Span of the text that folds,
+	// from the start of its first line
+	// through the newline ending its last line.
+	Span Span `json:"span"`
+
+	// Folds are the Folds nested within Span, in order.
+	Folds []Fold `json:"folds,omitempty"`
+}
+
+// A FoldRule computes the Folds of a Text.
+type FoldRule interface {
+	// Folds returns the outermost Folds of the Text, in order.
+	Folds(Text) ([]Fold, error)
+}
+
+// RegexpFolds returns a FoldRule that folds the lines
+// from each line matching the start regular expression
+// through the line matching the corresponding end regular expression.
+// Start and end lines nest like parentheses.
+// A line that matches both first ends a Fold and then starts a Fold,
+// as does the line "} else {" in C-like languages.
+// Unmatched start and end lines are ignored.
+//
+// The regular expressions are matched against each line
+// without its terminating newline or \r\n.
+func RegexpFolds(start, end string) (FoldRule, error) {
+	startRE, err := regexpCompile(start)
+	if err != nil {
+		return nil, err
+	}
+	endRE, err := regexpCompile(end)
+	if err != nil {
+		return nil, err
+	}
+	return regexpFolds{start: startRE, end: endRE}, nil
+}
+
+type regexpFolds struct{ start, end *textRegexp }
+
+func (rf regexpFolds) Folds(text Text) ([]Fold, error) {
+	var stack foldStack
+	err := eachLine(text, func(l textLine) {
+		if rf.end.MatchString(l.text) {
+			stack.pop(l.span[1])
+		}
+		if rf.start.MatchString(l.text) {
+			stack.push(l.span, 0)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stack.done(), nil
+}
+
+// IndentFolds returns a FoldRule that folds each line
+// through the following lines that are indented more deeply.
+// Lines with only whitespace are folded with the following line,
+// unless they trail a Fold.
+//
+// Indentation is measured in columns; a tab advances
+// to the next multiple of tabWidth.
+// If tabWidth is less than 1, a tab is a single column.
+func IndentFolds(tabWidth int) FoldRule {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	return indentFolds(tabWidth)
+}
+
+type indentFolds int
+
+func (tabWidth indentFolds) Folds(text Text) ([]Fold, error) {
+	var stack foldStack
+	// Prev is the end of the previous, non-blank line.
+	var prev int64
+	err := eachLine(text, func(l textLine) {
+		ind, blank := indent(l.text, int(tabWidth))
+		if blank {
+			return
+		}
+		for len(stack) > 1 && stack[len(stack)-1].depth >= ind {
+			stack.pop(prev)
+		}
+		stack.push(l.span, ind)
+		prev = l.span[1]
+	})
+	if err != nil {
+		return nil, err
+	}
+	for len(stack) > 1 {
+		stack.pop(prev)
+	}
+	return stack.done(), nil
+}
+
+// Indent returns the indentation of a line in columns,
+// and whether the line is entirely whitespace.
+func indent(text string, tabWidth int) (int, bool) {
+	var n int
+	for _, r := range text {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += tabWidth - n%tabWidth
+		default:
+			return n, false
+		}
+	}
+	return n, true
+}
+
+// A foldStack is a stack of the open Folds while computing a Fold tree.
+// Its bottom element collects the outermost Folds.
+type foldStack []openFold
+
+type openFold struct {
+	// First is the Span of the first line of the Fold.
+	first Span
+	depth int
+	folds []Fold
+}
+
+func (st *foldStack) push(first Span, depth int) {
+	if len(*st) == 0 {
+		*st = append(*st, openFold{})
+	}
+	*st = append(*st, openFold{first: first, depth: depth})
+}
+
+// Pop closes the top Fold at end.
+// Folds of a single line are discarded,
+// and their nested Folds are lifted to the enclosing Fold.
+func (st *foldStack) pop(end int64) {
+	n := len(*st)
+	if n < 2 {
+		return
+	}
+	top := (*st)[n-1]
+	*st = (*st)[:n-1]
+	parent := &(*st)[n-2]
+	if end <= top.first[1] {
+		parent.folds = append(parent.folds, top.folds...)
+		return
+	}
+	parent.folds = append(parent.folds, Fold{Span: Span{top.first[0], end}, Folds: top.folds})
+}
+
+// Done discards the unclosed Folds, lifting their nested Folds,
+// and returns the outermost Folds.
+func (st *foldStack) done() []Fold {
+	for len(*st) > 1 {
+		n := len(*st)
+		top := (*st)[n-1]
+		*st = (*st)[:n-1]
+		(*st)[n-2].folds = append((*st)[n-2].folds, top.folds...)
+	}
+	if len(*st) == 0 {
+		return nil
+	}
+	return (*st)[0].folds
+}
+
+type textLine struct {
+	// Span is the Span of the line, including its newline.
+	span Span
+	// Text is the text of the line,
+	// excluding its newline or \r\n.
+	text string
+}
+
+// EachLine calls f with each line of the Text, in order.
+func eachLine(text Text, f func(textLine)) error {
+	rr := text.RuneReader(Span{0, text.Size()})
+	var l textLine
+	var rs []rune
+	for {
+		r, w, err := rr.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		l.span[1] += int64(w)
+		if r != '\n' {
+			rs = append(rs, r)
+			continue
+		}
+		if n := len(rs); n > 0 && rs[n-1] == '\r' {
+			rs = rs[:n-1]
+		}
+		l.text = string(rs)
+		f(l)
+		l.span[0] = l.span[1]
+		rs = rs[:0]
+	}
+	if l.span.Size() > 0 {
+		l.text = string(rs)
+		f(l)
+	}
+	return nil
+}
+
+// A FoldTree is the tree of Folds of a Buffer,
+// kept up to date as the Buffer changes.
+//
+// A FoldTree is recomputed by the Buffer's OnChange hook,
+// so it is consistent with the Buffer whenever the Buffer is.
+// Like the Buffer, its methods are not safe for concurrent use
+// without the synchronization used for the Buffer.
+type FoldTree struct {
+	buf   *Buffer
+	rule  FoldRule
+	folds []Fold
+	err   error
+}
+
+// NewFoldTree returns a new FoldTree of a Buffer, computed by a FoldRule.
+func NewFoldTree(buf *Buffer, rule FoldRule) *FoldTree {
+	t := &FoldTree{buf: buf, rule: rule}
+	t.update()
+	buf.OnChange(func([]AppliedChange) { t.update() })
+	return t
+}
+
+func (t *FoldTree) update() { t.folds, t.err = t.rule.Folds(t.buf) }
+
+// Folds returns the outermost Folds of the Buffer, in order,
+// or the error computing them after the most recent change.
+//
+// The returned Folds must not be modified.
+func (t *FoldTree) Folds() ([]Fold, error) { return t.folds, t.err }
+
+// At returns the Folds containing a location,
+// from the outermost to the innermost.
+func (t *FoldTree) At(l int64) []Fold {
+	var path []Fold
+	folds := t.folds
+	for {
+		i := containing(folds, l)
+		if i < 0 {
+			return path
+		}
+		path = append(path, folds[i])
+		folds = folds[i].Folds
+	}
+}
+
+func containing(folds []Fold, l int64) int {
+	for i, f := range folds {
+		if f.Span.Contains(l) {
+			return i
+		}
+		if f.Span[0] > l {
+			break
+		}
+	}
+	return -1
+}
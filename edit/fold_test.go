@@ -0,0 +1,183 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegexpFolds(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Fold
+	}{
+		{text: "", want: nil},
+		{text: "a\nb\n", want: nil},
+		// Single-line folds are discarded.
+		{text: "{}\n", want: nil},
+		{
+			text: "{\n}\n",
+			want: []Fold{{Span: Span{0, 4}}},
+		},
+		{
+			// No trailing newline.
+			text: "{\n}",
+			want: []Fold{{Span: Span{0, 3}}},
+		},
+		{
+			text: "a\n{\nb\n}\nc\n",
+			want: []Fold{{Span: Span{2, 8}}},
+		},
+		{
+			text: "{\n{\n}\n{\n}\n}\n",
+			want: []Fold{{
+				Span: Span{0, 12},
+				Folds: []Fold{
+					{Span: Span{2, 6}},
+					{Span: Span{6, 10}},
+				},
+			}},
+		},
+		{
+			text: "if {\n} else {\n}\n",
+			want: []Fold{
+				{Span: Span{0, 14}},
+				{Span: Span{5, 16}},
+			},
+		},
+		{
+			// Unmatched starts are discarded, lifting their nested Folds.
+			text: "{\n{\n}\n",
+			want: []Fold{{Span: Span{2, 6}}},
+		},
+		{
+			// Unmatched ends are ignored.
+			text: "}\n{\n}\n}\n",
+			want: []Fold{{Span: Span{2, 6}}},
+		},
+		{
+			text: "{\r\n}\r\n",
+			want: []Fold{{Span: Span{0, 6}}},
+		},
+	}
+	rule, err := RegexpFolds(`\{$`, `^\}`)
+	if err != nil {
+		t.Fatalf("RegexpFolds(…)=_,%v, want _,nil", err)
+	}
+	for _, test := range tests {
+		buf := NewBuffer()
+		defer buf.Close()
+		applyChange(t, buf, Span{}, test.text)
+		folds, err := rule.Folds(buf)
+		if err != nil || !reflect.DeepEqual(folds, test.want) {
+			t.Errorf("Folds(%q)=%v,%v, want %v,nil", test.text, folds, err, test.want)
+		}
+	}
+}
+
+func TestRegexpFoldsBadRegexp(t *testing.T) {
+	if _, err := RegexpFolds("(", "x"); err == nil {
+		t.Errorf("RegexpFolds(\"(\", \"x\")=_,nil, want error")
+	}
+	if _, err := RegexpFolds("x", "("); err == nil {
+		t.Errorf("RegexpFolds(\"x\", \"(\")=_,nil, want error")
+	}
+}
+
+func TestIndentFolds(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Fold
+	}{
+		{text: "", want: nil},
+		{text: "a\nb\n", want: nil},
+		{
+			text: "a\n\tb\nc\n",
+			want: []Fold{{Span: Span{0, 5}}},
+		},
+		{
+			text: "a\n\tb\n\t\tc\n\td\n",
+			want: []Fold{{
+				Span:  Span{0, 12},
+				Folds: []Fold{{Span: Span{2, 9}}},
+			}},
+		},
+		{
+			// Blank lines within a Fold are folded,
+			// but trailing blank lines are not.
+			text: "a\n\n\tb\n\nc\n",
+			want: []Fold{{Span: Span{0, 6}}},
+		},
+		{
+			// A tab is the same as tabWidth spaces.
+			text: "a\n\tb\n    c\nd\n",
+			want: []Fold{{Span: Span{0, 11}}},
+		},
+		{
+			// No trailing newline.
+			text: "a\n b",
+			want: []Fold{{Span: Span{0, 4}}},
+		},
+	}
+	for _, test := range tests {
+		buf := NewBuffer()
+		defer buf.Close()
+		applyChange(t, buf, Span{}, test.text)
+		folds, err := IndentFolds(4).Folds(buf)
+		if err != nil || !reflect.DeepEqual(folds, test.want) {
+			t.Errorf("Folds(%q)=%v,%v, want %v,nil", test.text, folds, err, test.want)
+		}
+	}
+}
+
+func TestFoldTree(t *testing.T) {
+	buf := NewBuffer()
+	defer buf.Close()
+	tree := NewFoldTree(buf, IndentFolds(8))
+	if folds, err := tree.Folds(); err != nil || len(folds) != 0 {
+		t.Errorf("tree.Folds()=%v,%v, want [],nil", folds, err)
+	}
+
+	applyChange(t, buf, Span{}, "a\n\tb\n\t\tc\nd\n")
+	want := []Fold{{
+		Span:  Span{0, 9},
+		Folds: []Fold{{Span: Span{2, 9}}},
+	}}
+	if folds, err := tree.Folds(); err != nil || !reflect.DeepEqual(folds, want) {
+		t.Errorf("tree.Folds()=%v,%v, want %v,nil", folds, err, want)
+	}
+	if path := tree.At(6); !reflect.DeepEqual(path, []Fold{want[0], want[0].Folds[0]}) {
+		t.Errorf("tree.At(6)=%v, want %v", path, []Fold{want[0], want[0].Folds[0]})
+	}
+	if path := tree.At(0); !reflect.DeepEqual(path, want) {
+		t.Errorf("tree.At(0)=%v, want %v", path, want)
+	}
+	if path := tree.At(9); len(path) != 0 {
+		t.Errorf("tree.At(9)=%v, want []", path)
+	}
+
+	// Unindent c.
+	if _, err := buf.Change(Span{5, 7}, strings.NewReader("")); err != nil {
+		t.Fatalf("buf.Change(…)=_,%v, want _,nil", err)
+	}
+	if err := buf.Apply(); err != nil {
+		t.Fatalf("buf.Apply()=%v, want nil", err)
+	}
+	want = []Fold{{Span: Span{0, 5}}}
+	if folds, err := tree.Folds(); err != nil || !reflect.DeepEqual(folds, want) {
+		t.Errorf("after change, tree.Folds()=%v,%v, want %v,nil", folds, err, want)
+	}
+
+	if err := buf.Undo(); err != nil {
+		t.Fatalf("buf.Undo()=%v, want nil", err)
+	}
+	want = []Fold{{
+		Span:  Span{0, 9},
+		Folds: []Fold{{Span: Span{2, 9}}},
+	}}
+	if folds, err := tree.Folds(); err != nil || !reflect.DeepEqual(folds, want) {
+		t.Errorf("after undo, tree.Folds()=%v,%v, want %v,nil", folds, err, want)
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WireVersion is the version of the JSON encoding of Edits and Addresses.
+//
+// Edits and Addresses are encoded as a tree of JSON objects.
+// Each object has an "op" field naming the Edit or Address,
+// and fields for its operands.
+// The outermost object also has a "version" field, the WireVersion.
+// For example, the Edit Change(Line(1).Plus(Rune(2)), "abc") is encoded as
+// 	{
+// 		"version": 1,
+// 		"op": "change",
+// 		"addr": {
+// 			"op": "plus",
+// 			"left": {"op": "line", "n": 1},
+// 			"right": {"op": "rune", "n": 2}
+// 		},
+// 		"text": "abc"
+// 	}
+//
+// Unlike the String of an Edit, the encoding does not depend
+// on the syntax of the Edit language, so it is stable across releases.
+// If the encoding must change, the version will be incremented,
+// and UnmarshalEdit and UnmarshalAddress will continue to decode
+// all earlier versions.
+const WireVersion = 1
+
+// A wire is a node of the JSON encoding of an Edit or Address.
+// Fields that are not used by an op are omitted.
+type wire struct {
+	Version int    `json:"version,omitempty"`
+	Op      string `json:"op"`
+
+	N      int64  `json:"n,omitempty"`
+	Mark   string `json:"mark,omitempty"`
+	Regexp string `json:"regexp,omitempty"`
+	Text   string `json:"text,omitempty"`
+	With   string `json:"with,omitempty"`
+	Cmd    string `json:"cmd,omitempty"`
+	Global bool   `json:"global,omitempty"`
+	From   int    `json:"from,omitempty"`
+
+	Addr  *wire   `json:"addr,omitempty"`
+	Left  *wire   `json:"left,omitempty"`
+	Right *wire   `json:"right,omitempty"`
+	Src   *wire   `json:"src,omitempty"`
+	Dst   *wire   `json:"dst,omitempty"`
+	Edit  *wire   `json:"edit,omitempty"`
+	Body  []*wire `json:"body,omitempty"`
+}
+
+func marshalWire(w *wire, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	w.Version = WireVersion
+	return json.Marshal(w)
+}
+
+func (a to) MarshalJSON() ([]byte, error)         { return marshalWire(addrWire(a)) }
+func (a then) MarshalJSON() ([]byte, error)       { return marshalWire(addrWire(a)) }
+func (a between) MarshalJSON() ([]byte, error)    { return marshalWire(addrWire(a)) }
+func (a at) MarshalJSON() ([]byte, error)         { return marshalWire(addrWire(a)) }
+func (a plus) MarshalJSON() ([]byte, error)       { return marshalWire(addrWire(a)) }
+func (a minus) MarshalJSON() ([]byte, error)      { return marshalWire(addrWire(a)) }
+func (a clamp) MarshalJSON() ([]byte, error)      { return marshalWire(addrWire(a)) }
+func (a end) MarshalJSON() ([]byte, error)        { return marshalWire(addrWire(a)) }
+func (a line) MarshalJSON() ([]byte, error)       { return marshalWire(addrWire(a)) }
+func (a col) MarshalJSON() ([]byte, error)        { return marshalWire(addrWire(a)) }
+func (a mark) MarshalJSON() ([]byte, error)       { return marshalWire(addrWire(a)) }
+func (a regexpAddr) MarshalJSON() ([]byte, error) { return marshalWire(addrWire(a)) }
+func (a runeAddr) MarshalJSON() ([]byte, error)   { return marshalWire(addrWire(a)) }
+
+// AddrWire returns the wire encoding of an Address.
+func addrWire(a Address) (*wire, error) {
+	var err error
+	binary := func(op string, l, r Address) *wire {
+		w := &wire{Op: op}
+		if w.Left, err = addrWire(l); err == nil {
+			w.Right, err = addrWire(r)
+		}
+		return w
+	}
+	var w *wire
+	switch a := a.(type) {
+	case to:
+		w = binary("to", a.left, a.right)
+	case then:
+		w = binary("then", a.left, a.right)
+	case between:
+		w = binary("between", a.left, a.right)
+	case at:
+		w = binary("at", a.left, a.right)
+	case plus:
+		w = binary("plus", a.left, a.right)
+	case minus:
+		w = binary("minus", a.left, a.right)
+	case clamp:
+		w = &wire{Op: "clamp"}
+		w.Addr, err = addrWire(a.addr)
+	case end:
+		w = &wire{Op: "end"}
+	case line:
+		w = &wire{Op: "line", N: int64(a.n)}
+		if a.rev {
+			w.N = -w.N
+		}
+	case col:
+		w = &wire{Op: "col", N: int64(a.n)}
+	case mark:
+		w = &wire{Op: "mark", Mark: string(rune(a))}
+	case regexpAddr:
+		w = &wire{Op: "regexp", Regexp: a.regexp}
+	case runeAddr:
+		w = &wire{Op: "rune", N: int64(a)}
+	default:
+		return nil, errors.New("cannot encode address: " + a.String())
+	}
+	return w, err
+}
+
+func (e change) MarshalJSON() ([]byte, error)   { return marshalWire(editWire(e)) }
+func (e move) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e copyEdit) MarshalJSON() ([]byte, error) { return marshalWire(editWire(e)) }
+func (e set) MarshalJSON() ([]byte, error)      { return marshalWire(editWire(e)) }
+func (e print) MarshalJSON() ([]byte, error)    { return marshalWire(editWire(e)) }
+func (e where) MarshalJSON() ([]byte, error)    { return marshalWire(editWire(e)) }
+func (e eval) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e loop) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e pipe) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e undo) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e redo) MarshalJSON() ([]byte, error)     { return marshalWire(editWire(e)) }
+func (e block) MarshalJSON() ([]byte, error)    { return marshalWire(editWire(e)) }
+
+// MarshalJSON returns the JSON encoding of the Substitute.
+// Like String, it encodes the Edit as if Func and Confirm were nil.
+func (e Substitute) MarshalJSON() ([]byte, error) { return marshalWire(editWire(e)) }
+
+// EditWire returns the wire encoding of an Edit.
+func editWire(e Edit) (*wire, error) {
+	var err error
+	addr := func(op string, a Address) *wire {
+		w := &wire{Op: op}
+		w.Addr, err = addrWire(a)
+		return w
+	}
+	var w *wire
+	switch e := e.(type) {
+	case change:
+		switch e.op {
+		case 'c':
+			w = addr("change", e.Address)
+		case 'a':
+			w = addr("append", e.Address)
+		case 'i':
+			w = addr("insert", e.Address)
+		case 'd':
+			w = addr("delete", e.Address)
+		}
+		w.Text = e.str
+	case move:
+		w = &wire{Op: "move"}
+		if w.Src, err = addrWire(e.src); err == nil {
+			w.Dst, err = addrWire(e.dst)
+		}
+	case copyEdit:
+		w = &wire{Op: "copy"}
+		if w.Src, err = addrWire(e.src); err == nil {
+			w.Dst, err = addrWire(e.dst)
+		}
+	case set:
+		w = addr("set", e.Address)
+		w.Mark = string(e.mark)
+	case print:
+		w = addr("print", e.Address)
+	case where:
+		if e.line {
+			w = addr("whereLine", e.Address)
+		} else {
+			w = addr("where", e.Address)
+		}
+	case eval:
+		w = addr("eval", e.Address)
+	case Substitute:
+		w = addr("substitute", e.Address)
+		w.Regexp = e.Regexp
+		w.With = e.With
+		w.Global = e.Global
+		w.From = e.From
+	case loop:
+		w = addr("loop", e.Address)
+		w.Regexp = e.regexp
+		if err == nil {
+			w.Edit, err = editWire(e.body)
+		}
+	case pipe:
+		switch {
+		case !e.to:
+			w = addr("pipeFrom", e.Address)
+		case !e.from:
+			w = addr("pipeTo", e.Address)
+		default:
+			w = addr("pipe", e.Address)
+		}
+		w.Cmd = e.cmd
+	case undo:
+		w = &wire{Op: "undo", N: int64(e)}
+	case redo:
+		w = &wire{Op: "redo", N: int64(e)}
+	case block:
+		w = addr("block", e.Address)
+		for _, b := range e.body {
+			if err != nil {
+				break
+			}
+			var bw *wire
+			bw, err = editWire(b)
+			w.Body = append(w.Body, bw)
+		}
+	default:
+		return nil, errors.New("cannot encode edit: " + e.String())
+	}
+	return w, err
+}
+
+// UnmarshalEdit returns the Edit decoded from its JSON encoding.
+// See WireVersion for a description of the encoding.
+//
+// If data is a JSON string, the Edit is parsed from the string with Ed.
+func UnmarshalEdit(data []byte) (Edit, error) {
+	w, str, err := unmarshalWire(data)
+	switch {
+	case err != nil:
+		return nil, err
+	case w == nil:
+		e, err := parseAll(str, func(rs io.RuneScanner) (interface{}, error) { return Ed(rs) })
+		if err != nil {
+			return nil, err
+		}
+		return e.(Edit), nil
+	}
+	return wireEdit(w)
+}
+
+// UnmarshalAddress returns the Address decoded from its JSON encoding.
+// See WireVersion for a description of the encoding.
+//
+// If data is a JSON string, the Address is parsed from the string with Addr.
+func UnmarshalAddress(data []byte) (Address, error) {
+	w, str, err := unmarshalWire(data)
+	switch {
+	case err != nil:
+		return nil, err
+	case w == nil:
+		a, err := parseAll(str, func(rs io.RuneScanner) (interface{}, error) { return Addr(rs) })
+		if err != nil {
+			return nil, err
+		}
+		return a.(Address), nil
+	}
+	return wireAddr(w)
+}
+
+// UnmarshalJSON decodes the JSON encoding of a Substitute Edit.
+func (e *Substitute) UnmarshalJSON(data []byte) error {
+	ed, err := UnmarshalEdit(data)
+	if err != nil {
+		return err
+	}
+	sub, ok := ed.(Substitute)
+	if !ok {
+		return errors.New("not a substitute edit: " + ed.String())
+	}
+	*e = sub
+	return nil
+}
+
+// UnmarshalWire returns the decoded wire of a JSON object
+// or the decoded string of a JSON string.
+func unmarshalWire(data []byte) (*wire, string, error) {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return nil, str, nil
+	}
+	var w wire
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, "", err
+	}
+	switch {
+	case w.Version == 0:
+		return nil, "", errors.New("missing version")
+	case w.Version > WireVersion:
+		return nil, "", errors.New("unsupported version: " + strconv.Itoa(w.Version))
+	}
+	return &w, "", nil
+}
+
+// ParseAll returns the result of parse on the string,
+// or an error if parse does not consume the entire string.
+func parseAll(str string, parse func(io.RuneScanner) (interface{}, error)) (interface{}, error) {
+	rs := strings.NewReader(str)
+	v, err := parse(rs)
+	if err != nil {
+		return nil, err
+	}
+	if rest := str[len(str)-rs.Len():]; rest != "" {
+		return nil, errors.New("unexpected trailing text: " + rest)
+	}
+	return v, nil
+}
+
+func wireAddr(w *wire) (Address, error) {
+	if w == nil {
+		return nil, errors.New("missing address")
+	}
+	switch w.Op {
+	case "to", "then", "between", "at":
+		left, err := wireAddr(w.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := wireAdditive(w.Right)
+		if err != nil {
+			return nil, err
+		}
+		switch w.Op {
+		case "to":
+			return left.To(right), nil
+		case "then":
+			return left.Then(right), nil
+		case "between":
+			return left.Between(right), nil
+		default:
+			return left.At(right), nil
+		}
+	}
+	return wireAdditive(w)
+}
+
+func wireAdditive(w *wire) (AdditiveAddress, error) {
+	if w == nil {
+		return nil, errors.New("missing address")
+	}
+	switch w.Op {
+	case "plus", "minus":
+		left, err := wireAdditive(w.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := wireSimple(w.Right)
+		if err != nil {
+			return nil, err
+		}
+		if w.Op == "plus" {
+			return left.Plus(right), nil
+		}
+		return left.Minus(right), nil
+	case "to", "then", "between", "at":
+		return nil, errors.New("not an additive address: " + w.Op)
+	}
+	return wireSimple(w)
+}
+
+func wireSimple(w *wire) (SimpleAddress, error) {
+	if w == nil {
+		return nil, errors.New("missing address")
+	}
+	switch w.Op {
+	case "clamp":
+		a, err := wireSimple(w.Addr)
+		if err != nil {
+			return nil, err
+		}
+		return Clamp(a), nil
+	case "end":
+		return End, nil
+	case "line":
+		return Line(int(w.N)), nil
+	case "col":
+		return Col(int(w.N)), nil
+	case "mark":
+		r, err := wireRune(w.Mark)
+		if err != nil {
+			return nil, err
+		}
+		return Mark(r), nil
+	case "regexp":
+		if _, err := regexpCompile(w.Regexp); err != nil {
+			return nil, err
+		}
+		return Regexp(w.Regexp), nil
+	case "rune":
+		return Rune(w.N), nil
+	case "to", "then", "between", "at", "plus", "minus":
+		return nil, errors.New("not a simple address: " + w.Op)
+	}
+	return nil, errors.New("unknown address: " + w.Op)
+}
+
+// WireRune returns the single rune of a string.
+func wireRune(s string) (rune, error) {
+	r, w := utf8.DecodeRuneInString(s)
+	if w == 0 || w != len(s) {
+		return 0, errors.New("bad mark: " + strconv.Quote(s))
+	}
+	return r, nil
+}
+
+func wireEdit(w *wire) (Edit, error) {
+	if w == nil {
+		return nil, errors.New("missing edit")
+	}
+	switch w.Op {
+	case "undo":
+		return Undo(int(w.N)), nil
+	case "redo":
+		return Redo(int(w.N)), nil
+	case "move", "copy":
+		src, err := wireAddr(w.Src)
+		if err != nil {
+			return nil, err
+		}
+		dst, err := wireAddr(w.Dst)
+		if err != nil {
+			return nil, err
+		}
+		if w.Op == "move" {
+			return Move(src, dst), nil
+		}
+		return Copy(src, dst), nil
+	}
+
+	switch w.Op {
+	case "change", "append", "insert", "delete", "set", "print", "where", "whereLine",
+		"eval", "substitute", "loop", "pipe", "pipeTo", "pipeFrom", "block":
+	default:
+		return nil, errors.New("unknown edit: " + w.Op)
+	}
+	a, err := wireAddr(w.Addr)
+	if err != nil {
+		return nil, err
+	}
+	switch w.Op {
+	case "change":
+		return Change(a, w.Text), nil
+	case "append":
+		return Append(a, w.Text), nil
+	case "insert":
+		return Insert(a, w.Text), nil
+	case "delete":
+		return Delete(a), nil
+	case "set":
+		r, err := wireRune(w.Mark)
+		if err != nil {
+			return nil, err
+		}
+		return Set(a, r), nil
+	case "print":
+		return Print(a), nil
+	case "where":
+		return Where(a), nil
+	case "whereLine":
+		return WhereLine(a), nil
+	case "eval":
+		return Eval(a), nil
+	case "substitute":
+		if _, err := regexpCompile(w.Regexp); err != nil {
+			return nil, err
+		}
+		return Substitute{Address: a, Regexp: w.Regexp, With: w.With, Global: w.Global, From: w.From}, nil
+	case "loop":
+		if _, err := regexpCompile(w.Regexp); err != nil {
+			return nil, err
+		}
+		body, err := wireEdit(w.Edit)
+		if err != nil {
+			return nil, err
+		}
+		return Loop(a, w.Regexp, body), nil
+	case "pipe":
+		return Pipe(a, w.Cmd), nil
+	case "pipeTo":
+		return PipeTo(a, w.Cmd), nil
+	case "pipeFrom":
+		return PipeFrom(a, w.Cmd), nil
+	case "block":
+		var body []Edit
+		for _, bw := range w.Body {
+			b, err := wireEdit(bw)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, b)
+		}
+		return Block(a, body...), nil
+	default:
+		panic("impossible")
+	}
+}
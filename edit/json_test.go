@@ -0,0 +1,204 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var jsonAddrs = []Address{
+	All,
+	Dot,
+	End,
+	Rune(0),
+	Rune(5),
+	Rune(-5),
+	Line(0),
+	Line(3),
+	Line(-3),
+	Col(4),
+	Mark('a'),
+	Mark('☺'),
+	Regexp("a[bc]*d"),
+	Regexp("a/b"),
+	Clamp(Rune(100)),
+	Clamp(Line(-2)),
+	Line(1).Plus(Col(5)),
+	Line(1).Minus(Rune(2)).Plus(Regexp("x")),
+	Rune(1).To(Line(2)),
+	Rune(1).Then(Line(2).Plus(Rune(1))),
+	Rune(1).Between(Mark('m')),
+	Rune(1).At(Dot),
+	Rune(1).To(Rune(2)).Then(Rune(3)),
+}
+
+func TestAddressJSON(t *testing.T) {
+	for _, a := range jsonAddrs {
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Errorf("json.Marshal(%q)=_,%v", a, err)
+			continue
+		}
+		got, err := UnmarshalAddress(data)
+		if err != nil {
+			t.Errorf("UnmarshalAddress(%s)=_,%v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, a) {
+			t.Errorf("UnmarshalAddress(%s)=%q, want %q", data, got, a)
+		}
+	}
+}
+
+var jsonEdits = []Edit{
+	Change(All, "Hello, World"),
+	Change(Dot, "/\n\\"),
+	Append(Line(2), "xyz"),
+	Insert(End, ""),
+	Delete(Rune(1).To(Rune(3))),
+	Move(Line(1), Line(5)),
+	Copy(Regexp("abc"), End),
+	Set(Line(3), 'm'),
+	Print(All),
+	Where(Dot),
+	WhereLine(Dot),
+	Eval(All),
+	Sub(All, "a(b*)", "c${1}"),
+	SubGlobal(Line(2), "x", "y"),
+	Substitute{Address: Dot, Regexp: "x", With: "y", From: 3},
+	Loop(All, "[a-z]+", Change(Dot, "word")),
+	Loop(All, "x", Loop(Dot, "y", Delete(Dot))),
+	Pipe(Dot, "sort"),
+	PipeTo(All, "wc -l"),
+	PipeFrom(End, "date"),
+	Undo(1),
+	Undo(3),
+	Redo(2),
+	Block(All, Print(Dot), Change(Line(1), "a"), Block(Dot, Delete(Dot))),
+	Block(Dot),
+}
+
+func TestEditJSON(t *testing.T) {
+	for _, e := range jsonEdits {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Errorf("json.Marshal(%q)=_,%v", e, err)
+			continue
+		}
+		got, err := UnmarshalEdit(data)
+		if err != nil {
+			t.Errorf("UnmarshalEdit(%s)=_,%v", data, err)
+			continue
+		}
+		if got.String() != e.String() {
+			t.Errorf("UnmarshalEdit(%s)=%q, want %q", data, got, e)
+		}
+	}
+}
+
+func TestEditJSONEncoding(t *testing.T) {
+	tests := []struct {
+		edit Edit
+		want string
+	}{
+		{
+			edit: Change(Line(1).Plus(Rune(2)), "abc"),
+			want: `{"version":1,"op":"change","text":"abc",` +
+				`"addr":{"op":"plus","left":{"op":"line","n":1},"right":{"op":"rune","n":2}}}`,
+		},
+		{
+			edit: Set(Line(-2), 'm'),
+			want: `{"version":1,"op":"set","mark":"m","addr":{"op":"line","n":-2}}`,
+		},
+		{
+			edit: Loop(All, "x", Delete(Dot)),
+			want: `{"version":1,"op":"loop","regexp":"x",` +
+				`"addr":{"op":"to","left":{"op":"line"},"right":{"op":"end"}},` +
+				`"edit":{"op":"delete","addr":{"op":"mark","mark":"."}}}`,
+		},
+		{
+			edit: Undo(2),
+			want: `{"version":1,"op":"undo","n":2}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.edit)
+		if err != nil {
+			t.Errorf("json.Marshal(%q)=_,%v", test.edit, err)
+			continue
+		}
+		if string(data) != test.want {
+			t.Errorf("json.Marshal(%q)=%s, want %s", test.edit, data, test.want)
+		}
+	}
+}
+
+func TestSubstituteJSON(t *testing.T) {
+	sub := SubConfirm(Line(1), "a", "b", func(Span, string) Confirmation { return Accept }).(Substitute)
+	data, err := json.Marshal(sub)
+	if err != nil {
+		t.Fatalf("json.Marshal(%q)=_,%v", sub, err)
+	}
+	var got Substitute
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s, …)=%v", data, err)
+	}
+	if got.Confirm != nil || got.Func != nil || got.String() != sub.String() {
+		t.Errorf("json.Unmarshal(%s, …) got %q, want %q with no Confirm or Func", data, got, sub)
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"op":"print","addr":{"op":"end"}}`), &got); err == nil {
+		t.Errorf("json.Unmarshal(print, &Substitute)=nil, want error")
+	}
+}
+
+func TestUnmarshalString(t *testing.T) {
+	e, err := UnmarshalEdit([]byte(`"1,$ s/a/b/g"`))
+	if err != nil {
+		t.Fatalf("UnmarshalEdit(string)=_,%v", err)
+	}
+	if want := SubGlobal(Line(1).To(End), "a", "b"); e.String() != want.String() {
+		t.Errorf("UnmarshalEdit(string)=%q, want %q", e, want)
+	}
+	a, err := UnmarshalAddress([]byte(`"#3,/x/"`))
+	if err != nil {
+		t.Fatalf("UnmarshalAddress(string)=_,%v", err)
+	}
+	if want := Rune(3).To(Regexp("x")); !reflect.DeepEqual(a, want) {
+		t.Errorf("UnmarshalAddress(string)=%q, want %q", a, want)
+	}
+}
+
+func TestUnmarshalEditError(t *testing.T) {
+	tests := []struct {
+		data, err string
+	}{
+		{data: `{"op":"print","addr":{"op":"end"}}`, err: "missing version"},
+		{data: `{"version":2,"op":"print","addr":{"op":"end"}}`, err: "unsupported version: 2"},
+		{data: `{"version":1,"op":"frob"}`, err: "unknown edit: frob"},
+		{data: `{"version":1,"op":"print"}`, err: "missing address"},
+		{data: `{"version":1,"op":"print","addr":{"op":"frob"}}`, err: "unknown address: frob"},
+		{data: `{"version":1,"op":"set","mark":"ab","addr":{"op":"end"}}`, err: "bad mark"},
+		{data: `{"version":1,"op":"print","addr":{"op":"regexp","regexp":"/(/"}}`, err: "missing closing )"},
+		{data: `{"version":1,"op":"loop","regexp":"x","addr":{"op":"end"}}`, err: "missing edit"},
+		{
+			data: `{"version":1,"op":"print","addr":` +
+				`{"op":"plus","left":{"op":"end"},"right":{"op":"plus","left":{"op":"end"},"right":{"op":"end"}}}}`,
+			err: "not a simple address: plus",
+		},
+		{
+			data: `{"version":1,"op":"print","addr":` +
+				`{"op":"to","left":{"op":"end"},"right":{"op":"to","left":{"op":"end"},"right":{"op":"end"}}}}`,
+			err: "not an additive address: to",
+		},
+		{data: `"p trailing"`, err: "unexpected trailing text"},
+		{data: `[1]`, err: "cannot unmarshal"},
+	}
+	for _, test := range tests {
+		if _, err := UnmarshalEdit([]byte(test.data)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("UnmarshalEdit(%s)=_,%v, want containing %q", test.data, err, test.err)
+		}
+	}
+}
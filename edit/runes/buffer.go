@@ -34,9 +34,58 @@ type Buffer struct {
 	cache []rune
 	// Dirty tracks whether the cached data has changed since it was read.
 	dirty bool
+	// Lru holds the data of recently used blocks,
+	// most recently used first.
+	// If cached is non-negative, its data is at lru[0].
+	// Only the data of the cached block may differ from the file.
+	lru []cachedBlock
+	// CacheBlocks is the maximum number of blocks in lru.
+	cacheBlocks int
+	// Bytes is scratch space for reading and writing blocks of the file.
+	bytes []byte
 
 	// Size is the number of runes in the buffer.
 	size int64
+
+	// Hits and misses count the block loads
+	// that did and did not find the block in the cache.
+	// Evictions counts the blocks removed from the cache
+	// to make room for another.
+	hits, misses, evictions int64
+}
+
+// A cachedBlock is the data of a block cached in memory.
+type cachedBlock struct {
+	// Start is the start of the block in the file.
+	// It is -1 if the data is not of any block.
+	start int64
+	data  []rune
+}
+
+// Stats are statistics about a Buffer's use of resources.
+type Stats struct {
+	// Runes is the number of runes in the Buffer.
+	Runes int64
+	// Blocks is the number of blocks holding the runes.
+	Blocks int
+	// CacheBytes is the number of bytes of runes cached in memory.
+	CacheBytes int
+	// CacheHits is the number of accesses that were served by the cache.
+	// CacheMisses is the number of accesses that loaded a block into the cache.
+	CacheHits, CacheMisses int64
+	// CacheEvictions is the number of blocks removed from the cache
+	// to make room for other blocks.
+	CacheEvictions int64
+}
+
+// HitRate returns the fraction of accesses served by the cache.
+// If there have been no accesses, HitRate returns 0.
+func (s Stats) HitRate() float64 {
+	n := s.CacheHits + s.CacheMisses
+	if n == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(n)
 }
 
 // A ReaderWriterAt implements the io.ReaderAt and io.WriterAt interfaces.
@@ -55,11 +104,26 @@ type block struct {
 
 // NewBuffer returns a new, empty buffer.
 // No more than blockSize runes are cached in memory.
-func NewBuffer(blockSize int) *Buffer {
+func NewBuffer(blockSize int) *Buffer { return NewBufferCache(blockSize, 1) }
+
+// NewBufferCache returns a new, empty buffer
+// that caches up to cacheBlocks blocks of blockSize runes in memory,
+// evicting the least recently used block to make room for another.
+// If cacheBlocks is less than 1, 1 is used.
+//
+// Caching more than one block helps when accesses alternate
+// between different parts of the buffer,
+// such as a regular expression search
+// that repeatedly scans from the beginning of the text,
+// which would otherwise reload each block from the file.
+func NewBufferCache(blockSize, cacheBlocks int) *Buffer {
+	if cacheBlocks < 1 {
+		cacheBlocks = 1
+	}
 	return &Buffer{
-		blockSize: blockSize,
-		cached:    -1,
-		cache:     make([]rune, blockSize),
+		blockSize:   blockSize,
+		cached:      -1,
+		cacheBlocks: cacheBlocks,
 	}
 }
 
@@ -76,6 +140,8 @@ func NewBufferReaderWriterAt(blockSize int, f ReaderWriterAt) *Buffer {
 // Close closes the buffer and removes it's backing file.
 func (b *Buffer) Close() error {
 	b.cache = nil
+	b.lru = nil
+	b.bytes = nil
 	switch f := b.f.(type) {
 	case *os.File:
 		path := f.Name()
@@ -93,6 +159,18 @@ func (b *Buffer) Close() error {
 // Size returns the number of runes in the buffer.
 func (b *Buffer) Size() int64 { return b.size }
 
+// Stats returns statistics about the buffer's use of resources.
+func (b *Buffer) Stats() Stats {
+	return Stats{
+		Runes:          b.size,
+		Blocks:         len(b.blocks),
+		CacheBytes:     b.cacheBlocks * b.blockSize * runeBytes,
+		CacheHits:      b.hits,
+		CacheMisses:    b.misses,
+		CacheEvictions: b.evictions,
+	}
+}
+
 // Rune returns the rune at the given offset.
 // If the rune is out of range it panics.
 func (b *Buffer) Rune(offs int64) (rune, error) {
@@ -100,6 +178,7 @@ func (b *Buffer) Rune(offs int64) (rune, error) {
 		panic("rune index out of bounds")
 	}
 	if q0 := b.cached0; b.cached >= 0 && q0 <= offs && offs < q0+int64(b.blocks[b.cached].n) {
+		b.hits++
 		return b.cache[offs-q0], nil
 	}
 	i, q0 := b.blockAt(offs)
@@ -195,7 +274,32 @@ func (dst *readerFrom) ReadFrom(r Reader) (int64, error) {
 	if sz, ok := readLen(r); ok {
 		return fastReadFrom(dst, r, sz)
 	}
-	return slowCopy(dst.Buffer.Writer(dst.pos), r)
+	return streamReadFrom(dst, r)
+}
+
+// StreamReadFrom reads from a Reader of unknown length
+// a block at a time, inserting each block into the Buffer,
+// so that no more than a block of runes is held in memory.
+func streamReadFrom(dst *readerFrom, r Reader) (int64, error) {
+	var tot int64
+	p := make([]rune, dst.blockSize)
+	for {
+		n, rerr := r.Read(p)
+		if n > 0 {
+			blk := &readerFrom{Buffer: dst.Buffer, pos: dst.pos + tot}
+			m, err := fastReadFrom(blk, SliceReader(p[:n]), int64(n))
+			tot += m
+			if err != nil {
+				return tot, err
+			}
+		}
+		switch {
+		case rerr == io.EOF:
+			return tot, nil
+		case rerr != nil:
+			return tot, rerr
+		}
+	}
 }
 
 func fastReadFrom(dst *readerFrom, r Reader, sz int64) (int64, error) {
@@ -274,10 +378,8 @@ func (b *Buffer) makeSpace(n, at int64) ([]rune, error) {
 		}
 	case at == b.Size():
 		// Try to extend the last block if we are inserting on the end.
-		for _, blk := range b.blocks[:len(b.blocks)-1] {
-			blkStart += int64(blk.n)
-		}
 		i = len(b.blocks) - 1
+		blkStart = b.Size() - int64(b.blocks[i].n)
 	default:
 		i, blkStart = b.blockAt(at)
 	}
@@ -368,6 +470,12 @@ func (b *Buffer) allocBlock() block {
 
 func (b *Buffer) freeBlock(blk block) {
 	b.free = append(b.free, block{start: blk.start})
+	// The block may be re-allocated, so its cached data is stale.
+	for i := range b.lru {
+		if b.lru[i].start == blk.start {
+			b.lru[i].start = -1
+		}
+	}
 }
 
 // BlockAt returns the index and start address of the block containing the address.
@@ -431,6 +539,7 @@ func (b *Buffer) insertAt(at int64) (int, error) {
 	b.blocks[i+2].n = blk.n - o
 	copy(b.cache, b.cache[o:])
 	b.cached = i + 2
+	b.lru[0].start = nblk.start
 	b.dirty = true
 
 	return i + 1, nil
@@ -458,7 +567,7 @@ func (b *Buffer) put() error {
 	if err != nil {
 		return err
 	}
-	bs := make([]byte, blk.n*runeBytes)
+	bs := b.scratch(blk.n * runeBytes)
 	for i, r := range b.cache[:blk.n] {
 		binary.LittleEndian.PutUint32(bs[i*runeBytes:], uint32(r))
 	}
@@ -469,34 +578,56 @@ func (b *Buffer) put() error {
 	return nil
 }
 
+// Scratch returns n bytes of scratch space,
+// which is reused by each call.
+func (b *Buffer) scratch(n int) []byte {
+	if cap(b.bytes) < n {
+		b.bytes = make([]byte, n)
+	}
+	return b.bytes[:n]
+}
+
 // Get loads the cache with the data from the block at the given index,
 // returning a pointer to it.
 func (b *Buffer) get(i int) (*block, error) {
 	if b.cached == i {
+		b.hits++
 		return &b.blocks[i], nil
 	}
 	if err := b.put(); err != nil {
 		return nil, err
 	}
+	// The data of the cached block is now in the file,
+	// so if loading block i fails, nothing is lost.
+	b.cached = -1
 
 	blk := b.blocks[i]
-	f, err := b.file()
-	if err != nil {
-		return nil, err
-	}
-	bs := make([]byte, blk.n*runeBytes)
-	if _, err := f.ReadAt(bs, blk.start); err != nil {
-		if err == io.EOF {
-			panic("unexpected EOF")
+	if k := b.lookup(blk.start); k >= 0 {
+		b.hits++
+		b.use(k)
+	} else {
+		b.misses++
+		b.use(b.evict())
+		b.lru[0].start = -1
+		f, err := b.file()
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
-	}
-	j := 0
-	for len(bs) > 0 {
-		b.cache[j] = rune(binary.LittleEndian.Uint32(bs))
-		bs = bs[runeBytes:]
-		j++
+		bs := b.scratch(blk.n * runeBytes)
+		if _, err := f.ReadAt(bs, blk.start); err != nil {
+			if err == io.EOF {
+				panic("unexpected EOF")
+			}
+			return nil, err
+		}
+		data := b.lru[0].data
+		for j := 0; len(bs) > 0; j++ {
+			data[j] = rune(binary.LittleEndian.Uint32(bs))
+			bs = bs[runeBytes:]
+		}
+		b.lru[0].start = blk.start
 	}
+	b.cache = b.lru[0].data
 	b.cached = i
 	b.dirty = false
 	b.cached0 = 0
@@ -505,3 +636,40 @@ func (b *Buffer) get(i int) (*block, error) {
 	}
 	return &b.blocks[i], nil
 }
+
+// Lookup returns the index in lru of the data of the block
+// starting at the given file offset, or -1 if it is not cached.
+func (b *Buffer) lookup(start int64) int {
+	for k, c := range b.lru {
+		if c.start == start {
+			return k
+		}
+	}
+	return -1
+}
+
+// Use moves the kth cached block to the front of lru.
+func (b *Buffer) use(k int) {
+	c := b.lru[k]
+	copy(b.lru[1:k+1], b.lru[:k])
+	b.lru[0] = c
+}
+
+// Evict returns the index in lru of a cached block
+// whose data can be overwritten.
+// If the cache is not full, a new cached block is added.
+// Otherwise, data that is not of any block is used if there is some,
+// or else the least recently used block is evicted.
+func (b *Buffer) evict() int {
+	for k := len(b.lru) - 1; k >= 0; k-- {
+		if b.lru[k].start < 0 {
+			return k
+		}
+	}
+	if len(b.lru) < b.cacheBlocks {
+		b.lru = append(b.lru, cachedBlock{start: -1, data: make([]rune, b.blockSize)})
+		return len(b.lru) - 1
+	}
+	b.evictions++
+	return len(b.lru) - 1
+}
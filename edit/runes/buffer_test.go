@@ -5,6 +5,7 @@ package runes
 import (
 	"errors"
 	"io"
+	"math/rand"
 	"reflect"
 	"regexp"
 	"testing"
@@ -371,6 +372,122 @@ func TestBlockAlloc(t *testing.T) {
 	}
 }
 
+func TestStats(t *testing.T) {
+	b := NewBuffer(testBlockSize)
+	defer b.Close()
+
+	if s := b.Stats(); s != (Stats{CacheBytes: testBlockSize * runeBytes}) {
+		t.Errorf("empty b.Stats()=%+v", s)
+	}
+	if r := b.Stats().HitRate(); r != 0 {
+		t.Errorf("empty b.Stats().HitRate()=%v, want 0", r)
+	}
+
+	rs := []rune("αβξδφγθιζ")
+	if err := b.Insert(rs, 0); err != nil {
+		t.Fatalf("b.Insert(%q, 0)=%v, want nil", string(rs), err)
+	}
+	s := b.Stats()
+	if s.Runes != int64(len(rs)) || s.Blocks != 2 || s.CacheBytes != testBlockSize*runeBytes {
+		t.Errorf("b.Stats()=%+v, want Runes=%d, Blocks=2, CacheBytes=%d",
+			s, len(rs), testBlockSize*runeBytes)
+	}
+
+	// Reading from the same block hits the cache;
+	// moving to a different block misses it.
+	s0 := b.Stats()
+	for _, offs := range []int64{0, 1, 2, testBlockSize, 0} {
+		if _, err := b.Rune(offs); err != nil {
+			t.Fatalf("b.Rune(%d)=_,%v, want _,nil", offs, err)
+		}
+	}
+	s1 := b.Stats()
+	if hits, misses := s1.CacheHits-s0.CacheHits, s1.CacheMisses-s0.CacheMisses; hits != 2 || misses != 3 {
+		t.Errorf("%d hits and %d misses, want 2 and 3", hits, misses)
+	}
+	if r := s1.HitRate(); r <= 0 || r >= 1 {
+		t.Errorf("b.Stats().HitRate()=%v, want between 0 and 1", r)
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	b := NewBufferCache(testBlockSize, 2)
+	defer b.Close()
+	if s := b.Stats(); s.CacheBytes != 2*testBlockSize*runeBytes {
+		t.Errorf("b.Stats().CacheBytes=%d, want %d", s.CacheBytes, 2*testBlockSize*runeBytes)
+	}
+	rs := make([]rune, 3*testBlockSize)
+	for i := range rs {
+		rs[i] = rune('a' + i)
+	}
+	if err := b.Insert(rs, 0); err != nil {
+		t.Fatalf("b.Insert(%q, 0)=%v, want nil", string(rs), err)
+	}
+
+	tests := []struct {
+		offs                    int64
+		hits, misses, evictions int64
+	}{
+		// Blocks 1 and 2 were cached by the insert.
+		{offs: 2 * testBlockSize, hits: 1},
+		{offs: testBlockSize, hits: 1},
+		// Block 0 evicts block 2, the least recently used.
+		{offs: 0, misses: 1, evictions: 1},
+		{offs: 1, hits: 1},
+		{offs: testBlockSize + 1, hits: 1},
+		{offs: 0, hits: 1},
+		// Block 2 evicts block 1.
+		{offs: 2 * testBlockSize, misses: 1, evictions: 1},
+		{offs: 0, hits: 1},
+		{offs: testBlockSize, misses: 1, evictions: 1},
+	}
+	for _, test := range tests {
+		s0 := b.Stats()
+		r, err := b.Rune(test.offs)
+		if err != nil || r != rs[test.offs] {
+			t.Fatalf("b.Rune(%d)=%q,%v, want %q,nil", test.offs, r, err, rs[test.offs])
+		}
+		s1 := b.Stats()
+		hits := s1.CacheHits - s0.CacheHits
+		misses := s1.CacheMisses - s0.CacheMisses
+		evictions := s1.CacheEvictions - s0.CacheEvictions
+		if hits != test.hits || misses != test.misses || evictions != test.evictions {
+			t.Errorf("b.Rune(%d): %d hits, %d misses, %d evictions, want %d, %d, %d",
+				test.offs, hits, misses, evictions, test.hits, test.misses, test.evictions)
+		}
+	}
+}
+
+// TestCacheRandomEdits tests random inserts and deletes
+// with a multi-block cache against a slice of runes.
+func TestCacheRandomEdits(t *testing.T) {
+	rand.Seed(0)
+	for _, n := range []int{1, 2, 3, 10} {
+		b := NewBufferCache(testBlockSize, n)
+		var want []rune
+		for i := 0; i < 1000; i++ {
+			at := rand.Intn(len(want) + 1)
+			if rand.Intn(3) == 0 && at < len(want) {
+				m := rand.Intn(len(want)-at) + 1
+				if err := b.Delete(int64(m), int64(at)); err != nil {
+					t.Fatalf("b.Delete(%d, %d)=%v, want nil", m, at, err)
+				}
+				want = append(want[:at], want[at+m:]...)
+			} else {
+				rs := []rune("αβξδφγθιζ"[:rand.Intn(9)*2])
+				if err := b.Insert(rs, int64(at)); err != nil {
+					t.Fatalf("b.Insert(%q, %d)=%v, want nil", string(rs), at, err)
+				}
+				want = append(want[:at], append(rs, want[at:]...)...)
+			}
+			if got := b.String(); got != string(want) {
+				t.Fatalf("cache blocks %d, edit %d: b.String()=%q, want %q", n, i, got, string(want))
+			}
+		}
+		b.Close()
+	}
+}
+
 // TestInsertDeleteAndRead tests performing a few operations in sequence.
 func TestInsertDeleteAndRead(t *testing.T) {
 	b := NewBuffer(testBlockSize)
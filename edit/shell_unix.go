@@ -0,0 +1,30 @@
+// Copyright © 2016, The T Authors.
+
+//go:build !windows
+// +build !windows
+
+package edit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// DefaultShell is the default shell
+// which is used to execute commands
+// if the SHELL environment variable
+// is not set.
+const DefaultShell = "/bin/sh"
+
+func shell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	return DefaultShell
+}
+
+// ShellCommand returns a Cmd that executes cmd with the shell, sh.
+func shellCommand(ctx context.Context, sh, cmd string) *exec.Cmd {
+	return exec.CommandContext(ctx, sh, "-c", cmd)
+}
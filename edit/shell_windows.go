@@ -0,0 +1,53 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// DefaultShell is the default shell
+// which is used to execute commands
+// if neither the SHELL nor the ComSpec
+// environment variable is set.
+const DefaultShell = `C:\Windows\System32\cmd.exe`
+
+func shell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	if sh := os.Getenv("ComSpec"); sh != "" {
+		return sh
+	}
+	return DefaultShell
+}
+
+// ShellCommand returns a Cmd that executes cmd with the shell, sh.
+//
+// The arguments to the shell depend on its name.
+// Cmd.exe is given the command line /S /C "cmd" verbatim,
+// because it does not follow the quoting conventions
+// used by exec to build the command line from the arguments.
+// PowerShell is given the command with -Command.
+// Any other shell is assumed to be a POSIX shell,
+// and is given the command with -c.
+func shellCommand(ctx context.Context, sh, cmd string) *exec.Cmd {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(sh), filepath.Ext(sh)))
+	switch name {
+	case "cmd":
+		c := exec.CommandContext(ctx, sh)
+		c.SysProcAttr = &syscall.SysProcAttr{
+			CmdLine: `"` + sh + `" /S /C "` + cmd + `"`,
+		}
+		return c
+	case "powershell", "pwsh":
+		return exec.CommandContext(ctx, sh, "-NoProfile", "-NonInteractive", "-Command", cmd)
+	default:
+		return exec.CommandContext(ctx, sh, "-c", cmd)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		sh, cmd string
+		args    []string
+		cmdLine string
+	}{
+		{
+			sh:      `C:\Windows\System32\cmd.exe`,
+			cmd:     `echo "hello" & dir`,
+			args:    []string{`C:\Windows\System32\cmd.exe`},
+			cmdLine: `"C:\Windows\System32\cmd.exe" /S /C "echo "hello" & dir"`,
+		},
+		{
+			sh:      `C:\Windows\System32\CMD.EXE`,
+			cmd:     `dir`,
+			args:    []string{`C:\Windows\System32\CMD.EXE`},
+			cmdLine: `"C:\Windows\System32\CMD.EXE" /S /C "dir"`,
+		},
+		{
+			sh:   `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`,
+			cmd:  `Get-Content -Path x`,
+			args: []string{`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`, "-NoProfile", "-NonInteractive", "-Command", `Get-Content -Path x`},
+		},
+		{
+			sh:   `pwsh`,
+			cmd:  `ls`,
+			args: []string{`pwsh`, "-NoProfile", "-NonInteractive", "-Command", `ls`},
+		},
+		{
+			sh:   `C:\Program Files\Git\bin\sh.exe`,
+			cmd:  `sed 's/a/b/'`,
+			args: []string{`C:\Program Files\Git\bin\sh.exe`, "-c", `sed 's/a/b/'`},
+		},
+	}
+	for _, test := range tests {
+		c := shellCommand(context.Background(), test.sh, test.cmd)
+		if !reflect.DeepEqual(c.Args, test.args) {
+			t.Errorf("shellCommand(_, %q, %q).Args=%q, want %q", test.sh, test.cmd, c.Args, test.args)
+		}
+		var cmdLine string
+		if c.SysProcAttr != nil {
+			cmdLine = c.SysProcAttr.CmdLine
+		}
+		if cmdLine != test.cmdLine {
+			t.Errorf("shellCommand(_, %q, %q).SysProcAttr.CmdLine=%q, want %q", test.sh, test.cmd, cmdLine, test.cmdLine)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package edit
 import (
 	"errors"
 	"io"
+	"strings"
 )
 
 var (
@@ -138,3 +139,61 @@ func (s Span) Update(t Span, n int64) Span {
 
 // Contains returns whether a location is within the Span.
 func (s Span) Contains(l int64) bool { return s[0] <= l && l < s[1] }
+
+// An EOL is a line ending style.
+type EOL int
+
+const (
+	// LF ends lines with a line feed, \n.
+	LF EOL = iota
+	// CRLF ends lines with a carriage return and a line feed, \r\n.
+	CRLF
+)
+
+func (eol EOL) String() string {
+	if eol == CRLF {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// DetectEOL returns the dominant line ending style of the runes read from a RuneReader.
+// It returns CRLF if more lines end in \r\n than in a \n alone.
+// Otherwise it returns LF.
+func DetectEOL(rr io.RuneReader) (EOL, error) {
+	var lf, crlf int
+	var prev rune
+	for {
+		r, _, err := rr.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return LF, err
+		}
+		if r == '\n' {
+			if prev == '\r' {
+				crlf++
+			} else {
+				lf++
+			}
+		}
+		prev = r
+	}
+	if crlf > lf {
+		return CRLF, nil
+	}
+	return LF, nil
+}
+
+// NormalizeEOL returns the string with each \r\n replaced by \n.
+func NormalizeEOL(str string) string { return strings.Replace(str, "\r\n", "\n", -1) }
+
+// RestoreEOL returns the string with each line ending
+// replaced by that of the given style.
+func RestoreEOL(str string, eol EOL) string {
+	str = NormalizeEOL(str)
+	if eol == CRLF {
+		str = strings.Replace(str, "\n", "\r\n", -1)
+	}
+	return str
+}
@@ -0,0 +1,57 @@
+// Copyright © 2016, The T Authors.
+
+package edit
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectEOL(t *testing.T) {
+	tests := []struct {
+		text string
+		want EOL
+	}{
+		{text: "", want: LF},
+		{text: "abc", want: LF},
+		{text: "abc\n", want: LF},
+		{text: "abc\r\n", want: CRLF},
+		{text: "abc\r", want: LF},
+		{text: "a\r\nb\r\nc\n", want: CRLF},
+		{text: "a\r\nb\nc\n", want: LF},
+		{text: "a\r\nb\n", want: LF},
+		{text: "\r\r\n\n", want: LF},
+	}
+	for _, test := range tests {
+		got, err := DetectEOL(strings.NewReader(test.text))
+		if got != test.want || err != nil {
+			t.Errorf("DetectEOL(%q)=%v,%v, want %v,nil", test.text, got, err, test.want)
+		}
+	}
+}
+
+func TestRestoreEOL(t *testing.T) {
+	tests := []struct {
+		text     string
+		normal   string
+		eol      EOL
+		restored string
+	}{
+		{text: "", normal: "", eol: CRLF, restored: ""},
+		{text: "abc", normal: "abc", eol: CRLF, restored: "abc"},
+		{text: "a\nb\n", normal: "a\nb\n", eol: LF, restored: "a\nb\n"},
+		{text: "a\nb\n", normal: "a\nb\n", eol: CRLF, restored: "a\r\nb\r\n"},
+		{text: "a\r\nb\r\n", normal: "a\nb\n", eol: CRLF, restored: "a\r\nb\r\n"},
+		{text: "a\r\nb\n", normal: "a\nb\n", eol: CRLF, restored: "a\r\nb\r\n"},
+		{text: "a\r\nb\n", normal: "a\nb\n", eol: LF, restored: "a\nb\n"},
+		{text: "a\rb\r\r\n", normal: "a\rb\r\n", eol: CRLF, restored: "a\rb\r\r\n"},
+	}
+	for _, test := range tests {
+		if got := NormalizeEOL(test.text); got != test.normal {
+			t.Errorf("NormalizeEOL(%q)=%q, want %q", test.text, got, test.normal)
+		}
+		if got := RestoreEOL(test.text, test.eol); got != test.restored {
+			t.Errorf("RestoreEOL(%q, %v)=%q, want %q", test.text, test.eol, got, test.restored)
+		}
+	}
+}
@@ -3,6 +3,7 @@
 package editor
 
 import (
+	"net"
 	"testing"
 
 	"github.com/eaburns/T/edit"
@@ -36,3 +37,56 @@ func BenchmarkDo(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkDo_Local(b *testing.B) {
+	s := NewServer()
+	defer s.Close()
+	c := NewLocalClient(s)
+
+	buf, err := c.NewBuffer()
+	if err != nil {
+		panic(err)
+	}
+	ed, err := c.NewEditor(buf.Path)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := c.Do(ed.Path, edit.Change(edit.All, "Hello, World")); err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Do(ed.Path, edit.Print(edit.All)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func BenchmarkDo_RPC(b *testing.B) {
+	s := NewServer()
+	defer s.Close()
+	clientConn, serverConn := net.Pipe()
+	go s.ServeRPCConn(serverConn)
+	c := NewRPCClient(clientConn)
+	defer c.Disconnect()
+
+	buf, err := c.NewBuffer()
+	if err != nil {
+		panic(err)
+	}
+	ed, err := c.NewEditor(buf.Path)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := c.Do(ed.Path, edit.Change(edit.All, "Hello, World")); err != nil {
+		panic(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Do(ed.Path, edit.Print(edit.All)); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -9,9 +9,14 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/rpc"
 	"net/url"
+	"path"
+	"strconv"
+	"sync"
 
 	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/session"
 	"github.com/eaburns/T/websocket"
 )
 
@@ -21,14 +26,180 @@ var (
 
 	// ErrRange indicates an out-of-range Address.
 	ErrRange = errors.New("bad range")
+
+	// ErrForbidden indicates that the client is not authorized to access a resource.
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrGone indicates that the changes since a sequence number
+	// are no longer available.
+	ErrGone = errors.New("gone")
+
+	// ErrConflict indicates that edits were not performed,
+	// because the buffer was changed since the expected sequence number.
+	ErrConflict = errors.New("conflict")
+)
+
+// A Client is a client of the editor API.
+// Paths are the Path of a Buffer or Editor.
+type Client interface {
+	// BufferList returns the list of opened Buffers.
+	BufferList() ([]Buffer, error)
+	// NewBuffer creates a new buffer and returns its Buffer.
+	NewBuffer() (Buffer, error)
+	// BufferInfo returns the Buffer of the buffer at the given path.
+	BufferInfo(bufferPath string) (Buffer, error)
+	// UpdateBuffer updates the metadata of the buffer at the given path
+	// and returns the updated Buffer.
+	UpdateBuffer(bufferPath string, update BufferUpdate) (Buffer, error)
+	// Changes returns a ChangeStream of the buffer at the given path.
+	Changes(bufferPath string) (*ChangeStream, error)
+	// ChangesSince returns a ChangeStream of the buffer at the given path
+	// that begins with the ChangeLists following the given sequence number.
+	ChangesSince(bufferPath string, seq int) (*ChangeStream, error)
+	// History returns the HistoryEntries of the buffer at the given path.
+	History(bufferPath string) ([]HistoryEntry, error)
+	// Search returns the Spans of matches of a regular expression
+	// in the buffer at the given path.
+	Search(bufferPath, re string, from int64, max int) ([]edit.Span, error)
+	// NewEditor creates a new editor for the buffer at the given path
+	// and returns its Editor.
+	NewEditor(bufferPath string) (Editor, error)
+	// EditorInfo returns the Editor of the editor at the given path.
+	EditorInfo(editorPath string) (Editor, error)
+	// Reader returns an io.ReadCloser that reads the text from a given Address
+	// of the buffer of the editor at the given path.
+	Reader(editorPath string, addr edit.Address) (io.ReadCloser, error)
+	// Do performs a sequence of edits with the editor at the given path
+	// and returns their EditResults.
+	Do(editorPath string, edits ...edit.Edit) ([]EditResult, error)
+	// Transaction performs a transaction and returns its TransactionResult.
+	Transaction(eds ...EditorEdits) (TransactionResult, error)
+	// Close closes the buffer or editor at the given path.
+	Close(path string) error
+}
+
+// An HTTPClient is a Client that makes requests to an editor server over HTTP.
+type HTTPClient struct {
+	// URL is the root URL of the editor server.
+	URL *url.URL
+}
+
+func (c *HTTPClient) url(elems ...string) *url.URL {
+	u := *c.URL
+	u.Path = path.Join(append([]string{u.Path}, elems...)...)
+	return &u
+}
+
+// BufferList implements Client.BufferList.
+func (c *HTTPClient) BufferList() ([]Buffer, error) { return BufferList(c.url("buffers")) }
+
+// NewBuffer implements Client.NewBuffer.
+func (c *HTTPClient) NewBuffer() (Buffer, error) { return NewBuffer(c.url("buffers")) }
+
+// BufferInfo implements Client.BufferInfo.
+func (c *HTTPClient) BufferInfo(bufferPath string) (Buffer, error) {
+	return BufferInfo(c.url(bufferPath))
+}
+
+// UpdateBuffer implements Client.UpdateBuffer.
+func (c *HTTPClient) UpdateBuffer(bufferPath string, update BufferUpdate) (Buffer, error) {
+	return UpdateBuffer(c.url(bufferPath), update)
+}
+
+// Changes implements Client.Changes.
+func (c *HTTPClient) Changes(bufferPath string) (*ChangeStream, error) {
+	return Changes(c.changesURL(bufferPath))
+}
+
+// ChangesSince implements Client.ChangesSince.
+func (c *HTTPClient) ChangesSince(bufferPath string, seq int) (*ChangeStream, error) {
+	return ChangesSince(c.changesURL(bufferPath), seq)
+}
+
+func (c *HTTPClient) changesURL(bufferPath string) *url.URL {
+	u := c.url(bufferPath, "changes")
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	return u
+}
+
+// History implements Client.History.
+func (c *HTTPClient) History(bufferPath string) ([]HistoryEntry, error) {
+	return History(c.url(bufferPath, "history"))
+}
+
+// Search implements Client.Search.
+func (c *HTTPClient) Search(bufferPath, re string, from int64, max int) ([]edit.Span, error) {
+	return Search(c.url(bufferPath, "search"), re, from, max)
+}
+
+// NewEditor implements Client.NewEditor.
+func (c *HTTPClient) NewEditor(bufferPath string) (Editor, error) {
+	return NewEditor(c.url(bufferPath))
+}
+
+// EditorInfo implements Client.EditorInfo.
+func (c *HTTPClient) EditorInfo(editorPath string) (Editor, error) {
+	return EditorInfo(c.url(editorPath))
+}
+
+// Reader implements Client.Reader.
+func (c *HTTPClient) Reader(editorPath string, addr edit.Address) (io.ReadCloser, error) {
+	return Reader(c.url(editorPath, "text"), addr)
+}
+
+// Do implements Client.Do.
+func (c *HTTPClient) Do(editorPath string, edits ...edit.Edit) ([]EditResult, error) {
+	return Do(c.url(editorPath, "text"), edits...)
+}
+
+// Transaction implements Client.Transaction.
+func (c *HTTPClient) Transaction(eds ...EditorEdits) (TransactionResult, error) {
+	return Transaction(c.url("transaction"), eds...)
+}
+
+// Close implements Client.Close.
+func (c *HTTPClient) Close(path string) error { return Close(c.url(path)) }
+
+var (
+	recorderMu sync.Mutex
+	recorder   *session.Recorder
 )
 
+// SetRecorder sets the Recorder of the requests sent by the client functions of this package,
+// their responses, and the ChangeLists received by ChangeStreams.
+// If r is nil, nothing is recorded, which is the default.
+func SetRecorder(r *session.Recorder) {
+	recorderMu.Lock()
+	recorder = r
+	recorderMu.Unlock()
+}
+
+func getRecorder() *session.Recorder {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	return recorder
+}
+
+// httpClient returns the http.Client used by the client functions,
+// which records requests if there is a Recorder.
+func httpClient() *http.Client {
+	r := getRecorder()
+	if r == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: r.Transport(http.DefaultTransport)}
+}
+
 func request(url *url.URL, method string, body io.Reader, resp interface{}) error {
 	httpReq, err := http.NewRequest(method, url.String(), body)
 	if err != nil {
 		return err
 	}
-	httpResp, err := http.DefaultClient.Do(httpReq)
+	httpResp, err := httpClient().Do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -76,40 +247,219 @@ func BufferInfo(URL *url.URL) (Buffer, error) {
 	return buf, nil
 }
 
+// UpdateBuffer does a PATCH and returns the updated Buffer from the response body.
+// The URL is expected to point at a buffer path.
+func UpdateBuffer(URL *url.URL, update BufferUpdate) (Buffer, error) {
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(update); err != nil {
+		return Buffer{}, err
+	}
+	var buf Buffer
+	if err := request(URL, http.MethodPatch, body, &buf); err != nil {
+		return Buffer{}, err
+	}
+	return buf, nil
+}
+
 // A ChangeStream reads changes made to a buffer.
 // Methods on ChangeStream are safe for use by concurrent go routines.
+//
+// If the connection of a ChangeStream is lost
+// after it has received a ChangeList,
+// the ChangeStream reconnects and resumes
+// from the Sequence of the last ChangeList received.
 type ChangeStream struct {
-	conn *websocket.Conn
+	// URL is the URL of the change stream, used to reconnect.
+	// If it and rpc are nil, changes are received directly from a local buffer.
+	url *url.URL
+
+	// RPC is the connection of a change stream watched over RPC,
+	// and watch is the ID of the watch on the RPC server.
+	rpc   *rpc.Client
+	watch int
+
+	connMu     sync.Mutex
+	conn       *websocket.Conn
+	connClosed bool
+
+	buf       *buffer
+	changes   chan []ChangeList
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu sync.Mutex
+	// Batch holds ChangeLists received, but not yet returned by Next.
+	batch []ChangeList
+	// Seq is the Sequence of the last ChangeList received,
+	// or -1 if it is not known.
+	seq int
 }
 
 // Close unblocks any calls to Next and closes the stream.
-func (s *ChangeStream) Close() error { return s.conn.Close() }
+func (s *ChangeStream) Close() error {
+	if s.rpc != nil {
+		var err error
+		s.closeOnce.Do(func() { err = rpcError(s.rpc.Call("Editor.Unwatch", s.watch, nil)) })
+		return err
+	}
+	if s.url != nil {
+		s.connMu.Lock()
+		defer s.connMu.Unlock()
+		s.connClosed = true
+		return s.conn.Close()
+	}
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.buf.unwatch(s.changes)
+	})
+	return nil
+}
 
 // Next returns the next ChangeList from the stream.
 // Calling Next on a closed ChangeStream returns io.EOF.
+//
+// If the connection was lost and the ChangeStream could not resume,
+// Next returns an error; ErrGone if the missed ChangeLists
+// are no longer available from the server.
 func (s *ChangeStream) Next() (ChangeList, error) {
-	var cl ChangeList
-	return cl, s.conn.Recv(&cl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.batch) == 0 {
+		if err := s.fill(); err != nil {
+			return ChangeList{}, err
+		}
+	}
+	cl := s.batch[0]
+	s.batch = s.batch[1:]
+	return cl, nil
+}
+
+// Fill receives the next batch of ChangeLists.
+// Must be called with mu held.
+func (s *ChangeStream) fill() error {
+	switch {
+	case s.rpc != nil:
+		return rpcError(s.rpc.Call("Editor.Next", s.watch, &s.batch))
+	case s.url != nil:
+		return s.recv()
+	}
+	select {
+	case s.batch = <-s.changes:
+		return nil
+	case <-s.buf.done:
+	case <-s.closed:
+	}
+	return io.EOF
+}
+
+// Recv receives the next batch from the websocket,
+// reconnecting if the connection was lost.
+// Must be called with mu held.
+func (s *ChangeStream) recv() error {
+	s.connMu.Lock()
+	conn := s.conn
+	s.connMu.Unlock()
+
+	err := conn.Recv(&s.batch)
+	if n := len(s.batch); err == nil && n > 0 {
+		s.seq = s.batch[n-1].Sequence
+		getRecorder().Message(s.url.String(), s.batch)
+	}
+	if err == nil || err == io.EOF || s.seq < 0 {
+		return err
+	}
+
+	newConn, err := dialChanges(s.url, s.seq)
+	if err != nil {
+		return err
+	}
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.connClosed {
+		newConn.Close()
+		return io.EOF
+	}
+	// The lost connection is already closed,
+	// so an error closing it is not interesting.
+	s.conn.Close()
+	s.conn = newConn
+	return nil
 }
 
 // Changes returns a ChangeStream that reads changes made to a buffer.
 // The URL is expected to point at the changes file of a buffer.
 // Note that the changes file is a websocket, and must use a ws scheme:
 // 	ws://host:port/buffer/<ID>/changes
-func Changes(URL *url.URL) (*ChangeStream, error) {
-	conn, err := websocket.Dial(URL)
+// The URL parameters are kept when reconnecting.
+func Changes(URL *url.URL) (*ChangeStream, error) { return ChangesSince(URL, -1) }
+
+// ChangesSince returns a ChangeStream that reads changes made to a buffer,
+// beginning with the ChangeLists following the given sequence number.
+// If the sequence number is negative, only new changes are read.
+// If the ChangeLists following the sequence number
+// are no longer available, ErrGone is returned.
+// The URL is expected to point at the changes file of a buffer,
+// and must use a ws scheme, as with Changes.
+func ChangesSince(URL *url.URL, seq int) (*ChangeStream, error) {
+	conn, err := dialChanges(URL, seq)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeStream{url: URL, conn: conn, seq: seq}, nil
+}
+
+func dialChanges(URL *url.URL, seq int) (*websocket.Conn, error) {
+	urlCopy := *URL
+	if seq >= 0 {
+		vals := urlCopy.Query()
+		vals.Set("since", strconv.Itoa(seq))
+		urlCopy.RawQuery = vals.Encode()
+	}
+	conn, err := websocket.Dial(&urlCopy)
 	if err != nil {
-		if isNotFoundError(err) {
-			err = ErrNotFound
+		if hsErr, ok := err.(websocket.HandshakeError); ok {
+			switch hsErr.StatusCode {
+			case http.StatusNotFound:
+				err = ErrNotFound
+			case http.StatusForbidden:
+				err = ErrForbidden
+			case http.StatusGone:
+				err = ErrGone
+			}
 		}
 		return nil, err
 	}
-	return &ChangeStream{conn: conn}, nil
+	return conn, nil
 }
 
-func isNotFoundError(err error) bool {
-	hsErr, ok := err.(websocket.HandshakeError)
-	return ok && hsErr.StatusCode == http.StatusNotFound
+// History does a GET and returns a list of HistoryEntries from the response body.
+// The URL is expected to point at the history path of a buffer.
+func History(URL *url.URL) ([]HistoryEntry, error) {
+	var history []HistoryEntry
+	if err := request(URL, http.MethodGet, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Search does a GET and returns a list of Spans from the response body.
+// The regular expression is set as the value of the q URL parameter,
+// from is set as the value of the from URL parameter,
+// and max is set as the value of the max URL parameter.
+// The URL is expected to point at the search path of a buffer.
+func Search(URL *url.URL, re string, from int64, max int) ([]edit.Span, error) {
+	urlCopy := *URL
+	vals := make(url.Values)
+	vals["q"] = []string{re}
+	vals["from"] = []string{strconv.FormatInt(from, 10)}
+	vals["max"] = []string{strconv.Itoa(max)}
+	urlCopy.RawQuery = vals.Encode()
+
+	var spans []edit.Span
+	if err := request(&urlCopy, http.MethodGet, nil, &spans); err != nil {
+		return nil, err
+	}
+	return spans, nil
 }
 
 // NewEditor does a PUT and returns an Editor from the response body.
@@ -122,6 +472,16 @@ func NewEditor(URL *url.URL) (Editor, error) {
 	return ed, nil
 }
 
+// NewLabeledEditor is like NewEditor,
+// but the new Editor has the given Label.
+func NewLabeledEditor(URL *url.URL, label string) (Editor, error) {
+	urlCopy := *URL
+	vals := urlCopy.Query()
+	vals.Set("label", label)
+	urlCopy.RawQuery = vals.Encode()
+	return NewEditor(&urlCopy)
+}
+
 // EditorInfo does a GET and returns an Editor from the response body.
 // The URL is expected to point at an editor path.
 func EditorInfo(URL *url.URL) (Editor, error) {
@@ -144,7 +504,7 @@ func Reader(URL *url.URL, addr edit.Address) (io.ReadCloser, error) {
 		urlCopy.RawQuery += "&" + vals.Encode()
 	}
 
-	httpResp, err := http.Get(urlCopy.String())
+	httpResp, err := httpClient().Get(urlCopy.String())
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +519,21 @@ func Reader(URL *url.URL, addr edit.Address) (io.ReadCloser, error) {
 // from the response body.
 // The URL is expected to point at an editor path.
 func Do(URL *url.URL, edits ...edit.Edit) ([]EditResult, error) {
+	return DoSequence(URL, -1, edits...)
+}
+
+// DoSequence is like Do, but if the sequence number is non-negative,
+// it is added as the value of the sequence URL parameter;
+// the edits are only performed if the buffer's text
+// has not changed since that sequence number.
+// Otherwise ErrConflict is returned.
+func DoSequence(URL *url.URL, seq int, edits ...edit.Edit) ([]EditResult, error) {
+	urlCopy := *URL
+	if seq >= 0 {
+		vals := urlCopy.Query()
+		vals.Set("sequence", strconv.Itoa(seq))
+		urlCopy.RawQuery = vals.Encode()
+	}
 	var eds []editRequest
 	for _, ed := range edits {
 		eds = append(eds, editRequest{ed})
@@ -168,18 +543,62 @@ func Do(URL *url.URL, edits ...edit.Edit) ([]EditResult, error) {
 		return nil, err
 	}
 	var results []EditResult
-	if err := request(URL, http.MethodPost, body, &results); err != nil {
+	if err := request(&urlCopy, http.MethodPost, body, &results); err != nil {
 		return nil, err
 	}
 	return results, nil
 }
 
+// Transform POSTs a TransformRequest and returns its TransformResult
+// from the response body.
+// The URL is expected to point at an editor's transform path.
+func Transform(URL *url.URL, treq TransformRequest) (TransformResult, error) {
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(treq); err != nil {
+		return TransformResult{}, err
+	}
+	var result TransformResult
+	if err := request(URL, http.MethodPost, body, &result); err != nil {
+		return TransformResult{}, err
+	}
+	return result, nil
+}
+
+// Transaction POSTs a transaction and returns its TransactionResult
+// from the response body.
+// The URL is expected to point at an editor server's transaction path.
+func Transaction(URL *url.URL, eds ...EditorEdits) (TransactionResult, error) {
+	var reqs []editorEditsRequest
+	for _, ed := range eds {
+		req := editorEditsRequest{EditorPath: ed.EditorPath}
+		for _, e := range ed.Edits {
+			req.Edits = append(req.Edits, editRequest{e})
+		}
+		reqs = append(reqs, req)
+	}
+	body := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(body).Encode(reqs); err != nil {
+		return TransactionResult{}, err
+	}
+	var result TransactionResult
+	if err := request(URL, http.MethodPost, body, &result); err != nil {
+		return TransactionResult{}, err
+	}
+	return result, nil
+}
+
 func responseError(resp *http.Response) error {
 	switch resp.StatusCode {
 	case http.StatusNotFound:
 		return ErrNotFound
+	case http.StatusForbidden:
+		return ErrForbidden
 	case http.StatusRequestedRangeNotSatisfiable:
 		return ErrRange
+	case http.StatusGone:
+		return ErrGone
+	case http.StatusConflict:
+		return ErrConflict
 	default:
 		data, _ := ioutil.ReadAll(resp.Body)
 		return errors.New(resp.Status + ": " + string(data))
@@ -5,8 +5,7 @@
 package editor
 
 import (
-	"bytes"
-	"errors"
+	"encoding/json"
 	"time"
 
 	"github.com/eaburns/T/edit"
@@ -22,6 +21,18 @@ type Buffer struct {
 	// Path is the path to the buffer's resource.
 	Path string `json:"path"`
 
+	// Name is the name of the buffer.
+	// The name is chosen by clients;
+	// the server places no meaning on it.
+	Name string `json:"name,omitempty"`
+
+	// Size is the size of the buffer's text in runes.
+	Size int64 `json:"size"`
+
+	// Modified is whether the buffer's text has changed
+	// since it was created or since Modified was last cleared.
+	Modified bool `json:"modified"`
+
 	// Sequence is the sequence number of the last edit on the buffer.
 	Sequence int `json:"sequence"`
 
@@ -29,6 +40,24 @@ type Buffer struct {
 	Editors []Editor `json:"editors"`
 }
 
+// A BufferUpdate describes changes to the metadata of a buffer.
+type BufferUpdate struct {
+	// Name, if non-nil, is the new name of the buffer.
+	Name *string `json:"name,omitempty"`
+
+	// Modified, if non-nil, is the new modification state of the buffer.
+	// For example, a client might clear Modified
+	// after saving the buffer's text to a file.
+	Modified *bool `json:"modified,omitempty"`
+
+	// UnchangedSince, if non-nil, is a sequence number.
+	// The update is only made if the buffer's text
+	// has not changed since the edit with this sequence number.
+	// For example, a client that saves the text printed by an edit
+	// can clear Modified only if the saved text is still current.
+	UnchangedSince *int `json:"unchangedSince,omitempty"`
+}
+
 // An Editor describes an editor.
 type Editor struct {
 	// ID is the ID of the editor.
@@ -39,22 +68,26 @@ type Editor struct {
 
 	// BufferPath is the path to the editor's buffer's resource.
 	BufferPath string `json:"bufferPath"`
+
+	// Label is the label of the editor.
+	// The label is chosen by the client that created the editor,
+	// for example, to tell typing apart from command output;
+	// the server places no meaning on it.
+	Label string `json:"label,omitempty"`
 }
 
+// An editRequest is the JSON encoding of an Edit in a request.
+// Edits are sent in the versioned wire format of edit.WireVersion.
+// For compatibility with older clients,
+// an Edit can also be sent as a string in the syntax of edit.Ed.
 type editRequest struct{ edit.Edit }
 
-func (e *editRequest) MarshalText() ([]byte, error) { return []byte(e.String()), nil }
+func (e editRequest) MarshalJSON() ([]byte, error) { return json.Marshal(e.Edit) }
 
-func (e *editRequest) UnmarshalText(text []byte) error {
+func (e *editRequest) UnmarshalJSON(data []byte) error {
 	var err error
-	r := bytes.NewReader(text)
-	if e.Edit, err = edit.Ed(r); err != nil {
-		return err
-	}
-	if l := r.Len(); l != 0 {
-		return errors.New("unexpected trailing text: " + string(text[l:]))
-	}
-	return nil
+	e.Edit, err = edit.UnmarshalEdit(data)
+	return err
 }
 
 // An EditResult is result of performing an edito on a buffer.
@@ -69,16 +102,119 @@ type EditResult struct {
 	Error string `json:"error,omitempty"`
 }
 
+// An EditorEdits is a sequence of edits to perform with a single editor
+// as part of a transaction.
+type EditorEdits struct {
+	// EditorPath is the path to the editor's resource.
+	EditorPath string `json:"editorPath"`
+
+	// Edits are the edits to perform, in order.
+	Edits []edit.Edit `json:"-"`
+}
+
+type editorEditsRequest struct {
+	EditorPath string        `json:"editorPath"`
+	Edits      []editRequest `json:"edits"`
+}
+
+// A TransactionResult is the result of performing a transaction.
+type TransactionResult struct {
+	// Committed is whether all edits of the transaction succeeded.
+	// If Committed is false, all changes made by the transaction were rolled back.
+	Committed bool `json:"committed"`
+
+	// Results contains the EditResults of each EditorEdits
+	// in the order given in the transaction.
+	// Edits following the first failed edit are not performed,
+	// and have no EditResult.
+	Results [][]EditResult `json:"results"`
+}
+
+// A SpanChange is a change to a Span of a buffer's text.
+type SpanChange struct {
+	// Span is the Span of the text to change, in runes.
+	edit.Span `json:"span"`
+
+	// Text is the text to which the Span changes.
+	Text string `json:"text"`
+}
+
+// A TransformRequest is a sequence of changes
+// computed against a possibly stale view of a buffer's text.
+type TransformRequest struct {
+	// Sequence is the sequence number of the buffer's text
+	// against which the changes were computed;
+	// typically the Sequence of the last ChangeList seen by the client.
+	Sequence int `json:"sequence"`
+
+	// Changes are the changes to make, in order.
+	// The Spans must be in terms of the text at Sequence,
+	// and each must not overlap or precede the one before.
+	Changes []SpanChange `json:"changes"`
+}
+
+// A TransformResult is the result of performing a TransformRequest.
+type TransformResult struct {
+	// Sequence is the sequence number unique to the edit
+	// that made the changes.
+	Sequence int `json:"sequence"`
+
+	// Spans are the Spans of the changed text after the changes were made,
+	// one for each change, in the order of the request.
+	Spans []edit.Span `json:"spans"`
+}
+
 // A ChangeList is an atomic sequence of changes
 // made by an edit to a buffer.
 type ChangeList struct {
 	// Sequence is the sequence number
 	// unique to the edit that made the changes.
+	//
+	// An Undo or Redo edit of more than one change
+	// makes a ChangeList for each, all with the same Sequence.
 	Sequence int `json:"sequence"`
 
 	// Changes contains the changes made by an edit.
 	// The changes are in the sequence applied to the buffer.
 	Changes []Change `json:"changes"`
+
+	// EditorPath is the path to the resource of the editor
+	// that made the changes.
+	EditorPath string `json:"editorPath"`
+
+	// Label is the Label of the editor that made the changes.
+	Label string `json:"label,omitempty"`
+
+	// Presence describes the dots of the buffer's editors
+	// that changed since the previous ChangeList.
+	// It is only set for change streams that request presence,
+	// on ChangeLists with no Changes
+	// and the Sequence of the most recent edit.
+	Presence []Presence `json:"presence,omitempty"`
+}
+
+// A Presence describes the dot of an editor,
+// so that clients can show where the other editors of a buffer are.
+type Presence struct {
+	// EditorPath is the path to the editor's resource.
+	EditorPath string `json:"editorPath"`
+
+	// Label is the Label of the editor.
+	Label string `json:"label,omitempty"`
+
+	// Dot is the editor's dot.
+	Dot edit.Span `json:"dot"`
+
+	// Closed is whether the editor was closed.
+	Closed bool `json:"closed,omitempty"`
+}
+
+// A HistoryEntry is a ChangeList in the history of a buffer.
+type HistoryEntry struct {
+	ChangeList
+
+	// Time is the time at which the changes were made.
+	Time time.Time `json:"time"`
 }
 
 // MaxInline is the maximum size, in bytes, for which Change.Text is set.
@@ -3,19 +3,30 @@
 package editor
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unicode/utf8"
 
 	"github.com/eaburns/T/edit"
 	"github.com/eaburns/T/editor/editortest"
+	"github.com/eaburns/T/session"
+	"github.com/eaburns/T/websocket"
+	"github.com/gorilla/mux"
 )
 
 type bufferSlice []Buffer
@@ -104,6 +115,194 @@ func TestBufferInfo(t *testing.T) {
 	}
 }
 
+func TestBufferInfo_SizeAndModified(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{edit.Print(edit.All)}
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+	if got, err := BufferInfo(bufferURL); err != nil || got.Size != 0 || got.Modified {
+		t.Errorf("BufferInfo(%q)=%v,%v, want Size=0, Modified=false", bufferURL, got, err)
+	}
+
+	edits = []edit.Edit{edit.Append(edit.All, "Hello, 世界!")}
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+	if got, err := BufferInfo(bufferURL); err != nil || got.Size != 10 || !got.Modified {
+		t.Errorf("BufferInfo(%q)=%v,%v, want Size=10, Modified=true", bufferURL, got, err)
+	}
+	bufs, err := BufferList(buffersURL)
+	if err != nil || len(bufs) != 1 || bufs[0].Size != 10 || !bufs[0].Modified {
+		t.Errorf("BufferList(%q)=%v,%v, want [{Size=10, Modified=true}]", buffersURL, bufs, err)
+	}
+}
+
+func TestUpdateBuffer(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{edit.Append(edit.All, "Hello")}
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+
+	name := "/tmp/hello.txt"
+	update := BufferUpdate{Name: &name}
+	got, err := UpdateBuffer(bufferURL, update)
+	if err != nil || got.Name != name || !got.Modified {
+		t.Errorf("UpdateBuffer(%q, %v)=%v,%v, want Name=%q, Modified=true", bufferURL, update, got, err, name)
+	}
+
+	modified := false
+	update = BufferUpdate{Modified: &modified}
+	got, err = UpdateBuffer(bufferURL, update)
+	if err != nil || got.Name != name || got.Modified {
+		t.Errorf("UpdateBuffer(%q, %v)=%v,%v, want Name=%q, Modified=false", bufferURL, update, got, err, name)
+	}
+	if info, err := BufferInfo(bufferURL); err != nil || !reflect.DeepEqual(info, got) {
+		t.Errorf("BufferInfo(%q)=%v,%v, want %v,nil", bufferURL, info, err, got)
+	}
+
+	notFoundURL := s.PathURL("/", "buffer", "notfound")
+	if _, err := UpdateBuffer(notFoundURL, update); err != ErrNotFound {
+		t.Errorf("UpdateBuffer(%q, %v)=_,%v, want %v", notFoundURL, update, err, ErrNotFound)
+	}
+}
+
+func TestUpdateBuffer_UnchangedSince(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{
+		edit.Append(edit.All, "Hello"), // 1
+		edit.Print(edit.All),           // 2
+		edit.Where(edit.All),           // 3
+	}
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+
+	// Edits that do not change the text do not conflict.
+	modified := false
+	seq := 2
+	update := BufferUpdate{Modified: &modified, UnchangedSince: &seq}
+	if got, err := UpdateBuffer(bufferURL, update); err != nil || got.Modified {
+		t.Errorf("UpdateBuffer(%q, %v)=%v,%v, want Modified=false", bufferURL, update, got, err)
+	}
+
+	edits = []edit.Edit{edit.Append(edit.End, ", World")} // 4
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+	if _, err := UpdateBuffer(bufferURL, update); err != ErrConflict {
+		t.Errorf("UpdateBuffer(%q, %v)=_,%v, want %v", bufferURL, update, err, ErrConflict)
+	}
+	if info, err := BufferInfo(bufferURL); err != nil || !info.Modified {
+		t.Errorf("BufferInfo(%q)=%v,%v, want Modified=true", bufferURL, info, err)
+	}
+}
+
+// A readOnlyText is an edit.Editor that rejects changes.
+type readOnlyText struct {
+	*edit.Buffer
+	closed bool
+}
+
+var errRejected = errors.New("rejected")
+
+func (*readOnlyText) Change(edit.Span, io.Reader) (int64, error) { return 0, errRejected }
+
+func (t *readOnlyText) Close() error {
+	t.closed = true
+	return t.Buffer.Close()
+}
+
+func TestAddBuffer(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	text := &readOnlyText{Buffer: edit.NewBuffer()}
+	const str = "Hello, World"
+	if err := edit.Change(edit.All, str).Do(text.Buffer, ioutil.Discard); err != nil {
+		t.Fatalf("edit.Change(edit.All, %q).Do(…)=%v, want nil", str, err)
+	}
+	buf := editorServer.AddBuffer("hello", text)
+	if buf.Name != "hello" || buf.Size != int64(len(str)) {
+		t.Errorf("AddBuffer(\"hello\", _)=%v, want name \"hello\" and size %d", buf, len(str))
+	}
+
+	bufferURL := s.PathURL(buf.Path)
+	if got, err := BufferInfo(bufferURL); err != nil || !reflect.DeepEqual(got, buf) {
+		t.Errorf("BufferInfo(%q)=%v,%v, want %v,nil", bufferURL, got, err, buf)
+	}
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{
+		edit.Print(edit.Regexp("World")),
+		edit.Change(edit.Dot, "世界"),
+		edit.Print(edit.All),
+	}
+	want := []EditResult{
+		{Sequence: 1, Print: "World"},
+		{Sequence: 2, Error: errRejected.Error()},
+		{Sequence: 3, Print: str},
+	}
+	if got, err := Do(textURL, edits...); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Do(%q, %v...)=%v,%v, want %v,nil", textURL, edits, got, err, want)
+	}
+	if got, err := BufferInfo(bufferURL); err != nil || got.Modified {
+		t.Errorf("BufferInfo(%q)=%v,%v, want unmodified", bufferURL, got, err)
+	}
+
+	if err := Close(bufferURL); err != nil {
+		t.Errorf("Close(%q)=%v, want nil", bufferURL, err)
+	}
+	if !text.closed {
+		t.Errorf("text.closed=false after Close(%q), want true", bufferURL)
+	}
+}
+
 func TestCloseBuffer(t *testing.T) {
 	s := editortest.NewServer(NewServer())
 	defer s.Close()
@@ -373,6 +572,192 @@ func TestDo_BadRequest(t *testing.T) {
 	}
 }
 
+func TestDo_Sequence(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{edit.Append(edit.End, "a")}
+	want := []EditResult{{Sequence: 1}}
+	if got, err := DoSequence(textURL, 0, eds...); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("DoSequence(%q, 0, %v...)=%v,%v, want %v,nil", textURL, eds, got, err, want)
+	}
+	if got, err := DoSequence(textURL, 0, eds...); err != ErrConflict {
+		t.Errorf("DoSequence(%q, 0, %v...)=%v,%v, want _,%v", textURL, eds, got, err, ErrConflict)
+	}
+	if b, err := BufferInfo(bufferURL); err != nil || b.Sequence != 1 {
+		t.Errorf("BufferInfo(%q)=%v,%v, want {Sequence: 1},nil", bufferURL, b, err)
+	}
+
+	// Edits that do not change the text do not conflict.
+	if _, err := Do(textURL, edit.Print(edit.All), edit.Where(edit.All)); err != nil {
+		t.Fatalf("Do(%q, p, =)=_,%v, want _,nil", textURL, err)
+	}
+	want = []EditResult{{Sequence: 4}}
+	if got, err := DoSequence(textURL, 1, eds...); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("DoSequence(%q, 1, %v...)=%v,%v, want %v,nil", textURL, eds, got, err, want)
+	}
+	if got, err := DoSequence(textURL, 5, eds...); err != ErrConflict {
+		t.Errorf("DoSequence(%q, 5, %v...)=%v,%v, want _,%v", textURL, eds, got, err, ErrConflict)
+	}
+}
+
+func TestDoSequence_KeepsQuery(t *testing.T) {
+	var query string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query = req.URL.RawQuery
+		w.Write([]byte("[]"))
+	}))
+	defer s.Close()
+
+	URL, err := url.Parse(s.URL + "/editor/0/text?x=y")
+	if err != nil {
+		t.Fatalf("url.Parse(…)=_,%v", err)
+	}
+	if _, err := DoSequence(URL, 3); err != nil {
+		t.Fatalf("DoSequence(%q, 3)=_,%v, want _,nil", URL, err)
+	}
+	if want := "sequence=3&x=y"; query != want {
+		t.Errorf("query=%q, want %q", query, want)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	var eds [2]Editor
+	for i := range eds {
+		if eds[i], err = NewEditor(bufferURL); err != nil {
+			t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, eds[i], err)
+		}
+	}
+	textURL := s.PathURL(eds[0].Path, "text")
+	if _, err := Do(textURL, edit.Change(edit.All, "Hello, World")); err != nil { // 1
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+	if _, err := Do(textURL, edit.Change(edit.Regexp("Hello"), "Hi")); err != nil { // 2
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+
+	// Changes computed against "Hello, World", at sequence 1.
+	transformURL := s.PathURL(eds[1].Path, "transform")
+	treq := TransformRequest{
+		Sequence: 1,
+		Changes: []SpanChange{
+			{Span: edit.Span{5, 5}, Text: "!"},
+			{Span: edit.Span{7, 12}, Text: "Earth"},
+		},
+	}
+	want := TransformResult{Sequence: 3, Spans: []edit.Span{{2, 3}, {5, 10}}}
+	if got, err := Transform(transformURL, treq); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform(%q, %v)=%v,%v, want %v,nil", transformURL, treq, got, err, want)
+	}
+	if text := bufferText(t, editorServer, buf.ID); text != "Hi!, Earth" {
+		t.Errorf("buffer text=%q, want %q", text, "Hi!, Earth")
+	}
+
+	// Changes computed against "Hi!, Earth", at sequence 3,
+	// transformed against an Undo back to "Hi, World".
+	if _, err := Do(textURL, edit.Undo(1)); err != nil { // 4
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+	treq = TransformRequest{
+		Sequence: 3,
+		Changes:  []SpanChange{{Span: edit.Span{10, 10}, Text: "?"}},
+	}
+	want = TransformResult{Sequence: 5, Spans: []edit.Span{{9, 10}}}
+	if got, err := Transform(transformURL, treq); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform(%q, %v)=%v,%v, want %v,nil", transformURL, treq, got, err, want)
+	}
+	if text := bufferText(t, editorServer, buf.ID); text != "Hi, World?" {
+		t.Errorf("buffer text=%q, want %q", text, "Hi, World?")
+	}
+
+	treq = TransformRequest{Sequence: 100}
+	if got, err := Transform(transformURL, treq); err == nil {
+		t.Errorf("Transform(%q, %v)=%v,nil, want error", transformURL, treq, got)
+	}
+	treq = TransformRequest{Sequence: -1}
+	if got, err := Transform(transformURL, treq); err == nil || !strings.HasPrefix(err.Error(), "400") {
+		t.Errorf("Transform(%q, %v)=%v,%v, want 400 error", transformURL, treq, got, err)
+	}
+	treq = TransformRequest{
+		Sequence: 5,
+		Changes: []SpanChange{
+			{Span: edit.Span{5, 5}, Text: "a"},
+			{Span: edit.Span{0, 0}, Text: "b"},
+		},
+	}
+	if got, err := Transform(transformURL, treq); err == nil {
+		t.Errorf("Transform(%q, %v)=%v,nil, want error", transformURL, treq, got)
+	}
+	treq = TransformRequest{
+		Sequence: 5,
+		Changes:  []SpanChange{{Span: edit.Span{0, 100}, Text: "a"}},
+	}
+	if got, err := Transform(transformURL, treq); err != ErrRange {
+		t.Errorf("Transform(%q, %v)=%v,%v, want _,%v", transformURL, treq, got, err, ErrRange)
+	}
+	notFoundURL := s.PathURL("/", "editor", "notfound", "transform")
+	if got, err := Transform(notFoundURL, TransformRequest{}); err != ErrNotFound {
+		t.Errorf("Transform(%q, {})=%v,%v, want _,%v", notFoundURL, got, err, ErrNotFound)
+	}
+}
+
+// An opaqueText is an edit.Editor that does not report its changes.
+type opaqueText struct{ edit.Editor }
+
+func TestTransformUntrackedUndo(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	buf := editorServer.AddBuffer("opaque", opaqueText{edit.NewBuffer()})
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	if _, err := Do(textURL, edit.Change(edit.All, "abc"), edit.Change(edit.All, "xyz"), edit.Undo(1)); err != nil {
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+
+	transformURL := s.PathURL(ed.Path, "transform")
+	treq := TransformRequest{
+		Sequence: 2,
+		Changes:  []SpanChange{{Span: edit.Span{0, 0}, Text: "!"}},
+	}
+	if got, err := Transform(transformURL, treq); err != ErrConflict {
+		t.Errorf("Transform(%q, %v)=%v,%v, want _,%v", transformURL, treq, got, err, ErrConflict)
+	}
+	treq.Sequence = 3
+	want := TransformResult{Sequence: 4, Spans: []edit.Span{{0, 1}}}
+	if got, err := Transform(transformURL, treq); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Transform(%q, %v)=%v,%v, want %v,nil", transformURL, treq, got, err, want)
+	}
+}
+
 func TestEditorEdit_UpdateMarks(t *testing.T) {
 	s := editortest.NewServer(NewServer())
 	defer s.Close()
@@ -603,111 +988,150 @@ func TestReader(t *testing.T) {
 	}
 }
 
-func TestChangeStream(t *testing.T) {
+func TestTransaction(t *testing.T) {
 	s := editortest.NewServer(NewServer())
 	defer s.Close()
 
 	buffersURL := s.PathURL("/", "buffers")
-	buf, err := NewBuffer(buffersURL)
-	if err != nil {
-		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
-	}
-
-	bufferURL := s.PathURL(buf.Path)
-	ed, err := NewEditor(bufferURL)
-	if err != nil {
-		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
-	}
-
-	changesURL := s.PathURL(buf.Path, "changes")
-	changesURL.Scheme = "ws"
-	var watchers [3]*ChangeStream
-	for i := range watchers {
-		var err error
-		watchers[i], err = Changes(changesURL)
+	var eds []Editor
+	for i := 0; i < 2; i++ {
+		buf, err := NewBuffer(buffersURL)
 		if err != nil {
-			t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+			t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
 		}
-		defer watchers[i].Close()
-	}
-
-	var hi = "Hello, 世界!" + strings.Repeat("x", MaxInline)
-	eds := []edit.Edit{
-		edit.Insert(edit.All, hi),                                        // 1
-		edit.Change(edit.Regexp("世界"), "World"),                          // 2
-		edit.SubGlobal(edit.All, ",|!", "."),                             // 3
-		edit.Print(edit.All),                                             // 4
-		edit.Where(edit.Dot),                                             // 5
-		edit.Block(edit.All, edit.Where(edit.Dot), edit.Print(edit.Dot)), // 6
-		edit.Delete(edit.All),                                            // 7
-	}
-	textURL := s.PathURL(ed.Path, "text")
-	if res, err := Do(textURL, eds...); err != nil {
-		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+		bufferURL := s.PathURL(buf.Path)
+		ed, err := NewEditor(bufferURL)
+		if err != nil {
+			t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+		}
+		eds = append(eds, ed)
 	}
 
-	wants := []ChangeList{
-		ChangeList{
-			Sequence: 1,
-			Changes: []Change{
-				{
-					Span:    edit.Span{0: 0, 1: 0},
-					NewSize: int64(utf8.RuneCountInString(hi)),
-				},
+	transactionURL := s.PathURL("/", "transaction")
+	txn := []EditorEdits{
+		{
+			EditorPath: eds[0].Path,
+			Edits: []edit.Edit{
+				edit.Append(edit.All, "Hello, 世界!"), // 1
+				edit.Print(edit.All),                // 2
 			},
 		},
-		ChangeList{
-			Sequence: 2,
-			Changes: []Change{
-				{
-					Span:    edit.Span{0: 7, 1: 9},
-					NewSize: int64(utf8.RuneCountInString("World")),
-					Text:    []byte("World"),
-				},
+		{
+			EditorPath: eds[1].Path,
+			Edits: []edit.Edit{
+				edit.Append(edit.All, "abc"), // 1
 			},
 		},
-		ChangeList{
-			Sequence: 3,
-			Changes: []Change{
-				{
-					Span:    edit.Span{0: 5, 1: 6},
-					NewSize: 1,
-					Text:    []byte("."),
-				},
-				{
-					Span:    edit.Span{0: 12, 1: 13},
-					NewSize: 1,
-					Text:    []byte("."),
-				},
+	}
+	want := TransactionResult{
+		Committed: true,
+		Results: [][]EditResult{
+			{{Sequence: 1}, {Sequence: 2, Print: "Hello, 世界!"}},
+			{{Sequence: 1}},
+		},
+	}
+	got, err := Transaction(transactionURL, txn...)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Transaction(%q, %v...)=%v,%v, want %v,nil", transactionURL, txn, got, err, want)
+	}
+
+	for i, want := range []string{"Hello, 世界!", "abc"} {
+		textURL := s.PathURL(eds[i].Path, "text")
+		print := edit.Print(edit.All)
+		if res, err := Do(textURL, print); err != nil || len(res) != 1 || res[0].Print != want {
+			t.Errorf("Do(%q, %v)=%v,%v, want [{Print: %q}],nil", textURL, print, res, err, want)
+		}
+	}
+}
+
+func TestTransaction_RollBack(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	var eds []Editor
+	for i := 0; i < 2; i++ {
+		buf, err := NewBuffer(buffersURL)
+		if err != nil {
+			t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+		}
+		bufferURL := s.PathURL(buf.Path)
+		ed, err := NewEditor(bufferURL)
+		if err != nil {
+			t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+		}
+		eds = append(eds, ed)
+
+		textURL := s.PathURL(ed.Path, "text")
+		edits := []edit.Edit{
+			edit.Append(edit.All, "Hello, World!"), // 1
+			edit.Set(edit.Regexp("World"), 'm'),    // 2
+			edit.Set(edit.Regexp("Hello"), '.'),    // 3
+		}
+		if _, err := Do(textURL, edits...); err != nil {
+			t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+		}
+	}
+
+	transactionURL := s.PathURL("/", "transaction")
+	txn := []EditorEdits{
+		{
+			EditorPath: eds[0].Path,
+			Edits: []edit.Edit{
+				edit.SubGlobal(edit.All, "l", "L"),     // 4
+				edit.Change(edit.Regexp("WorLd"), "☺"), // 5
 			},
 		},
-		// Sequence 4 is a Print, which generates no change.
-		// Sequence 5 is a Where, which generates no change.
-		// Sequence 6 is a Block with Where and Print, which generates no change.
-		ChangeList{
-			Sequence: 7,
-			Changes: []Change{
-				{
-					// +3, because 世界 changed to World.
-					Span:    edit.Span{0: 0, 1: int64(utf8.RuneCountInString(hi)) + 3},
-					NewSize: 0,
-				},
+		{
+			EditorPath: eds[1].Path,
+			Edits: []edit.Edit{
+				edit.Delete(edit.Regexp(", ")),     // 4
+				edit.Insert(edit.All, "abc"),       // 5
+				edit.Print(edit.Regexp("nomatch")), // 6
+				edit.Delete(edit.All),              // not performed
 			},
 		},
 	}
-	for _, want := range wants {
-		for i := range watchers {
-			got, err := watchers[i].Next()
-			if err != nil || !reflect.DeepEqual(got, want) {
-				t.Errorf("watchers[%d].Next()=%v,%v, want %v,nil", i, got, err, want)
-			}
+	want := TransactionResult{
+		Committed: false,
+		Results: [][]EditResult{
+			{{Sequence: 4}, {Sequence: 5}},
+			{{Sequence: 4}, {Sequence: 5}, {Sequence: 6, Error: edit.ErrNoMatch.Error()}},
+		},
+	}
+	got, err := Transaction(transactionURL, txn...)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Transaction(%q, %v...)=%v,%v, want %v,nil", transactionURL, txn, got, err, want)
+	}
+
+	for _, ed := range eds {
+		textURL := s.PathURL(ed.Path, "text")
+		edits := []edit.Edit{
+			edit.Print(edit.Dot),
+			edit.Print(edit.Mark('m')),
+			edit.Print(edit.All),
+		}
+		res, err := Do(textURL, edits...)
+		if err != nil || len(res) != 3 ||
+			res[0].Print != "Hello" || res[1].Print != "World" || res[2].Print != "Hello, World!" {
+			t.Errorf("Do(%q, %v...)=%v,%v, want [Hello World Hello, World!],nil", textURL, edits, res, err)
+		}
+
+		// The rolled back changes are not in the history,
+		// and the next Undo reverts the change before the transaction.
+		historyURL := s.PathURL(ed.BufferPath, "history")
+		if h, err := History(historyURL); err != nil || len(h) != 1 || h[0].Sequence != 1 {
+			t.Errorf("History(%q)=%v,%v, want one entry with sequence 1,nil", historyURL, h, err)
+		}
+		edits = []edit.Edit{edit.Undo(1), edit.Print(edit.All)}
+		if res, err := Do(textURL, edits...); err != nil || len(res) != 2 || res[1].Print != "" {
+			t.Errorf("Do(%q, %v...)=%v,%v, want [_ \"\"],nil", textURL, edits, res, err)
 		}
 	}
 }
 
-func TestChangeStream_Close(t *testing.T) {
-	editorServer := NewServer()
-	s := editortest.NewServer(editorServer)
+func TestTransaction_RollBackNotSent(t *testing.T) {
+	s := editortest.NewServer(NewServer())
 	defer s.Close()
 
 	buffersURL := s.PathURL("/", "buffers")
@@ -715,31 +1139,206 @@ func TestChangeStream_Close(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
 	}
-
-	editorServer.buffers[buf.ID].watcherRemoved = make(chan struct{})
-
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
 	changesURL := s.PathURL(buf.Path, "changes")
 	changesURL.Scheme = "ws"
 	changes, err := Changes(changesURL)
 	if err != nil {
 		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
 	}
+	defer changes.Close()
 
-	if err := changes.Close(); err != nil {
-		t.Fatalf("changes.Close()=%v, want nil", err)
+	transactionURL := s.PathURL("/", "transaction")
+	txn := []EditorEdits{{
+		EditorPath: ed.Path,
+		Edits:      []edit.Edit{edit.Append(edit.All, "abc"), edit.Print(edit.Regexp("nomatch"))},
+	}}
+	if got, err := Transaction(transactionURL, txn...); err != nil || got.Committed {
+		t.Fatalf("Transaction(%q, %v...)=%v,%v, want not committed,nil", transactionURL, txn, got, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	if _, err := Do(textURL, edit.Append(edit.All, "xyz")); err != nil {
+		t.Fatalf("Do(%q, a/xyz/)=_,%v, want _,nil", textURL, err)
+	}
+	cl, err := changes.Next()
+	if err != nil || len(cl.Changes) != 1 || string(cl.Changes[0].Text) != "xyz" {
+		t.Errorf("changes.Next()=%v,%v, want the change to xyz,nil", cl, err)
 	}
+}
 
-	// Wait 1 second for the server to receive the close
-	// and call the defer to remove the watcher.
-	// This is suboptimal, but better than nothing.
-	select {
-	case <-editorServer.buffers[buf.ID].watcherRemoved:
-	case <-time.After(1 * time.Second):
-		t.Errorf("timed out waiting for watcher to close")
+func TestTransaction_NotFound(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	transactionURL := s.PathURL("/", "transaction")
+	txn := []EditorEdits{{EditorPath: "/editor/notfound"}}
+	if _, err := Transaction(transactionURL, txn...); err != ErrNotFound {
+		t.Errorf("Transaction(%q, %v...)=_,%v, want %v", transactionURL, txn, err, ErrNotFound)
 	}
 }
 
-func TestChangeStream_BufferClose(t *testing.T) {
+func TestAuthorize(t *testing.T) {
+	access := map[string]Access{"": WriteAccess, "0": ReadAccess, "1": NoAccess}
+	server := NewServer()
+	server.Authorize = func(_ *http.Request, bufferID string) Access { return access[bufferID] }
+	s := editortest.NewServer(server)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	var bufs []Buffer
+	for i := 0; i < 2; i++ {
+		buf, err := NewBuffer(buffersURL)
+		if err != nil {
+			t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+		}
+		bufs = append(bufs, buf)
+	}
+	readOnly, noAccess := bufs[0], bufs[1]
+
+	if got, err := BufferList(buffersURL); err != nil || len(got) != 1 || got[0].ID != readOnly.ID {
+		t.Errorf("BufferList(%q)=%v,%v, want [%v],nil", buffersURL, got, err, readOnly)
+	}
+
+	noAccessURL := s.PathURL(noAccess.Path)
+	if _, err := BufferInfo(noAccessURL); err != ErrForbidden {
+		t.Errorf("BufferInfo(%q)=_,%v, want %v", noAccessURL, err, ErrForbidden)
+	}
+	if _, err := NewEditor(noAccessURL); err != ErrForbidden {
+		t.Errorf("NewEditor(%q)=_,%v, want %v", noAccessURL, err, ErrForbidden)
+	}
+	changesURL := s.PathURL(noAccess.Path, "changes")
+	changesURL.Scheme = "ws"
+	if changes, err := Changes(changesURL); err != ErrForbidden {
+		t.Errorf("Changes(%q)=_,%v, want %v", changesURL, err, ErrForbidden)
+		if err == nil {
+			changes.Close()
+		}
+	}
+
+	readOnlyURL := s.PathURL(readOnly.Path)
+	if _, err := BufferInfo(readOnlyURL); err != nil {
+		t.Errorf("BufferInfo(%q)=_,%v, want _,nil", readOnlyURL, err)
+	}
+	name := "forbidden"
+	if _, err := UpdateBuffer(readOnlyURL, BufferUpdate{Name: &name}); err != ErrForbidden {
+		t.Errorf("UpdateBuffer(%q, _)=_,%v, want %v", readOnlyURL, err, ErrForbidden)
+	}
+	if err := Close(readOnlyURL); err != ErrForbidden {
+		t.Errorf("Close(%q)=%v, want %v", readOnlyURL, err, ErrForbidden)
+	}
+	ed, err := NewEditor(readOnlyURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", readOnlyURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{
+		edit.Change(edit.All, "Hello"),
+		edit.Set(edit.All, '.'),
+		edit.Undo(1),
+		edit.Print(edit.Dot),
+	}
+	results, err := Do(textURL, edits...)
+	if err != nil || len(results) != len(edits) {
+		t.Fatalf("Do(%q, %v...)=%v,%v, want %d results,nil", textURL, edits, results, err, len(edits))
+	}
+	for i, want := range []bool{true, false, true, false} {
+		if got := results[i].Error != ""; got != want {
+			t.Errorf("Do(%q, %v...)[%d].Error=%q, want error=%v", textURL, edits, i, results[i].Error, want)
+		}
+	}
+
+	transactionURL := s.PathURL("/", "transaction")
+	txn := []EditorEdits{{EditorPath: ed.Path, Edits: []edit.Edit{edit.Print(edit.All)}}}
+	if _, err := Transaction(transactionURL, txn...); err != ErrForbidden {
+		t.Errorf("Transaction(%q, %v...)=_,%v, want %v", transactionURL, txn, err, ErrForbidden)
+	}
+}
+
+func TestAuthorize_ReadOnlyServer(t *testing.T) {
+	server := NewServer()
+	server.Authorize = func(*http.Request, string) Access { return ReadAccess }
+	s := editortest.NewServer(server)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	if _, err := NewBuffer(buffersURL); err != ErrForbidden {
+		t.Errorf("NewBuffer(%q)=_,%v, want %v", buffersURL, err, ErrForbidden)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	edits := []edit.Edit{
+		edit.Append(edit.All, "abc ☺bc abc"),
+		edit.Set(edit.Rune(1), '.'),
+	}
+	if _, err := Do(textURL, edits...); err != nil {
+		t.Fatalf("Do(%q, %v...)=_,%v, want _,nil", textURL, edits, err)
+	}
+
+	searchURL := s.PathURL(buf.Path, "search")
+	tests := []struct {
+		re   string
+		from int64
+		max  int
+		want []edit.Span
+	}{
+		{re: "xyz", want: []edit.Span{}},
+		{re: "bc", want: []edit.Span{{1, 3}, {5, 7}, {9, 11}}},
+		{re: "bc", max: 2, want: []edit.Span{{1, 3}, {5, 7}}},
+		{re: "bc", from: 2, want: []edit.Span{{5, 7}, {9, 11}}},
+		{re: "bc", from: 10, want: []edit.Span{}},
+		{re: "☺", want: []edit.Span{{4, 5}}},
+		{re: "x*", from: 9, want: []edit.Span{{9, 9}, {10, 10}, {11, 11}}},
+	}
+	for _, test := range tests {
+		got, err := Search(searchURL, test.re, test.from, test.max)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Search(%q, %q, %d, %d)=%v,%v, want %v,nil",
+				searchURL, test.re, test.from, test.max, got, err, test.want)
+		}
+	}
+
+	// Searching does not change dot.
+	print := edit.Print(edit.Dot)
+	want := []EditResult{{Sequence: 3}}
+	if got, err := Do(textURL, print); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Do(%q, %v)=%v,%v, want %v,nil", textURL, print, got, err, want)
+	}
+
+	if _, err := Search(searchURL, "abc", 100, 0); err != ErrRange {
+		t.Errorf("Search(%q, \"abc\", 100, 0)=_,%v, want %v", searchURL, err, ErrRange)
+	}
+	if _, err := Search(searchURL, "*", 0, 0); err == nil {
+		t.Errorf("Search(%q, \"*\", 0, 0)=_,nil, want error", searchURL)
+	}
+	notFoundURL := s.PathURL("/", "buffer", "notfound", "search")
+	if _, err := Search(notFoundURL, "abc", 0, 0); err != ErrNotFound {
+		t.Errorf("Search(%q, \"abc\", 0, 0)=_,%v, want %v", notFoundURL, err, ErrNotFound)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	defer func(n int) { changeHistory = n }(changeHistory)
+	changeHistory = 2
+
 	s := editortest.NewServer(NewServer())
 	defer s.Close()
 
@@ -748,35 +1347,1293 @@ func TestChangeStream_BufferClose(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
 	}
+	bufferURL := s.PathURL(buf.Path)
+	historyURL := s.PathURL(buf.Path, "history")
+	if h, err := History(historyURL); err != nil || len(h) != 0 {
+		t.Errorf("History(%q)=%v,%v, want [],nil", historyURL, h, err)
+	}
+
+	var eds []Editor
+	for i := 0; i < 2; i++ {
+		ed, err := NewEditor(bufferURL)
+		if err != nil {
+			t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+		}
+		eds = append(eds, ed)
+	}
+	do := func(ed Editor, e edit.Edit) {
+		textURL := s.PathURL(ed.Path, "text")
+		if res, err := Do(textURL, e); err != nil {
+			t.Fatalf("Do(%q, %v)=%v,%v, want _,nil", textURL, e, res, err)
+		}
+	}
+	start := time.Now()
+	do(eds[0], edit.Append(edit.End, "abc"))      // 1
+	do(eds[1], edit.Print(edit.All))              // 2, no changes
+	do(eds[1], edit.Change(edit.Regexp("b"), "")) // 3
+	do(eds[0], edit.Append(edit.End, "xyz"))      // 4
+
+	got, err := History(historyURL)
+	if err != nil {
+		t.Fatalf("History(%q)=%v,%v, want _,nil", historyURL, got, err)
+	}
+	want := []HistoryEntry{
+		{
+			ChangeList: ChangeList{
+				Sequence:   3,
+				Changes:    []Change{{Span: edit.Span{1, 2}, NewSize: 0}},
+				EditorPath: eds[1].Path,
+			},
+		},
+		{
+			ChangeList: ChangeList{
+				Sequence:   4,
+				Changes:    []Change{{Span: edit.Span{2, 2}, NewSize: 3, Text: []byte("xyz")}},
+				EditorPath: eds[0].Path,
+			},
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("History(%q)=%v, want %v", historyURL, got, want)
+	}
+	for i := range got {
+		if got[i].Time.Before(start) || i > 0 && got[i].Time.Before(got[i-1].Time) {
+			t.Errorf("History(%q)[%d].Time=%v, want ≥ %v", historyURL, i, got[i].Time, start)
+		}
+		got[i].Time = time.Time{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("History(%q)=%v, want %v", historyURL, got, want)
+	}
+
+	notFoundURL := s.PathURL("/", "buffer", "notfound", "history")
+	if h, err := History(notFoundURL); err != ErrNotFound {
+		t.Errorf("History(%q)=%v,%v, want _,%v", notFoundURL, h, err, ErrNotFound)
+	}
+}
+
+func TestNewLabeledEditor(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
 
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
 	changesURL := s.PathURL(buf.Path, "changes")
 	changesURL.Scheme = "ws"
 	changes, err := Changes(changesURL)
 	if err != nil {
 		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
 	}
+	defer changes.Close()
 
 	bufferURL := s.PathURL(buf.Path)
-	if err := Close(bufferURL); err != nil {
-		t.Fatalf("buf.Close()=%v, want nil", err)
+	ed, err := NewLabeledEditor(bufferURL, "typing")
+	if err != nil || ed.Label != "typing" {
+		t.Fatalf("NewLabeledEditor(%q, \"typing\")=%v,%v, want label typing,nil", bufferURL, ed, err)
+	}
+	if got, err := EditorInfo(s.PathURL(ed.Path)); err != nil || got.Label != "typing" {
+		t.Errorf("EditorInfo(%q)=%v,%v, want label typing,nil", ed.Path, got, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	if _, err := Do(textURL, edit.Append(edit.All, "Hello")); err != nil {
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+	if cl, err := changes.Next(); err != nil || cl.EditorPath != ed.Path || cl.Label != "typing" {
+		t.Errorf("changes.Next()=%v,%v, want editor %s labeled typing,nil", cl, err, ed.Path)
+	}
+	historyURL := s.PathURL(buf.Path, "history")
+	if h, err := History(historyURL); err != nil || len(h) != 1 || h[0].Label != "typing" {
+		t.Errorf("History(%q)=%v,%v, want one entry labeled typing,nil", historyURL, h, err)
 	}
 
-	if _, err := changes.Next(); err == nil {
-		t.Errorf("changes.Next()=_,%v, want non-nil", err)
+	badURL := s.PathURL(buf.Path)
+	badURL.RawQuery = "label=a&label=b"
+	if ed, err := NewEditor(badURL); err == nil {
+		t.Errorf("NewEditor(%q)=%v,nil, want error", badURL, ed)
 	}
 }
 
-func TestChangeStream_NotFound(t *testing.T) {
+func TestChangeStream(t *testing.T) {
 	s := editortest.NewServer(NewServer())
 	defer s.Close()
 
-	changesURL := s.PathURL("buffer", "notfound", "changes")
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
 	changesURL.Scheme = "ws"
-	changes, err := Changes(changesURL)
-	if err != ErrNotFound {
-		t.Errorf("Changes(%q)=_,%v, want _,%v", changesURL, err, ErrNotFound)
+	var watchers [3]*ChangeStream
+	for i := range watchers {
+		var err error
+		watchers[i], err = Changes(changesURL)
+		if err != nil {
+			t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+		}
+		defer watchers[i].Close()
 	}
-	if err == nil {
-		changes.Close()
+
+	var hi = "Hello, 世界!" + strings.Repeat("x", MaxInline)
+	eds := []edit.Edit{
+		edit.Insert(edit.All, hi),                                        // 1
+		edit.Change(edit.Regexp("世界"), "World"),                          // 2
+		edit.SubGlobal(edit.All, ",|!", "."),                             // 3
+		edit.Print(edit.All),                                             // 4
+		edit.Where(edit.Dot),                                             // 5
+		edit.Block(edit.All, edit.Where(edit.Dot), edit.Print(edit.Dot)), // 6
+		edit.Delete(edit.All),                                            // 7
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+
+	wants := []ChangeList{
+		ChangeList{
+			Sequence:   1,
+			EditorPath: ed.Path,
+			Changes: []Change{
+				{
+					Span:    edit.Span{0: 0, 1: 0},
+					NewSize: int64(utf8.RuneCountInString(hi)),
+				},
+			},
+		},
+		ChangeList{
+			Sequence:   2,
+			EditorPath: ed.Path,
+			Changes: []Change{
+				{
+					Span:    edit.Span{0: 7, 1: 9},
+					NewSize: int64(utf8.RuneCountInString("World")),
+					Text:    []byte("World"),
+				},
+			},
+		},
+		ChangeList{
+			Sequence:   3,
+			EditorPath: ed.Path,
+			Changes: []Change{
+				{
+					Span:    edit.Span{0: 5, 1: 6},
+					NewSize: 1,
+					Text:    []byte("."),
+				},
+				{
+					Span:    edit.Span{0: 12, 1: 13},
+					NewSize: 1,
+					Text:    []byte("."),
+				},
+			},
+		},
+		// Sequence 4 is a Print, which generates no change.
+		// Sequence 5 is a Where, which generates no change.
+		// Sequence 6 is a Block with Where and Print, which generates no change.
+		ChangeList{
+			Sequence:   7,
+			EditorPath: ed.Path,
+			Changes: []Change{
+				{
+					// +3, because 世界 changed to World.
+					Span:    edit.Span{0: 0, 1: int64(utf8.RuneCountInString(hi)) + 3},
+					NewSize: 0,
+				},
+			},
+		},
+	}
+	for _, want := range wants {
+		for i := range watchers {
+			got, err := watchers[i].Next()
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("watchers[%d].Next()=%v,%v, want %v,nil", i, got, err, want)
+			}
+		}
+	}
+}
+
+func TestSetRecorder(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	var out bytes.Buffer
+	SetRecorder(session.NewRecorder(&out))
+	defer SetRecorder(nil)
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, ed, err)
+	}
+	textURL := s.PathURL(ed.Path, "text")
+	if _, err := Do(textURL, edit.Append(edit.All, "Hello")); err != nil {
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+	if _, err := changes.Next(); err != nil {
+		t.Fatalf("changes.Next()=_,%v, want _,nil", err)
+	}
+	SetRecorder(nil)
+
+	es, err := session.Load(&out)
+	if err != nil {
+		t.Fatalf("session.Load(…)=_,%v, want _,nil", err)
+	}
+	var kinds []string
+	for _, e := range es {
+		kinds = append(kinds, string(e.Kind)+" "+e.Method)
+	}
+	want := []string{"sent PUT", "sent PUT", "sent POST", "message "}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("recorded %v, want %v", kinds, want)
+	}
+	if e := es[2]; e.Status != http.StatusOK || !strings.Contains(e.Request, "Hello") {
+		t.Errorf("recorded Do=%+v, want status OK and a request with Hello", e)
+	}
+	var cls []ChangeList
+	if e := es[3]; e.URL != changesURL.String() ||
+		json.Unmarshal([]byte(e.Request), &cls) != nil ||
+		len(cls) != 1 || len(cls[0].Changes) != 1 || string(cls[0].Changes[0].Text) != "Hello" {
+		t.Errorf("recorded message=%+v, want URL %s and a ChangeList with Hello", e, changesURL)
+	}
+}
+
+func TestChangeStream_Presence(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	a, err := NewLabeledEditor(bufferURL, "a")
+	if err != nil {
+		t.Fatalf("NewLabeledEditor(%q, \"a\")=%v,%v, want _,nil", bufferURL, a, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changesURL.RawQuery = "presence=true"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+	next := func(want ChangeList) {
+		if got, err := changes.Next(); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("changes.Next()=%+v,%v, want %+v,nil", got, err, want)
+		}
+	}
+
+	// The first ChangeList has the Presence of all editors.
+	next(ChangeList{Presence: []Presence{{EditorPath: a.Path, Label: "a"}}})
+
+	b, err := NewLabeledEditor(bufferURL, "b")
+	if err != nil {
+		t.Fatalf("NewLabeledEditor(%q, \"b\")=%v,%v, want _,nil", bufferURL, b, err)
+	}
+	next(ChangeList{Presence: []Presence{{EditorPath: b.Path, Label: "b"}}})
+
+	textURL := s.PathURL(a.Path, "text")
+	if _, err := Do(textURL, edit.Append(edit.All, "Hello")); err != nil {
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+	next(ChangeList{
+		Sequence:   1,
+		Changes:    []Change{{Span: edit.Span{0, 0}, NewSize: 5, Text: []byte("Hello")}},
+		EditorPath: a.Path,
+		Label:      "a",
+	})
+	// Only the dot of a moved; b's empty dot stays before the insert.
+	next(ChangeList{
+		Sequence: 1,
+		Presence: []Presence{{EditorPath: a.Path, Label: "a", Dot: edit.Span{0, 5}}},
+	})
+
+	// Edits that do not move dots send no Presence.
+	if _, err := Do(textURL, edit.Print(edit.Dot)); err != nil {
+		t.Fatalf("Do(%q, …)=_,%v, want _,nil", textURL, err)
+	}
+
+	editorURL := s.PathURL(b.Path)
+	if err := Close(editorURL); err != nil {
+		t.Fatalf("Close(%q)=%v, want nil", editorURL, err)
+	}
+	next(ChangeList{
+		Sequence: 2,
+		Presence: []Presence{{EditorPath: b.Path, Label: "b", Closed: true}},
+	})
+
+	badURL := s.PathURL(buf.Path, "changes")
+	badURL.Scheme = "ws"
+	badURL.RawQuery = "presence=maybe"
+	if c, err := Changes(badURL); err == nil {
+		c.Close()
+		t.Errorf("Changes(%q)=_,nil, want error", badURL)
+	}
+}
+
+func TestChangeStream_Close(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+
+	editorServer.buffers[buf.ID].watcherRemoved = make(chan struct{})
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+
+	if err := changes.Close(); err != nil {
+		t.Fatalf("changes.Close()=%v, want nil", err)
+	}
+
+	// Wait 1 second for the server to receive the close
+	// and call the defer to remove the watcher.
+	// This is suboptimal, but better than nothing.
+	select {
+	case <-editorServer.buffers[buf.ID].watcherRemoved:
+	case <-time.After(1 * time.Second):
+		t.Errorf("timed out waiting for watcher to close")
+	}
+}
+
+func TestChangeStream_BufferClose(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+
+	bufferURL := s.PathURL(buf.Path)
+	if err := Close(bufferURL); err != nil {
+		t.Fatalf("buf.Close()=%v, want nil", err)
+	}
+
+	if _, err := changes.Next(); err == nil {
+		t.Errorf("changes.Next()=_,%v, want non-nil", err)
+	}
+}
+
+func TestChangeStream_NotFound(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	changesURL := s.PathURL("buffer", "notfound", "changes")
+	changesURL.Scheme = "ws"
+	changes, err := Changes(changesURL)
+	if err != ErrNotFound {
+		t.Errorf("Changes(%q)=_,%v, want _,%v", changesURL, err, ErrNotFound)
+	}
+	if err == nil {
+		changes.Close()
+	}
+}
+
+func TestChangeStream_Since(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{
+		edit.Append(edit.End, "a"), // 1
+		edit.Print(edit.All),       // 2
+		edit.Append(edit.End, "b"), // 3
+		edit.Append(edit.End, "c"), // 4
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := ChangesSince(changesURL, 1)
+	if err != nil {
+		t.Fatalf("ChangesSince(%q, 1)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+
+	eds = []edit.Edit{
+		edit.Append(edit.End, "d"), // 5
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+	for _, want := range []int{3, 4, 5} {
+		if cl, err := changes.Next(); err != nil || cl.Sequence != want {
+			t.Errorf("changes.Next()=%v,%v, want {Sequence: %d},nil", cl, err, want)
+		}
+	}
+
+	if changes, err := ChangesSince(changesURL, 6); err == nil {
+		changes.Close()
+		t.Errorf("ChangesSince(%q, 6)=_,nil, want error", changesURL)
+	}
+	badURL := *changesURL
+	badURL.RawQuery = "since=x"
+	if changes, err := Changes(&badURL); err == nil {
+		changes.Close()
+		t.Errorf("Changes(%q)=_,nil, want error", &badURL)
+	}
+}
+
+func TestChangeStream_Gone(t *testing.T) {
+	defer func(n int) { changeHistory = n }(changeHistory)
+	changeHistory = 2
+
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{
+		edit.Append(edit.End, "a"), // 1
+		edit.Append(edit.End, "b"), // 2
+		edit.Append(edit.End, "c"), // 3
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	// ChangeList 1 was dropped from the history.
+	changes, err := ChangesSince(changesURL, 0)
+	if err == nil {
+		changes.Close()
+	}
+	if err != ErrGone {
+		t.Errorf("ChangesSince(%q, 0)=_,%v, want _,%v", changesURL, err, ErrGone)
+	}
+	changes, err = ChangesSince(changesURL, 1)
+	if err != nil {
+		t.Fatalf("ChangesSince(%q, 1)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+	for _, want := range []int{2, 3} {
+		if cl, err := changes.Next(); err != nil || cl.Sequence != want {
+			t.Errorf("changes.Next()=%v,%v, want {Sequence: %d},nil", cl, err, want)
+		}
+	}
+}
+
+func TestChangeStream_Reconnect(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+	proxy := newTCPProxy(t, s.URL.Host)
+	defer proxy.close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changesURL.Host = proxy.addr()
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{edit.Append(edit.End, "a")} // 1
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+	if cl, err := changes.Next(); err != nil || cl.Sequence != 1 {
+		t.Fatalf("changes.Next()=%v,%v, want {Sequence: 1},nil", cl, err)
+	}
+
+	proxy.drop()
+	eds = []edit.Edit{
+		edit.Append(edit.End, "b"), // 2
+		edit.Append(edit.End, "c"), // 3
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+	for _, want := range []int{2, 3} {
+		if cl, err := changes.Next(); err != nil || cl.Sequence != want {
+			t.Errorf("changes.Next()=%v,%v, want {Sequence: %d},nil", cl, err, want)
+		}
+	}
+}
+
+func TestOfflineClient(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+	proxy := newTCPProxy(t, s.URL.Host)
+	defer proxy.close()
+
+	proxyURL := *s.URL
+	proxyURL.Host = proxy.addr()
+	c := NewOfflineClient(&proxyURL)
+	buf, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf, err)
+	}
+	ed, err := c.NewEditor(buf.Path)
+	if err != nil {
+		t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", buf.Path, ed, err)
+	}
+	eds := []edit.Edit{edit.Append(edit.End, "a")}
+	if res, err := c.Do(ed.Path, eds...); err != nil {
+		t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,nil", ed.Path, eds, res, err)
+	}
+
+	proxy.close()
+	for _, e := range []edit.Edit{edit.Append(edit.End, "b"), edit.Append(edit.End, "c")} {
+		if res, err := c.Do(ed.Path, e); err != ErrQueued {
+			t.Fatalf("c.Do(%q, %v)=%v,%v, want _,%v", ed.Path, e, res, err, ErrQueued)
+		}
+	}
+	if n := c.Queued(); n != 2 {
+		t.Fatalf("c.Queued()=%d, want 2", n)
+	}
+	if err := c.Flush(); err == nil {
+		t.Fatalf("c.Flush()=nil, want an error")
+	}
+	if got := bufferText(t, editorServer, buf.ID); got != "a" {
+		t.Errorf("offline text=%q, want %q", got, "a")
+	}
+
+	// Another client reads the buffer while c is offline.
+	other := &HTTPClient{URL: s.URL}
+	eds = []edit.Edit{edit.Print(edit.All)}
+	if res, err := other.Do(ed.Path, eds...); err != nil {
+		t.Fatalf("other.Do(%q, %v...)=%v,%v, want _,nil", ed.Path, eds, res, err)
+	}
+
+	// The server is reachable again.
+	c.URL = s.URL
+	eds = []edit.Edit{edit.Append(edit.End, "d")}
+	want := []EditResult{{Sequence: 5}}
+	if res, err := c.Do(ed.Path, eds...); err != nil || !reflect.DeepEqual(res, want) {
+		t.Errorf("c.Do(%q, %v...)=%v,%v, want %v,nil", ed.Path, eds, res, err, want)
+	}
+	if n := c.Queued(); n != 0 {
+		t.Errorf("c.Queued()=%d, want 0", n)
+	}
+	if got := bufferText(t, editorServer, buf.ID); got != "abcd" {
+		t.Errorf("text=%q, want %q", got, "abcd")
+	}
+}
+
+func TestOfflineClient_Conflict(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+	proxy := newTCPProxy(t, s.URL.Host)
+	defer proxy.close()
+
+	proxyURL := *s.URL
+	proxyURL.Host = proxy.addr()
+	c := NewOfflineClient(&proxyURL)
+	buf0, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf0, err)
+	}
+	ed0, err := c.NewEditor(buf0.Path)
+	if err != nil {
+		t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", buf0.Path, ed0, err)
+	}
+	buf1, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf1, err)
+	}
+	ed1, err := c.NewEditor(buf1.Path)
+	if err != nil {
+		t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", buf1.Path, ed1, err)
+	}
+	for _, ed := range []Editor{ed0, ed1} {
+		eds := []edit.Edit{edit.Append(edit.End, "a")}
+		if res, err := c.Do(ed.Path, eds...); err != nil {
+			t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,nil", ed.Path, eds, res, err)
+		}
+	}
+
+	proxy.close()
+	queued := []EditorEdits{
+		{EditorPath: ed0.Path, Edits: []edit.Edit{edit.Append(edit.End, "b")}},
+		{EditorPath: ed1.Path, Edits: []edit.Edit{edit.Append(edit.End, "b")}},
+		{EditorPath: ed0.Path, Edits: []edit.Edit{edit.Append(edit.End, "c")}},
+	}
+	for _, q := range queued {
+		if res, err := c.Do(q.EditorPath, q.Edits...); err != ErrQueued {
+			t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,%v", q.EditorPath, q.Edits, res, err, ErrQueued)
+		}
+	}
+
+	// Another client changes buffer 0 while c is offline.
+	other := &HTTPClient{URL: s.URL}
+	eds := []edit.Edit{edit.Append(edit.End, "x")}
+	if res, err := other.Do(ed0.Path, eds...); err != nil {
+		t.Fatalf("other.Do(%q, %v...)=%v,%v, want _,nil", ed0.Path, eds, res, err)
+	}
+
+	c.URL = s.URL
+	err = c.Flush()
+	conflict, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("c.Flush()=%v, want a *ConflictError", err)
+	}
+	want := []EditorEdits{queued[0], queued[2]}
+	if !reflect.DeepEqual(conflict.Dropped, want) {
+		t.Errorf("conflict.Dropped=%v, want %v", conflict.Dropped, want)
+	}
+	if n := c.Queued(); n != 0 {
+		t.Errorf("c.Queued()=%d, want 0", n)
+	}
+	if got := bufferText(t, editorServer, buf0.ID); got != "ax" {
+		t.Errorf("buffer 0 text=%q, want %q", got, "ax")
+	}
+	if got := bufferText(t, editorServer, buf1.ID); got != "ab" {
+		t.Errorf("buffer 1 text=%q, want %q", got, "ab")
+	}
+}
+
+func TestOfflineClient_Error(t *testing.T) {
+	editorServer := NewServer()
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+	proxy := newTCPProxy(t, s.URL.Host)
+	defer proxy.close()
+
+	proxyURL := *s.URL
+	proxyURL.Host = proxy.addr()
+	c := NewOfflineClient(&proxyURL)
+	buf, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf, err)
+	}
+	ed, err := c.NewEditor(buf.Path)
+	if err != nil {
+		t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", buf.Path, ed, err)
+	}
+	eds := []edit.Edit{edit.Append(edit.End, "a")}
+	if res, err := c.Do(ed.Path, eds...); err != nil {
+		t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,nil", ed.Path, eds, res, err)
+	}
+
+	proxy.close()
+	queued := []EditorEdits{
+		{EditorPath: ed.Path, Edits: []edit.Edit{edit.Append(edit.End, "b")}},
+		{EditorPath: ed.Path, Edits: []edit.Edit{edit.Append(edit.End, "c")}},
+	}
+	for _, q := range queued {
+		if res, err := c.Do(q.EditorPath, q.Edits...); err != ErrQueued {
+			t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,%v", q.EditorPath, q.Edits, res, err, ErrQueued)
+		}
+	}
+
+	// Another client closes the editor while c is offline.
+	other := &HTTPClient{URL: s.URL}
+	if err := other.Close(ed.Path); err != nil {
+		t.Fatalf("other.Close(%q)=%v, want nil", ed.Path, err)
+	}
+
+	c.URL = s.URL
+	err = c.Flush()
+	conflict, ok := err.(*ConflictError)
+	if !ok || conflict.Err != ErrNotFound {
+		t.Fatalf("c.Flush()=%v, want a *ConflictError with Err=%v", err, ErrNotFound)
+	}
+	if !reflect.DeepEqual(conflict.Dropped, queued) {
+		t.Errorf("conflict.Dropped=%v, want %v", conflict.Dropped, queued)
+	}
+	if n := c.Queued(); n != 0 {
+		t.Errorf("c.Queued()=%d, want 0", n)
+	}
+}
+
+func TestChangeStream_ReconnectKeepsQuery(t *testing.T) {
+	qs := &queryServer{Server: NewServer()}
+	s := editortest.NewServer(qs)
+	defer s.Close()
+	proxy := newTCPProxy(t, s.URL.Host)
+	defer proxy.close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changesURL.Host = proxy.addr()
+	changesURL.RawQuery = "x=y"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+
+	textURL := s.PathURL(ed.Path, "text")
+	for i, r := range []string{"a", "b"} {
+		if i > 0 {
+			proxy.drop()
+		}
+		eds := []edit.Edit{edit.Append(edit.End, r)}
+		if res, err := Do(textURL, eds...); err != nil {
+			t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+		}
+		if cl, err := changes.Next(); err != nil || cl.Sequence != i+1 {
+			t.Fatalf("changes.Next()=%v,%v, want {Sequence: %d},nil", cl, err, i+1)
+		}
+	}
+
+	want := []string{"x=y", "since=1&x=y"}
+	if got := qs.changesQueries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes queries=%v, want %v", got, want)
+	}
+}
+
+// A queryServer is a Server that records
+// the query strings of requests for changes.
+type queryServer struct {
+	*Server
+	mu      sync.Mutex
+	queries []string
+}
+
+func (qs *queryServer) RegisterHandlers(r *mux.Router) {
+	qs.Server.RegisterHandlers(r)
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.HasSuffix(req.URL.Path, "/changes") {
+				qs.mu.Lock()
+				qs.queries = append(qs.queries, req.URL.RawQuery)
+				qs.mu.Unlock()
+			}
+			h.ServeHTTP(w, req)
+		})
+	})
+}
+
+func (qs *queryServer) changesQueries() []string {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	return append([]string{}, qs.queries...)
+}
+
+// A tcpProxy forwards TCP connections to an address.
+// It can drop its connections, simulating a lost network connection.
+type tcpProxy struct {
+	listener net.Listener
+	to       string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTCPProxy(t *testing.T, to string) *tcpProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(\"tcp\", \"127.0.0.1:0\")=_,%v", err)
+	}
+	p := &tcpProxy{listener: l, to: to}
+	go p.serve()
+	return p
+}
+
+func (p *tcpProxy) addr() string { return p.listener.Addr().String() }
+
+func (p *tcpProxy) serve() {
+	for {
+		front, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		back, err := net.Dial("tcp", p.to)
+		if err != nil {
+			front.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, front, back)
+		p.mu.Unlock()
+		go io.Copy(front, back)
+		go io.Copy(back, front)
+	}
+}
+
+// Drop closes all connections.
+func (p *tcpProxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+func (p *tcpProxy) close() {
+	p.listener.Close()
+	p.drop()
+}
+
+func TestChangeStream_Batch(t *testing.T) {
+	editorServer := NewServer()
+	editorServer.ChangeBatchWindow = time.Hour
+	editorServer.ChangeBatchSize = 3
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	conn, err := websocket.Dial(changesURL)
+	if err != nil {
+		t.Fatalf("websocket.Dial(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer conn.Close()
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{
+		edit.Append(edit.End, "a"), // 1
+		edit.Print(edit.All),       // 2
+		edit.Append(edit.End, "b"), // 3
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+	// The batch is held until it reaches 3 changes.
+	eds = []edit.Edit{
+		edit.SubGlobal(edit.All, ".", "x"), // 4
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+
+	var batch []ChangeList
+	if err := conn.Recv(&batch); err != nil {
+		t.Fatalf("conn.Recv(&batch)=%v, want nil", err)
+	}
+	var got []int
+	for _, cl := range batch {
+		got = append(got, cl.Sequence)
+	}
+	if want := []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("batch sequences=%v, want %v", got, want)
+	}
+}
+
+func TestChangeStream_BatchWindow(t *testing.T) {
+	editorServer := NewServer()
+	editorServer.ChangeBatchWindow = 10 * time.Millisecond
+	s := editortest.NewServer(editorServer)
+	defer s.Close()
+
+	buffersURL := s.PathURL("/", "buffers")
+	buf, err := NewBuffer(buffersURL)
+	if err != nil {
+		t.Fatalf("NewBuffer(%q)=%v,%v, want _,nil", buffersURL, buf, err)
+	}
+	bufferURL := s.PathURL(buf.Path)
+	ed, err := NewEditor(bufferURL)
+	if err != nil {
+		t.Fatalf("NewEditor(%q)=%v,%v, want _,nil", bufferURL, buf, err)
+	}
+
+	changesURL := s.PathURL(buf.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := Changes(changesURL)
+	if err != nil {
+		t.Fatalf("Changes(%q)=_,%v, want _,nil", changesURL, err)
+	}
+	defer changes.Close()
+
+	textURL := s.PathURL(ed.Path, "text")
+	eds := []edit.Edit{
+		edit.Append(edit.End, "a"), // 1
+		edit.Append(edit.End, "b"), // 2
+	}
+	if res, err := Do(textURL, eds...); err != nil {
+		t.Fatalf("ed.Do(%q, %v...)=%v,%v want _,nil", textURL, eds, res, err)
+	}
+	for _, want := range []int{1, 2} {
+		if cl, err := changes.Next(); err != nil || cl.Sequence != want {
+			t.Errorf("changes.Next()=%v,%v, want {Sequence: %d},nil", cl, err, want)
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	s := editortest.NewServer(NewServer())
+	defer s.Close()
+	testClient(t, &HTTPClient{URL: s.URL})
+}
+
+func TestLocalClient(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	testClient(t, NewLocalClient(s))
+}
+
+func TestRPCClient(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	clientConn, serverConn := net.Pipe()
+	go s.ServeRPCConn(serverConn)
+	c := NewRPCClient(clientConn)
+	defer c.Disconnect()
+	testClient(t, c)
+}
+
+func TestRPCClientDisconnect(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen(\"tcp\", \"localhost:0\")=_,%v", err)
+	}
+	defer l.Close()
+	go s.ServeRPC(l)
+
+	c, err := DialRPC("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("DialRPC(\"tcp\", %q)=_,%v", l.Addr(), err)
+	}
+	buf, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf, err)
+	}
+	changes, err := c.Changes(buf.Path)
+	if err != nil {
+		t.Fatalf("c.Changes(%q)=_,%v, want _,nil", buf.Path, err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := changes.Next()
+		done <- err
+	}()
+	if err := c.Disconnect(); err != nil {
+		t.Fatalf("c.Disconnect()=%v, want nil", err)
+	}
+	if err := <-done; err == nil {
+		t.Errorf("changes.Next()=_,nil, want error")
+	}
+
+	// The server must have closed the watch of the lost connection.
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		b := s.buffers[buf.ID]
+		s.Unlock()
+		b.Lock()
+		n := len(b.watchers)
+		b.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("the watch was not closed after disconnect")
+}
+
+func testClient(t *testing.T, c Client) {
+	buf, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf, err)
+	}
+	if bufs, err := c.BufferList(); err != nil || len(bufs) != 1 || bufs[0].ID != buf.ID {
+		t.Errorf("c.BufferList()=%v,%v, want [%v],nil", bufs, err, buf)
+	}
+	ed, err := c.NewEditor(buf.Path)
+	if err != nil {
+		t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", buf.Path, ed, err)
+	}
+	if got, err := c.EditorInfo(ed.Path); err != nil || got != ed {
+		t.Errorf("c.EditorInfo(%q)=%v,%v, want %v,nil", ed.Path, got, err, ed)
+	}
+
+	changes, err := c.Changes(buf.Path)
+	if err != nil {
+		t.Fatalf("c.Changes(%q)=_,%v, want _,nil", buf.Path, err)
+	}
+	defer changes.Close()
+
+	edits := []edit.Edit{edit.Change(edit.All, "Hello, World"), edit.Print(edit.All)}
+	want := []EditResult{{Sequence: 1}, {Sequence: 2, Print: "Hello, World"}}
+	if got, err := c.Do(ed.Path, edits...); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("c.Do(%q, %v...)=%v,%v, want %v,nil", ed.Path, edits, got, err, want)
+	}
+	wantCL := ChangeList{
+		Sequence:   1,
+		Changes:    []Change{{Span: edit.Span{0, 0}, NewSize: 12}},
+		EditorPath: ed.Path,
+	}
+	if cl, err := changes.Next(); err != nil || !reflect.DeepEqual(cl, wantCL) {
+		t.Errorf("changes.Next()=%v,%v, want %v,nil", cl, err, wantCL)
+	}
+	since, err := c.ChangesSince(buf.Path, 0)
+	if err != nil {
+		t.Fatalf("c.ChangesSince(%q, 0)=_,%v, want _,nil", buf.Path, err)
+	}
+	if cl, err := since.Next(); err != nil || !reflect.DeepEqual(cl, wantCL) {
+		t.Errorf("since.Next()=%v,%v, want %v,nil", cl, err, wantCL)
+	}
+	since.Close()
+	if h, err := c.History(buf.Path); err != nil || len(h) != 1 || !reflect.DeepEqual(h[0].ChangeList, wantCL) || h[0].EditorPath != ed.Path {
+		t.Errorf("c.History(%q)=%v,%v, want [{%v %q}],nil", buf.Path, h, err, wantCL, ed.Path)
+	}
+
+	r, err := c.Reader(ed.Path, edit.Regexp("World"))
+	if err != nil {
+		t.Fatalf("c.Reader(%q, /World/)=_,%v, want _,nil", ed.Path, err)
+	}
+	text, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(text) != "World" {
+		t.Errorf("c.Reader(%q, /World/) read %q,%v, want \"World\",nil", ed.Path, text, err)
+	}
+	if _, err := c.Reader(ed.Path, edit.Rune(100)); err != ErrRange {
+		t.Errorf("c.Reader(%q, #100)=_,%v, want %v", ed.Path, err, ErrRange)
+	}
+
+	if spans, err := c.Search(buf.Path, "o", 0, 0); err != nil || !reflect.DeepEqual(spans, []edit.Span{{4, 5}, {8, 9}}) {
+		t.Errorf("c.Search(%q, \"o\", 0, 0)=%v,%v, want [[4 5] [8 9]],nil", buf.Path, spans, err)
+	}
+
+	name := "hello.txt"
+	if got, err := c.UpdateBuffer(buf.Path, BufferUpdate{Name: &name}); err != nil || got.Name != name || got.Size != 12 {
+		t.Errorf("c.UpdateBuffer(%q, _)=%v,%v, want name %q, size 12", buf.Path, got, err, name)
+	}
+
+	txn := []EditorEdits{{EditorPath: ed.Path, Edits: []edit.Edit{edit.Delete(edit.All)}}}
+	if got, err := c.Transaction(txn...); err != nil || !got.Committed {
+		t.Errorf("c.Transaction(%v...)=%v,%v, want committed", txn, got, err)
+	}
+	if got, err := c.BufferInfo(buf.Path); err != nil || got.Size != 0 {
+		t.Errorf("c.BufferInfo(%q)=%v,%v, want size 0", buf.Path, got, err)
+	}
+	wantCL = ChangeList{
+		Sequence:   3,
+		Changes:    []Change{{Span: edit.Span{0, 12}, NewSize: 0}},
+		EditorPath: ed.Path,
+	}
+	if cl, err := changes.Next(); err != nil || !reflect.DeepEqual(cl, wantCL) {
+		t.Errorf("changes.Next()=%v,%v, want %v,nil", cl, err, wantCL)
+	}
+
+	if err := c.Close(ed.Path); err != nil {
+		t.Errorf("c.Close(%q)=%v, want nil", ed.Path, err)
+	}
+	if _, err := c.EditorInfo(ed.Path); err != ErrNotFound {
+		t.Errorf("c.EditorInfo(%q)=_,%v, want %v", ed.Path, err, ErrNotFound)
+	}
+	if err := c.Close(buf.Path); err != nil {
+		t.Errorf("c.Close(%q)=%v, want nil", buf.Path, err)
+	}
+	if _, err := c.BufferInfo(buf.Path); err != ErrNotFound {
+		t.Errorf("c.BufferInfo(%q)=_,%v, want %v", buf.Path, err, ErrNotFound)
+	}
+	if _, err := changes.Next(); err == nil {
+		t.Errorf("changes.Next()=_,nil, want error")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	journal := bytes.NewBuffer(nil)
+	s := NewServer()
+	s.Journal = journal
+	c := NewLocalClient(s)
+
+	buf0, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf0, err)
+	}
+	buf1, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", buf1, err)
+	}
+	closed, err := c.NewBuffer()
+	if err != nil {
+		t.Fatalf("c.NewBuffer()=%v,%v, want _,nil", closed, err)
+	}
+	name := "journaled"
+	if _, err := c.UpdateBuffer(buf0.Path, BufferUpdate{Name: &name}); err != nil {
+		t.Fatalf("c.UpdateBuffer(%q, _)=_,%v, want _,nil", buf0.Path, err)
+	}
+	doAll := func(b Buffer, edits ...edit.Edit) {
+		ed, err := c.NewEditor(b.Path)
+		if err != nil {
+			t.Fatalf("c.NewEditor(%q)=%v,%v, want _,nil", b.Path, ed, err)
+		}
+		results, err := c.Do(ed.Path, edits...)
+		if err != nil {
+			t.Fatalf("c.Do(%q, %v...)=%v,%v, want _,nil", ed.Path, edits, results, err)
+		}
+		for _, r := range results {
+			if r.Error != "" {
+				t.Fatalf("c.Do(%q, %v...)=%v,nil, want no errors", ed.Path, edits, results)
+			}
+		}
+	}
+	doAll(buf0,
+		edit.Change(edit.All, "Hello, World"),
+		edit.Change(edit.Regexp("World"), strings.Repeat("☺", 2*MaxInline)),
+		edit.Undo(1),
+		edit.Undo(1),
+		edit.Redo(1),
+		edit.SubGlobal(edit.All, "o", "0"))
+	doAll(buf1, edit.Append(edit.All, "abc"), edit.Append(edit.Line(0), "xyz"))
+	doAll(closed, edit.Append(edit.All, "closed"))
+	if err := c.Close(closed.Path); err != nil {
+		t.Fatalf("c.Close(%q)=%v, want nil", closed.Path, err)
+	}
+	want, err := c.BufferList()
+	if err != nil {
+		t.Fatalf("c.BufferList()=_,%v, want _,nil", err)
+	}
+	sort.Sort(bufferSlice(want))
+	wantText := make(map[string]string)
+	for _, b := range want {
+		wantText[b.ID] = bufferText(t, s, b.ID)
+	}
+	if wantText[buf0.ID] != "Hell0, W0rld" {
+		t.Errorf("buffer %s text=%q, want \"Hell0, W0rld\"", buf0.ID, wantText[buf0.ID])
+	}
+
+	// Truncate the final record to simulate a crash while writing it.
+	data := journal.Bytes()
+	recovered := NewServer()
+	if err := recovered.Recover(bytes.NewReader(data[:len(data)-2])); err != nil {
+		t.Fatalf("Recover(…)=%v, want nil", err)
+	}
+	if _, ok := recovered.buffers[closed.ID]; !ok {
+		t.Errorf("buffer %s is not recovered, want recovered", closed.ID)
+	}
+
+	recovered = NewServer()
+	if err := recovered.Recover(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Recover(…)=%v, want nil", err)
+	}
+	got, err := NewLocalClient(recovered).BufferList()
+	if err != nil {
+		t.Fatalf("BufferList()=_,%v, want _,nil", err)
+	}
+	sort.Sort(bufferSlice(got))
+	for i := range want {
+		// Editors are not recovered.
+		want[i].Editors = []Editor{}
+		want[i].Sequence = 0
+	}
+	for i := range got {
+		if got[i].Editors == nil {
+			got[i].Editors = []Editor{}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("recovered BufferList()=%v, want %v", got, want)
+	}
+	for _, b := range got {
+		if text := bufferText(t, recovered, b.ID); text != wantText[b.ID] {
+			t.Errorf("recovered buffer %s text=%q, want %q", b.ID, text, wantText[b.ID])
+		}
+	}
+
+	// New buffers do not reuse recovered IDs.
+	if b, err := NewLocalClient(recovered).NewBuffer(); err != nil || b.ID == buf0.ID || b.ID == buf1.ID || b.ID == closed.ID {
+		t.Errorf("NewBuffer()=%v,%v, want a new ID", b, err)
+	}
+}
+
+func bufferText(t *testing.T, s *Server, id string) string {
+	buf, ok := s.buffers[id]
+	if !ok {
+		t.Fatalf("buffer %s not found", id)
+	}
+	text, err := ioutil.ReadAll(buf.text.Reader(edit.Span{0, buf.text.Size()}))
+	if err != nil {
+		t.Fatalf("failed to read buffer %s: %v", id, err)
 	}
+	return string(text)
 }
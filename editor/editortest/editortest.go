@@ -1,13 +1,23 @@
 // Copyright © 2016, The T Authors.
 
 // Package editortest provides an editor server for use in tests.
+//
+// The Server can inject faults into the requests that it serves:
+// latency, error responses, and dropped websocket messages.
+// This allows testing how clients handle a slow or flaky editor server.
 package editortest
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/eaburns/T/websocket"
 	"github.com/gorilla/mux"
 )
 
@@ -24,22 +34,38 @@ type Server struct {
 
 	editorServer EditorServer
 	httpServer   *httptest.Server
+	// backend serves the editor server directly.
+	// It is used as the destination of proxied websockets.
+	backend *httptest.Server
+	router  *mux.Router
+
+	mu      sync.Mutex
+	latency time.Duration
+	errors  []injectedError
+	drop    func(path string, n int) bool
+}
+
+type injectedError struct {
+	method, pattern string
+	status          int
 }
 
 // NewServer returns a new, running Server.
 func NewServer(editorServer EditorServer) *Server {
 	router := mux.NewRouter()
 	editorServer.RegisterHandlers(router)
-	httpServer := httptest.NewServer(router)
-	url, err := url.Parse(httpServer.URL)
+	s := &Server{
+		editorServer: editorServer,
+		router:       router,
+		backend:      httptest.NewServer(router),
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	url, err := url.Parse(s.httpServer.URL)
 	if err != nil {
 		panic(err)
 	}
-	return &Server{
-		URL:          url,
-		editorServer: editorServer,
-		httpServer:   httpServer,
-	}
+	s.URL = url
+	return s
 }
 
 // PathURL returns the URL for the given path on this server.
@@ -52,5 +78,142 @@ func (s *Server) PathURL(elems ...string) *url.URL {
 // Close closes the Server.
 func (s *Server) Close() {
 	s.httpServer.Close()
+	s.backend.Close()
 	s.editorServer.Close()
 }
+
+// SetLatency sets the amount of time to delay each request
+// before it is handled.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// SetError sets requests with the given method
+// and a path matching the given pattern
+// to fail with the given HTTP status code.
+// The pattern syntax is that of path.Match.
+// For example, SetError("POST", "/editor/*/text", 500)
+// makes all edits fail with an Internal Server Error.
+//
+// If the status is 0, a previously set error is removed.
+func (s *Server) SetError(method, pattern string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.errors {
+		if e.method == method && e.pattern == pattern {
+			s.errors = append(s.errors[:i], s.errors[i+1:]...)
+			break
+		}
+	}
+	if status != 0 {
+		s.errors = append(s.errors, injectedError{method: method, pattern: pattern, status: status})
+	}
+}
+
+// SetDrop sets a function that determines
+// which websocket messages sent by the editor server are dropped.
+// The function is called with the path of the websocket
+// and the 0-based index of each message sent on the connection.
+// If it returns true, the message is not delivered to the client.
+// If the function is nil, no messages are dropped.
+//
+// SetDrop only affects websockets connected after it is called.
+func (s *Server) SetDrop(drop func(path string, n int) bool) {
+	s.mu.Lock()
+	s.drop = drop
+	s.mu.Unlock()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	latency, drop := s.latency, s.drop
+	status := 0
+	for _, e := range s.errors {
+		if ok, _ := path.Match(e.pattern, req.URL.Path); ok && e.method == req.Method {
+			status = e.status
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	switch {
+	case status != 0:
+		http.Error(w, http.StatusText(status), status)
+	case drop != nil && strings.EqualFold(req.Header.Get("Upgrade"), "websocket"):
+		s.proxyWebsocket(w, req, drop)
+	default:
+		s.router.ServeHTTP(w, req)
+	}
+}
+
+// ProxyWebsocket forwards messages from the backend websocket to the client,
+// dropping those for which drop returns true.
+// Messages from the client are discarded.
+func (s *Server) proxyWebsocket(w http.ResponseWriter, req *http.Request, drop func(string, int) bool) {
+	backURL := *req.URL
+	backURL.Scheme = "ws"
+	backURL.Host = s.backend.Listener.Addr().String()
+	back, err := websocket.Dial(&backURL)
+	if err != nil {
+		if hsErr, ok := err.(websocket.HandshakeError); ok {
+			http.Error(w, hsErr.Status, hsErr.StatusCode)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer back.Close()
+	front, err := websocket.Upgrade(w, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer front.Close()
+
+	frontDone := make(chan struct{})
+	go func() {
+		defer close(frontDone)
+		for front.Recv(nil) == nil {
+		}
+	}()
+
+	quit := make(chan struct{})
+	defer close(quit)
+	msgs := make(chan json.RawMessage)
+	go func() {
+		defer close(msgs)
+		for {
+			var msg json.RawMessage
+			if err := back.Recv(&msg); err != nil {
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for n := 0; ; n++ {
+		select {
+		case <-frontDone:
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			if drop(req.URL.Path, n) {
+				continue
+			}
+			if err := front.Send(msg); err != nil {
+				return
+			}
+		}
+	}
+}
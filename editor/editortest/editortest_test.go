@@ -0,0 +1,104 @@
+// Copyright © 2016, The T Authors.
+
+package editortest
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/websocket"
+	"github.com/gorilla/mux"
+)
+
+// A fakeEditorServer serves /ok and a websocket at /ws
+// that sends the integers 0 through 4, and then closes.
+type fakeEditorServer struct{}
+
+func (fakeEditorServer) RegisterHandlers(r *mux.Router) {
+	r.HandleFunc("/ok", func(http.ResponseWriter, *http.Request) {}).Methods(http.MethodGet)
+	r.HandleFunc("/ws", func(w http.ResponseWriter, req *http.Request) {
+		conn, err := websocket.Upgrade(w, req)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			for conn.Recv(nil) == nil {
+			}
+		}()
+		for i := 0; i < 5; i++ {
+			if err := conn.Send(i); err != nil {
+				return
+			}
+		}
+	}).Methods(http.MethodGet)
+}
+
+func (fakeEditorServer) Close() error { return nil }
+
+func get(t *testing.T, s *Server, p string) int {
+	resp, err := http.Get(s.PathURL(p).String())
+	if err != nil {
+		t.Fatalf("http.Get(%q) failed: %v", p, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSetError(t *testing.T) {
+	s := NewServer(fakeEditorServer{})
+	defer s.Close()
+
+	if code := get(t, s, "/ok"); code != http.StatusOK {
+		t.Errorf("GET /ok status=%d, want %d", code, http.StatusOK)
+	}
+	s.SetError(http.MethodGet, "/o*", http.StatusServiceUnavailable)
+	if code := get(t, s, "/ok"); code != http.StatusServiceUnavailable {
+		t.Errorf("GET /ok status=%d, want %d", code, http.StatusServiceUnavailable)
+	}
+	s.SetError(http.MethodPut, "/ok", http.StatusInternalServerError)
+	s.SetError(http.MethodGet, "/o*", 0)
+	if code := get(t, s, "/ok"); code != http.StatusOK {
+		t.Errorf("GET /ok status=%d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestSetLatency(t *testing.T) {
+	s := NewServer(fakeEditorServer{})
+	defer s.Close()
+
+	const latency = 50 * time.Millisecond
+	s.SetLatency(latency)
+	start := time.Now()
+	get(t, s, "/ok")
+	if d := time.Since(start); d < latency {
+		t.Errorf("GET /ok took %v, want >= %v", d, latency)
+	}
+}
+
+func TestSetDrop(t *testing.T) {
+	s := NewServer(fakeEditorServer{})
+	defer s.Close()
+
+	s.SetDrop(func(path string, n int) bool { return path == "/ws" && n%2 == 1 })
+	wsURL := s.PathURL("/ws")
+	wsURL.Scheme = "ws"
+	conn, err := websocket.Dial(wsURL)
+	if err != nil {
+		t.Fatalf("websocket.Dial(%q) failed: %v", wsURL, err)
+	}
+	defer conn.Close()
+	var got []int
+	for {
+		var i int
+		if err := conn.Recv(&i); err != nil {
+			break
+		}
+		got = append(got, i)
+	}
+	if want := []int{0, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2016, The T Authors.
+
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/eaburns/T/edit"
+)
+
+// A journalRecord is a single entry in the journal.
+type journalRecord struct {
+	// Op is one of "new", "update", "change", "undo", "redo", or "close".
+	Op string `json:"op"`
+
+	// Buffer is the ID of the buffer.
+	Buffer string `json:"buffer"`
+
+	// Update is the update for an "update" record.
+	Update *BufferUpdate `json:"update,omitempty"`
+
+	// Changes are the changes for a "change" record,
+	// in the order that they were staged.
+	Changes []journalChange `json:"changes,omitempty"`
+}
+
+// A journalChange is a single staged change.
+type journalChange struct {
+	Span edit.Span `json:"span"`
+	Text []byte    `json:"text"`
+}
+
+// WriteJournal writes a record to the Journal.
+func (s *Server) writeJournal(rec journalRecord) error {
+	if s.Journal == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if _, err := s.Journal.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if syncer, ok := s.Journal.(interface {
+		Sync() error
+	}); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// Recover restores the buffers recorded in a journal.
+//
+// Recover must be called before the Server is used,
+// and Journal must not be set until Recover returns.
+// Buffers are recovered with their original IDs,
+// but editors are not recovered.
+// An incomplete record at the end of the journal,
+// such as one interrupted by a crash, is ignored.
+func (s *Server) Recover(r io.Reader) error {
+	s.Lock()
+	defer s.Unlock()
+	dec := json.NewDecoder(r)
+	for {
+		var rec journalRecord
+		switch err := dec.Decode(&rec); {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if err := s.replay(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Replay performs the operation of a journal record.
+// Must be called with the server's write Lock held.
+func (s *Server) replay(rec journalRecord) error {
+	if rec.Op == "new" {
+		id, err := strconv.Atoi(rec.Buffer)
+		if err != nil {
+			return errors.New("journal: bad buffer ID: " + rec.Buffer)
+		}
+		if id >= s.nextID {
+			s.nextID = id + 1
+		}
+		buf := makeBuffer(rec.Buffer, edit.NewBuffer())
+		buf.journal = s.writeJournal
+		s.buffers[buf.ID] = buf
+		return nil
+	}
+	buf, ok := s.buffers[rec.Buffer]
+	if !ok {
+		return errors.New("journal: unknown buffer: " + rec.Buffer)
+	}
+	switch rec.Op {
+	case "update":
+		if rec.Update == nil {
+			return errors.New("journal: update record with no update")
+		}
+		buf.update(*rec.Update)
+	case "change":
+		for _, c := range rec.Changes {
+			if _, err := buf.text.Change(c.Span, bytes.NewReader(c.Text)); err != nil {
+				return err
+			}
+		}
+		if err := buf.text.Apply(); err != nil {
+			return err
+		}
+		buf.Modified = true
+	case "undo":
+		return buf.text.Undo()
+	case "redo":
+		return buf.text.Redo()
+	case "close":
+		delete(s.buffers, buf.ID)
+		return buf.close()
+	default:
+		return errors.New("journal: unknown op: " + rec.Op)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright © 2016, The T Authors.
+
+package editor
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/eaburns/T/edit"
+)
+
+// A LocalClient is a Client that calls a Server directly, in-process.
+// Requests and responses are not encoded,
+// and no network connections are made.
+//
+// A LocalClient has WriteAccess to all buffers;
+// the Server's Authorize function is not called.
+type LocalClient struct {
+	server *Server
+}
+
+// NewLocalClient returns a new LocalClient for the Server.
+func NewLocalClient(s *Server) *LocalClient { return &LocalClient{server: s} }
+
+// BufferList implements Client.BufferList.
+func (c *LocalClient) BufferList() ([]Buffer, error) { return c.server.bufferList(nil), nil }
+
+// NewBuffer implements Client.NewBuffer.
+func (c *LocalClient) NewBuffer() (Buffer, error) {
+	buf, err := c.server.createBuffer(nil)
+	return buf, localError(err)
+}
+
+// BufferInfo implements Client.BufferInfo.
+func (c *LocalClient) BufferInfo(bufferPath string) (Buffer, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return Buffer{}, ErrNotFound
+	}
+	buf, err := c.server.getBuffer(nil, id)
+	return buf, localError(err)
+}
+
+// UpdateBuffer implements Client.UpdateBuffer.
+func (c *LocalClient) UpdateBuffer(bufferPath string, update BufferUpdate) (Buffer, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return Buffer{}, ErrNotFound
+	}
+	buf, err := c.server.setBuffer(nil, id, update)
+	return buf, localError(err)
+}
+
+// Changes implements Client.Changes.
+func (c *LocalClient) Changes(bufferPath string) (*ChangeStream, error) {
+	return c.ChangesSince(bufferPath, -1)
+}
+
+// ChangesSince implements Client.ChangesSince.
+func (c *LocalClient) ChangesSince(bufferPath string, seq int) (*ChangeStream, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	buf, changes, err := c.server.watch(nil, id, seq, false)
+	if err != nil {
+		return nil, localError(err)
+	}
+	return &ChangeStream{buf: buf, changes: changes, closed: make(chan struct{})}, nil
+}
+
+// History implements Client.History.
+func (c *LocalClient) History(bufferPath string) ([]HistoryEntry, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	history, err := c.server.getHistory(nil, id)
+	return history, localError(err)
+}
+
+// Search implements Client.Search.
+func (c *LocalClient) Search(bufferPath, re string, from int64, max int) ([]edit.Span, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	spans, err := c.server.searchBuffer(nil, id, re, from, max)
+	return spans, localError(err)
+}
+
+// NewEditor implements Client.NewEditor.
+func (c *LocalClient) NewEditor(bufferPath string) (Editor, error) {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return Editor{}, ErrNotFound
+	}
+	ed, err := c.server.createEditor(nil, id, "")
+	return ed, localError(err)
+}
+
+// EditorInfo implements Client.EditorInfo.
+func (c *LocalClient) EditorInfo(editorPath string) (Editor, error) {
+	id, ok := pathID(editorPath, "editor")
+	if !ok {
+		return Editor{}, ErrNotFound
+	}
+	ed, err := c.server.getEditor(nil, id)
+	return ed, localError(err)
+}
+
+// Reader implements Client.Reader.
+func (c *LocalClient) Reader(editorPath string, addr edit.Address) (io.ReadCloser, error) {
+	id, ok := pathID(editorPath, "editor")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if addr == nil {
+		addr = edit.All
+	}
+	text, err := c.server.readText(nil, id, addr)
+	if err != nil {
+		return nil, localError(err)
+	}
+	return ioutil.NopCloser(bytes.NewReader(text)), nil
+}
+
+// Do implements Client.Do.
+func (c *LocalClient) Do(editorPath string, edits ...edit.Edit) ([]EditResult, error) {
+	id, ok := pathID(editorPath, "editor")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	results, err := c.server.do(nil, id, -1, edits)
+	return results, localError(err)
+}
+
+// Transaction implements Client.Transaction.
+func (c *LocalClient) Transaction(eds ...EditorEdits) (TransactionResult, error) {
+	result, err := c.server.doTransaction(nil, eds)
+	return result, localError(err)
+}
+
+// Close implements Client.Close.
+func (c *LocalClient) Close(path string) error {
+	if id, ok := pathID(path, "buffer"); ok {
+		return localError(c.server.deleteBuffer(nil, id))
+	}
+	if id, ok := pathID(path, "editor"); ok {
+		return localError(c.server.deleteEditor(nil, id))
+	}
+	return ErrNotFound
+}
+
+// LocalError returns the error that an HTTP client would return for err.
+func localError(err error) error {
+	if err, ok := err.(statusError); ok {
+		if err.status == http.StatusRequestedRangeNotSatisfiable {
+			return ErrRange
+		}
+		return err.err
+	}
+	return err
+}
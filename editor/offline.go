@@ -0,0 +1,239 @@
+// Copyright © 2016, The T Authors.
+
+package editor
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+)
+
+// ErrQueued indicates that edits were not yet performed,
+// but were queued by an OfflineClient,
+// because the editor server was unreachable.
+var ErrQueued = errors.New("queued")
+
+// An OfflineClient is an HTTPClient that queues edits
+// while the editor server is unreachable.
+// Methods on OfflineClient are safe for use by concurrent go routines.
+//
+// If Do cannot reach the server, its edits are queued,
+// and Do returns ErrQueued.
+// Queued edits are replayed, in order, by Flush,
+// which is also called at the beginning of each Do.
+// While edits are queued, Do queues further edits behind them.
+//
+// Queued edits are replayed using DoSequence
+// with the Sequence of their buffer
+// following the last edits performed by the OfflineClient.
+// If the buffer's text was changed in the meantime,
+// the queued edits for the buffer are dropped
+// and reported by a ConflictError.
+// Edits that do not change the text, such as Print, do not conflict.
+// Edits can only be queued for a buffer
+// after the OfflineClient has performed edits on it;
+// otherwise Do returns the error from the server.
+type OfflineClient struct {
+	HTTPClient
+
+	mu sync.Mutex
+	// Buffers maps editor paths to their buffer paths.
+	buffers map[string]string
+	// Seqs maps buffer paths to their Sequence
+	// following the last edits performed by the OfflineClient.
+	seqs  map[string]int
+	queue []EditorEdits
+}
+
+// NewOfflineClient returns a new OfflineClient
+// for the editor server at the given root URL.
+func NewOfflineClient(URL *url.URL) *OfflineClient {
+	return &OfflineClient{
+		HTTPClient: HTTPClient{URL: URL},
+		buffers:    make(map[string]string),
+		seqs:       make(map[string]int),
+	}
+}
+
+// A ConflictError is returned by an OfflineClient
+// when queued edits conflict with changes made to their buffer.
+type ConflictError struct {
+	// Dropped are the queued edits that were not performed,
+	// in the order that they were queued.
+	Dropped []EditorEdits
+
+	// Err, if non-nil, is an error other than ErrConflict
+	// returned by the server for queued edits,
+	// which were dropped along with the further queued edits for their buffer.
+	Err error
+}
+
+func (err *ConflictError) Error() string {
+	if err.Err != nil {
+		return err.Err.Error()
+	}
+	return ErrConflict.Error()
+}
+
+// Queued returns the number of queued calls to Do.
+func (c *OfflineClient) Queued() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// Do implements Client.Do.
+//
+// If edits are queued, they are replayed before the given edits.
+// If they are still queued, the given edits are queued behind them.
+// If replaying queued edits returns a ConflictError,
+// the given edits are still performed or queued,
+// and Do returns the ConflictError in place of a nil error or ErrQueued.
+func (c *OfflineClient) Do(editorPath string, edits ...edit.Edit) ([]EditResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.flush()
+	conflict, _ := err.(*ConflictError)
+	if err != nil && conflict == nil && !unreachable(err) {
+		return nil, err
+	}
+	results, err := c.do(editorPath, edits)
+	if conflict != nil && (err == nil || err == ErrQueued) {
+		return results, conflict
+	}
+	return results, err
+}
+
+// Do performs the edits, or queues them if the server is unreachable
+// or if other edits are already queued.
+// Must be called with mu held.
+func (c *OfflineClient) do(editorPath string, edits []edit.Edit) ([]EditResult, error) {
+	if len(c.queue) > 0 {
+		return nil, c.enqueue(editorPath, edits)
+	}
+	bufferPath, err := c.bufferPath(editorPath)
+	if err == nil {
+		var results []EditResult
+		if results, err = c.HTTPClient.Do(editorPath, edits...); err == nil {
+			if n := len(results); n > 0 {
+				c.seqs[bufferPath] = results[n-1].Sequence
+			}
+			return results, nil
+		}
+	}
+	if unreachable(err) && c.enqueue(editorPath, edits) == ErrQueued {
+		return nil, ErrQueued
+	}
+	return nil, err
+}
+
+// Flush replays queued edits, in order.
+// If the server is still unreachable,
+// the remaining edits stay queued,
+// and the error from the server is returned.
+//
+// If queued edits conflict with changes to their buffer,
+// they and any further queued edits for the buffer are dropped,
+// and a ConflictError is returned
+// once the rest of the queue is replayed.
+// If the server returns any other error for queued edits,
+// they and any further queued edits for the buffer are dropped,
+// and a ConflictError with the error is returned
+// without replaying the rest of the queue.
+func (c *OfflineClient) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flush()
+}
+
+// Flush must be called with mu held.
+func (c *OfflineClient) flush() error {
+	var conflict *ConflictError
+	for len(c.queue) > 0 {
+		eds := c.queue[0]
+		bufferPath := c.buffers[eds.EditorPath]
+		results, err := DoSequence(c.url(eds.EditorPath, "text"), c.seqs[bufferPath], eds.Edits...)
+		switch {
+		case err == nil:
+			if n := len(results); n > 0 {
+				c.seqs[bufferPath] = results[n-1].Sequence
+			}
+			c.queue = c.queue[1:]
+		case unreachable(err):
+			if conflict != nil {
+				return conflict
+			}
+			return err
+		default:
+			if conflict == nil {
+				conflict = &ConflictError{}
+			}
+			conflict.Dropped = append(conflict.Dropped, c.drop(bufferPath)...)
+			delete(c.seqs, bufferPath)
+			if err != ErrConflict {
+				conflict.Err = err
+				return conflict
+			}
+		}
+	}
+	if conflict != nil {
+		return conflict
+	}
+	return nil
+}
+
+// Drop removes and returns all queued edits for a buffer.
+// Must be called with mu held.
+func (c *OfflineClient) drop(bufferPath string) []EditorEdits {
+	var dropped []EditorEdits
+	var queue []EditorEdits
+	for _, eds := range c.queue {
+		if c.buffers[eds.EditorPath] == bufferPath {
+			dropped = append(dropped, eds)
+		} else {
+			queue = append(queue, eds)
+		}
+	}
+	c.queue = queue
+	return dropped
+}
+
+// Enqueue queues edits, returning ErrQueued,
+// or an error if the Sequence of the editor's buffer is not known.
+// Must be called with mu held.
+func (c *OfflineClient) enqueue(editorPath string, edits []edit.Edit) error {
+	bufferPath, ok := c.buffers[editorPath]
+	if !ok {
+		return errors.New("unknown editor: " + editorPath)
+	}
+	if _, ok := c.seqs[bufferPath]; !ok {
+		return errors.New("unknown buffer sequence: " + bufferPath)
+	}
+	c.queue = append(c.queue, EditorEdits{EditorPath: editorPath, Edits: edits})
+	return ErrQueued
+}
+
+// BufferPath returns the path of the buffer of an editor.
+// Must be called with mu held.
+func (c *OfflineClient) bufferPath(editorPath string) (string, error) {
+	if bufferPath, ok := c.buffers[editorPath]; ok {
+		return bufferPath, nil
+	}
+	ed, err := c.EditorInfo(editorPath)
+	if err != nil {
+		return "", err
+	}
+	c.buffers[editorPath] = ed.BufferPath
+	return ed.BufferPath, nil
+}
+
+// Unreachable returns whether an error
+// is from failing to communicate with the server,
+// as opposed to an error response from the server.
+func unreachable(err error) bool {
+	_, ok := err.(*url.Error)
+	return ok
+}
@@ -0,0 +1,470 @@
+// Copyright © 2016, The T Authors.
+
+package editor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+)
+
+// ServeRPC accepts connections on the listener
+// and serves the editor API over each with ServeRPCConn.
+// ServeRPC returns the error that stopped it accepting connections.
+func (s *Server) ServeRPC(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeRPCConn(conn)
+	}
+}
+
+// ServeRPCConn serves the editor API over a single connection using net/rpc,
+// blocking until the client hangs up.
+// The API is the same as that of the HTTP server;
+// an RPCClient is a Client for it.
+//
+// As with a LocalClient, RPC clients have WriteAccess to all buffers;
+// the Server's Authorize function is not called.
+// It is up to the caller to only serve connections from trusted clients,
+// for example, over a Unix domain socket or a pipe to a child process.
+//
+// Changes are streamed with long-polling calls,
+// and the ChangeStreams watched over the connection
+// are closed when the connection is closed.
+func (s *Server) ServeRPCConn(conn io.ReadWriteCloser) {
+	svc := &rpcService{server: s, watches: make(map[int]*ChangeStream)}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Editor", svc); err != nil {
+		panic(err)
+	}
+	srv.ServeConn(rpcConn{ReadWriteCloser: conn, svc: svc})
+}
+
+// An rpcConn closes the watches of its service
+// when reading from the connection fails.
+// The watches must be closed before ServeConn returns,
+// because it waits for pending calls, which may be blocked in Next.
+type rpcConn struct {
+	io.ReadWriteCloser
+	svc *rpcService
+}
+
+func (c rpcConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if err != nil {
+		c.svc.unwatchAll()
+	}
+	return n, err
+}
+
+// The arguments of RPC methods.
+// Net/rpc requires argument types to be exported or built-in,
+// so these are aliases of unnamed struct types.
+type (
+	rpcUpdateArgs = struct {
+		BufferPath string
+		Update     BufferUpdate
+	}
+	rpcWatchArgs = struct {
+		BufferPath string
+		Since      int
+	}
+	rpcSearchArgs = struct {
+		BufferPath, Regexp string
+		From               int64
+		Max                int
+	}
+	rpcTextArgs = struct {
+		EditorPath string
+		Addr       []byte
+	}
+	rpcDoArgs = struct {
+		EditorPath string
+		Edits      []editRequest
+	}
+	rpcTransactionArgs = struct {
+		Edits []editorEditsRequest
+	}
+)
+
+// GobEncode returns the edit.WireVersion JSON encoding of the Edit,
+// so that Edits can be sent as arguments of the RPC transport.
+func (e editRequest) GobEncode() ([]byte, error) { return e.MarshalJSON() }
+
+// GobDecode decodes the Edit from its JSON encoding.
+func (e *editRequest) GobDecode(data []byte) error { return e.UnmarshalJSON(data) }
+
+// An rpcService implements the methods of the RPC server
+// for a single connection.
+// Watches is nil once the connection is closed.
+type rpcService struct {
+	server *Server
+
+	mu        sync.Mutex
+	nextWatch int
+	watches   map[int]*ChangeStream
+}
+
+func (svc *rpcService) BufferList(_ struct{}, bufs *[]Buffer) error {
+	*bufs = svc.server.bufferList(nil)
+	return nil
+}
+
+func (svc *rpcService) NewBuffer(_ struct{}, buf *Buffer) error {
+	var err error
+	*buf, err = svc.server.createBuffer(nil)
+	return localError(err)
+}
+
+func (svc *rpcService) BufferInfo(bufferPath string, buf *Buffer) error {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*buf, err = svc.server.getBuffer(nil, id)
+	return localError(err)
+}
+
+func (svc *rpcService) UpdateBuffer(args rpcUpdateArgs, buf *Buffer) error {
+	id, ok := pathID(args.BufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*buf, err = svc.server.setBuffer(nil, id, args.Update)
+	return localError(err)
+}
+
+// Watch begins watching the changes of a buffer,
+// and returns the ID of the watch, used by Next and Unwatch.
+func (svc *rpcService) Watch(args rpcWatchArgs, watch *int) error {
+	id, ok := pathID(args.BufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	buf, changes, err := svc.server.watch(nil, id, args.Since, false)
+	if err != nil {
+		return localError(err)
+	}
+	s := &ChangeStream{buf: buf, changes: changes, closed: make(chan struct{})}
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if svc.watches == nil {
+		s.Close()
+		return io.EOF
+	}
+	svc.nextWatch++
+	*watch = svc.nextWatch
+	svc.watches[*watch] = s
+	return nil
+}
+
+// Next blocks until there are ChangeLists on a watch,
+// and returns all of them that are available.
+// Next returns io.EOF if the watch is closed.
+func (svc *rpcService) Next(watch int, cls *[]ChangeList) error {
+	svc.mu.Lock()
+	s, ok := svc.watches[watch]
+	svc.mu.Unlock()
+	if !ok {
+		return io.EOF
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.batch) == 0 {
+		if err := s.fill(); err != nil {
+			return err
+		}
+	}
+	*cls, s.batch = s.batch, nil
+	return nil
+}
+
+// Unwatch closes a watch, unblocking any calls to Next.
+func (svc *rpcService) Unwatch(watch int, _ *struct{}) error {
+	svc.mu.Lock()
+	s, ok := svc.watches[watch]
+	delete(svc.watches, watch)
+	svc.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	return s.Close()
+}
+
+func (svc *rpcService) unwatchAll() {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	for watch, s := range svc.watches {
+		if err := s.Close(); err != nil {
+			log.Printf("Failed to close watch %d: %v", watch, err)
+		}
+	}
+	svc.watches = nil
+}
+
+func (svc *rpcService) History(bufferPath string, history *[]HistoryEntry) error {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*history, err = svc.server.getHistory(nil, id)
+	return localError(err)
+}
+
+func (svc *rpcService) Search(args rpcSearchArgs, spans *[]edit.Span) error {
+	id, ok := pathID(args.BufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*spans, err = svc.server.searchBuffer(nil, id, args.Regexp, args.From, args.Max)
+	return localError(err)
+}
+
+func (svc *rpcService) NewEditor(bufferPath string, ed *Editor) error {
+	id, ok := pathID(bufferPath, "buffer")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*ed, err = svc.server.createEditor(nil, id, "")
+	return localError(err)
+}
+
+func (svc *rpcService) EditorInfo(editorPath string, ed *Editor) error {
+	id, ok := pathID(editorPath, "editor")
+	if !ok {
+		return ErrNotFound
+	}
+	var err error
+	*ed, err = svc.server.getEditor(nil, id)
+	return localError(err)
+}
+
+func (svc *rpcService) Text(args rpcTextArgs, text *[]byte) error {
+	id, ok := pathID(args.EditorPath, "editor")
+	if !ok {
+		return ErrNotFound
+	}
+	addr, err := edit.UnmarshalAddress(args.Addr)
+	if err != nil {
+		return err
+	}
+	*text, err = svc.server.readText(nil, id, addr)
+	return localError(err)
+}
+
+func (svc *rpcService) Do(args rpcDoArgs, results *[]EditResult) error {
+	id, ok := pathID(args.EditorPath, "editor")
+	if !ok {
+		return ErrNotFound
+	}
+	edits := make([]edit.Edit, len(args.Edits))
+	for i, e := range args.Edits {
+		edits[i] = e.Edit
+	}
+	var err error
+	*results, err = svc.server.do(nil, id, -1, edits)
+	return localError(err)
+}
+
+func (svc *rpcService) Transaction(args rpcTransactionArgs, result *TransactionResult) error {
+	eds := make([]EditorEdits, len(args.Edits))
+	for i, ed := range args.Edits {
+		eds[i].EditorPath = ed.EditorPath
+		for _, e := range ed.Edits {
+			eds[i].Edits = append(eds[i].Edits, e.Edit)
+		}
+	}
+	var err error
+	*result, err = svc.server.doTransaction(nil, eds)
+	return localError(err)
+}
+
+func (svc *rpcService) Close(path string, _ *struct{}) error {
+	if id, ok := pathID(path, "buffer"); ok {
+		return localError(svc.server.deleteBuffer(nil, id))
+	}
+	if id, ok := pathID(path, "editor"); ok {
+		return localError(svc.server.deleteEditor(nil, id))
+	}
+	return ErrNotFound
+}
+
+// An RPCClient is a Client that makes requests to an editor server
+// over a net/rpc connection served by Server.ServeRPCConn.
+//
+// Edits are sent in the JSON wire format of edit.WireVersion,
+// and all other requests and responses are gob encoded.
+type RPCClient struct {
+	client *rpc.Client
+}
+
+// NewRPCClient returns a new RPCClient that makes requests over the connection.
+func NewRPCClient(conn io.ReadWriteCloser) *RPCClient {
+	return &RPCClient{client: rpc.NewClient(conn)}
+}
+
+// DialRPC returns a new RPCClient connected to the address on the named network,
+// as served by Server.ServeRPC.
+func DialRPC(network, address string) (*RPCClient, error) {
+	client, err := rpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &RPCClient{client: client}, nil
+}
+
+// Disconnect closes the connection of the RPCClient.
+// Its ChangeStreams return an error from Next after Disconnect.
+func (c *RPCClient) Disconnect() error { return c.client.Close() }
+
+func (c *RPCClient) call(method string, args, reply interface{}) error {
+	return rpcError(c.client.Call("Editor."+method, args, reply))
+}
+
+// BufferList implements Client.BufferList.
+func (c *RPCClient) BufferList() ([]Buffer, error) {
+	var bufs []Buffer
+	err := c.call("BufferList", struct{}{}, &bufs)
+	return bufs, err
+}
+
+// NewBuffer implements Client.NewBuffer.
+func (c *RPCClient) NewBuffer() (Buffer, error) {
+	var buf Buffer
+	err := c.call("NewBuffer", struct{}{}, &buf)
+	return buf, err
+}
+
+// BufferInfo implements Client.BufferInfo.
+func (c *RPCClient) BufferInfo(bufferPath string) (Buffer, error) {
+	var buf Buffer
+	err := c.call("BufferInfo", bufferPath, &buf)
+	return buf, err
+}
+
+// UpdateBuffer implements Client.UpdateBuffer.
+func (c *RPCClient) UpdateBuffer(bufferPath string, update BufferUpdate) (Buffer, error) {
+	var buf Buffer
+	err := c.call("UpdateBuffer", rpcUpdateArgs{BufferPath: bufferPath, Update: update}, &buf)
+	return buf, err
+}
+
+// Changes implements Client.Changes.
+func (c *RPCClient) Changes(bufferPath string) (*ChangeStream, error) {
+	return c.ChangesSince(bufferPath, -1)
+}
+
+// ChangesSince implements Client.ChangesSince.
+func (c *RPCClient) ChangesSince(bufferPath string, seq int) (*ChangeStream, error) {
+	var watch int
+	if err := c.call("Watch", rpcWatchArgs{BufferPath: bufferPath, Since: seq}, &watch); err != nil {
+		return nil, err
+	}
+	return &ChangeStream{rpc: c.client, watch: watch, seq: seq}, nil
+}
+
+// History implements Client.History.
+func (c *RPCClient) History(bufferPath string) ([]HistoryEntry, error) {
+	var history []HistoryEntry
+	err := c.call("History", bufferPath, &history)
+	return history, err
+}
+
+// Search implements Client.Search.
+func (c *RPCClient) Search(bufferPath, re string, from int64, max int) ([]edit.Span, error) {
+	var spans []edit.Span
+	args := rpcSearchArgs{BufferPath: bufferPath, Regexp: re, From: from, Max: max}
+	err := c.call("Search", args, &spans)
+	return spans, err
+}
+
+// NewEditor implements Client.NewEditor.
+func (c *RPCClient) NewEditor(bufferPath string) (Editor, error) {
+	var ed Editor
+	err := c.call("NewEditor", bufferPath, &ed)
+	return ed, err
+}
+
+// EditorInfo implements Client.EditorInfo.
+func (c *RPCClient) EditorInfo(editorPath string) (Editor, error) {
+	var ed Editor
+	err := c.call("EditorInfo", editorPath, &ed)
+	return ed, err
+}
+
+// Reader implements Client.Reader.
+// The text is read in its entirety before Reader returns.
+func (c *RPCClient) Reader(editorPath string, addr edit.Address) (io.ReadCloser, error) {
+	if addr == nil {
+		addr = edit.All
+	}
+	data, err := json.Marshal(addr)
+	if err != nil {
+		return nil, err
+	}
+	var text []byte
+	if err := c.call("Text", rpcTextArgs{EditorPath: editorPath, Addr: data}, &text); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(text)), nil
+}
+
+// Do implements Client.Do.
+func (c *RPCClient) Do(editorPath string, edits ...edit.Edit) ([]EditResult, error) {
+	args := rpcDoArgs{EditorPath: editorPath}
+	for _, e := range edits {
+		args.Edits = append(args.Edits, editRequest{e})
+	}
+	var results []EditResult
+	err := c.call("Do", args, &results)
+	return results, err
+}
+
+// Transaction implements Client.Transaction.
+func (c *RPCClient) Transaction(eds ...EditorEdits) (TransactionResult, error) {
+	var args rpcTransactionArgs
+	for _, ed := range eds {
+		req := editorEditsRequest{EditorPath: ed.EditorPath}
+		for _, e := range ed.Edits {
+			req.Edits = append(req.Edits, editRequest{e})
+		}
+		args.Edits = append(args.Edits, req)
+	}
+	var result TransactionResult
+	err := c.call("Transaction", args, &result)
+	return result, err
+}
+
+// Close implements Client.Close.
+func (c *RPCClient) Close(path string) error { return c.call("Close", path, nil) }
+
+// RPCError returns the error that an HTTP client would return
+// for an error returned by an RPC call.
+func rpcError(err error) error {
+	serr, ok := err.(rpc.ServerError)
+	if !ok {
+		return err
+	}
+	for _, e := range []error{ErrNotFound, ErrRange, ErrForbidden, ErrGone, ErrConflict, io.EOF} {
+		if string(serr) == e.Error() {
+			return e
+		}
+	}
+	return errors.New(string(serr))
+}
@@ -5,14 +5,18 @@ package editor
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/eaburns/T/edit"
 	"github.com/eaburns/T/websocket"
@@ -35,11 +39,108 @@ import (
 // https://godoc.org/github.com/eaburns/T/edit#Ed.
 // While multiple editors can edit the same buffer concurrently,
 // each editor maintains its own local state.
+//
+// Change notifications
+//
+// Changes made to a buffer are sent to the watchers of its change stream.
+// ChangeLists are coalesced into batches,
+// each of which is sent as a single websocket message.
+// Coalescing never reorders ChangeLists.
+//
+// Each buffer keeps a history of its most recent ChangeLists.
+// A watcher that lost its connection can resume the change stream
+// from the Sequence of the last ChangeList that it received,
+// so long as the following ChangeLists are still in the history.
+// The history can also be read, for example to display it to the user.
+//
+// Access control
+//
+// By default, all clients have full access to all buffers.
+// If the Authorize field is set, it is consulted on each request
+// to determine the Access granted to the client.
+// A client with ReadAccess to a buffer can read its text,
+// search it, watch its change stream, and create editors for it,
+// but edits that would change its text or metadata fail.
+// A client with NoAccess to a buffer cannot see it at all.
 type Server struct {
 	sync.RWMutex
 	buffers map[string]*buffer
 	editors map[string]*editor
 	nextID  int
+
+	// ChangeBatchWindow is the amount of time to collect ChangeLists
+	// before sending them to a watcher in a single batch.
+	// If ChangeBatchWindow is 0, ChangeLists are sent as soon as possible,
+	// but ChangeLists made while a watcher is still sending
+	// a previous batch are coalesced into the next batch.
+	//
+	// ChangeBatchWindow must not be modified after handlers are registered.
+	ChangeBatchWindow time.Duration
+
+	// ChangeBatchSize is the number of Changes
+	// at which a batch is sent without waiting for ChangeBatchWindow to expire.
+	// If ChangeBatchSize is 0, there is no limit.
+	// A single ChangeList is never split across batches,
+	// so a batch may have more than ChangeBatchSize changes.
+	//
+	// ChangeBatchSize must not be modified after handlers are registered.
+	ChangeBatchSize int
+
+	// Authorize, if non-nil, returns the Access granted to a request
+	// for the buffer with the given ID.
+	// The ID is the empty string for requests
+	// that do not refer to a particular buffer,
+	// such as listing or creating buffers.
+	//
+	// The request may be a websocket handshake,
+	// in which case Authorize can check its Origin header.
+	//
+	// Authorize is called with Server locks held,
+	// so it must not make requests to the Server.
+	//
+	// Authorize must not be modified after handlers are registered.
+	Authorize func(req *http.Request, bufferID string) Access
+
+	// Journal, if non-nil, is written a record
+	// of each change made to a buffer created by the Server,
+	// before the change is reported to clients.
+	// If Journal has a Sync method, it is called after each record.
+	// The buffers can be restored from the Journal using Recover.
+	// Buffers added with AddBuffer are not journaled.
+	//
+	// Journal must not be modified after the Server is in use.
+	Journal   io.Writer
+	journalMu sync.Mutex
+}
+
+// An Access is a level of access to a buffer.
+type Access int
+
+const (
+	// NoAccess is no access.
+	NoAccess Access = iota
+	// ReadAccess allows reading, but not modifying.
+	ReadAccess
+	// WriteAccess allows reading and modifying.
+	WriteAccess
+)
+
+// Access returns the Access granted to the request for the buffer with the given ID.
+// A nil request is from a LocalClient, and is granted WriteAccess.
+func (s *Server) access(req *http.Request, bufferID string) Access {
+	if s.Authorize == nil || req == nil {
+		return WriteAccess
+	}
+	return s.Authorize(req, bufferID)
+}
+
+// CheckAccess returns ErrForbidden if the request does not have
+// at least the given Access to the buffer with the given ID.
+func (s *Server) checkAccess(req *http.Request, bufferID string, a Access) error {
+	if s.access(req, bufferID) < a {
+		return ErrForbidden
+	}
+	return nil
 }
 
 // NewServer returns a new Server.
@@ -71,6 +172,7 @@ func (s *Server) Close() error {
 //  /buffers is the list of opened buffers.
 //
 // 	GET returns a Buffer list of the opened buffers.
+// 	Buffers to which the client has NoAccess are omitted.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
@@ -79,6 +181,7 @@ func (s *Server) Close() error {
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
+// 	• Forbidden if the client does not have WriteAccess to the server.
 //
 //  /buffer/<ID> is the buffer with the given ID.
 //
@@ -87,27 +190,93 @@ func (s *Server) Close() error {
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have ReadAccess to the buffer.
 //
 // 	DELETE deletes the buffer and all of its editors.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have WriteAccess to the buffer.
 //
 // 	PUT creates a new editor for the buffer and returns its Editor.
+// 	Parameters:
+// 	• label can optionally be set to the Label of the editor.
+// 	  It must not appear multiple times.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have ReadAccess to the buffer.
+// 	• Bad Request if the URL parameters are malformed.
+//
+// 	PATCH updates the buffer's metadata and returns its Buffer.
+// 	The body must be a BufferUpdate.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have WriteAccess to the buffer.
+// 	• Bad Request if the BufferUpdate is malformed.
+// 	• Conflict if UnchangedSince is set,
+// 	  and the buffer's text changed since that sequence number.
 //
 //  /buffer/<ID>/changes is the buffer's change stream.
 //
 // 	GET upgrades the connection to a websocket.
 // 	A ChangeList is sent on the websocket
 // 	for each edit made to the buffer.
+// 	The ChangeList identifies the editor that made the changes, and its Label.
+// 	Each websocket message is a list of one or more ChangeLists,
+// 	in the order that their edits were made.
+// 	Parameters:
+// 	• since is a sequence number.
+// 	  If it is set, all ChangeLists with a greater Sequence
+// 	  are sent before any new ChangeLists.
+// 	  If it is not set, only new ChangeLists are sent.
+// 	• presence is a boolean.
+// 	  If it is true, ChangeLists with the Presence of the buffer's editors
+// 	  are also sent: first with the Presence of all editors,
+// 	  then with the Presence of each editor whose dot changes,
+// 	  and of each editor that is created or closed.
+// 	Returns:
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have ReadAccess to the buffer.
+// 	• Bad Request if since or presence is malformed,
+// 	  or since is greater than the buffer's Sequence.
+// 	• Gone if the ChangeLists since the sequence number are no longer available.
+//
+//  /buffer/<ID>/history is the buffer's history.
+//
+// 	GET returns a HistoryEntry list of the buffer's most recent ChangeLists,
+// 	in the order that their edits were made.
 // 	Returns:
+// 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have ReadAccess to the buffer.
+//
+//  /buffer/<ID>/search searches the buffer's text.
+//
+// 	GET returns a list of Spans of matches of a regular expression.
+// 	The search does not modify the buffer,
+// 	nor the dot or marks of any of its editors.
+// 	Parameters:
+// 	• q is the regular expression, in the syntax of the edit package's Regexp Address.
+// 	  It is required, and must not appear multiple times.
+// 	• from is the rune offset at which to begin searching.
+// 	  If it is not set, the search begins at the start of the buffer.
+// 	  The search does not wrap around to the beginning of the buffer.
+// 	• max is the maximum number of matches to return.
+// 	  If it is not set or 0, all matches are returned.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the buffer is not found.
+// 	• Forbidden if the client does not have ReadAccess to the buffer.
+// 	• Bad Request if the URL parameters or regular expression are malformed.
+// 	• Range Not Satisfiable if from is out of the range of the buffer.
 //
 //  /editor/<ID> is the editor with the given ID.
 //
@@ -116,12 +285,14 @@ func (s *Server) Close() error {
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the editor is not found.
+// 	• Forbidden if the client does not have ReadAccess to the editor's buffer.
 //
 // 	DELETE deletes the editor.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the editor editor is not found.
+// 	• Forbidden if the client does not have ReadAccess to the editor's buffer.
 //
 //  /editor/<ID>/text is the text that the editor edits.
 //
@@ -135,6 +306,7 @@ func (s *Server) Close() error {
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the editor is not found.
+// 	• Forbidden if the client does not have ReadAccess to the editor's buffer.
 // 	• Bad Request if the URL parameters or addr value are malformed.
 // 	• Range Not Satisfiable if there is an error evaluating the address.
 // 	  The response body will contain an error message.
@@ -142,11 +314,67 @@ func (s *Server) Close() error {
 // 	POST performs an atomic sequence of edits on the buffer.
 // 	The body must be an ordered list of Edits.
 // 	The response is an ordered list of EditResult.
+// 	If the client has only ReadAccess to the buffer,
+// 	edits that would modify the buffer fail.
+// 	Parameters:
+// 	• sequence can optionally be set to a sequence number.
+// 	  If it is set, the edits are only performed
+// 	  if the buffer's text has not changed since that sequence number.
+// 	  Edits that do not change the text, such as Print, do not conflict.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the editor is not found.
-// 	• Bad Request if the Edit list is malformed.
+// 	• Forbidden if the client has NoAccess to the editor's buffer.
+// 	• Bad Request if the URL parameters or Edit list are malformed.
+// 	• Conflict if sequence is set and the buffer's text changed since,
+// 	  or if it is greater than the Sequence of the buffer.
+//
+//  /editor/<ID>/transform makes concurrent changes to the editor's buffer.
+//
+// 	POST makes a sequence of changes computed against the text
+// 	as of an earlier sequence number.
+// 	The body must be a TransformRequest.
+// 	The Span of each change is transformed
+// 	against the changes made to the buffer since the sequence number,
+// 	so that it identifies the same text in the current buffer,
+// 	and the changes are made atomically.
+// 	Text inserted at the same location as a change made since
+// 	is inserted after the text of that change.
+// 	The response is a TransformResult.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the editor is not found.
+// 	• Forbidden if the client does not have WriteAccess to the editor's buffer.
+// 	• Bad Request if the TransformRequest is malformed,
+// 	  its Sequence is negative or greater than the buffer's Sequence,
+// 	  or its changes are out of sequence.
+// 	• Range Not Satisfiable if a Span is out of the range of the buffer.
+// 	• Gone if the changes since the sequence number are no longer available.
+// 	• Conflict if the buffer's text does not report its changes,
+// 	  and there was an Undo or Redo since the sequence number.
+//
+//  /transaction performs edits on multiple buffers atomically.
+//
+// 	POST performs a transaction.
+// 	The body must be an ordered list of EditorEdits.
+// 	The edits of each EditorEdits are performed in order,
+// 	and the EditorEdits are performed in the order of the list.
+// 	If an edit fails, no further edits are performed,
+// 	and the changes made by all previous edits in the transaction
+// 	are rolled back, restoring the text and marks of all buffers.
+// 	A rolled back transaction leaves no undo history,
+// 	and its changes are not sent on change streams.
+// 	The Undo and Redo edits are not allowed within a transaction.
+// 	The response is a TransactionResult.
+// 	Returns:
+// 	• OK on success, even if the transaction is rolled back.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if an editor is not found.
+// 	• Forbidden if the client does not have WriteAccess to the buffer of an editor.
+// 	• Bad Request if the EditorEdits list is malformed,
+// 	  or if the text of a buffer cannot be rolled back.
 //
 // Unless otherwise stated, the body of all error responses is the error message.
 func (s *Server) RegisterHandlers(r *mux.Router) {
@@ -155,11 +383,16 @@ func (s *Server) RegisterHandlers(r *mux.Router) {
 	r.HandleFunc("/buffer/{id}", s.bufferInfo).Methods(http.MethodGet)
 	r.HandleFunc("/buffer/{id}", s.closeBuffer).Methods(http.MethodDelete)
 	r.HandleFunc("/buffer/{id}", s.newEditor).Methods(http.MethodPut)
+	r.HandleFunc("/buffer/{id}", s.updateBuffer).Methods(http.MethodPatch)
 	r.HandleFunc("/buffer/{id}/changes", s.changes).Methods(http.MethodGet)
+	r.HandleFunc("/buffer/{id}/history", s.history).Methods(http.MethodGet)
+	r.HandleFunc("/buffer/{id}/search", s.search).Methods(http.MethodGet)
 	r.HandleFunc("/editor/{id}", s.editorInfo).Methods(http.MethodGet)
 	r.HandleFunc("/editor/{id}", s.closeEditor).Methods(http.MethodDelete)
 	r.HandleFunc("/editor/{id}/text", s.read).Methods(http.MethodGet)
 	r.HandleFunc("/editor/{id}/text", s.edit).Methods(http.MethodPost)
+	r.HandleFunc("/editor/{id}/transform", s.transform).Methods(http.MethodPost)
+	r.HandleFunc("/transaction", s.transaction).Methods(http.MethodPost)
 }
 
 // respond JSON encodes resp to w, and sends an Internal Server Error on failure.
@@ -176,58 +409,181 @@ func respond(w http.ResponseWriter, resp interface{}) {
 }
 
 func (s *Server) listBuffers(w http.ResponseWriter, req *http.Request) {
+	respond(w, s.bufferList(req))
+}
+
+func (s *Server) bufferList(req *http.Request) []Buffer {
 	s.RLock()
+	defer s.RUnlock()
 	var bufs []Buffer
 	for _, b := range s.buffers {
-		bufs = append(bufs, b.Buffer)
+		if s.access(req, b.ID) < ReadAccess {
+			continue
+		}
+		b.RLock()
+		bufs = append(bufs, b.info())
+		b.RUnlock()
 	}
-	s.RUnlock()
-
-	respond(w, bufs)
+	return bufs
 }
 
 func (s *Server) newBuffer(w http.ResponseWriter, req *http.Request) {
+	buf, err := s.createBuffer(req)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, buf)
+}
+
+func (s *Server) createBuffer(req *http.Request) (Buffer, error) {
 	s.Lock()
-	id := strconv.Itoa(s.nextID)
+	defer s.Unlock()
+	if err := s.checkAccess(req, "", WriteAccess); err != nil {
+		return Buffer{}, err
+	}
+	buf := s.addBuffer(edit.NewBuffer())
+	if err := s.writeJournal(journalRecord{Op: "new", Buffer: buf.ID}); err != nil {
+		delete(s.buffers, buf.ID)
+		return Buffer{}, err
+	}
+	buf.journal = s.writeJournal
+	return buf.Buffer, nil
+}
+
+// AddBuffer adds a buffer with the given name
+// whose text is read and modified by the given edit.Editor,
+// and returns its Buffer.
+//
+// The edit.Editor may reject changes by returning errors
+// from its Change, Apply, Undo, and Redo methods,
+// for example to provide a read-only view of some text.
+// Such errors are reported in the EditResult of the failed edit.
+//
+// Once added, the edit.Editor must only be modified through the Server,
+// for example, by using a LocalClient.
+// If the edit.Editor implements io.Closer,
+// it is closed when the buffer is closed.
+func (s *Server) AddBuffer(name string, text edit.Editor) Buffer {
+	s.Lock()
+	defer s.Unlock()
+	buf := s.addBuffer(text)
+	buf.Name = name
+	return buf.info()
+}
+
+// AddBuffer adds and returns a new buffer with the given text.
+// Must be called with the server's write Lock held.
+func (s *Server) addBuffer(text edit.Editor) *buffer {
+	buf := makeBuffer(strconv.Itoa(s.nextID), text)
 	s.nextID++
+	s.buffers[buf.ID] = buf
+	return buf
+}
+
+func makeBuffer(id string, text edit.Editor) *buffer {
 	buf := &buffer{
 		Buffer: Buffer{
 			ID:   id,
 			Path: path.Join("/", "buffer", id),
 		},
-		buffer:  edit.NewBuffer(),
-		editors: make(map[string]*editor),
-		done:    make(chan struct{}),
+		text:     text,
+		editors:  make(map[string]*editor),
+		presence: make(map[chan []ChangeList]bool),
+		done:     make(chan struct{}),
 	}
-	s.buffers[buf.ID] = buf
-	s.Unlock()
+	if t, ok := text.(changeReporter); ok {
+		t.OnChange(func(cs []edit.AppliedChange) { buf.applied = append(buf.applied, cs...) })
+		buf.reportsChanges = true
+	}
+	return buf
+}
 
-	respond(w, buf.Buffer)
+// A changeReporter is a text that reports the changes made to it.
+type changeReporter interface {
+	OnChange(func([]edit.AppliedChange))
 }
 
 func (s *Server) bufferInfo(w http.ResponseWriter, req *http.Request) {
+	buf, err := s.getBuffer(req, mux.Vars(req)["id"])
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, buf)
+}
+
+func (s *Server) getBuffer(req *http.Request, id string) (Buffer, error) {
 	s.RLock()
-	buf, ok := s.buffers[mux.Vars(req)["id"]]
+	defer s.RUnlock()
+	buf, ok := s.buffers[id]
 	if !ok {
-		s.RUnlock()
-		http.NotFound(w, req)
-		return
+		return Buffer{}, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, ReadAccess); err != nil {
+		return Buffer{}, err
 	}
 	buf.RLock()
-	info := buf.Buffer
-	buf.RUnlock()
-	s.RUnlock()
+	defer buf.RUnlock()
+	return buf.info(), nil
+}
+
+func (s *Server) updateBuffer(w http.ResponseWriter, req *http.Request) {
+	var update BufferUpdate
+	if err := json.NewDecoder(req.Body).Decode(&update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buf, err := s.setBuffer(req, mux.Vars(req)["id"], update)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, buf)
+}
 
-	respond(w, info)
+func (s *Server) setBuffer(req *http.Request, id string, update BufferUpdate) (Buffer, error) {
+	s.RLock()
+	buf, ok := s.buffers[id]
+	if !ok {
+		s.RUnlock()
+		return Buffer{}, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, WriteAccess); err != nil {
+		s.RUnlock()
+		return Buffer{}, err
+	}
+	buf.Lock()
+	defer buf.Unlock()
+	s.RUnlock()
+	if update.UnchangedSince != nil && buf.changeSeq > *update.UnchangedSince {
+		return Buffer{}, ErrConflict
+	}
+	buf.update(update)
+	if buf.journal != nil {
+		if err := buf.journal(journalRecord{Op: "update", Buffer: buf.ID, Update: &update}); err != nil {
+			return Buffer{}, err
+		}
+	}
+	return buf.info(), nil
 }
 
 func (s *Server) closeBuffer(w http.ResponseWriter, req *http.Request) {
+	if err := s.deleteBuffer(req, mux.Vars(req)["id"]); err != nil {
+		httpError(w, req, err)
+	}
+}
+
+func (s *Server) deleteBuffer(req *http.Request, id string) error {
 	s.Lock()
-	buf, ok := s.buffers[mux.Vars(req)["id"]]
+	buf, ok := s.buffers[id]
 	if !ok {
 		s.Unlock()
-		http.NotFound(w, req)
-		return
+		return ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, WriteAccess); err != nil {
+		s.Unlock()
+		return err
 	}
 	buf.Lock()
 	defer buf.Unlock()
@@ -237,38 +593,49 @@ func (s *Server) closeBuffer(w http.ResponseWriter, req *http.Request) {
 	}
 	s.Unlock()
 
-	if err := buf.close(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if buf.journal != nil {
+		if err := buf.journal(journalRecord{Op: "close", Buffer: buf.ID}); err != nil {
+			buf.close()
+			return err
+		}
 	}
+	return buf.close()
 }
 
 func (s *Server) changes(w http.ResponseWriter, req *http.Request) {
-	s.Lock()
-	buf, ok := s.buffers[mux.Vars(req)["id"]]
-	if !ok {
-		s.Unlock()
-		http.NotFound(w, req)
+	vars, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	buf.Lock()
-	s.Unlock()
-	changes := make(chan []ChangeList, 1)
-	buf.watchers = append(buf.watchers, changes)
-	buf.Unlock()
-
-	defer func() {
-		buf.Lock()
-		for i := range buf.watchers {
-			if buf.watchers[i] == changes {
-				buf.watchers = append(buf.watchers[:i], buf.watchers[i+1:]...)
-				if buf.watcherRemoved != nil {
-					buf.watcherRemoved <- struct{}{}
-				}
-				break
-			}
+	since := -1
+	if v, ok := vars["since"]; ok {
+		if len(v) > 1 {
+			http.Error(w, "since can only be given once", http.StatusBadRequest)
+			return
 		}
-		buf.Unlock()
-	}()
+		if since, err = strconv.Atoi(v[0]); err != nil || since < 0 {
+			http.Error(w, "bad since: "+v[0], http.StatusBadRequest)
+			return
+		}
+	}
+	var presence bool
+	if v, ok := vars["presence"]; ok {
+		if len(v) > 1 {
+			http.Error(w, "presence can only be given once", http.StatusBadRequest)
+			return
+		}
+		if presence, err = strconv.ParseBool(v[0]); err != nil {
+			http.Error(w, "bad presence: "+v[0], http.StatusBadRequest)
+			return
+		}
+	}
+	buf, changes, err := s.watch(req, mux.Vars(req)["id"], since, presence)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	defer buf.unwatch(changes)
 
 	conn, err := websocket.Upgrade(w, req)
 	if err != nil {
@@ -280,6 +647,9 @@ func (s *Server) changes(w http.ResponseWriter, req *http.Request) {
 	done := make(chan struct{})
 	go recvUntilError(conn, done)
 
+	var batch []ChangeList
+	var size int
+	var timer <-chan time.Time
 	for {
 		select {
 		case <-done:
@@ -287,18 +657,80 @@ func (s *Server) changes(w http.ResponseWriter, req *http.Request) {
 		case <-buf.done:
 			return
 		case cls := <-changes:
+			batch = append(batch, cls...)
 			for _, cl := range cls {
-				if err := conn.Send(cl); err != nil {
-					if err != websocket.ErrCloseSent {
-						log.Printf("Error sending to websocket: %v", err)
-					}
-					return
+				size += len(cl.Changes)
+			}
+			if s.ChangeBatchWindow > 0 && (s.ChangeBatchSize <= 0 || size < s.ChangeBatchSize) {
+				if timer == nil {
+					timer = time.After(s.ChangeBatchWindow)
 				}
+				continue
+			}
+		case <-timer:
+		}
+		if err := conn.Send(batch); err != nil {
+			if err != websocket.ErrCloseSent {
+				log.Printf("Error sending to websocket: %v", err)
 			}
+			return
 		}
+		batch, size, timer = nil, 0, nil
 	}
 }
 
+// Watch adds and returns a new watcher to the buffer with the given ID.
+// If since is non-negative, the ChangeLists with greater Sequences
+// are queued on the watcher before any new ChangeLists.
+// If presence is true, the watcher is also sent the Presence of editors,
+// beginning with a ChangeList with the Presence of all editors.
+// The watcher must be removed with unwatch when no longer needed.
+func (s *Server) watch(req *http.Request, id string, since int, presence bool) (*buffer, chan []ChangeList, error) {
+	s.Lock()
+	buf, ok := s.buffers[id]
+	if !ok {
+		s.Unlock()
+		return nil, nil, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, ReadAccess); err != nil {
+		s.Unlock()
+		return nil, nil, err
+	}
+	buf.Lock()
+	s.Unlock()
+	changes := make(chan []ChangeList, 1)
+	if since >= 0 {
+		cls, err := buf.changesSince(since)
+		if err != nil {
+			buf.Unlock()
+			return nil, nil, err
+		}
+		if len(cls) > 0 {
+			changes <- cls
+		}
+	}
+	if presence {
+		var ps []Presence
+		for _, ed := range buf.editors {
+			ps = append(ps, ed.presence())
+		}
+		sort.Sort(presenceByPath(ps))
+		if len(ps) > 0 {
+			cl := ChangeList{Sequence: buf.Sequence, Presence: ps}
+			select {
+			case cls := <-changes:
+				changes <- append(cls, cl)
+			default:
+				changes <- []ChangeList{cl}
+			}
+		}
+		buf.presence[changes] = true
+	}
+	buf.watchers = append(buf.watchers, changes)
+	buf.Unlock()
+	return buf, changes, nil
+}
+
 func recvUntilError(conn *websocket.Conn, done chan<- struct{}) {
 	defer close(done)
 	for {
@@ -311,15 +743,163 @@ func recvUntilError(conn *websocket.Conn, done chan<- struct{}) {
 	}
 }
 
-func (s *Server) newEditor(w http.ResponseWriter, req *http.Request) {
+func (s *Server) history(w http.ResponseWriter, req *http.Request) {
+	history, err := s.getHistory(req, mux.Vars(req)["id"])
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, history)
+}
+
+func (s *Server) getHistory(req *http.Request, id string) ([]HistoryEntry, error) {
+	s.RLock()
+	defer s.RUnlock()
+	buf, ok := s.buffers[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, ReadAccess); err != nil {
+		return nil, err
+	}
+	buf.RLock()
+	defer buf.RUnlock()
+	return append([]HistoryEntry{}, buf.history...), nil
+}
+
+func (s *Server) search(w http.ResponseWriter, req *http.Request) {
+	vars, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, ok := vars["q"]
+	if !ok || len(q) != 1 {
+		http.Error(w, "q must be given once", http.StatusBadRequest)
+		return
+	}
+	var from int64
+	if f, ok := vars["from"]; ok {
+		if len(f) > 1 {
+			http.Error(w, "from can only be given once", http.StatusBadRequest)
+			return
+		}
+		if from, err = strconv.ParseInt(f[0], 10, 64); err != nil || from < 0 {
+			http.Error(w, "bad from: "+f[0], http.StatusBadRequest)
+			return
+		}
+	}
+	var max int
+	if m, ok := vars["max"]; ok {
+		if len(m) > 1 {
+			http.Error(w, "max can only be given once", http.StatusBadRequest)
+			return
+		}
+		if max, err = strconv.Atoi(m[0]); err != nil || max < 0 {
+			http.Error(w, "bad max: "+m[0], http.StatusBadRequest)
+			return
+		}
+	}
+	spans, err := s.searchBuffer(req, mux.Vars(req)["id"], q[0], from, max)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, spans)
+}
+
+func (s *Server) searchBuffer(req *http.Request, id, re string, from int64, max int) ([]edit.Span, error) {
+	if _, err := edit.Addr(strings.NewReader("/" + edit.Escape(re, '/') + "/")); err != nil {
+		return nil, statusError{http.StatusBadRequest, err}
+	}
+
 	s.Lock()
-	buf, ok := s.buffers[mux.Vars(req)["id"]]
+	buf, ok := s.buffers[id]
 	if !ok {
 		s.Unlock()
-		http.NotFound(w, req)
+		return nil, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, ReadAccess); err != nil {
+		s.Unlock()
+		return nil, err
+	}
+	buf.Lock()
+	defer buf.Unlock()
+	s.Unlock()
+
+	if size := buf.text.Size(); from > size {
+		return nil, statusError{http.StatusRequestedRangeNotSatisfiable, edit.RangeError(size)}
+	}
+	return search(buf.text, re, from, max)
+}
+
+// Search returns the Spans of matches of a regular expression
+// beginning from a rune offset, without wrapping,
+// stopping after max matches if max > 0.
+func search(text edit.Text, re string, from int64, max int) ([]edit.Span, error) {
+	spans := []edit.Span{}
+	size := text.Size()
+	for from <= size && (max <= 0 || len(spans) < max) {
+		s, err := edit.Rune(from).Plus(edit.Regexp(re)).Where(text)
+		if err == edit.ErrNoMatch {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s[0] < from {
+			// The search wrapped.
+			break
+		}
+		if n := len(spans); s.Size() == 0 && n > 0 && spans[n-1][1] == s[0] {
+			// Skip an empty match immediately following the previous match.
+			from++
+			continue
+		}
+		spans = append(spans, s)
+		if s.Size() == 0 {
+			from = s[1] + 1
+		} else {
+			from = s[1]
+		}
+	}
+	return spans, nil
+}
+
+func (s *Server) newEditor(w http.ResponseWriter, req *http.Request) {
+	vars, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	var label string
+	if v, ok := vars["label"]; ok {
+		if len(v) > 1 {
+			http.Error(w, "label can only be given once", http.StatusBadRequest)
+			return
+		}
+		label = v[0]
+	}
+	ed, err := s.createEditor(req, mux.Vars(req)["id"], label)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, ed)
+}
+
+func (s *Server) createEditor(req *http.Request, bufferID, label string) (Editor, error) {
+	s.Lock()
+	defer s.Unlock()
+	buf, ok := s.buffers[bufferID]
+	if !ok {
+		return Editor{}, ErrNotFound
+	}
+	if err := s.checkAccess(req, buf.ID, ReadAccess); err != nil {
+		return Editor{}, err
+	}
 	buf.Lock()
+	defer buf.Unlock()
 
 	id := strconv.Itoa(s.nextID)
 	s.nextID++
@@ -328,46 +908,61 @@ func (s *Server) newEditor(w http.ResponseWriter, req *http.Request) {
 			ID:         id,
 			Path:       path.Join("/", "editor", id),
 			BufferPath: buf.Path,
+			Label:      label,
 		},
+		text:   buf.text,
 		buffer: buf,
-		Buffer: buf.buffer,
 		marks:  make(map[rune]edit.Span),
 	}
 	s.editors[ed.ID] = ed
 	buf.editors[ed.ID] = ed
 	buf.Editors = append(buf.Editors, ed.Editor)
-
-	buf.Unlock()
-	s.Unlock()
-
-	respond(w, ed.Editor)
+	buf.sendPresence(ed.presence())
+	return ed.Editor, nil
 }
 
 func (s *Server) editorInfo(w http.ResponseWriter, req *http.Request) {
+	ed, err := s.getEditor(req, mux.Vars(req)["id"])
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, ed)
+}
+
+func (s *Server) getEditor(req *http.Request, id string) (Editor, error) {
 	s.RLock()
-	ed, ok := s.editors[mux.Vars(req)["id"]]
+	defer s.RUnlock()
+	ed, ok := s.editors[id]
 	if !ok {
-		s.RUnlock()
-		http.NotFound(w, req)
-		return
+		return Editor{}, ErrNotFound
+	}
+	if err := s.checkAccess(req, ed.buffer.ID, ReadAccess); err != nil {
+		return Editor{}, err
 	}
 	ed.buffer.RLock()
-	info := ed.Editor
-	ed.buffer.RUnlock()
-	s.RUnlock()
-
-	respond(w, info)
+	defer ed.buffer.RUnlock()
+	return ed.Editor, nil
 }
 
 func (s *Server) closeEditor(w http.ResponseWriter, req *http.Request) {
+	if err := s.deleteEditor(req, mux.Vars(req)["id"]); err != nil {
+		httpError(w, req, err)
+	}
+}
+
+func (s *Server) deleteEditor(req *http.Request, id string) error {
 	s.Lock()
-	ed, ok := s.editors[mux.Vars(req)["id"]]
+	defer s.Unlock()
+	ed, ok := s.editors[id]
 	if !ok {
-		s.Unlock()
-		http.NotFound(w, req)
-		return
+		return ErrNotFound
+	}
+	if err := s.checkAccess(req, ed.buffer.ID, ReadAccess); err != nil {
+		return err
 	}
 	ed.buffer.Lock()
+	defer ed.buffer.Unlock()
 
 	delete(s.editors, ed.ID)
 	delete(ed.buffer.editors, ed.ID)
@@ -378,23 +973,13 @@ func (s *Server) closeEditor(w http.ResponseWriter, req *http.Request) {
 			break
 		}
 	}
-
-	ed.buffer.Unlock()
-	s.Unlock()
+	p := ed.presence()
+	p.Closed = true
+	ed.buffer.sendPresence(p)
+	return nil
 }
 
 func (s *Server) read(w http.ResponseWriter, req *http.Request) {
-	s.Lock()
-	ed, ok := s.editors[mux.Vars(req)["id"]]
-	if !ok {
-		s.Unlock()
-		http.NotFound(w, req)
-		return
-	}
-	ed.buffer.Lock()
-	defer ed.buffer.Unlock()
-	s.Unlock()
-
 	addr := edit.All
 	vars, err := url.ParseQuery(req.URL.RawQuery)
 	if err != nil {
@@ -417,40 +1002,106 @@ func (s *Server) read(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
-	span, err := addr.Where(ed.Buffer)
+	text, err := s.readText(req, mux.Vars(req)["id"], addr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		httpError(w, req, err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain")
-	if _, err = io.Copy(w, ed.Buffer.Reader(span)); err != nil {
+	if _, err = w.Write(text); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+func (s *Server) readText(req *http.Request, id string, addr edit.Address) ([]byte, error) {
+	s.Lock()
+	ed, ok := s.editors[id]
+	if !ok {
+		s.Unlock()
+		return nil, ErrNotFound
+	}
+	if err := s.checkAccess(req, ed.buffer.ID, ReadAccess); err != nil {
+		s.Unlock()
+		return nil, err
+	}
+	ed.buffer.Lock()
+	defer ed.buffer.Unlock()
+	s.Unlock()
+
+	span, err := addr.Where(ed)
+	if err != nil {
+		return nil, statusError{http.StatusRequestedRangeNotSatisfiable, err}
+	}
+	return ioutil.ReadAll(ed.Reader(span))
+}
+
 func (s *Server) edit(w http.ResponseWriter, req *http.Request) {
+	vars, err := url.ParseQuery(req.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	seq := -1
+	if v, ok := vars["sequence"]; ok {
+		if len(v) > 1 {
+			http.Error(w, "sequence can only be given once", http.StatusBadRequest)
+			return
+		}
+		if seq, err = strconv.Atoi(v[0]); err != nil || seq < 0 {
+			http.Error(w, "bad sequence: "+v[0], http.StatusBadRequest)
+			return
+		}
+	}
 	var edits []editRequest
 	if err := json.NewDecoder(req.Body).Decode(&edits); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	es := make([]edit.Edit, len(edits))
+	for i, e := range edits {
+		es[i] = e.Edit
+	}
+	results, err := s.do(req, mux.Vars(req)["id"], seq, es)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, results)
+}
 
+// Do performs edits with an editor.
+// If seq is non-negative, the edits are only performed
+// if the text of the editor's buffer has not changed since that Sequence;
+// otherwise ErrConflict is returned.
+func (s *Server) do(req *http.Request, id string, seq int, edits []edit.Edit) ([]EditResult, error) {
 	s.Lock()
-	ed, ok := s.editors[mux.Vars(req)["id"]]
+	ed, ok := s.editors[id]
 	if !ok {
 		s.Unlock()
-		http.NotFound(w, req)
-		return
+		return nil, ErrNotFound
+	}
+	var edr edit.Editor = ed
+	switch s.access(req, ed.buffer.ID) {
+	case NoAccess:
+		s.Unlock()
+		return nil, ErrForbidden
+	case ReadAccess:
+		edr = readOnlyEditor{ed}
 	}
 	ed.buffer.Lock()
+	defer ed.buffer.Unlock()
 	s.Unlock()
 
+	if seq >= 0 && (seq > ed.buffer.Sequence || ed.buffer.changeSeq > seq) {
+		return nil, ErrConflict
+	}
+	defer ed.buffer.sendMovedPresence(ed.buffer.dots())
 	var results []EditResult
 	print := bytes.NewBuffer(nil)
 	for _, e := range edits {
 		print.Reset()
-		err := e.Do(ed, print)
+		err := e.Do(edr, print)
 		ed.buffer.Sequence++
 		result := EditResult{
 			Sequence: ed.buffer.Sequence,
@@ -461,38 +1112,420 @@ func (s *Server) edit(w http.ResponseWriter, req *http.Request) {
 		}
 		results = append(results, result)
 	}
+	return results, nil
+}
 
-	ed.buffer.Unlock()
+func (s *Server) transaction(w http.ResponseWriter, req *http.Request) {
+	var reqs []editorEditsRequest
+	if err := json.NewDecoder(req.Body).Decode(&reqs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	txn := make([]EditorEdits, len(reqs))
+	for i, r := range reqs {
+		txn[i].EditorPath = r.EditorPath
+		for _, e := range r.Edits {
+			txn[i].Edits = append(txn[i].Edits, e.Edit)
+		}
+	}
+	result, err := s.doTransaction(req, txn)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, result)
+}
 
-	respond(w, results)
+func (s *Server) doTransaction(req *http.Request, txn []EditorEdits) (TransactionResult, error) {
+	s.Lock()
+	eds := make([]*editor, len(txn))
+	bufs := make(map[string]*buffer)
+	for i, t := range txn {
+		var ed *editor
+		if id, ok := pathID(t.EditorPath, "editor"); ok {
+			ed = s.editors[id]
+		}
+		if ed == nil {
+			s.Unlock()
+			return TransactionResult{}, ErrNotFound
+		}
+		if err := s.checkAccess(req, ed.buffer.ID, WriteAccess); err != nil {
+			s.Unlock()
+			return TransactionResult{}, err
+		}
+		if _, ok := ed.buffer.text.(checkpointer); !ok {
+			s.Unlock()
+			return TransactionResult{}, statusError{
+				status: http.StatusBadRequest,
+				err:    errors.New("buffer " + ed.buffer.ID + " cannot be rolled back"),
+			}
+		}
+		eds[i] = ed
+		bufs[ed.buffer.ID] = ed.buffer
+	}
+	// Lock the buffers in a consistent order to avoid deadlock
+	// with concurrent transactions.
+	var ids []string
+	for id := range bufs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		bufs[id].Lock()
+	}
+	s.Unlock()
+	defer func() {
+		for _, id := range ids {
+			bufs[id].Unlock()
+		}
+	}()
+
+	for _, buf := range bufs {
+		defer buf.sendMovedPresence(buf.dots())
+	}
+	modified := make(map[*buffer]bool)
+	marks := make(map[*editor]map[rune]edit.Span)
+	for _, buf := range bufs {
+		buf.text.(checkpointer).Checkpoint()
+		buf.held = &heldChanges{}
+		modified[buf] = buf.Modified
+		for _, ed := range buf.editors {
+			marks[ed] = make(map[rune]edit.Span, len(ed.marks))
+			for m, s := range ed.marks {
+				marks[ed][m] = s
+			}
+		}
+	}
+
+	result := TransactionResult{Committed: true}
+	print := bytes.NewBuffer(nil)
+loop:
+	for i, t := range txn {
+		result.Results = append(result.Results, nil)
+		for _, e := range t.Edits {
+			print.Reset()
+			err := e.Do(txnEditor{eds[i]}, print)
+			eds[i].buffer.Sequence++
+			r := EditResult{
+				Sequence: eds[i].buffer.Sequence,
+				Print:    print.String(),
+			}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			result.Results[i] = append(result.Results[i], r)
+			if err != nil {
+				result.Committed = false
+				break loop
+			}
+		}
+	}
+
+	var err error
+	for _, buf := range bufs {
+		held := buf.held
+		buf.held = nil
+		if !result.Committed {
+			if e := buf.text.(checkpointer).Rollback(); e != nil && err == nil {
+				err = e
+			}
+			// The rolled back changes are not reported.
+			buf.applied = nil
+			continue
+		}
+		buf.text.(checkpointer).Commit()
+		for _, e := range held.history {
+			buf.addHistory(e)
+			for _, c := range buf.watchers {
+				send(c, e.ChangeList)
+			}
+		}
+		for _, rec := range held.journal {
+			if e := buf.journal(rec); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	if !result.Committed {
+		for ed, ms := range marks {
+			ed.marks = ms
+		}
+		for buf, m := range modified {
+			buf.Modified = m
+		}
+	}
+	return result, err
+}
+
+// PathID returns the ID from a resource path of the form /<dir>/<ID>.
+func pathID(p, dir string) (string, bool) {
+	d, id := path.Split(p)
+	return id, path.Clean(d) == path.Join("/", dir)
+}
+
+// A statusError is an error with an HTTP status code.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (err statusError) Error() string { return err.err.Error() }
+
+// HTTPError writes an error response for an error.
+func httpError(w http.ResponseWriter, req *http.Request, err error) {
+	switch err := err.(type) {
+	case statusError:
+		http.Error(w, err.Error(), err.status)
+	default:
+		switch err {
+		case ErrNotFound:
+			http.NotFound(w, req)
+		case ErrForbidden:
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		case ErrGone:
+			http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+		case ErrConflict:
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// A checkpointer is a text that can be rolled back
+// to a saved checkpoint, as an *edit.Buffer can.
+type checkpointer interface {
+	Checkpoint()
+	Commit()
+	Rollback() error
+}
+
+// HeldChanges are the ChangeLists and journal records
+// of a buffer in a transaction.
+// They are held until the transaction commits,
+// and are discarded if it is rolled back.
+type heldChanges struct {
+	history []HistoryEntry
+	journal []journalRecord
 }
 
+// A txnEditor is an editor that disallows Undo and Redo.
+type txnEditor struct{ *editor }
+
+func (txnEditor) Undo() error { return errors.New("undo is not allowed in a transaction") }
+
+func (txnEditor) Redo() error { return errors.New("redo is not allowed in a transaction") }
+
+// errReadOnly is returned when attempting to modify a buffer
+// to which the client has only ReadAccess.
+var errReadOnly = errors.New("read-only access")
+
+// A readOnlyEditor is an editor that cannot modify its buffer.
+type readOnlyEditor struct{ *editor }
+
+func (readOnlyEditor) Change(edit.Span, io.Reader) (int64, error) { return 0, errReadOnly }
+
+// Apply is a no-op, since no changes can be staged.
+func (readOnlyEditor) Apply() error { return nil }
+
+func (readOnlyEditor) Undo() error { return errReadOnly }
+
+func (readOnlyEditor) Redo() error { return errReadOnly }
+
+// ChangeHistory is the number of recent ChangeLists kept by each buffer
+// for resuming change streams.
+// It is a variable for testing.
+var changeHistory = 1000
+
 type buffer struct {
 	sync.RWMutex
 	Buffer
-	buffer *edit.Buffer
+	text edit.Editor
+
+	// Journal, if non-nil, writes a record to the server's Journal.
+	journal func(journalRecord) error
 
 	editors map[string]*editor
 
 	watchers []chan []ChangeList
+	// Presence is the set of watchers that are sent Presence.
+	presence map[chan []ChangeList]bool
 	done     chan struct{}
+
+	// History holds the most recent ChangeLists, oldest first.
+	// All ChangeLists with Sequence greater than historySeq are in history.
+	history    []HistoryEntry
+	historySeq int
+
+	// ChangeSeq is the Sequence of the most recent edit
+	// that changed the text.
+	changeSeq int
+
+	// ReportsChanges is whether the text reports its changes
+	// with an OnChange method, as an *edit.Buffer does.
+	// If so, applied holds the changes reported
+	// since the last Apply, Undo, or Redo.
+	// If not, untrackedSeq is the Sequence of the most recent Undo or Redo,
+	// whose changes are not in the history.
+	reportsChanges bool
+	applied        []edit.AppliedChange
+	untrackedSeq   int
+
+	// Held, if non-nil, holds the changes
+	// made during a transaction in progress.
+	held *heldChanges
+
 	// watcherRemoved is for testing purposes.
 	// If non-nil, an empty struct is sent when a watcher is removed.
 	watcherRemoved chan struct{}
 }
 
+// Info returns the buffer's Buffer.
+// Must be called with the read Lock held.
+func (buf *buffer) info() Buffer {
+	info := buf.Buffer
+	info.Size = buf.text.Size()
+	if buf.Editors != nil {
+		info.Editors = append([]Editor{}, buf.Editors...)
+	}
+	return info
+}
+
+// Update updates the buffer's metadata.
+// Must be called with the write Lock held.
+func (buf *buffer) update(update BufferUpdate) {
+	if update.Name != nil {
+		buf.Name = *update.Name
+	}
+	if update.Modified != nil {
+		buf.Modified = *update.Modified
+	}
+}
+
+// ChangesSince returns the ChangeLists with Sequence greater than seq.
+// Must be called with the read Lock held.
+func (buf *buffer) changesSince(seq int) ([]ChangeList, error) {
+	switch {
+	case seq > buf.Sequence:
+		err := errors.New("since is greater than the buffer sequence")
+		return nil, statusError{status: http.StatusBadRequest, err: err}
+	case seq < buf.historySeq:
+		return nil, ErrGone
+	}
+	i := sort.Search(len(buf.history), func(i int) bool {
+		return buf.history[i].Sequence > seq
+	})
+	cls := make([]ChangeList, 0, len(buf.history)-i)
+	for _, e := range buf.history[i:] {
+		cls = append(cls, e.ChangeList)
+	}
+	return cls, nil
+}
+
+// AddHistory adds a HistoryEntry to the history,
+// dropping the oldest if the history is full,
+// and records its Sequence as that of the most recent change.
+// Must be called with the write Lock held.
+func (buf *buffer) addHistory(e HistoryEntry) {
+	buf.changeSeq = e.Sequence
+	buf.history = append(buf.history, e)
+	if n := len(buf.history) - changeHistory; n > 0 {
+		buf.historySeq = buf.history[n-1].Sequence
+		buf.history = append(buf.history[:0], buf.history[n:]...)
+	}
+}
+
+// Changed adds a ChangeList to the history
+// and sends it to the watchers.
+// During a transaction, the ChangeList is held
+// until the transaction commits.
+// Must be called with the write Lock held.
+func (buf *buffer) changed(cl ChangeList) {
+	e := HistoryEntry{ChangeList: cl, Time: time.Now()}
+	if buf.held != nil {
+		buf.held.history = append(buf.held.history, e)
+		return
+	}
+	buf.addHistory(e)
+	for _, c := range buf.watchers {
+		send(c, cl)
+	}
+}
+
+// Dots returns the dots of the buffer's editors.
+// Must be called with the read Lock held.
+func (buf *buffer) dots() map[*editor]edit.Span {
+	dots := make(map[*editor]edit.Span, len(buf.editors))
+	for _, ed := range buf.editors {
+		dots[ed] = ed.marks['.']
+	}
+	return dots
+}
+
+// SendMovedPresence sends the Presence of each editor
+// whose dot differs from its dot in the given dots,
+// to the watchers that are sent Presence.
+// Must be called with the write Lock held.
+func (buf *buffer) sendMovedPresence(dots map[*editor]edit.Span) {
+	if len(buf.presence) == 0 {
+		return
+	}
+	var ps []Presence
+	for _, ed := range buf.editors {
+		if dot, ok := dots[ed]; !ok || dot != ed.marks['.'] {
+			ps = append(ps, ed.presence())
+		}
+	}
+	sort.Sort(presenceByPath(ps))
+	buf.sendPresence(ps...)
+}
+
+// SendPresence sends Presence to the watchers that are sent Presence.
+// Must be called with the write Lock held.
+func (buf *buffer) sendPresence(ps ...Presence) {
+	if len(ps) == 0 {
+		return
+	}
+	cl := ChangeList{Sequence: buf.Sequence, Presence: ps}
+	for c := range buf.presence {
+		send(c, cl)
+	}
+}
+
+// Unwatch removes a watcher added by Server.watch.
+func (buf *buffer) unwatch(changes chan []ChangeList) {
+	buf.Lock()
+	defer buf.Unlock()
+	for i := range buf.watchers {
+		if buf.watchers[i] == changes {
+			buf.watchers = append(buf.watchers[:i], buf.watchers[i+1:]...)
+			delete(buf.presence, changes)
+			if buf.watcherRemoved != nil {
+				buf.watcherRemoved <- struct{}{}
+			}
+			break
+		}
+	}
+}
+
 // Must be called with the write Lock held.
 func (buf *buffer) close() error {
 	close(buf.done)
-	return buf.buffer.Close()
+	if c, ok := buf.text.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 type editor struct {
 	Editor
-	*edit.Buffer
+	text    edit.Editor
 	buffer  *buffer
 	marks   map[rune]edit.Span
 	pending []Change
+	// Journaled are the pending changes with their full text.
+	// It is only set if the buffer is journaled.
+	journaled []journalChange
 }
 
 type change struct {
@@ -500,6 +1533,105 @@ type change struct {
 	size int64
 }
 
+func (ed *editor) Size() int64 { return ed.text.Size() }
+
+func (ed *editor) RuneReader(s edit.Span) io.RuneReader { return ed.text.RuneReader(s) }
+
+func (ed *editor) Reader(s edit.Span) io.Reader { return ed.text.Reader(s) }
+
+func (ed *editor) Undo() error {
+	err := ed.text.Undo()
+	ed.undone()
+	if err != nil {
+		return err
+	}
+	return ed.writeJournal(journalRecord{Op: "undo", Buffer: ed.buffer.ID})
+}
+
+func (ed *editor) Redo() error {
+	err := ed.text.Redo()
+	ed.undone()
+	if err != nil {
+		return err
+	}
+	return ed.writeJournal(journalRecord{Op: "redo", Buffer: ed.buffer.ID})
+}
+
+// Undone updates the marks of the editors,
+// adds a ChangeList to the history,
+// and notifies watchers of the changes made by an Undo or Redo.
+// Dot of the editor is set to cover the changes.
+//
+// The changes are reported by the text in the order they were made,
+// each in terms of the text just after the previous change.
+// Since they were staged by a single Apply, they are in ascending order,
+// so they are shifted to be in terms of the text before any were made,
+// as expected of a ChangeList.
+func (ed *editor) undone() {
+	if !ed.buffer.reportsChanges {
+		ed.buffer.untrackedSeq = ed.buffer.Sequence + 1
+		ed.buffer.changeSeq = ed.buffer.Sequence + 1
+		return
+	}
+	applied := ed.buffer.applied
+	ed.buffer.applied = nil
+	if len(applied) == 0 {
+		return
+	}
+	var delta int64
+	dot := edit.Span{applied[0].Span[0], applied[0].Span[0]}
+	changes := make([]Change, len(applied))
+	for i, c := range applied {
+		for _, e := range ed.buffer.editors {
+			for m, s := range e.marks {
+				e.marks[m] = s.Update(c.Span, c.NewSize)
+			}
+		}
+		dot[1] = c.Span[0] + c.NewSize
+		changes[i] = Change{
+			Span:    edit.Span{c.Span[0] - delta, c.Span[1] - delta},
+			NewSize: c.NewSize,
+		}
+		delta += c.NewSize - c.Span.Size()
+	}
+	for i, c := range applied {
+		changes[i].Text = inlineText(ed.text, edit.Span{c.Span[0], c.Span[0] + c.NewSize})
+	}
+	ed.marks['.'] = dot
+	ed.buffer.Modified = true
+	cl := ChangeList{
+		Sequence:   ed.buffer.Sequence + 1,
+		Changes:    changes,
+		EditorPath: ed.Path,
+		Label:      ed.Label,
+	}
+	ed.buffer.changed(cl)
+}
+
+// InlineText returns the text of a Span
+// if it is not empty and at most MaxInline bytes.
+func inlineText(text edit.Text, s edit.Span) []byte {
+	if s.Size() == 0 || s.Size() > MaxInline {
+		return nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(text.Reader(s), MaxInline+1))
+	if err != nil || len(data) > MaxInline {
+		return nil
+	}
+	return data
+}
+
+func (ed *editor) writeJournal(rec journalRecord) error {
+	switch {
+	case ed.buffer.journal == nil:
+		return nil
+	case ed.buffer.held != nil:
+		ed.buffer.held.journal = append(ed.buffer.held.journal, rec)
+		return nil
+	}
+	return ed.buffer.journal(rec)
+}
+
 func (ed *editor) Mark(m rune) edit.Span { return ed.marks[m] }
 
 func (ed *editor) SetMark(m rune, s edit.Span) error {
@@ -514,10 +1646,15 @@ type changeReader struct {
 	r      io.Reader
 	nbytes int
 	text   []byte
+	// If all is non-nil, all text read is appended to it.
+	all []byte
 }
 
 func (cr *changeReader) Read(d []byte) (int, error) {
 	n, err := cr.r.Read(d)
+	if cr.all != nil {
+		cr.all = append(cr.all, d[:n]...)
+	}
 	m := MaxInline - len(cr.text)
 	if m > n {
 		m = n
@@ -529,19 +1666,32 @@ func (cr *changeReader) Read(d []byte) (int, error) {
 
 func (ed *editor) Change(s edit.Span, r io.Reader) (int64, error) {
 	cr := changeReader{r: r}
-	n, err := ed.Buffer.Change(s, &cr)
-	if err == nil {
-		c := Change{Span: s, NewSize: n}
-		if 0 < cr.nbytes && cr.nbytes <= MaxInline {
-			c.Text = cr.text
-		}
-		ed.pending = append(ed.pending, c)
+	if ed.buffer.journal != nil {
+		cr.all = []byte{}
 	}
-	return n, err
+	n, err := ed.text.Change(s, &cr)
+	if err != nil {
+		// Previously staged changes are canceled.
+		ed.pending = nil
+		ed.journaled = nil
+		return n, err
+	}
+	if cr.all != nil {
+		ed.journaled = append(ed.journaled, journalChange{Span: s, Text: cr.all})
+	}
+	c := Change{Span: s, NewSize: n}
+	if 0 < cr.nbytes && cr.nbytes <= MaxInline {
+		c.Text = cr.text
+	}
+	ed.pending = append(ed.pending, c)
+	return n, nil
 }
 
 func (ed *editor) Apply() error {
-	if err := ed.Buffer.Apply(); err != nil {
+	if err := ed.text.Apply(); err != nil {
+		ed.buffer.applied = nil
+		ed.pending = nil
+		ed.journaled = nil
 		return err
 	}
 	for _, c := range ed.pending {
@@ -561,20 +1711,44 @@ func (ed *editor) Apply() error {
 			}
 		}
 	}
+	// The changes of an Apply are tracked by Change.
+	ed.buffer.applied = nil
 	if len(ed.pending) == 0 {
 		return nil
 	}
+	ed.buffer.Modified = true
+	// Watchers are notified even if journaling fails,
+	// since the changes have been applied.
+	err := ed.writeJournal(journalRecord{Op: "change", Buffer: ed.buffer.ID, Changes: ed.journaled})
+	ed.journaled = nil
 	cl := ChangeList{
-		Sequence: ed.buffer.Sequence + 1,
-		Changes:  ed.pending,
-	}
-	for _, c := range ed.buffer.watchers {
-		select {
-		case cls := <-c:
-			c <- append(cls, cl)
-		case c <- []ChangeList{cl}:
-		}
+		Sequence:   ed.buffer.Sequence + 1,
+		Changes:    ed.pending,
+		EditorPath: ed.Path,
+		Label:      ed.Label,
 	}
+	ed.buffer.changed(cl)
 	ed.pending = nil
-	return nil
+	return err
+}
+
+// Presence returns the editor's Presence.
+func (ed *editor) presence() Presence {
+	return Presence{EditorPath: ed.Path, Label: ed.Label, Dot: ed.marks['.']}
+}
+
+type presenceByPath []Presence
+
+func (ps presenceByPath) Len() int           { return len(ps) }
+func (ps presenceByPath) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+func (ps presenceByPath) Less(i, j int) bool { return ps[i].EditorPath < ps[j].EditorPath }
+
+// Send sends a ChangeList to a watcher,
+// coalescing it with any ChangeLists not yet received.
+func send(c chan []ChangeList, cl ChangeList) {
+	select {
+	case cls := <-c:
+		c <- append(cls, cl)
+	case c <- []ChangeList{cl}:
+	}
 }
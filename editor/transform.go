@@ -0,0 +1,109 @@
+// Copyright © 2016, The T Authors.
+
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) transform(w http.ResponseWriter, req *http.Request) {
+	var treq TransformRequest
+	if err := json.NewDecoder(req.Body).Decode(&treq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := s.doTransform(req, mux.Vars(req)["id"], treq)
+	if err != nil {
+		httpError(w, req, err)
+		return
+	}
+	respond(w, result)
+}
+
+// DoTransform makes the changes of a TransformRequest with an editor,
+// transforming their Spans against the changes made since the request's Sequence.
+func (s *Server) doTransform(req *http.Request, id string, treq TransformRequest) (TransformResult, error) {
+	s.Lock()
+	ed, ok := s.editors[id]
+	if !ok {
+		s.Unlock()
+		return TransformResult{}, ErrNotFound
+	}
+	if err := s.checkAccess(req, ed.buffer.ID, WriteAccess); err != nil {
+		s.Unlock()
+		return TransformResult{}, err
+	}
+	ed.buffer.Lock()
+	defer ed.buffer.Unlock()
+	s.Unlock()
+
+	switch {
+	case treq.Sequence < 0:
+		err := errors.New("negative sequence")
+		return TransformResult{}, statusError{status: http.StatusBadRequest, err: err}
+	case treq.Sequence < ed.buffer.untrackedSeq:
+		// The changes of an Undo or Redo since the Sequence are not known.
+		return TransformResult{}, ErrConflict
+	}
+	defer ed.buffer.sendMovedPresence(ed.buffer.dots())
+	cls, err := ed.buffer.changesSince(treq.Sequence)
+	if err != nil {
+		return TransformResult{}, err
+	}
+	size := ed.Size()
+	spans := make([]edit.Span, len(treq.Changes))
+	var prev int64
+	for i, c := range treq.Changes {
+		if c.Span[0] > c.Span[1] || c.Span[0] < prev {
+			err := errors.New("changes out of sequence")
+			return TransformResult{}, statusError{status: http.StatusBadRequest, err: err}
+		}
+		prev = c.Span[1]
+		spans[i] = transformSpan(c.Span, cls)
+		if spans[i][0] < 0 || spans[i][1] > size {
+			return TransformResult{}, statusError{http.StatusRequestedRangeNotSatisfiable, edit.RangeError(size)}
+		}
+	}
+
+	for i, c := range treq.Changes {
+		if _, err := ed.Change(spans[i], strings.NewReader(c.Text)); err != nil {
+			return TransformResult{}, err
+		}
+	}
+	if err := ed.Apply(); err != nil {
+		return TransformResult{}, err
+	}
+	ed.buffer.Sequence++
+
+	// The changed Spans are in terms of the text before the Apply.
+	// Shift them to account for the size change of preceding changes.
+	var delta int64
+	for i, c := range treq.Changes {
+		n := int64(len([]rune(c.Text)))
+		spans[i][0] += delta
+		delta += n - spans[i].Size()
+		spans[i][1] = spans[i][0] + n
+	}
+	return TransformResult{Sequence: ed.buffer.Sequence, Spans: spans}, nil
+}
+
+// TransformSpan returns the Span that identifies the same text as s
+// after the changes of the ChangeLists.
+//
+// The Spans of the Changes in a ChangeList are in terms of the text
+// before any of them were made, so they are applied last to first.
+func transformSpan(s edit.Span, cls []ChangeList) edit.Span {
+	for _, cl := range cls {
+		for i := len(cl.Changes) - 1; i >= 0; i-- {
+			c := cl.Changes[i]
+			s = s.Update(c.Span, c.NewSize)
+		}
+	}
+	return s
+}
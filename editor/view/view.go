@@ -34,6 +34,7 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 
@@ -74,6 +75,15 @@ type View struct {
 	n     int
 	text  []byte
 	marks []Mark
+	size  int64
+	line  int64
+	// Others is the Presence of the other labeled editors
+	// of the buffer, sorted by EditorPath.
+	others []editor.Presence
+	// Closed is whether Close has been called.
+	closed bool
+	// Err is the error that ended the View, if any.
+	err error
 }
 
 // A Mark is a mark tracked by a View.
@@ -97,7 +107,20 @@ type doResponse struct {
 // New returns a new View for a buffer.
 // The new view tracks the empty string at line 0 and the given marks.
 func New(bufferURL *url.URL, markRunes ...rune) (*View, error) {
-	ed, err := editor.NewEditor(bufferURL)
+	return NewLabeled(bufferURL, "", markRunes...)
+}
+
+// NewLabeled is like New,
+// but the View's editor has the given Label.
+// If the label is empty, the editor has no Label.
+func NewLabeled(bufferURL *url.URL, label string, markRunes ...rune) (*View, error) {
+	var ed editor.Editor
+	var err error
+	if label == "" {
+		ed, err = editor.NewEditor(bufferURL)
+	} else {
+		ed, err = editor.NewLabeledEditor(bufferURL, label)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +132,7 @@ func New(bufferURL *url.URL, markRunes ...rune) (*View, error) {
 	changesURL := editorURL
 	changesURL.Path = path.Join(bufferURL.Path, "changes")
 	changesURL.Scheme = "ws"
+	changesURL.RawQuery = "presence=true"
 	changes, err := editor.Changes(&changesURL)
 	if err != nil {
 		editor.Close(&editorURL)
@@ -157,6 +181,9 @@ func New(bufferURL *url.URL, markRunes ...rune) (*View, error) {
 
 // Close closes the view, and deletes its editor.
 func (v *View) Close() error {
+	v.mu.Lock()
+	v.closed = true
+	v.mu.Unlock()
 	close(v.do)
 	err := v.changes.Close()
 	editorErr := editor.Close(v.editorURL)
@@ -166,6 +193,27 @@ func (v *View) Close() error {
 	return err
 }
 
+// Err returns the error that caused the View to stop tracking its buffer,
+// for example, if the connection to the editor server was lost.
+// Err returns nil if the View is still open or was closed by Close.
+// It is useful after Notify is closed.
+func (v *View) Err() error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.err
+}
+
+// fail records the error that ended the View,
+// unless the View was closed by Close
+// or an error was already recorded.
+func (v *View) fail(err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.closed && v.err == nil {
+		v.err = err
+	}
+}
+
 // View calls the function with the current text and marks.
 // The text and marks will not change until f returns.
 func (v *View) View(f func(text []byte, marks []Mark)) {
@@ -174,6 +222,34 @@ func (v *View) View(f func(text []byte, marks []Mark)) {
 	v.mu.RUnlock()
 }
 
+// Others returns the Presence of the other editors of the buffer
+// as of the most recent update of the View,
+// sorted by EditorPath.
+// Editors without a Label are omitted;
+// they are typically used by programs, not people.
+// The View sends on Notify when the Presence of the others changes.
+func (v *View) Others() []editor.Presence {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return append([]editor.Presence{}, v.others...)
+}
+
+// Size returns the size of the buffer in runes
+// as of the most recent update of the View.
+func (v *View) Size() int64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.size
+}
+
+// Line returns the 1-based line number of the first line tracked by the View
+// as of the most recent update of the View.
+func (v *View) Line() int64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.line
+}
+
 // Resize resizes the View to track the given number of lines,
 // and returns whether the size actually changed.
 func (v *View) Resize(nLines int) bool {
@@ -228,6 +304,18 @@ func (v *View) Do(edits ...edit.Edit) ([]editor.EditResult, error) {
 // The EditResults are silently discarded.
 func (v *View) DoAsync(edits ...edit.Edit) { v.do <- doRequest{edits: edits} }
 
+// DoFunc is like DoAsync, but the results are not discarded;
+// f is called with them in a new goroutine once the edits are performed.
+// The edits are performed in order with those of Do and DoAsync.
+func (v *View) DoFunc(f func([]editor.EditResult, error), edits ...edit.Edit) {
+	result := make(chan doResponse)
+	v.do <- doRequest{edits: edits, result: result}
+	go func() {
+		r := <-result
+		f(r.results, r.error)
+	}()
+}
+
 func (v *View) run(do <-chan doRequest, Notify chan<- struct{}) {
 	changes := make(chan editor.ChangeList)
 	go func(changes chan<- editor.ChangeList) {
@@ -235,7 +323,7 @@ func (v *View) run(do <-chan doRequest, Notify chan<- struct{}) {
 		for {
 			cl, err := v.changes.Next()
 			if err != nil {
-				// TODO(eaburns): return error on Close.
+				v.fail(err)
 				return
 			}
 			changes <- cl
@@ -258,25 +346,66 @@ func (v *View) run(do <-chan doRequest, Notify chan<- struct{}) {
 				return
 			}
 			if err := v.edit(vd, Notify); err != nil {
-				// TODO(eaburns): return error on Close.
+				v.fail(err)
 				return
 			}
 		case cl, ok := <-changes:
 			if !ok {
 				return
 			}
-			if v.seq >= cl.Sequence {
+			if len(cl.Presence) > 0 {
+				v.updatePresence(cl.Presence, Notify)
+			}
+			if v.seq >= cl.Sequence || len(cl.Changes) == 0 {
 				break
 			}
 			// TODO(eaburns): this does a complete, blocking refresh.
 			// Don't require a complete refresh with every change.
 			if err := v.edit(doRequest{}, Notify); err != nil {
+				v.fail(err)
 				return
 			}
 		}
 	}
 }
 
+// UpdatePresence updates the Presence of the other editors
+// and notifies if it changed.
+func (v *View) updatePresence(ps []editor.Presence, Notify chan<- struct{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	changed := false
+	for _, p := range ps {
+		if p.Label == "" || p.EditorPath == v.editorURL.Path {
+			continue
+		}
+		i := sort.Search(len(v.others), func(i int) bool {
+			return v.others[i].EditorPath >= p.EditorPath
+		})
+		found := i < len(v.others) && v.others[i].EditorPath == p.EditorPath
+		switch {
+		case p.Closed && found:
+			v.others = append(v.others[:i], v.others[i+1:]...)
+		case p.Closed:
+			continue
+		case found:
+			v.others[i] = p
+		default:
+			v.others = append(v.others, editor.Presence{})
+			copy(v.others[i+1:], v.others[i:])
+			v.others[i] = p
+		}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	select {
+	case Notify <- struct{}{}:
+	default:
+	}
+}
+
 var (
 	saveDot    = edit.Set(edit.Dot, TmpMark)
 	restoreDot = edit.Set(edit.Mark('1'), '.')
@@ -292,8 +421,10 @@ func (v *View) edit(vd doRequest, Notify chan<- struct{}) error {
 		}
 		prints = append(prints, edit.Where(edit.Mark(n)))
 	}
+	prints = append(prints, edit.Where(edit.End))
 	// Use the start of the mark's line, regardless of where it ends up in the line.
 	start := edit.Mark(ViewMark).Minus(edit.Line(0)).Minus(edit.Rune(0))
+	prints = append(prints, edit.WhereLine(start))
 	end := start.Plus(edit.Clamp(edit.Line(v.n)))
 	win := start.To(end)
 	prints = append(prints, edit.Print(win))
@@ -318,7 +449,7 @@ func (v *View) edit(vd doRequest, Notify chan<- struct{}) error {
 	printed := strings.SplitN(update.Print, "\n", len(prints))
 	if len(printed) != len(prints) || update.Error != "" {
 		panic(fmt.Sprintf("bad update: len(%v)=%d want %d, Error=%v",
-			printed, len(printed), len(v.marks)+1, update.Error))
+			printed, len(printed), len(prints), update.Error))
 	}
 	for i := range v.marks {
 		m := &v.marks[i]
@@ -329,6 +460,14 @@ func (v *View) edit(vd doRequest, Notify chan<- struct{}) error {
 			panic("failed to scan address: " + printed[i])
 		}
 	}
+	sizeAddr := printed[len(v.marks)]
+	if n, err := fmt.Sscanf(sizeAddr, "#%d", &v.size); n != 1 || err != nil {
+		panic("failed to scan address: " + sizeAddr)
+	}
+	line := printed[len(v.marks)+1]
+	if n, err := fmt.Sscanf(line, "%d", &v.line); n != 1 || err != nil {
+		panic("failed to scan line: " + line)
+	}
 	v.text = []byte(printed[len(printed)-1])
 	v.seq = update.Sequence
 
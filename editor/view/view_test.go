@@ -33,6 +33,58 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestSize(t *testing.T) {
+	bufferURL, close := testBuffer()
+	defer close()
+	setText(bufferURL, "1\n2\n3\n")
+
+	v, err := New(bufferURL)
+	if err != nil {
+		t.Fatalf("New(%q)=_,%v, want _,nil", bufferURL, err)
+	}
+	defer v.Close()
+	if s := v.Size(); s != 6 {
+		t.Errorf("v.Size()=%d, want 6", s)
+	}
+
+	if _, err := v.Do(edit.Change(edit.All, "αβγ")); err != nil {
+		t.Fatalf("v.Do(…)=_,%v, want _,nil", err)
+	}
+	wait(v)
+	if s := v.Size(); s != 3 {
+		t.Errorf("v.Size()=%d, want 3", s)
+	}
+}
+
+func TestLine(t *testing.T) {
+	bufferURL, close := testBuffer()
+	defer close()
+	setText(bufferURL, "1\n2\n3\n4\n5\n")
+
+	v, err := New(bufferURL)
+	if err != nil {
+		t.Fatalf("New(%q)=_,%v, want _,nil", bufferURL, err)
+	}
+	defer v.Close()
+	if l := v.Line(); l != 1 {
+		t.Errorf("v.Line()=%d, want 1", l)
+	}
+
+	v.Resize(1)
+	wait(v)
+	v.Scroll(3)
+	wait(v)
+	if l := v.Line(); l != 4 {
+		t.Errorf("after Scroll(3) v.Line()=%d, want 4", l)
+	}
+
+	v.Warp(edit.Rune(3))
+	wait(v)
+	if l := v.Line(); l != 2 {
+		t.Errorf("after Warp(#3) v.Line()=%d, want 2", l)
+	}
+}
+
 func TestResizeScroll(t *testing.T) {
 	const lines = "1\n2\n3\n"
 	tests := []struct {
@@ -384,6 +436,50 @@ func TestMaintainDot(t *testing.T) {
 	}
 }
 
+func TestOthers(t *testing.T) {
+	bufferURL, close := testBuffer()
+	defer close()
+	setText(bufferURL, "abc")
+
+	v, err := NewLabeled(bufferURL, "me")
+	if err != nil {
+		t.Fatalf("NewLabeled(%q, \"me\")=_,%v, want _,nil", bufferURL, err)
+	}
+	defer v.Close()
+	if others := v.Others(); len(others) != 0 {
+		t.Errorf("v.Others()=%v, want []", others)
+	}
+
+	ed, err := editor.NewLabeledEditor(bufferURL, "you")
+	if err != nil {
+		t.Fatalf("editor.NewLabeledEditor(%q, \"you\")=_,%v, want _,nil", bufferURL, err)
+	}
+	editorURL := *bufferURL
+	editorURL.Path = ed.Path
+	for len(v.Others()) == 0 {
+		wait(v)
+	}
+
+	textURL := editorURL
+	textURL.Path = path.Join(ed.Path, "text")
+	if _, err := editor.Do(&textURL, edit.Set(edit.Regexp("b"), '.')); err != nil {
+		t.Fatalf("editor.Do(%q, …)=_,%v, want _,nil", &textURL, err)
+	}
+	// Editors without a Label, such as that of do, are not Others.
+	do(bufferURL, edit.Append(edit.End, "d"))
+	want := []editor.Presence{{EditorPath: ed.Path, Label: "you", Dot: edit.Span{1, 2}}}
+	for !reflect.DeepEqual(v.Others(), want) {
+		wait(v)
+	}
+
+	if err := editor.Close(&editorURL); err != nil {
+		t.Fatalf("editor.Close(%q)=%v, want nil", &editorURL, err)
+	}
+	for len(v.Others()) > 0 {
+		wait(v)
+	}
+}
+
 func TestMalformedEditError(t *testing.T) {
 	bufferURL, close := testBuffer()
 	defer close()
@@ -402,6 +498,43 @@ func TestMalformedEditError(t *testing.T) {
 	}
 }
 
+func TestErr(t *testing.T) {
+	bufferURL, close := testBuffer()
+	v, err := New(bufferURL)
+	if err != nil {
+		t.Fatalf("New(%q)=_,%v, want _,nil", bufferURL, err)
+	}
+	if err := v.Err(); err != nil {
+		t.Errorf("v.Err()=%v, want nil", err)
+	}
+
+	// Closing the server ends the View with an error.
+	close()
+	for range v.Notify {
+	}
+	if err := v.Err(); err == nil {
+		t.Errorf("v.Err()=nil after server close, want non-nil")
+	}
+	v.Close()
+}
+
+func TestErrClose(t *testing.T) {
+	bufferURL, close := testBuffer()
+	defer close()
+	v, err := New(bufferURL)
+	if err != nil {
+		t.Fatalf("New(%q)=_,%v, want _,nil", bufferURL, err)
+	}
+	if err := v.Close(); err != nil {
+		t.Fatalf("v.Close()=%v", err)
+	}
+	for range v.Notify {
+	}
+	if err := v.Err(); err != nil {
+		t.Errorf("v.Err()=%v after Close, want nil", err)
+	}
+}
+
 func markAddr(v *View, name rune) ([2]int64, bool) {
 	var ok bool
 	var where [2]int64
@@ -0,0 +1,104 @@
+// Copyright © 2016, The T Authors.
+
+package session
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A Replayer replays the requests served in a recorded session
+// to other servers, such as fresh headless servers in a test.
+//
+// Requests are replayed in order, each after the previous response.
+// Servers that number their resources in the order they are created,
+// as the editor and ui servers do, create the same resources
+// if they are started fresh and sent the same requests.
+type Replayer struct {
+	// Hosts maps the host:port of each recorded server
+	// to the host:port of the server to which its requests are replayed.
+	// The recorded hosts are replaced in both the URLs and the bodies of requests,
+	// since requests may refer to other servers, for example, by buffer URLs.
+	// Requests to hosts not in the map are not replayed.
+	Hosts map[string]string
+
+	// Speed is the factor by which the time between requests is scaled.
+	// A Speed of 0 replays the requests without delay,
+	// and a Speed of 1 replays them at the recorded pace.
+	Speed float64
+
+	// Check, if non-nil, is called with each replayed Entry
+	// and the status and body of the replayed response.
+	// If it returns an error, Replay stops and returns the error.
+	Check func(e Entry, status int, body string) error
+}
+
+// Replay replays the requests of the Served Entries.
+// Other Entries are ignored.
+// It returns the first error sending a request or returned by Check.
+func (r *Replayer) Replay(es []Entry) error {
+	var prev time.Time
+	for _, e := range es {
+		if e.Kind != Served {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return err
+		}
+		host, ok := r.Hosts[u.Host]
+		if !ok {
+			continue
+		}
+		if r.Speed > 0 && !prev.IsZero() {
+			time.Sleep(time.Duration(float64(e.Time.Sub(prev)) * r.Speed))
+		}
+		prev = e.Time
+		u.Host = host
+		status, body, err := r.send(e.Method, u, r.rewrite(e.Request))
+		if err != nil {
+			return fmt.Errorf("%s %s: %v", e.Method, e.URL, err)
+		}
+		if r.Check != nil {
+			if err := r.Check(e, status, body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Rewrite replaces the recorded hosts in a request body.
+func (r *Replayer) rewrite(body string) string {
+	for from, to := range r.Hosts {
+		body = strings.Replace(body, from, to, -1)
+	}
+	return body
+}
+
+func (r *Replayer) send(method string, u *url.URL, body string) (int, string, error) {
+	req, err := http.NewRequest(method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	d, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(d), err
+}
+
+// MatchStatus is a Check that returns an error
+// if the status of a replayed response differs from the recorded status.
+func MatchStatus(e Entry, status int, body string) error {
+	if status != e.Status {
+		return fmt.Errorf("%s %s: status %d, recorded %d: %s", e.Method, e.URL, status, e.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright © 2016, The T Authors.
+
+// Package session records the HTTP and websocket traffic
+// of T's servers and clients, and replays recorded sessions.
+//
+// A recorded session is a file of JSON-encoded Entries, one per line.
+// Attached to a bug report, it can be replayed
+// against fresh servers to reproduce the bug deterministically.
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Kind is the kind of a recorded Entry.
+type Kind string
+
+// The kinds of Entries.
+const (
+	// Served is a request received, and the response sent, by a server.
+	Served Kind = "served"
+	// Sent is a request sent, and the response received, by a client.
+	Sent Kind = "sent"
+	// Message is a message received by a client over a websocket.
+	Message Kind = "message"
+)
+
+// An Entry is a recorded request and response, or a recorded message.
+type Entry struct {
+	// Time is when the request was received or sent,
+	// or when the message was received.
+	Time time.Time `json:"time"`
+
+	// Kind is the kind of the Entry.
+	Kind Kind `json:"kind"`
+
+	// Method is the method of the request.
+	// It is empty for a Message.
+	Method string `json:"method,omitempty"`
+
+	// URL is the URL of the request or of the websocket.
+	URL string `json:"url"`
+
+	// Request is the body of the request, or the message.
+	Request string `json:"request,omitempty"`
+
+	// Status is the status code of the response.
+	// It is 0 for a Message.
+	Status int `json:"status,omitempty"`
+
+	// Response is the body of the response.
+	Response string `json:"response,omitempty"`
+}
+
+// A Recorder writes Entries to a session file.
+// All methods of a Recorder are safe for concurrent use.
+// The methods of a nil *Recorder do nothing.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+	err error
+}
+
+// NewRecorder returns a new Recorder that writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// Err returns the first error writing an Entry, if any.
+// Entries are not written after an error.
+func (r *Recorder) Err() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Record writes an Entry.
+// If the Entry's Time is zero, it is set to the current time.
+func (r *Recorder) Record(e Entry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = r.now()
+	}
+	r.err = r.enc.Encode(e)
+}
+
+// Message records a message received over the websocket at the URL.
+// The message is JSON encoded.
+func (r *Recorder) Message(URL string, msg interface{}) {
+	if r == nil {
+		return
+	}
+	d, err := json.Marshal(msg)
+	if err != nil {
+		d = []byte(err.Error())
+	}
+	r.Record(Entry{Kind: Message, URL: URL, Request: string(d)})
+}
+
+// Handler returns an http.Handler that records the requests served by h
+// and their responses.
+// Websocket upgrade requests are passed to h, but not recorded;
+// the messages are recorded by the client with Message.
+func (r *Recorder) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r == nil || strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+			h.ServeHTTP(w, req)
+			return
+		}
+		e := Entry{
+			Time:   r.now(),
+			Kind:   Served,
+			Method: req.Method,
+			URL:    "http://" + req.Host + req.URL.RequestURI(),
+		}
+		if req.Body != nil {
+			d, _ := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(d))
+			e.Request = string(d)
+		}
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rw, req)
+		e.Status = rw.status
+		e.Response = rw.body.String()
+		r.Record(e)
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(d []byte) (int, error) {
+	w.body.Write(d)
+	return w.ResponseWriter.Write(d)
+}
+
+// Transport returns an http.RoundTripper that records the requests
+// sent with rt and their responses.
+func (r *Recorder) Transport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripper{r: r, rt: rt}
+}
+
+type roundTripper struct {
+	r  *Recorder
+	rt http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.r == nil {
+		return t.rt.RoundTrip(req)
+	}
+	e := Entry{
+		Time:   t.r.now(),
+		Kind:   Sent,
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+	if req.Body != nil {
+		d, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(d))
+		e.Request = string(d)
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		e.Response = err.Error()
+		t.r.Record(e)
+		return nil, err
+	}
+	d, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(d))
+	e.Status = resp.StatusCode
+	e.Response = string(d)
+	t.r.Record(e)
+	return resp, err
+}
+
+// Load returns the Entries of a recorded session.
+func Load(r io.Reader) ([]Entry, error) {
+	var es []Entry
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Entry
+		switch err := dec.Decode(&e); {
+		case err == io.EOF:
+			return es, nil
+		case err != nil:
+			return nil, err
+		}
+		es = append(es, e)
+	}
+}
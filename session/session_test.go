@@ -0,0 +1,158 @@
+// Copyright © 2016, The T Authors.
+
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// EchoHandler responds with the method and body of the request,
+// and a status of 404 for the path /missing.
+func echoHandler(w http.ResponseWriter, req *http.Request) {
+	d, _ := ioutil.ReadAll(req.Body)
+	if req.URL.Path == "/missing" {
+		http.NotFound(w, req)
+		return
+	}
+	fmt.Fprintf(w, "%s %s", req.Method, d)
+}
+
+func TestHandler(t *testing.T) {
+	var out bytes.Buffer
+	rec := NewRecorder(&out)
+	server := httptest.NewServer(rec.Handler(http.HandlerFunc(echoHandler)))
+	defer server.Close()
+
+	post(t, server.URL+"/a?b=c", "hello")
+	post(t, server.URL+"/missing", "")
+	rec.Message("ws://host/changes", []int{1, 2})
+
+	es, err := Load(&out)
+	if err != nil {
+		t.Fatalf("Load(…)=_,%v, want _,nil", err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+	want := []Entry{
+		{Kind: Served, Method: http.MethodPost, URL: "http://" + host + "/a?b=c", Request: "hello", Status: http.StatusOK, Response: "POST hello"},
+		{Kind: Served, Method: http.MethodPost, URL: "http://" + host + "/missing", Status: http.StatusNotFound, Response: "404 page not found\n"},
+		{Kind: Message, URL: "ws://host/changes", Request: "[1,2]"},
+	}
+	for i := range es {
+		if es[i].Time.IsZero() {
+			t.Errorf("entry %d has a zero Time", i)
+		}
+		es[i].Time = want[0].Time
+	}
+	if !reflect.DeepEqual(es, want) {
+		t.Errorf("recorded %+v, want %+v", es, want)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	var out bytes.Buffer
+	rec := NewRecorder(&out)
+	client := &http.Client{Transport: rec.Transport(http.DefaultTransport)}
+	resp, err := client.Post(server.URL+"/x", "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatalf("Post(…)=_,%v, want _,nil", err)
+	}
+	d, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(d) != "POST hi" {
+		t.Errorf("response body=%q, want %q", d, "POST hi")
+	}
+
+	es, err := Load(&out)
+	if err != nil {
+		t.Fatalf("Load(…)=_,%v, want _,nil", err)
+	}
+	want := Entry{Kind: Sent, Method: http.MethodPost, URL: server.URL + "/x", Request: "hi", Status: http.StatusOK, Response: "POST hi"}
+	if len(es) != 1 {
+		t.Fatalf("recorded %d entries, want 1", len(es))
+	}
+	es[0].Time = want.Time
+	if es[0] != want {
+		t.Errorf("recorded %+v, want %+v", es[0], want)
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var rec *Recorder
+	server := httptest.NewServer(rec.Handler(http.HandlerFunc(echoHandler)))
+	defer server.Close()
+	post(t, server.URL, "x")
+	rec.Message("ws://host/changes", 1)
+	if err := rec.Err(); err != nil {
+		t.Errorf("rec.Err()=%v, want nil", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var out bytes.Buffer
+	rec := NewRecorder(&out)
+	recorded := httptest.NewServer(rec.Handler(http.HandlerFunc(echoHandler)))
+	recordedHost := strings.TrimPrefix(recorded.URL, "http://")
+	post(t, recorded.URL+"/a", "see "+recorded.URL)
+	post(t, recorded.URL+"/missing", "")
+	recorded.Close()
+	es, err := Load(&out)
+	if err != nil {
+		t.Fatalf("Load(…)=_,%v, want _,nil", err)
+	}
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		d, _ := ioutil.ReadAll(req.Body)
+		got = append(got, req.URL.Path+" "+string(d))
+		echoHandler(w, req)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	r := Replayer{
+		Hosts: map[string]string{recordedHost: host},
+		Check: MatchStatus,
+	}
+	if err := r.Replay(es); err != nil {
+		t.Fatalf("r.Replay(…)=%v, want nil", err)
+	}
+	want := []string{"/a see " + server.URL, "/missing "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %q, want %q", got, want)
+	}
+
+	// A status that differs from the recorded status is reported.
+	es[1].Status = http.StatusOK
+	if err := r.Replay(es); err == nil {
+		t.Errorf("r.Replay(…)=nil, want an error for the mismatched status")
+	}
+
+	// Requests to other hosts are not replayed.
+	got = nil
+	r.Hosts = map[string]string{"other:80": host}
+	if err := r.Replay(es); err != nil || len(got) != 0 {
+		t.Errorf("r.Replay(…)=%v, replayed %q, want nil, none", err, got)
+	}
+}
+
+func post(t *testing.T, URL, body string) {
+	u, err := url.Parse(URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q)=_,%v", URL, err)
+	}
+	resp, err := http.Post(u.String(), "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("http.Post(%q, …)=_,%v", URL, err)
+	}
+	resp.Body.Close()
+}
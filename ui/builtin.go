@@ -0,0 +1,364 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/mobile/event/paint"
+)
+
+// BuiltinCommands are the commands executed by a sheet
+// instead of by the shell.
+// They are called in the window's UI goroutine
+// with the arguments following the command name.
+//
+// Like acme:
+// 	Del deletes the sheet.
+// 	Put writes the body to the file named in the tag.
+// 	Get replaces the body with the contents of the file named in the tag.
+// 	Undo and Redo undo and redo the last change to the body.
+// 	Edit performs the edit given by its arguments on the body.
+// 	Look selects the next occurrence in the body of its arguments,
+// 	or of the body's dot if it has no arguments.
+// 	Send appends the snarf buffer to the end of the body.
+// 	Zoom grows the sheet to fill its column, or restores the column's layout.
+// 	Collapse shrinks the sheet to its tag, or grows it back.
+// 	ReadOnly prevents changes to the body by typing, mouse chords, and commands,
+// 	or allows them again.
+// 	Minimap shows or hides a miniature overview of the entire body
+// 	on the right edge of the body; clicking it scrolls the body.
+// 	Wrap switches the body between soft wrapping and clipping long lines.
+// 	Split opens a new sheet below the sheet, viewing the same body,
+// 	with its own dot and scroll position.
+// 	Learn adds its arguments, or the body's dot if it has no arguments,
+// 	to the user dictionary used to check spelling.
+// 	Diff shows the differences between the file named in the tag, as on disk,
+// 	or the body of the sheet named by its arguments, and the body
+// 	side by side in a new diff view below the sheet.
+// 	Font sets the font of the sheet's tag and body:
+// 	each argument is a face name, bold, italic, mono, or regular,
+// 	a size in points, or a size adjustment beginning with + or -.
+// 	With no arguments, the sheet uses its window's font again.
+var builtinCommands = map[string]func(s *sheet, args string){
+	"Del":  del,
+	"Put":  put,
+	"Get":  get,
+	"Undo": func(s *sheet, _ string) { s.body.doAsync(edit.Undo(1)) },
+	"Redo": func(s *sheet, _ string) { s.body.doAsync(edit.Redo(1)) },
+	"Edit": editCmd,
+	"Look": look,
+	"Send": send,
+	"Zoom": func(s *sheet, _ string) {
+		s.toggleZoom()
+		s.win.Send(paint.Event{})
+	},
+	"Collapse": func(s *sheet, _ string) {
+		s.toggleCollapse()
+		s.win.Send(paint.Event{})
+	},
+	"ReadOnly": func(s *sheet, _ string) { s.setReadOnly(!s.body.readOnly) },
+	"Minimap":  func(s *sheet, _ string) { s.toggleMinimap() },
+	"Wrap":     func(s *sheet, _ string) { s.setWrap(!s.wrap()) },
+	"Split":    split,
+	"Learn":    learn,
+	"Diff":     diffCmd,
+	"Font":     sheetFontCmd,
+}
+
+// WindowBuiltinCommands are the built-in commands
+// that operate on a window instead of a sheet.
+// They can be executed from any tag of the window,
+// including the window tag.
+//
+// 	Newcol adds a new column to the right of the window.
+// 	Dump writes the layout of all windows to the file named by its argument,
+// 	or to $HOME/t.dump if it has no argument.
+// 	Load opens windows with the layout read from the file named by its argument,
+// 	or from $HOME/t.dump if it has no argument.
+// 	Kill kills the running commands of the window named by its arguments,
+// 	or all of them if it has no arguments.
+// 	Search writes the matches in the window's sheets
+// 	of the regular expression given by its arguments to a new +search sheet.
+// 	Back and Forward navigate the window's list of locations
+// 	from which dot jumped.
+// 	Record begins recording the window's keyboard and mouse events
+// 	into the macro named by its argument, or into "macro" if it has no argument,
+// 	or, if a macro is being recorded, stops recording.
+// 	Play replays the macro named by its first argument, or "macro",
+// 	at the speed factor given by its optional second argument;
+// 	a speed of 0 replays the events without delay.
+// 	Font sets the font of the window's sheets that do not set their own,
+// 	with arguments like those of the sheet Font command.
+// 	Exit closes all windows, unless a sheet has changes
+// 	not yet written to its file and its argument is not -f,
+// 	in which case the modified sheets are reported.
+var windowBuiltinCommands = map[string]func(w *window, args string){
+	"Newcol":  newcol,
+	"Dump":    dumpFile,
+	"Load":    loadFile,
+	"Kill":    kill,
+	"Search":  searchCmd,
+	"Back":    func(w *window, _ string) { w.jumpBack() },
+	"Forward": func(w *window, _ string) { w.jumpForward() },
+	"Record":  recordCmd,
+	"Play":    playCmd,
+	"Font":    windowFontCmd,
+	"Exit":    exitCmd,
+}
+
+// SplitCommand returns the name and arguments of a command line.
+func splitCommand(commandLine string) (name, args string) {
+	commandLine = strings.TrimSpace(commandLine)
+	name = commandLine
+	if i := strings.IndexAny(commandLine, " \t\n"); i >= 0 {
+		name, args = commandLine[:i], strings.TrimSpace(commandLine[i:])
+	}
+	return name, args
+}
+
+// ExecBuiltin executes a built-in command on the sheet,
+// and returns whether the command line named a built-in command.
+func (s *sheet) execBuiltin(commandLine string) bool {
+	name, args := splitCommand(commandLine)
+	cmd, ok := builtinCommands[name]
+	if !ok {
+		return false
+	}
+	cmd(s, args)
+	return true
+}
+
+// ExecBuiltin executes a built-in command on the window,
+// and returns whether the command line named a built-in command.
+func (w *window) execBuiltin(commandLine string) bool {
+	name, args := splitCommand(commandLine)
+	cmd, ok := windowBuiltinCommands[name]
+	if !ok {
+		return false
+	}
+	cmd(w, args)
+	return true
+}
+
+// Errorf writes an error message to the window's output sheet.
+// It must be called in the window's UI goroutine.
+func (s *sheet) errorf(format string, vs ...interface{}) {
+	s.win.output(fmt.Sprintf(format, vs...) + "\n")
+}
+
+// FilePath returns the name of the file named in the sheet's tag,
+// or "" if the tag does not name a file.
+// Special sheets, such as +output, and sheets still named by their path
+// do not name a file.
+func (s *sheet) filePath() string {
+	name := s.tagFileName()
+	if !namesFile(name) {
+		return ""
+	}
+	return name
+}
+
+// NamesFile returns whether a tag file name names a file.
+func namesFile(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "+") && !strings.HasPrefix(name, "/sheet/")
+}
+
+func del(s *sheet, _ string) { s.win.server.deleteSheet(s.id) }
+
+func put(s *sheet, _ string) {
+	name := s.filePath()
+	if name == "" {
+		s.errorf("Put: no file name")
+		return
+	}
+	w := s.win
+	eol := s.eol
+	go func() {
+		if err := s.write(w, name, eol); err != nil {
+			w.Send(func() { s.errorf("Put %s: %v", name, err) })
+		}
+	}()
+}
+
+func get(s *sheet, _ string) {
+	name := s.filePath()
+	if name == "" {
+		s.errorf("Get: no file name")
+		return
+	}
+	s.load(name, edit.Rune(0))
+}
+
+// SetUnmodified marks the body unmodified
+// if its text did not change since the edit with the given sequence number,
+// typically the edit that read or set the text that was saved or loaded.
+// Otherwise, the body is left modified, since the changes are not saved.
+// It makes a blocking RPC, so it must not be called in a UI goroutine.
+func setUnmodified(s *sheet, seq int) error {
+	modified := false
+	update := editor.BufferUpdate{Modified: &modified, UnchangedSince: &seq}
+	_, err := editor.UpdateBuffer(s.body.bufferURL, update)
+	if err == editor.ErrConflict {
+		return nil
+	}
+	return err
+}
+
+func editCmd(s *sheet, args string) {
+	r := strings.NewReader(args)
+	e, err := edit.Ed(r)
+	if err != nil {
+		s.errorf("Edit: %v", err)
+		return
+	}
+	if r.Len() != 0 {
+		s.errorf("Edit: unexpected trailing text: %s", args[len(args)-r.Len():])
+		return
+	}
+	if s.body.readOnly && modifies([]edit.Edit{e}) {
+		s.errorf("Edit: %v", errReadOnly)
+		return
+	}
+	w := s.win
+	go func() {
+		res, err := s.body.view.Do(e)
+		var out string
+		switch {
+		case err != nil:
+			out = fmt.Sprintf("Edit: %v\n", err)
+		case res[0].Error != "":
+			out = fmt.Sprintf("Edit: %s\n", res[0].Error)
+		default:
+			out = res[0].Print
+		}
+		if out != "" {
+			w.Send(func() { w.output(out) })
+		}
+	}()
+}
+
+func look(s *sheet, args string) {
+	var re string
+	if args != "" {
+		re = regexp.QuoteMeta(args)
+	} else {
+		// TODO(eaburns): This makes a blocking RPC,
+		// but it's called in the window's UI goroutine.
+		res, err := s.body.doSync(edit.Print(dot))
+		if err != nil {
+			s.win.logf("failed to read dot: %v", err)
+			return
+		}
+		if res[0].Error != "" || res[0].Print == "" {
+			return
+		}
+		re = regexp.QuoteMeta(res[0].Print)
+	}
+	s.body.doAsync(edit.Set(dot.Plus(edit.Regexp(re)), '.'))
+	s.body.view.Warp(dot)
+}
+
+func send(s *sheet, _ string) {
+	str := s.body.snarf()
+	if str == "" {
+		return
+	}
+	if !strings.HasSuffix(str, "\n") {
+		str += "\n"
+	}
+	s.body.doAsync(edit.Change(edit.End, str), edit.Set(edit.End, '.'))
+}
+
+func newcol(w *window, _ string) {
+	c, err := newColumn(w)
+	if err != nil {
+		w.output(fmt.Sprintf("Newcol: %v\n", err))
+		return
+	}
+	last := w.columns[len(w.columns)-1]
+	x := float64(last.Min.X+last.Dx()/2) / float64(w.Dx())
+	if !w.addColumn(x, c) {
+		c.close()
+		w.output("Newcol: no room for a new column\n")
+		return
+	}
+	w.Send(paint.Event{})
+}
+
+func dumpFileName(args string) string {
+	if args != "" {
+		return args
+	}
+	return filepath.Join(os.Getenv("HOME"), "t.dump")
+}
+
+func dumpFile(w *window, args string) {
+	name := dumpFileName(args)
+	go func() {
+		layout, err := w.server.Dump()
+		if err == nil {
+			var d []byte
+			if d, err = json.MarshalIndent(layout, "", "\t"); err == nil {
+				err = ioutil.WriteFile(name, d, 0666)
+			}
+		}
+		if err != nil {
+			w.Send(func() { w.output(fmt.Sprintf("Dump %s: %v\n", name, err)) })
+		}
+	}()
+}
+
+func loadFile(w *window, args string) {
+	name := dumpFileName(args)
+	go func() {
+		var layout Layout
+		d, err := ioutil.ReadFile(name)
+		if err == nil {
+			err = json.Unmarshal(d, &layout)
+		}
+		if err == nil {
+			err = w.server.Load(layout)
+		}
+		if err != nil {
+			w.Send(func() { w.output(fmt.Sprintf("Load %s: %v\n", name, err)) })
+		}
+	}()
+}
+
+func kill(w *window, args string) {
+	names := strings.Fields(args)
+	for _, c := range w.running {
+		if len(names) > 0 && !containsString(names, c.name) {
+			continue
+		}
+		if err := c.cmd.Process.Kill(); err != nil {
+			w.output(fmt.Sprintf("Kill %s: %v\n", c.name, err))
+		}
+	}
+}
+
+func searchCmd(w *window, args string) {
+	w.search(args, func(_ *sheet, _ []SearchMatch, err error) {
+		if err != nil {
+			w.output(fmt.Sprintf("Search %s: %v\n", args, err))
+		}
+	})
+}
+
+func containsString(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+)
+
+func TestBuiltinCommands(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	exec := func(c string) {
+		w.Send(func() { sheet0.tag.exec(c) })
+		wait(w)
+	}
+	do := func(e edit.Edit) string {
+		res, err := sheet0.body.view.Do(e)
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("sheet0.body.view.Do(%s)=%v,%v", e, res, err)
+		}
+		return res[0].Print
+	}
+	// Builtin commands are asynchronous, so poll for their effects.
+	poll := func(f func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if f() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	exec("Edit ,c/hello world/")
+	if !poll(func() bool { return do(edit.Print(edit.All)) == "hello world" }) {
+		t.Errorf("after Edit body=%q, want %q", do(edit.Print(edit.All)), "hello world")
+	}
+
+	exec("Edit 0")
+	exec("Look world")
+	if !poll(func() bool { return strings.TrimSpace(do(edit.Where(edit.Dot))) == "#6,#11" }) {
+		t.Errorf("after Look dot=%q, want #6,#11", do(edit.Where(edit.Dot)))
+	}
+
+	dir, err := ioutil.TempDir("", "T_builtin_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	w.Send(func() { sheet0.setTagFileName(file) })
+	wait(w)
+
+	exec("Put")
+	if !poll(func() bool { d, _ := ioutil.ReadFile(file); return string(d) == "hello world" }) {
+		d, err := ioutil.ReadFile(file)
+		t.Errorf("after Put file=%q,%v, want %q", d, err, "hello world")
+	}
+
+	if err := ioutil.WriteFile(file, []byte("goodbye"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(…)=%v", err)
+	}
+	exec("Get")
+	if !poll(func() bool { return do(edit.Print(edit.All)) == "goodbye" }) {
+		t.Errorf("after Get body=%q, want %q", do(edit.Print(edit.All)), "goodbye")
+	}
+
+	exec("Del")
+	if _, ok := s.uiServer.sheets[sheet0.id]; ok {
+		t.Errorf("sheet0 not deleted")
+	}
+}
+
+func TestSetUnmodified(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	res, err := sheet0.body.view.Do(edit.Change(edit.All, "hello"), edit.Print(edit.All))
+	if err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	seq := res[1].Sequence
+	// A change after the Print, for example, by typing while saving.
+	if _, err := sheet0.body.view.Do(edit.Append(edit.End, " world")); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	if err := setUnmodified(sheet0, seq); err != nil {
+		t.Fatalf("setUnmodified(sheet0, %d)=%v", seq, err)
+	}
+	if buf, err := editor.BufferInfo(sheet0.body.bufferURL); err != nil || !buf.Modified {
+		t.Errorf("editor.BufferInfo(…)=%v,%v, want Modified=true", buf, err)
+	}
+
+	res, err = sheet0.body.view.Do(edit.Print(edit.All))
+	if err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	if err := setUnmodified(sheet0, res[0].Sequence); err != nil {
+		t.Fatalf("setUnmodified(sheet0, %d)=%v", res[0].Sequence, err)
+	}
+	if buf, err := editor.BufferInfo(sheet0.body.bufferURL); err != nil || buf.Modified {
+		t.Errorf("editor.BufferInfo(…)=%v,%v, want Modified=false", buf, err)
+	}
+}
+
+func TestKill(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	busy := func() (n, running int) {
+		w.Send(func() { n, running = sheet0.tag.busy, len(w.running) })
+		wait(w)
+		return n, running
+	}
+	// Executing from the body shows the busy indicator in the tag.
+	w.Send(func() { sheet0.body.exec("sleep 10") })
+	var n, running int
+	for i := 0; i < 100 && n == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		n, running = busy()
+	}
+	if n != 1 || running != 1 {
+		t.Fatalf("while running, busy=%d, running=%d, want 1, 1", n, running)
+	}
+
+	w.Send(func() { sheet0.tag.exec("Kill sleep") })
+	for i := 0; i < 500 && n > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		n, running = busy()
+	}
+	if n != 0 || running != 0 {
+		t.Errorf("after Kill, busy=%d, running=%d, want 0, 0", n, running)
+	}
+}
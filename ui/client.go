@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -68,6 +69,79 @@ func NewSheet(uiURL *url.URL, editorOrBufferURL *url.URL) (Sheet, error) {
 	return sheet, nil
 }
 
+// NewSheetInColumn is like NewSheet,
+// but it requests that the sheet be added to the column
+// with the given index, counting from 0 at the left.
+func NewSheetInColumn(uiURL *url.URL, editorOrBufferURL *url.URL, column int) (Sheet, error) {
+	req := NewSheetRequest{
+		URL:    editorOrBufferURL.String(),
+		Column: &column,
+	}
+	var sheet Sheet
+	if err := request(uiURL, http.MethodPut, req, &sheet); err != nil {
+		return Sheet{}, err
+	}
+	return sheet, nil
+}
+
+// MoveSheet PUTs a MoveSheetRequest
+// and returns a Sheet from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a sheet's window.
+func MoveSheet(URL *url.URL, windowPath string, x, y float64) (Sheet, error) {
+	req := MoveSheetRequest{
+		WindowPath: windowPath,
+		X:          x,
+		Y:          y,
+	}
+	var sheet Sheet
+	if err := request(URL, http.MethodPut, req, &sheet); err != nil {
+		return Sheet{}, err
+	}
+	return sheet, nil
+}
+
+// GetGeometry does a GET and returns a Geometry from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's layout.
+func GetGeometry(URL *url.URL) (Geometry, error) {
+	var g Geometry
+	if err := request(URL, http.MethodGet, nil, &g); err != nil {
+		return Geometry{}, err
+	}
+	return g, nil
+}
+
+// SetGeometry PUTs a SetGeometryRequest
+// and returns a Geometry from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's layout.
+func SetGeometry(URL *url.URL, req SetGeometryRequest) (Geometry, error) {
+	var g Geometry
+	if err := request(URL, http.MethodPut, req, &g); err != nil {
+		return Geometry{}, err
+	}
+	return g, nil
+}
+
+// GetExecEnv does a GET and returns an ExecEnv from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's env.
+func GetExecEnv(URL *url.URL) (ExecEnv, error) {
+	var env ExecEnv
+	if err := request(URL, http.MethodGet, nil, &env); err != nil {
+		return ExecEnv{}, err
+	}
+	return env, nil
+}
+
+// SetExecEnv PUTs an ExecEnv.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's env.
+func SetExecEnv(URL *url.URL, env ExecEnv) error {
+	return request(URL, http.MethodPut, env, nil)
+}
+
 // SheetList goes a GET and returns a list of Sheets from the response body.
 // The URL is expected to point to the server's sheets list.
 func SheetList(URL *url.URL) ([]Sheet, error) {
@@ -78,6 +152,168 @@ func SheetList(URL *url.URL) ([]Sheet, error) {
 	return list, nil
 }
 
+// Dump does a GET and returns a Layout from the response body.
+// The URL is expected to point to the server's layout.
+func Dump(URL *url.URL) (Layout, error) {
+	var layout Layout
+	if err := request(URL, http.MethodGet, nil, &layout); err != nil {
+		return Layout{}, err
+	}
+	return layout, nil
+}
+
+// Load PUTs a Layout.
+// The URL is expected to point to the server's layout.
+func Load(URL *url.URL, layout Layout) error {
+	return request(URL, http.MethodPut, layout, nil)
+}
+
+// DropFiles PUTs a DropRequest
+// and returns a Sheet list from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's drop target.
+func DropFiles(URL *url.URL, paths []string, x, y float64) ([]Sheet, error) {
+	req := DropRequest{Paths: paths, X: x, Y: y}
+	var sheets []Sheet
+	if err := request(URL, http.MethodPut, req, &sheets); err != nil {
+		return nil, err
+	}
+	return sheets, nil
+}
+
+// Search PUTs a SearchRequest
+// and returns a SearchResult from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's search.
+func Search(URL *url.URL, re string) (SearchResult, error) {
+	var res SearchResult
+	if err := request(URL, http.MethodPut, SearchRequest{Regexp: re}, &res); err != nil {
+		return SearchResult{}, err
+	}
+	return res, nil
+}
+
+// GetReadOnly does a GET and returns whether the sheet is read-only
+// from the ReadOnlyState of the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a sheet's read-only state.
+func GetReadOnly(URL *url.URL) (bool, error) {
+	var st ReadOnlyState
+	if err := request(URL, http.MethodGet, nil, &st); err != nil {
+		return false, err
+	}
+	return st.ReadOnly, nil
+}
+
+// SetReadOnly PUTs a ReadOnlyState.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a sheet's read-only state.
+func SetReadOnly(URL *url.URL, readOnly bool) error {
+	return request(URL, http.MethodPut, ReadOnlyState{ReadOnly: readOnly}, nil)
+}
+
+// GetWrap does a GET and returns whether the sheet soft wraps long lines
+// from the WrapState of the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a sheet's wrap state.
+func GetWrap(URL *url.URL) (bool, error) {
+	var st WrapState
+	if err := request(URL, http.MethodGet, nil, &st); err != nil {
+		return false, err
+	}
+	return st.Wrap, nil
+}
+
+// SetWrap PUTs a WrapState.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a sheet's wrap state.
+func SetWrap(URL *url.URL, wrap bool) error {
+	return request(URL, http.MethodPut, WrapState{Wrap: wrap}, nil)
+}
+
+// GetFont does a GET and returns a Font from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's or a sheet's font.
+func GetFont(URL *url.URL) (Font, error) {
+	var f Font
+	if err := request(URL, http.MethodGet, nil, &f); err != nil {
+		return Font{}, err
+	}
+	return f, nil
+}
+
+// SetFont PUTs a Font.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's or a sheet's font.
+func SetFont(URL *url.URL, f Font) error {
+	return request(URL, http.MethodPut, f, nil)
+}
+
+// WindowImage does a GET and returns the PNG image from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's image.
+func WindowImage(URL *url.URL) (image.Image, error) {
+	resp, err := http.Get(URL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	return png.Decode(resp.Body)
+}
+
+// GetTheme does a GET and returns a Theme from the response body.
+// The URL is expected to point to the server's theme.
+func GetTheme(URL *url.URL) (Theme, error) {
+	var th Theme
+	if err := request(URL, http.MethodGet, nil, &th); err != nil {
+		return Theme{}, err
+	}
+	return th, nil
+}
+
+// SetTheme PUTs a Theme.
+// The URL is expected to point to the server's theme.
+func SetTheme(URL *url.URL, th Theme) error {
+	return request(URL, http.MethodPut, th, nil)
+}
+
+// GetMacro does a GET and returns a Macro from the response body.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a macro.
+func GetMacro(URL *url.URL) (Macro, error) {
+	var m Macro
+	if err := request(URL, http.MethodGet, nil, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetMacro PUTs a Macro.
+// The URL is expected to point to a macro.
+func SetMacro(URL *url.URL, m Macro) error {
+	return request(URL, http.MethodPut, m, nil)
+}
+
+// Play PUTs a PlayRequest, and returns once the macro is replayed.
+// If the response status code is NotFound, ErrNotFound is returned.
+// The URL is expected to point to a window's play.
+func Play(URL *url.URL, name string, speed float64) error {
+	return request(URL, http.MethodPut, PlayRequest{Name: name, Speed: speed}, nil)
+}
+
+// Quit PUTs a QuitRequest and returns a QuitResult from the response body.
+// The URL is expected to point to the server's quit.
+func Quit(URL *url.URL, force bool) (QuitResult, error) {
+	var res QuitResult
+	if err := request(URL, http.MethodPut, QuitRequest{Force: force}, &res); err != nil {
+		return QuitResult{}, err
+	}
+	return res, nil
+}
+
 // Request makes an HTTP request to the given URL.
 // req is the body of the request.
 // If it implements io.Reader it is used directly as the body,
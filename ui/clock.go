@@ -0,0 +1,37 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import "time"
+
+// A Clock is the source of time of a Server.
+//
+// The Server uses its Clock to pace drawing,
+// blink the cursor, detect double clicks,
+// time hovers, file watches, and autosaves,
+// pace the replay of macros,
+// and timestamp the messages of the console.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel on which the current time is sent
+	// once the duration has elapsed.
+	After(time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SetClock sets the Clock of the server.
+// It must be called before any windows are created.
+// By default, the clock is the system clock.
+func (s *Server) SetClock(c Clock) { s.clock = c }
+
+// Now returns the current time of the server's clock.
+func (s *Server) now() time.Time { return s.clock.Now() }
+
+// Since returns the time elapsed since t on the server's clock.
+func (s *Server) since(t time.Time) time.Duration { return s.clock.Now().Sub(t) }
@@ -97,7 +97,7 @@ func (c *column) setAfterResizeBounds(bounds image.Rectangle) {
 		b := bounds
 		if i > 0 {
 			if i == 1 {
-				b.Min.Y = c.frames[0].bounds().Max.Y + borderWidth
+				b.Min.Y = bounds.Min.Y + c.frames[0].bounds().Dy() + borderWidth
 			} else {
 				b.Min.Y = bounds.Min.Y + int(height*c.ys[i])
 			}
@@ -128,10 +128,17 @@ func (c *column) draw(scr screen.Screen, win screen.Window) {
 		b := c.bounds()
 		b.Min.Y = f.bounds().Max.Y
 		b.Max.Y = g.bounds().Min.Y
-		win.Fill(b, borderColor, draw.Over)
+		win.Fill(b, c.theme().Border, draw.Over)
 	}
 }
 
+// Theme returns the column's color theme.
+// It is the theme of the column's tag,
+// since the column has no window while it is dragged.
+func (c *column) theme() *Theme {
+	return c.frames[0].(*columnTag).text.theme
+}
+
 func (c *column) removeFrame(f frame) bool {
 	i := frameIndex(c, f)
 	if i <= 0 {
@@ -167,7 +174,7 @@ func (c *column) addFrame(yfrac float64, f frame) bool {
 
 	// The frame we are splitting goes on top.
 	// The added frame goes on the bottom.
-	splitBounds := splitFrame.bounds()
+	splitBounds := splitFrame.bounds().Sub(image.Pt(0, c.Min.Y))
 	if topSize := y - splitBounds.Min.Y; i > 0 && topSize < splitFrame.minHeight() {
 		if !slideUp(c, i, splitFrame.minHeight()-topSize) {
 			y += splitFrame.minHeight() - topSize
@@ -209,7 +216,8 @@ func frameIndex(c *column, f frame) int {
 	return -1
 }
 
-// FrameAt returns the frame containing pixel row y.
+// FrameAt returns the frame containing pixel row y,
+// relative to the top of the column.
 // If y < 0, the top-most frame is returned.
 // If y > width, the the bottom-most frame is returned.
 func frameAt(c *column, y int) (i int, f frame) {
@@ -217,7 +225,7 @@ func frameAt(c *column, y int) (i int, f frame) {
 		return 0, c.frames[0]
 	}
 	for i, f = range c.frames {
-		if f.bounds().Max.Y > y {
+		if f.bounds().Max.Y-c.Min.Y > y {
 			return i, f
 		}
 	}
@@ -235,7 +243,7 @@ func slideUp(c *column, i int, delta int) bool {
 			return false
 		}
 	}
-	c.ys[i] = float64(y) / float64(c.Dy())
+	c.ys[i] = float64(y-c.Min.Y) / float64(c.Dy())
 	return true
 }
 
@@ -250,7 +258,7 @@ func slideDown(c *column, i int, delta int) bool {
 			return false
 		}
 	}
-	c.ys[i+1] = float64(y) / float64(c.Dy())
+	c.ys[i+1] = float64(y-c.Min.Y) / float64(c.Dy())
 	return true
 }
 
@@ -267,10 +275,10 @@ type columnTag struct {
 }
 
 func newColumnTag(w *window) (*columnTag, error) {
-	text, err := newTextBox(w, *w.server.editorURL, text.Style{
+	text, err := newTextBox(w, *w.server.editorURL, "", text.Style{
 		Face: w.face,
-		FG:   color.Black,
-		BG:   color.Gray16{0xF5F5},
+		FG:   w.theme.ColumnTagFG,
+		BG:   w.theme.ColumnTagBG,
 	})
 	if err != nil {
 		return nil, err
@@ -317,16 +325,16 @@ func (t *columnTag) drawLast(scr screen.Screen, win screen.Window) {
 		return
 	}
 	t.col.draw(scr, win)
-	drawBorder(t.col.bounds(), win)
+	drawBorder(t.col.bounds(), t.text.theme.Border, win)
 }
 
-func drawBorder(b image.Rectangle, win screen.Window) {
+func drawBorder(b image.Rectangle, c color.Color, win screen.Window) {
 	x0, x1 := b.Min.X, b.Max.X
 	y0, y1 := b.Min.Y, b.Max.Y
-	win.Fill(image.Rect(x0, y0-borderWidth, x1, y0), borderColor, draw.Over)
-	win.Fill(image.Rect(x0-borderWidth, y0, x0, y1), borderColor, draw.Over)
-	win.Fill(image.Rect(x0, y1, x1, y1+borderWidth), borderColor, draw.Over)
-	win.Fill(image.Rect(x1, y0, x1+borderWidth, y1), borderColor, draw.Over)
+	win.Fill(image.Rect(x0, y0-borderWidth, x1, y0), c, draw.Over)
+	win.Fill(image.Rect(x0-borderWidth, y0, x0, y1), c, draw.Over)
+	win.Fill(image.Rect(x0, y1, x1, y1+borderWidth), c, draw.Over)
+	win.Fill(image.Rect(x1, y0, x1+borderWidth, y1), c, draw.Over)
 }
 
 func (t *columnTag) changeFocus(win *window, inFocus bool) {
@@ -0,0 +1,236 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/mobile/event/key"
+)
+
+// A Completer returns the completions of a word typed in a tag.
+//
+// Dir is the directory in which commands executed from the tag run,
+// and word is the text preceding the cursor,
+// back to the nearest white space.
+// Each completion is a replacement for the entire word.
+//
+// Completers are called in the UI goroutine,
+// so they must not block.
+type Completer func(dir, word string) []string
+
+// SetCompleters sets the completers of words typed in tags.
+// When the key bound to the tab command is pressed in a tag,
+// the word before the cursor is replaced
+// by the longest common prefix of the completions of all completers.
+// If that does not extend the word,
+// the completions are listed in a tooltip.
+// By default, the completers are CompleteCommands and CompleteFiles.
+func (s *Server) SetCompleters(cs []Completer) {
+	s.Lock()
+	s.completers = append([]Completer{}, cs...)
+	s.Unlock()
+}
+
+// DefaultCompleters returns the default completers.
+func defaultCompleters() []Completer {
+	return []Completer{CompleteCommands, CompleteFiles}
+}
+
+// CompleteCommands is a Completer
+// that completes the names of built-in commands.
+func CompleteCommands(_, word string) []string {
+	if word == "" {
+		return nil
+	}
+	var cs []string
+	for name := range builtinCommands {
+		if strings.HasPrefix(name, word) {
+			cs = append(cs, name)
+		}
+	}
+	for name := range windowBuiltinCommands {
+		if strings.HasPrefix(name, word) {
+			cs = append(cs, name)
+		}
+	}
+	return cs
+}
+
+// CompleteFiles is a Completer that completes file paths.
+// Relative paths are relative to dir.
+// Completions of directories end in a slash.
+func CompleteFiles(dir, word string) []string {
+	base := word
+	parent := ""
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		parent, base = word[:i+1], word[i+1:]
+	}
+	d := parent
+	switch {
+	case d == "":
+		d = "."
+		fallthrough
+	case !filepath.IsAbs(d) && dir != "":
+		d = filepath.Join(dir, d)
+	}
+	fis, err := ioutil.ReadDir(d)
+	if err != nil {
+		return nil
+	}
+	var cs []string
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasPrefix(name, base) ||
+			strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		c := parent + name
+		if fi.IsDir() || fi.Mode()&os.ModeSymlink != 0 && isDir(filepath.Join(d, name)) {
+			c += "/"
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// CompleteKey handles a key event that completes
+// the word before the cursor of a tag.
+// It returns whether the event was handled.
+//
+// If the key is bound to the tab command,
+// and dot is empty,
+// the word before dot is read asynchronously
+// and replaced by the longest common prefix
+// of its completions.
+// If there is no word or it has no completions,
+// the event is then handled by handleKey as usual.
+func (t *textBox) completeKey(w *window, event key.Event) bool {
+	if boundName(t.keymap(), event) != "tab" || !t.isTag() || w == nil || t.dot0 != t.dot1 {
+		return false
+	}
+	t.doThen(func(res []editor.EditResult, err error) {
+		if !t.complete(w, res, err) {
+			handleKey(t, event)
+		}
+	},
+		edit.Where(dot),
+		edit.Print(dot.Minus(edit.Line(0)).Minus(zero).To(dot)),
+		// Print set dot to the line; restore it.
+		edit.Set(dot.Plus(zero), '.'),
+	)
+	return true
+}
+
+// Complete completes the word before the cursor of a tag,
+// given the results of the edits made by completeKey.
+// It returns whether there were any completions.
+func (t *textBox) complete(w *window, res []editor.EditResult, err error) bool {
+	if err != nil {
+		t.logf("failed to read word to complete: %v", err)
+		return false
+	}
+	for _, r := range res[:2] {
+		if r.Error != "" {
+			t.logf("failed to read word to complete: %s", r.Error)
+			return false
+		}
+	}
+	var at int64
+	if _, err := fmt.Sscanf(res[0].Print, "#%d", &at); err != nil {
+		t.logf("failed to scan address: %s", res[0].Print)
+		return false
+	}
+	line := res[1].Print
+	word := line
+	if i := strings.LastIndexFunc(line, unicode.IsSpace); i >= 0 {
+		_, n := utf8.DecodeRuneInString(line[i:])
+		word = line[i+n:]
+	}
+	if word == "" {
+		return false
+	}
+
+	w.server.RLock()
+	completers := w.server.completers
+	w.server.RUnlock()
+	dir, _ := w.commandEnv(t.sheet)
+	var cs []string
+	for _, c := range completers {
+		cs = append(cs, c(dir, word)...)
+	}
+	cs = uniq(cs)
+	if len(cs) == 0 {
+		return false
+	}
+
+	prefix := commonPrefix(cs)
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		from := at - int64(utf8.RuneCountInString(word))
+		t.doAsync(
+			edit.Change(edit.Rune(from).To(edit.Rune(at)), prefix),
+			edit.Set(dot.Plus(zero), '.'),
+		)
+		return true
+	}
+	if len(cs) > 1 {
+		w.hover = hover{p: t.caretPoint(), asked: true, tip: strings.Join(cs, "\n")}
+	}
+	return true
+}
+
+// CaretPoint returns the point of the window
+// at the beginning of dot.
+func (t *textBox) caretPoint() image.Point {
+	if t.text == nil || t.dot0 < t.l0 || t.dot0 > t.l0+int64(t.textLen) {
+		return t.topLeft
+	}
+	return t.text.GlyphBox(int(t.dot0 - t.l0)).Min.Add(t.topLeft)
+}
+
+// Uniq returns the sorted, distinct strings of ss.
+func uniq(ss []string) []string {
+	sort.Strings(ss)
+	var u []string
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			u = append(u, s)
+		}
+	}
+	return u
+}
+
+// CommonPrefix returns the longest common prefix of ss,
+// ending on a rune boundary.
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		i := 0
+		for i < len(prefix) && i < len(s) && prefix[i] == s[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
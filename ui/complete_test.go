@@ -0,0 +1,161 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestCompleteCommands(t *testing.T) {
+	got := uniq(CompleteCommands("", "Lo"))
+	if want := []string{"Load", "Look"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteCommands(\"Lo\")=%q, want %q", got, want)
+	}
+	if got := CompleteCommands("", ""); len(got) != 0 {
+		t.Errorf("CompleteCommands(\"\")=%q, want []", got)
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	dir := testCompleteDir(t)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		dir, word string
+		want      []string
+	}{
+		{dir: dir, word: "fo", want: []string{"foo.go", "food/"}},
+		{dir: dir, word: "food", want: []string{"food/"}},
+		{dir: dir, word: "food/", want: []string{"food/bar"}},
+		{dir: dir, word: "x", want: nil},
+		{dir: dir, word: "", want: []string{"bar", "foo.go", "food/"}},
+		{dir: dir, word: ".", want: []string{".hidden"}},
+		{dir: "", word: dir + "/ba", want: []string{dir + "/bar"}},
+		{dir: dir, word: "nodir/", want: nil},
+	}
+	for _, test := range tests {
+		got := uniq(CompleteFiles(test.dir, test.word))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("CompleteFiles(%q, %q)=%q, want %q", test.dir, test.word, got, test.want)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		ss   []string
+		want string
+	}{
+		{ss: nil, want: ""},
+		{ss: []string{"abc"}, want: "abc"},
+		{ss: []string{"abc", "abd"}, want: "ab"},
+		{ss: []string{"abc", "x"}, want: ""},
+		{ss: []string{"aα", "aβ"}, want: "a"},
+	}
+	for _, test := range tests {
+		if got := commonPrefix(test.ss); got != test.want {
+			t.Errorf("commonPrefix(%q)=%q, want %q", test.ss, got, test.want)
+		}
+	}
+}
+
+func TestCompleteKey(t *testing.T) {
+	dir := testCompleteDir(t)
+	defer os.RemoveAll(dir)
+
+	s, w := makeTestUI()
+	defer s.close()
+	w.Send(func() { w.execEnv.Dir = dir })
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.tag, "tag fo", edit.Span{6, 6})
+	mouseTo(w, sheet0.tag.topLeft.Add(image.Pt(1, 1)))
+
+	pressKey(w, -1, key.CodeTab)
+	wait(w)
+	if got := waitText(sheet0.tag, "tag foo"); got != "tag foo" {
+		t.Fatalf("tag text=%q, want %q", got, "tag foo")
+	}
+
+	setTextDot(t, w, sheet0.tag, "tag foo", edit.Span{7, 7})
+	pressKey(w, -1, key.CodeTab)
+	wait(w)
+	// The completions are listed asynchronously.
+	var tip string
+	for i := 0; i < 100 && tip == ""; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Send(func() { tip = w.hover.tip })
+		wait(w)
+	}
+	if want := "foo.go\nfood/"; tip != want {
+		t.Errorf("tooltip=%q, want %q", tip, want)
+	}
+	if got := waitText(sheet0.tag, "tag foo"); got != "tag foo" {
+		t.Errorf("tag text=%q, want %q", got, "tag foo")
+	}
+
+	pressKey(w, '.', key.CodeUnknown)
+	wait(w)
+	w.Send(func() { tip = w.hover.tip })
+	wait(w)
+	if tip != "" {
+		t.Errorf("after typing, tooltip=%q, want \"\"", tip)
+	}
+}
+
+func TestCompleteKey_Command(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.tag, "tag Newc", edit.Span{8, 8})
+	mouseTo(w, sheet0.tag.topLeft.Add(image.Pt(1, 1)))
+
+	pressKey(w, -1, key.CodeTab)
+	wait(w)
+	if got := waitText(sheet0.tag, "tag Newcol"); got != "tag Newcol" {
+		t.Errorf("tag text=%q, want %q", got, "tag Newcol")
+	}
+}
+
+func TestCompleteKey_NoCompletions(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.tag, "tag zzz", edit.Span{7, 7})
+	mouseTo(w, sheet0.tag.topLeft.Add(image.Pt(1, 1)))
+
+	pressKey(w, -1, key.CodeTab)
+	wait(w)
+	if got := waitText(sheet0.tag, "tag zzz\t"); got != "tag zzz\t" {
+		t.Errorf("tag text=%q, want %q", got, "tag zzz\t")
+	}
+}
+
+// TestCompleteDir returns a new temporary directory
+// containing files to complete.
+func testCompleteDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "complete_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo.go", "bar", ".hidden", "food/bar"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
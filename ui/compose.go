@@ -0,0 +1,104 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+)
+
+// A CompositionEvent is sent to a window by an input method
+// while text is composed, for example, with dead keys
+// or with a CJK input method.
+//
+// Shiny does not deliver input method events,
+// so CompositionEvents must be sent to the window's screen.Window
+// by platform-specific input method support.
+type CompositionEvent struct {
+	// Text is the composed text.
+	Text string
+
+	// Commit is whether the composition is complete.
+	// If so, Text is inserted at dot of the text box in focus.
+	// Otherwise, Text is shown at dot until the next CompositionEvent;
+	// an empty Text cancels the composition.
+	Commit bool
+}
+
+// A composer is a handler that accepts text composed by an input method.
+type composer interface {
+	// Compose is called if the handler is in focus
+	// and the window receives a CompositionEvent.
+	// The return value is whether to redraw the window.
+	compose(*window, CompositionEvent) bool
+}
+
+func (t *textBox) compose(_ *window, event CompositionEvent) bool {
+	if !event.Commit {
+		t.composition = event.Text
+		return true
+	}
+	t.composition = ""
+	if event.Text != "" {
+		t.showDot = true
+		t.doAsync(edit.Change(dot, event.Text), edit.Set(dot.Plus(zero), '.'))
+	}
+	return true
+}
+
+func (s *sheet) compose(w *window, event CompositionEvent) bool {
+	if s.find != nil {
+		if event.Commit {
+			s.setFindQuery(s.find.query + event.Text)
+		}
+		return true
+	}
+	if c, ok := s.subFocus.(composer); ok {
+		return c.compose(w, event)
+	}
+	return false
+}
+
+func (t *columnTag) compose(w *window, event CompositionEvent) bool {
+	return t.text.compose(w, event)
+}
+
+func (t *windowTag) compose(w *window, event CompositionEvent) bool {
+	return t.text.compose(w, event)
+}
+
+// DrawComposition draws the text being composed, if any,
+// highlighted and underlined over the text at the start of dot.
+func (t *textBox) drawComposition(pt image.Point, scr screen.Screen, win screen.Window) {
+	d := t.dot0
+	if t.composition == "" || d < t.l0 || d > t.l0+int64(t.textLen) {
+		return
+	}
+	r := t.text.GlyphBox(int(d - t.l0)).Add(pt)
+	box := image.Rectangle{Min: t.topLeft, Max: t.topLeft.Add(t.opts.Size)}
+	face := t.opts.DefaultStyle.Face
+	width := font.MeasureString(face, t.composition).Ceil()
+	r.Max.X = r.Min.X + width
+	if r = r.Intersect(box); r.Empty() {
+		return
+	}
+
+	opts := t.opts
+	opts.Size = r.Size()
+	opts.Padding = 0
+	opts.DefaultStyle.BG = t.theme.Highlight
+	setter := text.NewSetter(opts)
+	defer setter.Release()
+	setter.Add([]byte(t.composition))
+	txt := setter.Set()
+	txt.Draw(r.Min, scr, win)
+	txt.Release()
+
+	underline := scalePx(cursorWidth, t.scale)
+	win.Fill(image.Rect(r.Min.X, r.Max.Y-underline, r.Max.X, r.Max.Y), t.theme.Cursor, draw.Src)
+}
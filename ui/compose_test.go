@@ -0,0 +1,63 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestComposition(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "ab"), edit.Set(edit.Rune(1), '.')); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	mouseTo(w, center(sheet0))
+	wait(w)
+
+	composition := func() (str string) {
+		w.Send(func() { str = sheet0.body.composition })
+		wait(w)
+		return str
+	}
+	// Printing sets dot, so it is set back to #1.
+	bodyText := func() string {
+		res, err := sheet0.body.view.Do(edit.Print(edit.All), edit.Set(edit.Rune(1), '.'))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("sheet0.body.view.Do(Print(All))=%v,%v", res, err)
+		}
+		return res[0].Print
+	}
+
+	w.Send(CompositionEvent{Text: "´"})
+	if str := composition(); str != "´" {
+		t.Errorf("composition=%q, want %q", str, "´")
+	}
+	if text := bodyText(); text != "ab" {
+		t.Errorf("before commit, body=%q, want %q", text, "ab")
+	}
+
+	w.Send(CompositionEvent{Text: "é", Commit: true})
+	if str := composition(); str != "" {
+		t.Errorf("after commit, composition=%q, want \"\"", str)
+	}
+	var text string
+	for i := 0; i < 100 && text != "aéb"; i++ {
+		text = bodyText()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if text != "aéb" {
+		t.Errorf("after commit, body=%q, want %q", text, "aéb")
+	}
+
+	// Moving the focus cancels the composition.
+	w.Send(CompositionEvent{Text: "´"})
+	mouseTo(w, center(w.columns[1].frames[1]))
+	if str := composition(); str != "" {
+		t.Errorf("after focus change, composition=%q, want \"\"", str)
+	}
+}
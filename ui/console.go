@@ -0,0 +1,64 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+)
+
+const consoleSheetName = "+console"
+
+// Logf writes a message to the +console sheet of each window,
+// prefixed with the time, on the server's clock.
+// The sheet is created if the window does not have one.
+// If there are no windows, the message is written to the standard logger.
+//
+// Logf may be called from any goroutine.
+// It is how internal errors, such as failed commands
+// and errors communicating with the editor server, are reported.
+func (s *Server) Logf(format string, vs ...interface{}) {
+	msg := fmt.Sprintf(format, vs...)
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.windows) == 0 {
+		log.Print(msg)
+		return
+	}
+	for _, w := range s.windows {
+		w.logMessage(msg)
+	}
+}
+
+// Console returns an io.Writer that writes to the consoles of the windows.
+// Each Write is logged as a message by Logf.
+// It can be used as the output of a log.Logger.
+func (s *Server) Console() io.Writer { return consoleWriter{s} }
+
+type consoleWriter struct{ s *Server }
+
+func (c consoleWriter) Write(p []byte) (int, error) {
+	c.s.Logf("%s", p)
+	return len(p), nil
+}
+
+// Logf writes a message to the window's +console sheet,
+// prefixed with the time, on the server's clock.
+// It may be called from any goroutine.
+func (w *window) logf(format string, vs ...interface{}) {
+	w.logMessage(fmt.Sprintf(format, vs...))
+}
+
+func (w *window) logMessage(msg string) {
+	msg = w.server.now().Format("15:04:05 ") + strings.TrimSuffix(msg, "\n") + "\n"
+	w.Send(func() {
+		if c := w.specialSheet(consoleSheetName); c != nil {
+			// Messages are appended even if the sheet is read-only.
+			c.body.view.DoAsync(edit.Append(edit.End, msg))
+		}
+	})
+}
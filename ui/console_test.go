@@ -0,0 +1,50 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"log"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestLogf(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	s.uiServer.Logf("hello %s", "console")
+	log.New(s.uiServer.Console(), "", 0).Print("from the logger")
+	wait(w)
+
+	var console *sheet
+	w.Send(func() {
+		for _, c := range w.columns {
+			for _, f := range c.frames {
+				if h, ok := f.(*sheet); ok && h.tagFileName() == consoleSheetName {
+					console = h
+				}
+			}
+		}
+	})
+	wait(w)
+	if console == nil {
+		t.Fatalf("no %s sheet after Logf", consoleSheetName)
+	}
+
+	want := regexp.MustCompile(`^\d\d:\d\d:\d\d hello console\n\d\d:\d\d:\d\d from the logger\n$`)
+	var got string
+	for i := 0; i < 100 && !want.MatchString(got); i++ {
+		time.Sleep(10 * time.Millisecond)
+		res, err := console.body.doSync(edit.Print(edit.All))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("failed to read the console: %v, %v", err, res)
+		}
+		got = res[0].Print
+	}
+	if !want.MatchString(got) {
+		t.Errorf("console text=%q, want to match %q", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright © 2016, The T Authors.
+
+// Package cursor defines the shapes of the mouse cursor
+// and the interface of windows that can change it.
+//
+// Shiny's screen.Window has no way to change the cursor,
+// so window backends that can change it implement Setter.
+// The ui package changes the cursor of windows that implement Setter,
+// and leaves the cursor of other windows alone.
+package cursor
+
+// A Shape is the shape of the mouse cursor.
+type Shape int
+
+// The shapes of the cursor.
+const (
+	// Arrow is the default cursor.
+	Arrow Shape = iota
+	// ResizeEW is the cursor over a border that moves left and right.
+	ResizeEW
+	// ResizeNS is the cursor over a border that moves up and down.
+	ResizeNS
+	// ResizeAll is the cursor over the corner where borders meet,
+	// which moves in any direction.
+	ResizeAll
+)
+
+func (s Shape) String() string {
+	switch s {
+	case Arrow:
+		return "Arrow"
+	case ResizeEW:
+		return "ResizeEW"
+	case ResizeNS:
+		return "ResizeNS"
+	case ResizeAll:
+		return "ResizeAll"
+	default:
+		return "Shape(?)"
+	}
+}
+
+// A Setter is a window that can change the shape of the mouse cursor
+// while the cursor is over the window.
+type Setter interface {
+	// SetCursor sets the shape of the cursor.
+	SetCursor(Shape)
+}
@@ -0,0 +1,203 @@
+// Copyright © 2016, The T Authors.
+
+// Package diff computes the differences between two sequences,
+// such as the lines of two texts,
+// using the O(ND) algorithm of Eugene W. Myers,
+// “An O(ND) Difference Algorithm and Its Variations”.
+package diff
+
+import "strings"
+
+// An Op is the kind of a Hunk.
+type Op int
+
+// The kinds of hunks.
+const (
+	// Equal is a hunk of elements common to both sequences.
+	Equal Op = iota
+	// Delete is a hunk of elements only in the first sequence.
+	Delete
+	// Insert is a hunk of elements only in the second sequence.
+	Insert
+)
+
+func (op Op) String() string {
+	switch op {
+	case Equal:
+		return "="
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	}
+	return "?"
+}
+
+// A Hunk is a run of elements with the same Op.
+type Hunk struct {
+	Op Op
+	// A and B are the half-open ranges of the hunk's elements
+	// in the first and second sequence.
+	// For Delete hunks, B is empty, at the position of the deletion,
+	// and for Insert hunks, A is empty, at the position of the insertion.
+	A, B [2]int
+}
+
+// Diff returns the hunks of the differences
+// between a sequence of n elements and a sequence of m elements,
+// in order, covering both sequences.
+// Eq returns whether the ith element of the first sequence
+// equals the jth element of the second.
+//
+// Adjacent Delete and Insert hunks are ordered Delete first.
+func Diff(n, m int, eq func(i, j int) bool) []Hunk {
+	// Trim the common prefix and suffix,
+	// which are typically most of the elements.
+	var pre, suf int
+	for pre < n && pre < m && eq(pre, pre) {
+		pre++
+	}
+	for suf < n-pre && suf < m-pre && eq(n-1-suf, m-1-suf) {
+		suf++
+	}
+
+	var hs hunks
+	hs.add(Equal, pre, pre)
+	for _, s := range snakes(pre, n-suf, pre, m-suf, eq) {
+		hs.add(Delete, s.x-hs.x, 0)
+		hs.add(Insert, 0, s.y-hs.y)
+		hs.add(Equal, s.n, s.n)
+	}
+	hs.add(Delete, n-suf-hs.x, 0)
+	hs.add(Insert, 0, m-suf-hs.y)
+	hs.add(Equal, suf, suf)
+	return hs.hs
+}
+
+// Hunks accumulates hunks, merging adjacent hunks with the same Op.
+type hunks struct {
+	hs []Hunk
+	// X and y are the ends of the hunks in the first and second sequence.
+	x, y int
+}
+
+func (hs *hunks) add(op Op, dx, dy int) {
+	if dx == 0 && dy == 0 {
+		return
+	}
+	if n := len(hs.hs); n > 0 && hs.hs[n-1].Op == op {
+		hs.hs[n-1].A[1] += dx
+		hs.hs[n-1].B[1] += dy
+	} else {
+		hs.hs = append(hs.hs, Hunk{Op: op, A: [2]int{hs.x, hs.x + dx}, B: [2]int{hs.y, hs.y + dy}})
+	}
+	hs.x += dx
+	hs.y += dy
+}
+
+// A snake is a run of n equal elements,
+// beginning at x in the first sequence and y in the second.
+type snake struct{ x, y, n int }
+
+// Snakes returns the snakes of a shortest edit script
+// transforming elements [x0, x1) of the first sequence
+// into elements [y0, y1) of the second, in order.
+func snakes(x0, x1, y0, y1 int, eq func(i, j int) bool) []snake {
+	n, m := x1-x0, y1-y0
+	if n == 0 || m == 0 {
+		return nil
+	}
+	max := n + m
+	// V[k+max] is the furthest x reached on diagonal k = x-y.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[k-1+max] < v[k+1+max] {
+				x = v[k+1+max]
+			} else {
+				x = v[k-1+max] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(x0+x, y0+y) {
+				x++
+				y++
+			}
+			v[k+max] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, x0, y0)
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+// Backtrack returns the snakes of the path found by snakes,
+// given the trace of V at the start of each step.
+func backtrack(trace [][]int, n, m, x0, y0 int) []snake {
+	max := n + m
+	var ss []snake
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		k := x - y
+		var prevK int
+		if k == -d || k != d && trace[d][k-1+max] < trace[d][k+1+max] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := trace[d][prevK+max]
+		prevY := prevX - prevK
+		// The snake begins after the move from the previous diagonal.
+		sx, sy := prevX, prevY
+		if prevK == k+1 {
+			sy++
+		} else {
+			sx++
+		}
+		if d == 0 {
+			sx, sy = 0, 0
+		}
+		if x > sx {
+			ss = append(ss, snake{x: x0 + sx, y: y0 + sy, n: x - sx})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(ss)-1; i < j; i, j = i+1, j-1 {
+		ss[i], ss[j] = ss[j], ss[i]
+	}
+	return ss
+}
+
+// Lines returns the hunks of the differences
+// between the lines of two texts, as split by SplitLines.
+func Lines(a, b string) []Hunk {
+	as, bs := SplitLines(a), SplitLines(b)
+	return Diff(len(as), len(bs), func(i, j int) bool { return as[i] == bs[j] })
+}
+
+// Runes returns the hunks of the differences
+// between the runes of two strings.
+func Runes(a, b string) []Hunk {
+	as, bs := []rune(a), []rune(b)
+	return Diff(len(as), len(bs), func(i, j int) bool { return as[i] == bs[j] })
+}
+
+// SplitLines returns the lines of a text,
+// each including its terminating newline, if any.
+// The last line has no newline if the text does not end with one.
+// The empty text has no lines.
+func SplitLines(text string) []string {
+	var lines []string
+	for text != "" {
+		i := strings.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+		lines = append(lines, text[:i])
+		text = text[i:]
+	}
+	return lines
+}
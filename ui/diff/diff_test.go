@@ -0,0 +1,171 @@
+// Copyright © 2016, The T Authors.
+
+package diff
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []Hunk
+	}{
+		{a: "", b: "", want: nil},
+		{a: "abc", b: "abc", want: []Hunk{{Equal, [2]int{0, 3}, [2]int{0, 3}}}},
+		{a: "", b: "abc", want: []Hunk{{Insert, [2]int{0, 0}, [2]int{0, 3}}}},
+		{a: "abc", b: "", want: []Hunk{{Delete, [2]int{0, 3}, [2]int{0, 0}}}},
+		{
+			a: "abc",
+			b: "abxc",
+			want: []Hunk{
+				{Equal, [2]int{0, 2}, [2]int{0, 2}},
+				{Insert, [2]int{2, 2}, [2]int{2, 3}},
+				{Equal, [2]int{2, 3}, [2]int{3, 4}},
+			},
+		},
+		{
+			a: "abc",
+			b: "axc",
+			want: []Hunk{
+				{Equal, [2]int{0, 1}, [2]int{0, 1}},
+				{Delete, [2]int{1, 2}, [2]int{1, 1}},
+				{Insert, [2]int{2, 2}, [2]int{1, 2}},
+				{Equal, [2]int{2, 3}, [2]int{2, 3}},
+			},
+		},
+		{
+			a: "abcabba",
+			b: "cbabac",
+			want: []Hunk{
+				{Delete, [2]int{0, 2}, [2]int{0, 0}},
+				{Equal, [2]int{2, 3}, [2]int{0, 1}},
+				{Insert, [2]int{3, 3}, [2]int{1, 2}},
+				{Equal, [2]int{3, 5}, [2]int{2, 4}},
+				{Delete, [2]int{5, 6}, [2]int{4, 4}},
+				{Equal, [2]int{6, 7}, [2]int{4, 5}},
+				{Insert, [2]int{7, 7}, [2]int{5, 6}},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := Runes(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Runes(%q, %q)=%v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// TestDiff_Random checks that applying the hunks of random diffs
+// transforms the first sequence into the second,
+// and that the number of differences is minimal
+// compared to the longest common subsequence.
+func TestDiff_Random(t *testing.T) {
+	rand.Seed(1)
+	for i := 0; i < 1000; i++ {
+		a, b := randString(), randString()
+		hs := Runes(a, b)
+		if got := apply(a, b, hs); got != b {
+			t.Fatalf("apply(%q, Runes(%q, %q))=%q, want %q", a, a, b, got, b)
+		}
+		var d int
+		for _, h := range hs {
+			if h.Op != Equal {
+				d += h.A[1] - h.A[0] + h.B[1] - h.B[0]
+			}
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); d != want {
+			t.Fatalf("Runes(%q, %q) has %d differences, want %d", a, b, d, want)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	a := "a\nb\nc\n"
+	b := "a\nx\nc\nd"
+	want := []Hunk{
+		{Equal, [2]int{0, 1}, [2]int{0, 1}},
+		{Delete, [2]int{1, 2}, [2]int{1, 1}},
+		{Insert, [2]int{2, 2}, [2]int{1, 2}},
+		{Equal, [2]int{2, 3}, [2]int{2, 3}},
+		{Insert, [2]int{3, 3}, [2]int{3, 4}},
+	}
+	if got := Lines(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines(%q, %q)=%v, want %v", a, b, got, want)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: nil},
+		{text: "\n", want: []string{"\n"}},
+		{text: "a", want: []string{"a"}},
+		{text: "a\nb", want: []string{"a\n", "b"}},
+		{text: "a\nb\n", want: []string{"a\n", "b\n"}},
+		{text: "\n\n", want: []string{"\n", "\n"}},
+	}
+	for _, test := range tests {
+		if got := SplitLines(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitLines(%q)=%q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func randString() string {
+	n := rand.Intn(20)
+	var s []byte
+	for i := 0; i < n; i++ {
+		s = append(s, "abc"[rand.Intn(3)])
+	}
+	return string(s)
+}
+
+// Apply returns the result of applying hunks to a,
+// checking that Equal hunks match and that the hunks are contiguous.
+func apply(a, b string, hs []Hunk) string {
+	var s strings.Builder
+	var x, y int
+	for _, h := range hs {
+		if h.A[0] != x || h.B[0] != y {
+			return "<not contiguous>"
+		}
+		switch h.Op {
+		case Equal:
+			if a[h.A[0]:h.A[1]] != b[h.B[0]:h.B[1]] {
+				return "<not equal>"
+			}
+			s.WriteString(a[h.A[0]:h.A[1]])
+		case Insert:
+			s.WriteString(b[h.B[0]:h.B[1]])
+		}
+		x, y = h.A[1], h.B[1]
+	}
+	if x != len(a) || y != len(b) {
+		return "<incomplete>"
+	}
+	return s.String()
+}
+
+func lcs(a, b string) int {
+	l := make([][]int, len(a)+1)
+	for i := range l {
+		l[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				l[i][j] = l[i+1][j+1] + 1
+			} else if l[i+1][j] > l[i][j+1] {
+				l[i][j] = l[i+1][j]
+			} else {
+				l[i][j] = l[i][j+1]
+			}
+		}
+	}
+	return l[0][0]
+}
@@ -0,0 +1,613 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/editor/view"
+	"github.com/eaburns/T/ui/diff"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/paint"
+)
+
+const diffTagText = "Del"
+
+// A diffView is a frame showing the differences
+// between the texts of two buffers side by side.
+// Lines deleted from the first text are highlighted on the left,
+// lines inserted into the second text are highlighted on the right,
+// and lines common to both are aligned across from each other.
+// The two sides always scroll together.
+//
+// The diff is recomputed whenever either buffer changes.
+type diffView struct {
+	col *column
+	image.Rectangle
+
+	// Mu protects win, which is read by the goroutine computing the diff.
+	mu  sync.Mutex
+	win *window
+
+	tag *textBox
+	// TagColor is the index of the tag's background color
+	// in the TagBGs of the theme.
+	tagColor int
+	sep      image.Rectangle
+	// Scroll is the scroll bar.
+	// ScrollSep separates the scroll bar from the text.
+	scroll, scrollSep image.Rectangle
+	// Panes are the left and right texts,
+	// and mid separates them.
+	panes [2]diffPane
+	mid   image.Rectangle
+
+	// Views track the entire text of the two buffers.
+	views [2]*view.View
+	urls  [2]*url.URL
+	// Changed receives when either buffer changes.
+	changed chan struct{}
+
+	// Rows are the rows of the diff,
+	// and top is the index of the first visible row.
+	rows []diffRow
+	top  int
+	// Reset is whether the text of the panes must be reset.
+	reset bool
+
+	// SubFocus is either the tag or nil.
+	subFocus handler
+}
+
+// A diffPane is one side of a diff view.
+type diffPane struct {
+	image.Rectangle
+	opts   text.Options
+	setter *text.Setter
+	text   *text.Text
+}
+
+// A diffRow is a row of a diff view:
+// a line of each text, or a line of only one of them.
+type diffRow struct {
+	// Lines are the lines of the texts, without their newlines,
+	// and nums are their 1-based line numbers.
+	// The number is 0 if the row has no line of that text.
+	lines [2]string
+	nums  [2]int
+	// Changed is whether the lines were deleted or inserted.
+	changed bool
+	// Spans are the rune ranges of the lines of a changed row
+	// that differ from the line across from them.
+	spans [2][][2]int
+}
+
+// NewDiffView returns a new diff view, with no column or bounds,
+// showing the differences between the texts of two buffers.
+// The tag begins with the given name.
+func newDiffView(w *window, name string, a, b *url.URL) (*diffView, error) {
+	d := &diffView{win: w, changed: make(chan struct{}, 1), reset: true}
+
+	mu.Lock()
+	d.tagColor = nextTagColor
+	nextTagColor++
+	mu.Unlock()
+
+	tag, err := newTextBox(w, *w.server.editorURL, "", text.Style{
+		Face: w.face,
+		FG:   w.theme.TagFG,
+		BG:   w.theme.tagBG(d.tagColor),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tag.view.DoAsync(edit.Change(edit.All, name+" "+diffTagText+" "),
+		edit.Set(edit.End, '.'))
+	tag.diff = d
+	d.tag = tag
+
+	for i, URL := range []*url.URL{a, b} {
+		v, err := view.New(URL)
+		if err != nil {
+			for j, v := range d.views[:i] {
+				v.Close()
+				if unrefBuffer(d.urls[j]) {
+					editor.Close(d.urls[j])
+				}
+			}
+			tag.close()
+			return nil, err
+		}
+		refBuffer(URL)
+		d.views[i] = v
+		d.urls[i] = URL
+	}
+	for i := range d.panes {
+		d.panes[i].setter = text.NewSetter(d.panes[i].opts)
+		d.panes[i].text = d.panes[i].setter.Set()
+	}
+
+	var wg sync.WaitGroup
+	for _, v := range d.views {
+		wg.Add(1)
+		go func(v *view.View) {
+			defer wg.Done()
+			for range v.Notify {
+				select {
+				case d.changed <- struct{}{}:
+				default:
+				}
+			}
+		}(v)
+		// Track the entire text; this also sends the first notification.
+		v.Resize(math.MaxInt32)
+	}
+	go func() {
+		wg.Wait()
+		close(d.changed)
+	}()
+	go d.run()
+	return d, nil
+}
+
+// Run recomputes the rows of the diff each time either buffer changes,
+// until the views are closed.
+func (d *diffView) run() {
+	for range d.changed {
+		var texts [2]string
+		for i, v := range d.views {
+			v.View(func(text []byte, _ []view.Mark) { texts[i] = string(text) })
+		}
+		rows := diffRows(texts[0], texts[1])
+		d.mu.Lock()
+		if d.win != nil {
+			d.win.Send(func() { d.setRows(rows) })
+		}
+		d.mu.Unlock()
+	}
+}
+
+// SetRows sets the rows of the diff,
+// keeping the top row in range.
+func (d *diffView) setRows(rows []diffRow) {
+	d.rows = rows
+	d.scrollRows(0)
+}
+
+// DiffRows returns the rows of the diff between two texts.
+// Runs of deleted lines followed by inserted lines
+// are paired up, side by side.
+func diffRows(a, b string) []diffRow {
+	as, bs := diff.SplitLines(a), diff.SplitLines(b)
+	hs := diff.Diff(len(as), len(bs), func(i, j int) bool { return as[i] == bs[j] })
+	var rows []diffRow
+	for i := 0; i < len(hs); i++ {
+		h := hs[i]
+		switch h.Op {
+		case diff.Equal:
+			for k := 0; k < h.A[1]-h.A[0]; k++ {
+				x, y := h.A[0]+k, h.B[0]+k
+				rows = append(rows, diffRow{
+					lines: [2]string{trimNewline(as[x]), trimNewline(bs[y])},
+					nums:  [2]int{x + 1, y + 1},
+				})
+			}
+		case diff.Delete:
+			ins := h.B
+			if i+1 < len(hs) && hs[i+1].Op == diff.Insert {
+				i++
+				ins = hs[i].B
+			}
+			rows = append(rows, changedRows(as, bs, h.A, ins)...)
+		case diff.Insert:
+			rows = append(rows, changedRows(as, bs, h.A, h.B)...)
+		}
+	}
+	return rows
+}
+
+// ChangedRows returns the rows of the lines in range a of as
+// replaced by the lines in range b of bs.
+func changedRows(as, bs []string, a, b [2]int) []diffRow {
+	n := a[1] - a[0]
+	if m := b[1] - b[0]; m > n {
+		n = m
+	}
+	rows := make([]diffRow, n)
+	for k := range rows {
+		r := &rows[k]
+		r.changed = true
+		if x := a[0] + k; x < a[1] {
+			r.lines[0] = trimNewline(as[x])
+			r.nums[0] = x + 1
+		}
+		if y := b[0] + k; y < b[1] {
+			r.lines[1] = trimNewline(bs[y])
+			r.nums[1] = y + 1
+		}
+		if r.nums[0] == 0 || r.nums[1] == 0 {
+			continue
+		}
+		for _, h := range diff.Runes(r.lines[0], r.lines[1]) {
+			switch h.Op {
+			case diff.Delete:
+				r.spans[0] = append(r.spans[0], h.A)
+			case diff.Insert:
+				r.spans[1] = append(r.spans[1], h.B)
+			}
+		}
+	}
+	return rows
+}
+
+func trimNewline(line string) string { return strings.TrimSuffix(line, "\n") }
+
+// OpenDiff opens a diff view below a frame of the window,
+// showing the differences between the texts of two buffers.
+func (w *window) openDiff(below frame, name string, a, b *url.URL) (*diffView, error) {
+	d, err := newDiffView(w, name, a, b)
+	if err != nil {
+		return nil, err
+	}
+	if below == nil || !w.addFrameBelow(d, below) {
+		w.addFrame(d)
+	}
+	return d, nil
+}
+
+// ExecBuiltin executes a built-in command on the diff view,
+// and returns whether the command line named a built-in command.
+// The only built-in command of a diff view is Del, which deletes it.
+func (d *diffView) execBuiltin(commandLine string) bool {
+	if name, _ := splitCommand(commandLine); name != "Del" {
+		return false
+	}
+	d.win.deleteFrame(d)
+	return true
+}
+
+func (d *diffView) close() {
+	d.mu.Lock()
+	if d.win == nil {
+		// Already closed.
+		d.mu.Unlock()
+		return
+	}
+	d.win = nil
+	d.mu.Unlock()
+
+	d.tag.close()
+	for i, v := range d.views {
+		v.Close()
+		if unrefBuffer(d.urls[i]) {
+			editor.Close(d.urls[i])
+		}
+	}
+	for i := range d.panes {
+		d.panes[i].text.Release()
+		d.panes[i].setter.Release()
+	}
+}
+
+func (d *diffView) minHeight() int { return minHeight(d.tag.opts) }
+
+func (d *diffView) bounds() image.Rectangle { return d.Rectangle }
+
+func (d *diffView) setBounds(b image.Rectangle) {
+	d.Rectangle = b
+	d.updateText()
+}
+
+func (d *diffView) setColumn(c *column) { d.col = c }
+
+func (d *diffView) focus(p image.Point) handler {
+	prev := d.subFocus
+	if p.Y < d.sep.Min.Y {
+		d.subFocus = d.tag
+	} else if p.Y >= d.sep.Max.Y {
+		d.subFocus = nil
+	}
+	if d.subFocus != prev {
+		if prev != nil {
+			prev.changeFocus(d.win, false)
+		}
+		if d.subFocus != nil {
+			d.subFocus.changeFocus(d.win, true)
+		}
+		// Always redraw on focus change.
+		d.win.Send(paint.Event{})
+	}
+	return d
+}
+
+func (d *diffView) updateText() {
+	b := &d.Rectangle
+
+	tagMax := b.Dy()
+	if min := d.minHeight(); tagMax < min {
+		tagMax = min
+	}
+	d.tag.topLeft = b.Min
+	d.tag.setSize(image.Pt(b.Dx(), tagMax))
+	tagHeight := d.tag.text.LinesHeight()
+
+	y := b.Min.Y + tagHeight + borderWidth
+	if y > b.Max.Y {
+		// Only the tag shows.
+		y = b.Max.Y
+	}
+	d.sep = image.Rect(b.Min.X, y-borderWidth, b.Max.X, y)
+	scrollX := b.Min.X + d.win.px(scrollWidth)
+	if scrollX > b.Max.X {
+		scrollX = b.Max.X
+	}
+	d.scroll = image.Rect(b.Min.X, y, scrollX, b.Max.Y)
+	d.scrollSep = image.Rect(scrollX, y, scrollX+borderWidth, b.Max.Y)
+
+	x0 := d.scrollSep.Max.X
+	midX := x0 + (b.Max.X-x0)/2
+	if midX < x0 {
+		midX = x0
+	}
+	d.mid = image.Rect(midX, y, midX+borderWidth, b.Max.Y)
+	rs := [2]image.Rectangle{
+		image.Rect(x0, y, midX, b.Max.Y),
+		image.Rect(d.mid.Max.X, y, b.Max.X, b.Max.Y),
+	}
+	for i := range d.panes {
+		if d.panes[i].Rectangle != rs[i] {
+			d.panes[i].Rectangle = rs[i]
+			d.reset = true
+		}
+	}
+	if d.reset {
+		d.reset = false
+		for i := range d.panes {
+			d.setPaneText(i)
+		}
+	}
+}
+
+// SetPaneText sets the text of a pane
+// to the visible rows of its side of the diff.
+func (d *diffView) setPaneText(i int) {
+	th := d.win.theme
+	p := &d.panes[i]
+	size := p.Size()
+	if size.X < 0 {
+		size.X = 0
+	}
+	p.opts = text.Options{
+		Size: size,
+		DefaultStyle: text.Style{
+			Face: d.tag.opts.DefaultStyle.Face,
+			FG:   th.BodyFG,
+			BG:   th.BodyBG,
+		},
+		TabWidth: 4,
+		Padding:  d.win.px(textPadding),
+		Wrap:     text.NoWrap,
+	}
+	plain := p.opts.DefaultStyle
+	filler, changed, span := plain, plain, plain
+	filler.BG = th.GutterBG
+	changed.BG, span.BG = th.DiffDelete, th.DiffDeleteSpan
+	if i == 1 {
+		changed.BG, span.BG = th.DiffInsert, th.DiffInsertSpan
+	}
+
+	rows := d.rows[d.top:]
+	if n := d.visibleRows(); len(rows) > n {
+		rows = rows[:n]
+	}
+	p.text.Release()
+	p.setter.Reset(p.opts)
+	for _, r := range rows {
+		switch {
+		case r.nums[i] == 0:
+			p.setter.AddStyle(&filler, []byte("\n"))
+		case !r.changed:
+			p.setter.AddStyle(&plain, []byte(r.lines[i]+"\n"))
+		default:
+			rs := []rune(r.lines[i])
+			var at int
+			for _, s := range r.spans[i] {
+				p.setter.AddStyle(&changed, []byte(string(rs[at:s[0]])))
+				p.setter.AddStyle(&span, []byte(string(rs[s[0]:s[1]])))
+				at = s[1]
+			}
+			p.setter.AddStyle(&changed, []byte(string(rs[at:])+"\n"))
+		}
+	}
+	p.text = p.setter.Set()
+}
+
+// VisibleRows returns the number of rows that fit in the panes.
+func (d *diffView) visibleRows() int {
+	h := d.tag.opts.DefaultStyle.Face.Metrics().Height.Round()
+	if h <= 0 {
+		return 0
+	}
+	return (d.panes[0].Dy() - 2*d.win.px(textPadding)) / h
+}
+
+// ScrollRows scrolls the diff by delta rows,
+// keeping at least the last row visible.
+func (d *diffView) scrollRows(delta int) {
+	top := d.top + delta
+	if top > len(d.rows)-1 {
+		top = len(d.rows) - 1
+	}
+	if top < 0 {
+		top = 0
+	}
+	d.top = top
+	d.reset = true
+}
+
+// ScrollClick scrolls the diff in response to a button press
+// at y pixels from the top of the scroll bar,
+// like textBox.scrollClick.
+func (d *diffView) scrollClick(b mouse.Button, y int) {
+	rows := y / d.tag.opts.DefaultStyle.Face.Metrics().Height.Round()
+	if rows < 1 {
+		rows = 1
+	}
+	switch b {
+	case mouse.ButtonLeft:
+		d.scrollRows(-rows)
+	case mouse.ButtonRight:
+		d.scrollRows(rows)
+	case mouse.ButtonMiddle:
+		if h := d.scroll.Dy(); h > 0 {
+			d.scrollRows(len(d.rows)*y/h - d.top)
+		}
+	}
+}
+
+func (d *diffView) draw(scr screen.Screen, win screen.Window) {
+	d.updateText()
+
+	d.tag.drawLines(scr, win)
+	sepColor := d.win.theme.Separator
+	win.Fill(d.sep, sepColor, draw.Over)
+	win.Fill(d.scroll, d.win.theme.ScrollBG, draw.Src)
+	th := thumb(d.scroll, int64(d.top), d.visibleRows(), int64(len(d.rows)))
+	win.Fill(th, d.win.theme.ScrollThumb, draw.Src)
+	win.Fill(d.scrollSep, sepColor, draw.Over)
+	for i := range d.panes {
+		d.panes[i].text.Draw(d.panes[i].Min, scr, win)
+	}
+	win.Fill(d.mid, sepColor, draw.Over)
+}
+
+func (d *diffView) drawLast(scr screen.Screen, win screen.Window) {}
+
+func (d *diffView) changeFocus(win *window, inFocus bool) {
+	if d.subFocus != nil {
+		d.subFocus.changeFocus(win, inFocus)
+	}
+}
+
+func (d *diffView) tick(win *window) bool {
+	if d.subFocus != nil {
+		return d.subFocus.tick(win)
+	}
+	return false
+}
+
+func (d *diffView) key(w *window, event key.Event) bool {
+	if d.subFocus != nil {
+		return d.subFocus.key(w, event)
+	}
+	if event.Direction == key.DirRelease {
+		return false
+	}
+	switch boundName(d.tag.keymap(), event) {
+	case "up":
+		d.scrollRows(-1)
+		return true
+	case "down":
+		d.scrollRows(1)
+		return true
+	}
+	return false
+}
+
+func (d *diffView) mouse(w *window, event mouse.Event) bool {
+	if d.subFocus != nil {
+		return d.subFocus.mouse(w, event)
+	}
+	p := image.Pt(int(event.X), int(event.Y))
+	switch event.Direction {
+	case mouse.DirStep:
+		switch event.Button {
+		case mouse.ButtonWheelUp:
+			d.scrollRows(-wheelLines)
+			return true
+		case mouse.ButtonWheelDown:
+			d.scrollRows(wheelLines)
+			return true
+		}
+	case mouse.DirPress:
+		if event.Modifiers == 0 && p.In(d.scroll) {
+			d.scrollClick(event.Button, p.Y-d.scroll.Min.Y)
+			return true
+		}
+	}
+	return false
+}
+
+// DiffCmd opens a diff view below the sheet.
+// With no arguments, it shows the differences
+// between the file named in the tag, as it is on disk, and the body.
+// Otherwise, it shows the differences
+// between the body of the sheet named by the arguments and the body.
+func diffCmd(s *sheet, args string) {
+	w := s.win
+	if args != "" {
+		o, _, ok := w.namedSheet(args)
+		if !ok {
+			s.errorf("Diff: no sheet %s", args)
+			return
+		}
+		name := "+Diff " + args + " " + s.tagFileName()
+		if _, err := w.openDiff(s, name, o.body.bufferURL, s.body.bufferURL); err != nil {
+			s.errorf("Diff: %v", err)
+		}
+		return
+	}
+	file := s.filePath()
+	if file == "" {
+		s.errorf("Diff: no file name")
+		return
+	}
+	go func() {
+		text, _, err := w.readText(file)
+		w.Send(func() {
+			if err != nil {
+				s.errorf("Diff %s: %v", file, err)
+				return
+			}
+			if err := openFileDiff(s, file, text); err != nil {
+				s.errorf("Diff %s: %v", file, err)
+			}
+		})
+	}()
+}
+
+// OpenFileDiff opens a diff view below the sheet
+// showing the differences between the text of a file and the body.
+// The text of the file is copied into a new buffer,
+// which is closed with the diff view.
+func openFileDiff(s *sheet, file, text string) error {
+	if s.win == nil {
+		// The sheet was closed while reading the file.
+		return nil
+	}
+	URL := *s.win.server.editorURL
+	URL.Path = path.Join("/", "buffers")
+	buf, err := editor.NewBuffer(&URL)
+	if err != nil {
+		return err
+	}
+	URL.Path = buf.Path
+	d, err := s.win.openDiff(s, "+Diff "+file, &URL, s.body.bufferURL)
+	if err != nil {
+		editor.Close(&URL)
+		return err
+	}
+	d.views[0].DoAsync(edit.Change(edit.All, text))
+	return nil
+}
@@ -0,0 +1,247 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestDiffRows(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []diffRow
+	}{
+		{a: "", b: "", want: nil},
+		{
+			a: "a\nb\n",
+			b: "a\nb\n",
+			want: []diffRow{
+				{lines: [2]string{"a", "a"}, nums: [2]int{1, 1}},
+				{lines: [2]string{"b", "b"}, nums: [2]int{2, 2}},
+			},
+		},
+		{
+			a: "a\nc\n",
+			b: "a\nb\nc\n",
+			want: []diffRow{
+				{lines: [2]string{"a", "a"}, nums: [2]int{1, 1}},
+				{lines: [2]string{"", "b"}, nums: [2]int{0, 2}, changed: true},
+				{lines: [2]string{"c", "c"}, nums: [2]int{2, 3}},
+			},
+		},
+		{
+			a: "a\nb\nc\n",
+			b: "a\nc\n",
+			want: []diffRow{
+				{lines: [2]string{"a", "a"}, nums: [2]int{1, 1}},
+				{lines: [2]string{"b", ""}, nums: [2]int{2, 0}, changed: true},
+				{lines: [2]string{"c", "c"}, nums: [2]int{3, 2}},
+			},
+		},
+		{
+			a: "x = 1\nx\n",
+			b: "x = 23\n",
+			want: []diffRow{
+				{
+					lines:   [2]string{"x = 1", "x = 23"},
+					nums:    [2]int{1, 1},
+					changed: true,
+					spans:   [2][][2]int{{{4, 5}}, {{4, 6}}},
+				},
+				{lines: [2]string{"x", ""}, nums: [2]int{2, 0}, changed: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := diffRows(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("diffRows(%q, %q)=%+v, want %+v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDiffCmd_File(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	f, err := ioutil.TempFile("", "diffview_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("a\nb\nc\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet0.setTagFileName(f.Name())
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "a\nx\nc\n")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	w.Send(func() { sheet0.execBuiltin("Diff") })
+	d := waitDiffView(t, w, sheet0)
+	want := []diffRow{
+		{lines: [2]string{"a", "a"}, nums: [2]int{1, 1}},
+		{
+			lines:   [2]string{"b", "x"},
+			nums:    [2]int{2, 2},
+			changed: true,
+			spans:   [2][][2]int{{{0, 1}}, {{0, 1}}},
+		},
+		{lines: [2]string{"c", "c"}, nums: [2]int{3, 3}},
+	}
+	if got := waitDiffRows(w, d, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows=%+v, want %+v", got, want)
+	}
+
+	// The diff is updated when the body changes.
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "a\nb\nc\n")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	want = []diffRow{
+		{lines: [2]string{"a", "a"}, nums: [2]int{1, 1}},
+		{lines: [2]string{"b", "b"}, nums: [2]int{2, 2}},
+		{lines: [2]string{"c", "c"}, nums: [2]int{3, 3}},
+	}
+	if got := waitDiffRows(w, d, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows=%+v, want %+v", got, want)
+	}
+
+	// Del closes the diff view and the buffer of the file,
+	// but not the body's buffer.
+	fileURL := *d.urls[0]
+	w.Send(func() { d.tag.exec("Del") })
+	wait(w)
+	if i := frameIndex(sheet0.col, d); i >= 0 {
+		t.Errorf("diff view is still in the column at %d", i)
+	}
+	if _, err := editor.BufferInfo(&fileURL); err == nil {
+		t.Errorf("BufferInfo(%s)=_,nil, want error", fileURL.String())
+	}
+	if _, err := editor.BufferInfo(sheet0.body.bufferURL); err != nil {
+		t.Errorf("BufferInfo(%s)=_,%v", sheet0.body.bufferURL, err)
+	}
+}
+
+func TestDiffCmd_Sheet(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+	sheet1.setTagFileName("other")
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "a\nb\n")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	if _, err := sheet1.body.doSync(edit.Change(edit.All, "b\n")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	waitText(sheet1.tag, "other")
+	w.Send(func() { sheet0.execBuiltin("Diff other") })
+	d := waitDiffView(t, w, sheet0)
+	want := []diffRow{
+		{lines: [2]string{"", "a"}, nums: [2]int{0, 1}, changed: true},
+		{lines: [2]string{"b", "b"}, nums: [2]int{1, 2}},
+	}
+	if got := waitDiffRows(w, d, want); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows=%+v, want %+v", got, want)
+	}
+}
+
+func TestDiffView_Scroll(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+	var text string
+	for i := 0; i < 100; i++ {
+		text += "line\n"
+	}
+	if _, err := sheet1.body.doSync(edit.Change(edit.All, text)); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	var d *diffView
+	w.Send(func() {
+		var err error
+		d, err = w.openDiff(sheet0, "+Diff", sheet0.body.bufferURL, sheet1.body.bufferURL)
+		if err != nil {
+			t.Errorf("openDiff(…)=_,%v", err)
+		}
+	})
+	wait(w)
+	if d == nil {
+		t.FailNow()
+	}
+	for i := 0; i < 100; i++ {
+		var n int
+		w.Send(func() { n = len(d.rows) })
+		wait(w)
+		if n == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var p image.Point
+	w.Send(func() { p = d.panes[1].Min.Add(d.panes[1].Size().Div(2)) })
+	wait(w)
+	mouseTo(w, p)
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonWheelDown, Direction: mouse.DirStep})
+	wait(w)
+	var top int
+	w.Send(func() { top = d.top })
+	wait(w)
+	if top != wheelLines {
+		t.Errorf("top=%d, want %d", top, wheelLines)
+	}
+
+	// Scrolling stops at the last row.
+	w.Send(func() { d.scrollRows(1000) })
+	wait(w)
+	w.Send(func() { top = d.top })
+	wait(w)
+	if top != 99 {
+		t.Errorf("top=%d, want 99", top)
+	}
+}
+
+// WaitDiffView returns the frame below s
+// once it is a diff view, or fails after a timeout.
+func waitDiffView(t *testing.T, w *window, s *sheet) *diffView {
+	for i := 0; i < 100; i++ {
+		var d *diffView
+		w.Send(func() {
+			if i := frameIndex(s.col, s); i+1 < len(s.col.frames) {
+				d, _ = s.col.frames[i+1].(*diffView)
+			}
+		})
+		wait(w)
+		if d != nil {
+			return d
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no diff view")
+	return nil
+}
+
+// WaitDiffRows returns the rows of a diff view
+// once they are the wanted rows, or after a timeout.
+func waitDiffRows(w *window, d *diffView, want []diffRow) []diffRow {
+	var got []diffRow
+	for i := 0; i < 100 && !reflect.DeepEqual(got, want); i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		w.Send(func() { got = d.rows })
+		wait(w)
+	}
+	return got
+}
@@ -0,0 +1,200 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/mouse"
+)
+
+const (
+	// DragDistance is how far the pointer must move
+	// after a button 1 press inside a non-empty dot
+	// before the text of dot is dragged.
+	dragDistance = 10 // px
+
+	// GhostLines is the maximum number of lines of dragged text
+	// shown at the pointer.
+	ghostLines = 3
+)
+
+// A textDrag is text dragged from a text box with button 1.
+//
+// Dragged text that is dropped on a tag is executed,
+// and dragged text that is dropped on a sheet body
+// is inserted at the drop point.
+type textDrag struct {
+	// From is the text box from which the text is dragged.
+	from *textBox
+	text string
+	// P is the current location of the pointer.
+	p image.Point
+
+	// Loading is whether the text is still being read from the text box.
+	loading bool
+	// Dropped is whether the text was dropped at p.
+	// Text dropped while loading is dropped once it is loaded.
+	dropped bool
+}
+
+// DragText handles the mouse events that drag the text of dot.
+// It returns whether the event was handled,
+// and whether to redraw the window.
+//
+// A button 1 press inside a non-empty dot is held back.
+// If the pointer moves dragDistance from the press,
+// the text of dot is dragged until button 1 is released.
+// Otherwise, the press is handled by handleMouse as usual,
+// once button 1 is released or another button is pressed.
+func (t *textBox) dragText(w *window, event mouse.Event) (handled, redraw bool) {
+	p := image.Pt(int(event.X), int(event.Y))
+	switch {
+	case w.textDrag != nil && w.textDrag.from == t:
+		switch event.Direction {
+		case mouse.DirNone:
+			w.textDrag.p = p
+			return true, true
+		case mouse.DirRelease:
+			if event.Button == mouse.ButtonLeft {
+				w.dropText(p)
+				return true, true
+			}
+		}
+		return true, false
+
+	case t.dragPress != nil:
+		press := *t.dragPress
+		switch event.Direction {
+		case mouse.DirNone:
+			d := p.Sub(press)
+			if n := scalePx(dragDistance, t.scale); d.X*d.X+d.Y*d.Y <= n*n {
+				return true, false
+			}
+			t.dragPress = nil
+			drag := &textDrag{from: t, p: p, loading: true}
+			w.textDrag = drag
+			t.doThen(func(res []editor.EditResult, err error) {
+				drag.loading = false
+				switch {
+				case err != nil:
+					t.logf("failed to read dragged text: %v", err)
+				case res[0].Error != "":
+					t.logf("failed to read dragged text: %s", res[0].Error)
+				default:
+					drag.text = res[0].Print
+					if drag.dropped {
+						w.drop(drag)
+					}
+					return
+				}
+				if w.textDrag == drag {
+					w.textDrag = nil
+				}
+			}, edit.Print(dot))
+			return true, true
+		case mouse.DirRelease, mouse.DirPress:
+			t.dragPress = nil
+			handleMouse(t, mouse.Event{
+				X:         float32(press.X),
+				Y:         float32(press.Y),
+				Button:    mouse.ButtonLeft,
+				Direction: mouse.DirPress,
+			})
+		}
+		return false, false
+
+	case event.Direction == mouse.DirPress && event.Button == mouse.ButtonLeft &&
+		event.Modifiers == 0 && t.held == mouse.ButtonNone:
+		at := t.where(p)
+		if t.dot0 == t.dot1 || at < t.dot0 || at >= t.dot1 {
+			return false, false
+		}
+		if c := t.last; c.n > 0 && c.at == at && t.now().Sub(c.time) < doubleClickTime {
+			// It's a multi-click.
+			return false, false
+		}
+		t.dragPress = &p
+		return true, false
+	}
+	return false, false
+}
+
+// DropText drops the dragged text at a point of the window.
+// Text dropped on a tag is executed,
+// and text dropped on a sheet body is inserted at the point,
+// unless it is dropped back onto the dragged text.
+// If the text is still loading, it is dropped once it is loaded.
+func (w *window) dropText(p image.Point) {
+	d := w.textDrag
+	w.textDrag = nil
+	d.p, d.dropped = p, true
+	if !d.loading {
+		w.drop(d)
+	}
+}
+
+// Drop drops loaded dragged text at its point.
+func (w *window) drop(d *textDrag) {
+	p := d.p
+	t := w.textBoxAt(p)
+	switch {
+	case t == nil:
+		return
+	case t.sheet == nil || t != t.sheet.body:
+		t.exec(d.text)
+	default:
+		at := t.where(p)
+		if t == d.from && t.dot0 <= at && at <= t.dot1 {
+			return
+		}
+		t.doAsync(edit.Change(edit.Rune(at), d.text))
+	}
+}
+
+// TextBoxAt returns the text box containing a point of the window, or nil.
+func (w *window) textBoxAt(p image.Point) *textBox {
+	if p.In(w.tag.bounds()) {
+		return w.tag.text
+	}
+	for _, c := range w.columns {
+		if !p.In(c.bounds()) {
+			continue
+		}
+		switch _, f := frameAt(c, p.Y-c.Min.Y); f := f.(type) {
+		case *columnTag:
+			return f.text
+		case *sheet:
+			switch {
+			case p.Y < f.sep.Min.Y:
+				return f.tag
+			case p.Y >= f.sep.Max.Y && !p.In(f.scroll):
+				return f.body
+			}
+		}
+	}
+	return nil
+}
+
+// DrawTextDrag draws the dragged text, if any,
+// at the pointer.
+func (w *window) drawTextDrag(scr screen.Screen, win screen.Window) {
+	if w.textDrag != nil && !w.textDrag.loading {
+		w.drawTip(scr, win, ghostText(w.textDrag.text), w.textDrag.p)
+	}
+}
+
+// GhostText returns the text shown at the pointer
+// while dragging text:
+// at most ghostLines lines, followed by … if any are elided.
+func ghostText(text string) string {
+	lines := strings.SplitN(strings.TrimSuffix(text, "\n"), "\n", ghostLines+1)
+	if len(lines) > ghostLines {
+		lines[ghostLines] = "…"
+	}
+	return strings.Join(lines, "\n")
+}
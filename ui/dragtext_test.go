@@ -0,0 +1,152 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor/view"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestDragText_Insert(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+	setTextDot(t, w, sheet0.body, "hello world", edit.Span{0, 5})
+
+	p := sheet0.body.topLeft.Add(image.Pt(1, 1))
+	dragLeft(w, p, sheet1.body.topLeft.Add(image.Pt(1, 1)))
+
+	if got := waitText(sheet1.body, "hello"); got != "hello" {
+		t.Errorf("sheet1 body text=%q, want %q", got, "hello")
+	}
+	if got := waitText(sheet0.body, "hello world"); got != "hello world" {
+		t.Errorf("sheet0 body text=%q, want %q", got, "hello world")
+	}
+	var drag *textDrag
+	w.Send(func() { drag = w.textDrag })
+	wait(w)
+	if drag != nil {
+		t.Errorf("textDrag=%v, want nil", drag)
+	}
+}
+
+func TestDragText_OntoItself(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, "hello world", edit.Span{0, 11})
+
+	p := sheet0.body.topLeft.Add(image.Pt(1, 1))
+	dragLeft(w, p, p.Add(image.Pt(20, 0)))
+
+	res, err := sheet0.body.view.Do(edit.Print(edit.All), edit.Where(edit.Dot))
+	if err != nil || res[0].Print != "hello world" || res[1].Print != "#0,#11\n" {
+		t.Errorf("sheet0 body text, dot=%v,%v, want \"hello world\",\"#0,#11\\n\"", res, err)
+	}
+}
+
+func TestDragText_Exec(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, "Newcol", edit.Span{0, 6})
+
+	p := sheet0.body.topLeft.Add(image.Pt(1, 1))
+	dragLeft(w, p, center(w.tag))
+
+	var n int
+	for i := 0; i < 100 && n != 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { n = len(w.columns) })
+		wait(w)
+	}
+	if n != 4 {
+		t.Errorf("len(w.columns)=%d, want 4", n)
+	}
+}
+
+func TestDragText_Click(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, "hello world", edit.Span{0, 5})
+
+	// Clicking inside dot without dragging sets dot as usual.
+	p := sheet0.body.topLeft.Add(image.Pt(1, 1))
+	mouseTo(w, p)
+	click(w, p, mouse.ButtonLeft)
+	wait(w)
+
+	res, err := sheet0.body.doSync(edit.Where(edit.Dot))
+	if err != nil || res[0].Print != "#0\n" {
+		t.Errorf("sheet0 body dot=%v,%v, want \"#0\\n\"", res, err)
+	}
+}
+
+func TestGhostText(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{text: "", want: ""},
+		{text: "abc", want: "abc"},
+		{text: "abc\n", want: "abc"},
+		{text: "a\nb\nc\n", want: "a\nb\nc"},
+		{text: "a\nb\nc\nd", want: "a\nb\nc\n…"},
+		{text: "a\nb\nc\nd\ne\n", want: "a\nb\nc\n…"},
+	}
+	for _, test := range tests {
+		if got := ghostText(test.text); got != test.want {
+			t.Errorf("ghostText(%q)=%q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+// SetTextDot sets the text and dot of a text box,
+// and waits for the text box to draw the new dot.
+func setTextDot(t *testing.T, w *window, tb *textBox, text string, dot edit.Span) {
+	if _, err := tb.doSync(edit.Change(edit.All, text), edit.Set(edit.Rune(dot[0]).To(edit.Rune(dot[1])), '.')); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	var d edit.Span
+	for i := 0; i < 100 && d != dot; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { d = edit.Span{tb.dot0, tb.dot1} })
+		wait(w)
+	}
+	if d != dot {
+		t.Fatalf("dot=%v, want %v", d, dot)
+	}
+}
+
+// DragLeft drags button 1 from one point to another of a window.
+func dragLeft(w *window, from, to image.Point) {
+	mouseTo(w, from)
+	w.Send(mouse.Event{X: float32(from.X), Y: float32(from.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	mouseTo(w, to)
+	w.Send(mouse.Event{X: float32(to.X), Y: float32(to.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	wait(w)
+}
+
+// WaitText returns the text of a text box
+// once it is the wanted text, or after a timeout.
+// It does not change dot.
+func waitText(tb *textBox, want string) string {
+	var got string
+	for i := 0; i < 100 && got != want; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		res, err := tb.view.Do(edit.Set(dot, view.TmpMark), edit.Print(edit.All), edit.Set(edit.Mark(view.TmpMark), '.'))
+		if err != nil || res[1].Error != "" {
+			return ""
+		}
+		got = res[1].Print
+	}
+	return got
+}
@@ -0,0 +1,73 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/eaburns/T/edit"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) dropHandler(w http.ResponseWriter, req *http.Request) {
+	var dreq DropRequest
+	if err := json.NewDecoder(req.Body).Decode(&dreq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.Lock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.Unlock()
+		http.NotFound(w, req)
+		return
+	}
+	fs, err := s.dropFiles(win, dreq.Paths, dreq.X, dreq.Y)
+	if err != nil {
+		s.Unlock()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]Sheet, 0, len(fs))
+	for _, f := range fs {
+		resp = append(resp, makeSheet(f))
+	}
+	s.Unlock()
+	respond(w, resp)
+}
+
+// DropFiles opens files dropped on a window at x, y,
+// where x is a fraction of the window width,
+// and y is a fraction of the column height.
+// Each file is opened in a new sheet,
+// added to the column under the point, at the point,
+// as if the sheet were moved there.
+// If a sheet does not fit there, it is added as if newly created.
+// The files are loaded asynchronously;
+// errors are written to the window's output sheet.
+//
+// This method must be called with the server lock held.
+func (s *Server) dropFiles(win *window, paths []string, x, y float64) ([]*sheet, error) {
+	var fs []*sheet
+	for _, p := range paths {
+		f, err := newSheet(strconv.Itoa(s.nextID), s.editorURL, win)
+		if err != nil {
+			return fs, err
+		}
+		s.nextID++
+		s.sheets[f.id] = f
+		win.Send(func() {
+			_, c := columnAt(win, int(x*float64(win.Dx())))
+			if !c.addFrame(y, f) {
+				win.addFrame(f)
+			}
+		})
+		f.setTagFileName(p)
+		f.load(p, edit.Rune(0))
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
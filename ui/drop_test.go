@@ -0,0 +1,71 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestDropFiles(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	dir, err := ioutil.TempDir("", "T_drop_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("hello\n"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(…)=%v", err)
+	}
+
+	dropURL := urlWithPath(s.url, "/", "window", w.id, "drop")
+	sheets, err := DropFiles(dropURL, []string{file}, 0.5, 0.5)
+	if err != nil || len(sheets) != 1 {
+		t.Fatalf("DropFiles(%s, {%q}, 0.5, 0.5)=%v,%v, want 1 sheet", dropURL, file, sheets, err)
+	}
+	s.uiServer.RLock()
+	f := s.uiServer.sheets[sheets[0].ID]
+	s.uiServer.RUnlock()
+
+	// The sheet is in the column under the drop, at the drop.
+	var col *column
+	var y float64
+	w.Send(func() {
+		if col = f.col; col != nil {
+			y = col.ys[frameIndex(col, f)]
+		}
+	})
+	wait(w)
+	if col != w.columns[2] || y != 0.5 {
+		t.Errorf("dropped sheet in column %p at %g, want column %p at 0.5", col, y, w.columns[2])
+	}
+
+	var text string
+	for i := 0; i < 100 && text != "hello\n"; i++ {
+		res, err := f.body.doSync(edit.Print(edit.All))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("f.body.doSync(Print(All))=%v,%v", res, err)
+		}
+		text = res[0].Print
+		time.Sleep(10 * time.Millisecond)
+	}
+	if text != "hello\n" {
+		t.Errorf("dropped sheet body=%q, want %q", text, "hello\n")
+	}
+	if name := f.tagFileName(); name != file {
+		t.Errorf("dropped sheet tag file name=%q, want %q", name, file)
+	}
+
+	notFoundURL := urlWithPath(s.url, "/", "window", "notfound", "drop")
+	if _, err := DropFiles(notFoundURL, []string{file}, 0, 0); err != ErrNotFound {
+		t.Errorf("DropFiles(%s, …)=_,%v, want %v", notFoundURL, err, ErrNotFound)
+	}
+}
@@ -4,10 +4,13 @@ package ui
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/eaburns/T/edit"
@@ -40,6 +43,13 @@ func TestMouseHandler(t *testing.T) {
 		// Cmds are commands expected to be executed.
 		cmds []string
 
+		// Looks are texts expected to be plumbed.
+		looks []string
+
+		// Snarf is the initial snarf buffer text,
+		// and wantSnarf is the desired final snarf buffer text.
+		snarf, wantSnarf string
+
 		// If Skip is true the test is not run.
 		Skip bool
 	}{
@@ -93,6 +103,153 @@ func TestMouseHandler(t *testing.T) {
 			want:   "abc\ne{..}nv\nxyz",
 			cmds:   []string{"env"},
 		},
+		{
+			name:   "3-click",
+			given:  "{..}abc\nmain.go:12 xyz",
+			events: rightClick(image.Pt(2, 2)),
+			want:   "abc\n{.}main.go:12{.} xyz",
+			looks:  []string{"main.go:12"},
+		},
+		{
+			name:   "3-click word",
+			given:  "{..}abc def",
+			events: rightClick(image.Pt(5, 1)),
+			want:   "abc {.}def{.}",
+			looks:  []string{"def"},
+		},
+		{
+			name:   "double-click word",
+			given:  "{..}abc\nfoo_bar1 baz",
+			events: doubleClick(image.Pt(2, 2)),
+			want:   "abc\n{.}foo_bar1{.} baz",
+		},
+		{
+			name:   "double-click between words",
+			given:  "{..}abc def",
+			events: doubleClick(image.Pt(3, 1)),
+			want:   "{.}abc{.} def",
+		},
+		{
+			name:   "double-click after open bracket",
+			given:  "{..}f(a(b)c)d",
+			events: doubleClick(image.Pt(2, 1)),
+			want:   "f({.}a(b)c{.})d",
+		},
+		{
+			name:   "double-click before close bracket",
+			given:  "{..}f(a(b)c)d",
+			events: doubleClick(image.Pt(7, 1)),
+			want:   "f({.}a(b)c{.})d",
+		},
+		{
+			name:   "double-click after open quote",
+			given:  "{..}x = \"hello, world\"",
+			events: doubleClick(image.Pt(5, 1)),
+			want:   "x = \"{.}hello, world{.}\"",
+		},
+		{
+			name:   "double-click unmatched bracket",
+			given:  "{..}f(abc",
+			events: doubleClick(image.Pt(2, 1)),
+			want:   "f({.}abc{.}",
+		},
+		{
+			name:   "double-click bracket beyond scan",
+			given:  "{..}f(" + strings.Repeat("a", maxBracketScan) + " b)",
+			events: doubleClick(image.Pt(2, 1)),
+			want:   "f({.}" + strings.Repeat("a", maxBracketScan) + "{.} b)",
+		},
+		{
+			name:   "double-click different runes",
+			given:  "{..}abc def",
+			events: append(leftClick(image.Pt(1, 1)), leftClick(image.Pt(5, 1))...),
+			want:   "abc d{..}ef",
+		},
+		{
+			name:   "triple-click",
+			given:  "{..}abc\ndef\nghi",
+			events: append(doubleClick(image.Pt(1, 2)), leftClick(image.Pt(1, 2))...),
+			want:   "abc\n{.}def\n{.}ghi",
+		},
+		{
+			name:   "quadruple-click",
+			given:  "{..}abc\ndef\nghi",
+			events: append(doubleClick(image.Pt(1, 2)), doubleClick(image.Pt(1, 2))...),
+			want:   "abc\nd{..}ef\nghi",
+		},
+		{
+			name:   "drag forward",
+			given:  "{..}abc\ndef\nghi",
+			events: leftDrag(image.Pt(1, 1), image.Pt(2, 1), image.Pt(2, 2)),
+			want:   "a{.}bc\nde{.}f\nghi",
+		},
+		{
+			name:   "drag backward",
+			given:  "{..}abc\ndef\nghi",
+			events: leftDrag(image.Pt(2, 2), image.Pt(1, 2), image.Pt(1, 1)),
+			want:   "a{.}bc\nde{.}f\nghi",
+		},
+		{
+			name:   "drag back to the start",
+			given:  "{..}abc",
+			events: leftDrag(image.Pt(1, 1), image.Pt(3, 1), image.Pt(1, 1)),
+			want:   "a{..}bc",
+		},
+		{
+			name:  "double-click jitter",
+			given: "{..}abc def",
+			events: append(doubleClick(image.Pt(5, 1))[:3],
+				mouse.Event{X: 5, Y: 1},
+				mouse.Event{X: 5, Y: 1, Button: mouse.ButtonLeft, Direction: mouse.DirRelease}),
+			want: "abc {.}def{.}",
+		},
+		{
+			name:      "drag after chord",
+			given:     "{..}abc",
+			snarf:     "xyz",
+			events:    append(chord(image.Pt(1, 1), mouse.ButtonRight)[:3], mouse.Event{X: 0, Y: 1}),
+			want:      "a{.}xyz{.}bc",
+			wantSnarf: "xyz",
+		},
+		{
+			name:      "1-3 chord",
+			given:     "{..}abc",
+			snarf:     "xyz",
+			events:    chord(image.Pt(1, 1), mouse.ButtonRight),
+			want:      "a{.}xyz{.}bc",
+			wantSnarf: "xyz",
+		},
+		{
+			name:  "1-3-2 chord",
+			given: "{..}abc",
+			snarf: "xyz",
+			events: chord(image.Pt(1, 1),
+				mouse.ButtonRight, mouse.ButtonMiddle),
+			want:      "a{..}bc",
+			wantSnarf: "xyz",
+		},
+		{
+			name:      "1-2 chord empty dot",
+			given:     "{..}abc",
+			snarf:     "xyz",
+			events:    chord(image.Pt(1, 1), mouse.ButtonMiddle),
+			want:      "a{..}bc",
+			wantSnarf: "",
+		},
+		{
+			name:  "2-1 is not a chord",
+			given: "{..}abc\nenv\nxyz",
+			snarf: "xyz",
+			events: []mouse.Event{
+				{X: 1, Y: 2, Button: mouse.ButtonMiddle, Direction: mouse.DirPress},
+				{X: 1, Y: 2, Button: mouse.ButtonLeft, Direction: mouse.DirPress},
+				{X: 1, Y: 2, Button: mouse.ButtonLeft, Direction: mouse.DirRelease},
+				{X: 1, Y: 2, Button: mouse.ButtonMiddle, Direction: mouse.DirRelease},
+			},
+			want:      "abc\ne{..}nv\nxyz",
+			cmds:      []string{"env"},
+			wantSnarf: "xyz",
+		},
 	}
 
 	for _, test := range tests {
@@ -114,6 +271,7 @@ func TestMouseHandler(t *testing.T) {
 		}
 
 		h := newTestHandler(buf)
+		h.snarfed = test.snarf
 		for _, e := range test.events {
 			handleMouse(h, e)
 		}
@@ -134,6 +292,14 @@ func TestMouseHandler(t *testing.T) {
 		if !reflect.DeepEqual(h.cmds, test.cmds) {
 			t.Errorf("%s, executed %v, want %v", test.name, h.cmds, test.cmds)
 		}
+
+		if !reflect.DeepEqual(h.looks, test.looks) {
+			t.Errorf("%s, looked %v, want %v", test.name, h.looks, test.looks)
+		}
+
+		if h.snarfed != test.wantSnarf {
+			t.Errorf("%s, snarf %q, want %q", test.name, h.snarfed, test.wantSnarf)
+		}
 	}
 }
 
@@ -791,6 +957,35 @@ func leftClick(p image.Point) []mouse.Event {
 	}
 }
 
+// Chord returns the events of pressing the left button at p,
+// clicking each of the given buttons in order while it is held,
+// and then releasing it.
+func chord(p image.Point, buttons ...mouse.Button) []mouse.Event {
+	x, y := float32(p.X), float32(p.Y)
+	events := []mouse.Event{{X: x, Y: y, Button: mouse.ButtonLeft, Direction: mouse.DirPress}}
+	for _, b := range buttons {
+		events = append(events,
+			mouse.Event{X: x, Y: y, Button: b, Direction: mouse.DirPress},
+			mouse.Event{X: x, Y: y, Button: b, Direction: mouse.DirRelease})
+	}
+	return append(events, mouse.Event{X: x, Y: y, Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+}
+
+// LeftDrag returns the events of pressing the left button at p,
+// moving it through each of the given points,
+// and releasing it at the last.
+func leftDrag(p image.Point, ps ...image.Point) []mouse.Event {
+	events := []mouse.Event{{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress}}
+	for _, p = range ps {
+		events = append(events, mouse.Event{X: float32(p.X), Y: float32(p.Y)})
+	}
+	return append(events, mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+}
+
+func doubleClick(p image.Point) []mouse.Event {
+	return append(leftClick(p), leftClick(p)...)
+}
+
 func middleClick(p image.Point) []mouse.Event {
 	x, y := float32(p.X), float32(p.Y)
 	return []mouse.Event{
@@ -799,26 +994,63 @@ func middleClick(p image.Point) []mouse.Event {
 	}
 }
 
+func rightClick(p image.Point) []mouse.Event {
+	x, y := float32(p.X), float32(p.Y)
+	return []mouse.Event{
+		{X: x, Y: y, Button: mouse.ButtonRight, Direction: mouse.DirPress},
+		{X: x, Y: y, Button: mouse.ButtonRight, Direction: mouse.DirRelease},
+	}
+}
+
 type testHandler struct {
-	buf  *edit.Buffer
-	col  int
-	seq  int
-	cmds []string
+	buf     *edit.Buffer
+	col     int
+	seq     int
+	cmds    []string
+	looks   []string
+	logs    []string
+	held    mouse.Button
+	km      Keymap
+	last    multiClick
+	snarfed string
 }
 
 func newTestHandler(buf *edit.Buffer) *testHandler {
 	return &testHandler{
 		buf: buf,
 		col: -1,
+		km:  DefaultKeymap(),
 	}
 }
 
 func (h *testHandler) exec(cmd string) { h.cmds = append(h.cmds, cmd) }
 
+func (h *testHandler) look(text string) { h.looks = append(h.looks, text) }
+
 func (h *testHandler) column() int { return h.col }
 
 func (h *testHandler) setColumn(c int) { h.col = c }
 
+func (h *testHandler) keymap() Keymap { return h.km }
+
+func (h *testHandler) button() mouse.Button { return h.held }
+
+func (h *testHandler) setButton(b mouse.Button) { h.held = b }
+
+func (h *testHandler) snarf() string { return h.snarfed }
+
+func (h *testHandler) lastClick() multiClick { return h.last }
+
+func (h *testHandler) setLastClick(c multiClick) { h.last = c }
+
+func (h *testHandler) now() time.Time { return time.Now() }
+
+func (h *testHandler) logf(format string, vs ...interface{}) {
+	h.logs = append(h.logs, fmt.Sprintf(format, vs...))
+}
+
+func (h *testHandler) setSnarf(s string) { h.snarfed = s }
+
 func (h *testHandler) where(p image.Point) int64 {
 	line := edit.Clamp(edit.Line(p.Y)).Minus(edit.Rune(0))
 	addr := line.Plus(edit.Clamp(edit.Rune(int64(p.X))))
@@ -842,6 +1074,11 @@ func (h *testHandler) doAsync(eds ...edit.Edit) {
 	h.do(eds...)
 }
 
+func (h *testHandler) doThen(f func([]editor.EditResult, error), eds ...edit.Edit) {
+	h.col = -1
+	f(h.do(eds...))
+}
+
 func (h *testHandler) do(eds ...edit.Edit) ([]editor.EditResult, error) {
 	print := bytes.NewBuffer(nil)
 	var results []editor.EditResult
@@ -0,0 +1,90 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getExecEnvHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	envChan := make(chan ExecEnv)
+	win.Send(func() { envChan <- copyExecEnv(win.execEnv) })
+	s.RUnlock()
+	respond(w, <-envChan)
+}
+
+func (s *Server) setExecEnvHandler(w http.ResponseWriter, req *http.Request) {
+	var env ExecEnv
+	if err := json.NewDecoder(req.Body).Decode(&env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	win.Send(func() {
+		win.execEnv = env
+		close(done)
+	})
+	s.RUnlock()
+	<-done
+}
+
+func copyExecEnv(env ExecEnv) ExecEnv {
+	env.Env = append([]string{}, env.Env...)
+	return env
+}
+
+// CommandEnv returns the working directory and the environment
+// of a command executed from the sheet, or from a column tag if s is nil.
+//
+// Like acme, the working directory is the directory of the sheet's file,
+// or the file itself if it is a directory,
+// and the environment has the file name in $% and $samfile,
+// and the sheet ID in $winid.
+// The environment also has the paths of the window and sheet resources
+// in $T_WINDOW_PATH and $T_SHEET_PATH.
+// Sheets without a file name and column tags
+// use the directory of the window's ExecEnv.
+//
+// CommandEnv must be called in the window's UI goroutine.
+func (w *window) commandEnv(s *sheet) (dir string, env []string) {
+	dir = w.execEnv.Dir
+	env = append(os.Environ(), "T_WINDOW_PATH="+windowPath(w))
+	env = append(env, w.execEnv.Env...)
+	if s == nil {
+		return dir, env
+	}
+	env = append(env, "T_SHEET_PATH="+path.Join("/", "sheet", s.id), "winid="+s.id)
+	name := s.filePath()
+	if name == "" {
+		return dir, env
+	}
+	if !filepath.IsAbs(name) && dir != "" {
+		name = filepath.Join(dir, name)
+	}
+	env = append(env, "%="+name, "samfile="+name)
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		return name, env
+	}
+	return filepath.Dir(name), env
+}
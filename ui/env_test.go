@@ -0,0 +1,83 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestExecEnv(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	dir, err := ioutil.TempDir("", "T_env_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0777); err != nil {
+		t.Fatalf("os.Mkdir(%q)=%v", sub, err)
+	}
+
+	envURL := urlWithPath(s.url, "/", "window", w.id, "env")
+	want := ExecEnv{Dir: dir, Env: []string{"FOO=bar"}}
+	if err := SetExecEnv(envURL, want); err != nil {
+		t.Fatalf("SetExecEnv(%s, %+v)=%v", envURL, want, err)
+	}
+	if got, err := GetExecEnv(envURL); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("GetExecEnv(%s)=%+v,%v, want %+v,nil", envURL, got, err, want)
+	}
+	notFoundURL := urlWithPath(s.url, "/", "window", "notfound", "env")
+	if _, err := GetExecEnv(notFoundURL); err != ErrNotFound {
+		t.Errorf("GetExecEnv(%s)=_,%v, want %v", notFoundURL, err, ErrNotFound)
+	}
+
+	// Without a file name, the window's directory is used.
+	var gotDir string
+	var env []string
+	w.Send(func() { gotDir, env = w.commandEnv(sheet0) })
+	wait(w)
+	if gotDir != dir || !containsString(env, "FOO=bar") || !containsString(env, "winid="+sheet0.id) {
+		t.Errorf("commandEnv(sheet0)=%q,%q, want %q with FOO=bar and winid=%s", gotDir, env, dir, sheet0.id)
+	}
+
+	// A relative file name is relative to the window's directory.
+	w.Send(func() { sheet0.setTagFileName("sub/file") })
+	var name string
+	for i := 0; i < 100 && name != "sub/file"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { name = sheet0.tagFileName() })
+		wait(w)
+	}
+	file := filepath.Join(sub, "file")
+	w.Send(func() { gotDir, env = w.commandEnv(sheet0) })
+	wait(w)
+	if gotDir != sub || !containsString(env, "%="+file) || !containsString(env, "samfile="+file) {
+		t.Errorf("commandEnv(sheet0)=%q,%q, want %q with %%=%s", gotDir, env, sub, file)
+	}
+
+	// Commands run in the directory.
+	outSheet := output(w, "")
+	w.Send(func() { sheet0.body.exec("pwd") })
+	var text string
+	for i := 0; i < 500 && text == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+		res, err := outSheet.body.view.Do(edit.Print(edit.All))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("outSheet.body.view.Do(Print(All))=%v,%v", res, err)
+		}
+		text = res[0].Print
+	}
+	if want, _ := filepath.EvalSymlinks(sub); text != sub+"\n" && text != want+"\n" {
+		t.Errorf("pwd output=%q, want %q", text, sub+"\n")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+)
+
+// MaxFindMatches is the maximum number of matches highlighted by a find.
+const maxFindMatches = 1000
+
+// A finder is the state of an incremental search of a sheet's body.
+type finder struct {
+	// Query is the literal text searched for.
+	query string
+
+	// Matches are the spans of the body text matching the query.
+	matches []edit.Span
+
+	// Cur is the index of the current match,
+	// or -1 if there are no matches.
+	cur int
+
+	// From is the rune offset of the body's dot when the find began.
+	// The current match is the first match at or after from.
+	from int64
+
+	// L0 is the rune offset of the first visible rune of the body
+	// when the find began.
+	// If the find is canceled, the view returns to l0.
+	l0 int64
+}
+
+// StartFind begins an incremental search of the sheet's body.
+// If a search is already in progress,
+// the current match advances to the next match.
+func (s *sheet) startFind() {
+	if s.find != nil {
+		if len(s.find.matches) > 0 {
+			s.find.cur = (s.find.cur + 1) % len(s.find.matches)
+			s.showMatch()
+		}
+		return
+	}
+	s.find = &finder{cur: -1, from: s.body.dot0, l0: s.body.l0}
+}
+
+// FindKey handles a key event while a search is in progress.
+//
+// Return jumps to the current match, setting the body's dot to it,
+// and Escape cancels the search.
+// Both end the search.
+// Backspace deletes the last rune of the query,
+// and other runes are appended to the query.
+func (s *sheet) findKey(event key.Event) {
+	if event.Direction == key.DirRelease {
+		return
+	}
+	if cmd := sheetCommands[boundName(s.keymap(), event)]; cmd != nil {
+		cmd(s)
+		return
+	}
+	switch event.Code {
+	case key.CodeReturnEnter:
+		if s.find.cur >= 0 {
+			m := s.find.matches[s.find.cur]
+			s.body.doAsync(edit.Set(edit.Rune(m[0]).To(edit.Rune(m[1])), '.'))
+		}
+		s.endFind()
+	case key.CodeEscape:
+		s.body.view.Warp(edit.Clamp(edit.Rune(s.find.l0)))
+		s.endFind()
+	case key.CodeDeleteBackspace:
+		if q := s.find.query; q != "" {
+			_, w := utf8.DecodeLastRuneInString(q)
+			s.setFindQuery(q[:len(q)-w])
+		}
+	default:
+		if event.Modifiers&^key.ModShift == 0 && event.Rune >= 0 {
+			s.setFindQuery(s.find.query + string(event.Rune))
+		}
+	}
+}
+
+func (s *sheet) endFind() {
+	s.find = nil
+	s.body.setHighlights(nil)
+}
+
+// SetFindQuery sets the query of the search in progress,
+// and searches the body for it asynchronously.
+// When the search completes, if the query is still current,
+// its matches are highlighted in the body
+// and the current match is shown.
+func (s *sheet) setFindQuery(q string) {
+	f := s.find
+	f.query = q
+	f.matches = nil
+	f.cur = -1
+	if q == "" {
+		s.setFindMatches(nil)
+		return
+	}
+	URL := *s.body.bufferURL
+	URL.Path = path.Join(URL.Path, "search")
+	w := s.win
+	go func() {
+		matches, err := editor.Search(&URL, regexp.QuoteMeta(q), 0, maxFindMatches)
+		w.Send(func() {
+			if err != nil {
+				w.logf("failed to search: %v", err)
+			}
+			if s.find != f || f.query != q {
+				// The find ended or its query changed.
+				return
+			}
+			s.setFindMatches(matches)
+		})
+	}()
+}
+
+// SetFindMatches sets the matches of the search in progress,
+// highlights them in the body,
+// and shows the current match.
+func (s *sheet) setFindMatches(matches []edit.Span) {
+	f := s.find
+	f.matches = matches
+	f.cur = -1
+	for i, m := range f.matches {
+		if m[0] >= f.from {
+			f.cur = i
+			break
+		}
+	}
+	if f.cur < 0 && len(f.matches) > 0 {
+		// Wrap around to the first match.
+		f.cur = 0
+	}
+	s.body.setHighlights(f.matches)
+	s.showMatch()
+}
+
+// ShowMatch scrolls the body to the current match
+// if it is not already visible.
+func (s *sheet) showMatch() {
+	f := s.find
+	if f.cur < 0 {
+		return
+	}
+	m := f.matches[f.cur]
+	if m[0] >= s.body.l0 && m[1] <= s.body.l0+int64(s.body.nRunes) {
+		return
+	}
+	s.body.view.Warp(edit.Rune(m[0]))
+}
+
+// FindBar returns the bounds of the find bar,
+// which overlays the bottom of the body.
+func (s *sheet) findBar() image.Rectangle {
+	r := image.Rectangle{Min: s.body.topLeft, Max: s.body.topLeft.Add(s.body.opts.Size)}
+	if h := minHeight(s.tag.opts); r.Dy() > h {
+		r.Min.Y = r.Max.Y - h
+	}
+	return r
+}
+
+// DrawFindBar draws the query of the search in progress.
+func (s *sheet) drawFindBar(scr screen.Screen, win screen.Window) {
+	r := s.findBar()
+	win.Fill(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+borderWidth), s.win.theme.Separator, draw.Over)
+	r.Min.Y += borderWidth
+
+	opts := s.tag.opts
+	opts.Size = r.Size()
+	setter := text.NewSetter(opts)
+	defer setter.Release()
+	n := len(s.find.matches)
+	setter.Add([]byte(fmt.Sprintf("Find: %s (%d/%d)", s.find.query, s.find.cur+1, n)))
+	t := setter.Set()
+	t.Draw(r.Min, scr, win)
+	t.Release()
+}
@@ -0,0 +1,120 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestFind(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	_, err := sheet0.body.doSync(edit.Change(edit.All, "abc abc abc"), edit.Set(edit.Rune(2), '.'))
+	if err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	mouseTo(w, center(sheet0))
+	// Wait for the body to draw the new dot.
+	var dot0 int64
+	for i := 0; i < 100 && dot0 != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { dot0 = sheet0.body.dot0 })
+		wait(w)
+	}
+
+	ctrlS := key.Event{Rune: 's', Code: key.CodeS, Modifiers: key.ModControl, Direction: key.DirPress}
+	w.Send(ctrlS)
+	for _, r := range "ab" {
+		pressKey(w, r, key.CodeUnknown)
+	}
+
+	var query string
+	var matches []edit.Span
+	var cur int
+	var highlights []edit.Span
+	state := func() {
+		w.Send(func() {
+			query, matches, cur, highlights = "", nil, -1, sheet0.body.highlights
+			if sheet0.find != nil {
+				query, matches, cur = sheet0.find.query, sheet0.find.matches, sheet0.find.cur
+			}
+		})
+		wait(w)
+	}
+	// The search is asynchronous, so wait for its highlights.
+	waitHighlights := func(want []edit.Span) {
+		done := func() bool {
+			return len(highlights) == len(want) && (len(want) == 0 || reflect.DeepEqual(highlights, want))
+		}
+		state()
+		for i := 0; i < 100 && !done(); i++ {
+			time.Sleep(10 * time.Millisecond)
+			state()
+		}
+	}
+
+	wantMatches := []edit.Span{{0, 2}, {4, 6}, {8, 10}}
+	waitHighlights(wantMatches)
+	if query != "ab" || !reflect.DeepEqual(matches, wantMatches) || cur != 1 {
+		t.Errorf("query=%q, matches=%v, cur=%d, want %q, %v, 1", query, matches, cur, "ab", wantMatches)
+	}
+	if !reflect.DeepEqual(highlights, wantMatches) {
+		t.Errorf("highlights=%v, want %v", highlights, wantMatches)
+	}
+
+	// C-s advances to the next match.
+	w.Send(ctrlS)
+	state()
+	if cur != 2 {
+		t.Errorf("after C-s cur=%d, want 2", cur)
+	}
+
+	// Backspace shortens the query.
+	pressKey(w, -1, key.CodeDeleteBackspace)
+	wantMatches = []edit.Span{{0, 1}, {4, 5}, {8, 9}}
+	waitHighlights(wantMatches)
+	if query != "a" || !reflect.DeepEqual(matches, wantMatches) {
+		t.Errorf("query=%q, matches=%v, want %q, %v", query, matches, "a", wantMatches)
+	}
+
+	pressKey(w, 'c', key.CodeUnknown)
+	waitHighlights(nil)
+	if query != "ac" || len(matches) != 0 || cur != -1 {
+		t.Errorf("query=%q, matches=%v, cur=%d, want %q, [], -1", query, matches, cur, "ac")
+	}
+
+	// Escape cancels the search.
+	pressKey(w, -1, key.CodeEscape)
+	state()
+	if query != "" || highlights != nil {
+		t.Errorf("after Escape query=%q, highlights=%v, want \"\", nil", query, highlights)
+	}
+	res, err := sheet0.body.doSync(edit.Where(edit.Dot))
+	if err != nil || res[0].Error != "" || strings.TrimSpace(res[0].Print) != "#2" {
+		t.Errorf("after Escape dot=%v,%v, want #2", res, err)
+	}
+
+	// Return sets dot to the current match.
+	w.Send(ctrlS)
+	for _, r := range "bc" {
+		pressKey(w, r, key.CodeUnknown)
+	}
+	waitHighlights([]edit.Span{{1, 3}, {5, 7}, {9, 11}})
+	pressKey(w, -1, key.CodeReturnEnter)
+	state()
+	if query != "" || highlights != nil {
+		t.Errorf("after Return query=%q, highlights=%v, want \"\", nil", query, highlights)
+	}
+	res, err = sheet0.body.doSync(edit.Where(edit.Dot))
+	if err != nil || res[0].Error != "" || strings.TrimSpace(res[0].Print) != "#5,#7" {
+		t.Errorf("after Return dot=%v,%v, want #5,#7", res, err)
+	}
+}
@@ -3,31 +3,306 @@
 package ui
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/golang/freetype/truetype"
+	"github.com/gorilla/mux"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
 	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/mobile/event/paint"
 )
 
-var defaultFont = loadDefaultFont()
+const (
+	defaultFontName = "regular"
+	defaultFontSize = 11 // pt
+	minFontSize     = 4  // pt
+)
+
+// FontTTFs are the built-in font faces, keyed by name.
+var fontTTFs = map[string][]byte{
+	"regular": goregular.TTF,
+	"mono":    gomono.TTF,
+	"bold":    gobold.TTF,
+	"italic":  goitalic.TTF,
+}
+
+var (
+	fontsMu sync.Mutex
+	// Fonts are the parsed built-in font faces, keyed by name.
+	// A nil font failed to parse.
+	fonts = make(map[string]*truetype.Font)
+)
+
+// FontNames returns the sorted names of the built-in font faces.
+func fontNames() []string {
+	var names []string
+	for name := range fontTTFs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckFont returns an error if the Font's name is not a built-in font face
+// or its size is negative.
+func checkFont(f Font) error {
+	if _, ok := fontTTFs[f.Name]; f.Name != "" && !ok {
+		return errors.New("unknown font " + f.Name +
+			"; want one of " + strings.Join(fontNames(), ", "))
+	}
+	if f.Size < 0 {
+		return fmt.Errorf("bad font size %g", f.Size)
+	}
+	return nil
+}
+
+// Inherit returns the Font with its unset fields set from another Font.
+func (f Font) inherit(from Font) Font {
+	if f.Name == "" {
+		f.Name = from.Name
+	}
+	if f.Size == 0 {
+		f.Size = from.Size
+	}
+	return f
+}
 
-func newFace(dpi float64) font.Face {
-	if defaultFont == nil {
+// DefaultFont returns the Font used by windows that do not set one.
+func defaultFont() Font { return Font{Name: defaultFontName, Size: defaultFontSize} }
+
+// NewFace returns a new face for the Font at the given DPI.
+// Unset fields of the Font are those of the default Font.
+func newFace(f Font, dpi float64) font.Face {
+	f = f.inherit(defaultFont())
+	ttf := loadFont(f.Name)
+	if ttf == nil {
 		return basicfont.Face7x13
 	}
-	return truetype.NewFace(defaultFont, &truetype.Options{
-		Size: 11, // pt
+	return truetype.NewFace(ttf, &truetype.Options{
+		Size: f.Size,
 		DPI:  dpi,
 	})
 }
 
-func loadDefaultFont() *truetype.Font {
-	ttf, err := truetype.Parse(goregular.TTF)
+func loadFont(name string) *truetype.Font {
+	fontsMu.Lock()
+	defer fontsMu.Unlock()
+	if ttf, ok := fonts[name]; ok {
+		return ttf
+	}
+	ttf, err := truetype.Parse(fontTTFs[name])
 	if err != nil {
-		log.Printf("Failed to load default font %s", err)
-		return nil
+		log.Printf("Failed to load font %s: %s", name, err)
+		ttf = nil
 	}
+	fonts[name] = ttf
 	return ttf
 }
+
+// ParseFontArgs returns the Font set by the arguments of a Font command.
+// Each argument is either the name of a font face,
+// a size in points, or a size adjustment beginning with + or -.
+// Adjustments are relative to cur, the Font in effect.
+// With no arguments, the result is the zero Font.
+func parseFontArgs(set, cur Font, args string) (Font, error) {
+	fs := strings.Fields(args)
+	if len(fs) == 0 {
+		return Font{}, nil
+	}
+	for _, a := range fs {
+		n, err := strconv.ParseFloat(a, 64)
+		switch {
+		case err != nil:
+			set.Name = a
+		case strings.HasPrefix(a, "+") || strings.HasPrefix(a, "-"):
+			set.Size = cur.Size + n
+			if set.Size < minFontSize {
+				set.Size = minFontSize
+			}
+		default:
+			set.Size = n
+		}
+		if err := checkFont(set); err != nil {
+			return Font{}, err
+		}
+	}
+	return set, nil
+}
+
+// SetFont sets the window's default Font,
+// used by sheets that do not set their own,
+// and lays out the window again.
+// It must be called in the window's UI goroutine.
+func (w *window) setFont(f Font) {
+	old := w.face
+	w.font = f
+	w.face = newFace(f, w.dpi)
+	w.updateFrames()
+	old.Close()
+	w.setBoundsAfterResize(w.bounds())
+	w.Send(paint.Event{})
+}
+
+// FontInEffect returns the window's Font with unset fields set.
+func (w *window) fontInEffect() Font { return w.font.inherit(defaultFont()) }
+
+// SetFont sets the Font of the sheet,
+// overriding the set fields of its window's Font,
+// and lays out the sheet's column again.
+// It must be called in the UI goroutine of the sheet's window.
+func (s *sheet) setFont(f Font) {
+	s.font = f
+	s.updateFace(s.win)
+	if s.col != nil {
+		s.col.setAfterResizeBounds(s.col.bounds())
+	} else {
+		s.setBounds(s.bounds())
+	}
+	s.win.Send(paint.Event{})
+}
+
+// FontInEffect returns the sheet's Font with unset fields set.
+func (s *sheet) fontInEffect() Font { return s.font.inherit(s.win.fontInEffect()) }
+
+// UpdateFace updates the face of the sheet's tag and body
+// after a change to the sheet's Font or to the window's DPI or Font.
+func (s *sheet) updateFace(w *window) {
+	old := s.face
+	s.face = nil
+	face := w.face
+	if s.font != (Font{}) {
+		s.face = newFace(s.font.inherit(w.fontInEffect()), w.dpi)
+		face = s.face
+	}
+	s.tag.setFace(face)
+	s.body.setFace(face)
+	if old != nil {
+		old.Close()
+	}
+}
+
+// ZoomFont adjusts the size of the sheet's font by delta points.
+func (s *sheet) zoomFont(delta float64) {
+	f := s.font
+	f.Size = s.fontInEffect().Size + delta
+	if f.Size < minFontSize {
+		f.Size = minFontSize
+	}
+	s.setFont(f)
+}
+
+func sheetFontCmd(s *sheet, args string) {
+	f, err := parseFontArgs(s.font, s.fontInEffect(), args)
+	if err != nil {
+		s.errorf("Font: %v", err)
+		return
+	}
+	s.setFont(f)
+}
+
+func windowFontCmd(w *window, args string) {
+	f, err := parseFontArgs(w.font, w.fontInEffect(), args)
+	if err != nil {
+		w.output(fmt.Sprintf("Font: %v\n", err))
+		return
+	}
+	w.setFont(f)
+}
+
+func (s *Server) getWindowFontHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	result := make(chan Font)
+	win.Send(func() { result <- win.font })
+	s.RUnlock()
+	respond(w, <-result)
+}
+
+func (s *Server) setWindowFontHandler(w http.ResponseWriter, req *http.Request) {
+	f, ok := decodeFont(w, req)
+	if !ok {
+		return
+	}
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	win.Send(func() {
+		win.setFont(f)
+		close(done)
+	})
+	s.RUnlock()
+	<-done
+}
+
+func (s *Server) getSheetFontHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	result := make(chan Font)
+	f.win.Send(func() { result <- f.font })
+	s.RUnlock()
+	respond(w, <-result)
+}
+
+func (s *Server) setSheetFontHandler(w http.ResponseWriter, req *http.Request) {
+	ft, ok := decodeFont(w, req)
+	if !ok {
+		return
+	}
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	f.win.Send(func() {
+		f.setFont(ft)
+		close(done)
+	})
+	s.RUnlock()
+	<-done
+}
+
+// DecodeFont decodes a Font from the request body.
+// If the Font is malformed, it responds with Bad Request
+// and returns false.
+func decodeFont(w http.ResponseWriter, req *http.Request) (Font, bool) {
+	var f Font
+	if err := json.NewDecoder(req.Body).Decode(&f); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return Font{}, false
+	}
+	if err := checkFont(f); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return Font{}, false
+	}
+	return f, true
+}
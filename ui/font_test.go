@@ -0,0 +1,169 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"testing"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestParseFontArgs(t *testing.T) {
+	cur := Font{Name: "regular", Size: 11}
+	tests := []struct {
+		set  Font
+		args string
+		want Font
+		err  bool
+	}{
+		{set: Font{Name: "mono", Size: 12}, args: "", want: Font{}},
+		{set: Font{}, args: "mono", want: Font{Name: "mono"}},
+		{set: Font{Name: "bold"}, args: "14", want: Font{Name: "bold", Size: 14}},
+		{set: Font{}, args: "mono 14", want: Font{Name: "mono", Size: 14}},
+		{set: Font{}, args: "+2", want: Font{Size: 13}},
+		{set: Font{Size: 20}, args: "-3", want: Font{Size: 8}},
+		{set: Font{}, args: "-100", want: Font{Size: minFontSize}},
+		{set: Font{}, args: "italic +1", want: Font{Name: "italic", Size: 12}},
+		{set: Font{}, args: "nope", err: true},
+	}
+	for _, test := range tests {
+		got, err := parseFontArgs(test.set, cur, test.args)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseFontArgs(%+v, %+v, %q)=%+v,nil, want error",
+					test.set, cur, test.args, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseFontArgs(%+v, %+v, %q)=%+v,%v, want %+v,nil",
+				test.set, cur, test.args, got, err, test.want)
+		}
+	}
+}
+
+func TestFontCmd(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+
+	var font0, font1 Font
+	var ownFace, usesFace, sharesFace bool
+	w.Send(func() {
+		sheet0.tag.exec("Font mono 14")
+		font0, font1 = sheet0.font, sheet1.font
+		ownFace = sheet0.face != nil
+		usesFace = sheet0.tag.opts.DefaultStyle.Face == sheet0.face &&
+			sheet0.body.opts.DefaultStyle.Face == sheet0.face
+		sharesFace = sheet1.body.opts.DefaultStyle.Face == w.face
+	})
+	wait(w)
+	if want := (Font{Name: "mono", Size: 14}); font0 != want {
+		t.Errorf("sheet0 font=%+v, want %+v", font0, want)
+	}
+	if font1 != (Font{}) {
+		t.Errorf("sheet1 font=%+v, want %+v", font1, Font{})
+	}
+	if !ownFace || !usesFace || !sharesFace {
+		t.Errorf("ownFace=%v, usesFace=%v, sharesFace=%v, want all true",
+			ownFace, usesFace, sharesFace)
+	}
+
+	// The window font changes sheets that do not set their own.
+	var effective0, effective1 Font
+	w.Send(func() {
+		w.tag.text.exec("Font bold 9")
+		effective0, effective1 = sheet0.fontInEffect(), sheet1.fontInEffect()
+		sharesFace = sheet1.body.opts.DefaultStyle.Face == w.face
+	})
+	wait(w)
+	if want := (Font{Name: "mono", Size: 14}); effective0 != want {
+		t.Errorf("sheet0 font in effect=%+v, want %+v", effective0, want)
+	}
+	if want := (Font{Name: "bold", Size: 9}); effective1 != want {
+		t.Errorf("sheet1 font in effect=%+v, want %+v", effective1, want)
+	}
+	if !sharesFace {
+		t.Errorf("sheet1 does not use the window face")
+	}
+
+	// With no arguments, the sheet uses the window font again.
+	var face0 bool
+	w.Send(func() {
+		sheet0.tag.exec("Font")
+		font0 = sheet0.font
+		face0 = sheet0.face == nil && sheet0.body.opts.DefaultStyle.Face == w.face
+	})
+	wait(w)
+	if font0 != (Font{}) || !face0 {
+		t.Errorf("after Font, sheet0 font=%+v, uses window face=%v, want %+v, true",
+			font0, face0, Font{})
+	}
+}
+
+func TestFontZoom(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	p := image.Pt(sheet0.Min.X+sheet0.Dx()/2, sheet0.Max.Y-5)
+	mouseTo(w, p)
+	wait(w)
+
+	step := func(b mouse.Button, mods key.Modifiers) {
+		w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: b, Modifiers: mods, Direction: mouse.DirStep})
+	}
+	step(mouse.ButtonWheelUp, key.ModControl)
+	step(mouse.ButtonWheelUp, key.ModControl)
+	step(mouse.ButtonWheelDown, key.ModControl)
+	// Scrolling without control does not zoom.
+	step(mouse.ButtonWheelUp, 0)
+
+	var font Font
+	w.Send(func() { font = sheet0.font })
+	wait(w)
+	if want := (Font{Size: defaultFontSize + 1}); font != want {
+		t.Errorf("after zoom, font=%+v, want %+v", font, want)
+	}
+}
+
+func TestFont_HTTP(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	sheetURL := urlWithPath(s.url, "/", "sheet", sheet0.id, "font")
+	if f, err := GetFont(sheetURL); err != nil || f != (Font{}) {
+		t.Fatalf("GetFont(%s)=%+v,%v, want %+v,nil", sheetURL, f, err, Font{})
+	}
+	want := Font{Name: "italic", Size: 13}
+	if err := SetFont(sheetURL, want); err != nil {
+		t.Fatalf("SetFont(%s, %+v)=%v", sheetURL, want, err)
+	}
+	if f, err := GetFont(sheetURL); err != nil || f != want {
+		t.Fatalf("GetFont(%s)=%+v,%v, want %+v,nil", sheetURL, f, err, want)
+	}
+	if err := SetFont(sheetURL, Font{Name: "nope"}); err == nil {
+		t.Errorf("SetFont(%s, nope)=nil, want error", sheetURL)
+	}
+
+	winURL := urlWithPath(s.url, windowPath(w), "font")
+	want = Font{Size: 15}
+	if err := SetFont(winURL, want); err != nil {
+		t.Fatalf("SetFont(%s, %+v)=%v", winURL, want, err)
+	}
+	if f, err := GetFont(winURL); err != nil || f != want {
+		t.Fatalf("GetFont(%s)=%+v,%v, want %+v,nil", winURL, f, err, want)
+	}
+
+	notFound := urlWithPath(s.url, "/", "sheet", "nope", "font")
+	if err := SetFont(notFound, Font{}); err != ErrNotFound {
+		t.Errorf("SetFont(%s)=%v, want %v", notFound, err, ErrNotFound)
+	}
+	notFound = urlWithPath(s.url, "/", "window", "nope", "font")
+	if _, err := GetFont(notFound); err != ErrNotFound {
+		t.Errorf("GetFont(%s)=%v, want %v", notFound, err, ErrNotFound)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getGeometryHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	geoChan := make(chan Geometry)
+	win.Send(func() { geoChan <- win.geometry() })
+	s.RUnlock()
+	respond(w, <-geoChan)
+}
+
+func (s *Server) setGeometryHandler(w http.ResponseWriter, req *http.Request) {
+	var greq SetGeometryRequest
+	if err := json.NewDecoder(req.Body).Decode(&greq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	type result struct {
+		geo Geometry
+		err error
+	}
+	resultChan := make(chan result)
+	win.Send(func() {
+		err := win.setGeometry(greq)
+		resultChan <- result{geo: win.geometry(), err: err}
+	})
+	s.RUnlock()
+	res := <-resultChan
+	if res.err != nil {
+		http.Error(w, res.err.Error(), http.StatusBadRequest)
+		return
+	}
+	respond(w, res.geo)
+}
+
+// Geometry returns the geometry of the window.
+// It must be called in the window's UI goroutine.
+func (w *window) geometry() Geometry {
+	g := Geometry{Width: w.Dx(), Height: w.Dy()}
+	for i, c := range w.columns {
+		cg := ColumnGeometry{X: w.xs[i]}
+		for j, f := range c.frames {
+			if s, ok := f.(*sheet); ok {
+				cg.Sheets = append(cg.Sheets, SheetGeometry{ID: s.id, Y: c.ys[j]})
+			}
+		}
+		g.Columns = append(g.Columns, cg)
+	}
+	return g
+}
+
+// SetGeometry changes the geometry of the window.
+// If the request is invalid, the geometry is unchanged,
+// and an error is returned.
+// It must be called in the window's UI goroutine.
+func (w *window) setGeometry(req SetGeometryRequest) error {
+	var max, min *sheet
+	if req.Maximize != "" {
+		if max = findSheet(w, req.Maximize); max == nil {
+			return errors.New("sheet not found: " + req.Maximize)
+		}
+	}
+	if req.Minimize != "" {
+		if min = findSheet(w, req.Minimize); min == nil {
+			return errors.New("sheet not found: " + req.Minimize)
+		}
+	}
+	if req.Columns != nil {
+		if err := w.setColumns(req.Columns); err != nil {
+			return err
+		}
+		w.setBounds(w.bounds())
+	}
+	if max != nil {
+		maximize(max)
+		w.setBounds(w.bounds())
+	}
+	if min != nil {
+		minimize(min)
+		w.setBounds(w.bounds())
+	}
+	return nil
+}
+
+// FindSheet returns the sheet with the given ID
+// in one of the window's columns, or nil if there is none.
+func findSheet(w *window, id string) *sheet {
+	for _, c := range w.columns {
+		for _, f := range c.frames {
+			if s, ok := f.(*sheet); ok && s.id == id {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func (w *window) setColumns(cols []ColumnGeometry) error {
+	if len(cols) != len(w.columns) {
+		return fmt.Errorf("got %d columns, want %d", len(cols), len(w.columns))
+	}
+	sheets := make(map[string]*sheet)
+	for _, c := range w.columns {
+		for _, f := range c.frames {
+			if s, ok := f.(*sheet); ok {
+				sheets[s.id] = s
+			}
+		}
+	}
+
+	frames := make([][]frame, len(cols))
+	for i, cg := range cols {
+		x := int(cg.X * float64(w.Dx()))
+		switch {
+		case i == 0 && cg.X != 0:
+			return errors.New("the first column must be at 0")
+		case i > 0 && x-int(cols[i-1].X*float64(w.Dx())) < w.px(minFrameWidth)+borderWidth:
+			return fmt.Errorf("column %d is too narrow", i-1)
+		case i == len(cols)-1 && w.Dx()-x < w.px(minFrameWidth):
+			return fmt.Errorf("column %d is too narrow", i)
+		}
+
+		c := w.columns[i]
+		frames[i] = []frame{c.frames[0]}
+		prevY := 0
+		var prev frame = c.frames[0]
+		for _, sg := range cg.Sheets {
+			s, ok := sheets[sg.ID]
+			if !ok {
+				return errors.New("sheet not found or listed twice: " + sg.ID)
+			}
+			delete(sheets, sg.ID)
+			y := int(sg.Y * float64(c.Dy()))
+			if sg.Y < 0 || prev != c.frames[0] && y-prevY-borderWidth < prev.minHeight() {
+				return fmt.Errorf("sheet %s is too high", sg.ID)
+			}
+			frames[i] = append(frames[i], s)
+			prevY, prev = y, s
+		}
+		if c.Dy()-prevY < prev.minHeight() {
+			return fmt.Errorf("column %d is too short", i)
+		}
+	}
+	for id := range sheets {
+		return errors.New("sheet not listed: " + id)
+	}
+
+	for i, c := range w.columns {
+		w.xs[i] = cols[i].X
+		c.frames = frames[i]
+		c.ys = c.ys[:1]
+		for j, f := range c.frames[1:] {
+			c.ys = append(c.ys, cols[i].Sheets[j].Y)
+			f.setColumn(c)
+		}
+	}
+	return nil
+}
+
+// Maximize grows a sheet to fill its column,
+// shrinking the column's other sheets to their minimum height.
+func maximize(s *sheet) {
+	c := s.col
+	i := frameIndex(c, s)
+	h := float64(c.Dy())
+	y := int(c.ys[1] * h)
+	for j := 1; j < i; j++ {
+		c.ys[j] = float64(y) / h
+		y += c.frames[j].minHeight() + borderWidth
+	}
+	c.ys[i] = float64(y) / h
+	y = c.Dy()
+	for j := len(c.frames) - 1; j > i; j-- {
+		y -= c.frames[j].minHeight()
+		c.ys[j] = float64(y) / h
+		y -= borderWidth
+	}
+}
+
+// Minimize shrinks a sheet to its minimum height.
+// The space is given to the sheet below it,
+// or to the sheet above it if it is the bottom sheet.
+func minimize(s *sheet) {
+	c := s.col
+	min := s.minHeight()
+	if s.Dy() <= min {
+		return
+	}
+	i := frameIndex(c, s)
+	h := float64(c.Dy())
+	if i < len(c.frames)-1 {
+		c.ys[i+1] = float64(s.Min.Y-c.Min.Y+min+borderWidth) / h
+	} else {
+		c.ys[i] = float64(c.Dy()-min) / h
+	}
+}
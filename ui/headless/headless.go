@@ -0,0 +1,450 @@
+// Copyright © 2016, The T Authors.
+
+// Package headless provides a screen.Screen that draws off-screen,
+// for testing the ui package without a display.
+//
+// Events are injected into a Window with Send
+// or the helper methods Type, MoveMouse, Press, ReleaseButton, Click, Resize, and Close.
+// Sync waits for the injected events to be handled.
+// Each call to Publish records a Frame,
+// holding an image of the window and a description
+// of the drawing operations since the previous Frame.
+//
+// A Clock is a fake ui.Clock that only advances when told to,
+// so that a ui.Server's drawing, blinking, and timeouts are deterministic.
+package headless
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"time"
+
+	"github.com/eaburns/T/ui/cursor"
+	"golang.org/x/exp/shiny/screen"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/lifecycle"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/geom"
+)
+
+// DPI is the dots per inch of the Windows.
+const DPI = 96
+
+const ptPerInch = 72
+
+// ErrTimeout is returned by WaitFrame
+// if the frame is not published before the timeout.
+var ErrTimeout = errors.New("timed out")
+
+// A Screen is a screen.Screen that draws off-screen.
+type Screen struct {
+	mu      sync.Mutex
+	windows []*Window
+}
+
+// NewScreen returns a new Screen.
+func NewScreen() *Screen { return new(Screen) }
+
+// Windows returns the Screen's windows, in the order that they were created.
+// Released windows are not included.
+func (s *Screen) Windows() []*Window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var wins []*Window
+	for _, w := range s.windows {
+		if !w.Released() {
+			wins = append(wins, w)
+		}
+	}
+	return wins
+}
+
+// NewBuffer returns a new buffer backed by an RGBA image.
+func (*Screen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &buffer{image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// NewTexture returns a new texture backed by an RGBA image.
+func (*Screen) NewTexture(size image.Point) (screen.Texture, error) {
+	return &texture{image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// NewWindow returns a new *Window.
+// The first event of the window is a size.Event
+// with the requested size at DPI.
+func (s *Screen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
+	w := &Window{
+		back: image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height)),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	w.Resize(image.Pt(opts.Width, opts.Height))
+	s.mu.Lock()
+	s.windows = append(s.windows, w)
+	s.mu.Unlock()
+	return w, nil
+}
+
+type buffer struct{ img *image.RGBA }
+
+func (*buffer) Release()                  {}
+func (b *buffer) Size() image.Point       { return b.img.Bounds().Size() }
+func (b *buffer) Bounds() image.Rectangle { return b.img.Bounds() }
+func (b *buffer) RGBA() *image.RGBA       { return b.img }
+
+type texture struct{ img *image.RGBA }
+
+func (*texture) Release()                  {}
+func (t *texture) Size() image.Point       { return t.img.Bounds().Size() }
+func (t *texture) Bounds() image.Rectangle { return t.img.Bounds() }
+
+func (t *texture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(t.img, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func (t *texture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(t.img, dr, image.NewUniform(src), image.ZP, op)
+}
+
+// A Frame is a published image of a Window.
+type Frame struct {
+	// N is the number of the frame.
+	// The first frame published by a Window is frame 1.
+	N int
+
+	// Image is the contents of the window when the frame was published.
+	Image *image.RGBA
+
+	// Ops describe the drawing operations
+	// since the previous frame, in order.
+	// For example:
+	// 	Fill (0,0)-(10,10) #ff0000ff Src
+	// 	Upload (0,0)-(10,10) from (0,0)
+	Ops []string
+}
+
+// A Window is a screen.Window that draws to an image.
+// It is a cursor.Setter, recording the shape of the cursor.
+//
+// The events sent to a Window are queued without bound.
+type Window struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	events   []interface{}
+	released bool
+	// Synced are the channels of sync events
+	// to close on the next call to NextEvent.
+	synced []chan struct{}
+
+	// Back is the back buffer, drawn to by the drawing methods.
+	back   *image.RGBA
+	ops    []string
+	frames int
+	frame  Frame
+
+	cursor cursor.Shape
+}
+
+type syncEvent struct{ done chan struct{} }
+
+// Send queues an event to be returned by NextEvent.
+func (w *Window) Send(event interface{}) {
+	w.mu.Lock()
+	w.events = append(w.events, event)
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// SendFirst queues an event to be returned by NextEvent
+// before all other queued events.
+func (w *Window) SendFirst(event interface{}) {
+	w.mu.Lock()
+	w.events = append([]interface{}{event}, w.events...)
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// NextEvent returns the next queued event,
+// blocking until there is one.
+func (w *Window) NextEvent() interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, done := range w.synced {
+		close(done)
+	}
+	w.synced = w.synced[:0]
+	for len(w.events) == 0 {
+		w.cond.Wait()
+	}
+	e := w.events[0]
+	w.events = w.events[1:]
+	if s, ok := e.(syncEvent); ok {
+		w.synced = append(w.synced, s.done)
+	}
+	return e
+}
+
+// Sync blocks until the window's event handler
+// has asked for the next event after all events sent before Sync.
+//
+// The ui package handles each event
+// before asking for the event after the next,
+// so after Sync returns, all events sent before Sync are handled.
+// Sync must not be called after the window is closed.
+func (w *Window) Sync() {
+	done := make(chan struct{})
+	w.Send(syncEvent{done: done})
+	<-done
+}
+
+// Release marks the Window as released.
+func (w *Window) Release() {
+	w.mu.Lock()
+	w.released = true
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// Released returns whether the Window is released.
+func (w *Window) Released() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.released
+}
+
+// SetCursor sets the shape of the cursor.
+func (w *Window) SetCursor(s cursor.Shape) {
+	w.mu.Lock()
+	w.cursor = s
+	w.mu.Unlock()
+}
+
+// Cursor returns the shape of the cursor,
+// as last set by SetCursor.
+func (w *Window) Cursor() cursor.Shape {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.cursor
+}
+
+// Resize sends a size.Event with the given size at DPI.
+func (w *Window) Resize(sz image.Point) {
+	const pxPerPt = float32(DPI) / ptPerInch
+	w.Send(size.Event{
+		WidthPx:     sz.X,
+		HeightPx:    sz.Y,
+		WidthPt:     geom.Pt(float32(sz.X) / pxPerPt),
+		HeightPt:    geom.Pt(float32(sz.Y) / pxPerPt),
+		PixelsPerPt: pxPerPt,
+	})
+}
+
+// Close sends a lifecycle.Event of the window dying,
+// as sent when the window is closed by the window manager.
+func (w *Window) Close() {
+	w.Send(lifecycle.Event{From: lifecycle.StageFocused, To: lifecycle.StageDead})
+}
+
+// Type sends a press and a release key.Event for each rune of the text.
+func (w *Window) Type(text string) {
+	for _, r := range text {
+		code := key.CodeUnknown
+		switch r {
+		case '\n':
+			code = key.CodeReturnEnter
+		case '\t':
+			code = key.CodeTab
+		case ' ':
+			code = key.CodeSpacebar
+		}
+		w.Send(key.Event{Rune: r, Code: code, Direction: key.DirPress})
+		w.Send(key.Event{Rune: r, Code: code, Direction: key.DirRelease})
+	}
+}
+
+// MoveMouse sends a mouse.Event of the mouse moving to the point.
+func (w *Window) MoveMouse(p image.Point) {
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y)})
+}
+
+// Press sends a mouse.Event of the button pressed at the point.
+func (w *Window) Press(p image.Point, b mouse.Button) {
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: b, Direction: mouse.DirPress})
+}
+
+// ReleaseButton sends a mouse.Event of the button released at the point.
+func (w *Window) ReleaseButton(p image.Point, b mouse.Button) {
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: b, Direction: mouse.DirRelease})
+}
+
+// Click moves the mouse to the point, and presses and releases the button.
+func (w *Window) Click(p image.Point, b mouse.Button) {
+	w.MoveMouse(p)
+	w.Press(p, b)
+	w.ReleaseButton(p, b)
+}
+
+// Frame returns the most recently published frame.
+// If no frame has been published, the zero Frame is returned.
+func (w *Window) Frame() Frame {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.frame
+}
+
+// WaitFrame blocks until frame n is published,
+// and returns the most recently published frame.
+// If frame n is not published before the timeout,
+// the most recently published frame and ErrTimeout are returned.
+func (w *Window) WaitFrame(n int, timeout time.Duration) (Frame, error) {
+	var timedOut bool
+	timer := time.AfterFunc(timeout, func() {
+		w.mu.Lock()
+		timedOut = true
+		w.mu.Unlock()
+		w.cond.Broadcast()
+	})
+	defer timer.Stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.frame.N < n {
+		if timedOut {
+			return w.frame, ErrTimeout
+		}
+		w.cond.Wait()
+	}
+	return w.frame, nil
+}
+
+// Publish records a Frame of the window.
+func (w *Window) Publish() screen.PublishResult {
+	w.mu.Lock()
+	w.frames++
+	img := image.NewRGBA(w.back.Bounds())
+	copy(img.Pix, w.back.Pix)
+	w.frame = Frame{N: w.frames, Image: img, Ops: w.ops}
+	w.ops = nil
+	w.mu.Unlock()
+	w.cond.Broadcast()
+	return screen.PublishResult{BackBufferPreserved: true}
+}
+
+func (w *Window) op(format string, args ...interface{}) {
+	w.mu.Lock()
+	w.ops = append(w.ops, fmt.Sprintf(format, args...))
+	w.mu.Unlock()
+}
+
+// Upload draws the buffer to the window.
+func (w *Window) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	dr := sr.Sub(sr.Min).Add(dp)
+	w.op("Upload %v from %v", dr, sr.Min)
+	draw.Draw(w.back, dr, src.RGBA(), sr.Min, draw.Src)
+}
+
+// Fill fills the rectangle of the window with the color.
+func (w *Window) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	w.op("Fill %v %s %s", dr, colorString(src), opString(op))
+	draw.Draw(w.back, dr, image.NewUniform(src), image.ZP, op)
+}
+
+// Draw draws the texture to the window with the affine transform.
+func (w *Window) Draw(src2dst f64.Aff3, src screen.Texture, sr image.Rectangle, op draw.Op, opts *screen.DrawOptions) {
+	w.op("Draw %v %v %s", src2dst, sr, opString(op))
+	xdraw.NearestNeighbor.Transform(w.back, src2dst, src.(*texture).img, sr, xdraw.Op(op), nil)
+}
+
+// DrawUniform draws the color to the window with the affine transform.
+func (w *Window) DrawUniform(src2dst f64.Aff3, src color.Color, sr image.Rectangle, op draw.Op, opts *screen.DrawOptions) {
+	w.op("DrawUniform %v %s %v %s", src2dst, colorString(src), sr, opString(op))
+	xdraw.NearestNeighbor.Transform(w.back, src2dst, image.NewUniform(src), sr, xdraw.Op(op), nil)
+}
+
+// Copy draws the texture to the window at the point.
+func (w *Window) Copy(dp image.Point, src screen.Texture, sr image.Rectangle, op draw.Op, opts *screen.DrawOptions) {
+	dr := sr.Sub(sr.Min).Add(dp)
+	w.op("Copy %v from %v %s", dr, sr.Min, opString(op))
+	draw.Draw(w.back, dr, src.(*texture).img, sr.Min, op)
+}
+
+// Scale draws the texture to the window, scaled to the rectangle.
+func (w *Window) Scale(dr image.Rectangle, src screen.Texture, sr image.Rectangle, op draw.Op, opts *screen.DrawOptions) {
+	w.op("Scale %v from %v %s", dr, sr, opString(op))
+	xdraw.NearestNeighbor.Scale(w.back, dr, src.(*texture).img, sr, xdraw.Op(op), nil)
+}
+
+func colorString(c color.Color) string {
+	r := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", r.R, r.G, r.B, r.A)
+}
+
+func opString(op draw.Op) string {
+	if op == draw.Src {
+		return "Src"
+	}
+	return "Over"
+}
+
+// A Clock is a ui.Clock that only advances with calls to Advance.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []timer
+}
+
+type timer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock returns a new Clock with the given current time.
+func NewClock(now time.Time) *Clock { return &Clock{now: now} }
+
+// Now returns the current time of the Clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel on which the time is sent
+// once the Clock has advanced by the duration.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, timer{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance advances the Clock by the duration,
+// sending on the channels returned by After that are due,
+// in the order that they are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for {
+		i := -1
+		for j, t := range c.timers {
+			if !t.at.After(c.now) && (i < 0 || t.at.Before(c.timers[i].at)) {
+				i = j
+			}
+		}
+		if i < 0 {
+			return
+		}
+		c.timers[i].c <- c.timers[i].at
+		c.timers = append(c.timers[:i], c.timers[i+1:]...)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright © 2016, The T Authors.
+
+package headless
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/editor/editortest"
+	"github.com/eaburns/T/ui"
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/paint"
+	"golang.org/x/mobile/event/size"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	a := c.After(10 * time.Second)
+	b := c.After(5 * time.Second)
+	d := c.After(20 * time.Second)
+
+	c.Advance(10 * time.Second)
+	if got, want := c.Now(), start.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("c.Now()=%v, want %v", got, want)
+	}
+	for _, test := range []struct {
+		name string
+		c    <-chan time.Time
+		want time.Time
+	}{
+		{"After(10s)", a, start.Add(10 * time.Second)},
+		{"After(5s)", b, start.Add(5 * time.Second)},
+	} {
+		select {
+		case got := <-test.c:
+			if !got.Equal(test.want) {
+				t.Errorf("%s sent %v, want %v", test.name, got, test.want)
+			}
+		default:
+			t.Errorf("%s not sent", test.name)
+		}
+	}
+	select {
+	case got := <-d:
+		t.Errorf("After(20s) sent %v, want nothing", got)
+	default:
+	}
+	c.Advance(10 * time.Second)
+	select {
+	case <-d:
+	default:
+		t.Errorf("After(20s) not sent")
+	}
+}
+
+func TestWindowEvents(t *testing.T) {
+	scr := NewScreen()
+	win, err := scr.NewWindow(&screen.NewWindowOptions{Width: 10, Height: 20})
+	if err != nil {
+		t.Fatalf("scr.NewWindow(…)=_,%v", err)
+	}
+	w := win.(*Window)
+	if got := scr.Windows(); !reflect.DeepEqual(got, []*Window{w}) {
+		t.Errorf("scr.Windows()=%v, want [%p]", got, w)
+	}
+	e, ok := w.NextEvent().(size.Event)
+	if !ok || e.WidthPx != 10 || e.HeightPx != 20 || e.PixelsPerPt*ptPerInch != DPI {
+		t.Errorf("first event=%#v, want a 10x20 size.Event at %d DPI", e, DPI)
+	}
+
+	w.Send(paint.Event{})
+	w.SendFirst(paint.Event{External: true})
+	go func() {
+		for {
+			w.NextEvent()
+		}
+	}()
+	w.Sync()
+	w.mu.Lock()
+	n := len(w.events)
+	w.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d events queued after Sync, want 0", n)
+	}
+
+	w.Release()
+	if got := scr.Windows(); len(got) != 0 {
+		t.Errorf("scr.Windows()=%v after Release, want []", got)
+	}
+}
+
+func TestWindowFrame(t *testing.T) {
+	scr := NewScreen()
+	win, err := scr.NewWindow(&screen.NewWindowOptions{Width: 10, Height: 10})
+	if err != nil {
+		t.Fatalf("scr.NewWindow(…)=_,%v", err)
+	}
+	w := win.(*Window)
+	if f, err := w.WaitFrame(1, time.Millisecond); err != ErrTimeout || f.N != 0 {
+		t.Errorf("w.WaitFrame(1, 1ms)=%d,%v, want 0,%v", f.N, err, ErrTimeout)
+	}
+
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	w.Fill(image.Rect(0, 0, 10, 10), color.White, draw.Src)
+	buf, err := scr.NewBuffer(image.Pt(5, 5))
+	if err != nil {
+		t.Fatalf("scr.NewBuffer(…)=_,%v", err)
+	}
+	draw.Draw(buf.RGBA(), buf.Bounds(), image.NewUniform(red), image.ZP, draw.Src)
+	w.Upload(image.Pt(2, 3), buf, image.Rect(1, 1, 3, 3))
+	w.Publish()
+
+	f, err := w.WaitFrame(1, time.Second)
+	if err != nil {
+		t.Fatalf("w.WaitFrame(1, 1s)=_,%v", err)
+	}
+	want := []string{
+		"Fill (0,0)-(10,10) #ffffffff Src",
+		"Upload (2,3)-(4,5) from (1,1)",
+	}
+	if f.N != 1 || !reflect.DeepEqual(f.Ops, want) {
+		t.Errorf("frame=%d %q, want 1 %q", f.N, f.Ops, want)
+	}
+	for _, test := range []struct {
+		p    image.Point
+		want color.RGBA
+	}{
+		{image.Pt(0, 0), color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}},
+		{image.Pt(2, 3), red},
+		{image.Pt(3, 4), red},
+		{image.Pt(4, 5), color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}},
+	} {
+		if got := f.Image.RGBAAt(test.p.X, test.p.Y); got != test.want {
+			t.Errorf("frame pixel %v=%v, want %v", test.p, got, test.want)
+		}
+	}
+
+	// Later drawing does not change the published frame.
+	w.Fill(image.Rect(0, 0, 10, 10), red, draw.Src)
+	if got := f.Image.RGBAAt(0, 0); got == red {
+		t.Errorf("frame pixel (0,0) changed after publish")
+	}
+}
+
+// TestServer tests drawing a ui.Server window
+// paced by a Clock.
+func TestServer(t *testing.T) {
+	editorServer := editortest.NewServer(editor.NewServer())
+	defer editorServer.Close()
+
+	scr := NewScreen()
+	clock := NewClock(time.Now())
+	uiServer := ui.NewServer(scr, editorServer.PathURL("/"))
+	uiServer.SetClock(clock)
+	router := mux.NewRouter()
+	uiServer.RegisterHandlers(router)
+	httpServer := httptest.NewServer(router)
+	defer httpServer.Close()
+	defer uiServer.Close()
+
+	winsURL, err := url.Parse(httpServer.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q)=_,%v", httpServer.URL, err)
+	}
+	winsURL.Path = path.Join("/", "windows")
+	if _, err := ui.NewWindow(winsURL, image.Pt(400, 300)); err != nil {
+		t.Fatalf("ui.NewWindow(%s, …)=_,%v", winsURL, err)
+	}
+	wins := scr.Windows()
+	if len(wins) != 1 {
+		t.Fatalf("%d windows, want 1", len(wins))
+	}
+	w := wins[0]
+
+	w.Send(paint.Event{})
+	w.Sync()
+	if f := w.Frame(); f.N != 0 {
+		t.Errorf("frame %d published before the clock advanced", f.N)
+	}
+	clock.Advance(time.Second)
+	f, err := w.WaitFrame(1, 10*time.Second)
+	if err != nil {
+		t.Fatalf("w.WaitFrame(1, 10s)=_,%v", err)
+	}
+	if got := f.Image.Bounds().Size(); got != image.Pt(400, 300) {
+		t.Errorf("frame size=%v, want %v", got, image.Pt(400, 300))
+	}
+	if len(f.Ops) == 0 {
+		t.Errorf("frame has no drawing operations")
+	}
+	bg := color.RGBAModel.Convert(ui.DefaultTheme().ColumnTagBG)
+	if got := f.Image.At(1, 1); color.RGBAModel.Convert(got) != bg {
+		t.Errorf("frame pixel (1,1)=%v, want %v", got, bg)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"path"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/ui/syntax"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/mobile/event/paint"
+)
+
+// A highlighter tracks the syntax tokens of a textBox's buffer.
+//
+// Changes to the buffer are applied to the tokens incrementally
+// if the change text is inlined in the change notification.
+// Otherwise, the entire text is re-read and re-tokenized.
+type highlighter struct {
+	changes *editor.ChangeStream
+
+	mu  sync.Mutex
+	hl  *syntax.Highlighter
+	seq int
+}
+
+func newHighlighter(t *textBox, tok syntax.Tokenizer) (*highlighter, error) {
+	changesURL := *t.bufferURL
+	changesURL.Path = path.Join(t.bufferURL.Path, "changes")
+	changesURL.Scheme = "ws"
+	changes, err := editor.Changes(&changesURL)
+	if err != nil {
+		return nil, err
+	}
+	h := &highlighter{changes: changes}
+	if err := h.reset(t, tok); err != nil {
+		changes.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			cl, err := changes.Next()
+			if err != nil {
+				return
+			}
+			if err := h.change(t, tok, cl); err != nil {
+				t.logf("failed to highlight: %v", err)
+				return
+			}
+			t.mu.Lock()
+			t.reset = true
+			if t.win != nil {
+				t.win.Send(paint.Event{})
+			}
+			t.mu.Unlock()
+		}
+	}()
+	return h, nil
+}
+
+func (h *highlighter) close() { h.changes.Close() }
+
+// Reset re-reads and re-tokenizes the entire text.
+func (h *highlighter) reset(t *textBox, tok syntax.Tokenizer) error {
+	res, err := t.view.Do(edit.Print(edit.All))
+	if err != nil {
+		return err
+	}
+	hl := syntax.NewHighlighter(tok, []byte(res[0].Print))
+	h.mu.Lock()
+	h.hl, h.seq = hl, res[0].Sequence
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *highlighter) change(t *textBox, tok syntax.Tokenizer, cl editor.ChangeList) error {
+	h.mu.Lock()
+	if cl.Sequence <= h.seq {
+		// The change was already read by reset.
+		h.mu.Unlock()
+		return nil
+	}
+	inline := true
+	for _, c := range cl.Changes {
+		if c.NewSize > 0 && len(c.Text) == 0 {
+			inline = false
+			break
+		}
+	}
+	if inline {
+		for _, c := range cl.Changes {
+			h.hl.Change(c.Span, c.Text)
+		}
+		h.seq = cl.Sequence
+		h.mu.Unlock()
+		return nil
+	}
+	h.mu.Unlock()
+	return h.reset(t, tok)
+}
+
+// Tokens returns the tokens overlapping the span.
+func (h *highlighter) tokens(span edit.Span) []syntax.Token {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]syntax.Token{}, h.hl.Tokens(span)...)
+}
+
+// SetTokenizer sets the Tokenizer used to highlight the text box's syntax.
+// If tok is nil, the syntax is not highlighted.
+func (t *textBox) setTokenizer(tok syntax.Tokenizer) {
+	if t.syntax != nil {
+		t.syntax.close()
+		t.syntax = nil
+	}
+	if tok != nil {
+		h, err := newHighlighter(t, tok)
+		if err != nil {
+			t.logf("failed to highlight syntax: %v", err)
+		}
+		t.syntax = h
+	}
+	t.mu.Lock()
+	t.reset = true
+	t.mu.Unlock()
+}
+
+// AddStyled adds src, the text beginning at rune offset at in the buffer,
+// to the Setter.
+// Runes within tokens use the theme's syntax color as the foreground,
+// runes within highlights use the theme's highlight color as the background,
+// and runes within misspelled are underlined with the theme's misspelled color.
+// The tokens, highlights, and misspelled must each be sorted and non-overlapping.
+func addStyled(s *text.Setter, src []byte, at int64, tokens []syntax.Token, highlights, misspelled []edit.Span, def text.Style, th *Theme) {
+	styleAt := func(at int64) text.Style {
+		sty := def
+		for len(tokens) > 0 && tokens[0].Span[1] <= at {
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 && tokens[0].Span[0] <= at {
+			if c, ok := th.syntaxColor(tokens[0].Class); ok {
+				sty.FG = c
+			}
+		}
+		for len(highlights) > 0 && highlights[0][1] <= at {
+			highlights = highlights[1:]
+		}
+		if len(highlights) > 0 && highlights[0][0] <= at {
+			sty.BG = th.Highlight
+		}
+		for len(misspelled) > 0 && misspelled[0][1] <= at {
+			misspelled = misspelled[1:]
+		}
+		if len(misspelled) > 0 && misspelled[0][0] <= at {
+			sty.Underline = text.WavyUnderline
+			sty.UnderlineColor = th.Misspelled
+		}
+		return sty
+	}
+
+	var start int
+	sty := styleAt(at)
+	for i := 0; i < len(src); {
+		if next := styleAt(at); next != sty {
+			s.AddStyle(&sty, src[start:i])
+			start, sty = i, next
+		}
+		_, w := utf8.DecodeRune(src[i:])
+		i += w
+		at++
+	}
+	s.AddStyle(&sty, src[start:])
+}
@@ -0,0 +1,73 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/syntax"
+	_ "github.com/eaburns/T/ui/syntax/golang"
+)
+
+func TestSyntaxHighlighting(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "package main")); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	sheet0.setTagFileName("/tmp/main.go")
+
+	// Tokens waits for the body's tokens to equal want,
+	// and returns the last tokens.
+	tokens := func(want []syntax.Token) []syntax.Token {
+		var got []syntax.Token
+		for i := 0; i < 100; i++ {
+			w.Send(func() {
+				got = nil
+				if sheet0.body.syntax != nil {
+					got = sheet0.body.syntax.tokens(edit.Span{0, 100})
+				}
+			})
+			wait(w)
+			if reflect.DeepEqual(got, want) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return got
+	}
+
+	want := []syntax.Token{{Span: edit.Span{0, 7}, Class: syntax.Keyword}}
+	if got := tokens(want); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens=%v, want %v", got, want)
+	}
+
+	// A small, inline change.
+	if _, err := sheet0.body.doSync(edit.Change(edit.End, "\nfunc")); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	want = append(want, syntax.Token{Span: edit.Span{13, 17}, Class: syntax.Keyword})
+	if got := tokens(want); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens=%v, want %v", got, want)
+	}
+
+	// A large change, not inlined.
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, `x := "a long string"`)); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	want = []syntax.Token{{Span: edit.Span{5, 20}, Class: syntax.String}}
+	if got := tokens(want); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens=%v, want %v", got, want)
+	}
+
+	// Changing the file name to an unknown extension disables highlighting.
+	sheet0.setTagFileName("/tmp/main.txt")
+	if got := tokens(nil); got != nil {
+		t.Errorf("tokens=%v, want nil", got)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+)
+
+// MaxHistory is the maximum number of commands
+// in the history of a window.
+const maxHistory = 100
+
+// A recall is the state of recalling commands
+// from the history of a window into the end of a tag.
+type recall struct {
+	// Active is whether a command is being recalled.
+	// It is cleared by any key other than up or down,
+	// and by mouse presses.
+	active bool
+	// I is the index in the history of the recalled command,
+	// or len(history) if no command is recalled.
+	i int
+	// Span is the span of the recalled text in the tag.
+	span edit.Span
+}
+
+// AddHistory adds a command executed from a tag
+// to the end of the window's history,
+// unless it is empty or the same as the most recent command.
+func (w *window) addHistory(c string) {
+	c = strings.TrimSpace(c)
+	if c == "" {
+		return
+	}
+	if n := len(w.history); n > 0 && w.history[n-1] == c {
+		return
+	}
+	w.history = append(w.history, c)
+	if n := len(w.history); n > maxHistory {
+		w.history = append(w.history[:0], w.history[n-maxHistory:]...)
+	}
+}
+
+// IsTag returns whether the text box is a tag,
+// as opposed to the body of a sheet.
+func (t *textBox) isTag() bool {
+	return t.sheet == nil || t != t.sheet.body
+}
+
+// RecallKey handles a key event that recalls commands
+// from the window's history into the end of a tag.
+// It returns whether the event was handled.
+//
+// If the key is bound to the up command,
+// and dot is empty at the end of the tag,
+// or a command is already being recalled,
+// the previous command in the history replaces the recalled text.
+// If the key is bound to the down command,
+// and a command is being recalled,
+// the next command in the history replaces the recalled text,
+// or, after the most recent command, the recalled text is removed.
+func (t *textBox) recallKey(w *window, event key.Event) bool {
+	var d int
+	switch boundName(t.keymap(), event) {
+	case "up":
+		d = -1
+	case "down":
+		d = 1
+	default:
+		t.recall.active = false
+		return false
+	}
+	if !t.isTag() || w == nil {
+		return false
+	}
+	r := &t.recall
+	if !r.active {
+		if d > 0 || len(w.history) == 0 || t.dot0 != t.dot1 || t.dot1 != t.size {
+			return false
+		}
+		*r = recall{active: true, i: len(w.history), span: edit.Span{t.size, t.size}}
+	}
+	if r.i > len(w.history) {
+		r.i = len(w.history)
+	}
+	switch i := r.i + d; {
+	case i < 0:
+		return true
+	case i > len(w.history):
+		r.active = false
+		return true
+	default:
+		r.i = i
+	}
+	var text string
+	if r.i < len(w.history) {
+		text = w.history[r.i]
+	}
+	t.doAsync(
+		edit.Change(edit.Rune(r.span[0]).To(edit.Rune(r.span[1])), text),
+		edit.Set(edit.End, '.'),
+	)
+	r.span[1] = r.span[0] + int64(utf8.RuneCountInString(text))
+	if r.i == len(w.history) {
+		r.active = false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestAddHistory(t *testing.T) {
+	w := &window{}
+	for _, c := range []string{"a", " a ", "", "b", "a", "\n"} {
+		w.addHistory(c)
+	}
+	if want := []string{"a", "b", "a"}; !reflect.DeepEqual(w.history, want) {
+		t.Errorf("history=%q, want %q", w.history, want)
+	}
+
+	w = &window{}
+	for i := 0; i < maxHistory+10; i++ {
+		w.addHistory(strconv.Itoa(i))
+	}
+	if len(w.history) != maxHistory || w.history[0] != "10" {
+		t.Errorf("len(history)=%d, history[0]=%q, want %d, \"10\"", len(w.history), w.history[0], maxHistory)
+	}
+}
+
+func TestRecallHistory(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() {
+		w.addHistory("Put")
+		w.addHistory("Look x")
+	})
+	setTextDot(t, w, sheet0.tag, "tag ", edit.Span{4, 4})
+	mouseTo(w, sheet0.tag.topLeft.Add(image.Pt(1, 1)))
+
+	tests := []struct {
+		code key.Code
+		want string
+	}{
+		{key.CodeUpArrow, "tag Look x"},
+		{key.CodeUpArrow, "tag Put"},
+		{key.CodeUpArrow, "tag Put"},
+		{key.CodeDownArrow, "tag Look x"},
+		{key.CodeDownArrow, "tag "},
+	}
+	for _, test := range tests {
+		pressKey(w, -1, test.code)
+		wait(w)
+		if got := waitText(sheet0.tag, test.want); got != test.want {
+			t.Fatalf("after %v, tag text=%q, want %q", test.code, got, test.want)
+		}
+	}
+}
+
+func TestRecallHistory_NotAtEnd(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() { w.addHistory("Put") })
+	setTextDot(t, w, sheet0.tag, "tag ", edit.Span{1, 1})
+	mouseTo(w, sheet0.tag.topLeft.Add(image.Pt(1, 1)))
+
+	pressKey(w, -1, key.CodeUpArrow)
+	wait(w)
+	if got := waitText(sheet0.tag, "tag "); got != "tag " {
+		t.Errorf("tag text=%q, want %q", got, "tag ")
+	}
+}
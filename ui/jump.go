@@ -0,0 +1,190 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+
+	"github.com/eaburns/T/edit"
+)
+
+const (
+	// JumpRunes is the minimum distance, in runes,
+	// that dot of a sheet body must move
+	// for its previous location to be recorded in the jump list.
+	jumpRunes = 1000
+
+	// MaxJumps is the maximum number of locations in a jump list.
+	maxJumps = 100
+)
+
+// A location is a span of the body of a sheet.
+type location struct {
+	sheet *sheet
+	// File is the file that the body was loaded from or written to
+	// when the location was recorded, or "".
+	// If the sheet is closed, the file is re-opened.
+	file string
+	span edit.Span
+}
+
+// A jumpList is the history of the locations in the sheets of a window
+// from which dot jumped.
+//
+// A location is recorded when dot of a sheet body
+// moves at least jumpRunes,
+// and when a key or mouse button is pressed in the body of a sheet
+// other than the current sheet.
+// Back and Forward navigate the list.
+type jumpList struct {
+	locs []location
+	// I is the index in locs of the location navigated to
+	// by Back and Forward, or len(locs) if not navigating.
+	i int
+	// Cur is the current sheet: the sheet in the body of which
+	// a key or mouse button was last pressed,
+	// dot last jumped, or which was last navigated to.
+	cur *sheet
+}
+
+// PushJump records a location in the jump list,
+// discarding any locations ahead of the location navigated to.
+func (w *window) pushJump(l location) {
+	j := &w.jumps
+	if j.i < len(j.locs) {
+		j.locs = j.locs[:j.i]
+	}
+	if n := len(j.locs); n > 0 && j.locs[n-1].sheet == l.sheet && j.locs[n-1].span == l.span {
+		j.i = len(j.locs)
+		return
+	}
+	j.locs = append(j.locs, l)
+	if n := len(j.locs); n > maxJumps {
+		j.locs = append(j.locs[:0], j.locs[n-maxJumps:]...)
+	}
+	j.i = len(j.locs)
+}
+
+// CurrentLocation returns the location of dot in a sheet body.
+func currentLocation(s *sheet) location {
+	return location{sheet: s, file: s.file, span: edit.Span{s.body.dot0, s.body.dot1}}
+}
+
+// EnterSheet makes a sheet the current sheet.
+// If it was not already, the location of dot
+// in the previously current sheet is recorded.
+func (w *window) enterSheet(s *sheet) {
+	j := &w.jumps
+	if j.cur == s {
+		return
+	}
+	if j.cur != nil && j.cur.win == w {
+		w.pushJump(currentLocation(j.cur))
+	}
+	j.cur = s
+}
+
+// MoveDot notes that dot of a sheet body moved from a previous span.
+// If it moved at least jumpRunes, the previous span is recorded.
+func (t *textBox) moveDot(from edit.Span) {
+	to := edit.Span{t.dot0, t.dot1}
+	if t.jumpTarget != nil {
+		target := *t.jumpTarget
+		t.jumpTarget = nil
+		if to == target {
+			return
+		}
+	}
+	d := to[0] - from[0]
+	if d < 0 {
+		d = -d
+	}
+	if d < jumpRunes || t.sheet == nil || t != t.sheet.body {
+		return
+	}
+	t.mu.RLock()
+	w := t.win
+	t.mu.RUnlock()
+	if w != nil {
+		w.enterSheet(t.sheet)
+		w.pushJump(location{sheet: t.sheet, file: t.sheet.file, span: from})
+	}
+}
+
+// JumpBack navigates to the previous location of the jump list.
+func (w *window) jumpBack() {
+	j := &w.jumps
+	if j.i == 0 {
+		return
+	}
+	if j.i == len(j.locs) && j.cur != nil {
+		// Record the current location so that Forward returns to it.
+		w.pushJump(currentLocation(j.cur))
+		j.i = len(j.locs) - 1
+		if j.i == 0 {
+			return
+		}
+	}
+	j.i--
+	w.jumpTo(j.locs[j.i])
+}
+
+// JumpForward navigates to the next location of the jump list.
+func (w *window) jumpForward() {
+	j := &w.jumps
+	if j.i+1 >= len(j.locs) {
+		return
+	}
+	j.i++
+	w.jumpTo(j.locs[j.i])
+}
+
+// JumpTo sets dot of a sheet body to a location,
+// scrolls it into view, and focuses the body.
+// If the sheet was closed, its file is re-opened in a new sheet.
+func (w *window) jumpTo(l location) {
+	s := l.sheet
+	addr := edit.Clamp(edit.Rune(l.span[0])).To(edit.Clamp(edit.Rune(l.span[1])))
+	if s.win != w {
+		if l.file == "" {
+			return
+		}
+		w.server.Lock()
+		f, err := w.server.newSheet(w, w.server.editorURL, nil)
+		w.server.Unlock()
+		if err != nil {
+			w.logf("failed to re-open %s: %v", l.file, err)
+			return
+		}
+		for i := range w.jumps.locs {
+			if w.jumps.locs[i].sheet == s {
+				w.jumps.locs[i].sheet = f
+			}
+		}
+		w.jumps.cur = f
+		f.setTagFileName(l.file)
+		f.load(l.file, addr)
+		return
+	}
+	if s.col != nil && s.Dy() <= s.minHeight() {
+		s.toggleCollapse()
+	}
+	span := l.span
+	s.body.jumpTarget = &span
+	s.body.doAsync(edit.Set(addr, '.'))
+	s.body.view.Warp(dot)
+	w.jumps.cur = s
+	w.focusBody(s)
+}
+
+// FocusBody gives the focus to the body of a sheet.
+func (w *window) focusBody(s *sheet) {
+	if prev := w.inFocus; prev != handler(s) {
+		if prev != nil {
+			prev.changeFocus(w, false)
+		}
+		w.inFocus = s
+		s.changeFocus(w, true)
+	}
+	s.focus(image.Pt(s.Min.X, s.sep.Max.Y))
+}
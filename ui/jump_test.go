@@ -0,0 +1,157 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestPushJump(t *testing.T) {
+	s0, s1 := &sheet{}, &sheet{}
+	w := &window{}
+	w.pushJump(location{sheet: s0, span: edit.Span{1, 1}})
+	w.pushJump(location{sheet: s0, span: edit.Span{1, 1}})
+	w.pushJump(location{sheet: s1, span: edit.Span{2, 2}})
+	if len(w.jumps.locs) != 2 || w.jumps.i != 2 {
+		t.Fatalf("len(locs)=%d, i=%d, want 2, 2", len(w.jumps.locs), w.jumps.i)
+	}
+
+	// Pushing while navigating discards the locations ahead.
+	w.jumps.i = 1
+	w.pushJump(location{sheet: s0, span: edit.Span{3, 3}})
+	if len(w.jumps.locs) != 2 || w.jumps.i != 2 || w.jumps.locs[1].span != (edit.Span{3, 3}) {
+		t.Errorf("locs=%v, i=%d, want [s0:1 s0:3], 2", w.jumps.locs, w.jumps.i)
+	}
+
+	w = &window{}
+	for i := 0; i < maxJumps+10; i++ {
+		w.pushJump(location{sheet: s0, span: edit.Span{int64(i), int64(i)}})
+	}
+	if len(w.jumps.locs) != maxJumps || w.jumps.locs[0].span[0] != 10 {
+		t.Errorf("len(locs)=%d, locs[0]=%v, want %d, #10", len(w.jumps.locs), w.jumps.locs[0].span, maxJumps)
+	}
+}
+
+func TestJump_AcrossSheets(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+	setTextDot(t, w, sheet0.body, "abc", edit.Span{1, 1})
+	setTextDot(t, w, sheet1.body, "xyz", edit.Span{2, 2})
+
+	p0 := sheet0.body.topLeft.Add(image.Pt(1, 1))
+	mouseTo(w, p0)
+	click(w, p0, mouse.ButtonLeft)
+	p1 := sheet1.body.topLeft.Add(image.Pt(1, 1))
+	mouseTo(w, p1)
+	click(w, p1, mouse.ButtonLeft)
+	wait(w)
+
+	var cur *sheet
+	var inFocus handler
+	state := func() {
+		w.Send(func() { cur, inFocus = w.jumps.cur, w.inFocus })
+		wait(w)
+	}
+	w.Send(func() { w.jumpBack() })
+	state()
+	if cur != sheet0 || inFocus != handler(sheet0) {
+		t.Errorf("after back, cur=%p, inFocus=%p, want sheet0=%p", cur, inFocus, sheet0)
+	}
+	w.Send(func() { w.jumpForward() })
+	state()
+	if cur != sheet1 || inFocus != handler(sheet1) {
+		t.Errorf("after forward, cur=%p, inFocus=%p, want sheet1=%p", cur, inFocus, sheet1)
+	}
+}
+
+func TestJump_WithinSheet(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	text := strings.Repeat("abcdefghi\n", jumpRunes/5)
+	setTextDot(t, w, sheet0.body, text, edit.Span{5, 5})
+	far := int64(len(text) - 5)
+	if _, err := sheet0.body.doSync(edit.Set(edit.Rune(far), '.')); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	waitDot(w, sheet0.body, edit.Span{far, far})
+
+	w.Send(func() { w.jumpBack() })
+	if got := waitDot(w, sheet0.body, edit.Span{5, 5}); got != (edit.Span{5, 5}) {
+		t.Errorf("after back, dot=%v, want #5", got)
+	}
+	w.Send(func() { w.jumpForward() })
+	if got := waitDot(w, sheet0.body, edit.Span{far, far}); got != (edit.Span{far, far}) {
+		t.Errorf("after forward, dot=%v, want #%d", got, far)
+	}
+
+	// Navigating does not record jumps.
+	var n int
+	w.Send(func() { n = len(w.jumps.locs) })
+	wait(w)
+	if n != 2 {
+		t.Errorf("len(locs)=%d, want 2", n)
+	}
+}
+
+func TestJump_ReopenClosed(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	f, err := ioutil.TempFile("", "jump_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() {
+		w.pushJump(location{sheet: sheet0, file: f.Name(), span: edit.Span{0, 0}})
+	})
+	s.uiServer.deleteSheet(sheet0.id)
+	wait(w)
+	n := countSheets(w)
+	w.Send(func() { w.jumpBack() })
+	wait(w)
+	var m int
+	for i := 0; i < 100 && m != n+1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		m = countSheets(w)
+	}
+	if m != n+1 {
+		t.Errorf("sheets=%d, want %d", m, n+1)
+	}
+}
+
+// WaitDot returns dot of a text box
+// once it is the wanted span, or after a timeout.
+func waitDot(w *window, tb *textBox, want edit.Span) edit.Span {
+	var d edit.Span
+	for i := 0; i < 100 && d != want; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { d = edit.Span{tb.dot0, tb.dot1} })
+		wait(w)
+	}
+	return d
+}
+
+func countSheets(w *window) int {
+	var n int
+	w.Send(func() {
+		for _, c := range w.columns {
+			n += len(c.frames) - 1
+		}
+	})
+	wait(w)
+	return n
+}
@@ -0,0 +1,308 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/mobile/event/key"
+)
+
+// A Keymap maps key chords to the names of commands.
+//
+// Key chords are written Emacs-style:
+// zero or more modifier prefixes followed by a key name.
+// The modifier prefixes are
+// C- for control,
+// M- for alt,
+// s- for meta (the command or windows key),
+// and S- for shift.
+// A key name is either a single rune
+// or one of the following special key names:
+// Up, Down, Left, Right, Home, End, PageUp, PageDown,
+// Backspace, Delete, Return, Tab, Escape, and Space.
+//
+// The shift modifier only applies to special keys;
+// for runes, use the shifted rune instead.
+// For example, write C-A, not C-S-a.
+//
+// Keys that are not bound to a command,
+// pressed with either no modifier or only shift,
+// insert their rune.
+type Keymap map[string]string
+
+// DefaultKeymap returns a new Keymap with the default bindings.
+func DefaultKeymap() Keymap {
+	return Keymap{
+		"Up":        "up",
+		"Down":      "down",
+		"Left":      "left",
+		"Right":     "right",
+		"Backspace": "backspace",
+		"Return":    "newline",
+		"Tab":       "tab",
+		"C-a":       "line-start",
+		"C-e":       "line-end",
+		"C-h":       "backspace",
+		"C-u":       "delete-line-backward",
+		"C-w":       "delete-word-backward",
+		"C-s":       "find",
+		"M-z":       "zoom",
+		"M-c":       "collapse",
+		"M-Left":    "jump-back",
+		"M-Right":   "jump-forward",
+	}
+}
+
+// Load reads bindings from r, adding them to the Keymap
+// and replacing any existing bindings for the same key chords.
+//
+// Each line of the input is either blank, a comment beginning with #,
+// or a binding: a key chord followed by white space
+// and the name of a registered command.
+// The command name none removes the binding of the key chord.
+func (km Keymap) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected a key chord and a command", n)
+		}
+		chord, err := parseChord(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		if fields[1] == "none" {
+			delete(km, chord)
+			continue
+		}
+		if !isCommand(fields[1]) {
+			return fmt.Errorf("line %d: unknown command %s", n, fields[1])
+		}
+		km[chord] = fields[1]
+	}
+	return scanner.Err()
+}
+
+// Commands returns the sorted names of all registered commands.
+func Commands() []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	for name := range sheetCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterCommand registers a command that can be bound to key chords.
+// When the command's key chord is pressed,
+// f is called with a function that performs edits
+// on the text of the focused tag or body
+// and returns their results.
+//
+// RegisterCommand panics if name is already registered
+// or if name contains white space.
+func RegisterCommand(name string, f func(do func(...edit.Edit) ([]editor.EditResult, error))) {
+	if name == "" || name == "none" || strings.ContainsAny(name, " \t\n") {
+		panic("bad command name: " + name)
+	}
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	if _, ok := commands[name]; ok || sheetCommands[name] != nil {
+		panic("command already registered: " + name)
+	}
+	commands[name] = func(h keyHandler) { f(h.doSync) }
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = map[string]func(keyHandler){
+		"up": func(h keyHandler) {
+			col := getColumn(h)
+			re := fmt.Sprintf("(?:.?){%d}", col)
+			up := dot.Minus(oneLine).Minus(zero).Plus(edit.Regexp(re)).Plus(zero)
+			h.doAsync(edit.Set(up, '.'))
+			h.setColumn(col)
+		},
+		"down": func(h keyHandler) {
+			col := getColumn(h)
+			re := fmt.Sprintf("(?:.?){%d}", col)
+			// We use .-1+2, because .+1 does not move dot
+			// if it is at the beginning of an empty line.
+			down := dot.Minus(oneLine).Plus(twoLines).Minus(zero).Plus(edit.Regexp(re)).Plus(zero)
+			h.doAsync(edit.Set(down, '.'))
+			h.setColumn(col)
+		},
+		"right":     func(h keyHandler) { h.doAsync(moveDotRight) },
+		"left":      func(h keyHandler) { h.doAsync(moveDotLeft) },
+		"backspace": func(h keyHandler) { h.doAsync(backspace) },
+		"newline":   func(h keyHandler) { h.doAsync(newline...) },
+		"tab":       func(h keyHandler) { h.doAsync(tab...) },
+		"line-start": func(h keyHandler) {
+			h.doAsync(edit.Set(dot.Minus(edit.Line(0)).Minus(zero), '.'))
+		},
+		"line-end": func(h keyHandler) {
+			h.doAsync(edit.Set(dot.Minus(zero).Plus(edit.Regexp("$")), '.'))
+		},
+		"delete-line-backward": func(h keyHandler) { h.doAsync(backline) },
+		"delete-word-backward": func(h keyHandler) { h.doAsync(backword) },
+	}
+)
+
+// SheetCommands are commands that operate on a sheet
+// instead of on the text of its tag or body.
+var sheetCommands = map[string]func(*sheet){
+	"find":         (*sheet).startFind,
+	"line-numbers": (*sheet).toggleLineNumbers,
+	"zoom":         (*sheet).toggleZoom,
+	"collapse":     (*sheet).toggleCollapse,
+	"jump-back":    func(s *sheet) { s.win.jumpBack() },
+	"jump-forward": func(s *sheet) { s.win.jumpForward() },
+}
+
+func lookupCommand(name string) func(keyHandler) {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	return commands[name]
+}
+
+func isCommand(name string) bool {
+	return lookupCommand(name) != nil || sheetCommands[name] != nil
+}
+
+var keyNames = map[key.Code]string{
+	key.CodeUpArrow:         "Up",
+	key.CodeDownArrow:       "Down",
+	key.CodeLeftArrow:       "Left",
+	key.CodeRightArrow:      "Right",
+	key.CodeHome:            "Home",
+	key.CodeEnd:             "End",
+	key.CodePageUp:          "PageUp",
+	key.CodePageDown:        "PageDown",
+	key.CodeDeleteBackspace: "Backspace",
+	key.CodeDeleteForward:   "Delete",
+	key.CodeReturnEnter:     "Return",
+	key.CodeTab:             "Tab",
+	key.CodeEscape:          "Escape",
+	key.CodeSpacebar:        "Space",
+}
+
+var modifierPrefixes = []struct {
+	mod    key.Modifiers
+	prefix string
+}{
+	{key.ModControl, "C-"},
+	{key.ModAlt, "M-"},
+	{key.ModMeta, "s-"},
+	{key.ModShift, "S-"},
+}
+
+// ChordName returns the key chord name of a key event,
+// and whether the key has a special key name.
+// If the key has neither a special name nor a rune, the name is "".
+func chordName(event key.Event) (string, bool) {
+	mods := event.Modifiers
+	name, special := keyNames[event.Code]
+	if !special {
+		if event.Rune < 0 {
+			return "", false
+		}
+		name = string(event.Rune)
+		mods &^= key.ModShift
+	}
+	var prefix string
+	for _, m := range modifierPrefixes {
+		if mods&m.mod != 0 {
+			prefix += m.prefix
+		}
+	}
+	return prefix + name, special
+}
+
+// ParseChord returns the canonical name of a key chord:
+// the name with its modifier prefixes in a fixed order.
+func parseChord(s string) (string, error) {
+	orig := s
+	var mods key.Modifiers
+	for len(s) > 2 && s[1] == '-' {
+		var ok bool
+		for _, m := range modifierPrefixes {
+			if strings.HasPrefix(s, m.prefix) {
+				mods |= m.mod
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return "", errors.New("bad modifier in key chord " + orig)
+		}
+		s = s[2:]
+	}
+	var event key.Event
+	if r, w := utf8.DecodeRuneInString(s); w == len(s) && r != utf8.RuneError {
+		event.Rune = r
+		if mods&key.ModShift != 0 {
+			return "", errors.New("shift modifier on a rune in key chord " + orig)
+		}
+	} else {
+		event.Rune = -1
+		for c, name := range keyNames {
+			if name == s {
+				event.Code = c
+				break
+			}
+		}
+		if event.Code == key.CodeUnknown {
+			return "", errors.New("bad key name in key chord " + orig)
+		}
+	}
+	event.Modifiers = mods
+	name, _ := chordName(event)
+	return name, nil
+}
+
+// BoundName returns the name of the command
+// bound to the key event in a Keymap,
+// or "" if there is none.
+//
+// Special keys with modifiers that are not bound
+// fall back to the binding of the key without modifiers.
+func boundName(km Keymap, event key.Event) string {
+	name, special := chordName(event)
+	if name == "" {
+		return ""
+	}
+	cmd, ok := km[name]
+	if !ok && special && event.Modifiers != 0 {
+		cmd = km[keyNames[event.Code]]
+	}
+	return cmd
+}
+
+// BoundCommand returns the text command bound to the key event in a Keymap,
+// or nil if there is none.
+func boundCommand(km Keymap, event key.Event) func(keyHandler) {
+	name := boundName(km, event)
+	if name == "" {
+		return nil
+	}
+	return lookupCommand(name)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestParseChord(t *testing.T) {
+	tests := []struct {
+		chord, want string
+		err         string
+	}{
+		{chord: "a", want: "a"},
+		{chord: "A", want: "A"},
+		{chord: "-", want: "-"},
+		{chord: "α", want: "α"},
+		{chord: "C-a", want: "C-a"},
+		{chord: "C--", want: "C--"},
+		{chord: "M-C-a", want: "C-M-a"},
+		{chord: "s-M-C-a", want: "C-M-s-a"},
+		{chord: "Up", want: "Up"},
+		{chord: "S-Up", want: "S-Up"},
+		{chord: "S-C-Return", want: "C-S-Return"},
+		{chord: "", err: "bad key name"},
+		{chord: "Foo", err: "bad key name"},
+		{chord: "C-", err: "bad key name"},
+		{chord: "X-a", err: "bad modifier"},
+		{chord: "S-a", err: "shift modifier on a rune"},
+	}
+	for _, test := range tests {
+		got, err := parseChord(test.chord)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("parseChord(%q)=%q,%v, want error containing %q", test.chord, got, err, test.err)
+			}
+			continue
+		}
+		if got != test.want || err != nil {
+			t.Errorf("parseChord(%q)=%q,%v, want %q,nil", test.chord, got, err, test.want)
+		}
+	}
+}
+
+func TestChordName(t *testing.T) {
+	tests := []struct {
+		event   key.Event
+		want    string
+		special bool
+	}{
+		{event: key.Event{Rune: 'a', Code: key.CodeA}, want: "a"},
+		{event: key.Event{Rune: 'A', Code: key.CodeA, Modifiers: key.ModShift}, want: "A"},
+		{event: key.Event{Rune: 'a', Code: key.CodeA, Modifiers: key.ModControl}, want: "C-a"},
+		{event: key.Event{Rune: 'a', Code: key.CodeA, Modifiers: key.ModControl | key.ModAlt}, want: "C-M-a"},
+		{event: key.Event{Rune: -1, Code: key.CodeUpArrow}, want: "Up", special: true},
+		{event: key.Event{Rune: -1, Code: key.CodeUpArrow, Modifiers: key.ModShift}, want: "S-Up", special: true},
+		{event: key.Event{Rune: ' ', Code: key.CodeSpacebar}, want: "Space", special: true},
+		{event: key.Event{Rune: -1, Code: key.CodeLeftShift}, want: ""},
+	}
+	for _, test := range tests {
+		got, special := chordName(test.event)
+		if got != test.want || special != test.special {
+			t.Errorf("chordName(%+v)=%q,%t, want %q,%t", test.event, got, special, test.want, test.special)
+		}
+	}
+}
+
+func TestKeymapLoad(t *testing.T) {
+	const config = `
+# Emacs-style movement.
+C-f	right
+C-b	left
+M-C-p	up
+
+C-u	none
+`
+	km := DefaultKeymap()
+	if err := km.Load(strings.NewReader(config)); err != nil {
+		t.Fatalf("km.Load(…)=%v, want nil", err)
+	}
+	want := DefaultKeymap()
+	want["C-f"] = "right"
+	want["C-b"] = "left"
+	want["C-M-p"] = "up"
+	delete(want, "C-u")
+	if !reflect.DeepEqual(km, want) {
+		t.Errorf("km=%v, want %v", km, want)
+	}
+
+	errs := []string{
+		"C-f",
+		"C-f right left",
+		"X-f right",
+		"C-f no-such-command",
+	}
+	for _, config := range errs {
+		if err := make(Keymap).Load(strings.NewReader(config)); err == nil {
+			t.Errorf("Load(%q)=nil, want error", config)
+		}
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	RegisterCommand("test-upper-line", func(do func(...edit.Edit) ([]editor.EditResult, error)) {
+		do(edit.Change(dot.Minus(edit.Line(0)).To(dot.Plus(zero).Plus(edit.Regexp("$"))), "ABC"))
+	})
+	defer func() {
+		commandsMu.Lock()
+		delete(commands, "test-upper-line")
+		commandsMu.Unlock()
+	}()
+
+	buf := edit.NewBuffer()
+	defer buf.Close()
+	if err := edit.Change(edit.All, "abc").Do(buf, ioutil.Discard); err != nil {
+		t.Fatalf("failed to init buffer text: %v", err)
+	}
+
+	h := newTestHandler(buf)
+	if err := h.km.Load(strings.NewReader("C-M-u test-upper-line")); err != nil {
+		t.Fatalf("h.km.Load(…)=%v, want nil", err)
+	}
+	handleKey(h, key.Event{
+		Rune:      'u',
+		Code:      key.CodeU,
+		Modifiers: key.ModControl | key.ModAlt,
+		Direction: key.DirPress,
+	})
+	d, err := ioutil.ReadAll(buf.Reader(edit.Span{0: 0, 1: buf.Size()}))
+	if err != nil {
+		t.Fatalf("failed to read buffer: %v", err)
+	}
+	if s := string(d); s != "ABC" {
+		t.Errorf("buffer text=%q, want %q", s, "ABC")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("RegisterCommand(\"test-upper-line\", …) did not panic on a duplicate")
+		}
+	}()
+	RegisterCommand("test-upper-line", nil)
+}
@@ -0,0 +1,261 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor/view"
+)
+
+// Dump returns the layout of the server's windows.
+//
+// Dump must not be called in a window's UI goroutine.
+func (s *Server) Dump() (Layout, error) {
+	s.RLock()
+	wins := make([]*window, 0, len(s.windows))
+	for _, w := range s.windows {
+		wins = append(wins, w)
+	}
+	sort.Sort(windowsByID(wins))
+	dumps := make([]*windowDump, len(wins))
+	for i, w := range wins {
+		dumps[i] = dumpWindow(w)
+	}
+	s.RUnlock()
+
+	var layout Layout
+	for _, d := range dumps {
+		wl, err := d.layout()
+		if err != nil {
+			return Layout{}, err
+		}
+		layout.Windows = append(layout.Windows, wl)
+	}
+	return layout, nil
+}
+
+type windowsByID []*window
+
+func (ws windowsByID) Len() int      { return len(ws) }
+func (ws windowsByID) Swap(i, j int) { ws[i], ws[j] = ws[j], ws[i] }
+func (ws windowsByID) Less(i, j int) bool {
+	x, _ := strconv.Atoi(ws[i].id)
+	y, _ := strconv.Atoi(ws[j].id)
+	return x < y
+}
+
+// A windowDump is the layout of a window
+// read in the window's UI goroutine.
+type windowDump struct {
+	done chan struct{}
+	wl   WindowLayout
+	// Tags are the column tags.
+	tags []*textBox
+	// Sheets are the sheets of each column.
+	sheets [][]*sheet
+}
+
+// DumpWindow asynchronously reads the layout of a window
+// in the window's UI goroutine.
+func dumpWindow(w *window) *windowDump {
+	d := &windowDump{done: make(chan struct{})}
+	w.Send(func() {
+		d.wl.Width, d.wl.Height = w.Dx(), w.Dy()
+		for i, c := range w.columns {
+			cl := ColumnLayout{X: w.xs[i]}
+			var sheets []*sheet
+			for j, f := range c.frames {
+				if s, ok := f.(*sheet); ok {
+					cl.Sheets = append(cl.Sheets, SheetLayout{Y: c.ys[j], NoWrap: !s.wrap()})
+					sheets = append(sheets, s)
+				}
+			}
+			d.wl.Columns = append(d.wl.Columns, cl)
+			d.tags = append(d.tags, c.frames[0].(*columnTag).text)
+			d.sheets = append(d.sheets, sheets)
+		}
+		close(d.done)
+	})
+	return d
+}
+
+// Layout waits for the window's layout to be read,
+// and returns it with the text of the tags and the state of the bodies.
+func (d *windowDump) layout() (WindowLayout, error) {
+	<-d.done
+	for i, t := range d.tags {
+		cl := &d.wl.Columns[i]
+		var err error
+		if cl.Tag, err = printAll(t); err != nil {
+			return WindowLayout{}, err
+		}
+		for j, s := range d.sheets[i] {
+			sl := &cl.Sheets[j]
+			if sl.Tag, err = printAll(s.tag); err != nil {
+				return WindowLayout{}, err
+			}
+			sl.BodyURL = s.body.bufferURL.String()
+			s.body.view.View(func(_ []byte, marks []view.Mark) {
+				for _, m := range marks {
+					switch m.Name {
+					case view.ViewMark:
+						sl.Scroll = m.Where[0]
+					case '.':
+						sl.Dot = m.Where
+					}
+				}
+			})
+		}
+	}
+	return d.wl, nil
+}
+
+func printAll(t *textBox) (string, error) {
+	res, err := t.view.Do(edit.Print(edit.All))
+	if err != nil {
+		return "", err
+	}
+	if res[0].Error != "" {
+		return "", errors.New(res[0].Error)
+	}
+	return res[0].Print, nil
+}
+
+// Load opens new windows with the given layout.
+//
+// Load must not be called in a window's UI goroutine.
+func (s *Server) Load(layout Layout) error {
+	for _, wl := range layout.Windows {
+		if err := s.loadWindow(wl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) loadWindow(wl WindowLayout) error {
+	s.Lock()
+	id := strconv.Itoa(s.nextID)
+	s.nextID++
+	s.Unlock()
+	w, err := newWindow(id, s, image.Pt(wl.Width, wl.Height))
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.windows[id] = w
+	s.Unlock()
+
+	var cols []*column
+	errChan := make(chan error)
+	w.Send(func() {
+		for i, cl := range wl.Columns {
+			c := w.columns[0]
+			if i > 0 {
+				var err error
+				if c, err = newColumn(w); err != nil {
+					errChan <- err
+					return
+				}
+				if !w.addColumn(cl.X, c) {
+					c.close()
+					errChan <- errors.New("column does not fit")
+					return
+				}
+			}
+			if cl.Tag != "" {
+				tag := c.frames[0].(*columnTag).text
+				tag.doAsync(edit.Change(edit.All, cl.Tag), edit.Set(edit.End, '.'))
+			}
+			cols = append(cols, c)
+		}
+		errChan <- nil
+	})
+	if err := <-errChan; err != nil {
+		return err
+	}
+	for i, cl := range wl.Columns {
+		for _, sl := range cl.Sheets {
+			if err := s.loadSheet(w, cols[i], sl); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) loadSheet(w *window, c *column, sl SheetLayout) error {
+	URL, err := url.Parse(sl.BodyURL)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	f, err := newSheet(strconv.Itoa(s.nextID), URL, w)
+	reused := err == nil
+	if err != nil {
+		// The body's buffer is gone; use a new one.
+		f, err = newSheet(strconv.Itoa(s.nextID), s.editorURL, w)
+	}
+	if err != nil {
+		s.Unlock()
+		return err
+	}
+	s.nextID++
+	s.sheets[f.id] = f
+	s.Unlock()
+	w.Send(func() {
+		if !c.addFrame(sl.Y, f) {
+			w.addFrame(f)
+		}
+		f.body.setWrap(!sl.NoWrap)
+	})
+
+	if sl.Tag != "" {
+		if _, err := f.tag.view.Do(edit.Change(edit.All, sl.Tag), edit.Set(edit.End, '.')); err != nil {
+			return err
+		}
+		if strings.HasPrefix(f.tagFileName(), "/sheet/") {
+			// The tag has the path of the dumped sheet.
+			f.setTagFileName("/sheet/" + f.id)
+		}
+	}
+	dot := edit.Clamp(edit.Rune(sl.Dot[0])).To(edit.Clamp(edit.Rune(sl.Dot[1])))
+	if !reused {
+		if name := f.filePath(); name != "" {
+			f.load(name, dot)
+		}
+		return nil
+	}
+	f.body.view.DoAsync(edit.Set(dot, '.'))
+	f.body.view.Warp(edit.Clamp(edit.Rune(sl.Scroll)))
+	return nil
+}
+
+func (s *Server) dumpHandler(w http.ResponseWriter, req *http.Request) {
+	layout, err := s.Dump()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, layout)
+}
+
+func (s *Server) loadHandler(w http.ResponseWriter, req *http.Request) {
+	var layout Layout
+	if err := json.NewDecoder(req.Body).Decode(&layout); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Load(layout); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor/view"
+)
+
+func TestDumpLoad(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	_, err := sheet0.body.doSync(edit.Change(edit.All, "hello\nworld\n"), edit.Set(edit.Rune(6).To(edit.Rune(11)), '.'))
+	if err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	// Wait for the view to see the new dot.
+	var dot [2]int64
+	for i := 0; i < 100 && dot != [2]int64{6, 11}; i++ {
+		time.Sleep(10 * time.Millisecond)
+		sheet0.body.view.View(func(_ []byte, marks []view.Mark) {
+			for _, m := range marks {
+				if m.Name == '.' {
+					dot = m.Where
+				}
+			}
+		})
+	}
+
+	w.Send(func() { sheet0.setWrap(false) })
+	wait(w)
+
+	layoutURL := urlWithPath(s.url, "/", "layout")
+	layout, err := Dump(layoutURL)
+	if err != nil {
+		t.Fatalf("Dump(%s)=_,%v", layoutURL, err)
+	}
+	if len(layout.Windows) != 1 {
+		t.Fatalf("len(layout.Windows)=%d, want 1", len(layout.Windows))
+	}
+	wl := layout.Windows[0]
+	if len(wl.Columns) != len(w.columns) {
+		t.Fatalf("len(wl.Columns)=%d, want %d", len(wl.Columns), len(w.columns))
+	}
+	sl := wl.Columns[0].Sheets[0]
+	if sl.BodyURL != sheet0.body.bufferURL.String() || sl.Dot != [2]int64{6, 11} {
+		t.Errorf("sheet0 layout=%+v, want BodyURL=%s, Dot=[6 11]", sl, sheet0.body.bufferURL)
+	}
+	if !sl.NoWrap || wl.Columns[0].Sheets[1].NoWrap {
+		t.Errorf("NoWrap=%v,%v, want true,false", sl.NoWrap, wl.Columns[0].Sheets[1].NoWrap)
+	}
+	if !strings.HasPrefix(sl.Tag, "/sheet/"+sheet0.id+" ") {
+		t.Errorf("sheet0 layout Tag=%q, want prefix %q", sl.Tag, "/sheet/"+sheet0.id+" ")
+	}
+
+	if err := Load(layoutURL, layout); err != nil {
+		t.Fatalf("Load(%s, …)=%v", layoutURL, err)
+	}
+	var got Layout
+	for i := 0; i < 100; i++ {
+		if got, err = Dump(layoutURL); err != nil {
+			t.Fatalf("Dump(%s)=_,%v", layoutURL, err)
+		}
+		if len(got.Windows) == 2 && reflect.DeepEqual(stripSheetPaths(got.Windows[1]), stripSheetPaths(wl)) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(got.Windows) != 2 {
+		t.Fatalf("len(got.Windows)=%d, want 2", len(got.Windows))
+	}
+	if g, want := stripSheetPaths(got.Windows[1]), stripSheetPaths(wl); !reflect.DeepEqual(g, want) {
+		t.Errorf("loaded layout=%+v, want %+v", g, want)
+	}
+}
+
+// StripSheetPaths returns a copy of the WindowLayout
+// with the sheet paths removed from the sheet tags,
+// since loaded sheets have new IDs.
+func stripSheetPaths(wl WindowLayout) WindowLayout {
+	var cols []ColumnLayout
+	for _, cl := range wl.Columns {
+		var sheets []SheetLayout
+		for _, sl := range cl.Sheets {
+			if strings.HasPrefix(sl.Tag, "/sheet/") {
+				if i := strings.Index(sl.Tag, " "); i >= 0 {
+					sl.Tag = sl.Tag[i:]
+				}
+			}
+			sheets = append(sheets, sl)
+		}
+		cl.Sheets = sheets
+		cols = append(cols, cl)
+	}
+	wl.Columns = cols
+	return wl
+}
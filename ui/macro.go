@@ -0,0 +1,253 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+// DefaultMacro is the name of the macro
+// recorded and played by Record and Play with no name.
+const defaultMacro = "macro"
+
+var errPlaying = errors.New("already playing a macro")
+
+// A recording is a macro being recorded in a window.
+type recording struct {
+	name  string
+	macro Macro
+	// Origin is the top-left corner of the frame in focus
+	// when recording began.
+	origin image.Point
+	// Last is the time of the last recorded event,
+	// or when recording began.
+	last time.Time
+	// Click is the number of mouse buttons held.
+	click int
+	// Gesture is the index in macro of the first event
+	// of a key press or of mouse button presses
+	// that are not yet released, or len(macro) if there is none.
+	// Events are recorded after they are handled,
+	// so if recording stops during a gesture,
+	// the gesture executed the command to stop,
+	// and its events are dropped.
+	gesture int
+}
+
+// SetMacro sets the named macro.
+// Macros are shared by all windows.
+func (s *Server) SetMacro(name string, m Macro) {
+	s.Lock()
+	s.macros[name] = append(Macro{}, m...)
+	s.Unlock()
+}
+
+// Macro returns the named macro, and whether it exists.
+func (s *Server) Macro(name string) (Macro, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	m, ok := s.macros[name]
+	return append(Macro{}, m...), ok
+}
+
+// FocusOrigin returns the top-left corner of the frame in focus,
+// or the origin if there is none.
+func (w *window) focusOrigin() image.Point {
+	if f, ok := w.inFocus.(frame); ok {
+		return f.bounds().Min
+	}
+	return image.ZP
+}
+
+// StartRecording begins recording the window's keyboard and mouse events
+// into the named macro.
+func (w *window) startRecording(name string) {
+	w.recording = &recording{
+		name:   name,
+		origin: w.focusOrigin(),
+		last:   w.server.now(),
+	}
+}
+
+// StopRecording stops recording and stores the recorded macro.
+func (w *window) stopRecording() {
+	r := w.recording
+	if r == nil {
+		return
+	}
+	w.recording = nil
+	w.server.SetMacro(r.name, r.macro[:r.gesture])
+}
+
+// Record records a handled keyboard or mouse event
+// to the macro being recorded, if any.
+// Events replayed from a macro are not recorded.
+func (w *window) record(e interface{}) {
+	r := w.recording
+	if r == nil || w.playing {
+		return
+	}
+	now := w.server.now()
+	me := MacroEvent{Delay: now.Sub(r.last)}
+	r.last = now
+	switch e := e.(type) {
+	case key.Event:
+		me.Key = &e
+	case mouse.Event:
+		switch e.Direction {
+		case mouse.DirPress:
+			r.click++
+		case mouse.DirRelease:
+			if r.click == 0 {
+				// The press was before recording began.
+				return
+			}
+			r.click--
+		}
+		e.X -= float32(r.origin.X)
+		e.Y -= float32(r.origin.Y)
+		me.Mouse = &e
+	default:
+		return
+	}
+	r.macro = append(r.macro, me)
+	if r.click == 0 && (me.Key == nil || me.Key.Direction == key.DirRelease) {
+		// The gesture is complete.
+		r.gesture = len(r.macro)
+	}
+}
+
+// Play replays a macro in the window,
+// with the mouse coordinates relative to the frame in focus.
+// Speed is the factor by which the replay is faster than recorded;
+// if it is 0 or less, the events are replayed without delay.
+// Done is called in the window's UI goroutine
+// once all of the events are handled.
+func (w *window) play(m Macro, speed float64, done func()) error {
+	if w.playing {
+		return errPlaying
+	}
+	w.playing = true
+	origin := w.focusOrigin()
+	go func() {
+		for _, e := range m {
+			if speed > 0 && e.Delay > 0 {
+				<-w.server.clock.After(time.Duration(float64(e.Delay) / speed))
+			}
+			switch {
+			case e.Key != nil:
+				w.Send(*e.Key)
+			case e.Mouse != nil:
+				me := *e.Mouse
+				me.X += float32(origin.X)
+				me.Y += float32(origin.Y)
+				w.Send(me)
+			}
+		}
+		w.Send(func() {
+			w.playing = false
+			done()
+		})
+	}()
+	return nil
+}
+
+// RecordCmd begins recording the named macro,
+// or the default macro if no name is given.
+// If a macro is already being recorded, it stops recording instead.
+func recordCmd(w *window, args string) {
+	if w.recording != nil {
+		w.stopRecording()
+		return
+	}
+	name := args
+	if name == "" {
+		name = defaultMacro
+	}
+	w.startRecording(name)
+}
+
+// PlayCmd replays the named macro, or the default macro,
+// at the speed given by the optional second argument,
+// or at the recorded speed.
+func playCmd(w *window, args string) {
+	name, speed := defaultMacro, 1.0
+	fs := strings.Fields(args)
+	if len(fs) > 0 {
+		name = fs[0]
+	}
+	if len(fs) > 1 {
+		var err error
+		if speed, err = strconv.ParseFloat(fs[1], 64); err != nil {
+			w.output(fmt.Sprintf("Play: bad speed %s\n", fs[1]))
+			return
+		}
+	}
+	m, ok := w.server.Macro(name)
+	if !ok {
+		w.output(fmt.Sprintf("Play: no macro %s\n", name))
+		return
+	}
+	if err := w.play(m, speed, func() {}); err != nil {
+		w.output(fmt.Sprintf("Play %s: %v\n", name, err))
+	}
+}
+
+func (s *Server) getMacroHandler(w http.ResponseWriter, req *http.Request) {
+	m, ok := s.Macro(mux.Vars(req)["name"])
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	respond(w, m)
+}
+
+func (s *Server) setMacroHandler(w http.ResponseWriter, req *http.Request) {
+	var m Macro
+	if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.SetMacro(mux.Vars(req)["name"], m)
+}
+
+func (s *Server) playHandler(w http.ResponseWriter, req *http.Request) {
+	var preq PlayRequest
+	if err := json.NewDecoder(req.Body).Decode(&preq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m, ok := s.Macro(preq.Name)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	errChan := make(chan error, 1)
+	win.Send(func() {
+		if err := win.play(m, preq.Speed, func() { errChan <- nil }); err != nil {
+			errChan <- err
+		}
+	})
+	s.RUnlock()
+	if err := <-errChan; err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestMacro_RecordPlay(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	mouseTo(w, image.Pt(sheet0.Min.X+sheet0.Dx()/2, sheet0.Max.Y-5))
+	wait(w)
+
+	w.Send(func() { recordCmd(w, "m") })
+	pressKey(w, 'a', key.CodeA)
+	pressKey(w, 'b', key.CodeB)
+	w.Send(func() { recordCmd(w, "") })
+	wait(w)
+	if got := waitText(sheet0.body, "ab"); got != "ab" {
+		t.Fatalf("body=%q, want %q", got, "ab")
+	}
+	m, ok := s.uiServer.Macro("m")
+	if !ok {
+		t.Fatalf("no macro m")
+	}
+	var got []key.Event
+	for _, e := range m {
+		if e.Key == nil || e.Mouse != nil {
+			t.Fatalf("macro event %+v, want a key event", e)
+		}
+		got = append(got, *e.Key)
+	}
+	want := []key.Event{
+		{Rune: 'a', Code: key.CodeA, Direction: key.DirPress},
+		{Rune: 'a', Code: key.CodeA, Direction: key.DirRelease},
+		{Rune: 'b', Code: key.CodeB, Direction: key.DirPress},
+		{Rune: 'b', Code: key.CodeB, Direction: key.DirRelease},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("macro=%v, want %v", got, want)
+	}
+
+	// WaitText set dot to the entire body; type at the end.
+	setTextDot(t, w, sheet0.body, "ab", edit.Span{2, 2})
+	done := make(chan struct{})
+	w.Send(func() {
+		if err := w.play(m, 0, func() { close(done) }); err != nil {
+			t.Errorf("play failed: %v", err)
+			close(done)
+		}
+	})
+	<-done
+	wait(w)
+	if got := waitText(sheet0.body, "abab"); got != "abab" {
+		t.Errorf("after Play, body=%q, want %q", got, "abab")
+	}
+}
+
+// TestMacro_StopGesture tests that the events of the gesture
+// that stops recording are not recorded.
+func TestMacro_StopGesture(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	p := image.Pt(sheet0.Min.X+5, sheet0.Min.Y+5)
+	mouseTo(w, p)
+	wait(w)
+
+	w.Send(func() { recordCmd(w, "") })
+	mouseTo(w, p.Add(image.Pt(1, 1)))
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonMiddle, Direction: mouse.DirPress})
+	w.Send(func() { recordCmd(w, "") })
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonMiddle, Direction: mouse.DirRelease})
+	wait(w)
+
+	m, ok := s.uiServer.Macro(defaultMacro)
+	if !ok {
+		t.Fatalf("no macro %s", defaultMacro)
+	}
+	if len(m) != 1 || m[0].Mouse == nil || m[0].Mouse.Direction != mouse.DirNone {
+		t.Fatalf("macro=%+v, want one mouse move", m)
+	}
+	// Mouse coordinates are relative to the frame in focus.
+	if x, y := m[0].Mouse.X, m[0].Mouse.Y; x != 6 || y != 6 {
+		t.Errorf("mouse move at %v,%v, want 6,6", x, y)
+	}
+}
+
+func TestMacro_HTTP(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, "", edit.Span{})
+
+	macroURL := urlWithPath(s.url, "/", "macro", "m")
+	if _, err := GetMacro(macroURL); err != ErrNotFound {
+		t.Fatalf("GetMacro(%s)=_,%v, want %v", macroURL, err, ErrNotFound)
+	}
+	// The mouse move focuses the body of sheet0,
+	// since the focus is the window tag when the macro is played.
+	m := Macro{
+		{Mouse: &mouse.Event{X: float32(sheet0.Min.X + 5), Y: float32(sheet0.Max.Y - 5)}},
+		{Delay: time.Millisecond, Key: &key.Event{Rune: 'x', Code: key.CodeX, Direction: key.DirPress}},
+		{Delay: time.Millisecond, Key: &key.Event{Rune: 'x', Code: key.CodeX, Direction: key.DirRelease}},
+	}
+	mouseTo(w, image.Pt(1, 1))
+	wait(w)
+	if err := SetMacro(macroURL, m); err != nil {
+		t.Fatalf("SetMacro(%s, …)=%v", macroURL, err)
+	}
+	got, err := GetMacro(macroURL)
+	if err != nil || !reflect.DeepEqual(got, m) {
+		t.Fatalf("GetMacro(%s)=%+v,%v, want %+v,nil", macroURL, got, err, m)
+	}
+
+	playURL := urlWithPath(s.url, "/", "window", w.id, "play")
+	if err := Play(playURL, "m", 1); err != nil {
+		t.Fatalf("Play(%s, m, 1)=%v", playURL, err)
+	}
+	if got := waitText(sheet0.body, "x"); got != "x" {
+		t.Errorf("after Play, body=%q, want %q", got, "x")
+	}
+	if err := Play(playURL, "nothing", 1); err != ErrNotFound {
+		t.Errorf("Play(%s, nothing, 1)=%v, want %v", playURL, err, ErrNotFound)
+	}
+}
@@ -1,12 +1,41 @@
 // Copyright © 2016, The T Authors.
 
+//go:build ignore
 // +build ignore
 
 // Main is demo program to try out the ui package.
+//
+// If a file is given as an argument,
+// the windows are restored from the layout in the file,
+// as written by the Dump command.
+//
+// The -theme flag gives a file with a JSON-encoded ui.Theme,
+// or "dark" for the built-in dark theme.
+//
+// The -autosave flag gives how long a sheet must be idle
+// before it is written to its file; 0 disables autosave.
+//
+// The -normalize-eol flag replaces \r\n line endings with \n
+// when files are loaded, restoring them when files are written.
+//
+// The -dict flag gives a file of correctly spelled words, one per line;
+// if it is given, the spelling of sheet bodies is checked.
+// The -userdict flag gives the file to which the Learn command adds words.
+//
+// The -placement flag chooses the column of new sheets:
+// last, focused, emptiest, or directory.
+// Output sheets are always placed in the last column.
+//
+// The -record flag gives a file to which the requests and responses
+// of the ui server and of the editor client, and the changes received,
+// are recorded; see the session package.
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"image"
+	"io/ioutil"
 	"net/http/httptest"
 	"net/url"
 	"os"
@@ -14,14 +43,30 @@ import (
 
 	"github.com/eaburns/T/editor"
 	"github.com/eaburns/T/editor/editortest"
+	"github.com/eaburns/T/session"
 	"github.com/eaburns/T/ui"
+	_ "github.com/eaburns/T/ui/syntax/golang"
 	"github.com/gorilla/mux"
 	"github.com/pkg/profile"
 	"golang.org/x/exp/shiny/driver"
 	"golang.org/x/exp/shiny/screen"
 )
 
-func main() { driver.Main(Main) }
+var (
+	theme        = flag.String("theme", "", "a JSON theme file, or dark")
+	autosave     = flag.Duration("autosave", 0, "how long a sheet must be idle before it is saved, or 0")
+	normalizeEOL = flag.Bool("normalize-eol", false, "whether to normalize \\r\\n line endings to \\n")
+	dict         = flag.String("dict", "", "a word list file used to check spelling")
+	userDict     = flag.String("userdict", "", "a word list file to which Learn adds words")
+	placement    = flag.String("placement", "last", "the column of new sheets: last, focused, emptiest, or directory")
+	record       = flag.String("record", "", "a file to which the session is recorded")
+	user         = flag.String("user", "", "the name shown to others editing the same buffers")
+)
+
+func main() {
+	flag.Parse()
+	driver.Main(Main)
+}
 
 // Main is the logical main function, called by the shiny driver.
 func Main(scr screen.Screen) {
@@ -32,17 +77,91 @@ func Main(scr screen.Screen) {
 
 	r := mux.NewRouter()
 	s := ui.NewServer(scr, es.PathURL("/"))
+	done := make(chan struct{}, 1)
 	s.SetDoneHandler(func() {
+		select {
+		case done <- struct{}{}:
+		default:
+		}
+	})
+	defer func() {
 		es.Close()
 		profiler.Stop()
-		os.Exit(0)
-	})
+	}()
+	switch *theme {
+	case "":
+	case "dark":
+		s.SetTheme(ui.DarkTheme())
+	default:
+		d, err := ioutil.ReadFile(*theme)
+		if err != nil {
+			panic(err)
+		}
+		th := ui.DefaultTheme()
+		if err := json.Unmarshal(d, &th); err != nil {
+			panic(err)
+		}
+		s.SetTheme(th)
+	}
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		rec := session.NewRecorder(f)
+		s.SetRecorder(rec)
+		editor.SetRecorder(rec)
+	}
+	s.SetUserName(*user)
+	s.SetAutosave(*autosave)
+	s.SetNormalizeEOL(*normalizeEOL)
+	switch *placement {
+	case "last":
+	case "focused":
+		s.SetPlacement(ui.PlaceOutput(ui.PlaceFocused()))
+	case "emptiest":
+		s.SetPlacement(ui.PlaceOutput(ui.PlaceEmptiest()))
+	case "directory":
+		s.SetPlacement(ui.PlaceOutput(ui.PlaceByDirectory(ui.PlaceEmptiest())))
+	default:
+		panic("unknown placement: " + *placement)
+	}
+	if *dict != "" {
+		d, err := ui.LoadWordList(*dict)
+		if err != nil {
+			panic(err)
+		}
+		user := ui.NewWordList()
+		if *userDict != "" {
+			if user, err = ui.LoadWordList(*userDict); err != nil {
+				panic(err)
+			}
+		}
+		s.SetSpelling(d, user)
+	}
 	s.RegisterHandlers(r)
 	baseURL, err := url.Parse(httptest.NewServer(r).URL)
 	if err != nil {
 		panic(err)
 	}
 
+	if flag.NArg() > 0 {
+		d, err := ioutil.ReadFile(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		var layout ui.Layout
+		if err := json.Unmarshal(d, &layout); err != nil {
+			panic(err)
+		}
+		if err := s.Load(layout); err != nil {
+			panic(err)
+		}
+		<-done
+		return
+	}
+
 	wins := *baseURL
 	wins.Path = path.Join("/", "windows")
 
@@ -81,5 +200,5 @@ func Main(scr screen.Screen) {
 		panic(err)
 	}
 
-	select {}
+	<-done
 }
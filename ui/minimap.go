@@ -0,0 +1,192 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor/view"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/paint"
+)
+
+// MinimapWidth is the width of a minimap in pixels at the default DPI.
+const minimapWidth = 80
+
+// A minimap is a miniature overview of the entire body of a sheet,
+// drawn in a strip on the right edge of the body.
+// It shows the density of the text,
+// the visible portion of the body, dot, and the highlighted text,
+// and clicking it scrolls the body.
+type minimap struct {
+	image.Rectangle
+	// Sep is the separator between the body and the minimap.
+	sep image.Rectangle
+
+	// View tracks the entire text of the body's buffer.
+	view *view.View
+	// Changed receives when the text changes or the minimap is resized.
+	changed chan struct{}
+
+	mu sync.Mutex
+	// Win is the window of the minimap's sheet, or nil if closed.
+	win *window
+	// Size is the size for which to compute the overview.
+	size image.Point
+	// TabWidth is the tab width of the body.
+	tabWidth int
+
+	// Overview is the overview of the text,
+	// accessed only in the UI goroutine.
+	overview *text.Overview
+}
+
+func newMinimap(s *sheet) (*minimap, error) {
+	v, err := view.New(s.body.bufferURL)
+	if err != nil {
+		return nil, err
+	}
+	m := &minimap{
+		view:     v,
+		changed:  make(chan struct{}, 1),
+		win:      s.win,
+		tabWidth: s.body.opts.TabWidth,
+	}
+	go func() {
+		for range v.Notify {
+			m.change()
+		}
+		close(m.changed)
+	}()
+	// Track the entire text; this also sends the first notification.
+	v.Resize(math.MaxInt32)
+	go m.run(s)
+	return m, nil
+}
+
+// Run recomputes the overview each time the text changes
+// or the minimap is resized, until the view is closed.
+func (m *minimap) run(s *sheet) {
+	for range m.changed {
+		m.mu.Lock()
+		size, tabWidth := m.size, m.tabWidth
+		m.mu.Unlock()
+		var o *text.Overview
+		m.view.View(func(b []byte, _ []view.Mark) {
+			o = text.NewOverview(b, tabWidth, size)
+		})
+		m.mu.Lock()
+		if m.win != nil {
+			m.win.Send(func() {
+				if s.minimap == m {
+					m.overview = o
+				}
+			})
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *minimap) change() {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (m *minimap) close() {
+	m.mu.Lock()
+	m.win = nil
+	m.mu.Unlock()
+	m.view.Close()
+}
+
+func (m *minimap) setWindow(w *window) {
+	m.mu.Lock()
+	m.win = w
+	m.mu.Unlock()
+}
+
+// SetBounds sets the bounds of the minimap,
+// recomputing the overview if the size changed.
+func (m *minimap) setBounds(b, sep image.Rectangle, tabWidth int) {
+	m.Rectangle, m.sep = b, sep
+	m.mu.Lock()
+	changed := m.size != b.Size() || m.tabWidth != tabWidth
+	m.size, m.tabWidth = b.Size(), tabWidth
+	m.mu.Unlock()
+	if changed {
+		m.change()
+	}
+}
+
+func (m *minimap) draw(s *sheet, win screen.Window) {
+	th := s.win.theme
+	win.Fill(m.sep, th.Separator, draw.Over)
+	win.Fill(m.Rectangle, th.BodyBG, draw.Src)
+	o := m.overview
+	if o == nil || o.Size() != m.Size() {
+		return
+	}
+	t := s.body
+	// Line0 is 1-based, but lines of the overview are 0-based.
+	l0 := int(t.line0 - 1)
+	vis := image.Rect(m.Min.X, m.Min.Y+o.RowOf(l0), m.Max.X, m.Min.Y+o.RowOf(l0+len(t.lineYs)))
+	win.Fill(vis.Intersect(m.Rectangle), th.ScrollBG, draw.Src)
+	for _, h := range t.highlights {
+		m.fillLines(o, h, th.Highlight, win)
+	}
+	o.Draw(m.Min, th.GutterFG, win)
+	m.fillLines(o, edit.Span{t.dot0, t.dot1}, th.Cursor, win)
+}
+
+// FillLines fills the rows of the lines of a span of the text.
+func (m *minimap) fillLines(o *text.Overview, sp edit.Span, c color.Color, win screen.Window) {
+	y0 := o.RowOf(o.LineOf(sp[0]))
+	y1 := o.RowOf(o.LineOf(sp[1])) + 1
+	win.Fill(image.Rect(m.Min.X, m.Min.Y+y0, m.Max.X, m.Min.Y+y1), c, draw.Src)
+}
+
+// Mouse scrolls the body so that the line clicked in the minimap
+// is near the middle of the body.
+func (m *minimap) mouse(s *sheet, event mouse.Event) {
+	o := m.overview
+	if o == nil || event.Direction != mouse.DirPress || event.Button != mouse.ButtonLeft {
+		return
+	}
+	line := o.LineAt(int(event.Y) - m.Min.Y)
+	h := s.body.opts.DefaultStyle.Face.Metrics().Height.Round()
+	n := 0
+	if h > 0 {
+		n = s.body.opts.Size.Y / h / 2
+	}
+	s.body.showDot = false
+	s.body.view.Warp(edit.Rune(o.LineStart(line)).Minus(edit.Clamp(edit.Line(n))))
+}
+
+// ToggleMinimap shows or hides the minimap of the sheet's body.
+func (s *sheet) toggleMinimap() {
+	if s.minimap != nil {
+		s.minimap.close()
+		s.minimap = nil
+	} else {
+		m, err := newMinimap(s)
+		if err != nil {
+			s.errorf("Minimap: %v", err)
+			return
+		}
+		s.minimap = m
+	}
+	s.body.mu.Lock()
+	s.body.reset = true
+	s.body.mu.Unlock()
+	s.updateText()
+	s.win.Send(paint.Event{})
+}
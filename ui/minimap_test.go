@@ -0,0 +1,84 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestMinimap(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, strings.Repeat("line\n", 1000), edit.Span{0, 0})
+
+	var width int
+	w.Send(func() {
+		width = sheet0.body.opts.Size.X
+		sheet0.tag.exec("Minimap")
+	})
+	wait(w)
+
+	var m *minimap
+	var lines int
+	for i := 0; i < 100 && lines != 1000; i++ {
+		w.Send(func() {
+			m = sheet0.minimap
+			if m != nil && m.overview != nil && m.overview.Size() == m.Size() {
+				lines = m.overview.NumLines()
+			}
+		})
+		wait(w)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m == nil {
+		t.Fatalf("no minimap after Minimap")
+	}
+	if lines != 1000 {
+		t.Fatalf("minimap lines=%d, want 1000", lines)
+	}
+	var bodyWidth int
+	var bounds image.Rectangle
+	w.Send(func() {
+		bodyWidth = sheet0.body.opts.Size.X
+		bounds = m.Rectangle
+	})
+	wait(w)
+	if bodyWidth >= width {
+		t.Errorf("body width with minimap=%d, want < %d", bodyWidth, width)
+	}
+	if bounds.Max.X != sheet0.Max.X || bounds.Empty() {
+		t.Errorf("minimap bounds=%v, want non-empty at the right of %v", bounds, sheet0.Rectangle)
+	}
+
+	// Clicking the bottom of the minimap scrolls to the end of the body.
+	p := image.Pt(bounds.Min.X+bounds.Dx()/2, bounds.Max.Y-10)
+	mouseTo(w, p)
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	wait(w)
+	var line0 int64
+	for i := 0; i < 100 && line0 < 500; i++ {
+		line0 = sheet0.body.view.Line()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if line0 < 500 {
+		t.Errorf("after click, first line=%d, want ≥500", line0)
+	}
+
+	w.Send(func() {
+		sheet0.tag.exec("Minimap")
+		m = sheet0.minimap
+		bodyWidth = sheet0.body.opts.Size.X
+	})
+	wait(w)
+	if m != nil || bodyWidth != width {
+		t.Errorf("after second Minimap, minimap=%v, body width=%d, want nil, %d", m, bodyWidth, width)
+	}
+}
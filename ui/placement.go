@@ -0,0 +1,165 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"path"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+)
+
+// A Placement chooses the column of each new sheet.
+//
+// Sheets opened below another sheet, for example by plumbing,
+// are added below that sheet, regardless of the Placement.
+//
+// Placements are called in the UI goroutine,
+// so they must not block.
+type Placement interface {
+	// Place returns the index of the column,
+	// counting from 0 at the left,
+	// in which to add a new sheet with the given tag file name.
+	// If the index is out of range,
+	// the sheet is added to the last column.
+	Place(cols []PlacementColumn, name string) int
+}
+
+// A PlacementColumn describes a column of a window to a Placement.
+type PlacementColumn struct {
+	// Sheets are the tag file names of the column's sheets,
+	// from top to bottom.
+	Sheets []string
+	// Focus is whether the frame in focus is in the column.
+	Focus bool
+}
+
+// A PlacementFunc is a Placement implemented by a function.
+type PlacementFunc func(cols []PlacementColumn, name string) int
+
+// Place returns f(cols, name).
+func (f PlacementFunc) Place(cols []PlacementColumn, name string) int { return f(cols, name) }
+
+// SetPlacement sets the Placement that chooses the column of new sheets.
+// By default, the Placement is PlaceLast.
+func (s *Server) SetPlacement(p Placement) {
+	s.Lock()
+	s.placement = p
+	s.Unlock()
+}
+
+// PlaceLast returns a Placement that adds sheets to the rightmost column.
+func PlaceLast() Placement {
+	return PlacementFunc(func(cols []PlacementColumn, _ string) int {
+		return len(cols) - 1
+	})
+}
+
+// PlaceFocused returns a Placement that adds sheets
+// to the column of the frame in focus.
+// If no frame has focus, sheets are added to the rightmost column.
+func PlaceFocused() Placement {
+	return PlacementFunc(func(cols []PlacementColumn, _ string) int {
+		for i, c := range cols {
+			if c.Focus {
+				return i
+			}
+		}
+		return len(cols) - 1
+	})
+}
+
+// PlaceEmptiest returns a Placement that adds sheets
+// to the column with the fewest sheets.
+// Ties go to the leftmost column.
+func PlaceEmptiest() Placement {
+	return PlacementFunc(func(cols []PlacementColumn, _ string) int {
+		min := 0
+		for i, c := range cols {
+			if len(c.Sheets) < len(cols[min].Sheets) {
+				min = i
+			}
+		}
+		return min
+	})
+}
+
+// PlaceByDirectory returns a Placement that groups sheets by directory.
+// A sheet is added to the column with the most sheets
+// whose file names are in the same directory as its own.
+// Ties go to the leftmost column.
+// If no column has such a sheet, the other Placement chooses.
+func PlaceByDirectory(other Placement) Placement {
+	return PlacementFunc(func(cols []PlacementColumn, name string) int {
+		if name == "" {
+			return other.Place(cols, name)
+		}
+		dir := path.Dir(name)
+		best, max := -1, 0
+		for i, c := range cols {
+			var n int
+			for _, s := range c.Sheets {
+				if s != "" && path.Dir(s) == dir {
+					n++
+				}
+			}
+			if n > max {
+				best, max = i, n
+			}
+		}
+		if best < 0 {
+			return other.Place(cols, name)
+		}
+		return best
+	})
+}
+
+// PlaceOutput returns a Placement that adds output sheets,
+// those whose names begin with +, such as +output and +search,
+// to the rightmost column.
+// The other Placement chooses the column of all other sheets.
+func PlaceOutput(other Placement) Placement {
+	return PlacementFunc(func(cols []PlacementColumn, name string) int {
+		if strings.HasPrefix(name, "+") {
+			return len(cols) - 1
+		}
+		return other.Place(cols, name)
+	})
+}
+
+// PlaceSheet adds a new sheet to the window.
+// If col is the index of a column, the sheet is added to that column.
+// Otherwise, the server's Placement chooses the column.
+func (w *window) placeSheet(s *sheet, col int) {
+	if col < 0 || col >= len(w.columns) {
+		w.server.RLock()
+		p := w.server.placement
+		w.server.RUnlock()
+		// The tag of a new sheet may have pending edits,
+		// so its name is read from the editor, not the view.
+		name := s.viewFileName()
+		if res, err := s.tag.doSync(edit.Print(tagFileAddr)); err == nil && res[0].Error == "" {
+			name = res[0].Print
+		}
+		col = p.Place(w.placementColumns(), name)
+	}
+	if col < 0 || col >= len(w.columns) {
+		col = len(w.columns) - 1
+	}
+	w.addFrameTo(w.columns[col], s)
+}
+
+func (w *window) placementColumns() []PlacementColumn {
+	cols := make([]PlacementColumn, len(w.columns))
+	for i, c := range w.columns {
+		for _, f := range c.frames {
+			if h, ok := f.(handler); ok && h == w.inFocus {
+				cols[i].Focus = true
+			}
+			if s, ok := f.(*sheet); ok {
+				cols[i].Sheets = append(cols[i].Sheets, s.viewFileName())
+			}
+		}
+	}
+	return cols
+}
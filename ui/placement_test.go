@@ -0,0 +1,126 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import "testing"
+
+func TestPlacement(t *testing.T) {
+	cols := []PlacementColumn{
+		{Sheets: []string{"/a/x.go", "/b/y.go"}},
+		{Sheets: []string{"/b/z.go", "/b/w.go", "+output"}, Focus: true},
+		{Sheets: []string{"/c/v.go"}},
+		{Sheets: []string{"/sheet/3", "/c/u.go"}},
+	}
+	tests := []struct {
+		placement string
+		p         Placement
+		name      string
+		want      int
+	}{
+		{placement: "last", p: PlaceLast(), name: "/a/t.go", want: 3},
+		{placement: "focused", p: PlaceFocused(), name: "/a/t.go", want: 1},
+		{placement: "emptiest", p: PlaceEmptiest(), name: "/a/t.go", want: 2},
+		{placement: "directory", p: PlaceByDirectory(PlaceLast()), name: "/a/t.go", want: 0},
+		{placement: "directory", p: PlaceByDirectory(PlaceLast()), name: "/b/t.go", want: 1},
+		// Ties go to the leftmost column.
+		{placement: "directory", p: PlaceByDirectory(PlaceLast()), name: "/c/t.go", want: 2},
+		{placement: "directory", p: PlaceByDirectory(PlaceEmptiest()), name: "/d/t.go", want: 2},
+		{placement: "directory", p: PlaceByDirectory(PlaceLast()), name: "", want: 3},
+		{placement: "output", p: PlaceOutput(PlaceFocused()), name: "+search", want: 3},
+		{placement: "output", p: PlaceOutput(PlaceFocused()), name: "/a/t.go", want: 1},
+	}
+	for _, test := range tests {
+		if got := test.p.Place(cols, test.name); got != test.want {
+			t.Errorf("%s placement of %q=%d, want %d",
+				test.placement, test.name, got, test.want)
+		}
+	}
+
+	// With no focus, PlaceFocused uses the last column.
+	cols[1].Focus = false
+	if got := PlaceFocused().Place(cols, ""); got != 3 {
+		t.Errorf("focused placement with no focus=%d, want 3", got)
+	}
+}
+
+func TestNewSheetInColumn(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	winPath := windowPath(w)
+	sheetsURL := urlWithPath(s.url, winPath, "sheets")
+	editorURL := s.editorServer.PathURL("/")
+	for _, col := range []int{0, 1, 2, 0} {
+		sheet, err := NewSheetInColumn(sheetsURL, editorURL, col)
+		if err != nil {
+			t.Fatalf("NewSheetInColumn(%q, %q, %d)=%v,%v, want _,nil",
+				sheetsURL, editorURL, col, sheet, err)
+		}
+		if got := sheetColumn(w, sheet.ID); got != col {
+			t.Errorf("NewSheetInColumn(%q, %q, %d) added to column %d",
+				sheetsURL, editorURL, col, got)
+		}
+	}
+
+	// An out of range column falls back to the server's Placement.
+	s.uiServer.SetPlacement(PlaceEmptiest())
+	for _, col := range []int{-1, 3} {
+		sheet, err := NewSheetInColumn(sheetsURL, editorURL, col)
+		if err != nil {
+			t.Fatalf("NewSheetInColumn(%q, %q, %d)=%v,%v, want _,nil",
+				sheetsURL, editorURL, col, sheet, err)
+		}
+		// Column 0 has 4 sheets, column 1 has 3, and column 2 has 3.
+		want := 1
+		if col == 3 {
+			want = 2
+		}
+		if got := sheetColumn(w, sheet.ID); got != want {
+			t.Errorf("NewSheetInColumn(%q, %q, %d) added to column %d, want %d",
+				sheetsURL, editorURL, col, got, want)
+		}
+	}
+}
+
+func TestSetPlacement(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	s.uiServer.SetPlacement(PlaceOutput(PlaceFocused()))
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	mouseTo(w, center(sheet0))
+	wait(w)
+
+	sheetsURL := urlWithPath(s.url, windowPath(w), "sheets")
+	h, err := NewSheet(sheetsURL, s.editorServer.PathURL("/"))
+	if err != nil {
+		t.Fatalf("NewSheet(%q, _)=%v,%v, want _,nil", sheetsURL, h, err)
+	}
+	if got := sheetColumn(w, h.ID); got != 0 {
+		t.Errorf("new sheet added to column %d, want 0", got)
+	}
+
+	var out *sheet
+	w.Send(func() { out = w.output("hello\n") })
+	wait(w)
+	if got, want := sheetColumn(w, out.id), len(w.columns)-1; got != want {
+		t.Errorf("output sheet added to column %d, want %d", got, want)
+	}
+}
+
+// SheetColumn returns the index of the column
+// containing the sheet with the given ID, or -1.
+func sheetColumn(w *window, id string) int {
+	col := -1
+	w.Send(func() {
+		for i, c := range w.columns {
+			for _, f := range c.frames {
+				if s, ok := f.(*sheet); ok && s.id == id {
+					col = i
+				}
+			}
+		}
+	})
+	wait(w)
+	return col
+}
@@ -0,0 +1,193 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+)
+
+// A PlumbRule plumbs the text looked at by a button 3 click.
+//
+// Dir is the directory of the file named in the clicked sheet's tag,
+// or the current directory if the tag does not name a file.
+// If the rule applies to the text,
+// it returns the path of a file to open in a new sheet,
+// the address to select in the file, and true.
+// Otherwise it returns false.
+type PlumbRule func(dir, text string) (path string, addr edit.Address, ok bool)
+
+// DefaultPlumbRules returns the default plumbing rules.
+// Text that is not plumbed by a rule
+// is searched for in the clicked sheet's body.
+func DefaultPlumbRules() []PlumbRule {
+	return []PlumbRule{FileRule}
+}
+
+// FileRule is a PlumbRule that opens existing files.
+// The text is a file path,
+// relative to dir if it is not absolute,
+// optionally followed by a colon and an address,
+// for example, main.go:12.
+// Without an address, the beginning of the file is selected.
+func FileRule(dir, text string) (string, edit.Address, bool) {
+	name, addr, ok := splitAddress(text)
+	if !ok {
+		return "", nil, false
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	if fi, err := os.Stat(name); err != nil || fi.IsDir() {
+		return "", nil, false
+	}
+	return name, addr, true
+}
+
+// SplitAddress returns the name and address of text
+// of the form name or name:address,
+// optionally followed by a colon.
+// Without an address, the address is the beginning of the file.
+func splitAddress(text string) (string, edit.Address, bool) {
+	name := strings.TrimSuffix(text, ":")
+	var addr edit.Address = edit.Rune(0)
+	if i := strings.Index(name, ":"); i >= 0 {
+		r := strings.NewReader(name[i+1:])
+		a, err := edit.Addr(r)
+		if err != nil || a == nil || r.Len() != 0 {
+			return "", nil, false
+		}
+		name, addr = name[:i], a
+	}
+	if name == "" {
+		return "", nil, false
+	}
+	return name, addr, true
+}
+
+// Plumb plumbs text looked at by a button 3 click in the sheet.
+// If the text names a sheet of the window,
+// optionally followed by a colon and an address,
+// the address is selected and shown in that sheet.
+// A sheet is named by the file name in its tag,
+// or by its path if the tag does not name a file.
+// Otherwise, the text is given to each of the server's plumbing rules in turn.
+// If a rule applies, its file is opened in a new sheet
+// below this one.
+// Otherwise, the next occurrence of the text in the body is selected.
+func (s *sheet) plumb(text string) {
+	if text == "" {
+		return
+	}
+	if f, addr, ok := s.win.namedSheet(text); ok {
+		f.body.doAsync(edit.Set(addr, '.'))
+		f.body.view.Warp(dot)
+		return
+	}
+	dir := filepath.Dir(s.filePath())
+	if dir == "." {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			s.win.logf("failed to get the working directory: %v", err)
+		}
+	}
+	s.win.server.RLock()
+	rules := s.win.server.plumbing
+	s.win.server.RUnlock()
+	for _, rule := range rules {
+		if name, addr, ok := rule(dir, text); ok {
+			s.open(name, addr)
+			return
+		}
+	}
+	look(s, text)
+}
+
+// NamedSheet returns the sheet of the window named by text
+// of the form name or name:address, and the address.
+func (w *window) namedSheet(text string) (*sheet, edit.Address, bool) {
+	name, addr, ok := splitAddress(text)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, c := range w.columns {
+		for _, f := range c.frames[1:] {
+			if s, ok := f.(*sheet); ok && s.tagFileName() == name {
+				return s, addr, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// Open opens a file in a new sheet below this one,
+// selecting the given address.
+func (s *sheet) open(name string, addr edit.Address) {
+	w := s.win
+	w.server.Lock()
+	f, err := w.server.newSheet(w, w.server.editorURL, s)
+	w.server.Unlock()
+	if err != nil {
+		s.errorf("failed to open %s: %v", name, err)
+		return
+	}
+	f.setTagFileName(name)
+	f.load(name, addr)
+}
+
+// ReadText returns the text of the named file and its line ending style.
+// If the server normalizes line endings, they are normalized to LF.
+// It may be called from any goroutine.
+func (w *window) readText(name string) (string, edit.EOL, error) {
+	d, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", edit.LF, err
+	}
+	text := string(d)
+	w.server.RLock()
+	normalize := w.server.normalizeEOL
+	w.server.RUnlock()
+	if !normalize {
+		return text, edit.LF, nil
+	}
+	// DetectEOL cannot fail reading a string.
+	eol, _ := edit.DetectEOL(strings.NewReader(text))
+	return edit.NormalizeEOL(text), eol, nil
+}
+
+// Load replaces the body with the contents of the named file,
+// marks the body unmodified, and selects and shows the given address.
+// The file is read in a new goroutine;
+// errors are written to the window's output sheet.
+func (s *sheet) load(name string, addr edit.Address) {
+	w := s.win
+	go func() {
+		text, eol, err := w.readText(name)
+		if err == nil {
+			_, err = s.body.view.Do(edit.Change(edit.All, text), edit.Set(edit.Rune(0), '.'))
+		}
+		var res []editor.EditResult
+		if err == nil {
+			res, err = s.body.view.Do(edit.Set(addr, '.'))
+			if err == nil && res[0].Error != "" {
+				err = fmt.Errorf("%s", res[0].Error)
+			}
+		}
+		if err == nil {
+			s.body.view.Warp(dot)
+			err = setUnmodified(s, res[0].Sequence)
+		}
+		if err != nil {
+			w.Send(func() { s.errorf("Get %s: %v", name, err) })
+			return
+		}
+		w.Send(func() { s.eol = eol })
+		setFileFromDisk(w, s, name)
+	}()
+}
@@ -0,0 +1,117 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestFileRule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "T_plumb_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file.go")
+	if err := ioutil.WriteFile(file, nil, 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(…)=%v", err)
+	}
+
+	tests := []struct {
+		text string
+		path string
+		addr edit.Address
+	}{
+		{text: "file.go", path: file, addr: edit.Rune(0)},
+		{text: "file.go:", path: file, addr: edit.Rune(0)},
+		{text: "file.go:12", path: file, addr: edit.Line(12)},
+		{text: file + ":#5", path: file, addr: edit.Rune(5)},
+		{text: "file.go:12:", path: file, addr: edit.Line(12)},
+		{text: "file.go:12:5", path: file, addr: edit.Line(12).Plus(edit.Col(5))},
+		{text: "file.go:)"},
+		{text: "nofile.go"},
+		{text: "."},
+		{text: ""},
+	}
+	for _, test := range tests {
+		path, addr, ok := FileRule(dir, test.text)
+		if test.path == "" {
+			if ok {
+				t.Errorf("FileRule(%q, %q)=%q,%v,true, want false", dir, test.text, path, addr)
+			}
+			continue
+		}
+		if !ok || path != test.path || addr.String() != test.addr.String() {
+			t.Errorf("FileRule(%q, %q)=%q,%v,%t, want %q,%v,true",
+				dir, test.text, path, addr, ok, test.path, test.addr)
+		}
+	}
+}
+
+func TestPlumb(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	dir, err := ioutil.TempDir("", "T_plumb_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("line 1\nline 2\n"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(…)=%v", err)
+	}
+
+	// Text not matching a rule is searched for in the body,
+	// after the looked-at text, which is selected by the click.
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "abc abc"), edit.Set(edit.Rune(0).To(edit.Rune(3)), '.')); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	w.Send(func() { sheet0.plumb("abc") })
+	wait(w)
+	res, err := sheet0.body.doSync(edit.Where(edit.Dot))
+	if err != nil || res[0].Error != "" || strings.TrimSpace(res[0].Print) != "#4,#7" {
+		t.Errorf("after plumb dot=%v,%v, want #4,#7", res, err)
+	}
+
+	// A file is opened below the sheet, in its column.
+	nFrames := len(w.columns[0].frames)
+	w.Send(func() { sheet0.plumb(file + ":2") })
+	wait(w)
+	var opened *sheet
+	for i := 0; i < 100 && opened == nil; i++ {
+		w.Send(func() {
+			if fs := w.columns[0].frames; len(fs) > nFrames {
+				opened, _ = fs[2].(*sheet)
+			}
+		})
+		wait(w)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if opened == nil {
+		t.Fatalf("no sheet opened below sheet0")
+	}
+	var text string
+	for i := 0; i < 100 && text != "line 2\n"; i++ {
+		res, err = opened.body.doSync(edit.Print(edit.Dot))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("opened.body.doSync(Print(.))=%v,%v", res, err)
+		}
+		text = res[0].Print
+		time.Sleep(10 * time.Millisecond)
+	}
+	if text != "line 2\n" {
+		t.Errorf("opened dot=%q, want %q", text, "line 2\n")
+	}
+	if name := opened.tagFileName(); name != file {
+		t.Errorf("opened tag file name=%q, want %q", name, file)
+	}
+}
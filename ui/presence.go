@@ -0,0 +1,76 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+)
+
+// DrawOthers draws the dots of the other labeled editors of the buffer.
+// Each dot is drawn in the color of its editor's label
+// as a caret at each end and a line under the text between them.
+// The label is drawn in a box to the right of the caret at the beginning.
+func (t *textBox) drawOthers(pt image.Point, scr screen.Screen, win screen.Window) {
+	box := image.Rectangle{Min: pt, Max: pt.Add(t.opts.Size)}
+	width := scalePx(cursorWidth, t.scale)
+	for _, p := range t.others {
+		c := t.theme.collaboratorColor(p.Label)
+		for i := p.Dot[0]; i < p.Dot[1]; i++ {
+			if i < t.l0 {
+				i = t.l0
+			}
+			r, ok := t.caretBox(pt, i)
+			if !ok {
+				break
+			}
+			r.Max.X = t.text.GlyphBox(int(i - t.l0)).Add(pt).Max.X
+			r.Min.Y = r.Max.Y - width
+			win.Fill(r.Intersect(box), c, draw.Src)
+		}
+		if r, ok := t.caretBox(pt, p.Dot[1]); ok && p.Dot[1] != p.Dot[0] {
+			win.Fill(r.Intersect(box), c, draw.Src)
+		}
+		if r, ok := t.caretBox(pt, p.Dot[0]); ok {
+			win.Fill(r.Intersect(box), c, draw.Src)
+			t.drawLabel(p.Label, r, box, scr, win)
+		}
+	}
+}
+
+// DrawLabel draws a label in a box the height of a line,
+// to the right of the caret rectangle and above it,
+// or below it if there is no room above,
+// clipped to the bounds of the text box.
+func (t *textBox) drawLabel(label string, caret, box image.Rectangle, scr screen.Screen, win screen.Window) {
+	face := t.opts.DefaultStyle.Face
+	h := face.Metrics().Height.Ceil()
+	pad := t.opts.Padding
+	size := image.Pt(font.MeasureString(face, label).Ceil()+2*pad, h+2*pad)
+	p := image.Pt(caret.Max.X, caret.Min.Y-size.Y)
+	if p.Y < box.Min.Y {
+		p.Y = caret.Max.Y
+	}
+	r := image.Rectangle{Min: p, Max: p.Add(size)}.Intersect(box)
+	if r.Empty() || r.Min != p {
+		return
+	}
+	setter := text.NewSetter(text.Options{
+		DefaultStyle: text.Style{
+			Face: face,
+			FG:   t.theme.BodyBG,
+			BG:   t.theme.collaboratorColor(label),
+		},
+		Size:    r.Size(),
+		Padding: pad,
+	})
+	defer setter.Release()
+	setter.Add([]byte(label))
+	txt := setter.Set()
+	txt.Draw(r.Min, scr, win)
+	txt.Release()
+}
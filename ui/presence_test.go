@@ -0,0 +1,100 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/ui/headless"
+)
+
+func TestDrawOthers(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	hw := w.Window.(*headless.Window)
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "Hello, World")); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+
+	bufferURL := *sheet0.body.bufferURL
+	ed, err := editor.NewLabeledEditor(&bufferURL, "you")
+	if err != nil {
+		t.Fatalf("editor.NewLabeledEditor(%s, \"you\")=_,%v", &bufferURL, err)
+	}
+	textURL := bufferURL
+	textURL.Path = path.Join(ed.Path, "text")
+	if _, err := editor.Do(&textURL, edit.Set(edit.Regexp("World"), '.')); err != nil {
+		t.Fatalf("editor.Do(%s, …)=_,%v", &textURL, err)
+	}
+
+	want := color.RGBAModel.Convert(w.theme.collaboratorColor("you"))
+	var got color.Color
+	for i := 0; i < 100; i++ {
+		var others []editor.Presence
+		var caret image.Rectangle
+		w.Send(func() {
+			others = sheet0.body.others
+			caret, _ = sheet0.body.caretBox(sheet0.body.topLeft, 7)
+		})
+		wait(w)
+		if len(others) == 1 && others[0].Label == "you" && others[0].Dot == (edit.Span{7, 12}) {
+			got = color.RGBAModel.Convert(hw.Frame().Image.At(caret.Min.X, caret.Min.Y))
+			if got == want {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got != want {
+		t.Errorf("color of the other caret=%v, want %v", got, want)
+	}
+
+	editorURL := bufferURL
+	editorURL.Path = ed.Path
+	if err := editor.Close(&editorURL); err != nil {
+		t.Fatalf("editor.Close(%s)=%v", &editorURL, err)
+	}
+	var n int
+	for i := 0; i < 100; i++ {
+		w.Send(func() { n = len(sheet0.body.others) })
+		wait(w)
+		if n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n != 0 {
+		t.Errorf("after close, len(others)=%d, want 0", n)
+	}
+}
+
+func TestSetUserName(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	s.uiServer.SetUserName("me")
+
+	sheetsURL := urlWithPath(s.url, "/", "window", w.id, "sheets")
+	sh, err := NewSheet(sheetsURL, s.editorServer.PathURL("/"))
+	if err != nil {
+		t.Fatalf("NewSheet(%s, …)=_,%v", sheetsURL, err)
+	}
+	bodyURL, err := url.Parse(sh.BodyURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q)=_,%v", sh.BodyURL, err)
+	}
+	buf, err := editor.BufferInfo(bodyURL)
+	if err != nil {
+		t.Fatalf("editor.BufferInfo(%s)=_,%v", bodyURL, err)
+	}
+	if len(buf.Editors) != 1 || buf.Editors[0].Label != "me" {
+		t.Errorf("body editors=%v, want one with Label me", buf.Editors)
+	}
+}
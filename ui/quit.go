@@ -0,0 +1,131 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/eaburns/T/editor"
+)
+
+// Quit closes all windows, waits for them and their sheets to close,
+// and calls the done handler.
+//
+// If the body of a sheet has changes not yet written to the file named in its tag,
+// Quit does not close any windows unless force is true.
+// Either way, the QuitResult lists the modified sheets.
+//
+// Quit makes blocking RPCs, so it must not be called in a UI goroutine.
+func (s *Server) Quit(force bool) (QuitResult, error) {
+	mod, err := s.modifiedSheets()
+	if err != nil && !force {
+		return QuitResult{}, err
+	}
+	var res QuitResult
+	s.RLock()
+	for _, h := range mod {
+		if _, ok := s.sheets[h.id]; ok {
+			res.Modified = append(res.Modified, makeSheet(h))
+		}
+	}
+	s.RUnlock()
+	if len(mod) > 0 && !force {
+		return res, nil
+	}
+	s.shutdown()
+	res.Quit = true
+	return res, nil
+}
+
+// ModifiedSheets returns the sheets whose bodies have changes
+// not yet written to the files named in their tags,
+// sorted by file name.
+// It makes blocking RPCs, so it must not be called in a UI goroutine.
+func (s *Server) modifiedSheets() ([]*sheet, error) {
+	s.RLock()
+	var sheets []*sheet
+	for _, h := range s.sheets {
+		sheets = append(sheets, h)
+	}
+	s.RUnlock()
+
+	var mod []*sheet
+	for _, h := range sheets {
+		if !namesFile(h.viewFileName()) {
+			continue
+		}
+		buf, err := editor.BufferInfo(h.body.bufferURL)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Modified {
+			mod = append(mod, h)
+		}
+	}
+	sort.Slice(mod, func(i, j int) bool {
+		return mod[i].viewFileName() < mod[j].viewFileName()
+	})
+	return mod, nil
+}
+
+// Shutdown closes all windows, waits for them and their sheets to close,
+// and calls the done handler.
+// Closing the sheets closes their connections to the editor server.
+// It must not be called in a UI goroutine.
+func (s *Server) shutdown() {
+	s.Lock()
+	var wins []*window
+	for _, w := range s.windows {
+		wins = append(wins, w)
+		s.removeWindow(w)
+		w.close()
+	}
+	done := s.done
+	s.Unlock()
+	for _, w := range wins {
+		<-w.closed
+	}
+	done()
+}
+
+// ExitCmd is the Exit command.
+// It quits unless a sheet is modified and the argument is not -f.
+func exitCmd(w *window, args string) {
+	force := args == "-f"
+	s := w.server
+	go func() {
+		mod, err := s.modifiedSheets()
+		switch {
+		case force:
+		case err != nil:
+			w.Send(func() { w.output("Exit: " + err.Error() + "\n") })
+			return
+		case len(mod) > 0:
+			var names []string
+			for _, h := range mod {
+				names = append(names, h.viewFileName())
+			}
+			msg := "Exit: modified: " + strings.Join(names, " ") + "\nUse Exit -f to discard the changes.\n"
+			w.Send(func() { w.output(msg) })
+			return
+		}
+		s.shutdown()
+	}()
+}
+
+func (s *Server) quitHandler(w http.ResponseWriter, req *http.Request) {
+	var qreq QuitRequest
+	if err := json.NewDecoder(req.Body).Decode(&qreq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.Quit(qreq.Force)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, res)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestQuit(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() { sheet0.setTagFileName("/tmp/quit.txt") })
+	for i := 0; i < 100 && sheet0.viewFileName() != "/tmp/quit.txt"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "modified")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+
+	quitURL := urlWithPath(s.url, "/", "quit")
+	res, err := Quit(quitURL, false)
+	if err != nil {
+		t.Fatalf("Quit(%q, false)=_,%v, want _,nil", quitURL, err)
+	}
+	if res.Quit || len(res.Modified) != 1 || res.Modified[0].ID != sheet0.id {
+		t.Errorf("Quit(%q, false)=%+v, want Quit=false, Modified=[sheet %s]", quitURL, res, sheet0.id)
+	}
+	if s.done {
+		t.Errorf("done handler called after a refused Quit")
+	}
+	if wins, err := WindowList(urlWithPath(s.url, "/", "windows")); err != nil || len(wins) != 1 {
+		t.Errorf("WindowList(…)=%v,%v, want 1 window", wins, err)
+	}
+
+	res, err = Quit(quitURL, true)
+	if err != nil {
+		t.Fatalf("Quit(%q, true)=_,%v, want _,nil", quitURL, err)
+	}
+	if !res.Quit || len(res.Modified) != 1 {
+		t.Errorf("Quit(%q, true)=%+v, want Quit=true, 1 modified sheet", quitURL, res)
+	}
+	if !s.done {
+		t.Errorf("done handler not called after a forced Quit")
+	}
+	select {
+	case <-w.closed:
+	default:
+		t.Errorf("window not closed after Quit")
+	}
+	if sheets, err := SheetList(urlWithPath(s.url, "/", "sheets")); err != nil || len(sheets) != 0 {
+		t.Errorf("SheetList(…)=%v,%v, want [],nil", sheets, err)
+	}
+}
+
+func TestExitModified(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() { sheet0.setTagFileName("/tmp/exit.txt") })
+	for i := 0; i < 100 && sheet0.viewFileName() != "/tmp/exit.txt"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "modified")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+
+	w.Send(func() { w.tag.text.exec("Exit") })
+	var out *sheet
+	for i := 0; i < 100 && out == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() {
+			s.uiServer.RLock()
+			for _, h := range s.uiServer.sheets {
+				if h.viewFileName() == "+output" {
+					out = h
+				}
+			}
+			s.uiServer.RUnlock()
+		})
+		wait(w)
+	}
+	if out == nil {
+		t.Fatalf("no +output sheet after Exit")
+	}
+	var got string
+	for i := 0; i < 100 && !strings.Contains(got, "/tmp/exit.txt"); i++ {
+		time.Sleep(10 * time.Millisecond)
+		res, err := out.body.doSync(edit.Print(edit.All))
+		if err != nil {
+			t.Fatalf("doSync(…)=_,%v", err)
+		}
+		got = res[0].Print
+	}
+	if !strings.Contains(got, "modified: /tmp/exit.txt") {
+		t.Errorf("+output=%q, want it to report /tmp/exit.txt", got)
+	}
+	if s.done {
+		t.Errorf("done handler called after Exit with a modified sheet")
+	}
+}
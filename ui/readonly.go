@@ -0,0 +1,147 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eaburns/T/edit"
+	"github.com/gorilla/mux"
+	"golang.org/x/mobile/event/paint"
+)
+
+// ErrReadOnly is returned when attempting to modify
+// the body of a read-only sheet.
+var errReadOnly = errors.New("read-only sheet")
+
+// SetReadOnly sets whether the body rejects changes to its text
+// made by typing, mouse chords, and the sheet's commands,
+// and redraws the sheet.
+// Changes made by other clients of the body's buffer are not rejected.
+// It must be called in the UI goroutine of the sheet's window.
+func (s *sheet) setReadOnly(readOnly bool) {
+	s.body.readOnly = readOnly
+	s.win.Send(paint.Event{})
+}
+
+// Modifies returns whether any of the edits may change the text of a buffer.
+func modifies(eds []edit.Edit) bool {
+	for _, e := range eds {
+		rs := strings.NewReader(e.String())
+		if !readOnlyEdit(rs) || rs.Len() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadOnlyEdit returns whether the edit read from rs,
+// in the syntax of edit.Ed, cannot change the text of a buffer.
+// Only the runes of the edit are read from rs
+// if it cannot change the text.
+//
+// Edits that set marks, print, or pipe to a command
+// cannot change the text, nor can loops and blocks of such edits.
+func readOnlyEdit(rs io.RuneScanner) bool {
+	if _, err := edit.Addr(rs); err != nil {
+		return false
+	}
+	r, _, err := rs.ReadRune()
+	switch {
+	case err == io.EOF:
+		return true
+	case err != nil:
+		return false
+	case r == '\n':
+		return rs.UnreadRune() == nil
+	case r == 'p' || r == 'E':
+		return true
+	case r == 'k':
+		_, _, err := rs.ReadRune()
+		return err == nil
+	case r == '=':
+		if r, _, err := rs.ReadRune(); err == nil && r != '#' {
+			return rs.UnreadRune() == nil
+		}
+		return true
+	case r == '>':
+		for {
+			if r, _, err := rs.ReadRune(); err == io.EOF || r == '\n' {
+				return true
+			} else if err != nil {
+				return false
+			}
+		}
+	case r == 'x' || r == 'y':
+		if d, _, err := rs.ReadRune(); err != nil || d != '/' {
+			return false
+		}
+		for esc := false; ; {
+			r, _, err := rs.ReadRune()
+			if err != nil {
+				return false
+			}
+			if r == '/' && !esc {
+				break
+			}
+			esc = !esc && r == '\\'
+		}
+		return readOnlyEdit(rs)
+	case r == '{':
+		for {
+			r, _, err := rs.ReadRune()
+			switch {
+			case err == io.EOF || r == '}':
+				return true
+			case err != nil:
+				return false
+			case r == '\n':
+				continue
+			}
+			if rs.UnreadRune() != nil || !readOnlyEdit(rs) {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) getReadOnlyHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	result := make(chan ReadOnlyState)
+	f.win.Send(func() { result <- ReadOnlyState{ReadOnly: f.body.readOnly} })
+	s.RUnlock()
+	respond(w, <-result)
+}
+
+func (s *Server) setReadOnlyHandler(w http.ResponseWriter, req *http.Request) {
+	var st ReadOnlyState
+	if err := json.NewDecoder(req.Body).Decode(&st); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	f.win.Send(func() {
+		f.setReadOnly(st.ReadOnly)
+		close(done)
+	})
+	s.RUnlock()
+	<-done
+}
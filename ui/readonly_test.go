@@ -0,0 +1,108 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+)
+
+func TestModifies(t *testing.T) {
+	tests := []struct {
+		ed   string
+		want bool
+	}{
+		{ed: "", want: false},
+		{ed: "#3", want: false},
+		{ed: "/abc/k s", want: false},
+		{ed: ",p", want: false},
+		{ed: "$=", want: false},
+		{ed: "$=#", want: false},
+		{ed: "1,/abc/E", want: false},
+		{ed: "> cat", want: false},
+		{ed: ",x/a\\/b/p", want: false},
+		{ed: ",x/abc/x/b/=#", want: false},
+		{ed: "{\np\n=#\n> wc\n}", want: false},
+
+		{ed: "c/abc/", want: true},
+		{ed: "a/abc/", want: true},
+		{ed: "i/abc/", want: true},
+		{ed: "d", want: true},
+		{ed: "m$", want: true},
+		{ed: "t$", want: true},
+		{ed: ",s/a/b/g", want: true},
+		{ed: "| sort", want: true},
+		{ed: "< date", want: true},
+		{ed: "u", want: true},
+		{ed: "r3", want: true},
+		{ed: ",x/abc/d", want: true},
+		{ed: "{\np\nd\n}", want: true},
+	}
+	for _, test := range tests {
+		e, err := edit.Ed(strings.NewReader(test.ed))
+		if err != nil {
+			t.Fatalf("edit.Ed(%q)=_,%v", test.ed, err)
+		}
+		if got := modifies([]edit.Edit{e}); got != test.want {
+			t.Errorf("modifies(%q)=%v, want %v", e, got, test.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "abc"), edit.Set(edit.End, '.')); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	mouseTo(w, center(sheet0))
+	wait(w)
+
+	readOnlyURL := urlWithPath(s.url, "/", "sheet", sheet0.id, "readonly")
+	if ro, err := GetReadOnly(readOnlyURL); err != nil || ro {
+		t.Fatalf("GetReadOnly(%s)=%v,%v, want false,nil", readOnlyURL, ro, err)
+	}
+	if err := SetReadOnly(readOnlyURL, true); err != nil {
+		t.Fatalf("SetReadOnly(%s, true)=%v", readOnlyURL, err)
+	}
+	if ro, err := GetReadOnly(readOnlyURL); err != nil || !ro {
+		t.Fatalf("GetReadOnly(%s)=%v,%v, want true,nil", readOnlyURL, ro, err)
+	}
+
+	w.Send(key.Event{Rune: 'x', Direction: key.DirPress})
+	w.Send(func() {
+		sheet0.tag.exec("Undo")
+		sheet0.tag.exec("Edit ,d")
+		sheet0.tag.exec("Edit ,p")
+	})
+	wait(w)
+
+	// Toggle off read-only and type,
+	// so that a change eventually shows in the body.
+	w.Send(func() { sheet0.tag.exec("ReadOnly") })
+	if ro, err := GetReadOnly(readOnlyURL); err != nil || ro {
+		t.Fatalf("after ReadOnly, GetReadOnly(%s)=%v,%v, want false,nil", readOnlyURL, ro, err)
+	}
+	w.Send(key.Event{Rune: 'y', Direction: key.DirPress})
+	var text string
+	for i := 0; i < 100 && text != "abcy"; i++ {
+		res, err := sheet0.body.view.Do(edit.Print(edit.All), edit.Set(edit.End, '.'))
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("sheet0.body.view.Do(Print(All))=%v,%v", res, err)
+		}
+		text = res[0].Print
+		time.Sleep(10 * time.Millisecond)
+	}
+	if text != "abcy" {
+		t.Errorf("body=%q, want %q", text, "abcy")
+	}
+
+	if err := SetReadOnly(urlWithPath(s.url, "/", "sheet", "nope", "readonly"), true); err != ErrNotFound {
+		t.Errorf("SetReadOnly(nope)=%v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/editor/view"
+	"github.com/gorilla/mux"
+)
+
+// MaxSearchMatches is the maximum number of matches
+// reported by a search for each sheet.
+const maxSearchMatches = 1000
+
+const searchSheetName = "+search"
+
+func (s *Server) searchHandler(w http.ResponseWriter, req *http.Request) {
+	var sreq SearchRequest
+	if err := json.NewDecoder(req.Body).Decode(&sreq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkSearchRegexp(sreq.Regexp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	type result struct {
+		SearchResult
+		err error
+	}
+	resChan := make(chan result)
+	win.Send(func() {
+		win.search(sreq.Regexp, func(f *sheet, ms []SearchMatch, err error) {
+			var res result
+			if res.err = err; err == nil {
+				s.RLock()
+				res.Sheet = makeSheet(f)
+				s.RUnlock()
+				res.Matches = ms
+			}
+			resChan <- res
+		})
+	})
+	s.RUnlock()
+	res := <-resChan
+	if res.err != nil {
+		// TODO(eaburns): this may be an http error, propogate it.
+		http.Error(w, res.err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, res.SearchResult)
+}
+
+// CheckSearchRegexp returns an error if the regular expression
+// is empty or malformed.
+func checkSearchRegexp(re string) error {
+	if re == "" {
+		return errors.New("no regexp")
+	}
+	_, err := edit.Addr(strings.NewReader("/" + edit.Escape(re, '/') + "/"))
+	return err
+}
+
+// Search searches the bodies of the window's sheets
+// for matches of a regular expression,
+// and writes the matches to a new +search sheet,
+// one per line, in the form name:line: text.
+// Each line can be plumbed to show its match.
+// Special sheets, such as +output, are not searched.
+//
+// Search must be called in the window's UI goroutine.
+// The sheets are searched concurrently in other goroutines.
+// When all searches finish, done is called in the window's UI goroutine
+// with the new sheet and the matches, or with an error.
+func (w *window) search(re string, done func(*sheet, []SearchMatch, error)) {
+	if err := checkSearchRegexp(re); err != nil {
+		done(nil, nil, err)
+		return
+	}
+	var sheets []*sheet
+	var names []string
+	for _, c := range w.columns {
+		for _, f := range c.frames[1:] {
+			s, ok := f.(*sheet)
+			if !ok {
+				continue
+			}
+			if name := s.tagFileName(); !strings.HasPrefix(name, "+") {
+				sheets = append(sheets, s)
+				names = append(names, name)
+			}
+		}
+	}
+	go func() {
+		matches := make([][]SearchMatch, len(sheets))
+		errs := make([]error, len(sheets))
+		var wg sync.WaitGroup
+		for i := range sheets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				matches[i], errs[i] = searchBody(sheets[i], names[i], re)
+			}(i)
+		}
+		wg.Wait()
+
+		var all []SearchMatch
+		for i := range sheets {
+			if errs[i] != nil {
+				w.Send(func() { done(nil, nil, fmt.Errorf("%s: %v", names[i], errs[i])) })
+				return
+			}
+			all = append(all, matches[i]...)
+		}
+		var text bytes.Buffer
+		for _, m := range all {
+			fmt.Fprintf(&text, "%s:%d: %s\n", m.Name, m.Line, m.Text)
+		}
+		w.Send(func() {
+			w.server.Lock()
+			f, err := w.server.newSheet(w, w.server.editorURL, nil)
+			w.server.Unlock()
+			if err != nil {
+				done(nil, nil, err)
+				return
+			}
+			f.setTagFileName(searchSheetName)
+			f.body.doAsync(edit.Change(edit.All, text.String()), edit.Set(edit.Rune(0), '.'))
+			w.refocus()
+			done(f, all, nil)
+		})
+	}()
+}
+
+// SearchBody returns the matches of a regular expression
+// in the body of a sheet with the given name.
+func searchBody(s *sheet, name, re string) ([]SearchMatch, error) {
+	URL := *s.body.bufferURL
+	URL.Path = path.Join(URL.Path, "search")
+	spans, err := editor.Search(&URL, re, 0, maxSearchMatches)
+	if err != nil || len(spans) == 0 {
+		return nil, err
+	}
+	// Printing sets dot; save it in the View's temporary mark and restore it.
+	// The temporary mark is not otherwise used by the View until after these edits.
+	res, err := s.body.view.Do(edit.Set(dot, view.TmpMark), edit.Print(edit.All), edit.Set(edit.Mark(view.TmpMark), '.'))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res {
+		if r.Error != "" {
+			return nil, errors.New(r.Error)
+		}
+	}
+	text := []rune(res[1].Print)
+
+	// Starts are the rune offsets of the beginning of each line.
+	starts := []int64{0}
+	for i, r := range text {
+		if r == '\n' {
+			starts = append(starts, int64(i+1))
+		}
+	}
+	var ms []SearchMatch
+	for _, sp := range spans {
+		if sp[0] > int64(len(text)) {
+			// The body changed since the search.
+			break
+		}
+		l := sort.Search(len(starts), func(i int) bool { return starts[i] > sp[0] }) - 1
+		end := int64(len(text))
+		if l+1 < len(starts) {
+			end = starts[l+1] - 1
+		}
+		ms = append(ms, SearchMatch{
+			SheetID: s.id,
+			Name:    name,
+			Line:    l + 1,
+			Span:    sp,
+			Text:    string(text[starts[l]:end]),
+		})
+	}
+	return ms, nil
+}
@@ -0,0 +1,88 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestSearch(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	sheet1 := w.columns[0].frames[2].(*sheet)
+
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "abc\nxyz\nabcabc\n"), edit.Set(edit.Rune(1), 's'), edit.Set(edit.Rune(4), '.')); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	if _, err := sheet1.body.view.Do(edit.Change(edit.All, "xyz\nabc")); err != nil {
+		t.Fatalf("sheet1.body.view.Do(…)=_,%v", err)
+	}
+
+	searchURL := urlWithPath(s.url, "/", "window", w.id, "search")
+	if _, err := Search(searchURL, "("); err == nil {
+		t.Errorf("Search(%s, \"(\")=_,nil, want error", searchURL)
+	}
+
+	res, err := Search(searchURL, "abc")
+	if err != nil {
+		t.Fatalf("Search(%s, abc)=_,%v", searchURL, err)
+	}
+	name0 := "/sheet/" + sheet0.id
+	name1 := "/sheet/" + sheet1.id
+	want := []SearchMatch{
+		{SheetID: sheet0.id, Name: name0, Line: 1, Span: edit.Span{0, 3}, Text: "abc"},
+		{SheetID: sheet0.id, Name: name0, Line: 3, Span: edit.Span{8, 11}, Text: "abcabc"},
+		{SheetID: sheet0.id, Name: name0, Line: 3, Span: edit.Span{11, 14}, Text: "abcabc"},
+		{SheetID: sheet1.id, Name: name1, Line: 2, Span: edit.Span{4, 7}, Text: "abc"},
+	}
+	if !reflect.DeepEqual(res.Matches, want) {
+		t.Errorf("Search(%s, abc).Matches=%+v, want %+v", searchURL, res.Matches, want)
+	}
+	// Searching doesn't move dot or other marks.
+	if r, err := sheet0.body.view.Do(edit.Where(edit.Dot)); err != nil || strings.TrimSpace(r[0].Print) != "#4" {
+		t.Errorf("after Search, sheet0 dot=%v,%v, want #4", r, err)
+	}
+	if r, err := sheet0.body.view.Do(edit.Where(edit.Mark('s'))); err != nil || strings.TrimSpace(r[0].Print) != "#1" {
+		t.Errorf("after Search, sheet0 mark s=%v,%v, want #1", r, err)
+	}
+
+	s.uiServer.RLock()
+	out := s.uiServer.sheets[res.Sheet.ID]
+	s.uiServer.RUnlock()
+	var name string
+	w.Send(func() { name = out.tagFileName() })
+	wait(w)
+	if name != searchSheetName {
+		t.Errorf("search sheet name=%q, want %q", name, searchSheetName)
+	}
+	wantText := name0 + ":1: abc\n" +
+		name0 + ":3: abcabc\n" +
+		name0 + ":3: abcabc\n" +
+		name1 + ":2: abc\n"
+	var text string
+	for i := 0; i < 100 && text != wantText; i++ {
+		r, err := out.body.view.Do(edit.Print(edit.All))
+		if err != nil || r[0].Error != "" {
+			t.Fatalf("out.body.view.Do(Print(All))=%v,%v", r, err)
+		}
+		text = r[0].Print
+		time.Sleep(10 * time.Millisecond)
+	}
+	if text != wantText {
+		t.Errorf("search sheet text=%q, want %q", text, wantText)
+	}
+
+	// Plumbing a match selects it in its sheet.
+	w.Send(func() { out.plumb(name1 + ":2:") })
+	wait(w)
+	r, err := sheet1.body.view.Do(edit.Print(edit.Dot))
+	if err != nil || r[0].Error != "" || r[0].Print != "abc" {
+		t.Errorf("after plumbing, sheet1 dot=%v,%v, want abc", r, err)
+	}
+}
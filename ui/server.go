@@ -10,20 +10,53 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/eaburns/T/session"
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/shiny/screen"
 )
 
 // Server is a T user interface server
 type Server struct {
-	screen    screen.Screen
-	editorURL *url.URL
-	windows   map[string]*window
-	sheets    map[string]*sheet
-	nextID    int
-	done      func()
+	screen     screen.Screen
+	editorURL  *url.URL
+	windows    map[string]*window
+	sheets     map[string]*sheet
+	nextID     int
+	done       func()
+	keymap     Keymap
+	plumbing   []PlumbRule
+	annotators []Annotator
+	completers []Completer
+	autosave   time.Duration
+	clock      Clock
+	placement  Placement
+	theme      *Theme
+	blink      bool
+	// NormalizeEOL is whether \r\n line endings
+	// are replaced by \n when a file is loaded.
+	normalizeEOL bool
+	// Dict and userDict are the dictionaries
+	// used to check spelling, or nil.
+	dict     Dictionary
+	userDict *WordList
+	// Snarf is the snarf buffer, shared by all windows.
+	snarf string
+	// Macros are the recorded macros, keyed by name.
+	macros map[string]Macro
+	// Transit is a sheet dragged out of its window,
+	// and transitTime is the time that it was dragged out.
+	// The next window that the mouse enters
+	// before transitTimeout elapses takes the sheet.
+	transit     *sheet
+	transitTime time.Time
+	// Recorder records the requests served and their responses, or is nil.
+	recorder *session.Recorder
+	// UserName is the Label of the editors of sheet bodies.
+	userName string
 	sync.RWMutex
 }
 
@@ -32,16 +65,79 @@ type Server struct {
 // Column and sheet tags use buffers created on this editor server.
 func NewServer(scr screen.Screen, editorURL *url.URL) *Server {
 	editorURL.Path = "/"
+	theme := DefaultTheme()
 	return &Server{
-		screen:    scr,
-		editorURL: editorURL,
-		windows:   make(map[string]*window),
-		sheets:    make(map[string]*sheet),
-		done:      func() {},
+		screen:     scr,
+		editorURL:  editorURL,
+		windows:    make(map[string]*window),
+		sheets:     make(map[string]*sheet),
+		done:       func() {},
+		keymap:     DefaultKeymap(),
+		plumbing:   DefaultPlumbRules(),
+		completers: defaultCompleters(),
+		macros:     make(map[string]Macro),
+		clock:      systemClock{},
+		placement:  PlaceLast(),
+		theme:      &theme,
+		blink:      true,
 	}
 }
 
-// SetDoneHandler sets the function which is called if the last window is closed.
+// SetKeymap sets the key bindings used by all tags and bodies.
+// By default, the key bindings are those of DefaultKeymap.
+func (s *Server) SetKeymap(km Keymap) {
+	bindings := make(Keymap, len(km))
+	for k, v := range km {
+		bindings[k] = v
+	}
+	s.Lock()
+	s.keymap = bindings
+	s.Unlock()
+}
+
+// SetPlumbRules sets the rules used to plumb text
+// looked at by button 3 clicks in sheets.
+// By default, the rules are those of DefaultPlumbRules.
+func (s *Server) SetPlumbRules(rules []PlumbRule) {
+	s.Lock()
+	s.plumbing = append([]PlumbRule{}, rules...)
+	s.Unlock()
+}
+
+// SetCursorBlink sets whether the cursor blinks.
+// If not, the cursor of the text box in focus is drawn solid.
+// By default, the cursor blinks.
+func (s *Server) SetCursorBlink(blink bool) {
+	s.Lock()
+	s.blink = blink
+	s.Unlock()
+}
+
+// SetNormalizeEOL sets whether \r\n line endings are replaced by \n
+// when a file is loaded into the body of a sheet.
+// If so, the file's dominant line ending style is restored
+// when the body is written back to a file.
+// By default, line endings are not normalized.
+func (s *Server) SetNormalizeEOL(normalize bool) {
+	s.Lock()
+	s.normalizeEOL = normalize
+	s.Unlock()
+}
+
+// SetUserName sets the name shown to other clients
+// editing the same buffers as the sheets opened after it is called.
+// Each sheet body shows the carets of the other labeled editors of its buffer,
+// labeled with their names.
+// By default, the name is empty,
+// and other clients do not show the carets of the server's sheets.
+func (s *Server) SetUserName(name string) {
+	s.Lock()
+	s.userName = name
+	s.Unlock()
+}
+
+// SetDoneHandler sets the function which is called if the last window is closed
+// or the server quits.
 // By default, the done handler is a no-op.
 func (s *Server) SetDoneHandler(f func()) {
 	s.Lock()
@@ -49,6 +145,33 @@ func (s *Server) SetDoneHandler(f func()) {
 	s.Unlock()
 }
 
+// SetRecorder sets the Recorder of the requests served by the server's handlers
+// and their responses.
+// If r is nil, nothing is recorded, which is the default.
+//
+// The server's requests to the editor server, and the changes it receives,
+// are recorded by the editor package's Recorder; see editor.SetRecorder.
+func (s *Server) SetRecorder(r *session.Recorder) {
+	s.Lock()
+	s.recorder = r
+	s.Unlock()
+}
+
+// recorded returns a handler that calls f,
+// recording the request and response if the server has a Recorder.
+func (s *Server) recorded(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.RLock()
+		r := s.recorder
+		s.RUnlock()
+		if r == nil {
+			f(w, req)
+			return
+		}
+		r.Handler(f).ServeHTTP(w, req)
+	}
+}
+
 // Close closes all windows.
 // The server should not be used after calling Close.
 func (s *Server) Close() error {
@@ -98,16 +221,106 @@ func (s *Server) Close() error {
 // 	• Not Found if the window is not found.
 // 	• Bad Request if the WindowRequest is malformed.
 //
+//  /window/<ID>/layout is the geometry of the window's columns and sheets.
+//
+// 	GET returns the window's Geometry.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+//
+// 	PUT changes the window's geometry and returns its new Geometry.
+// 	The body must be a SetGeometryRequest.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+// 	• Bad Request if the SetGeometryRequest is malformed
+// 	  or does not fit the window.
+//
+//  /window/<ID>/drop is the drop target of the window.
+//  Shiny drivers do not deliver drop events from the OS;
+//  the window system glue can forward them here.
+//
+// 	PUT opens each dropped file in a new sheet
+// 	in the column under the drop point
+// 	and returns a Sheet list of the new sheets.
+// 	The body must be a DropRequest.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the window is not found.
+// 	• Bad Request if the DropRequest is malformed.
+//
+//  /window/<ID>/env is the execution environment
+//  of commands executed from the window.
+//
+// 	GET returns the window's ExecEnv.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+//
+// 	PUT sets the window's ExecEnv.
+// 	The body must be an ExecEnv.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+// 	• Bad Request if the ExecEnv is malformed.
+//
+//  /window/<ID>/search is the search of the bodies of the window's sheets.
+//
+// 	PUT searches for the regular expression of a SearchRequest,
+// 	writes the matches to a new +search sheet,
+// 	and returns a SearchResult.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the window is not found.
+// 	• Bad Request if the SearchRequest or its regular expression is malformed.
+//
+//  /window/<ID>/image is an image of the window's contents.
+//
+// 	GET draws the window off-screen and returns it as a PNG image.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the window is not found.
+//
 //  /window/<ID>/sheets is the list of the window's sheets.
 //
-// 	PUT adds a sheet to the left-most column of the window
-// 	and returns its Sheet.
+// 	PUT adds a sheet to the window and returns its Sheet.
+// 	The sheet is added to the column of the NewSheetRequest,
+// 	or, if it has none, to the column chosen by the server's Placement.
 // 	Returns:
 // 	• OK on success.
 // 	• Internal Server Error on internal error
 // 	  or if a new sheet cannot fit in the column.
 // 	• Not Found if the window is not found.
 //
+//  /window/<ID>/play replays macros in the window.
+//
+// 	PUT replays the macro of a PlayRequest in the window,
+// 	with its mouse coordinates relative to the frame in focus.
+// 	It returns once all of the macro's events are handled.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window or the macro is not found.
+// 	• Bad Request if the PlayRequest is malformed.
+// 	• Conflict if a macro is already being replayed in the window.
+//
+//  /window/<ID>/font is the font of the window's sheets.
+//
+// 	GET returns the window's Font.
+// 	Sheets that set their own Font override its set fields.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+//
+// 	PUT sets the window's Font and lays out the window again.
+// 	The body must be a Font.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the window is not found.
+// 	• Bad Request if the Font is malformed or names an unknown face.
+//
 //  /sheets is the list of opened sheets.
 //
 // 	GET returns a Sheet list of the opened sheets.
@@ -123,15 +336,145 @@ func (s *Server) Close() error {
 // 	• Internal Server Error on internal error.
 // 	• Not Found if the sheet is not found.
 //
+//  /sheet/<ID>/window is the window of the sheet with the given ID.
+//
+// 	PUT moves the sheet to a window and returns its Sheet.
+// 	The body must be a MoveSheetRequest.
+// 	The sheet keeps its tag and body buffers.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Not Found if the sheet or the window is not found.
+// 	• Bad Request if the MoveSheetRequest is malformed.
+//
+//  /sheet/<ID>/readonly is whether the body of the sheet is read-only.
+//
+// 	GET returns the sheet's ReadOnlyState.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+//
+// 	PUT sets the sheet's ReadOnlyState.
+// 	The body must be a ReadOnlyState.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+// 	• Bad Request if the ReadOnlyState is malformed.
+//
+//  /sheet/<ID>/wrap is whether the body of the sheet soft wraps long lines.
+//
+// 	GET returns the sheet's WrapState.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+//
+// 	PUT sets the sheet's WrapState.
+// 	The body must be a WrapState.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+// 	• Bad Request if the WrapState is malformed.
+//
+//  /sheet/<ID>/font is the font of the sheet's tag and body.
+//
+// 	GET returns the sheet's Font.
+// 	Unset fields are inherited from the window's Font.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+//
+// 	PUT sets the sheet's Font and lays out its column again.
+// 	The body must be a Font.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the sheet is not found.
+// 	• Bad Request if the Font is malformed or names an unknown face.
+//
+//  /layout is the layout of the windows, columns, and sheets.
+//
+// 	GET returns the Layout of the opened windows.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+//
+// 	PUT opens new windows with the layout given by the body.
+// 	The body must be a Layout.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Bad Request if the Layout is malformed.
+//
+//  /macro/<name> is the macro with the given name.
+//
+// 	GET returns the Macro.
+// 	Returns:
+// 	• OK on success.
+// 	• Not Found if the macro is not found.
+//
+// 	PUT sets the macro to the Macro of the body.
+// 	Returns:
+// 	• OK on success.
+// 	• Bad Request if the Macro is malformed.
+//
+//  /theme is the color theme of the windows.
+//
+// 	GET returns the Theme.
+// 	Returns:
+// 	• OK on success.
+//
+// 	PUT sets the Theme and redraws the windows with its colors.
+// 	The body must be a Theme.
+// 	Returns:
+// 	• OK on success.
+// 	• Bad Request if the Theme is malformed.
+//
+//  /quit is the server's shutdown.
+//
+// 	PUT closes all windows and their sheets,
+// 	calls the done handler, and returns a QuitResult.
+// 	The body must be a QuitRequest.
+// 	If a sheet has changes not written to its file
+// 	and the QuitRequest does not Force the quit,
+// 	the server does not quit;
+// 	the modified sheets are listed in the QuitResult either way.
+// 	Returns:
+// 	• OK on success.
+// 	• Internal Server Error on internal error.
+// 	• Bad Request if the QuitRequest is malformed.
+//
 // Unless otherwise stated, the body of all error responses is the error message.
 func (s *Server) RegisterHandlers(r *mux.Router) {
-	r.HandleFunc("/windows", s.listWindowsHandler).Methods(http.MethodGet)
-	r.HandleFunc("/windows", s.newWindowHandler).Methods(http.MethodPut)
-	r.HandleFunc("/window/{id}", s.deleteWindowHandler).Methods(http.MethodDelete)
-	r.HandleFunc("/window/{id}/columns", s.newColumnHandler).Methods(http.MethodPut)
-	r.HandleFunc("/window/{id}/sheets", s.newSheetHandler).Methods(http.MethodPut)
-	r.HandleFunc("/sheets", s.listSheetsHandler).Methods(http.MethodGet)
-	r.HandleFunc("/sheet/{id}", s.deleteSheetHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/windows", s.recorded(s.listWindowsHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/windows", s.recorded(s.newWindowHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}", s.recorded(s.deleteWindowHandler)).Methods(http.MethodDelete)
+	r.HandleFunc("/window/{id}/columns", s.recorded(s.newColumnHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/layout", s.recorded(s.getGeometryHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/window/{id}/layout", s.recorded(s.setGeometryHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/sheets", s.recorded(s.newSheetHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/drop", s.recorded(s.dropHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/env", s.recorded(s.getExecEnvHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/window/{id}/env", s.recorded(s.setExecEnvHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/search", s.recorded(s.searchHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/image", s.recorded(s.windowImageHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/window/{id}/play", s.recorded(s.playHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/window/{id}/font", s.recorded(s.getWindowFontHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/window/{id}/font", s.recorded(s.setWindowFontHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/sheets", s.recorded(s.listSheetsHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/sheet/{id}", s.recorded(s.deleteSheetHandler)).Methods(http.MethodDelete)
+	r.HandleFunc("/sheet/{id}/window", s.recorded(s.moveSheetHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/sheet/{id}/readonly", s.recorded(s.getReadOnlyHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/sheet/{id}/readonly", s.recorded(s.setReadOnlyHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/sheet/{id}/wrap", s.recorded(s.getWrapHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/sheet/{id}/wrap", s.recorded(s.setWrapHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/sheet/{id}/font", s.recorded(s.getSheetFontHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/sheet/{id}/font", s.recorded(s.setSheetFontHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/layout", s.recorded(s.dumpHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/layout", s.recorded(s.loadHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/macro/{name}", s.recorded(s.getMacroHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/macro/{name}", s.recorded(s.setMacroHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/theme", s.recorded(s.getThemeHandler)).Methods(http.MethodGet)
+	r.HandleFunc("/theme", s.recorded(s.setThemeHandler)).Methods(http.MethodPut)
+	r.HandleFunc("/quit", s.recorded(s.quitHandler)).Methods(http.MethodPut)
 }
 
 // respond JSON encodes resp to w, and sends an Internal Server Error on failure.
@@ -199,7 +542,7 @@ func (s *Server) delWin(winID string) bool {
 	if !ok {
 		return false
 	}
-	delete(s.windows, w.id)
+	s.removeWindow(w)
 	w.close()
 	if len(s.windows) == 0 {
 		s.done()
@@ -207,6 +550,17 @@ func (s *Server) delWin(winID string) bool {
 	return true
 }
 
+// RemoveWindow removes the window and its sheets from the server.
+// It must be called with the server lock held.
+func (s *Server) removeWindow(w *window) {
+	delete(s.windows, w.id)
+	for id, h := range s.sheets {
+		if h.win == w {
+			delete(s.sheets, id)
+		}
+	}
+}
+
 func (s *Server) newColumnHandler(w http.ResponseWriter, req *http.Request) {
 	var creq NewColumnRequest
 	if err := json.NewDecoder(req.Body).Decode(&creq); err != nil {
@@ -256,7 +610,11 @@ func (s *Server) newSheetHandler(w http.ResponseWriter, req *http.Request) {
 		http.NotFound(w, req)
 		return
 	}
-	f, err := s.newSheet(win, URL)
+	col := -1
+	if sreq.Column != nil {
+		col = *sreq.Column
+	}
+	f, err := s.newSheetInColumn(win, URL, col)
 	if err != nil {
 		s.Unlock()
 		// TODO(eaburns): This may be an http response error.
@@ -271,16 +629,35 @@ func (s *Server) newSheetHandler(w http.ResponseWriter, req *http.Request) {
 
 // NewSheet creates a new sheet, adds it to the server's sheet list
 // and asynchronously adds it to the window.
+// If below is non-nil, the sheet is added below it, in its column.
+// Otherwise, the server's Placement chooses the column.
+//
+// This method must be called with the server lock held.
+func (s *Server) newSheet(win *window, URL *url.URL, below frame) (*sheet, error) {
+	return s.addSheet(win, URL, func(f *sheet) {
+		if below == nil || !win.addFrameBelow(f, below) {
+			win.placeSheet(f, -1)
+		}
+	})
+}
+
+// NewSheetInColumn is like newSheet,
+// but it adds the sheet to the column with the given index.
+// If the index is out of range, the server's Placement chooses the column.
 //
 // This method must be called with the server lock held.
-func (s *Server) newSheet(win *window, URL *url.URL) (*sheet, error) {
+func (s *Server) newSheetInColumn(win *window, URL *url.URL, col int) (*sheet, error) {
+	return s.addSheet(win, URL, func(f *sheet) { win.placeSheet(f, col) })
+}
+
+func (s *Server) addSheet(win *window, URL *url.URL, add func(*sheet)) (*sheet, error) {
 	f, err := newSheet(strconv.Itoa(s.nextID), URL, win)
 	if err != nil {
 		return nil, err
 	}
 	s.nextID++
 	s.sheets[f.id] = f
-	win.Send(func() { win.addFrame(f) })
+	win.Send(func() { add(f) })
 	return f, nil
 }
 
@@ -323,3 +700,111 @@ func (s *Server) deleteSheet(sheetID string) bool {
 	f.win.Send(func() { f.win.deleteFrame(f) })
 	return true
 }
+
+func (s *Server) moveSheetHandler(w http.ResponseWriter, req *http.Request) {
+	var mreq MoveSheetRequest
+	if err := json.NewDecoder(req.Body).Decode(&mreq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ok, err := path.Match("/window/*", mreq.WindowPath); err != nil {
+		// The only error is path.ErrBadPattern. This pattern is not bad.
+		panic(err)
+	} else if !ok {
+		http.Error(w, "bad window path: "+mreq.WindowPath, http.StatusBadRequest)
+		return
+	}
+
+	s.Lock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	win, ok2 := s.windows[strings.TrimPrefix(mreq.WindowPath, "/window/")]
+	if !ok || !ok2 {
+		s.Unlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	s.moveSheet(f, win, mreq.X, mreq.Y, func() { close(done) })
+	s.Unlock()
+	<-done
+
+	s.RLock()
+	resp := makeSheet(f)
+	s.RUnlock()
+	respond(w, resp)
+}
+
+// MoveSheet moves a sheet to a window,
+// adding it to the column at x, at y,
+// where x is a fraction of the window width,
+// and y is a fraction of the column height.
+// If the sheet does not fit there, it is added as if newly created.
+//
+// The sheet is removed from its window and added to the new window
+// asynchronously, in the UI goroutines of the windows.
+// Done is called in the new window's UI goroutine after the sheet is added.
+//
+// This method must be called with the server lock held.
+func (s *Server) moveSheet(f *sheet, to *window, x, y float64, done func()) {
+	from := f.win
+	f.win = to
+	from.Send(func() {
+		if f.col != nil {
+			f.col.removeFrame(f)
+		}
+		if h := handler(f); h == from.inFocus {
+			from.inFocus = nil
+			f.changeFocus(from, false)
+			from.refocus()
+		}
+		to.Send(func() {
+			updateFrame(to, f)
+			_, c := columnAt(to, int(x*float64(to.Dx())))
+			if !c.addFrame(y, f) {
+				to.addFrame(f)
+			}
+			to.refocus()
+			done()
+		})
+	})
+}
+
+// TransitTimeout is how long a sheet dragged out of its window
+// waits for another window to take it.
+const transitTimeout = 2 * time.Second
+
+// DragOut records that a sheet was dragged out of its window.
+// The next window that the mouse enters takes the sheet.
+func (s *Server) dragOut(f *sheet) {
+	s.Lock()
+	s.transit = f
+	s.transitTime = s.now()
+	s.Unlock()
+}
+
+// DropIn moves the sheet dragged out of another window, if any,
+// to the given point of the window.
+//
+// DropIn must be called in the window's UI goroutine.
+func (s *Server) dropIn(w *window, p image.Point) {
+	s.RLock()
+	f := s.transit
+	s.RUnlock()
+	if f == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	f = s.transit
+	if f == nil || f.win == w {
+		return
+	}
+	s.transit = nil
+	if _, ok := s.sheets[f.id]; !ok || s.since(s.transitTime) > transitTimeout {
+		return
+	}
+	_, c := columnAt(w, p.X)
+	x := float64(p.X) / float64(w.Dx())
+	y := float64(p.Y-c.Min.Y) / float64(c.Dy())
+	s.moveSheet(f, w, x, y, func() {})
+}
@@ -3,34 +3,40 @@
 package ui
 
 import (
+	"bytes"
 	"image"
 	"image/color"
 	"image/draw"
 	"net/url"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor/view"
+	"github.com/eaburns/T/ui/syntax"
 	"github.com/eaburns/T/ui/text"
 	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
 	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/mouse"
 	"golang.org/x/mobile/event/paint"
 )
 
+const (
+	scrollWidth     = 12 // px
+	minGutterDigits = 3
+)
+
 var (
-	separatorColor = color.Gray16{0xAAAA}
-	tagColors      = []color.Color{
-		color.NRGBA{R: 0xE6, G: 0xF0, B: 0xFA, A: 0xFF},
-		color.NRGBA{R: 0xE6, G: 0xFA, B: 0xF0, A: 0xFF},
-		color.NRGBA{R: 0xF0, G: 0xE6, B: 0xFA, A: 0xFF},
-		color.NRGBA{R: 0xF0, G: 0xFA, B: 0xE6, A: 0xFF},
-		color.NRGBA{R: 0xFA, G: 0xE6, B: 0xF0, A: 0xFF},
-	}
 	mu           sync.Mutex
 	nextTagColor = 0
 )
 
-const sheetTagText = "Get Undo Look"
+const sheetTagText = "Get Put Undo Look Del"
 
 // A sheet is an editable view of a buffer of text.
 // Each sheet contains an editable tag and body.
@@ -46,7 +52,18 @@ type sheet struct {
 
 	tag  *textBox
 	body *textBox
-	sep  image.Rectangle
+	// TagColor is the index of the tag's background color
+	// in the TagBGs of the theme.
+	tagColor int
+	sep      image.Rectangle
+	// Scroll is the body's scroll bar.
+	// ScrollSep separates the scroll bar from the body text.
+	scroll, scrollSep image.Rectangle
+	// LineNumbers is whether the body has a gutter with line numbers.
+	// Gutter is the gutter, and gutterSep separates it from the body text.
+	// Both are empty if lineNumbers is false.
+	lineNumbers       bool
+	gutter, gutterSep image.Rectangle
 
 	// SubFocus is either the tag, the body, or nil.
 	subFocus handler
@@ -54,8 +71,52 @@ type sheet struct {
 	p      image.Point
 	button mouse.Button
 
+	// Find is the incremental search in progress, or nil.
+	find *finder
+
+	// FileName is the file name in the tag
+	// when the body's syntax highlighting was last updated.
+	fileName string
+
+	// Minimap is the overview of the body, or nil if it is hidden.
+	minimap *minimap
+
+	// Font is the font of the sheet's tag and body.
+	// Its unset fields are those of the window's font.
+	font Font
+	// Face is the face of the font,
+	// or nil if the sheet uses the window's face.
+	face font.Face
+
 	origX int
 	origY float64
+
+	// Unzoom is the layout of the sheet's column before the sheet was zoomed,
+	// or nil if the sheet is not zoomed.
+	unzoom *columnLayout
+	// ExpandHeight is the height of the sheet before it was collapsed.
+	expandHeight int
+
+	// File is the file that the body was last loaded from or written to,
+	// and fileTime is the modification time of the file at that point.
+	// File is "" if the body was not loaded from or written to a file.
+	file     string
+	fileTime time.Time
+	// FileChanged is whether the file changed on disk since.
+	fileChanged bool
+	// EOL is the line ending style of the file,
+	// restored when the body is written to a file.
+	// It is LF unless the file's line endings were normalized when loaded.
+	eol edit.EOL
+	// Changed is when the body last changed,
+	// or the zero time if it has not changed since it was last autosaved.
+	changed time.Time
+}
+
+// A columnLayout is the frames of a column and their y coordinates.
+type columnLayout struct {
+	frames []frame
+	ys     []float64
 }
 
 // NewSheet creates a new sheet.
@@ -63,35 +124,40 @@ type sheet struct {
 // or the path to an open buffer of an editor server.
 // The body uses the given URL for its buffer (either a new one or existing).
 // The tag uses a new buffer created on the window server's editor.
+// The body's editor is labeled with the server's user name.
+//
+// This function must be called with the server lock held.
 func newSheet(id string, URL *url.URL, w *window) (*sheet, error) {
 	s := &sheet{id: id, win: w}
 
 	mu.Lock()
-	tagBG := tagColors[nextTagColor%len(tagColors)]
+	s.tagColor = nextTagColor
 	nextTagColor++
 	mu.Unlock()
 
-	tag, err := newTextBox(w, *w.server.editorURL, text.Style{
+	tag, err := newTextBox(w, *w.server.editorURL, "", text.Style{
 		Face: w.face,
-		FG:   color.Black,
-		BG:   tagBG,
+		FG:   w.theme.TagFG,
+		BG:   w.theme.tagBG(s.tagColor),
 	})
 	if err != nil {
 		return nil, err
 	}
 	tag.view.DoAsync(edit.Change(edit.All, "/sheet/"+id+" "+sheetTagText+" "),
 		edit.Set(edit.End, '.'))
+	tag.sheet = s
 	s.tag = tag
 
-	body, err := newTextBox(w, *URL, text.Style{
+	body, err := newTextBox(w, *URL, w.server.userName, text.Style{
 		Face: w.face,
-		FG:   color.Black,
-		BG:   color.NRGBA{R: 0xFA, G: 0xF0, B: 0xE6, A: 0xFF},
+		FG:   w.theme.BodyFG,
+		BG:   w.theme.BodyBG,
 	})
 	if err != nil {
 		tag.close()
 		return nil, err
 	}
+	body.sheet = s
 	s.body = body
 
 	return s, nil
@@ -104,8 +170,15 @@ func (s *sheet) close() {
 		// The in-focus handler is closed, and so are all columns.
 		return
 	}
+	if s.minimap != nil {
+		s.minimap.close()
+		s.minimap = nil
+	}
 	s.tag.close()
 	s.body.close()
+	if s.face != nil {
+		s.face.Close()
+	}
 	s.win = nil
 }
 
@@ -113,20 +186,49 @@ var tagFileAddr = edit.Rune(0).To(edit.Rune(0).Plus(edit.Regexp(`\S*`)))
 
 func (s *sheet) tagFileName() string {
 	// TODO(eaburns): This is a blocking RPC, but it's called in the window handler go routine. Don't do that. Use a view to update this asynchronously.
-	res, err := s.tag.doSync(edit.Print(tagFileAddr))
+	// Print sets dot to the file name; restore it.
+	res, err := s.tag.doSync(edit.Set(dot, view.TmpMark), edit.Print(tagFileAddr), edit.Set(edit.Mark(view.TmpMark), '.'))
 	if err != nil {
 		panic("failed to read tag: " + err.Error())
 	}
-	if res[0].Error != "" {
-		panic("failed to read tag: " + res[0].Error)
+	if res[1].Error != "" {
+		panic("failed to read tag: " + res[1].Error)
 	}
-	return res[0].Print
+	return res[1].Print
+}
+
+// ViewFileName returns the file name in the tag's view.
+// Unlike tagFileName, it does not block,
+// but it may lag behind edits to the tag.
+func (s *sheet) viewFileName() string {
+	var name string
+	s.tag.view.View(func(text []byte, _ []view.Mark) {
+		if i := bytes.IndexFunc(text, unicode.IsSpace); i >= 0 {
+			text = text[:i]
+		}
+		name = string(text)
+	})
+	return name
 }
 
 func (s *sheet) setTagFileName(str string) {
 	s.tag.doAsync(edit.Change(tagFileAddr, str))
 }
 
+// UpdateSyntax updates the body's syntax highlighting
+// if the extension of the file name in the tag has changed.
+func (s *sheet) updateSyntax() {
+	name := s.viewFileName()
+	if name == s.fileName {
+		return
+	}
+	prev := s.fileName
+	s.fileName = name
+	if path.Ext(name) != path.Ext(prev) {
+		s.body.setTokenizer(syntax.Lookup(name))
+	}
+}
+
 func (s *sheet) updateText() {
 	b := &s.Rectangle
 
@@ -140,12 +242,167 @@ func (s *sheet) updateText() {
 	s.tag.setSize(image.Pt(b.Dx(), tagMax))
 	tagHeight := s.tag.text.LinesHeight()
 
-	s.body.topLeft = image.Pt(b.Min.X, b.Min.Y+tagHeight+borderWidth)
-	s.body.setSize(image.Pt(b.Dx(), b.Dy()-tagHeight-borderWidth))
+	bodyY := b.Min.Y + tagHeight + borderWidth
+	if bodyY > b.Max.Y {
+		// The sheet is collapsed; only the tag shows.
+		bodyY = b.Max.Y
+	}
+	scrollX := b.Min.X + s.win.px(scrollWidth)
+	if scrollX > b.Max.X {
+		scrollX = b.Max.X
+	}
+	s.scroll = image.Rect(b.Min.X, bodyY, scrollX, b.Max.Y)
+	s.scrollSep = image.Rect(scrollX, bodyY, scrollX+borderWidth, b.Max.Y)
+
+	s.gutter = image.Rect(s.scrollSep.Max.X, bodyY, s.scrollSep.Max.X, b.Max.Y)
+	s.gutterSep = s.gutter
+	if s.lineNumbers {
+		gutterX := s.gutter.Min.X + s.gutterWidth()
+		if gutterX+borderWidth > b.Max.X {
+			gutterX = b.Max.X - borderWidth
+		}
+		s.gutter.Max.X = gutterX
+		s.gutterSep = image.Rect(gutterX, bodyY, gutterX+borderWidth, b.Max.Y)
+	}
+
+	bodyMaxX := b.Max.X
+	if s.minimap != nil {
+		x := b.Max.X - s.win.px(minimapWidth)
+		if x < s.gutterSep.Max.X+borderWidth {
+			x = s.gutterSep.Max.X + borderWidth
+		}
+		if x > b.Max.X {
+			x = b.Max.X
+		}
+		bodyMaxX = x - borderWidth
+		s.minimap.setBounds(image.Rect(x, bodyY, b.Max.X, b.Max.Y),
+			image.Rect(bodyMaxX, bodyY, x, b.Max.Y), s.body.opts.TabWidth)
+	}
+
+	s.body.topLeft = image.Pt(s.gutterSep.Max.X, bodyY)
+	bodySize := image.Pt(bodyMaxX-s.gutterSep.Max.X, b.Max.Y-bodyY)
+	if bodySize.X < 0 {
+		bodySize.X = 0
+	}
+	s.body.setSize(bodySize)
 
 	s.sep = image.Rectangle{
-		Min: image.Pt(b.Min.X, b.Min.Y+tagHeight),
-		Max: image.Pt(b.Max.X, b.Min.Y+tagHeight+borderWidth),
+		Min: image.Pt(b.Min.X, bodyY-borderWidth),
+		Max: image.Pt(b.Max.X, bodyY),
+	}
+}
+
+// ToggleLineNumbers shows or hides the body's line number gutter.
+func (s *sheet) toggleLineNumbers() {
+	s.lineNumbers = !s.lineNumbers
+	s.body.mu.Lock()
+	s.body.reset = true
+	s.body.mu.Unlock()
+}
+
+// ToggleZoom grows the sheet to fill its column,
+// shrinking the column's other sheets to their tags.
+// If the sheet is already zoomed,
+// the column's layout from before the zoom is restored,
+// unless frames were since added to or removed from the column.
+func (s *sheet) toggleZoom() {
+	c := s.col
+	if c == nil {
+		return
+	}
+	if z := s.unzoom; z != nil && sameFrames(z.frames, c.frames) {
+		copy(c.ys, z.ys)
+		s.unzoom = nil
+	} else {
+		s.unzoom = &columnLayout{
+			frames: append([]frame{}, c.frames...),
+			ys:     append([]float64{}, c.ys...),
+		}
+		maximize(s)
+	}
+	c.setBounds(c.bounds())
+}
+
+func sameFrames(fs, gs []frame) bool {
+	if len(fs) != len(gs) {
+		return false
+	}
+	for i := range fs {
+		if fs[i] != gs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ToggleCollapse shrinks the sheet so that only its tag shows.
+// If the sheet is already collapsed,
+// it grows back to its height from before it was collapsed,
+// taking space from the sheets below it, or else above it.
+func (s *sheet) toggleCollapse() {
+	c := s.col
+	if c == nil {
+		return
+	}
+	s.unzoom = nil
+	if min := s.minHeight(); s.Dy() > min {
+		s.expandHeight = s.Dy()
+		minimize(s)
+	} else {
+		h := s.expandHeight
+		if h <= min {
+			h = c.Dy() / len(c.frames)
+		}
+		i := frameIndex(c, s)
+		if delta := h - s.Dy(); delta > 0 && !slideDown(c, i, delta) {
+			slideUp(c, i, delta)
+		}
+	}
+	c.setBounds(c.bounds())
+}
+
+// GutterWidth returns the width of the line number gutter,
+// wide enough for the line numbers of the visible lines.
+func (s *sheet) gutterWidth() int {
+	face := s.body.opts.DefaultStyle.Face
+	h := face.Metrics().Height.Round()
+	if h <= 0 {
+		h = 1
+	}
+	last := s.body.view.Line() + int64(s.Dy()/h)
+	digits := len(strconv.FormatInt(last, 10))
+	if digits < minGutterDigits {
+		digits = minGutterDigits
+	}
+	w := font.MeasureString(face, strings.Repeat("0", digits)).Round()
+	return w + 2*s.body.opts.Padding
+}
+
+// DrawGutter draws the line numbers of the body's visible lines.
+func (s *sheet) drawGutter(scr screen.Screen, win screen.Window) {
+	th := s.win.theme
+	win.Fill(s.gutter, th.GutterBG, draw.Src)
+
+	opts := s.body.opts
+	opts.DefaultStyle.FG = th.GutterFG
+	opts.DefaultStyle.BG = th.GutterBG
+	opts.Padding = 0
+	h := opts.DefaultStyle.Face.Metrics().Height.Round()
+	setter := text.NewSetter(opts)
+	defer setter.Release()
+	for i, y := range s.body.lineYs {
+		num := strconv.FormatInt(s.body.line0+int64(i), 10)
+		w := font.MeasureString(opts.DefaultStyle.Face, num).Round()
+		x := s.gutter.Max.X - s.body.opts.Padding - w
+		if x < s.gutter.Min.X || y+h > s.gutter.Dy() {
+			continue
+		}
+		opts.Size = image.Pt(w, h)
+		setter.Reset(opts)
+		setter.Add([]byte(num))
+		t := setter.Set()
+		t.Draw(image.Pt(x, s.gutter.Min.Y+y), scr, win)
+		t.Release()
 	}
 }
 
@@ -181,11 +438,50 @@ func (s *sheet) focus(p image.Point) handler {
 }
 
 func (s *sheet) draw(scr screen.Screen, win screen.Window) {
+	s.updateSyntax()
 	s.updateText()
 
 	s.tag.drawLines(scr, win)
-	win.Fill(s.sep, separatorColor, draw.Over)
+	s.drawIndicators(win)
+	sepColor := s.win.theme.Separator
+	win.Fill(s.sep, sepColor, draw.Over)
+	s.body.drawScrollBar(s.scroll, win)
+	win.Fill(s.scrollSep, sepColor, draw.Over)
+	if s.lineNumbers {
+		s.drawGutter(scr, win)
+		win.Fill(s.gutterSep, sepColor, draw.Over)
+	}
+	if s.minimap != nil {
+		s.minimap.draw(s, win)
+	}
 	s.body.draw(scr, win)
+	if s.find != nil {
+		s.drawFindBar(scr, win)
+	}
+}
+
+// DrawIndicators draws boxes at the right of the tag's first line,
+// to the left of the busy indicator:
+// one if the body is read-only,
+// and one if the file of the body changed on disk.
+func (s *sheet) drawIndicators(win screen.Window) {
+	var colors []color.Color
+	if s.body.readOnly {
+		colors = append(colors, s.win.theme.ReadOnly)
+	}
+	if s.fileChanged {
+		colors = append(colors, s.win.theme.FileChanged)
+	}
+	t := s.tag
+	h := t.opts.DefaultStyle.Face.Metrics().Height.Round()
+	x1 := t.topLeft.X + t.opts.Size.X - t.opts.Padding
+	y0 := t.topLeft.Y + t.opts.Padding
+	box := image.Rectangle{Min: t.topLeft, Max: t.topLeft.Add(t.opts.Size)}
+	for _, c := range colors {
+		x1 -= h/2 + h/4
+		r := image.Rect(x1-h/2, y0+h/4, x1, y0+h/4+h/2)
+		win.Fill(r.Intersect(box), c, draw.Src)
+	}
 }
 
 // DrawLast is called if the sheet is in focus, after the entire window has been drawn.
@@ -193,7 +489,7 @@ func (s *sheet) draw(scr screen.Screen, win screen.Window) {
 func (s *sheet) drawLast(scr screen.Screen, win screen.Window) {
 	if s.col == nil {
 		s.draw(scr, win)
-		drawBorder(s.bounds(), win)
+		drawBorder(s.bounds(), s.win.theme.Border, win)
 	}
 }
 
@@ -222,23 +518,66 @@ func (s *sheet) key(w *window, event key.Event) bool {
 			redraw = true
 		}
 	}
+	if s.find != nil {
+		s.findKey(event)
+		return true
+	}
+	if cmd := sheetCommands[boundName(s.keymap(), event)]; cmd != nil {
+		if event.Direction != key.DirRelease {
+			cmd(s)
+		}
+		return true
+	}
 	if s.subFocus != nil && s.subFocus.key(w, event) {
 		redraw = true
 	}
 	return redraw
 }
 
+func (s *sheet) keymap() Keymap {
+	s.win.server.RLock()
+	defer s.win.server.RUnlock()
+	return s.win.server.keymap
+}
+
 func (s *sheet) mouse(w *window, event mouse.Event) bool {
 	p := image.Pt(int(event.X), int(event.Y))
 
 	switch event.Direction {
+	case mouse.DirStep:
+		if event.Modifiers != key.ModControl {
+			break
+		}
+		switch event.Button {
+		case mouse.ButtonWheelUp:
+			s.zoomFont(1)
+			return true
+		case mouse.ButtonWheelDown:
+			s.zoomFont(-1)
+			return true
+		}
+
 	case mouse.DirPress:
+		if s.button == mouse.ButtonNone && event.Modifiers == 0 && p.In(s.scroll) {
+			s.body.scrollClick(event.Button, p.Y-s.scroll.Min.Y, s.scroll.Dy())
+			return true
+		}
+		if s.button == mouse.ButtonNone && event.Modifiers == 0 && s.minimap != nil && p.In(s.minimap.Rectangle) {
+			s.minimap.mouse(s, event)
+			return true
+		}
 		if s.button == mouse.ButtonNone {
 			s.p = p
 			s.button = event.Button
 			break
 		}
-		// A second button was pressed while the first was held.
+		if event.Modifiers != key.ModShift {
+			// A second button was pressed while the first was held,
+			// but not while moving the sheet.
+			// This is a text editing chord; let the tag or body handle it.
+			break
+		}
+		// A second button was pressed while moving the sheet.
 		// Sheets don't use chords; treat this as a release of the first.
 		event.Button = s.button
 		fallthrough
@@ -264,13 +603,18 @@ func (s *sheet) mouse(w *window, event mouse.Event) bool {
 				return slideDown(s.col, i, s.minHeight())
 			}
 			_, c := columnAt(w, p.X)
-			yfrac := float64(s.Min.Y) / float64(c.Dy())
-			if c.addFrame(yfrac, s) {
+			yfrac := float64(s.Min.Y-c.Min.Y) / float64(c.Dy())
+			if p.In(w.bounds()) && c.addFrame(yfrac, s) {
 				return true
 			}
 			if _, c = columnAt(w, s.origX); !c.addFrame(s.origY, s) {
 				panic("can't put it back")
 			}
+			if !p.In(w.bounds()) {
+				// Dropped outside of the window.
+				// Another window may take it.
+				w.server.dragOut(s)
+			}
 			return true
 		case mouse.ButtonMiddle:
 			s.win.server.deleteSheet(s.id)
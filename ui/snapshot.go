@@ -0,0 +1,79 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/math/f64"
+)
+
+func (s *Server) windowImageHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	win, ok := s.windows[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	result := make(chan *image.RGBA)
+	win.Send(func() { result <- win.image() })
+	s.RUnlock()
+	img := <-result
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// Image returns a new image of the window,
+// drawn off-screen as it would be drawn on the screen.
+// It must be called in the window's UI goroutine.
+func (w *window) image() *image.RGBA {
+	img := imageWindow{image.NewRGBA(w.bounds())}
+	w.draw(w.server.screen, img)
+	if w.inFocus != nil {
+		w.inFocus.drawLast(w.server.screen, img)
+	}
+	w.drawTooltip(w.server.screen, img)
+	return img.RGBA
+}
+
+// An imageWindow is a screen.Window that draws to an image.
+//
+// Only Upload and Fill are implemented;
+// the ui package does not draw textures.
+// Events sent to the window are discarded.
+type imageWindow struct{ *image.RGBA }
+
+func (imageWindow) Release()                    {}
+func (imageWindow) Send(event interface{})      {}
+func (imageWindow) SendFirst(event interface{}) {}
+func (imageWindow) NextEvent() interface{}      { panic("unimplemented") }
+
+func (w imageWindow) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(w.RGBA, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func (w imageWindow) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(w.RGBA, dr, image.NewUniform(src), image.ZP, op)
+}
+
+func (imageWindow) Draw(f64.Aff3, screen.Texture, image.Rectangle, draw.Op, *screen.DrawOptions) {}
+func (imageWindow) DrawUniform(f64.Aff3, color.Color, image.Rectangle, draw.Op, *screen.DrawOptions) {
+}
+func (imageWindow) Copy(image.Point, screen.Texture, image.Rectangle, draw.Op, *screen.DrawOptions) {}
+func (imageWindow) Scale(image.Rectangle, screen.Texture, image.Rectangle, draw.Op, *screen.DrawOptions) {
+}
+func (imageWindow) Publish() screen.PublishResult { return screen.PublishResult{} }
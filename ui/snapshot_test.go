@@ -0,0 +1,44 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWindowImage(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	imageURL := urlWithPath(s.url, "/", "window", w.id, "image")
+	img, err := WindowImage(imageURL)
+	if err != nil {
+		t.Fatalf("WindowImage(%s)=_,%v", imageURL, err)
+	}
+	var size image.Point
+	var tag, body image.Point
+	w.Send(func() {
+		size = w.Size()
+		tag = w.tag.Min.Add(image.Pt(1, 1))
+		body = sheet0.body.topLeft.Add(sheet0.body.opts.Size.Div(2))
+	})
+	wait(w)
+	if got := img.Bounds().Size(); got != size {
+		t.Errorf("WindowImage(%s) size=%v, want %v", imageURL, got, size)
+	}
+	th := DefaultTheme()
+	if c := color.RGBAModel.Convert(img.At(tag.X, tag.Y)); c != color.RGBAModel.Convert(th.ColumnTagBG) {
+		t.Errorf("window tag color=%v, want %v", c, th.ColumnTagBG)
+	}
+	if c := color.RGBAModel.Convert(img.At(body.X, body.Y)); c != color.RGBAModel.Convert(th.BodyBG) {
+		t.Errorf("sheet body color=%v, want %v", c, th.BodyBG)
+	}
+
+	notFoundURL := urlWithPath(s.url, "/", "window", "nope", "image")
+	if _, err := WindowImage(notFoundURL); err != ErrNotFound {
+		t.Errorf("WindowImage(%s)=_,%v, want %v", notFoundURL, err, ErrNotFound)
+	}
+}
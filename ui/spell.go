@@ -0,0 +1,265 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/paint"
+)
+
+// A Dictionary checks the spelling of words.
+//
+// Dictionaries are called in their own goroutine,
+// so they may block.
+type Dictionary interface {
+	// Correct returns whether the word is spelled correctly.
+	Correct(word string) bool
+}
+
+// A WordList is a Dictionary containing a list of words.
+// Methods on WordList are safe for use by concurrent go routines.
+type WordList struct {
+	mu    sync.RWMutex
+	words map[string]bool
+	// Path is the file to which added words are appended,
+	// or "" if they are not saved.
+	path string
+}
+
+// NewWordList returns a new WordList containing the given words.
+// Words added to it are not saved.
+func NewWordList(words ...string) *WordList {
+	l := &WordList{words: make(map[string]bool)}
+	for _, w := range words {
+		l.words[w] = true
+	}
+	return l
+}
+
+// LoadWordList returns a new WordList containing the words in a file,
+// one per line.
+// Words added to it are appended to the file.
+// If the file does not exist, the WordList is empty,
+// and the file is created when the first word is added.
+func LoadWordList(path string) (*WordList, error) {
+	l := NewWordList()
+	l.path = path
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			l.words[w] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Correct implements Dictionary.Correct.
+// A word is correct if it or its lower-case form is in the list.
+func (l *WordList) Correct(word string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.words[word] || l.words[strings.ToLower(word)]
+}
+
+// Add adds a word to the list,
+// and appends it to the list's file, if any.
+func (l *WordList) Add(word string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.words[word] {
+		return nil
+	}
+	if l.path != "" {
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(word + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	l.words[word] = true
+	return nil
+}
+
+// SetSpelling sets the dictionaries used to check the spelling
+// of the text visible in the body of the sheet in focus.
+// Words that are correct in neither dict nor user
+// are drawn with a squiggly underline.
+// The Learn command adds words to user.
+// If dict is nil, spelling is not checked.
+// By default, spelling is not checked.
+func (s *Server) SetSpelling(dict Dictionary, user *WordList) {
+	s.Lock()
+	s.dict, s.userDict = dict, user
+	s.Unlock()
+}
+
+// A spelling is the state of checking the spelling of a text box.
+type spelling struct {
+	// Text is the visible text, beginning at rune offset at.
+	text string
+	at   int64
+	// Checked is whether the text was checked,
+	// or is being checked.
+	checked bool
+	// Misspelled are the sorted spans of the misspelled words
+	// of the most recently checked text.
+	misspelled []edit.Span
+}
+
+// SetSpellingText sets the visible text to check,
+// if it differs from the current text.
+func (t *textBox) setSpellingText(text []byte, at int64) {
+	if at == t.spelling.at && string(text) == t.spelling.text {
+		return
+	}
+	t.spelling.text, t.spelling.at = string(text), at
+	t.spelling.checked = false
+}
+
+// TickSpelling checks the spelling of the text visible
+// in the body of the sheet in focus, in a new goroutine,
+// if it was not yet checked.
+func (w *window) tickSpelling() {
+	s, ok := w.inFocus.(*sheet)
+	if !ok || s.col == nil {
+		return
+	}
+	w.server.RLock()
+	dict, user := w.server.dict, w.server.userDict
+	w.server.RUnlock()
+	t := s.body
+	if dict == nil {
+		if t.spelling.misspelled != nil {
+			t.spelling.misspelled = nil
+			t.mu.Lock()
+			t.reset = true
+			t.mu.Unlock()
+			w.Send(paint.Event{})
+		}
+		return
+	}
+	if t.spelling.checked {
+		return
+	}
+	t.spelling.checked = true
+	text, at := t.spelling.text, t.spelling.at
+	go func() {
+		correct := func(word string) bool {
+			return dict.Correct(word) || user != nil && user.Correct(word)
+		}
+		misspelled := misspellings(text, at, correct)
+		w.Send(func() {
+			if t.spelling.text != text || t.spelling.at != at {
+				return
+			}
+			t.spelling.misspelled = misspelled
+			t.mu.Lock()
+			t.reset = true
+			t.mu.Unlock()
+		})
+	}()
+}
+
+// Misspellings returns the spans of the words of text,
+// which begins at rune offset at,
+// that are not correct.
+//
+// Words are runs of word characters, as matched by \w,
+// possibly joined by single apostrophes, as in "don't".
+// Typographic apostrophes are checked as ASCII apostrophes.
+// Words containing digits or underscores are not checked,
+// since they are more likely identifiers than prose.
+func misspellings(text string, at int64, correct func(string) bool) []edit.Span {
+	var spans []edit.Span
+	var start, i int
+	var s0, n int64
+	inWord, prose := false, true
+	end := func() {
+		if inWord && prose && !correct(strings.Replace(text[start:i], "’", "'", -1)) {
+			spans = append(spans, edit.Span{s0, at + n})
+		}
+		inWord, prose = false, true
+	}
+	for i < len(text) {
+		r, w := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isWordRune(r):
+			if !inWord {
+				inWord, start, s0 = true, i, at+n
+			}
+			if !unicode.IsLetter(r) {
+				prose = false
+			}
+		case (r == '\'' || r == '’') && inWord && i+w < len(text):
+			if next, _ := utf8.DecodeRuneInString(text[i+w:]); !unicode.IsLetter(next) {
+				end()
+			}
+		default:
+			end()
+		}
+		i += w
+		n++
+	}
+	end()
+	return spans
+}
+
+// IsWordRune returns whether r is a word character, as matched by \w.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Learn adds words to the user dictionary:
+// its arguments, or the text of the body's dot if there are none.
+func learn(s *sheet, args string) {
+	s.win.server.RLock()
+	user := s.win.server.userDict
+	s.win.server.RUnlock()
+	if user == nil {
+		s.errorf("Learn: no user dictionary")
+		return
+	}
+	if args == "" {
+		res, err := s.body.doSync(edit.Print(dot))
+		if err != nil {
+			s.errorf("Learn: failed to read dot: %v", err)
+			return
+		}
+		if res[0].Error != "" {
+			s.errorf("Learn: failed to read dot: %s", res[0].Error)
+			return
+		}
+		args = res[0].Print
+	}
+	for _, word := range strings.Fields(args) {
+		if err := user.Add(word); err != nil {
+			s.errorf("Learn: %v", err)
+			return
+		}
+	}
+	s.body.spelling.checked = false
+}
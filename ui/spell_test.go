@@ -0,0 +1,125 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestMisspellings(t *testing.T) {
+	words := NewWordList("hello", "world", "don't", "a")
+	tests := []struct {
+		text string
+		at   int64
+		want []edit.Span
+	}{
+		{text: "", want: nil},
+		{text: "hello world", want: nil},
+		{text: "Hello World", want: nil},
+		{text: "helo world", want: []edit.Span{{0, 4}}},
+		{text: "helo wrld", want: []edit.Span{{0, 4}, {5, 9}}},
+		{text: "helo wrld", at: 10, want: []edit.Span{{10, 14}, {15, 19}}},
+		{text: "don't", want: nil},
+		{text: "don’t", want: nil},
+		{text: "dont", want: []edit.Span{{0, 4}}},
+		{text: "'hello'", want: nil},
+		{text: "hello'", want: nil},
+		{text: "a_b x1 123", want: nil},
+		{text: "«wrld»", want: []edit.Span{{1, 5}}},
+		{text: "héllo wörld", want: []edit.Span{{0, 5}, {6, 11}}},
+		{text: "hello\nwrld\n", want: []edit.Span{{6, 10}}},
+	}
+	for _, test := range tests {
+		got := misspellings(test.text, test.at, words.Correct)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("misspellings(%q, %d)=%v, want %v", test.text, test.at, got, test.want)
+		}
+	}
+}
+
+func TestWordList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spell_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "words")
+
+	l, err := LoadWordList(path)
+	if err != nil {
+		t.Fatalf("LoadWordList(%q)=_,%v", path, err)
+	}
+	if l.Correct("hello") {
+		t.Errorf("Correct(hello)=true, want false")
+	}
+	for _, w := range []string{"hello", "world", "hello"} {
+		if err := l.Add(w); err != nil {
+			t.Fatalf("Add(%q)=%v", w, err)
+		}
+	}
+	if !l.Correct("hello") || !l.Correct("Hello") {
+		t.Errorf("Correct(hello)=false, want true")
+	}
+
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(d), "hello\nworld\n"; got != want {
+		t.Errorf("file contents=%q, want %q", got, want)
+	}
+
+	l, err = LoadWordList(path)
+	if err != nil {
+		t.Fatalf("LoadWordList(%q)=_,%v", path, err)
+	}
+	if !l.Correct("hello") || !l.Correct("world") {
+		t.Errorf("Correct(hello), Correct(world)=false, want true")
+	}
+}
+
+func TestSpelling(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	user := NewWordList()
+	s.uiServer.SetSpelling(NewWordList("hello", "world"), user)
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() { w.inFocus = sheet0 })
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "hello wrld")); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	if got, want := waitMisspelled(w, sheet0.body, 1), []edit.Span{{6, 10}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("misspelled=%v, want %v", got, want)
+	}
+
+	w.Send(func() { learn(sheet0, "wrld") })
+	if got := waitMisspelled(w, sheet0.body, 0); len(got) != 0 {
+		t.Errorf("misspelled=%v, want []", got)
+	}
+	if !user.Correct("wrld") {
+		t.Errorf("user.Correct(wrld)=false, want true")
+	}
+}
+
+// WaitMisspelled returns the misspelled spans of a text box
+// once there are n of them, or after a timeout.
+func waitMisspelled(w *window, tb *textBox, n int) []edit.Span {
+	var got []edit.Span
+	var checked bool
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { got, checked = tb.spelling.misspelled, tb.spelling.checked })
+		wait(w)
+		if len(got) == n && checked {
+			break
+		}
+	}
+	return got
+}
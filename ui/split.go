@@ -0,0 +1,74 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+)
+
+// BufferRefs counts the text boxes viewing each buffer,
+// keyed by the buffer URL.
+// Each text box has its own editor, and so its own dot and scroll position,
+// but a buffer is only closed when the last text box viewing it is closed.
+var bufferRefs = struct {
+	sync.Mutex
+	n map[string]int
+}{n: make(map[string]int)}
+
+// RefBuffer adds a reference to a buffer.
+func refBuffer(URL *url.URL) {
+	bufferRefs.Lock()
+	bufferRefs.n[URL.String()]++
+	bufferRefs.Unlock()
+}
+
+// UnrefBuffer removes a reference to a buffer,
+// and returns whether it was the last reference.
+func unrefBuffer(URL *url.URL) bool {
+	bufferRefs.Lock()
+	defer bufferRefs.Unlock()
+	k := URL.String()
+	bufferRefs.n[k]--
+	if bufferRefs.n[k] > 0 {
+		return false
+	}
+	delete(bufferRefs.n, k)
+	return true
+}
+
+// Split opens a new sheet below s, viewing the same body buffer.
+// The new sheet has the same tag file name, file, dot, and scroll position,
+// but its dot and scroll position change independently of s.
+func split(s *sheet, _ string) {
+	w := s.win
+	name := s.tagFileName()
+	w.server.Lock()
+	f, err := w.server.newSheet(w, s.body.bufferURL, s)
+	w.server.Unlock()
+	if err != nil {
+		s.errorf("Split: %v", err)
+		return
+	}
+	f.setTagFileName(name)
+	f.setFile(s.file, s.fileTime)
+	f.fileChanged = s.fileChanged
+	f.eol = s.eol
+	f.body.readOnly = s.body.readOnly
+	f.body.doAsync(edit.Set(edit.Clamp(edit.Rune(s.body.dot0)).To(edit.Clamp(edit.Rune(s.body.dot1))), '.'))
+	f.body.view.Warp(edit.Clamp(edit.Rune(s.body.l0)))
+}
+
+// BufferSheets returns the sheets with bodies viewing a buffer.
+// It must be called with the server lock held.
+func (s *Server) bufferSheets(URL *url.URL) []*sheet {
+	var sheets []*sheet
+	for _, f := range s.sheets {
+		if f.body.bufferURL.String() == URL.String() {
+			sheets = append(sheets, f)
+		}
+	}
+	return sheets
+}
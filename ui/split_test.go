@@ -0,0 +1,103 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+)
+
+func TestBufferRefs(t *testing.T) {
+	URL, err := url.Parse("http://localhost/buffer/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refBuffer(URL)
+	refBuffer(URL)
+	if unrefBuffer(URL) {
+		t.Errorf("unrefBuffer()=true, want false")
+	}
+	if !unrefBuffer(URL) {
+		t.Errorf("unrefBuffer()=false, want true")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, "hello world", edit.Span{6, 11})
+
+	w.Send(func() { split(sheet0, "") })
+	split0 := waitSplit(t, w, sheet0)
+	if *split0.body.bufferURL != *sheet0.body.bufferURL {
+		t.Fatalf("split body URL=%s, want %s", split0.body.bufferURL, sheet0.body.bufferURL)
+	}
+	if got := waitDot(w, split0.body, edit.Span{6, 11}); got != (edit.Span{6, 11}) {
+		t.Errorf("split dot=%v, want #6,#11", got)
+	}
+
+	// Changes through either sheet show in both,
+	// but dot is independent.
+	if _, err := split0.body.doSync(edit.Change(edit.Rune(0).To(edit.Rune(5)), "howdy"), edit.Set(edit.Rune(0), '.')); err != nil {
+		t.Fatalf("doSync(…)=_,%v", err)
+	}
+	if got := waitDot(w, split0.body, edit.Span{0, 0}); got != (edit.Span{0, 0}) {
+		t.Errorf("split dot=%v, want #0", got)
+	}
+	if got := waitDot(w, sheet0.body, edit.Span{6, 11}); got != (edit.Span{6, 11}) {
+		t.Errorf("sheet0 dot=%v, want #6,#11", got)
+	}
+	if got := waitText(sheet0.body, "howdy world"); got != "howdy world" {
+		t.Errorf("sheet0 text=%q, want %q", got, "howdy world")
+	}
+}
+
+func TestSplit_Close(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	URL := *sheet0.body.bufferURL
+	w.Send(func() { split(sheet0, "") })
+	split0 := waitSplit(t, w, sheet0)
+
+	// The buffer stays open until the last sheet viewing it is closed.
+	s.uiServer.deleteSheet(split0.id)
+	wait(w)
+	if _, err := editor.BufferInfo(&URL); err != nil {
+		t.Fatalf("after closing the split, BufferInfo(%s)=_,%v", URL.String(), err)
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, "x")); err != nil {
+		t.Errorf("after closing the split, doSync(…)=_,%v", err)
+	}
+
+	s.uiServer.deleteSheet(sheet0.id)
+	wait(w)
+	if _, err := editor.BufferInfo(&URL); err == nil {
+		t.Errorf("after closing both, BufferInfo(%s)=_,nil, want error", URL.String())
+	}
+}
+
+// WaitSplit returns the sheet below s
+// once it views the same buffer as s, or fails after a timeout.
+func waitSplit(t *testing.T, w *window, s *sheet) *sheet {
+	for i := 0; i < 100; i++ {
+		var f *sheet
+		w.Send(func() {
+			if i := frameIndex(s.col, s); i+1 < len(s.col.frames) {
+				f, _ = s.col.frames[i+1].(*sheet)
+			}
+		})
+		wait(w)
+		if f != nil && f.body.bufferURL.String() == s.body.bufferURL.String() {
+			return f
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no split sheet")
+	return nil
+}
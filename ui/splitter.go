@@ -0,0 +1,206 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+
+	"github.com/eaburns/T/ui/cursor"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+// SplitterSlop is the distance from a border
+// within which the border's splitter is in focus.
+// A splitter reaches at most a quarter of the way into a frame,
+// so the middle of a small frame is never taken by its borders.
+const splitterSlop = 2 // px
+
+// A splitter is the border between two columns,
+// or between two sheets of a column.
+// Dragging a splitter with the left button
+// resizes the columns or sheets on either side of it,
+// keeping each at least its minimum size.
+//
+// Where the border between two columns meets
+// the border between two sheets of one of the columns,
+// the splitter is a corner, and dragging it moves both borders.
+type splitter struct {
+	// Col is the column right of the splitter
+	// or the column containing the splitter.
+	col *column
+
+	// Frame is the index in col of the frame below the splitter,
+	// or 0 if the splitter is the left border of col.
+	frame int
+
+	// Corner is the splitter of the border between two sheets
+	// that meets the border left of col at the splitter,
+	// or nil if the splitter is not a corner.
+	corner *splitter
+
+	held bool
+}
+
+// SplitterAt returns the splitter at a point in the window,
+// or nil if there is none.
+//
+// The border between a column's tag and its first sheet
+// is not a splitter, because column tags have a fixed height.
+func splitterAt(w *window, p image.Point) *splitter {
+	slop := w.px(splitterSlop)
+	for i := 1; i < len(w.columns); i++ {
+		c, left := w.columns[i], w.columns[i-1]
+		b := image.Rect(left.Max.X-reach(left.Dx(), slop), c.Min.Y, c.Min.X+reach(c.Dx(), slop), c.Max.Y)
+		if !p.In(b) {
+			continue
+		}
+		corner := frameSplitterAt(c, p, slop)
+		if corner == nil {
+			corner = frameSplitterAt(w.columns[i-1], p, slop)
+		}
+		return &splitter{col: c, corner: corner}
+	}
+	for _, c := range w.columns {
+		if p.X < c.Min.X || p.X >= c.Max.X {
+			continue
+		}
+		if s := frameSplitterAt(c, p, slop); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// FrameSplitterAt returns the splitter between two frames of the column
+// within slop pixels of the y coordinate of the point,
+// or nil if there is none.
+func frameSplitterAt(c *column, p image.Point, slop int) *splitter {
+	for i := 2; i < len(c.frames); i++ {
+		above, below := c.frames[i-1].bounds(), c.frames[i].bounds()
+		if p.Y >= above.Max.Y-reach(above.Dy(), slop) && p.Y < below.Min.Y+reach(below.Dy(), slop) {
+			return &splitter{col: c, frame: i}
+		}
+	}
+	return nil
+}
+
+// Reach returns the distance that a splitter reaches
+// into a frame of the given width or height.
+func reach(size, slop int) int {
+	if d := size / 4; d < slop {
+		return d
+	}
+	return slop
+}
+
+// Same returns whether two splitters are at the same border.
+func (s *splitter) same(t *splitter) bool {
+	if (s.corner == nil) != (t.corner == nil) || s.corner != nil && !s.corner.same(t.corner) {
+		return false
+	}
+	return s.col == t.col && s.frame == t.frame
+}
+
+// Cursor returns the shape of the mouse cursor over the splitter.
+func (s *splitter) cursor() cursor.Shape {
+	switch {
+	case s.corner != nil:
+		return cursor.ResizeAll
+	case s.frame == 0:
+		return cursor.ResizeEW
+	default:
+		return cursor.ResizeNS
+	}
+}
+
+func (s *splitter) changeFocus(*window, bool) {}
+
+func (s *splitter) tick(*window) bool { return false }
+
+func (s *splitter) key(*window, key.Event) bool { return false }
+
+func (s *splitter) mouse(w *window, event mouse.Event) bool {
+	if event.Button != mouse.ButtonLeft && event.Direction != mouse.DirNone {
+		return false
+	}
+	switch event.Direction {
+	case mouse.DirPress:
+		s.held = true
+	case mouse.DirRelease:
+		s.held = false
+	case mouse.DirNone:
+		if !s.held || s.col.win != w {
+			return false
+		}
+		p := image.Pt(int(event.X), int(event.Y))
+		if s.frame != 0 {
+			return s.moveFrame(p.Y)
+		}
+		moved := s.moveColumn(w, p.X)
+		if s.corner != nil && s.corner.moveFrame(p.Y) {
+			moved = true
+		}
+		return moved
+	}
+	return false
+}
+
+// MoveColumn moves the left side of the splitter's column to x,
+// or as close as it can while keeping both columns
+// at least minFrameWidth wide.
+func (s *splitter) moveColumn(w *window, x int) bool {
+	i := columnIndex(w, s.col)
+	if i <= 0 {
+		return false
+	}
+	min := w.columns[i-1].Min.X + w.px(minFrameWidth) + borderWidth
+	max := s.col.Max.X - w.px(minFrameWidth)
+	if max < min {
+		return false
+	}
+	x = clampInt(x, min, max)
+	if x == s.col.Min.X {
+		return false
+	}
+	w.xs[i] = float64(x-w.Min.X) / float64(w.Dx())
+	w.setBounds(w.bounds())
+	return true
+}
+
+// MoveFrame moves the top of the splitter's frame to y,
+// or as close as it can while keeping both frames
+// at least their minimum height.
+func (s *splitter) moveFrame(y int) bool {
+	c := s.col
+	i := s.frame
+	if i >= len(c.frames) {
+		return false
+	}
+	above, below := c.frames[i-1], c.frames[i]
+	min := above.bounds().Min.Y + above.minHeight() + borderWidth
+	max := below.bounds().Max.Y - below.minHeight()
+	if max < min {
+		return false
+	}
+	y = clampInt(y, min, max)
+	if y == below.bounds().Min.Y {
+		return false
+	}
+	c.ys[i] = float64(y-c.Min.Y) / float64(c.Dy())
+	c.setBounds(c.bounds())
+	return true
+}
+
+func clampInt(x, min, max int) int {
+	switch {
+	case x < min:
+		return min
+	case x > max:
+		return max
+	}
+	return x
+}
+
+func (s *splitter) drawLast(screen.Screen, screen.Window) {}
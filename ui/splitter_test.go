@@ -0,0 +1,144 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"testing"
+
+	"github.com/eaburns/T/ui/cursor"
+	"github.com/eaburns/T/ui/headless"
+	"golang.org/x/mobile/event/mouse"
+)
+
+func TestSplitterColumn(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	col0, col1 := w.columns[0], w.columns[1]
+
+	from := image.Pt(col1.Min.X-borderWidth, 300)
+	mouseTo(w, from)
+	wait(w)
+	var sp *splitter
+	w.Send(func() { sp, _ = w.inFocus.(*splitter) })
+	wait(w)
+	if sp == nil || sp.col != col1 || sp.frame != 0 {
+		t.Fatalf("inFocus=%#v, want the splitter left of column 1", sp)
+	}
+
+	to := image.Pt((col0.Min.X+col1.Max.X)/2, 300)
+	splitterDrag(w, from, to)
+	var x int
+	w.Send(func() { x = col1.Min.X })
+	wait(w)
+	if x != to.X {
+		t.Errorf("after drag, column 1 Min.X=%d, want %d", x, to.X)
+	}
+
+	// Columns keep their minimum width.
+	splitterDrag(w, image.Pt(to.X-borderWidth, 300), image.Pt(0, 300))
+	var x0 int
+	w.Send(func() { x, x0 = col1.Min.X, col0.Min.X })
+	wait(w)
+	if want := x0 + w.px(minFrameWidth) + borderWidth; x != want {
+		t.Errorf("after drag to 0, column 1 Min.X=%d, want %d", x, want)
+	}
+}
+
+func TestSplitterFrame(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	c := w.columns[0]
+	sheet0 := c.frames[1].(*sheet)
+	sheet1 := c.frames[2].(*sheet)
+
+	// The border below the column tag is not a splitter.
+	mouseTo(w, image.Pt(c.Min.X+10, sheet0.Min.Y-borderWidth))
+	wait(w)
+	var ok bool
+	w.Send(func() { _, ok = w.inFocus.(*splitter) })
+	wait(w)
+	if ok {
+		t.Errorf("the border below the column tag is in focus as a splitter")
+	}
+
+	from := image.Pt(c.Min.X+10, sheet1.Min.Y-borderWidth)
+	splitterDrag(w, from, image.Pt(from.X, 400))
+	var y int
+	w.Send(func() { y = sheet1.Min.Y })
+	wait(w)
+	if y != 400 {
+		t.Errorf("after drag, sheet1 Min.Y=%d, want 400", y)
+	}
+
+	// Sheets keep their minimum height.
+	splitterDrag(w, image.Pt(from.X, 400-borderWidth), image.Pt(from.X, 10000))
+	var max int
+	w.Send(func() { y, max = sheet1.Min.Y, sheet1.Max.Y-sheet1.minHeight() })
+	wait(w)
+	if y != max {
+		t.Errorf("after drag to the bottom, sheet1 Min.Y=%d, want %d", y, max)
+	}
+}
+
+func TestSplitterCorner(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	col0, col1 := w.columns[0], w.columns[1]
+	sheet1 := col1.frames[2].(*sheet)
+
+	from := image.Pt(col1.Min.X-borderWidth, sheet1.Min.Y-borderWidth)
+	mouseTo(w, from)
+	wait(w)
+	var sp *splitter
+	w.Send(func() { sp, _ = w.inFocus.(*splitter) })
+	wait(w)
+	if sp == nil || sp.col != col1 || sp.frame != 0 || sp.corner == nil || sp.corner.col != col1 || sp.corner.frame != 2 {
+		t.Fatalf("inFocus=%#v, want the corner of column 1 and its sheet 1", sp)
+	}
+
+	to := image.Pt((col0.Min.X+col1.Max.X)/2, 400)
+	splitterDrag(w, from, to)
+	var x, y int
+	w.Send(func() { x, y = col1.Min.X, sheet1.Min.Y })
+	wait(w)
+	if x != to.X || y != to.Y {
+		t.Errorf("after drag, column 1 Min.X=%d, sheet 1 Min.Y=%d, want %d, %d", x, y, to.X, to.Y)
+	}
+}
+
+func TestSplitterCursor(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	hw := w.Window.(*headless.Window)
+	c := w.columns[0]
+	sheet1 := c.frames[2].(*sheet)
+	col1 := w.columns[1]
+	sheet2 := col1.frames[2].(*sheet)
+
+	tests := []struct {
+		p    image.Point
+		want cursor.Shape
+	}{
+		{p: image.Pt(c.Min.X+10, sheet1.Min.Y-borderWidth), want: cursor.ResizeNS},
+		{p: image.Pt(c.Min.X+10, sheet1.Min.Y+20), want: cursor.Arrow},
+		{p: image.Pt(col1.Min.X-borderWidth, 300), want: cursor.ResizeEW},
+		{p: image.Pt(col1.Min.X-borderWidth, sheet2.Min.Y-borderWidth), want: cursor.ResizeAll},
+		{p: image.Pt(col1.Min.X+10, sheet2.Min.Y+20), want: cursor.Arrow},
+	}
+	for _, test := range tests {
+		mouseTo(w, test.p)
+		wait(w)
+		if got := hw.Cursor(); got != test.want {
+			t.Errorf("cursor at %v=%v, want %v", test.p, got, test.want)
+		}
+	}
+}
+
+func splitterDrag(w *window, from, to image.Point) {
+	mouseTo(w, from)
+	w.Send(mouse.Event{X: float32(from.X), Y: float32(from.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	w.Send(mouse.Event{X: float32(to.X), Y: float32(to.Y)})
+	w.Send(mouse.Event{X: float32(to.X), Y: float32(to.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	wait(w)
+}
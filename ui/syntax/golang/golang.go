@@ -0,0 +1,72 @@
+// Copyright © 2016, The T Authors.
+
+// Package golang provides a syntax.Tokenizer for Go source code.
+//
+// Importing the package registers its Tokenizer
+// for file names ending in .go.
+package golang
+
+import (
+	"go/scanner"
+	"go/token"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/syntax"
+)
+
+func init() { syntax.Register(".go", Tokenizer{}) }
+
+// A Tokenizer tokenizes Go source code.
+// It returns tokens for keywords, string and rune literals,
+// numeric literals, and comments.
+type Tokenizer struct{}
+
+// Tokenize implements syntax.Tokenizer.Tokenize.
+func (Tokenizer) Tokenize(src []byte, f func(syntax.Token) bool) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	// Errors are ignored; the source is often incomplete while editing.
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	// R is the rune offset of byte offset b.
+	var r int64
+	var b int
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return
+		}
+		class, ok := classOf(tok)
+		if !ok {
+			continue
+		}
+		start := file.Offset(pos)
+		end := start + len(lit)
+		if end > len(src) {
+			end = len(src)
+		}
+		r += int64(utf8.RuneCount(src[b:start]))
+		n := int64(utf8.RuneCount(src[start:end]))
+		if !f(syntax.Token{Span: edit.Span{r, r + n}, Class: class}) {
+			return
+		}
+		r += n
+		b = end
+	}
+}
+
+func classOf(tok token.Token) (syntax.Class, bool) {
+	switch {
+	case tok.IsKeyword():
+		return syntax.Keyword, true
+	case tok == token.STRING || tok == token.CHAR:
+		return syntax.String, true
+	case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+		return syntax.Number, true
+	case tok == token.COMMENT:
+		return syntax.Comment, true
+	}
+	return 0, false
+}
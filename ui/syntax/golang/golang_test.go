@@ -0,0 +1,81 @@
+// Copyright © 2016, The T Authors.
+
+package golang
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/syntax"
+)
+
+func TestTokenize(t *testing.T) {
+	const src = `package main
+
+// Main says hello.
+func main() {
+	s := "αβγ"
+	for i := 0; i < 1.5; i++ {
+		print(s, 'x') /* done */
+	}
+}
+`
+	var got []syntax.Token
+	Tokenizer{}.Tokenize([]byte(src), func(tok syntax.Token) bool {
+		got = append(got, tok)
+		return true
+	})
+	want := []struct {
+		text  string
+		class syntax.Class
+	}{
+		{"package", syntax.Keyword},
+		{"// Main says hello.", syntax.Comment},
+		{"func", syntax.Keyword},
+		{`"αβγ"`, syntax.String},
+		{"for", syntax.Keyword},
+		{"0", syntax.Number},
+		{"1.5", syntax.Number},
+		{"'x'", syntax.String},
+		{"/* done */", syntax.Comment},
+	}
+	rs := []rune(src)
+	var gotText []string
+	for _, tok := range got {
+		gotText = append(gotText, string(rs[tok.Span[0]:tok.Span[1]]))
+	}
+	var wantText []string
+	for _, w := range want {
+		wantText = append(wantText, w.text)
+	}
+	if !reflect.DeepEqual(gotText, wantText) {
+		t.Fatalf("token text=%q, want %q", gotText, wantText)
+	}
+	for i, tok := range got {
+		if tok.Class != want[i].class {
+			t.Errorf("token %q class=%d, want %d", gotText[i], tok.Class, want[i].class)
+		}
+	}
+}
+
+func TestTokenize_Stop(t *testing.T) {
+	var got []syntax.Token
+	Tokenizer{}.Tokenize([]byte("1 2 3"), func(tok syntax.Token) bool {
+		got = append(got, tok)
+		return len(got) < 2
+	})
+	want := []syntax.Token{
+		{Span: edit.Span{0, 1}, Class: syntax.Number},
+		{Span: edit.Span{2, 3}, Class: syntax.Number},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens=%v, want %v", got, want)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	if tok := syntax.Lookup("main.go"); tok != (Tokenizer{}) {
+		t.Errorf("syntax.Lookup(\"main.go\")=%v, want Tokenizer{}", tok)
+	}
+}
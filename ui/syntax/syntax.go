@@ -0,0 +1,157 @@
+// Copyright © 2016, The T Authors.
+
+// Package syntax provides incremental tokenizing of text
+// for syntax highlighting.
+//
+// Tokenizers for particular languages live in subpackages.
+// They register themselves by file name extension
+// when imported, for example:
+// 	import _ "github.com/eaburns/T/ui/syntax/golang"
+package syntax
+
+import (
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/eaburns/T/edit"
+)
+
+// A Class is the lexical class of a token.
+type Class int
+
+// The lexical classes.
+const (
+	Keyword Class = iota
+	String
+	Number
+	Comment
+)
+
+// A Token is a span of text with a lexical class.
+type Token struct {
+	// Span is the rune offsets of the token's text.
+	edit.Span
+	// Class is the lexical class of the token.
+	Class Class
+}
+
+// A Tokenizer splits text into tokens.
+type Tokenizer interface {
+	// Tokenize calls f with each token of src, in order.
+	// The Span of each token is relative to the beginning of src,
+	// and tokens do not overlap.
+	// Text that is not part of any token is not highlighted.
+	// Tokenize stops if f returns false.
+	//
+	// Tokenize is called with src beginning either
+	// at the beginning of the text
+	// or immediately after a token that it previously returned.
+	Tokenize(src []byte, f func(Token) bool)
+}
+
+var (
+	mu         sync.RWMutex
+	tokenizers = make(map[string]Tokenizer)
+)
+
+// Register registers a Tokenizer for file names with the given extension.
+// The extension includes the leading dot, for example ".go".
+func Register(ext string, t Tokenizer) {
+	mu.Lock()
+	tokenizers[ext] = t
+	mu.Unlock()
+}
+
+// Lookup returns the Tokenizer registered for the extension of a file name,
+// or nil if there is none.
+func Lookup(name string) Tokenizer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tokenizers[path.Ext(name)]
+}
+
+// A Highlighter maintains the tokens of a text as the text changes.
+//
+// When the text changes, only the tokens from just before the change
+// up to the first following token that is unaffected by the change
+// are re-tokenized.
+type Highlighter struct {
+	tokenizer Tokenizer
+	text      []rune
+	tokens    []Token
+}
+
+// NewHighlighter returns a new Highlighter for the text.
+func NewHighlighter(t Tokenizer, text []byte) *Highlighter {
+	h := &Highlighter{tokenizer: t, text: []rune(string(text))}
+	t.Tokenize(text, func(tok Token) bool {
+		h.tokens = append(h.tokens, tok)
+		return true
+	})
+	return h
+}
+
+// Size returns the size of the text in runes.
+func (h *Highlighter) Size() int64 { return int64(len(h.text)) }
+
+// Change changes a span of the text, in rune offsets, to the given text,
+// and updates the tokens.
+// The span must be within the text.
+func (h *Highlighter) Change(span edit.Span, text []byte) {
+	rs := []rune(string(text))
+	delta := int64(len(rs)) - span.Size()
+	newEnd := span[0] + int64(len(rs))
+	h.text = append(h.text[:span[0]], append(rs, h.text[span[1]:]...)...)
+
+	// Tokenizing restarts at the end of the last token before the change.
+	// A token ending at the start of the change may be extended by it,
+	// so it is re-tokenized.
+	i := sort.Search(len(h.tokens), func(i int) bool { return h.tokens[i].Span[1] >= span[0] })
+	var restart int64
+	if i > 0 {
+		restart = h.tokens[i-1].Span[1]
+	}
+	// Old are the tokens following the change.
+	j := sort.Search(len(h.tokens), func(j int) bool { return h.tokens[j].Span[0] >= span[1] })
+	old := h.tokens[j:]
+
+	var tokens []Token
+	tokens = append(tokens, h.tokens[:i]...)
+	synced := false
+	h.tokenizer.Tokenize([]byte(string(h.text[restart:])), func(tok Token) bool {
+		tok.Span = edit.Span{tok.Span[0] + restart, tok.Span[1] + restart}
+		for len(old) > 0 && old[0].Span[0]+delta < tok.Span[0] {
+			old = old[1:]
+		}
+		if tok.Span[0] >= newEnd && len(old) > 0 && shift(old[0], delta) == tok {
+			// The remaining tokens are unchanged but for their offsets.
+			synced = true
+			return false
+		}
+		tokens = append(tokens, tok)
+		return true
+	})
+	if synced {
+		for _, tok := range old {
+			tokens = append(tokens, shift(tok, delta))
+		}
+	}
+	h.tokens = tokens
+}
+
+func shift(tok Token, delta int64) Token {
+	tok.Span = edit.Span{tok.Span[0] + delta, tok.Span[1] + delta}
+	return tok
+}
+
+// Tokens returns the tokens that overlap the span, in order.
+// The returned slice must not be modified.
+func (h *Highlighter) Tokens(span edit.Span) []Token {
+	i := sort.Search(len(h.tokens), func(i int) bool { return h.tokens[i].Span[1] > span[0] })
+	j := sort.Search(len(h.tokens), func(j int) bool { return h.tokens[j].Span[0] >= span[1] })
+	if j < i {
+		j = i
+	}
+	return h.tokens[i:j]
+}
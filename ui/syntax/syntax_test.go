@@ -0,0 +1,142 @@
+// Copyright © 2016, The T Authors.
+
+package syntax
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/eaburns/T/edit"
+)
+
+// TestTokenizer is a Tokenizer for tests.
+// Strings are delimited by " and may span lines,
+// numbers are runs of digits,
+// and comments begin with # and continue to the end of the line.
+type testTokenizer struct{}
+
+func (testTokenizer) Tokenize(src []byte, f func(Token) bool) {
+	rs := []rune(string(src))
+	for i := 0; i < len(rs); {
+		start := i
+		var class Class
+		switch r := rs[i]; {
+		case r == '"':
+			class = String
+			for i++; i < len(rs) && rs[i] != '"'; i++ {
+			}
+			if i < len(rs) {
+				i++
+			}
+		case r >= '0' && r <= '9':
+			class = Number
+			for i < len(rs) && rs[i] >= '0' && rs[i] <= '9' {
+				i++
+			}
+		case r == '#':
+			class = Comment
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		default:
+			i++
+			continue
+		}
+		if !f(Token{Span: edit.Span{int64(start), int64(i)}, Class: class}) {
+			return
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	Register(".test", testTokenizer{})
+	defer func() {
+		mu.Lock()
+		delete(tokenizers, ".test")
+		mu.Unlock()
+	}()
+	if tok := Lookup("/a/b/c.test"); tok != (testTokenizer{}) {
+		t.Errorf("Lookup(\"/a/b/c.test\")=%v, want testTokenizer{}", tok)
+	}
+	if tok := Lookup("/a/b/c.none"); tok != nil {
+		t.Errorf("Lookup(\"/a/b/c.none\")=%v, want nil", tok)
+	}
+}
+
+func TestNewHighlighter(t *testing.T) {
+	h := NewHighlighter(testTokenizer{}, []byte("x 12 \"α\nβ\" # c"))
+	want := []Token{
+		{Span: edit.Span{2, 4}, Class: Number},
+		{Span: edit.Span{5, 10}, Class: String},
+		{Span: edit.Span{11, 14}, Class: Comment},
+	}
+	if got := h.Tokens(edit.Span{0, h.Size()}); !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens=%v, want %v", got, want)
+	}
+	if got := h.Tokens(edit.Span{4, 5}); len(got) != 0 {
+		t.Errorf("Tokens({4, 5})=%v, want []", got)
+	}
+	if got := h.Tokens(edit.Span{3, 6}); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("Tokens({3, 6})=%v, want %v", got, want[:2])
+	}
+}
+
+func TestChange(t *testing.T) {
+	tests := []struct {
+		text, change string
+		span         edit.Span
+	}{
+		{text: "", change: "123", span: edit.Span{0, 0}},
+		{text: "1 2 3", change: "", span: edit.Span{0, 5}},
+		{text: "1 2 3", change: "4", span: edit.Span{2, 2}},
+		{text: "1 2 3", change: "x", span: edit.Span{1, 2}},
+		{text: `a "b" c "d" e`, change: `"`, span: edit.Span{0, 0}},
+		{text: `a "b" c "d" e`, change: ``, span: edit.Span{2, 3}},
+		{text: "# a\n1 2\n# b", change: "", span: edit.Span{0, 1}},
+		{text: "1 # a\n2", change: "\n", span: edit.Span{3, 3}},
+	}
+	for _, test := range tests {
+		h := NewHighlighter(testTokenizer{}, []byte(test.text))
+		h.Change(test.span, []byte(test.change))
+		text := test.text[:test.span[0]] + test.change + test.text[test.span[1]:]
+		want := NewHighlighter(testTokenizer{}, []byte(text)).tokens
+		if !reflect.DeepEqual(h.tokens, want) {
+			t.Errorf("%q: Change(%v, %q) tokens=%v, want %v",
+				test.text, test.span, test.change, h.tokens, want)
+		}
+	}
+}
+
+func TestChange_Random(t *testing.T) {
+	const runes = "ab1 2#\n\"α"
+	rs := []rune(runes)
+	rng := rand.New(rand.NewSource(0))
+	randText := func(n int) string {
+		var s []rune
+		for i := 0; i < n; i++ {
+			s = append(s, rs[rng.Intn(len(rs))])
+		}
+		return string(s)
+	}
+
+	text := []rune(randText(100))
+	h := NewHighlighter(testTokenizer{}, []byte(string(text)))
+	for i := 0; i < 1000; i++ {
+		s0 := rng.Int63n(int64(len(text)) + 1)
+		s1 := s0 + rng.Int63n(int64(len(text))-s0+1)
+		change := randText(rng.Intn(5))
+		h.Change(edit.Span{s0, s1}, []byte(change))
+		text = append(text[:s0], append([]rune(change), text[s1:]...)...)
+
+		want := NewHighlighter(testTokenizer{}, []byte(string(text))).tokens
+		if !reflect.DeepEqual(h.tokens, want) {
+			t.Fatalf("change %d: Change(%v, %q) tokens=%v, want %v",
+				i, edit.Span{s0, s1}, change, h.tokens, want)
+		}
+		if h.Size() != int64(utf8.RuneCountInString(string(text))) {
+			t.Fatalf("change %d: Size()=%d, want %d", i, h.Size(), len(text))
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2016, The T Authors.
+
+package text
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// AtlasWidth is the width of a glyph atlas.
+	atlasWidth = 1024
+
+	// MinAtlasHeight and maxAtlasHeight are the initial and maximum heights
+	// of a glyph atlas.
+	// The atlas doubles in height when full, up to maxAtlasHeight,
+	// after which it is cleared.
+	minAtlasHeight = 64
+	maxAtlasHeight = 2048
+
+	// SubPixels is the number of horizontal sub-pixel positions
+	// at which a glyph is cached.
+	// Vertically, glyphs are cached at their exact position,
+	// since the baseline is the same for all glyphs of a line.
+	subPixels = 4
+)
+
+// SharedGlyphs is the glyph cache shared by all Setters.
+var sharedGlyphs glyphCache
+
+// A glyphCache caches rasterized glyph masks in an atlas image.
+//
+// The horizontal position of a glyph is rounded
+// to the nearest of subPixels positions.
+// For faces that round to the same sub-pixel positions,
+// such as those of the freetype package,
+// cached glyphs are exactly those drawn by the face.
+//
+// Glyphs are packed into the atlas left to right
+// in shelves as tall as their tallest glyph.
+type glyphCache struct {
+	mu    sync.Mutex
+	atlas *image.Alpha
+	faces map[faceKey]*faceGlyphs
+	// X and y are the next free point of the current shelf,
+	// and h is the height of the current shelf.
+	x, y, h int
+}
+
+type faceKey struct {
+	face font.Face
+	// Y is the fractional part of the vertical position of the glyphs.
+	y fixed.Int26_6
+}
+
+// FaceGlyphs are the cached glyphs of a single face
+// at a single fractional vertical position.
+type faceGlyphs struct {
+	faceKey
+	// ASCII are the glyphs of ASCII runes,
+	// indexed by rune and sub-pixel position.
+	ascii [128 * subPixels]glyph
+	// Other are the glyphs of all other runes.
+	other map[glyphKey]glyph
+}
+
+type glyphKey struct {
+	r   rune
+	sub int
+}
+
+type glyph struct {
+	// Dr is the bounds of the glyph relative to
+	// the integer pixel of its dot.
+	dr image.Rectangle
+	// Src is the upper-left of the glyph's mask in the atlas.
+	src image.Point
+	// Adv is the advance of the rune, as returned by advance.
+	adv fixed.Int26_6
+	// Cached is whether the glyph is in the cache.
+	// Ok is whether the face has a glyph for the rune.
+	cached, ok bool
+}
+
+// Face returns the cached glyphs of the face
+// with their dot at the vertical position y.
+// It must be called with c.mu held.
+func (c *glyphCache) face(face font.Face, y fixed.Int26_6) *faceGlyphs {
+	k := faceKey{face: face, y: y & 63}
+	fg, ok := c.faces[k]
+	if !ok {
+		if c.faces == nil {
+			c.faces = make(map[faceKey]*faceGlyphs)
+		}
+		fg = &faceGlyphs{faceKey: k}
+		c.faces[k] = fg
+	}
+	return fg
+}
+
+// Draw draws the glyph of the rune at the dot
+// to the image in the source color,
+// and returns the advance of the rune.
+// The fractional vertical position of the dot must be that of fg.
+// If the face has no glyph for the rune,
+// the glyph of unicode.ReplacementChar is drawn.
+// It must be called with c.mu held.
+func (c *glyphCache) draw(dst draw.Image, src image.Image, fg *faceGlyphs, dot fixed.Point26_6, r rune) fixed.Int26_6 {
+	dr, mask, maskp, adv, ok := c.glyph(fg, dot, r)
+	if !ok {
+		dr, mask, maskp, _, _ = c.glyph(fg, dot, unicode.ReplacementChar)
+	}
+	draw.DrawMask(dst, dr, src, image.ZP, mask, maskp, draw.Over)
+	return adv
+}
+
+// Reset clears the cache and releases its atlas.
+// It must be called with c.mu held.
+func (c *glyphCache) reset() {
+	// The faceGlyphs may still be referenced by callers,
+	// so they are cleared too.
+	for _, fg := range c.faces {
+		fg.ascii = [len(fg.ascii)]glyph{}
+		fg.other = nil
+	}
+	c.faces = nil
+	c.atlas = nil
+	c.x, c.y, c.h = 0, 0, 0
+}
+
+// Glyph is like font.Face.Glyph,
+// but the returned mask is the atlas of the cache,
+// and the returned advance is that used to lay out text.
+// The mask is only valid until the next call to glyph.
+// It must be called with c.mu held.
+func (c *glyphCache) glyph(fg *faceGlyphs, dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	const sub = 64 / subPixels
+	x := dot.X + sub/2
+	k := glyphKey{r: r, sub: int((x & 63) / sub)}
+	var g glyph
+	if r >= 0 && r < 128 {
+		g = fg.ascii[int(r)*subPixels+k.sub]
+	} else {
+		g = fg.other[k]
+	}
+	if !g.cached {
+		var ok bool
+		subDot := fixed.Point26_6{X: fixed.Int26_6(k.sub * sub), Y: fg.y}
+		if g, ok = c.add(fg, k, subDot); !ok {
+			// The glyph is too big for the atlas.
+			dr, mask, maskp, _, ok := fg.face.Glyph(dot, r)
+			return dr, mask, maskp, advance(&Style{Face: fg.face}, r), ok
+		}
+	}
+	p := image.Pt(x.Floor(), dot.Y.Floor())
+	return g.dr.Add(p), c.atlas, g.src, g.adv, g.ok
+}
+
+// Add rasterizes the glyph at the dot into the atlas and caches it.
+// False is returned if the glyph does not fit in the atlas.
+func (c *glyphCache) add(fg *faceGlyphs, k glyphKey, dot fixed.Point26_6) (glyph, bool) {
+	g := glyph{adv: advance(&Style{Face: fg.face}, k.r), cached: true}
+	dr, mask, maskp, _, ok := fg.face.Glyph(dot, k.r)
+	if ok {
+		size := dr.Size()
+		src, fits := c.alloc(size)
+		if !fits {
+			return glyph{}, false
+		}
+		draw.Draw(c.atlas, image.Rectangle{Min: src, Max: src.Add(size)}, mask, maskp, draw.Src)
+		g.dr, g.src, g.ok = dr, src, true
+	}
+	if k.r >= 0 && k.r < 128 {
+		fg.ascii[int(k.r)*subPixels+k.sub] = g
+	} else {
+		if fg.other == nil {
+			fg.other = make(map[glyphKey]glyph)
+		}
+		fg.other[k] = g
+	}
+	return g, true
+}
+
+// Alloc returns the upper-left of a free rectangle of the atlas
+// with the given size.
+// The atlas is grown or cleared if there is not enough room.
+// False is returned if the size is bigger than the atlas.
+func (c *glyphCache) alloc(size image.Point) (image.Point, bool) {
+	if size.X > atlasWidth || size.Y > maxAtlasHeight {
+		return image.ZP, false
+	}
+	if c.atlas == nil {
+		c.atlas = image.NewAlpha(image.Rect(0, 0, atlasWidth, minAtlasHeight))
+	}
+	if c.x+size.X > atlasWidth {
+		c.x, c.y, c.h = 0, c.y+c.h, 0
+	}
+	for c.y+size.Y > c.atlas.Bounds().Dy() {
+		h := c.atlas.Bounds().Dy() * 2
+		if h > maxAtlasHeight {
+			c.reset()
+			c.atlas = image.NewAlpha(image.Rect(0, 0, atlasWidth, maxAtlasHeight))
+			break
+		}
+		atlas := image.NewAlpha(image.Rect(0, 0, atlasWidth, h))
+		copy(atlas.Pix, c.atlas.Pix)
+		c.atlas = atlas
+	}
+	p := image.Pt(c.x, c.y)
+	c.x += size.X
+	if size.Y > c.h {
+		c.h = size.Y
+	}
+	return p, true
+}
@@ -0,0 +1,183 @@
+// Copyright © 2016, The T Authors.
+
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestGlyphCacheDraw(t *testing.T) {
+	faces := map[string]font.Face{
+		"basicfont": basicfont.Face7x13,
+		"goregular": goRegular(t),
+	}
+	for name, face := range faces {
+		opts := Options{
+			DefaultStyle: Style{Face: face, FG: color.Black, BG: color.White},
+			Size:         image.Pt(200, 200),
+			TabWidth:     4,
+		}
+		const text = "Hello, World!\n\tαβξ☃ ~`|{}"
+
+		cached := NewSetter(opts)
+		cached.glyphs = new(glyphCache)
+		uncached := NewSetter(opts)
+		uncached.glyphs = nil
+
+		// Draw twice, to draw from both a new and a warm cache.
+		for i := 0; i < 2; i++ {
+			cached.Add([]byte(text))
+			uncached.Add([]byte(text))
+			c, u := cached.Set(), uncached.Set()
+			for j := range c.lines {
+				size := image.Pt(c.lines[j].w.Ceil(), c.lines[j].h.Ceil())
+				got := image.NewRGBA(image.Rectangle{Max: size})
+				want := image.NewRGBA(image.Rectangle{Max: size})
+				drawLine(c, c.lines[j], got)
+				drawLine(u, u.lines[j], want)
+				if !equalImages(got, want) {
+					t.Errorf("%s draw %d: line %d drawn with the glyph cache differs", name, i, j)
+				}
+			}
+			c.Release()
+			u.Release()
+		}
+	}
+}
+
+func TestGlyphCacheFull(t *testing.T) {
+	const size = 100
+	face := &boxFace{size: size}
+	var c glyphCache
+	// Enough glyphs to fill the atlas, at its maximum height, twice.
+	n := 2 * (atlasWidth / size) * (maxAtlasHeight / size)
+	for r := rune(0); r < rune(n); r++ {
+		img := image.NewAlpha(image.Rect(0, 0, size, size))
+		c.draw(img, image.Opaque, c.face(face, 0), fixed.P(0, size), r)
+		for _, pt := range []image.Point{{0, 0}, {size - 1, size - 1}} {
+			if a := img.AlphaAt(pt.X, pt.Y).A; a != face.alpha(r) {
+				t.Fatalf("glyph %d alpha at %v=%d, want %d", r, pt, a, face.alpha(r))
+			}
+		}
+		if h := c.atlas.Bounds().Dy(); h > maxAtlasHeight {
+			t.Fatalf("glyph %d atlas height=%d, want ≤%d", r, h, maxAtlasHeight)
+		}
+	}
+	fg := c.face(face, 0)
+	cached := len(fg.other)
+	for _, g := range fg.ascii {
+		if g.cached {
+			cached++
+		}
+	}
+	if cached > n/2 {
+		t.Errorf("%d glyphs cached, want ≤%d", cached, n/2)
+	}
+
+	// Glyphs too big for the atlas are drawn, but not cached.
+	big := &boxFace{size: atlasWidth + 1}
+	img := image.NewAlpha(image.Rect(0, 0, big.size, big.size))
+	c.draw(img, image.Opaque, c.face(big, 0), fixed.P(0, big.size), 'x')
+	if a := img.AlphaAt(big.size-1, 0).A; a != big.alpha('x') {
+		t.Errorf("big glyph alpha=%d, want %d", a, big.alpha('x'))
+	}
+	if g := c.face(big, 0).ascii['x'*subPixels]; g.cached {
+		t.Errorf("big glyph is cached")
+	}
+}
+
+// BenchmarkDrawLinesGlyphCache benchmarks rasterizing
+// a frame of lines of text using the glyph cache.
+func BenchmarkDrawLinesGlyphCache(b *testing.B) {
+	benchmarkDrawLines(b, new(glyphCache))
+}
+
+// BenchmarkDrawLinesNoGlyphCache benchmarks rasterizing
+// a frame of lines of text without using the glyph cache.
+func BenchmarkDrawLinesNoGlyphCache(b *testing.B) {
+	benchmarkDrawLines(b, nil)
+}
+
+func benchmarkDrawLines(b *testing.B, c *glyphCache) {
+	face := goRegular(b)
+	s := NewSetter(Options{
+		DefaultStyle: Style{
+			Face: face,
+			FG:   color.Black,
+			BG:   color.White,
+		},
+		Size:     image.Pt(800, 600),
+		TabWidth: 4,
+	})
+	s.glyphs = c
+	for i := 0; i < 100; i++ {
+		s.Add([]byte(benchmarkLine))
+	}
+	txt := s.Set()
+	img := image.NewRGBA(image.Rect(0, 0, 800, face.Metrics().Height.Ceil()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, l := range txt.lines {
+			drawLine(txt, l, img)
+		}
+	}
+}
+
+func goRegular(tb testing.TB) font.Face {
+	ttf, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		tb.Fatalf("truetype.Parse(goregular.TTF)=_,%v", err)
+	}
+	return truetype.NewFace(ttf, &truetype.Options{Size: 11, DPI: 96})
+}
+
+func equalImages(a, b *image.RGBA) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	for y := a.Bounds().Min.Y; y < a.Bounds().Max.Y; y++ {
+		for x := a.Bounds().Min.X; x < a.Bounds().Max.X; x++ {
+			if a.RGBAAt(x, y) != b.RGBAAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// A boxFace is a font.Face with square glyphs
+// of a different alpha for each rune.
+type boxFace struct{ size int }
+
+func (f *boxFace) alpha(r rune) uint8 { return uint8(r%255) + 1 }
+
+func (*boxFace) Close() error { return nil }
+
+func (f *boxFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	x, y := dot.X.Floor(), dot.Y.Floor()
+	dr := image.Rect(x, y-f.size, x+f.size, y)
+	mask := image.NewAlpha(image.Rect(0, 0, f.size, f.size))
+	draw.Draw(mask, mask.Bounds(), image.NewUniform(color.Alpha{A: f.alpha(r)}), image.ZP, draw.Src)
+	return dr, mask, image.ZP, fixed.I(f.size), true
+}
+
+func (f *boxFace) GlyphAdvance(rune) (fixed.Int26_6, bool) { return fixed.I(f.size), true }
+
+func (*boxFace) Kern(rune, rune) fixed.Int26_6 { return 0 }
+
+func (f *boxFace) GlyphBounds(rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return fixed.R(0, -f.size, f.size, 0), fixed.I(f.size), true
+}
+
+func (f *boxFace) Metrics() font.Metrics {
+	return font.Metrics{Height: fixed.I(f.size), Ascent: fixed.I(f.size)}
+}
@@ -0,0 +1,188 @@
+// Copyright © 2016, The T Authors.
+
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// MaxOverviewLineHeight is the greatest height, in pixels,
+// of a line of text in an Overview.
+const maxOverviewLineHeight = 2
+
+// An Overview is a downsampled rendering of an entire text,
+// used to draw a miniature of the text in little space.
+//
+// Each rune of the text is one pixel wide, and tabs are expanded.
+// Each line of the text is drawn as a single row of pixels,
+// with the runs of non-space runes on the line filled.
+// If there is room, lines are spaced apart;
+// otherwise, many lines share a row,
+// and the row is the union of their runs.
+//
+// An Overview is cheap to make compared to laying out the text,
+// since it never measures or rasterizes glyphs.
+type Overview struct {
+	size  image.Point
+	scale float64
+	// Starts are the rune offsets of the start of each line.
+	starts []int64
+	// Rows are the runs of non-space runes of each row,
+	// as sorted, non-overlapping [x0, x1) pixel spans.
+	rows [][][2]int
+}
+
+// NewOverview returns a new Overview of the text
+// to be drawn in a rectangle of the given size.
+// The tab width is in runes.
+func NewOverview(text []byte, tabWidth int, size image.Point) *Overview {
+	if tabWidth < 1 {
+		tabWidth = 1
+	}
+	o := &Overview{size: size, starts: []int64{0}}
+	var r int64
+	for i := 0; i < len(text); {
+		c, w := utf8.DecodeRune(text[i:])
+		i += w
+		r++
+		if c == '\n' && i < len(text) {
+			o.starts = append(o.starts, r)
+		}
+	}
+
+	o.scale = maxOverviewLineHeight
+	if n := float64(len(o.starts)); n*o.scale > float64(size.Y) {
+		o.scale = float64(size.Y) / n
+	}
+	if size.Y <= 0 {
+		return o
+	}
+	o.rows = make([][][2]int, size.Y)
+
+	line, x, x0 := 0, 0, -1
+	end := func() {
+		if x0 < 0 {
+			return
+		}
+		if x0 < size.X {
+			x1 := x
+			if x1 > size.X {
+				x1 = size.X
+			}
+			y := o.RowOf(line)
+			o.rows[y] = append(o.rows[y], [2]int{x0, x1})
+		}
+		x0 = -1
+	}
+	for len(text) > 0 {
+		c, w := utf8.DecodeRune(text)
+		text = text[w:]
+		switch {
+		case c == '\n':
+			end()
+			line++
+			x = 0
+		case c == '\t':
+			end()
+			x += tabWidth - x%tabWidth
+		case unicode.IsSpace(c):
+			end()
+			x++
+		default:
+			if x0 < 0 {
+				x0 = x
+			}
+			x++
+		}
+	}
+	end()
+	if o.scale < 1 {
+		for i, row := range o.rows {
+			o.rows[i] = mergeSpans(row)
+		}
+	}
+	return o
+}
+
+// MergeSpans returns the union of the spans
+// as sorted, non-overlapping spans.
+func mergeSpans(spans [][2]int) [][2]int {
+	if len(spans) < 2 {
+		return spans
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp[0] > last[1] {
+			merged = append(merged, sp)
+		} else if sp[1] > last[1] {
+			last[1] = sp[1]
+		}
+	}
+	return merged
+}
+
+// Size returns the size of the Overview.
+func (o *Overview) Size() image.Point { return o.size }
+
+// NumLines returns the number of lines of the text.
+func (o *Overview) NumLines() int { return len(o.starts) }
+
+// RowOf returns the row of the line with the given index,
+// counting from 0.
+func (o *Overview) RowOf(line int) int {
+	y := int(float64(line) * o.scale)
+	if y >= o.size.Y {
+		y = o.size.Y - 1
+	}
+	if y < 0 {
+		y = 0
+	}
+	return y
+}
+
+// LineAt returns the index of the first line drawn at or below row y.
+func (o *Overview) LineAt(y int) int {
+	if y <= 0 {
+		return 0
+	}
+	line := int(math.Ceil(float64(y) / o.scale))
+	if line >= len(o.starts) {
+		line = len(o.starts) - 1
+	}
+	return line
+}
+
+// LineOf returns the index of the line containing the rune offset.
+func (o *Overview) LineOf(r int64) int {
+	return sort.Search(len(o.starts), func(i int) bool { return o.starts[i] > r }) - 1
+}
+
+// LineStart returns the rune offset of the start of the line
+// with the given index.
+func (o *Overview) LineStart(line int) int64 { return o.starts[line] }
+
+// Row returns the runs of non-space runes drawn in row y,
+// as sorted, non-overlapping [x0, x1) pixel spans.
+func (o *Overview) Row(y int) [][2]int { return o.rows[y] }
+
+// Draw draws the runs of the Overview in the color,
+// with its top-left corner at the given point.
+// The background is not drawn.
+func (o *Overview) Draw(at image.Point, c color.Color, win screen.Window) {
+	for y, row := range o.rows {
+		for _, sp := range row {
+			r := image.Rect(sp[0], y, sp[1], y+1).Add(at)
+			win.Fill(r, c, draw.Over)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2016, The T Authors.
+
+package text
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverview(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		size  image.Point
+		lines int
+		rows  map[int][][2]int
+	}{
+		{
+			name:  "empty",
+			text:  "",
+			size:  image.Pt(10, 10),
+			lines: 1,
+			rows:  map[int][][2]int{},
+		},
+		{
+			name:  "words",
+			text:  "ab cd\n  efg\n",
+			size:  image.Pt(10, 10),
+			lines: 2,
+			rows: map[int][][2]int{
+				0: {{0, 2}, {3, 5}},
+				2: {{2, 5}},
+			},
+		},
+		{
+			name:  "tabs",
+			text:  "\tx\ty",
+			size:  image.Pt(10, 10),
+			lines: 1,
+			rows:  map[int][][2]int{0: {{4, 5}, {8, 9}}},
+		},
+		{
+			name:  "clipped",
+			text:  "abc defghijkl\n",
+			size:  image.Pt(6, 10),
+			lines: 1,
+			rows:  map[int][][2]int{0: {{0, 3}, {4, 6}}},
+		},
+		{
+			name:  "blank lines",
+			text:  "a\n\n\nb\n",
+			size:  image.Pt(10, 10),
+			lines: 4,
+			rows: map[int][][2]int{
+				0: {{0, 1}},
+				6: {{0, 1}},
+			},
+		},
+		{
+			name:  "lines share rows",
+			text:  "ab\n   cd\nx\nyyyyy\n",
+			size:  image.Pt(10, 2),
+			lines: 4,
+			rows: map[int][][2]int{
+				0: {{0, 2}, {3, 5}},
+				1: {{0, 5}},
+			},
+		},
+	}
+	for _, test := range tests {
+		o := NewOverview([]byte(test.text), 4, test.size)
+		if n := o.NumLines(); n != test.lines {
+			t.Errorf("%s: NumLines()=%d, want %d", test.name, n, test.lines)
+		}
+		for y := 0; y < test.size.Y; y++ {
+			if got, want := o.Row(y), test.rows[y]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: Row(%d)=%v, want %v", test.name, y, got, want)
+			}
+		}
+	}
+}
+
+func TestOverviewLines(t *testing.T) {
+	text := strings.Repeat("line\n", 100)
+	o := NewOverview([]byte(text), 4, image.Pt(10, 50))
+	for _, test := range []struct {
+		line, row int
+	}{{0, 0}, {1, 0}, {2, 1}, {99, 49}} {
+		if y := o.RowOf(test.line); y != test.row {
+			t.Errorf("RowOf(%d)=%d, want %d", test.line, y, test.row)
+		}
+	}
+	for _, test := range []struct {
+		row, line int
+	}{{-1, 0}, {0, 0}, {1, 2}, {49, 98}, {60, 99}} {
+		if l := o.LineAt(test.row); l != test.line {
+			t.Errorf("LineAt(%d)=%d, want %d", test.row, l, test.line)
+		}
+	}
+	for _, test := range []struct {
+		r    int64
+		line int
+	}{{0, 0}, {4, 0}, {5, 1}, {499, 99}, {1000, 99}} {
+		if l := o.LineOf(test.r); l != test.line {
+			t.Errorf("LineOf(%d)=%d, want %d", test.r, l, test.line)
+		}
+	}
+	if s := o.LineStart(3); s != 15 {
+		t.Errorf("LineStart(3)=%d, want 15", s)
+	}
+}
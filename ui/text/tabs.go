@@ -0,0 +1,166 @@
+// Copyright © 2016, The T Authors.
+
+package text
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// An elasticLine is a line of text, ending at a newline,
+// laid out with elastic tabs.
+type elasticLine struct {
+	runs []Run
+	// Cells are the widths of the text before each tab of the line.
+	cells []fixed.Int26_6
+	// Tabs are the tab stops of the line.
+	tabs []fixed.Int26_6
+}
+
+// AddElastic adds the runs to the Setter with elastic tab stops.
+// It returns false if the text did not fit in the Setter's height.
+func (s *Setter) addElastic(runs []Run) bool {
+	defer func() { s.tabs = nil }()
+	lines := elasticLines(&s.opts.DefaultStyle, runs)
+	setTabs(s, lines)
+	for _, l := range lines {
+		s.tabs = l.tabs
+		if !addRuns(s, l.runs) {
+			return false
+		}
+	}
+	return true
+}
+
+// ElasticLines splits the runs into lines, ending at newlines,
+// and measures the cells of each line.
+func elasticLines(def *Style, runs []Run) []elasticLine {
+	var lines []elasticLine
+	var l elasticLine
+	// X is the width of the current cell,
+	// and prev and prevSty are the rune before x and its style.
+	var x fixed.Int26_6
+	var prev rune
+	var prevSty *Style
+	for _, r := range runs {
+		sty := r.Style
+		if sty == nil {
+			sty = def
+		}
+		text := r.Text
+		for i := 0; i < len(text); {
+			c, w := utf8.DecodeRune(text[i:])
+			i += w
+			switch c {
+			case '\n':
+				l.runs = append(l.runs, Run{Style: sty, Text: text[:i]})
+				text, i = text[i:], 0
+				lines = append(lines, l)
+				l = elasticLine{}
+				x, prevSty = 0, nil
+			case '\t':
+				l.cells = append(l.cells, x)
+				x, prevSty = 0, nil
+			default:
+				x += advance(sty, c)
+				if prevSty != nil && prevSty.Face == sty.Face {
+					x += sty.Face.Kern(prev, c)
+				}
+				prev, prevSty = c, sty
+			}
+		}
+		if len(text) > 0 {
+			l.runs = append(l.runs, Run{Style: sty, Text: text})
+		}
+	}
+	if len(l.runs) > 0 {
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// SetTabs sets the tab stops of the lines.
+//
+// The kth cells of a block of consecutive lines
+// that all have a kth cell form a column.
+// The tab stop ending a column is a space-width
+// after the end of its widest cell,
+// but the column is at least TabWidth space-widths wide.
+func setTabs(s *Setter, lines []elasticLine) {
+	sp := advance(&s.opts.DefaultStyle, ' ')
+	min := sp * fixed.Int26_6(s.opts.TabWidth)
+	for k := 0; ; k++ {
+		more := false
+		for i := 0; i < len(lines); {
+			if len(lines[i].cells) <= k {
+				i++
+				continue
+			}
+			more = true
+			j := i
+			w := min
+			for ; j < len(lines) && len(lines[j].cells) > k; j++ {
+				if c := lines[j].cells[k] + sp; c > w {
+					w = c
+				}
+			}
+			for ; i < j; i++ {
+				var x0 fixed.Int26_6
+				if k > 0 {
+					x0 = lines[i].tabs[k-1]
+				}
+				lines[i].tabs = append(lines[i].tabs, x0+w)
+			}
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// ReplaceElastic is Replace for a Text with elastic tabs.
+func (t *Text) replaceElastic(start, end int, runs []Run) {
+	if start < 0 {
+		start = 0
+	}
+	if start > end {
+		start = end
+	}
+	var all []Run
+	var n int
+	added := false
+	for _, l := range t.lines {
+		for _, sp := range l.spans {
+			sty := sp.Style
+			text := []byte(sp.text)
+			if n < start {
+				k := start - n
+				if k > len(text) {
+					k = len(text)
+				}
+				all = append(all, Run{Style: &sty, Text: text[:k]})
+			}
+			if !added && n+len(text) >= start {
+				all = append(all, runs...)
+				added = true
+			}
+			if n+len(text) > end {
+				k := end - n
+				if k < 0 {
+					k = 0
+				}
+				all = append(all, Run{Style: &sty, Text: text[k:]})
+			}
+			n += len(text)
+		}
+	}
+	if !added {
+		all = append(all, runs...)
+	}
+
+	s := &Setter{opts: t.setter.opts}
+	s.addElastic(all)
+	reuseBufs(s.lines, t.lines)
+	t.lines = s.lines
+}
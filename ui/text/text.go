@@ -25,12 +25,17 @@
 // The new Text re-uses pre-rendered lines from the old text.
 // In the common case, where little changed,
 // drawing the new Text is very efficient.
+//
+// Alternatively, when a small part of the text changes,
+// Text.Replace replaces it in place,
+// laying out only the lines affected by the change.
 package text
 
 import (
 	"image"
 	"image/color"
 	"image/draw"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -39,12 +44,41 @@ import (
 	"golang.org/x/image/math/fixed"
 )
 
-// A Style describes a font face and colors.
+// A Style describes a font face, colors, and decorations.
 type Style struct {
 	Face   font.Face
 	FG, BG color.Color
+
+	// Underline is the kind of line drawn under the text.
+	Underline Underline
+
+	// UnderlineColor is the color of the underline.
+	// If UnderlineColor is nil, FG is used.
+	UnderlineColor color.Color
+
+	// Strikethrough is whether a line is drawn through the text
+	// in the FG color.
+	Strikethrough bool
+
+	// Border is the color of a box drawn around the text.
+	// If Border is nil, no box is drawn.
+	Border color.Color
 }
 
+// An Underline is a kind of line drawn under text.
+type Underline int
+
+const (
+	// NoUnderline draws no line.
+	NoUnderline Underline = iota
+
+	// SolidUnderline draws a straight line, as for links.
+	SolidUnderline
+
+	// WavyUnderline draws a squiggly line, as for misspellings.
+	WavyUnderline
+)
+
 // Options control text layout by a setter.
 type Options struct {
 	// Size is the size of the Text returned by Set.
@@ -60,22 +94,78 @@ type Options struct {
 	// between tab stops.
 	TabWidth int
 
+	// TabStops are the positions of the first tab stops,
+	// in increasing order,
+	// in DefaultStyle space-widths from the left of the text.
+	// Tab stops after the last of TabStops are TabWidth apart.
+	TabStops []int
+
+	// ElasticTabs is whether tab stops are elastic.
+	//
+	// With elastic tabs, the text of each line before a tab is a cell,
+	// and the cells of consecutive lines form columns.
+	// The tab stops of a column are at the end of its widest cell,
+	// so that the column is aligned.
+	// Tabs not ending a cell of a column use the TabStops and TabWidth.
+	ElasticTabs bool
+
 	// Padding is the number of pixels
 	// between the borders of Bounds
 	// and the Text.
 	Padding int
+
+	// Wrap is how lines too long to fit the width are broken.
+	Wrap Wrap
+
+	// Indent is the number of DefaultStyle space-widths
+	// by which lines continuing a broken line are indented.
+	Indent int
 }
 
+// A Wrap is a mode of breaking lines
+// that are too long to fit the width of a Text.
+type Wrap int
+
+const (
+	// WrapAnywhere breaks lines after the last rune that fits.
+	WrapAnywhere Wrap = iota
+
+	// WrapWords breaks lines after the last space that fits,
+	// or after the last rune that fits if the line has no such space.
+	// Spaces that do not fit remain at the end of the line.
+	WrapWords
+
+	// NoWrap breaks lines only at newlines.
+	// Lines too long to fit are clipped when drawn.
+	NoWrap
+)
+
 // A Setter lays out text to fit in a rectangle.
 type Setter struct {
 	opts              Options
 	lines, reuseLines []*line
+	// Glyphs caches the glyphs drawn by the Texts of the Setter.
+	// If glyphs is nil, glyphs are not cached.
+	// By default, it is the cache shared by all Setters.
+	glyphs *glyphCache
+	// Pending is the text added with ElasticTabs,
+	// which is laid out by Set.
+	pending []Run
+	// Tabs are the elastic tab stops of the lines being added.
+	tabs []fixed.Int26_6
 }
 
 type line struct {
 	spans   []*span
 	w, h, a fixed.Int26_6
-	buf     screen.Buffer
+	// X0 is the x coordinate of the start of the line.
+	// It is non-zero for indented lines continuing a broken line.
+	x0 fixed.Int26_6
+	// Full is whether the line ends with runes that did not fit.
+	full bool
+	// Tabs are the elastic tab stops of the line.
+	tabs []fixed.Int26_6
+	buf  screen.Buffer
 }
 
 type span struct {
@@ -85,7 +175,7 @@ type span struct {
 }
 
 // NewSetter returns a new Setter.
-func NewSetter(opts Options) *Setter { return &Setter{opts: opts} }
+func NewSetter(opts Options) *Setter { return &Setter{opts: opts, glyphs: &sharedGlyphs} }
 
 // Release releases the resources of the Setter.
 //
@@ -101,12 +191,25 @@ func (s *Setter) Release() {
 // Reset clears any added lines, and resets the setter with new Options.
 func (s *Setter) Reset(opts Options) {
 	s.lines = s.lines[:0]
+	s.pending = nil
+	s.tabs = nil
 	s.opts = opts
 }
 
-// Tab returns the next tab stop.
-func (s *Setter) tab(x fixed.Int26_6) fixed.Int26_6 {
+// Tab returns the next tab stop after x on the line.
+// The tab stop is at least a space-width after x.
+func (s *Setter) tab(l *line, x fixed.Int26_6) fixed.Int26_6 {
 	sp := advance(&s.opts.DefaultStyle, ' ')
+	for _, t := range l.tabs {
+		if t-x >= sp {
+			return t
+		}
+	}
+	for _, n := range s.opts.TabStops {
+		if t := sp * fixed.Int26_6(n); t-x >= sp {
+			return t
+		}
+	}
 	w := sp * fixed.Int26_6(s.opts.TabWidth)
 	t := w - (x % w) + x
 	if t-x < sp {
@@ -120,44 +223,90 @@ func (s *Setter) Add(text []byte) { s.AddStyle(&s.opts.DefaultStyle, text) }
 
 // AddStyle adds text to the Setter using the given style.
 func (s *Setter) AddStyle(sty *Style, text []byte) {
-	if len(text) == 0 {
+	if s.opts.ElasticTabs {
+		// Elastic tab stops depend on the following lines,
+		// so the text is laid out by Set.
+		sty := *sty
+		s.pending = append(s.pending, Run{Style: &sty, Text: append([]byte{}, text...)})
 		return
 	}
+	s.add(sty, text)
+}
+
+// Add adds text to the Setter using the given style,
+// and returns whether all of the text fit.
+func (s *Setter) add(sty *Style, text []byte) bool {
+	if len(text) == 0 {
+		return true
+	}
 
+	// H is the height of the lines before the last line.
+	// The last line is kept as long as h does not exceed ymax.
 	ymax := fixed.I(s.opts.Size.Y - 2*s.opts.Padding)
 	var h fixed.Int26_6
-	for _, l := range s.lines {
-		h += l.h
+	if len(s.lines) > 0 {
+		for _, l := range s.lines[:len(s.lines)-1] {
+			h += l.h
+		}
 	}
 	if h > ymax {
-		return
+		return false
 	}
 
 	m := s.opts.DefaultStyle.Face.Metrics()
 	if len(s.lines) == 0 {
-		s.lines = append(s.lines, &line{h: m.Height, a: m.Ascent})
+		s.lines = append(s.lines, &line{h: m.Height, a: m.Ascent, tabs: s.tabs})
 	}
 	for len(text) > 0 {
-		text = add1(s, sty, text)
-		if len(text) > 0 {
-			h += s.lines[len(s.lines)-1].h
-			if h > ymax {
-				break
+		var carry []*span
+		text, carry = add1(s, sty, text)
+		if len(text) == 0 {
+			break
+		}
+		last := s.lines[len(s.lines)-1]
+		h += last.h
+		if h > ymax {
+			return false
+		}
+		l := &line{h: m.Height, a: m.Ascent, tabs: s.tabs}
+		if r, ok := lastRune(last); !ok || r != '\n' {
+			l.x0 = advance(&s.opts.DefaultStyle, ' ') * fixed.Int26_6(s.opts.Indent)
+		}
+		s.lines = append(s.lines, l)
+
+		// Words carried over from the broken line begin the new line.
+		if len(carry) == 0 {
+			continue
+		}
+		for _, sp := range carry {
+			sty := sp.Style
+			if !s.add(&sty, []byte(sp.text)) {
+				return false
 			}
-			s.lines = append(s.lines, &line{h: m.Height, a: m.Ascent})
+		}
+		h = 0
+		for _, l := range s.lines[:len(s.lines)-1] {
+			h += l.h
 		}
 	}
+	return true
 }
 
-func add1(s *Setter, sty *Style, text []byte) []byte {
+// Add1 adds text to the last line until the line is full,
+// and returns the text that did not fit.
+// If the line is broken at a space of one of its earlier spans,
+// the runes following the space are removed from the line
+// and returned as spans to be added to the next line,
+// before the returned text.
+func add1(s *Setter, sty *Style, text []byte) ([]byte, []*span) {
 	l := s.lines[len(s.lines)-1]
-	var x0 fixed.Int26_6
+	x0 := l.x0
 	width := fixed.I(s.opts.Size.X - 2*s.opts.Padding)
 	if len(l.spans) > 0 && len(l.spans[len(l.spans)-1].text) > 0 {
 		lastSpan := l.spans[len(l.spans)-1]
 		lastText := lastSpan.text
 		if r, _ := utf8.DecodeLastRuneInString(lastText); r == '\n' {
-			return text
+			return text, nil
 		}
 		x0 = lastSpan.x1
 		if len(text) > 0 && lastSpan.Face == sty.Face {
@@ -170,6 +319,10 @@ func add1(s *Setter, sty *Style, text []byte) []byte {
 	}
 	sp := &span{Style: *sty, x0: x0, x1: x0}
 	var start, i int
+	// Brk is the index in text just after the last space,
+	// and brkX is its x coordinate, or brk is 0 if there is no space.
+	var brk int
+	var brkX fixed.Int26_6
 	for i < len(text) {
 		r, w := utf8.DecodeRune(text[i:])
 		adv := advance(sty, r)
@@ -178,24 +331,52 @@ func add1(s *Setter, sty *Style, text []byte) []byte {
 			adv += sty.Face.Kern(p, r)
 		}
 		if r == '\t' {
-			adv = s.tab(sp.x1) - sp.x1
+			adv = s.tab(l, sp.x1) - sp.x1
 		}
-		if r == '\n' || sp.x1+adv > width {
+		if r == '\n' || l.full || (sp.x1+adv > width && s.opts.Wrap != NoWrap) {
 			// Always add newline or non-fitting tabs to the end of the line,
 			// but ignore their width.
-			if r == '\n' || r == '\t' {
-				i += w
+			// When wrapping words, the same goes for non-fitting spaces.
+			// Following tabs or spaces, and a newline, do not fit either,
+			// so they are added too, even by later calls to add1.
+			if trailing(s, r) {
+				l.full = r != '\n'
+				for i += w; r != '\n' && i < len(text); i += w {
+					if r, w = utf8.DecodeRune(text[i:]); !trailing(s, r) {
+						break
+					}
+				}
+				break
 			}
 			// If the line is empty and the first rune doesn't fit, add it anyway,
 			// and bump the line width up so it's too wide to draw.
 			if len(l.spans) == 0 && i == 0 {
 				i += w
 				sp.x1 += adv
+				l.full = true
+				continue
+			}
+			if s.opts.Wrap != WrapWords {
+				break
+			}
+			if brk > 0 {
+				i, sp.x1 = brk, brkX
+				break
+			}
+			if carry := breakWords(s, l); carry != nil {
+				sp.text = string(text[start:i])
+				if len(sp.text) > 0 {
+					carry = append(carry, sp)
+				}
+				return text[i:], carry
 			}
 			break
 		}
 		i += w
 		sp.x1 += adv
+		if unicode.IsSpace(r) {
+			brk, brkX = i, sp.x1
+		}
 	}
 
 	m := sp.Face.Metrics()
@@ -208,7 +389,74 @@ func add1(s *Setter, sty *Style, text []byte) []byte {
 	l.w = sp.x1
 	sp.text = string(text[start:i])
 	l.spans = append(l.spans, sp)
-	return text[i:]
+	return text[i:], nil
+}
+
+// Trailing returns whether the rune is added
+// to the end of a line even if it does not fit.
+func trailing(s *Setter, r rune) bool {
+	return r == '\n' || r == '\t' || (s.opts.Wrap == WrapWords && unicode.IsSpace(r))
+}
+
+// BreakWords breaks the line after the last space in its spans.
+// The spans, or parts of spans, following the space
+// are removed from the line and returned.
+// If there is no space in the line, nil is returned.
+func breakWords(s *Setter, l *line) []*span {
+	for i := len(l.spans) - 1; i >= 0; i-- {
+		sp := l.spans[i]
+		j := strings.LastIndexFunc(sp.text, unicode.IsSpace)
+		if j < 0 {
+			continue
+		}
+		_, w := utf8.DecodeRuneInString(sp.text[j:])
+		j += w
+		if j == len(sp.text) && i == len(l.spans)-1 {
+			// The line already ends at the space.
+			return []*span{}
+		}
+
+		carry := []*span{}
+		if j < len(sp.text) {
+			carry = append(carry, &span{Style: sp.Style, text: sp.text[j:]})
+		}
+		carry = append(carry, l.spans[i+1:]...)
+		sp.text = sp.text[:j]
+		sp.x1 = s.measure(l, &sp.Style, sp.x0, sp.text)
+		l.spans = l.spans[:i+1]
+		l.w = sp.x1
+
+		m := s.opts.DefaultStyle.Face.Metrics()
+		l.h, l.a = m.Height, m.Ascent
+		for _, sp := range l.spans {
+			m := sp.Face.Metrics()
+			if m.Height > l.h {
+				l.h = m.Height
+			}
+			if m.Ascent > l.a {
+				l.a = m.Ascent
+			}
+		}
+		return carry
+	}
+	return nil
+}
+
+// Measure returns the x coordinate after the text
+// added to the line at x in the given style.
+func (s *Setter) measure(l *line, sty *Style, x fixed.Int26_6, text string) fixed.Int26_6 {
+	for i, r := range text {
+		if r == '\t' {
+			x = s.tab(l, x)
+			continue
+		}
+		x += advance(sty, r)
+		if i > 0 {
+			p, _ := utf8.DecodeLastRuneInString(text[:i])
+			x += sty.Face.Kern(p, r)
+		}
+	}
+	return x
 }
 
 func advance(sty *Style, r rune) fixed.Int26_6 {
@@ -226,17 +474,32 @@ func advance(sty *Style, r rune) fixed.Int26_6 {
 // that were released to the Setter
 // by the previous call to Text.Release.
 func (s *Setter) Set() *Text {
-	var h1 int
-	for _, line := range s.lines {
+	if s.opts.ElasticTabs {
+		s.addElastic(s.pending)
+		s.pending = nil
+	}
+	reuseBufs(s.lines, s.reuseLines)
+	t := &Text{setter: s, lines: s.lines, size: s.opts.Size}
+	s.lines = s.reuseLines[:0]
+	s.reuseLines = nil
+	return t
+}
+
+// ReuseBufs moves the buffers of the old lines
+// to the lines with the exact same spans,
+// and releases the remaining buffers of the old lines.
+func reuseBufs(lines, old []*line) {
+	for _, line := range lines {
 		// Find resue line with the exact same spans and reuse its buffer.
-		for _, reuseLine := range s.reuseLines {
+		for _, reuseLine := range old {
 			if reuseLine.buf == nil || len(reuseLine.spans) != len(line.spans) {
 				continue
 			}
 			match := true
 			for i, reuseSpan := range reuseLine.spans {
 				span := line.spans[i]
-				if reuseSpan.Style != span.Style || reuseSpan.text != span.text {
+				if reuseSpan.Style != span.Style || reuseSpan.text != span.text ||
+					reuseSpan.x0 != span.x0 || reuseSpan.x1 != span.x1 {
 					match = false
 					break
 				}
@@ -247,18 +510,13 @@ func (s *Setter) Set() *Text {
 				break
 			}
 		}
-		h1 += line.h.Round()
 	}
-	t := &Text{setter: s, lines: s.lines, size: s.opts.Size}
-	for _, l := range s.reuseLines {
+	for _, l := range old {
 		if l.buf != nil {
 			l.buf.Release()
 			l.buf = nil
 		}
 	}
-	s.lines = s.reuseLines[:0]
-	s.reuseLines = nil
-	return t
 }
 
 // A Text is a type-set text.
@@ -287,6 +545,195 @@ func (t *Text) Release() {
 	t.lines = nil
 }
 
+// A Run is text in a single style.
+type Run struct {
+	// Style is the style of the text.
+	// If Style is nil, the Setter's DefaultStyle is used.
+	Style *Style
+	Text  []byte
+}
+
+// Replace replaces the bytes in the range [start, end) of the Text
+// with the text of the runs.
+//
+// Only the lines from the start of the replaced range
+// up to the first line that begins at the same text as before
+// are laid out again.
+// The other lines, and their rasterization, are reused.
+// Like Set, the Text only contains the lines that fit its height.
+// Lines pushed out of the Text by the replacement are removed,
+// but text that was not added to the Setter is not brought in
+// if the replacement makes room for it.
+//
+// With ElasticTabs, an edit can change the tab stops of any line,
+// so all lines are laid out again.
+// Only the rasterization of unchanged lines is reused.
+func (t *Text) Replace(start, end int, runs []Run) {
+	opts := t.setter.opts
+	if opts.ElasticTabs {
+		t.replaceElastic(start, end, runs)
+		return
+	}
+	var n, li int
+	starts := make([]int, len(t.lines)+1)
+	for i, l := range t.lines {
+		starts[i] = n
+		if n <= start {
+			li = i
+		}
+		n += l.len()
+	}
+	starts[len(t.lines)] = n
+	if end > n {
+		end = n
+	}
+	if start > end {
+		start = end
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	// The break of the line before the replaced text
+	// may change if the text after the break changes.
+	// When wrapping words, the break may move back
+	// as far as the last newline.
+	for li > 0 && opts.Wrap != NoWrap {
+		if r, ok := lastRune(t.lines[li-1]); ok && r == '\n' {
+			break
+		}
+		li--
+		if opts.Wrap == WrapAnywhere {
+			break
+		}
+	}
+
+	// The new lines are added to a Setter
+	// whose first line stands in for the lines before li.
+	var h0 fixed.Int26_6
+	for _, l := range t.lines[:li] {
+		h0 += l.h
+	}
+	m := opts.DefaultStyle.Face.Metrics()
+	first := &line{h: m.Height, a: m.Ascent}
+	if li > 0 {
+		if r, ok := lastRune(t.lines[li-1]); !ok || r != '\n' {
+			first.x0 = advance(&opts.DefaultStyle, ' ') * fixed.Int26_6(opts.Indent)
+		}
+	}
+	s := &Setter{opts: opts, lines: []*line{{h: h0}, first}}
+
+	var runsLen int
+	for _, r := range runs {
+		runsLen += len(r.Text)
+	}
+	delta := runsLen - (end - start)
+
+	// Sync is the index of the first old line reused after the new lines,
+	// or len(t.lines) if none are reused.
+	sync := len(t.lines)
+	fit, added := true, false
+	for i := li; i < len(t.lines) && fit; i++ {
+		o := starts[i]
+		for _, sp := range t.lines[i].spans {
+			sty := sp.Style
+			text := []byte(sp.text)
+			if o < start {
+				k := start - o
+				if k > len(text) {
+					k = len(text)
+				}
+				fit = s.add(&sty, text[:k])
+			}
+			if fit && !added && o+len(text) >= start {
+				fit = addRuns(s, runs)
+				added = true
+			}
+			if fit && o+len(text) > end {
+				k := end - o
+				if k < 0 {
+					k = 0
+				}
+				fit = s.add(&sty, text[k:])
+			}
+			o += len(text)
+			if !fit {
+				break
+			}
+		}
+		if fit && i > li && starts[i] >= end {
+			if j := syncLine(s, starts[li], starts[i]+delta, t.lines[i].x0); j > 0 {
+				s.lines = s.lines[:j]
+				sync = i
+				break
+			}
+		}
+	}
+	if !added && fit {
+		addRuns(s, runs)
+	}
+	if len(first.spans) == 0 {
+		// Nothing was added.
+		s.lines = s.lines[:1]
+	}
+
+	for _, l := range t.lines[li:sync] {
+		if l.buf != nil {
+			l.buf.Release()
+			l.buf = nil
+		}
+	}
+	lines := append(append(t.lines[:li:li], s.lines[1:]...), t.lines[sync:]...)
+
+	// Remove lines pushed out of the bottom of the Text.
+	ymax := fixed.I(opts.Size.Y - 2*opts.Padding)
+	var h fixed.Int26_6
+	for i, l := range lines {
+		if h > ymax {
+			for _, l := range lines[i:] {
+				if l.buf != nil {
+					l.buf.Release()
+					l.buf = nil
+				}
+			}
+			lines = lines[:i]
+			break
+		}
+		h += l.h
+	}
+	t.lines = lines
+}
+
+// SyncLine returns the index of the line of the Setter,
+// other than its first two lines,
+// beginning at the given byte offset and x coordinate,
+// where the second line begins at byte offset n.
+// If there is no such line, 0 is returned.
+func syncLine(s *Setter, n, offs int, x0 fixed.Int26_6) int {
+	for j := 1; j < len(s.lines) && n <= offs; j++ {
+		if j > 1 && n == offs && s.lines[j].x0 == x0 {
+			return j
+		}
+		n += s.lines[j].len()
+	}
+	return 0
+}
+
+// AddRuns adds the runs to the Setter,
+// and returns whether all of the text fit.
+func addRuns(s *Setter, runs []Run) bool {
+	for _, r := range runs {
+		sty := r.Style
+		if sty == nil {
+			sty = &s.opts.DefaultStyle
+		}
+		if !s.add(sty, r.Text) {
+			return false
+		}
+	}
+	return true
+}
+
 // Index returns the byte index into the text
 // corresponding to the glyph at the given point.
 // The point 0,0 is the top left of the text.
@@ -329,7 +776,7 @@ func (t *Text) Index(p image.Point) int {
 			var r rune
 			r, w = utf8.DecodeRuneInString(sp.text[j:])
 			if r == '\t' {
-				x = t.setter.tab(x)
+				x = t.setter.tab(line, x)
 			} else {
 				x += advance(&sp.Style, r)
 				if j > 0 {
@@ -398,7 +845,7 @@ func (t *Text) GlyphBox(index int) image.Rectangle {
 			for j, r := range s.text {
 				var x1 fixed.Int26_6
 				if r == '\t' {
-					x1 = t.setter.tab(x0)
+					x1 = t.setter.tab(l, x0)
 				} else {
 					x1 = x0 + advance(&s.Style, r)
 					if j > 0 {
@@ -407,6 +854,10 @@ func (t *Text) GlyphBox(index int) image.Rectangle {
 					}
 				}
 				if j == index {
+					if t.setter.opts.Wrap == NoWrap && x0.Round() > t.size.X-2*pad {
+						// The glyph is clipped.
+						return image.ZR
+					}
 					return image.Rect(x0.Round()+pad, y, x1.Round()+pad, y+h)
 				}
 				x0 = x1
@@ -427,6 +878,84 @@ func (t *Text) GlyphBox(index int) image.Rectangle {
 	return image.Rect(x0.Round()+pad, y-h, x0.Round()+pad, y)
 }
 
+// NumLines returns the number of lines of the Text
+// that fit in its size.
+// Lines broken to fit the width of the Text are counted separately.
+func (t *Text) NumLines() int {
+	pad := t.setter.opts.Padding
+	if t.size.X <= 2*pad || t.size.Y <= 2*pad {
+		return 0
+	}
+	y := pad
+	for i, l := range t.lines {
+		y += l.h.Round()
+		if y > t.size.Y-pad {
+			return i
+		}
+	}
+	return len(t.lines)
+}
+
+// LineRange returns the byte indices [start, end)
+// of the text of the ith line.
+// If i is out of the range [0, NumLines()), start and end are equal,
+// and they are either 0 or the end of the text of the last line.
+func (t *Text) LineRange(i int) (start, end int) {
+	n := t.NumLines()
+	for j, l := range t.lines[:n] {
+		if j == i {
+			return start, start + l.len()
+		}
+		start += l.len()
+	}
+	if i < 0 {
+		return 0, 0
+	}
+	return start, start
+}
+
+// LineBox returns the bounding rectangle of the ith line,
+// relative to the upper-left of the text at point 0,0.
+// Horizontally, the bounds span the width of the Text between its padding.
+//
+// If i is out of the range [0, NumLines()),
+// the zero Rectangle is returned.
+func (t *Text) LineBox(i int) image.Rectangle {
+	if i < 0 || i >= t.NumLines() {
+		return image.ZR
+	}
+	pad := t.setter.opts.Padding
+	y := pad
+	for _, l := range t.lines[:i] {
+		y += l.h.Round()
+	}
+	return image.Rect(pad, y, t.size.X-pad, y+t.lines[i].h.Round())
+}
+
+// LineOf returns the index of the line containing the byte index.
+//
+// An index at the end of the text is on the last line,
+// unless the line ends with a newline,
+// in which case it is on the line following the text.
+// The returned line may be NumLines() or greater
+// if the index is not on a line that fits the Text.
+func (t *Text) LineOf(index int) int {
+	var n int
+	for i, l := range t.lines {
+		n += l.len()
+		if index < n {
+			return i
+		}
+	}
+	if len(t.lines) == 0 {
+		return 0
+	}
+	if r, ok := lastRune(t.lines[len(t.lines)-1]); (ok && r == '\n') || index > n {
+		return len(t.lines)
+	}
+	return len(t.lines) - 1
+}
+
 // Len returns the length of the line in bytes.
 func (l *line) len() int {
 	var n int
@@ -514,7 +1043,7 @@ func (t *Text) DrawLines(at image.Point, scr screen.Screen, win screen.Window) i
 			drawLine(t, l, l.buf.RGBA())
 		}
 		var dx int
-		if l.buf != nil && l.w <= fixed.I(textWidth) {
+		if l.buf != nil && (l.w <= fixed.I(textWidth) || t.setter.opts.Wrap == NoWrap) {
 			b := l.buf.Bounds()
 			if b.Dx() > textWidth {
 				b.Max.X = b.Min.X + textWidth
@@ -565,15 +1094,28 @@ func lastRune(l *line) (rune, bool) {
 }
 
 func drawLine(t *Text, l *line, img draw.Image) {
+	if l.x0 > 0 {
+		bg := image.NewUniform(t.setter.opts.DefaultStyle.BG)
+		draw.Draw(img, image.Rect(0, 0, l.x0.Round(), l.h.Round()), bg, image.ZP, draw.Src)
+	}
+	c := t.setter.glyphs
+	if c != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
 	for _, sp := range l.spans {
 		fg := image.NewUniform(sp.FG)
 		bg := image.NewUniform(sp.BG)
 		box := image.Rect(sp.x0.Round(), 0, sp.x1.Round(), l.h.Round())
 		draw.Draw(img, box, bg, image.ZP, draw.Src)
+		var glyphs *faceGlyphs
+		if c != nil {
+			glyphs = c.face(sp.Face, l.a)
+		}
 		x := sp.x0
 		for i, r := range sp.text {
 			if r == '\t' {
-				x = t.setter.tab(x)
+				x = t.setter.tab(l, x)
 				continue
 			}
 			if r == '\n' {
@@ -584,6 +1126,10 @@ func drawLine(t *Text, l *line, img draw.Image) {
 				x += sp.Face.Kern(p, r)
 			}
 			pt := fixed.Point26_6{X: x, Y: l.a}
+			if glyphs != nil {
+				x += c.draw(img, fg, glyphs, pt, r)
+				continue
+			}
 			dr, mask, maskp, _, ok := sp.Face.Glyph(pt, r)
 			if !ok {
 				dr, mask, maskp, _, _ = sp.Face.Glyph(pt, unicode.ReplacementChar)
@@ -592,4 +1138,69 @@ func drawLine(t *Text, l *line, img draw.Image) {
 			x += advance(&sp.Style, r)
 		}
 	}
+	for i := range l.spans {
+		drawDecorations(l, i, img)
+	}
+}
+
+// DrawDecorations draws the underline, strikethrough, and border
+// of the ith span of the line.
+// Adjacent spans of the same style share a single border.
+func drawDecorations(l *line, i int, img draw.Image) {
+	sp := l.spans[i]
+	x0, x1, h := sp.x0.Round(), sp.x1.Round(), l.h.Round()
+	if x1 <= x0 || h <= 0 {
+		return
+	}
+	base := l.a.Round()
+	if sp.Strikethrough {
+		// Strike through the middle of lower-case letters.
+		y := clampY(base-sp.Face.Metrics().Ascent.Round()/3, h-1)
+		fill(img, image.Rect(x0, y, x1, y+1), sp.FG)
+	}
+	c := sp.UnderlineColor
+	if c == nil {
+		c = sp.FG
+	}
+	switch sp.Underline {
+	case SolidUnderline:
+		y := clampY(base+1, h-1)
+		fill(img, image.Rect(x0, y, x1, y+1), c)
+	case WavyUnderline:
+		y := clampY(base+1, h-2)
+		for x := x0; x < x1; x++ {
+			// The period is relative to the line,
+			// so adjacent spans' waves line up.
+			dy := 0
+			if x%4 >= 2 {
+				dy = 1
+			}
+			fill(img, image.Rect(x, y+dy, x+1, y+dy+1), c)
+		}
+	}
+	if sp.Border != nil {
+		fill(img, image.Rect(x0, 0, x1, 1), sp.Border)
+		fill(img, image.Rect(x0, h-1, x1, h), sp.Border)
+		if i == 0 || l.spans[i-1].Style != sp.Style {
+			fill(img, image.Rect(x0, 0, x0+1, h), sp.Border)
+		}
+		if i == len(l.spans)-1 || l.spans[i+1].Style != sp.Style {
+			fill(img, image.Rect(x1-1, 0, x1, h), sp.Border)
+		}
+	}
+}
+
+// ClampY returns y clamped to the range [0, max].
+func clampY(y, max int) int {
+	if y > max {
+		y = max
+	}
+	if y < 0 {
+		y = 0
+	}
+	return y
+}
+
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.ZP, draw.Src)
 }
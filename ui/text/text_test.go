@@ -4,7 +4,10 @@ package text
 
 import (
 	"bytes"
+	"fmt"
 	"image"
+	"image/color"
+	"reflect"
 	"testing"
 	"unicode"
 	"unicode/utf8"
@@ -139,6 +142,308 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestWrap(t *testing.T) {
+	opts := func(wrap Wrap, indent int) Options {
+		return Options{
+			DefaultStyle: Style{Face: &unitFace{}},
+			Size:         image.Pt(5, 10),
+			TabWidth:     2,
+			Wrap:         wrap,
+			Indent:       indent,
+		}
+	}
+	tests := []struct {
+		name string
+		opts Options
+		adds []string
+		want string
+		x0s  []int
+	}{
+		{
+			name: "wrap anywhere",
+			opts: opts(WrapAnywhere, 0),
+			adds: []string{"ab cd ef"},
+			want: "[ab cd][ ef]",
+		},
+		{
+			name: "wrap words",
+			opts: opts(WrapWords, 0),
+			adds: []string{"ab cd ef"},
+			want: "[ab cd ][ef]",
+		},
+		{
+			name: "wrap words across adds",
+			opts: opts(WrapWords, 0),
+			adds: []string{"ab c", "d", "ef gh"},
+			want: "[ab ][cdef ][gh]",
+		},
+		{
+			name: "wrap words no space",
+			opts: opts(WrapWords, 0),
+			adds: []string{"abcdefg hi"},
+			want: "[abcde][fg hi]",
+		},
+		{
+			name: "wrap words newline",
+			opts: opts(WrapWords, 0),
+			adds: []string{"ab\ncd ef gh"},
+			want: "[ab\n][cd ef ][gh]",
+		},
+		{
+			name: "indent",
+			opts: opts(WrapAnywhere, 2),
+			adds: []string{"abcdefghij\nklm"},
+			want: "[abcde][fgh][ij\n][klm]",
+			x0s:  []int{0, 2, 2, 0},
+		},
+		{
+			name: "indent wrap words",
+			opts: opts(WrapWords, 1),
+			adds: []string{"ab cd ef"},
+			want: "[ab cd ][ef]",
+			x0s:  []int{0, 1},
+		},
+		{
+			name: "no wrap",
+			opts: opts(NoWrap, 0),
+			adds: []string{"abcdefghij\nklm"},
+			want: "[abcdefghij\n][klm]",
+		},
+	}
+	for _, test := range tests {
+		s := NewSetter(test.opts)
+		for _, str := range test.adds {
+			s.Add([]byte(str))
+		}
+		txt := s.Set()
+		if got := lineString(txt); got != test.want {
+			t.Errorf("%s s.Set()=%q, want %q", test.name, got, test.want)
+		}
+		if test.x0s == nil {
+			continue
+		}
+		var x0s []int
+		for _, l := range txt.lines {
+			x0s = append(x0s, l.x0.Round())
+		}
+		if !reflect.DeepEqual(x0s, test.x0s) {
+			t.Errorf("%s line x0s=%v, want %v", test.name, x0s, test.x0s)
+		}
+	}
+}
+
+func TestWrapGlyphBox(t *testing.T) {
+	s := NewSetter(Options{
+		DefaultStyle: Style{Face: &unitFace{}},
+		Size:         image.Pt(5, 10),
+		Wrap:         WrapWords,
+		Indent:       2,
+	})
+	s.Add([]byte("ab cde"))
+	txt := s.Set()
+	if got, want := lineString(txt), "[ab ][cde]"; got != want {
+		t.Fatalf("s.Set()=%q, want %q", got, want)
+	}
+	if got, want := txt.GlyphBox(3), image.Rect(2, 1, 3, 2); got != want {
+		t.Errorf("txt.GlyphBox(3)=%v, want %v", got, want)
+	}
+	if got, want := txt.Index(image.Pt(0, 1)), 3; got != want {
+		t.Errorf("txt.Index(%v)=%d, want %d", image.Pt(0, 1), got, want)
+	}
+
+	s.Reset(Options{
+		DefaultStyle: Style{Face: &unitFace{}},
+		Size:         image.Pt(5, 10),
+		Wrap:         NoWrap,
+	})
+	s.Add([]byte("abcdefg"))
+	txt = s.Set()
+	if got, want := txt.GlyphBox(1), image.Rect(1, 0, 2, 1); got != want {
+		t.Errorf("txt.GlyphBox(1)=%v, want %v", got, want)
+	}
+	if got := txt.GlyphBox(6); got != image.ZR {
+		t.Errorf("txt.GlyphBox(6)=%v, want %v", got, image.ZR)
+	}
+}
+
+func TestTabs(t *testing.T) {
+	opts := func(tabWidth int, stops []int, elastic bool) Options {
+		return Options{
+			DefaultStyle: Style{Face: &unitFace{}},
+			Size:         image.Pt(100, 100),
+			TabWidth:     tabWidth,
+			TabStops:     stops,
+			ElasticTabs:  elastic,
+		}
+	}
+	tests := []struct {
+		name string
+		opts Options
+		adds []string
+		// Want is the x coordinate of the rune after each tab.
+		want []int
+	}{
+		{
+			name: "tab width",
+			opts: opts(4, nil, false),
+			adds: []string{"a\tbcd\te\n\tf"},
+			want: []int{4, 8, 4},
+		},
+		{
+			name: "tab stops",
+			opts: opts(4, []int{3, 5}, false),
+			adds: []string{"a\tb\tc\td"},
+			want: []int{3, 5, 8},
+		},
+		{
+			name: "tab stop too close",
+			opts: opts(4, []int{3, 6}, false),
+			adds: []string{"abc\td\te"},
+			want: []int{6, 8},
+		},
+		{
+			name: "elastic",
+			opts: opts(2, nil, true),
+			adds: []string{"a\tb\nccc\td"},
+			want: []int{4, 4},
+		},
+		{
+			name: "elastic min width",
+			opts: opts(4, nil, true),
+			adds: []string{"a\tb\nc\td"},
+			want: []int{4, 4},
+		},
+		{
+			name: "elastic columns",
+			opts: opts(2, nil, true),
+			adds: []string{"a\tbbb\tc\nxx\ty\tz"},
+			want: []int{3, 7, 3, 7},
+		},
+		{
+			name: "elastic partial column",
+			opts: opts(2, nil, true),
+			adds: []string{"a\tb\tc\nddd\te"},
+			want: []int{4, 6, 4},
+		},
+		{
+			name: "elastic blocks",
+			opts: opts(2, nil, true),
+			adds: []string{"a\tb\nccc\td\n\neeeee\tf"},
+			want: []int{4, 4, 6},
+		},
+		{
+			name: "elastic across adds",
+			opts: opts(2, nil, true),
+			adds: []string{"a\t", "b\ncc", "c\td"},
+			want: []int{4, 4},
+		},
+	}
+	for _, test := range tests {
+		s := NewSetter(test.opts)
+		var text string
+		for _, str := range test.adds {
+			s.Add([]byte(str))
+			text += str
+		}
+		txt := s.Set()
+		var got []int
+		for i, r := range text {
+			if r == '\t' {
+				got = append(got, txt.GlyphBox(i+1).Min.X)
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s tab positions=%v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	const text = "the quick brown\nfox jumped over the lazy dog\n\nxxxxxxxxxxxxxxxxxxxx yy\tz\nend"
+	tests := []struct {
+		start, end int
+		insert     string
+	}{
+		{start: 0, end: 0, insert: ""},
+		{start: 0, end: 0, insert: "a"},
+		{start: 0, end: 0, insert: "aaaaaaa "},
+		{start: 4, end: 5, insert: "Q"},
+		{start: 4, end: 9, insert: ""},
+		{start: 15, end: 16, insert: " "},
+		{start: 15, end: 15, insert: "\n"},
+		{start: 20, end: 20, insert: "\n\n\n\n"},
+		{start: 25, end: 26, insert: "\n"},
+		{start: 22, end: 22, insert: "\t\t\t"},
+		{start: 44, end: 45, insert: ""},
+		{start: 45, end: 65, insert: "yyyyyyyyyyyyyyyyyyyyy"},
+		{start: 10, end: 70, insert: ""},
+		{start: 0, end: len(text), insert: "new"},
+		{start: len(text), end: len(text), insert: " the end\n"},
+		{start: len(text) - 1, end: len(text), insert: ""},
+	}
+	for _, wrap := range []Wrap{WrapAnywhere, WrapWords, NoWrap} {
+		for _, indent := range []int{0, 2} {
+			for _, height := range []int{100, 6} {
+				for _, elastic := range []bool{false, true} {
+					opts := Options{
+						DefaultStyle: Style{Face: &unitFace{}},
+						Size:         image.Pt(10, height),
+						TabWidth:     4,
+						Wrap:         wrap,
+						Indent:       indent,
+						ElasticTabs:  elastic,
+					}
+					for _, test := range tests {
+						s := NewSetter(opts)
+						s.Add([]byte(text))
+						txt := s.Set()
+						// Text not fitting the height is not in the Text.
+						set := textString(txt)
+						start, end := test.start, test.end
+						if start > len(set) {
+							start = len(set)
+						}
+						if end > len(set) {
+							end = len(set)
+						}
+						txt.Replace(test.start, test.end, []Run{{Text: []byte(test.insert)}})
+
+						want := set[:start] + test.insert + set[end:]
+						s.Reset(opts)
+						s.Add([]byte(want))
+						wantTxt := s.Set()
+						if got, want := layoutString(txt), layoutString(wantTxt); got != want {
+							t.Errorf("wrap=%d indent=%d height=%d elastic=%v Replace(%d, %d, %q)=\n%s\nwant\n%s",
+								wrap, indent, height, elastic, test.start, test.end, test.insert, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReplaceStyle(t *testing.T) {
+	other := Style{Face: &unitFace{}, FG: color.White}
+	s := NewSetter(Options{
+		DefaultStyle: Style{Face: &unitFace{}},
+		Size:         image.Pt(5, 100),
+	})
+	s.Add([]byte("12345\n12345\n12345\n12345"))
+	txt := s.Set()
+	old := append([]*line{}, txt.lines...)
+	txt.Replace(7, 8, []Run{{Style: &other, Text: []byte("xy")}})
+	if got, want := lineString(txt), "[12345\n][1xy34][5\n][12345\n][12345]"; got != want {
+		t.Fatalf("txt.Replace(…); lineString(txt)=%q, want %q", got, want)
+	}
+	if sp := txt.lines[1].spans[1]; sp.Style != other || sp.text != "xy" {
+		t.Errorf("replaced span=%+v, want style %+v, text %q", sp, other, "xy")
+	}
+	if txt.lines[0] != old[0] || txt.lines[3] != old[2] || txt.lines[4] != old[3] {
+		t.Errorf("unchanged lines were not reused")
+	}
+}
+
 func TestAddVerticalMetrics(t *testing.T) {
 	tallHeight, tallAscent := fixed.I(1000), fixed.I(800)
 	tall := Style{
@@ -348,6 +653,116 @@ func TestTextIndex(t *testing.T) {
 	}
 }
 
+func TestDrawDecorations(t *testing.T) {
+	var (
+		face   = &testFace{adv: map[rune]fixed.Int26_6{'a': fixed.I(4)}, height: fixed.I(10), ascent: fixed.I(7)}
+		bg     = color.RGBA{A: 0xFF, R: 0xFF, G: 0xFF, B: 0xFF}
+		fg     = color.RGBA{A: 0xFF}
+		red    = color.RGBA{A: 0xFF, R: 0xFF}
+		blue   = color.RGBA{A: 0xFF, B: 0xFF}
+		plain  = Style{Face: face, FG: fg, BG: bg}
+		under  = Style{Face: face, FG: fg, BG: bg, Underline: SolidUnderline, UnderlineColor: red}
+		wavy   = Style{Face: face, FG: fg, BG: bg, Underline: WavyUnderline, UnderlineColor: red}
+		strike = Style{Face: face, FG: fg, BG: bg, Strikethrough: true}
+		border = Style{Face: face, FG: fg, BG: bg, Border: blue}
+	)
+	s := NewSetter(Options{DefaultStyle: plain, Size: image.Pt(100, 100)})
+	// Each style is 8 pixels wide.
+	s.AddStyle(&plain, []byte("aa"))
+	s.AddStyle(&under, []byte("aa"))
+	s.AddStyle(&wavy, []byte("aa"))
+	s.AddStyle(&strike, []byte("aa"))
+	s.AddStyle(&border, []byte("a"))
+	s.AddStyle(&border, []byte("a"))
+	txt := s.Set()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	drawLine(txt, txt.lines[0], img)
+
+	tests := []struct {
+		name string
+		pt   image.Point
+		want color.RGBA
+	}{
+		{name: "plain", pt: image.Pt(4, 8), want: bg},
+		{name: "underline", pt: image.Pt(8, 8), want: red},
+		{name: "underline end", pt: image.Pt(15, 8), want: red},
+		{name: "above underline", pt: image.Pt(12, 7), want: bg},
+		{name: "wave low", pt: image.Pt(16, 8), want: red},
+		{name: "wave low gap", pt: image.Pt(16, 9), want: bg},
+		{name: "wave high", pt: image.Pt(18, 9), want: red},
+		{name: "wave high gap", pt: image.Pt(18, 8), want: bg},
+		{name: "strikethrough", pt: image.Pt(28, 5), want: fg},
+		{name: "not strikethrough", pt: image.Pt(28, 6), want: bg},
+		{name: "border top left", pt: image.Pt(32, 0), want: blue},
+		{name: "border bottom right", pt: image.Pt(39, 9), want: blue},
+		{name: "border inside", pt: image.Pt(35, 5), want: bg},
+		{name: "border shared by spans", pt: image.Pt(35, 0), want: blue},
+		{name: "no border between spans", pt: image.Pt(36, 5), want: bg},
+		{name: "bottom border shared by spans", pt: image.Pt(36, 9), want: blue},
+	}
+	for _, test := range tests {
+		if got := img.RGBAAt(test.pt.X, test.pt.Y); got != test.want {
+			t.Errorf("%s: pixel at %v=%v, want %v", test.name, test.pt, got, test.want)
+		}
+	}
+}
+
+func TestLineMetrics(t *testing.T) {
+	const pad = 1
+	s := NewSetter(Options{
+		DefaultStyle: Style{Face: &unitFace{}},
+		Size:         image.Pt(5+2*pad, 3+2*pad),
+		Padding:      pad,
+	})
+	s.Add([]byte("12345678\nab\ncd"))
+	txt := s.Set()
+	if got, want := lineString(txt), "[12345][678\n][ab\n][cd]"; got != want {
+		t.Fatalf("s.Set()=%q, want %q", got, want)
+	}
+	if n := txt.NumLines(); n != 3 {
+		t.Errorf("txt.NumLines()=%d, want 3", n)
+	}
+
+	lines := []struct {
+		start, end int
+		box        image.Rectangle
+	}{
+		{start: 0, end: 0, box: image.ZR},
+		{start: 0, end: 5, box: image.Rect(1, 1, 6, 2)},
+		{start: 5, end: 9, box: image.Rect(1, 2, 6, 3)},
+		{start: 9, end: 12, box: image.Rect(1, 3, 6, 4)},
+		// The line [cd] does not fit.
+		{start: 12, end: 12, box: image.ZR},
+	}
+	for i, want := range lines {
+		i-- // Start at line -1.
+		if start, end := txt.LineRange(i); start != want.start || end != want.end {
+			t.Errorf("txt.LineRange(%d)=%d,%d, want %d,%d", i, start, end, want.start, want.end)
+		}
+		if box := txt.LineBox(i); box != want.box {
+			t.Errorf("txt.LineBox(%d)=%v, want %v", i, box, want.box)
+		}
+	}
+
+	lineOfs := []struct{ index, want int }{
+		{-1, 0}, {0, 0}, {4, 0}, {5, 1}, {8, 1}, {9, 2}, {12, 3}, {14, 3}, {15, 4},
+	}
+	for _, test := range lineOfs {
+		if got := txt.LineOf(test.index); got != test.want {
+			t.Errorf("txt.LineOf(%d)=%d, want %d", test.index, got, test.want)
+		}
+	}
+
+	s.Add([]byte("1\n2\n"))
+	txt = s.Set()
+	if got := txt.LineOf(4); got != 2 {
+		t.Errorf("after trailing newline txt.LineOf(4)=%d, want 2", got)
+	}
+	if got := txt.LineOf(3); got != 1 {
+		t.Errorf("after trailing newline txt.LineOf(3)=%d, want 1", got)
+	}
+}
+
 func TestTextGlyphBox(t *testing.T) {
 	const (
 		pad        = 3
@@ -483,6 +898,30 @@ func TestTextGlyphBox(t *testing.T) {
 	}
 }
 
+// LayoutString returns a string of the lines of the Text,
+// one per line, with their x0 and width.
+func layoutString(t *Text) string {
+	buf := bytes.NewBuffer(nil)
+	for _, l := range t.lines {
+		var text string
+		for _, s := range l.spans {
+			text += s.text
+		}
+		fmt.Fprintf(buf, "%d %d %q\n", l.x0.Round(), l.w.Round(), text)
+	}
+	return buf.String()
+}
+
+func textString(t *Text) string {
+	var text string
+	for _, l := range t.lines {
+		for _, s := range l.spans {
+			text += s.text
+		}
+	}
+	return text
+}
+
 func lineString(t *Text) string {
 	buf := bytes.NewBuffer(nil)
 	for _, l := range t.lines {
@@ -527,8 +966,11 @@ type testFace struct {
 
 func (testFace) Close() error { return nil }
 
-func (testFace) Glyph(fixed.Point26_6, rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
-	panic("unimplemented")
+// Glyph returns an empty glyph, so that only the background
+// and decorations of spans are drawn.
+func (f testFace) Glyph(_ fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	a, ok := f.adv[r]
+	return image.ZR, image.Transparent, image.ZP, a, ok
 }
 
 func (f testFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
@@ -553,3 +995,52 @@ func (f testFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool)
 func (f testFace) Metrics() font.Metrics {
 	return font.Metrics{Height: f.height, Ascent: f.ascent}
 }
+
+// BenchmarkTypeSet benchmarks typing into a 1MB document,
+// setting the entire document after each keystroke.
+func BenchmarkTypeSet(b *testing.B) {
+	s, doc := benchmarkDocument()
+	txt := s.Set()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txt.Release()
+		s.Reset(s.opts)
+		at := len(doc)/2 + i%len(benchmarkLine)
+		doc = append(doc[:at], append([]byte{'x'}, doc[at:]...)...)
+		s.Add(doc)
+		txt = s.Set()
+	}
+}
+
+// BenchmarkTypeReplace benchmarks typing into a 1MB document,
+// replacing only the typed text after each keystroke.
+func BenchmarkTypeReplace(b *testing.B) {
+	s, doc := benchmarkDocument()
+	txt := s.Set()
+	run := []Run{{Text: []byte{'x'}}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		at := len(doc)/2 + i%len(benchmarkLine)
+		txt.Replace(at, at, run)
+	}
+}
+
+const benchmarkLine = "The quick brown fox jumps over the lazy dog.\tThe quick brown fox jumps over the lazy dog.\n"
+
+// BenchmarkDocument returns a Setter with a 1MB document added,
+// and the document.
+func benchmarkDocument() (*Setter, []byte) {
+	const size = 1 << 20
+	var doc []byte
+	for len(doc) < size {
+		doc = append(doc, benchmarkLine...)
+	}
+	s := NewSetter(Options{
+		DefaultStyle: Style{Face: &unitFace{}},
+		Size:         image.Pt(100, size),
+		TabWidth:     4,
+		Wrap:         WrapWords,
+	})
+	s.Add(doc)
+	return s, doc
+}
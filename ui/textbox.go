@@ -12,22 +12,31 @@ import (
 	"math"
 	"net/url"
 	"path"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/eaburns/T/edit"
 	"github.com/eaburns/T/editor"
 	"github.com/eaburns/T/editor/view"
+	"github.com/eaburns/T/ui/syntax"
 	"github.com/eaburns/T/ui/text"
 	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
 	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/mouse"
-	"golang.org/x/mobile/event/paint"
 )
 
 const (
-	cursorWidth   = 1 // px
-	blinkDuration = 500 * time.Millisecond
+	textPadding     = 2 // px
+	cursorWidth     = 1 // px
+	blinkDuration   = 500 * time.Millisecond
+	minThumbSize    = 2 // px
+	doubleClickTime = 500 * time.Millisecond
+	// WheelLines is the number of lines scrolled
+	// by each step of a mouse wheel or touchpad.
+	wheelLines = 3
 )
 
 // A textBox is an editable text box.
@@ -39,15 +48,88 @@ type textBox struct {
 	text      *text.Text
 	topLeft   image.Point
 
-	textLen  int
-	l0, dot0 int64
+	// Sheet is the sheet containing the text box, or nil.
+	sheet *sheet
+	// Diff is the diff view with the text box as its tag, or nil.
+	diff *diffView
+
+	textLen int
+	// L0 is the rune offset of the first visible rune,
+	// and dot0 and dot1 are the rune offsets of dot.
+	l0, dot0, dot1 int64
+	// ShowDot is whether to scroll dot into view
+	// after the next change to the text.
+	// It is set by key presses, and cleared by mouse presses.
+	showDot bool
+	// NRunes is the number of runes in the text.
+	nRunes int
+	// Size is the size of the buffer in runes.
+	size int64
+
+	// Others are the other labeled editors of the buffer,
+	// whose dots are drawn as carets labeled with their names.
+	others []editor.Presence
+
+	// Highlights are sorted, non-overlapping spans
+	// of the buffer drawn with a highlighted background.
+	highlights []edit.Span
+
+	// Syntax highlights the syntax of the text, or is nil.
+	syntax *highlighter
+
+	// JumpTarget is the span to which dot is being set
+	// by navigating the window's jump list, or nil.
+	jumpTarget *edit.Span
+
+	// Recall is the state of recalling commands
+	// from the window's history into a tag.
+	recall recall
+
+	// Spelling is the state of checking the spelling
+	// of the visible text of a sheet body.
+	spelling spelling
+
+	// Line0 is the line number of the first line of the text.
+	line0 int64
+	// LineYs are the y coordinates, relative to topLeft,
+	// of the beginnings of the visible lines of the text.
+	lineYs []int
 
 	// Col is the column number of the cursor, or -1 if unknown.
 	col int
 
+	// Held is the first mouse button pressed and still held,
+	// or mouse.ButtonNone if no button is held.
+	held mouse.Button
+
+	// Last is the most recent button 1 click.
+	last multiClick
+	// DragPoint is the most recent mouse location
+	// while a button is held.
+	dragPoint image.Point
+	// DragPress is the location of a button 1 press inside dot
+	// that may begin dragging the text of dot, or nil.
+	dragPress *image.Point
+
 	lastBlink        time.Time
 	inFocus, blinkOn bool
 
+	// Composition is the text being composed by an input method,
+	// which is drawn at dot until it is committed.
+	composition string
+
+	// Busy is the number of running commands
+	// executed from the text box's sheet or column.
+	busy int
+
+	// ReadOnly is whether edits that may change the text are rejected.
+	readOnly bool
+
+	// Scale is the scale factor of the text box's window,
+	// and theme is its color theme.
+	scale float64
+	theme *Theme
+
 	mu    sync.RWMutex
 	reset bool
 	win   *window
@@ -56,7 +138,8 @@ type textBox struct {
 // NewTextBod creates a new text box.
 // URL is either the root path to an editor server,
 // or the path to an open buffer of an editor server.
-func newTextBox(w *window, URL url.URL, style text.Style) (t *textBox, err error) {
+// If label is not empty, it is the Label of the text box's editor.
+func newTextBox(w *window, URL url.URL, label string, style text.Style) (t *textBox, err error) {
 	if URL.Path == "/" {
 		URL.Path = path.Join("/", "buffers")
 		buf, err := editor.NewBuffer(&URL)
@@ -77,14 +160,15 @@ func newTextBox(w *window, URL url.URL, style text.Style) (t *textBox, err error
 		return nil, errors.New("bad buffer path: " + URL.Path)
 	}
 
-	v, err := view.New(&URL, '.')
+	v, err := view.NewLabeled(&URL, label, '.')
 	if err != nil {
 		return nil, err
 	}
+	refBuffer(&URL)
 	opts := text.Options{
 		DefaultStyle: style,
 		TabWidth:     4,
-		Padding:      2,
+		Padding:      w.px(textPadding),
 	}
 	setter := text.NewSetter(opts)
 	t = &textBox{
@@ -94,6 +178,8 @@ func newTextBox(w *window, URL url.URL, style text.Style) (t *textBox, err error
 		setter:    setter,
 		text:      setter.Set(),
 		col:       -1,
+		scale:     w.scale,
+		theme:     w.theme,
 		win:       w,
 	}
 	go func() {
@@ -101,10 +187,13 @@ func newTextBox(w *window, URL url.URL, style text.Style) (t *textBox, err error
 			t.mu.Lock()
 			t.reset = true
 			if t.win != nil {
-				t.win.Send(paint.Event{})
+				t.win.Send(changeEvent{t})
 			}
 			t.mu.Unlock()
 		}
+		if err := v.Err(); err != nil {
+			t.logf("lost connection to the editor: %v", err)
+		}
 	}()
 	return t, nil
 }
@@ -114,10 +203,15 @@ func (t *textBox) close() {
 	t.win = nil
 	t.mu.Unlock()
 
+	if t.syntax != nil {
+		t.syntax.close()
+	}
 	t.text.Release()
 	t.setter.Release()
 	t.view.Close()
-	editor.Close(t.bufferURL)
+	if unrefBuffer(t.bufferURL) {
+		editor.Close(t.bufferURL)
+	}
 }
 
 // SetSize resets the text if either the size changed or the text changed.
@@ -136,69 +230,329 @@ func (t *textBox) setSize(size image.Point) {
 	t.opts.Size = size
 	t.setter.Reset(t.opts)
 
+	var newlines []int
+	dot0, dot1 := t.dot0, t.dot1
 	t.view.View(func(text []byte, marks []view.Mark) {
+		newlines = newlines[:0]
+		for i, b := range text {
+			if b == '\n' {
+				newlines = append(newlines, i)
+			}
+		}
 		t.textLen = len(text)
-		t.setter.Add(text)
+		t.nRunes = utf8.RuneCount(text)
 		for _, m := range marks {
 			switch m.Name {
 			case view.ViewMark:
 				t.l0 = m.Where[0]
 			case '.':
-				t.dot0 = m.Where[0]
+				t.dot0, t.dot1 = m.Where[0], m.Where[1]
 			}
 		}
+		var tokens []syntax.Token
+		if t.syntax != nil {
+			tokens = t.syntax.tokens(edit.Span{t.l0, t.l0 + int64(t.nRunes)})
+		}
+		if t.sheet != nil && t == t.sheet.body {
+			t.setSpellingText(text, t.l0)
+		}
+		addStyled(t.setter, text, t.l0, tokens, t.highlights, t.spelling.misspelled, t.opts.DefaultStyle, t.theme)
 	})
+	t.size = t.view.Size()
+	t.line0 = t.view.Line()
+	t.others = t.view.Others()
+	if t.dot0 != dot0 || t.dot1 != dot1 {
+		t.moveDot(edit.Span{dot0, dot1})
+	}
+	if t.showDot {
+		t.showDot = false
+		if !t.dotVisible() {
+			// Warp so that dot is near the middle of the text box.
+			n := size.Y / h.Round() / 2
+			t.view.Warp(edit.Rune(t.dot1).Minus(edit.Clamp(edit.Line(n))))
+		}
+	}
 
 	t.text = t.setter.Set()
 
+	t.lineYs = append(t.lineYs[:0], t.text.GlyphBox(0).Min.Y)
+	for _, i := range newlines {
+		if i+1 == t.textLen && t.l0+int64(t.nRunes) < t.size {
+			// The line after the last newline is not visible.
+			break
+		}
+		r := t.text.GlyphBox(i + 1)
+		if r == image.ZR {
+			break
+		}
+		t.lineYs = append(t.lineYs, r.Min.Y)
+	}
+
 	if t.inFocus {
 		t.blinkOn = true
-		t.lastBlink = time.Now()
+		t.lastBlink = t.now()
 	}
 }
 
+// SetHighlights sets the highlighted spans of the buffer.
+// The spans must be sorted and non-overlapping.
+func (t *textBox) setHighlights(spans []edit.Span) {
+	t.highlights = spans
+	t.mu.Lock()
+	t.reset = true
+	t.mu.Unlock()
+}
+
 func (t *textBox) draw(scr screen.Screen, win screen.Window) {
 	t.text.Draw(t.topLeft, scr, win)
+	t.drawOthers(t.topLeft, scr, win)
+	t.drawComposition(t.topLeft, scr, win)
 	t.drawDot(t.topLeft, win)
+	t.drawBusy(win)
 }
 
 func (t *textBox) drawLines(scr screen.Screen, win screen.Window) {
 	t.text.DrawLines(t.topLeft, scr, win)
+	t.drawOthers(t.topLeft, scr, win)
+	t.drawComposition(t.topLeft, scr, win)
 	t.drawDot(t.topLeft, win)
+	t.drawBusy(win)
+}
+
+// DrawBusy draws a box at the right of the first line
+// if commands executed from the text box are running.
+func (t *textBox) drawBusy(win screen.Window) {
+	if t.busy == 0 {
+		return
+	}
+	h := t.opts.DefaultStyle.Face.Metrics().Height.Round()
+	x1 := t.topLeft.X + t.opts.Size.X - t.opts.Padding
+	y0 := t.topLeft.Y + t.opts.Padding
+	r := image.Rect(x1-h/2, y0+h/4, x1, y0+h/4+h/2)
+	win.Fill(r.Intersect(image.Rectangle{Min: t.topLeft, Max: t.topLeft.Add(t.opts.Size)}), t.theme.Busy, draw.Src)
 }
 
+// DrawDot draws the cursor at the beginning of dot,
+// and, if dot is not empty, a caret at its end.
 func (t *textBox) drawDot(pt image.Point, win screen.Window) {
-	l, d := t.l0, t.dot0
-	if !t.blinkOn || d < t.l0 || d > l+int64(t.textLen) || t.opts.Size.X < cursorWidth {
+	if !t.blinkOn {
 		return
 	}
+	t.drawCaret(pt, t.dot0, win)
+	if t.dot1 != t.dot0 {
+		t.drawCaret(pt, t.dot1, win)
+	}
+}
+
+func (t *textBox) drawCaret(pt image.Point, d int64, win screen.Window) {
+	if r, ok := t.caretBox(pt, d); ok {
+		win.Fill(r, t.theme.Cursor, draw.Src)
+	}
+}
+
+// CaretBox returns the rectangle of a caret at the given rune offset,
+// and whether it is visible.
+func (t *textBox) caretBox(pt image.Point, d int64) (image.Rectangle, bool) {
+	l := t.l0
+	width := scalePx(cursorWidth, t.scale)
+	if d < l || d > l+int64(t.textLen) || t.opts.Size.X < width {
+		return image.ZR, false
+	}
 	i := int(d - l)
 	r := t.text.GlyphBox(i).Add(pt)
-	r.Max.X = r.Min.X + cursorWidth
-	win.Fill(r, color.Black, draw.Src)
+	r.Max.X = r.Min.X + width
+	return r, true
+}
+
+// DotVisible returns whether the end of dot is visible.
+// Dot at the end of the buffer is visible
+// if the visible text reaches the end of the buffer.
+func (t *textBox) dotVisible() bool {
+	l := t.l0
+	d := t.dot1
+	switch {
+	case d < l:
+		return false
+	case d < l+int64(t.nRunes):
+		return true
+	case d == t.size && d == l+int64(t.nRunes):
+		return t.text.GlyphBox(t.textLen) != image.ZR
+	}
+	return false
+}
+
+// DrawScrollBar draws a scroll bar in the given rectangle,
+// with a thumb showing the visible portion of the text.
+func (t *textBox) drawScrollBar(r image.Rectangle, win screen.Window) {
+	win.Fill(r, t.theme.ScrollBG, draw.Src)
+	win.Fill(thumb(r, t.l0, t.nRunes, t.size), t.theme.ScrollThumb, draw.Src)
+}
+
+// Thumb returns the rectangle of a scroll bar thumb
+// for a visible text of n runes beginning at rune l0
+// of a buffer with the given size.
+func thumb(r image.Rectangle, l0 int64, n int, size int64) image.Rectangle {
+	if size <= 0 {
+		return r
+	}
+	h := float64(r.Dy())
+	y0 := r.Min.Y + int(h*float64(l0)/float64(size))
+	y1 := r.Min.Y + int(h*float64(l0+int64(n))/float64(size))
+	if y1 > r.Max.Y {
+		y1 = r.Max.Y
+	}
+	if y1-y0 < minThumbSize {
+		y1 = y0 + minThumbSize
+		if y1 > r.Max.Y {
+			y0, y1 = r.Max.Y-minThumbSize, r.Max.Y
+		}
+	}
+	return image.Rect(r.Min.X, y0, r.Max.X, y1)
+}
+
+// ScrollClick scrolls the text in response to a button press
+// at y pixels from the top of a scroll bar with height h.
+//
+// Like acme,
+// the left button scrolls backward, moving the top line down to y,
+// the right button scrolls forward, moving the line at y to the top,
+// and the middle button jumps to the position in the buffer
+// proportional to y.
+func (t *textBox) scrollClick(b mouse.Button, y, h int) {
+	t.showDot = false
+	lines := y / t.opts.DefaultStyle.Face.Metrics().Height.Round()
+	if lines < 1 {
+		lines = 1
+	}
+	switch b {
+	case mouse.ButtonLeft:
+		t.view.Scroll(-lines)
+	case mouse.ButtonRight:
+		t.view.Scroll(lines)
+	case mouse.ButtonMiddle:
+		if h <= 0 {
+			return
+		}
+		at := int64(float64(t.size) * float64(y) / float64(h))
+		t.view.Warp(edit.Clamp(edit.Rune(at)))
+	}
+}
+
+// Wheel scrolls the text in response to a step of a mouse wheel.
+// Touchpads send steps too, but the text always scrolls by whole lines.
+func (t *textBox) wheel(b mouse.Button) {
+	t.showDot = false
+	switch b {
+	case mouse.ButtonWheelUp:
+		t.view.Scroll(-wheelLines)
+	case mouse.ButtonWheelDown:
+		t.view.Scroll(wheelLines)
+	}
 }
 
 func (t *textBox) changeFocus(_ *window, inFocus bool) {
+	if !inFocus {
+		t.composition = ""
+	}
 	t.inFocus = inFocus
 	t.blinkOn = inFocus
-	t.lastBlink = time.Now()
+	t.lastBlink = t.now()
 }
 
 func (t *textBox) tick(win *window) bool {
-	if s := time.Since(t.lastBlink); s < blinkDuration {
+	if t.held == mouse.ButtonLeft && t.last.n > 0 {
+		h := t.opts.DefaultStyle.Face.Metrics().Height.Round()
+		y0 := t.topLeft.Y
+		y1 := y0 + t.opts.Size.Y
+		if n := autoscrollLines(t.dragPoint.Y, y0, y1, h); n != 0 {
+			t.view.Scroll(n)
+			// Extend the selection to the newly visible text.
+			p := t.dragPoint
+			handleMouse(t, mouse.Event{X: float32(p.X), Y: float32(p.Y)})
+		}
+	}
+	if !t.blink() {
+		// The cursor is solid.
+		redraw := t.blinkOn != t.inFocus
+		t.blinkOn = t.inFocus
+		return redraw
+	}
+	now := t.now()
+	if now.Sub(t.lastBlink) < blinkDuration {
 		return false
 	}
-	t.lastBlink = time.Now()
+	t.lastBlink = now
 	t.blinkOn = !t.blinkOn
 	return true
 }
 
-func (t *textBox) key(_ *window, event key.Event) bool {
-	handleKey(t, event)
+// Blink returns whether the cursor blinks.
+// Now returns the current time of the server's clock.
+func (t *textBox) now() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win == nil {
+		return time.Now()
+	}
+	return t.win.server.now()
+}
+
+func (t *textBox) logf(format string, vs ...interface{}) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win == nil {
+		log.Printf(format, vs...)
+		return
+	}
+	t.win.logf(format, vs...)
+}
+
+func (t *textBox) blink() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win == nil {
+		return true
+	}
+	t.win.server.RLock()
+	defer t.win.server.RUnlock()
+	return t.win.server.blink
+}
+
+func (t *textBox) key(w *window, event key.Event) bool {
+	if event.Direction == key.DirRelease {
+		handleKey(t, event)
+		return false
+	}
+	t.showDot = true
+	if w != nil && t.sheet != nil && t == t.sheet.body {
+		w.enterSheet(t.sheet)
+	}
+	switch {
+	case t.recallKey(w, event):
+	case t.completeKey(w, event):
+	default:
+		handleKey(t, event)
+	}
 	return false
 }
 
 func (t *textBox) mouse(w *window, event mouse.Event) bool {
+	switch event.Direction {
+	case mouse.DirStep:
+		// The text is redrawn when the view changes.
+		t.wheel(event.Button)
+		return false
+	case mouse.DirPress:
+		t.showDot = false
+		t.recall.active = false
+		if t.sheet != nil && t == t.sheet.body {
+			w.enterSheet(t.sheet)
+		}
+	}
+	t.dragPoint = image.Pt(int(event.X), int(event.Y))
+	if handled, redraw := t.dragText(w, event); handled {
+		return redraw
+	}
 	handleMouse(t, event)
 	return false
 }
@@ -207,28 +561,140 @@ func (t *textBox) drawLast(scr screen.Screen, win screen.Window) {}
 
 func (t *textBox) doSync(eds ...edit.Edit) ([]editor.EditResult, error) {
 	t.col = -1
+	if t.readOnly && modifies(eds) {
+		return nil, errReadOnly
+	}
 	return t.view.Do(eds...)
 }
 
 func (t *textBox) doAsync(eds ...edit.Edit) {
 	t.col = -1
+	if t.readOnly && modifies(eds) {
+		return
+	}
 	t.view.DoAsync(eds...)
 }
 
+func (t *textBox) doThen(f func([]editor.EditResult, error), eds ...edit.Edit) {
+	t.col = -1
+	if t.readOnly && modifies(eds) {
+		f(nil, errReadOnly)
+		return
+	}
+	t.mu.RLock()
+	w := t.win
+	t.mu.RUnlock()
+	t.view.DoFunc(func(res []editor.EditResult, err error) {
+		w.Send(func() { f(res, err) })
+	}, eds...)
+}
+
 func (t *textBox) where(p image.Point) int64 {
 	return int64(t.text.Index(p.Sub(t.topLeft))) + t.l0
 }
 
 func (t *textBox) exec(c string) {
+	if t.sheet != nil && t.sheet.execBuiltin(c) {
+		return
+	}
+	if t.diff != nil && t.diff.execBuiltin(c) {
+		return
+	}
 	t.mu.RLock()
 	w := t.win
 	t.mu.RUnlock()
-	go w.exec(c)
+	w.addHistory(c)
+	if w.execBuiltin(c) {
+		return
+	}
+	tag := t
+	if t.sheet != nil {
+		tag = t.sheet.tag
+	}
+	dir, env := w.commandEnv(t.sheet)
+	go w.exec(tag, dir, env, c)
+}
+
+func (t *textBox) look(text string) {
+	if t.sheet != nil {
+		t.sheet.plumb(text)
+	}
 }
 
 func (t *textBox) setColumn(c int) { t.col = c }
 func (t *textBox) column() int     { return t.col }
 
+func (t *textBox) keymap() Keymap {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win == nil {
+		return nil
+	}
+	t.win.server.RLock()
+	defer t.win.server.RUnlock()
+	return t.win.server.keymap
+}
+
+func (t *textBox) setButton(b mouse.Button) { t.held = b }
+func (t *textBox) button() mouse.Button     { return t.held }
+
+func (t *textBox) setLastClick(c multiClick) { t.last = c }
+func (t *textBox) lastClick() multiClick     { return t.last }
+
+func (t *textBox) snarf() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win == nil {
+		return ""
+	}
+	t.win.server.RLock()
+	defer t.win.server.RUnlock()
+	return t.win.server.snarf
+}
+
+func (t *textBox) setSnarf(s string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.win != nil {
+		t.win.server.Lock()
+		t.win.server.snarf = s
+		t.win.server.Unlock()
+	}
+}
+
+// SetWindow moves the text box to a different window,
+// or updates it after a change to the DPI or theme of its window.
+// It must be called in the UI goroutine of the window.
+func (t *textBox) setWindow(w *window) {
+	t.mu.Lock()
+	t.win = w
+	t.reset = true
+	t.mu.Unlock()
+	t.scale = w.scale
+	t.theme = w.theme
+	t.opts.DefaultStyle.Face = w.face
+	t.opts.Padding = w.px(textPadding)
+}
+
+// SetFace sets the font face of the text.
+// It must be called in the UI goroutine of the text box's window.
+func (t *textBox) setFace(face font.Face) {
+	t.mu.Lock()
+	t.reset = true
+	t.mu.Unlock()
+	t.opts.DefaultStyle.Face = face
+}
+
+// SetColors sets the default text and background colors.
+// It must be called in the UI goroutine of the text box's window.
+func (t *textBox) setColors(fg, bg color.Color) {
+	t.mu.Lock()
+	t.reset = true
+	t.mu.Unlock()
+	t.opts.DefaultStyle.FG = fg
+	t.opts.DefaultStyle.BG = bg
+}
+
 var (
 	dot          = edit.Dot
 	zero         = edit.Clamp(edit.Rune(0))
@@ -254,6 +720,11 @@ type doer interface {
 	// and performs the edit asynchronously,
 	// discarding the result.
 	doAsync(...edit.Edit)
+
+	// DoThen clears the column marker,
+	// performs the edit asynchronously,
+	// and calls the function with the result in the UI goroutine.
+	doThen(func([]editor.EditResult, error), ...edit.Edit)
 }
 
 type mouseHandler interface {
@@ -263,6 +734,38 @@ type mouseHandler interface {
 	where(image.Point) int64
 	// Exec executes a command.
 	exec(string)
+	// Look plumbs text looked at by a button 3 click.
+	look(string)
+	// Button returns the first button pressed and still held,
+	// or mouse.ButtonNone if no button is held.
+	button() mouse.Button
+	// SetButton sets the held button.
+	setButton(mouse.Button)
+	// Snarf returns the contents of the snarf buffer.
+	snarf() string
+	// SetSnarf sets the contents of the snarf buffer.
+	setSnarf(string)
+	// LastClick returns the most recent button 1 click.
+	lastClick() multiClick
+	// SetLastClick sets the most recent button 1 click.
+	setLastClick(multiClick)
+	// Now returns the current time.
+	now() time.Time
+	// Logf writes a message to the console.
+	logf(string, ...interface{})
+}
+
+// A multiClick is a button 1 press,
+// used to detect double and triple clicks.
+type multiClick struct {
+	// N is the number of successive clicks at the same rune:
+	// 1 for a single click, 2 for a double, and 3 for a triple.
+	// N is 0 if a chord ended the click's selection.
+	n int
+	// At is the rune offset of the click.
+	at int64
+	// Time is the time of the click.
+	time time.Time
 }
 
 func handleMouse(h mouseHandler, event mouse.Event) {
@@ -273,10 +776,66 @@ func handleMouse(h mouseHandler, event mouse.Event) {
 	p := image.Pt(int(event.X), int(event.Y))
 
 	switch event.Direction {
+	case mouse.DirNone:
+		c := h.lastClick()
+		if h.button() != mouse.ButtonLeft || c.n == 0 {
+			break
+		}
+		at := h.where(p)
+		if at == c.at && c.n > 1 {
+			// Keep the double or triple click selection
+			// until the drag leaves the clicked rune.
+			break
+		}
+		from, to := c.at, at
+		if to < from {
+			from, to = to, from
+		}
+		h.doAsync(edit.Set(edit.Rune(from).To(edit.Rune(to)), '.'))
+
+	case mouse.DirRelease:
+		if event.Button == h.button() {
+			h.setButton(mouse.ButtonNone)
+		}
+
 	case mouse.DirPress:
+		if h.button() == mouse.ButtonLeft {
+			// A chord: another button is pressed while button 1 is held.
+			// Like acme, 1-2 cuts dot, and 1-3 pastes over dot.
+			// A chord ends the button 1 selection.
+			h.setLastClick(multiClick{})
+			switch event.Button {
+			case mouse.ButtonMiddle:
+				cut(h)
+			case mouse.ButtonRight:
+				h.doAsync(edit.Change(dot, h.snarf()))
+			}
+			return
+		}
+		if h.button() == mouse.ButtonNone {
+			h.setButton(event.Button)
+		}
 		switch event.Button {
 		case mouse.ButtonLeft:
-			h.doAsync(edit.Set(edit.Rune(h.where(p)), '.'))
+			at := h.where(p)
+			c := h.lastClick()
+			now := h.now()
+			if c.n < 3 && c.at == at && now.Sub(c.time) < doubleClickTime {
+				c.n++
+			} else {
+				c.n = 1
+			}
+			c.at, c.time = at, now
+			h.setLastClick(c)
+
+			switch rune := edit.Rune(at); c.n {
+			case 1:
+				h.doAsync(edit.Set(rune, '.'))
+			case 2:
+				selectWord(h, at)
+			case 3:
+				h.doAsync(edit.Set(rune.Minus(edit.Line(1)).Plus(edit.Line(1)), '.'))
+			}
 		case mouse.ButtonMiddle:
 			// TODO(eaburns): This makes a blocking RPC,
 			// but it's called from the mouse handler.
@@ -286,22 +845,163 @@ func handleMouse(h mouseHandler, event mouse.Event) {
 			res, err := h.doSync(edit.Print(rune.Minus(re).To(rune.Plus(re))),
 				edit.Set(rune, '.'))
 			if err != nil {
-				log.Println("failed to read command: ", err)
+				h.logf("failed to read command: %v", err)
 				return
 			}
 			if res[0].Error != "" {
-				log.Println("failed to read command: ", res[0].Error)
+				h.logf("failed to read command: %s", res[0].Error)
 				return
 			}
 			h.exec(res[0].Print)
+		case mouse.ButtonRight:
+			rune := edit.Rune(h.where(p))
+			re := edit.Regexp(`[a-zA-Z0-9_.\-+/:]*`) // file name and address characters
+			text := rune.Minus(re).To(rune.Plus(re))
+			h.doThen(func(res []editor.EditResult, err error) {
+				if err != nil {
+					h.logf("failed to read look text: %v", err)
+					return
+				}
+				if res[0].Error != "" {
+					h.logf("failed to read look text: %s", res[0].Error)
+					return
+				}
+				h.look(res[0].Print)
+			}, edit.Print(text), edit.Set(text, '.'))
+		}
+	}
+}
+
+var (
+	openBrackets  = "([{<'\"`"
+	closeBrackets = ")]}>'\"`"
+)
+
+// MaxBracketScan is the maximum number of runes
+// scanned from a double click for a matching bracket.
+const maxBracketScan = 1 << 16
+
+// SelectWord sets dot to the text selected by a double click at the given rune.
+// If the rune before the click is an opening bracket or quote,
+// the text up to the matching close is selected.
+// Otherwise, if the rune after the click is a closing bracket or quote,
+// the text back to the matching open is selected.
+// Otherwise the run of word characters around the click is selected.
+// Only maxBracketScan runes on either side of the click
+// are searched for a matching bracket.
+func selectWord(h mouseHandler, at int64) {
+	rune := edit.Rune(at)
+	word := edit.Regexp(`\w*`)
+	wordAddr := rune.Minus(word).To(rune.Plus(word))
+	from := at - maxBracketScan
+	if from < 0 {
+		from = 0
+	}
+	h.doThen(func(res []editor.EditResult, err error) {
+		if err != nil {
+			h.logf("failed to read brackets: %v", err)
+			h.doAsync(edit.Set(wordAddr, '.'))
+			return
+		}
+		h.doAsync(edit.Set(bracketAddr(res, at, wordAddr), '.'))
+	},
+		edit.Set(edit.Dot, view.TmpMark),
+		edit.Print(edit.Clamp(edit.Rune(at-1)).To(rune)),
+		edit.Print(rune.To(edit.Clamp(edit.Rune(at+1)))),
+		edit.Print(rune.To(edit.Clamp(edit.Rune(at+maxBracketScan)))),
+		edit.Print(edit.Rune(from).To(rune)),
+		edit.Set(edit.Mark(view.TmpMark), '.'))
+}
+
+// BracketAddr returns the address selected by a double click at the given rune,
+// given the results of the edits made by selectWord.
+// If there is no matching bracket, def is returned.
+func bracketAddr(res []editor.EditResult, at int64, def edit.Address) edit.Address {
+	before, after, fwd, back := res[1], res[2], res[3], res[4]
+	rune := edit.Rune(at)
+	if before.Error == "" && fwd.Error == "" && len(before.Print) == 1 {
+		if i := strings.Index(openBrackets, before.Print); i >= 0 {
+			if n, ok := matchBracket(fwd.Print, openBrackets[i], closeBrackets[i], false); ok {
+				return rune.To(edit.Rune(at + n))
+			}
+		}
+	}
+	if after.Error == "" && back.Error == "" && len(after.Print) == 1 {
+		if i := strings.Index(closeBrackets, after.Print); i >= 0 {
+			if n, ok := matchBracket(back.Print, closeBrackets[i], openBrackets[i], true); ok {
+				return edit.Rune(at - n).To(rune)
+			}
+		}
+	}
+	return def
+}
+
+// MatchBracket returns the number of runes of the text
+// before the bracket that closes an open bracket just outside of the text,
+// and whether there is such a bracket.
+// Nested brackets are skipped.
+// If rev is true, the text is scanned from its end to its beginning.
+func matchBracket(text string, open, close byte, rev bool) (int64, bool) {
+	rs := []rune(text)
+	var depth int
+	for i := range rs {
+		r := rs[i]
+		if rev {
+			r = rs[len(rs)-1-i]
+		}
+		switch {
+		case r == rune(close) && depth == 0:
+			return int64(i), true
+		case r == rune(close):
+			depth--
+		case r == rune(open):
+			depth++
 		}
 	}
+	return 0, false
+}
+
+// AutoscrollLines returns the number of lines to scroll
+// while dragging a selection at y
+// in a text box spanning from y0 to y1 with lines of height h.
+// The result is negative to scroll up, positive to scroll down,
+// and 0 if y is within the text box.
+// Its magnitude is proportional to the distance of y beyond the text box.
+func autoscrollLines(y, y0, y1, h int) int {
+	if h <= 0 {
+		h = 1
+	}
+	switch {
+	case y < y0:
+		return -(1 + (y0-y)/h)
+	case y >= y1:
+		return 1 + (y-y1)/h
+	}
+	return 0
+}
+
+// Cut deletes the text at dot and saves it in the snarf buffer.
+// The snarf buffer is set asynchronously, once the text is deleted.
+func cut(h mouseHandler) {
+	h.doThen(func(res []editor.EditResult, err error) {
+		if err != nil {
+			h.logf("failed to cut: %v", err)
+			return
+		}
+		if res[0].Error != "" {
+			h.logf("failed to cut: %s", res[0].Error)
+			return
+		}
+		h.setSnarf(res[0].Print)
+	}, edit.Print(dot), edit.Delete(dot))
 }
 
 type keyHandler interface {
 	doer
 	column() int
 	setColumn(int)
+	// Keymap returns the key bindings.
+	keymap() Keymap
 }
 
 // HandleKey encapsulates the keyboard editing logic for a textBox.
@@ -309,51 +1009,18 @@ func handleKey(h keyHandler, event key.Event) {
 	if event.Direction == key.DirRelease {
 		return
 	}
-	switch event.Code {
-	case key.CodeUpArrow:
-		col := getColumn(h)
-		re := fmt.Sprintf("(?:.?){%d}", col)
-		up := dot.Minus(oneLine).Minus(zero).Plus(edit.Regexp(re)).Plus(zero)
-		h.doAsync(edit.Set(up, '.'))
-		h.setColumn(col)
-	case key.CodeDownArrow:
-		col := getColumn(h)
-		re := fmt.Sprintf("(?:.?){%d}", col)
-		// We use .-1+2, because .+1 does not move dot
-		// if it is at the beginning of an empty line.
-		up := dot.Minus(oneLine).Plus(twoLines).Minus(zero).Plus(edit.Regexp(re)).Plus(zero)
-		h.doAsync(edit.Set(up, '.'))
-		h.setColumn(col)
-	case key.CodeRightArrow:
-		h.doAsync(moveDotRight)
-	case key.CodeLeftArrow:
-		h.doAsync(moveDotLeft)
-	case key.CodeDeleteBackspace:
-		h.doAsync(backspace)
-	case key.CodeReturnEnter:
-		h.doAsync(newline...)
-	case key.CodeTab:
-		h.doAsync(tab...)
-	default:
-		switch event.Modifiers {
-		case 0, key.ModShift:
-			if event.Rune >= 0 {
-				r := string(event.Rune)
-				h.doAsync(edit.Change(dot, r), edit.Set(dot.Plus(zero), '.'))
-			}
-		case key.ModControl:
-			switch event.Rune {
-			case 'a':
-				h.doAsync(edit.Set(dot.Minus(edit.Line(0)).Minus(zero), '.'))
-			case 'e':
-				h.doAsync(edit.Set(dot.Minus(zero).Plus(edit.Regexp("$")), '.'))
-			case 'h':
-				h.doAsync(backspace)
-			case 'u':
-				h.doAsync(backline)
-			case 'w':
-				h.doAsync(backword)
-			}
+	if cmd := boundCommand(h.keymap(), event); cmd != nil {
+		cmd(h)
+		return
+	}
+	switch event.Modifiers {
+	case 0, key.ModShift:
+		if _, special := keyNames[event.Code]; special && event.Code != key.CodeSpacebar {
+			return
+		}
+		if event.Rune >= 0 {
+			r := string(event.Rune)
+			h.doAsync(edit.Change(dot, r), edit.Set(dot.Plus(zero), '.'))
 		}
 	}
 }
@@ -0,0 +1,301 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"io"
+	"net/http"
+
+	"github.com/eaburns/T/ui/syntax"
+)
+
+// A Theme is the set of colors used to draw the windows.
+type Theme struct {
+	// Border is the color of the borders
+	// between columns and between the frames of a column.
+	Border Color `json:"border"`
+
+	// Separator is the color of the lines separating
+	// a sheet's tag, body, scroll bar, and line number gutter.
+	Separator Color `json:"separator"`
+
+	// ColumnTagFG and ColumnTagBG are the text and background colors
+	// of column tags.
+	ColumnTagFG Color `json:"columnTagFg"`
+	ColumnTagBG Color `json:"columnTagBg"`
+
+	// TagFG is the text color of sheet tags.
+	TagFG Color `json:"tagFg"`
+
+	// TagBGs are the background colors of sheet tags.
+	// Each new sheet uses the next color of the list.
+	// If TagBGs is empty, BodyBG is used.
+	TagBGs []Color `json:"tagBgs"`
+
+	// BodyFG and BodyBG are the text and background colors
+	// of sheet bodies.
+	BodyFG Color `json:"bodyFg"`
+	BodyBG Color `json:"bodyBg"`
+
+	// GutterFG and GutterBG are the text and background colors
+	// of the line number gutter.
+	GutterFG Color `json:"gutterFg"`
+	GutterBG Color `json:"gutterBg"`
+
+	// ScrollBG is the background color of scroll bars,
+	// and ScrollThumb is the color of their thumbs.
+	ScrollBG    Color `json:"scrollBg"`
+	ScrollThumb Color `json:"scrollThumb"`
+
+	// Cursor is the color of the cursor.
+	Cursor Color `json:"cursor"`
+
+	// Highlight is the background color of highlighted text,
+	// such as the matches of an incremental search.
+	Highlight Color `json:"highlight"`
+
+	// Busy is the color of the indicator drawn in a tag
+	// while commands executed from it are running.
+	Busy Color `json:"busy"`
+
+	// ReadOnly is the color of the indicator drawn in the tag
+	// of a sheet with a read-only body.
+	ReadOnly Color `json:"readOnly"`
+
+	// FileChanged is the color of the indicator drawn in the tag
+	// of a sheet whose file changed on disk since it was loaded or written.
+	FileChanged Color `json:"fileChanged"`
+
+	// Misspelled is the color of the line drawn under misspelled words.
+	Misspelled Color `json:"misspelled"`
+
+	// DiffDelete and DiffInsert are the background colors
+	// of lines deleted from and inserted into the text
+	// on the left and right of a diff view.
+	// DiffDeleteSpan and DiffInsertSpan are the background colors
+	// of the changed runes within those lines.
+	DiffDelete     Color `json:"diffDelete"`
+	DiffDeleteSpan Color `json:"diffDeleteSpan"`
+	DiffInsert     Color `json:"diffInsert"`
+	DiffInsertSpan Color `json:"diffInsertSpan"`
+
+	// Keyword, String, Number, and Comment are the text colors
+	// of syntax highlighted tokens of the corresponding class.
+	Keyword Color `json:"keyword"`
+	String  Color `json:"string"`
+	Number  Color `json:"number"`
+	Comment Color `json:"comment"`
+
+	// Collaborators are the colors of the carets and labels
+	// of the other editors of a sheet body's buffer.
+	// A label is always drawn in the same color of the list.
+	// If Collaborators is empty, Cursor is used.
+	Collaborators []Color `json:"collaborators"`
+}
+
+// DefaultTheme returns a new Theme with the default, light colors.
+func DefaultTheme() Theme {
+	return Theme{
+		Border:      Color{0x00, 0x00, 0x00},
+		Separator:   Color{0xAA, 0xAA, 0xAA},
+		ColumnTagFG: Color{0x00, 0x00, 0x00},
+		ColumnTagBG: Color{0xF5, 0xF5, 0xF5},
+		TagFG:       Color{0x00, 0x00, 0x00},
+		TagBGs: []Color{
+			{0xE6, 0xF0, 0xFA},
+			{0xE6, 0xFA, 0xF0},
+			{0xF0, 0xE6, 0xFA},
+			{0xF0, 0xFA, 0xE6},
+			{0xFA, 0xE6, 0xF0},
+		},
+		BodyFG:         Color{0x00, 0x00, 0x00},
+		BodyBG:         Color{0xFA, 0xF0, 0xE6},
+		GutterFG:       Color{0x77, 0x77, 0x77},
+		GutterBG:       Color{0xF4, 0xF4, 0xF4},
+		ScrollBG:       Color{0xEE, 0xEE, 0xEE},
+		ScrollThumb:    Color{0xAA, 0xAA, 0xAA},
+		Cursor:         Color{0x00, 0x00, 0x00},
+		Highlight:      Color{0xEE, 0xEE, 0x9E},
+		Busy:           Color{0xCC, 0x66, 0x00},
+		ReadOnly:       Color{0x99, 0x99, 0x99},
+		FileChanged:    Color{0xCC, 0x00, 0x00},
+		Misspelled:     Color{0xCC, 0x00, 0x00},
+		DiffDelete:     Color{0xFA, 0xDC, 0xDC},
+		DiffDeleteSpan: Color{0xF0, 0xAA, 0xAA},
+		DiffInsert:     Color{0xDC, 0xF5, 0xDC},
+		DiffInsertSpan: Color{0xAA, 0xE0, 0xAA},
+		Keyword:        Color{0x00, 0x00, 0x99},
+		String:         Color{0x00, 0x77, 0x00},
+		Number:         Color{0x99, 0x00, 0x99},
+		Comment:        Color{0x77, 0x77, 0x77},
+		Collaborators: []Color{
+			{0xCC, 0x33, 0x33},
+			{0x33, 0x77, 0xCC},
+			{0x33, 0x99, 0x33},
+			{0x99, 0x33, 0xCC},
+			{0xCC, 0x77, 0x00},
+		},
+	}
+}
+
+// DarkTheme returns a new Theme with light text on dark backgrounds.
+func DarkTheme() Theme {
+	return Theme{
+		Border:      Color{0x10, 0x10, 0x10},
+		Separator:   Color{0x50, 0x50, 0x50},
+		ColumnTagFG: Color{0xD0, 0xD0, 0xD0},
+		ColumnTagBG: Color{0x2A, 0x2A, 0x2A},
+		TagFG:       Color{0xD8, 0xD8, 0xD8},
+		TagBGs: []Color{
+			{0x1F, 0x2A, 0x36},
+			{0x1F, 0x36, 0x29},
+			{0x2B, 0x21, 0x36},
+			{0x2F, 0x36, 0x21},
+			{0x36, 0x21, 0x2B},
+		},
+		BodyFG:         Color{0xD4, 0xD4, 0xD4},
+		BodyBG:         Color{0x1E, 0x1E, 0x1E},
+		GutterFG:       Color{0x80, 0x80, 0x80},
+		GutterBG:       Color{0x25, 0x25, 0x25},
+		ScrollBG:       Color{0x2A, 0x2A, 0x2A},
+		ScrollThumb:    Color{0x55, 0x55, 0x55},
+		Cursor:         Color{0xF0, 0xF0, 0xF0},
+		Highlight:      Color{0x5A, 0x5A, 0x2A},
+		Busy:           Color{0xE0, 0x90, 0x30},
+		ReadOnly:       Color{0x70, 0x70, 0x70},
+		FileChanged:    Color{0xE0, 0x50, 0x50},
+		Misspelled:     Color{0xE0, 0x50, 0x50},
+		DiffDelete:     Color{0x48, 0x22, 0x22},
+		DiffDeleteSpan: Color{0x78, 0x30, 0x30},
+		DiffInsert:     Color{0x22, 0x40, 0x22},
+		DiffInsertSpan: Color{0x30, 0x6A, 0x30},
+		Keyword:        Color{0x6C, 0xA0, 0xDC},
+		String:         Color{0x8C, 0xC8, 0x6E},
+		Number:         Color{0xD0, 0x8C, 0xD0},
+		Comment:        Color{0x80, 0x80, 0x80},
+		Collaborators: []Color{
+			{0xE0, 0x60, 0x60},
+			{0x60, 0xA0, 0xE0},
+			{0x70, 0xC0, 0x70},
+			{0xC0, 0x80, 0xE0},
+			{0xE0, 0xA0, 0x40},
+		},
+	}
+}
+
+// TagBG returns the background color of the tag
+// of the nth sheet.
+func (th *Theme) tagBG(n int) color.Color {
+	if len(th.TagBGs) == 0 {
+		return th.BodyBG
+	}
+	return th.TagBGs[n%len(th.TagBGs)]
+}
+
+// CollaboratorColor returns the color of the caret and label
+// of another editor with the given label.
+func (th *Theme) collaboratorColor(label string) color.Color {
+	if len(th.Collaborators) == 0 {
+		return th.Cursor
+	}
+	h := fnv.New32a()
+	io.WriteString(h, label)
+	return th.Collaborators[h.Sum32()%uint32(len(th.Collaborators))]
+}
+
+// SyntaxColor returns the text color of tokens of the given class,
+// and whether there is one.
+func (th *Theme) syntaxColor(c syntax.Class) (color.Color, bool) {
+	switch c {
+	case syntax.Keyword:
+		return th.Keyword, true
+	case syntax.String:
+		return th.String, true
+	case syntax.Number:
+		return th.Number, true
+	case syntax.Comment:
+		return th.Comment, true
+	}
+	return nil, false
+}
+
+// A Color is an opaque RGB color.
+// In JSON, it is a string of the form "#RRGGBB".
+type Color struct {
+	R, G, B uint8
+}
+
+// RGBA implements the color.Color interface.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xFF}.RGBA()
+}
+
+// String returns the color in the form "#RRGGBB".
+func (c Color) String() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (c Color) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (c *Color) UnmarshalText(text []byte) error {
+	if len(text) != 7 || text[0] != '#' {
+		return errors.New("bad color: " + string(text))
+	}
+	d, err := hex.DecodeString(string(text[1:]))
+	if err != nil {
+		return errors.New("bad color: " + string(text))
+	}
+	c.R, c.G, c.B = d[0], d[1], d[2]
+	return nil
+}
+
+// Theme returns the theme used by all windows.
+func (s *Server) Theme() Theme {
+	s.RLock()
+	defer s.RUnlock()
+	return copyTheme(s.theme)
+}
+
+// SetTheme sets the theme used by all windows,
+// and redraws the opened windows with its colors.
+// By default, the theme is that of DefaultTheme.
+func (s *Server) SetTheme(th Theme) {
+	th = copyTheme(&th)
+	s.Lock()
+	defer s.Unlock()
+	s.theme = &th
+	for _, w := range s.windows {
+		w := w
+		w.Send(func() { w.setTheme(&th) })
+	}
+}
+
+func copyTheme(th *Theme) Theme {
+	c := *th
+	c.TagBGs = append([]Color{}, th.TagBGs...)
+	c.Collaborators = append([]Color{}, th.Collaborators...)
+	return c
+}
+
+func (s *Server) getThemeHandler(w http.ResponseWriter, req *http.Request) {
+	respond(w, s.Theme())
+}
+
+func (s *Server) setThemeHandler(w http.ResponseWriter, req *http.Request) {
+	var th Theme
+	if err := json.NewDecoder(req.Body).Decode(&th); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.SetTheme(th)
+}
@@ -0,0 +1,109 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestColorJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Color
+		err  bool
+	}{
+		{json: `"#000000"`, want: Color{}},
+		{json: `"#FFFFFF"`, want: Color{0xFF, 0xFF, 0xFF}},
+		{json: `"#12ab3C"`, want: Color{0x12, 0xAB, 0x3C}},
+		{json: `"#FFF"`, err: true},
+		{json: `"FFFFFF"`, err: true},
+		{json: `"#FFFFFFF"`, err: true},
+		{json: `"#GGGGGG"`, err: true},
+		{json: `""`, err: true},
+	}
+	for _, test := range tests {
+		var c Color
+		err := json.Unmarshal([]byte(test.json), &c)
+		if test.err {
+			if err == nil {
+				t.Errorf("json.Unmarshal(%s, …)=nil, want error", test.json)
+			}
+			continue
+		}
+		if err != nil || c != test.want {
+			t.Errorf("json.Unmarshal(%s, …)=%v, got %v, want %v", test.json, err, c, test.want)
+		}
+	}
+
+	th := DarkTheme()
+	d, err := json.Marshal(th)
+	if err != nil {
+		t.Fatalf("json.Marshal(DarkTheme())=_,%v", err)
+	}
+	var got Theme
+	if err := json.Unmarshal(d, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s, …)=%v", d, err)
+	}
+	if !reflect.DeepEqual(got, th) {
+		t.Errorf("json.Unmarshal(json.Marshal(DarkTheme()))=%+v, want %+v", got, th)
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	themeURL := urlWithPath(s.url, "/", "theme")
+	th, err := GetTheme(themeURL)
+	if err != nil {
+		t.Fatalf("GetTheme(%s)=_,%v", themeURL, err)
+	}
+	if want := DefaultTheme(); !reflect.DeepEqual(th, want) {
+		t.Errorf("GetTheme(%s)=%+v, want %+v", themeURL, th, want)
+	}
+
+	dark := DarkTheme()
+	if err := SetTheme(themeURL, dark); err != nil {
+		t.Fatalf("SetTheme(%s, DarkTheme())=%v", themeURL, err)
+	}
+	if th, err = GetTheme(themeURL); err != nil || !reflect.DeepEqual(th, dark) {
+		t.Errorf("GetTheme(%s)=%+v,%v, want %+v,nil", themeURL, th, err, dark)
+	}
+	wait(w)
+
+	var tagBG, bodyBG, colTagBG interface{}
+	w.Send(func() {
+		tagBG = sheet0.tag.opts.DefaultStyle.BG
+		bodyBG = sheet0.body.opts.DefaultStyle.BG
+		colTagBG = w.columns[0].frames[0].(*columnTag).text.opts.DefaultStyle.BG
+	})
+	wait(w)
+	if want := dark.tagBG(sheet0.tagColor); tagBG != want {
+		t.Errorf("sheet0 tag BG=%v, want %v", tagBG, want)
+	}
+	if bodyBG != dark.BodyBG {
+		t.Errorf("sheet0 body BG=%v, want %v", bodyBG, dark.BodyBG)
+	}
+	if colTagBG != dark.ColumnTagBG {
+		t.Errorf("column tag BG=%v, want %v", colTagBG, dark.ColumnTagBG)
+	}
+
+	// New sheets use the new theme.
+	sheetsURL := urlWithPath(s.url, "/", "window", w.id, "sheets")
+	editorURL := s.editorServer.PathURL("/")
+	newSheet, err := NewSheet(sheetsURL, editorURL)
+	if err != nil {
+		t.Fatalf("NewSheet(%s, %s)=_,%v", sheetsURL, editorURL, err)
+	}
+	s.uiServer.RLock()
+	f := s.uiServer.sheets[newSheet.ID]
+	s.uiServer.RUnlock()
+	w.Send(func() { bodyBG = f.body.opts.DefaultStyle.BG })
+	wait(w)
+	if bodyBG != dark.BodyBG {
+		t.Errorf("new sheet body BG=%v, want %v", bodyBG, dark.BodyBG)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+)
+
+// HoverDelay is how long the mouse must rest over a sheet body
+// before the annotations of the text under it are shown in a tooltip.
+const hoverDelay = 500 * time.Millisecond
+
+// An Annotator annotates text of a sheet's body,
+// for example, with compile errors or version control history.
+//
+// Path is the file named in the sheet's tag,
+// or "" if the tag does not name a file.
+// BufferURL is the URL of the body's buffer on the editor server,
+// and span is the span of the rune under the mouse.
+// If the annotator has an annotation for the span,
+// it returns the annotation text and true.
+// Otherwise it returns false.
+//
+// Annotators are called in their own goroutine,
+// so they may block, for example, to run a command.
+type Annotator func(path string, bufferURL *url.URL, span edit.Span) (string, bool)
+
+// SetAnnotators sets the annotators of the text under the mouse.
+// The annotations of all annotators that have one
+// are shown together in a tooltip
+// while the mouse rests over the text of a sheet's body.
+// By default, there are no annotators.
+func (s *Server) SetAnnotators(as []Annotator) {
+	s.Lock()
+	s.annotators = append([]Annotator{}, as...)
+	s.Unlock()
+}
+
+// A hover is the state of the mouse resting over a window.
+type hover struct {
+	// P is the point of the mouse,
+	// and since is when it moved there.
+	p     image.Point
+	since time.Time
+
+	// Asked is whether the annotators were called for the hover.
+	asked bool
+
+	// Tip is the tooltip text, shown at p,
+	// or "" if no tooltip is shown.
+	tip string
+}
+
+// MoveHover restarts the hover at a new mouse point,
+// dismissing the tooltip, if any.
+// The return value is whether to redraw the window.
+func (w *window) moveHover(p image.Point) bool {
+	shown := w.hover.tip != ""
+	w.hover = hover{p: p, since: w.server.now()}
+	return shown
+}
+
+// TickHover calls the annotators for the text under the mouse
+// if the mouse has rested over a sheet body for hoverDelay.
+// The annotators are called in a new goroutine.
+// If any of them annotates the text, the tooltip is shown,
+// unless the mouse moved in the meantime.
+func (w *window) tickHover() {
+	h := &w.hover
+	if h.asked || h.since.IsZero() || w.server.since(h.since) < hoverDelay {
+		return
+	}
+	h.asked = true
+	s, ok := w.inFocus.(*sheet)
+	if !ok || s.col == nil || s.subFocus != handler(s.body) {
+		return
+	}
+	w.server.RLock()
+	as := w.server.annotators
+	w.server.RUnlock()
+	if len(as) == 0 {
+		return
+	}
+	i := s.body.where(h.p)
+	span := edit.Span{i, i + 1}
+	path := s.filePath()
+	bufferURL := *s.body.bufferURL
+	since := h.since
+	go func() {
+		var tips []string
+		for _, a := range as {
+			if tip, ok := a(path, &bufferURL, span); ok && tip != "" {
+				tips = append(tips, tip)
+			}
+		}
+		if len(tips) == 0 {
+			return
+		}
+		w.Send(func() {
+			if w.hover.since == since {
+				w.hover.tip = strings.Join(tips, "\n")
+			}
+		})
+	}()
+}
+
+// DrawTooltip draws the tooltip, if any,
+// below and to the right of the hover point,
+// kept within the window.
+func (w *window) drawTooltip(scr screen.Screen, win screen.Window) {
+	if w.hover.tip != "" {
+		w.drawTip(scr, win, w.hover.tip, w.hover.p)
+	}
+}
+
+// DrawTip draws text in a bordered box
+// below and to the right of a point,
+// kept within the window.
+func (w *window) drawTip(scr screen.Screen, win screen.Window, tip string, at image.Point) {
+	lines := strings.Split(tip, "\n")
+	pad := w.px(textPadding)
+	var width int
+	for _, l := range lines {
+		if x := font.MeasureString(w.face, l).Ceil(); x > width {
+			width = x
+		}
+	}
+	height := w.face.Metrics().Height.Ceil() * len(lines)
+	size := image.Pt(width+2*pad+1, height+2*pad)
+	if max := w.Dx() / 2; size.X > max {
+		size.X = max
+	}
+
+	p := at.Add(image.Pt(0, w.face.Metrics().Height.Ceil()))
+	if p.X+size.X > w.Max.X {
+		p.X = w.Max.X - size.X
+	}
+	if p.Y+size.Y > w.Max.Y {
+		p.Y = at.Y - size.Y
+	}
+	r := image.Rectangle{Min: p, Max: p.Add(size)}.Intersect(w.bounds())
+	if r.Empty() {
+		return
+	}
+
+	setter := text.NewSetter(text.Options{
+		DefaultStyle: text.Style{
+			Face: w.face,
+			FG:   w.theme.ColumnTagFG,
+			BG:   w.theme.ColumnTagBG,
+		},
+		Size:     r.Size(),
+		TabWidth: 4,
+		Padding:  pad,
+	})
+	defer setter.Release()
+	setter.Add([]byte(tip))
+	t := setter.Set()
+	t.Draw(r.Min, scr, win)
+	t.Release()
+	drawBorder(r, w.theme.Border, win)
+}
@@ -0,0 +1,71 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestTooltip(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	type call struct {
+		path string
+		URL  string
+		span edit.Span
+	}
+	calls := make(chan call, 10)
+	s.uiServer.SetAnnotators([]Annotator{
+		func(path string, bufferURL *url.URL, span edit.Span) (string, bool) {
+			calls <- call{path, bufferURL.String(), span}
+			return "annotation", true
+		},
+		func(string, *url.URL, edit.Span) (string, bool) { return "", false },
+	})
+
+	tip := func() (str string) {
+		w.Send(func() { str = w.hover.tip })
+		wait(w)
+		return str
+	}
+
+	p := center(sheet0)
+	mouseTo(w, p)
+	wait(w)
+	if str := tip(); str != "" {
+		t.Errorf("before hoverDelay, tip=%q, want \"\"", str)
+	}
+
+	// Pretend the mouse has rested for hoverDelay.
+	w.Send(func() { w.hover.since = time.Now().Add(-hoverDelay) })
+	var c call
+	select {
+	case c = <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the annotator was not called")
+	}
+	if c.path != "" || c.URL != sheet0.body.bufferURL.String() || c.span[1] != c.span[0]+1 {
+		t.Errorf("annotator called with %+v, want path \"\", URL %s, and a one-rune span",
+			c, sheet0.body.bufferURL)
+	}
+	var str string
+	for i := 0; i < 100 && str == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+		str = tip()
+	}
+	if str != "annotation" {
+		t.Errorf("after hoverDelay, tip=%q, want %q", str, "annotation")
+	}
+
+	// Moving the mouse dismisses the tooltip.
+	mouseTo(w, p.Add(p))
+	if str := tip(); str != "" {
+		t.Errorf("after moving, tip=%q, want \"\"", str)
+	}
+}
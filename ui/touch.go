@@ -0,0 +1,213 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/touch"
+)
+
+const (
+	// TapSlop is how far a touch may move, at the default DPI,
+	// and still be a tap or a long press.
+	tapSlop = 10 // px
+
+	// LongPressDelay is how long a touch must rest
+	// to be a long press.
+	longPressDelay = 500 * time.Millisecond
+
+	// PinchStep is the ratio by which the distance
+	// between two touches must change to zoom the font by a point.
+	pinchStep = 1.25
+)
+
+// A touches is the state of the touches on a window.
+//
+// Touches are translated into mouse events:
+// 	A tap is a button 1 click.
+// 	A long press is a button 2 click, which executes the text under it.
+// 	Dragging one finger is a button 1 drag, which selects text.
+// 	Dragging two fingers scrolls with the mouse wheel.
+// 	Pinching two fingers zooms the font of a sheet, like control and the wheel.
+// The mouse events are sent to the window,
+// so they are handled, and recorded in macros, like real mouse events.
+type touches struct {
+	// Seqs are the sequences of the touches on the window, in order of their beginning,
+	// and ps are their points.
+	seqs []touch.Sequence
+	ps   map[touch.Sequence]image.Point
+
+	// Start is the point where the first touch began,
+	// and since is when it began.
+	start image.Point
+	since time.Time
+
+	// Dragging is whether the first touch is dragging button 1.
+	dragging bool
+
+	// Done is whether the gesture of the current touches
+	// has been handled, and the touches are ignored until they all end.
+	done bool
+
+	// Center is the point between two touches when the wheel last scrolled,
+	// and dist is the distance between them when the font last zoomed.
+	center image.Point
+	dist   float64
+}
+
+// Touch handles a touch event, translating it into mouse events.
+func (w *window) touch(e touch.Event) {
+	t := &w.touches
+	p := image.Pt(int(e.X), int(e.Y))
+	switch e.Type {
+	case touch.TypeBegin:
+		if t.ps == nil {
+			t.ps = make(map[touch.Sequence]image.Point)
+		}
+		t.seqs = append(t.seqs, e.Sequence)
+		t.ps[e.Sequence] = p
+		switch len(t.seqs) {
+		case 1:
+			t.start, t.since = p, w.server.now()
+			t.dragging, t.done = false, false
+		case 2:
+			if t.dragging {
+				w.sendMouse(t.ps[t.seqs[0]], mouse.ButtonLeft, mouse.DirRelease, 0)
+				t.dragging = false
+			}
+			t.done = true
+			t.center, t.dist = t.twoFinger()
+		}
+
+	case touch.TypeMove:
+		if _, ok := t.ps[e.Sequence]; !ok {
+			return
+		}
+		t.ps[e.Sequence] = p
+		switch {
+		case len(t.seqs) == 1 && !t.done:
+			w.dragTouch(p)
+		case len(t.seqs) >= 2:
+			w.scrollTouch()
+			w.pinchTouch()
+		}
+
+	case touch.TypeEnd:
+		if _, ok := t.ps[e.Sequence]; !ok {
+			return
+		}
+		if len(t.seqs) == 1 && !t.done {
+			if t.dragging {
+				w.sendMouse(p, mouse.ButtonLeft, mouse.DirRelease, 0)
+			} else {
+				w.click(t.start, mouse.ButtonLeft)
+			}
+		}
+		delete(t.ps, e.Sequence)
+		for i, s := range t.seqs {
+			if s == e.Sequence {
+				t.seqs = append(t.seqs[:i], t.seqs[i+1:]...)
+				break
+			}
+		}
+		if len(t.seqs) == 1 {
+			// Lifting one of two fingers does not begin a new gesture.
+			t.done = true
+		}
+	}
+}
+
+// TickTouch clicks button 2 if a single touch
+// has rested for longPressDelay.
+func (w *window) tickTouch() {
+	t := &w.touches
+	if len(t.seqs) != 1 || t.done || t.dragging || w.server.since(t.since) < longPressDelay {
+		return
+	}
+	t.done = true
+	w.click(t.start, mouse.ButtonMiddle)
+}
+
+// DragTouch drags button 1 with a single touch,
+// once it moves farther than tapSlop from where it began.
+func (w *window) dragTouch(p image.Point) {
+	t := &w.touches
+	if !t.dragging {
+		d := p.Sub(t.start)
+		if n := w.px(tapSlop); d.X*d.X+d.Y*d.Y <= n*n {
+			return
+		}
+		t.dragging = true
+		w.sendMouse(t.start, mouse.ButtonNone, mouse.DirNone, 0)
+		w.sendMouse(t.start, mouse.ButtonLeft, mouse.DirPress, 0)
+	}
+	w.sendMouse(p, mouse.ButtonNone, mouse.DirNone, 0)
+}
+
+// ScrollTouch scrolls with the wheel for each line height
+// that the point between two touches moved vertically.
+func (w *window) scrollTouch() {
+	t := &w.touches
+	c, _ := t.twoFinger()
+	h := w.face.Metrics().Height.Round()
+	if h <= 0 {
+		return
+	}
+	w.sendMouse(c, mouse.ButtonNone, mouse.DirNone, 0)
+	for ; c.Y-t.center.Y >= h; t.center.Y += h {
+		w.sendMouse(c, mouse.ButtonWheelUp, mouse.DirStep, 0)
+	}
+	for ; t.center.Y-c.Y >= h; t.center.Y -= h {
+		w.sendMouse(c, mouse.ButtonWheelDown, mouse.DirStep, 0)
+	}
+}
+
+// PinchTouch zooms the font, like control and the wheel,
+// each time the distance between two touches changes by pinchStep.
+func (w *window) pinchTouch() {
+	t := &w.touches
+	c, d := t.twoFinger()
+	if t.dist <= 0 {
+		t.dist = d
+		return
+	}
+	switch {
+	case d >= t.dist*pinchStep:
+		w.sendMouse(c, mouse.ButtonWheelUp, mouse.DirStep, key.ModControl)
+		t.dist = d
+	case d <= t.dist/pinchStep:
+		w.sendMouse(c, mouse.ButtonWheelDown, mouse.DirStep, key.ModControl)
+		t.dist = d
+	}
+}
+
+// TwoFinger returns the point between the first two touches
+// and the distance between them.
+func (t *touches) twoFinger() (image.Point, float64) {
+	p0, p1 := t.ps[t.seqs[0]], t.ps[t.seqs[1]]
+	d := p1.Sub(p0)
+	return p0.Add(p1).Div(2), math.Hypot(float64(d.X), float64(d.Y))
+}
+
+// Click sends the mouse events of moving to p
+// and clicking a button there.
+func (w *window) click(p image.Point, b mouse.Button) {
+	w.sendMouse(p, mouse.ButtonNone, mouse.DirNone, 0)
+	w.sendMouse(p, b, mouse.DirPress, 0)
+	w.sendMouse(p, b, mouse.DirRelease, 0)
+}
+
+func (w *window) sendMouse(p image.Point, b mouse.Button, dir mouse.Direction, mods key.Modifiers) {
+	w.Send(mouse.Event{
+		X:         float32(p.X),
+		Y:         float32(p.Y),
+		Button:    b,
+		Modifiers: mods,
+		Direction: dir,
+	})
+}
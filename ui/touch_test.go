@@ -0,0 +1,121 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/touch"
+)
+
+func TestTouchTap(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	// A non-zero dot waits for the text to be laid out.
+	setTextDot(t, w, sheet0.body, strings.Repeat("abc\n", 100), edit.Span{1, 1})
+
+	var p image.Point
+	w.Send(func() { p = image.Pt(sheet0.scroll.Max.X+10, sheet0.Max.Y-10) })
+	wait(w)
+	sendTouch(w, 0, touch.TypeBegin, p)
+	sendTouch(w, 0, touch.TypeEnd, p)
+	wait(w)
+	wait(w)
+
+	// The tap clicks button 1, moving dot to the tapped line.
+	var dot int64
+	for i := 0; i < 100 && dot <= 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { dot = sheet0.body.dot0 })
+		wait(w)
+	}
+	if dot <= 1 {
+		t.Errorf("after tap, dot=%d, want >1", dot)
+	}
+}
+
+func TestTouchLongPress(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, strings.Repeat("Wrap\n", 100), edit.Span{1, 1})
+
+	var p image.Point
+	w.Send(func() { p = image.Pt(sheet0.scroll.Max.X+10, sheet0.Max.Y-10) })
+	wait(w)
+	sendTouch(w, 0, touch.TypeBegin, p)
+	var wrap bool
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		wait(w)
+		w.Send(func() { wrap = sheet0.wrap() })
+		wait(w)
+		if !wrap {
+			break
+		}
+	}
+	sendTouch(w, 0, touch.TypeEnd, p)
+	wait(w)
+	if wrap {
+		t.Errorf("after long press on Wrap, wrap=true, want false")
+	}
+}
+
+func TestTouchTwoFingers(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	setTextDot(t, w, sheet0.body, strings.Repeat("line\n", 1000), edit.Span{0, 0})
+
+	var p0, p1 image.Point
+	var h int
+	w.Send(func() {
+		c := image.Pt(sheet0.Min.X+sheet0.Dx()/2, sheet0.Max.Y-20)
+		p0, p1 = c.Sub(image.Pt(20, 0)), c.Add(image.Pt(20, 0))
+		h = w.face.Metrics().Height.Round()
+	})
+	wait(w)
+
+	// Dragging two fingers up scrolls down.
+	sendTouch(w, 0, touch.TypeBegin, p0)
+	sendTouch(w, 1, touch.TypeBegin, p1)
+	var d image.Point
+	for d.Y > -5*h {
+		d.Y -= 5
+		sendTouch(w, 0, touch.TypeMove, p0.Add(d))
+		sendTouch(w, 1, touch.TypeMove, p1.Add(d))
+	}
+	wait(w)
+	wait(w)
+	var line int64
+	for i := 0; i < 100 && line <= 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		line = sheet0.body.view.Line()
+	}
+	if line <= 1 {
+		t.Errorf("after two-finger drag, first line=%d, want >1", line)
+	}
+
+	// Spreading two fingers zooms in.
+	sendTouch(w, 0, touch.TypeMove, p0.Add(d).Sub(image.Pt(5, 0)))
+	sendTouch(w, 1, touch.TypeMove, p1.Add(d).Add(image.Pt(5, 0)))
+	sendTouch(w, 0, touch.TypeEnd, p0)
+	sendTouch(w, 1, touch.TypeEnd, p1)
+	wait(w)
+	wait(w)
+	var font Font
+	w.Send(func() { font = sheet0.font })
+	wait(w)
+	if want := (Font{Size: defaultFontSize + 1}); font != want {
+		t.Errorf("after pinch, font=%+v, want %+v", font, want)
+	}
+}
+
+func sendTouch(w *window, seq touch.Sequence, typ touch.Type, p image.Point) {
+	w.Send(touch.Event{X: float32(p.X), Y: float32(p.Y), Sequence: seq, Type: typ})
+}
@@ -3,6 +3,14 @@
 // Package ui implements the T text editor UI.
 package ui
 
+import (
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
 // A NewWindowRequest requests a new window be created.
 type NewWindowRequest struct {
 	// Width is the requested width.
@@ -32,6 +40,137 @@ type NewSheetRequest struct {
 	// If URL is an existing buffer, that buffer will be used as the sheet body.
 	// Otherwise, a new buffer is created on the editor server for the body.
 	URL string `json:"url"`
+
+	// Column, if non-nil, is the index of the column,
+	// counting from 0 at the left, to which the sheet is added.
+	// If Column is nil or out of range,
+	// the server's Placement chooses the column.
+	Column *int `json:"column,omitempty"`
+}
+
+// A MoveSheetRequest requests a sheet be moved to a window.
+type MoveSheetRequest struct {
+	// WindowPath is the path of the window's resource.
+	WindowPath string `json:"windowPath"`
+
+	// X is the horizontal location in the window of the sheet's new column,
+	// given as a fraction of the window width.
+	X float64 `json:"x"`
+
+	// Y is the top of the sheet in its new column,
+	// given as a fraction of the column height.
+	Y float64 `json:"y"`
+}
+
+// A DropRequest requests that files dropped on a window be opened.
+type DropRequest struct {
+	// Paths are the paths of the dropped files.
+	// Each file is opened in a new sheet.
+	Paths []string `json:"paths"`
+
+	// X is the horizontal location of the drop,
+	// given as a fraction of the window width.
+	X float64 `json:"x"`
+
+	// Y is the vertical location of the drop,
+	// given as a fraction of the column height.
+	Y float64 `json:"y"`
+}
+
+// An ExecEnv is the execution environment
+// of commands executed from a window.
+type ExecEnv struct {
+	// Dir is the working directory of commands executed
+	// from column tags and from sheets without a file name.
+	// Relative file names of sheets are relative to Dir.
+	// If Dir is empty, the working directory of the server is used.
+	Dir string `json:"dir"`
+
+	// Env are environment variables of the form key=value,
+	// added to the environment of the server.
+	Env []string `json:"env"`
+}
+
+// A SearchRequest requests a search of the sheets of a window.
+type SearchRequest struct {
+	// Regexp is the regular expression searched for.
+	Regexp string `json:"regexp"`
+}
+
+// A SearchResult is the result of a search of the sheets of a window.
+type SearchResult struct {
+	// Sheet is the new sheet to which the matches were written.
+	Sheet Sheet `json:"sheet"`
+
+	// Matches are the matches, in the order of the sheets in the window.
+	Matches []SearchMatch `json:"matches"`
+}
+
+// A SearchMatch is a match of a search in the body of a sheet.
+type SearchMatch struct {
+	// SheetID is the ID of the sheet.
+	SheetID string `json:"sheetId"`
+
+	// Name is the file name in the sheet's tag,
+	// or the sheet's path if the tag does not name a file.
+	Name string `json:"name"`
+
+	// Line is the line number of the start of the match,
+	// where the first line is 1.
+	Line int `json:"line"`
+
+	// Span is the rune offsets of the match in the sheet's body.
+	Span edit.Span `json:"span"`
+
+	// Text is the text of the line containing the start of the match.
+	Text string `json:"text"`
+}
+
+// A WrapState is whether the body of a sheet soft wraps long lines.
+type WrapState struct {
+	// Wrap is whether lines too long to fit the width of the body
+	// are broken onto the following lines.
+	// If not, they are clipped at the right edge of the body.
+	Wrap bool `json:"wrap"`
+}
+
+// A Font is a font face and size.
+type Font struct {
+	// Name is the name of a built-in font face:
+	// bold, italic, mono, or regular.
+	// If Name is empty, the face is inherited:
+	// a sheet uses the face of its window,
+	// and a window uses regular.
+	Name string `json:"name,omitempty"`
+	// Size is the size of the font in points.
+	// If Size is 0, the size is inherited:
+	// a sheet uses the size of its window,
+	// and a window uses 11.
+	Size float64 `json:"size,omitempty"`
+}
+
+// A ReadOnlyState is whether the body of a sheet is read-only.
+type ReadOnlyState struct {
+	// ReadOnly is whether typing, mouse chords, and the sheet's commands
+	// are prevented from changing the body's text.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// A QuitRequest requests that the server quit.
+type QuitRequest struct {
+	// Force is whether to quit
+	// even if sheets have changes not written to their files.
+	Force bool `json:"force"`
+}
+
+// A QuitResult is the result of a request to quit the server.
+type QuitResult struct {
+	// Quit is whether the server quit.
+	Quit bool `json:"quit"`
+
+	// Modified are the sheets with changes
+	// not written to the files named in their tags.
+	Modified []Sheet `json:"modified"`
 }
 
 // A Window describes an opened window.
@@ -60,3 +199,148 @@ type Sheet struct {
 	// BodyURL is the URL of the body's buffer.
 	BodyURL string `json:"bodyUrl"`
 }
+
+// A Layout describes the windows, columns, and sheets of a server.
+// It is used to save a working session and to later restore it.
+type Layout struct {
+	// Windows are the layouts of the server's windows.
+	Windows []WindowLayout `json:"windows"`
+}
+
+// A WindowLayout describes the layout of a window.
+type WindowLayout struct {
+	// Width is the width of the window.
+	Width int `json:"width"`
+
+	// Height is the height of the window.
+	Height int `json:"height"`
+
+	// Columns are the window's columns, from left to right.
+	Columns []ColumnLayout `json:"columns"`
+}
+
+// A ColumnLayout describes the layout of a column.
+type ColumnLayout struct {
+	// X is the left side of the column
+	// given as a fraction of the window width.
+	X float64 `json:"x"`
+
+	// Tag is the text of the column's tag.
+	Tag string `json:"tag"`
+
+	// Sheets are the column's sheets, from top to bottom.
+	Sheets []SheetLayout `json:"sheets"`
+}
+
+// A SheetLayout describes the layout of a sheet.
+type SheetLayout struct {
+	// Y is the top of the sheet
+	// given as a fraction of the column height.
+	Y float64 `json:"y"`
+
+	// Tag is the text of the sheet's tag.
+	Tag string `json:"tag"`
+
+	// BodyURL is the URL of the body's buffer.
+	//
+	// When a layout is loaded, if the buffer no longer exists,
+	// the body uses a new buffer,
+	// and if the tag names a file, the file is read into the body.
+	BodyURL string `json:"bodyUrl"`
+
+	// Scroll is the rune offset of the first rune visible in the body.
+	Scroll int64 `json:"scroll"`
+
+	// Dot is the rune span of the body's dot.
+	Dot [2]int64 `json:"dot"`
+
+	// NoWrap is whether the body clips long lines
+	// instead of soft wrapping them.
+	NoWrap bool `json:"noWrap,omitempty"`
+}
+
+// A Geometry describes the arrangement of a window's columns and sheets.
+type Geometry struct {
+	// Width is the width of the window.
+	// It is ignored when setting the geometry.
+	Width int `json:"width"`
+
+	// Height is the height of the window.
+	// It is ignored when setting the geometry.
+	Height int `json:"height"`
+
+	// Columns are the window's columns, from left to right.
+	Columns []ColumnGeometry `json:"columns"`
+}
+
+// A ColumnGeometry describes the arrangement of a column.
+type ColumnGeometry struct {
+	// X is the left side of the column
+	// given as a fraction of the window width.
+	X float64 `json:"x"`
+
+	// Sheets are the column's sheets, from top to bottom.
+	Sheets []SheetGeometry `json:"sheets"`
+}
+
+// A SheetGeometry describes the position of a sheet.
+type SheetGeometry struct {
+	// ID is the ID of the sheet.
+	ID string `json:"id"`
+
+	// Y is the top of the sheet
+	// given as a fraction of the column height.
+	Y float64 `json:"y"`
+}
+
+// A SetGeometryRequest requests changes to the geometry of a window.
+// The changes are made in the order of the fields.
+type SetGeometryRequest struct {
+	// Columns, if non-nil, are the new columns of the window.
+	// There must be one ColumnGeometry for each column of the window,
+	// and each sheet of the window must be listed in exactly one column.
+	// Sheets can be moved between the columns of the window,
+	// but not between windows.
+	Columns []ColumnGeometry `json:"columns,omitempty"`
+
+	// Maximize, if non-empty, is the ID of a sheet
+	// to grow to fill its column,
+	// shrinking the other sheets of the column to their minimum height.
+	Maximize string `json:"maximize,omitempty"`
+
+	// Minimize, if non-empty, is the ID of a sheet
+	// to shrink to its minimum height.
+	// The space is given to the sheet below it,
+	// or to the sheet above it if it is the bottom sheet.
+	Minimize string `json:"minimize,omitempty"`
+}
+
+// A Macro is a recorded sequence of keyboard and mouse events.
+type Macro []MacroEvent
+
+// A MacroEvent is a keyboard or mouse event of a Macro.
+// Exactly one of Key and Mouse is non-nil.
+type MacroEvent struct {
+	// Delay is the time elapsed since the previous event,
+	// or since recording began for the first event.
+	Delay time.Duration `json:"delay"`
+
+	// Key is a keyboard event.
+	Key *key.Event `json:"key,omitempty"`
+
+	// Mouse is a mouse event.
+	// Its coordinates are relative to the top-left corner
+	// of the frame in focus when recording began.
+	Mouse *mouse.Event `json:"mouse,omitempty"`
+}
+
+// A PlayRequest requests that a macro be replayed in a window.
+type PlayRequest struct {
+	// Name is the name of the macro.
+	Name string `json:"name"`
+
+	// Speed is the factor by which the replay
+	// is faster than the macro was recorded.
+	// If Speed is 0 or less, the events are replayed without delay.
+	Speed float64 `json:"speed"`
+}
@@ -15,12 +15,13 @@ import (
 
 	"github.com/eaburns/T/editor"
 	"github.com/eaburns/T/editor/editortest"
+	"github.com/eaburns/T/ui/headless"
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/shiny/screen"
 )
 
 func TestWindowList(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -47,7 +48,7 @@ func TestWindowList(t *testing.T) {
 }
 
 func TestNewWindow(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -69,7 +70,7 @@ func TestNewWindow(t *testing.T) {
 
 // TODO(eaburns): test that we are actually getting BadRequest errors.
 func TestNewWindow_BadRequest(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 	winsURL := urlWithPath(s.url, "/", "windows")
 	var win Window
@@ -80,7 +81,7 @@ func TestNewWindow_BadRequest(t *testing.T) {
 }
 
 func TestCloseWindow(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -111,7 +112,7 @@ func TestCloseWindow(t *testing.T) {
 }
 
 func TestCloseWindow_NotFound(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 	notFoundURL := urlWithPath(s.url, "/", "window", "notfound")
 	if err := Close(notFoundURL); err != ErrNotFound {
@@ -121,7 +122,7 @@ func TestCloseWindow_NotFound(t *testing.T) {
 
 func TestNewColumn(t *testing.T) {
 	const N = 3
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -144,7 +145,7 @@ func TestNewColumn(t *testing.T) {
 }
 
 func TestNewColumn_NotFound(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 	notFoundURL := urlWithPath(s.url, "/", "window", "notfound", "columns")
 	if err := NewColumn(notFoundURL, 0.5); err != ErrNotFound {
@@ -154,7 +155,7 @@ func TestNewColumn_NotFound(t *testing.T) {
 
 // TODO(eaburns): test that we are actually getting BadRequest errors.
 func TestNewColumn_BadRequest(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -170,7 +171,7 @@ func TestNewColumn_BadRequest(t *testing.T) {
 }
 
 func TestNewColumn_WindowEdges(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -221,7 +222,7 @@ func TestNewColumn_WindowEdges(t *testing.T) {
 }
 
 func TestNewColumn_DoesNotFit(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -245,7 +246,7 @@ func TestNewColumn_DoesNotFit(t *testing.T) {
 }
 
 func TestNewSheet(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -272,7 +273,7 @@ func TestNewSheet(t *testing.T) {
 }
 
 func TestNewSheet_NotFound(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -308,7 +309,7 @@ func TestNewSheet_NotFound(t *testing.T) {
 
 // TODO(eaburns): test that we are actually getting BadRequest errors.
 func TestNewSheet_BadRequest(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -338,7 +339,7 @@ func TestNewSheet_BadRequest(t *testing.T) {
 }
 
 func TestNewSheet_DoesNotFit(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -368,7 +369,7 @@ func TestNewSheet_DoesNotFit(t *testing.T) {
 }
 
 func TestCloseSheet(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	winsURL := urlWithPath(s.url, "/", "windows")
@@ -401,7 +402,7 @@ func TestCloseSheet(t *testing.T) {
 }
 
 func TestCloseSheet_NotFound(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 	notFoundURL := urlWithPath(s.url, "/", "sheet", "notfound")
 	if err := Close(notFoundURL); err != ErrNotFound {
@@ -410,7 +411,7 @@ func TestCloseSheet_NotFound(t *testing.T) {
 }
 
 func TestSheetList(t *testing.T) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	defer s.close()
 
 	// Empty.
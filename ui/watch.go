@@ -0,0 +1,167 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/editor"
+)
+
+// WatchInterval is how often the files of a window's sheets
+// are checked for changes on disk.
+const watchInterval = time.Second
+
+// SetAutosave sets how long the body of a sheet must be idle
+// before it is written to its file, if it is modified.
+// Only sheets whose body was loaded from or written to
+// the file named in the tag are autosaved,
+// and not if the file changed on disk since.
+// If d is 0, sheets are not autosaved.
+// By default, sheets are not autosaved.
+func (s *Server) SetAutosave(d time.Duration) {
+	s.Lock()
+	s.autosave = d
+	s.Unlock()
+}
+
+// SetFile sets the file that the body was last loaded from or written to,
+// and the modification time of the file at that point.
+// It must be called in the UI goroutine of the sheet's window.
+func (s *sheet) setFile(name string, modTime time.Time) {
+	s.file = name
+	s.fileTime = modTime
+	s.fileChanged = false
+}
+
+// Write writes the body to the named file
+// with line endings of the given style,
+// marks the body unmodified if it did not change since it was printed,
+// and sets the file as the sheet's file in the UI goroutine of w.
+// It makes blocking RPCs, so it must not be called in a UI goroutine.
+func (s *sheet) write(w *window, name string, eol edit.EOL) error {
+	res, err := s.body.view.Do(edit.Print(edit.All))
+	if err == nil && res[0].Error != "" {
+		err = fmt.Errorf("%s", res[0].Error)
+	}
+	if err == nil {
+		text := res[0].Print
+		if eol == edit.CRLF {
+			text = edit.RestoreEOL(text, eol)
+		}
+		err = ioutil.WriteFile(name, []byte(text), 0666)
+	}
+	if err == nil {
+		err = setUnmodified(s, res[0].Sequence)
+	}
+	if err != nil {
+		return err
+	}
+	setFileFromDisk(w, s, name)
+	return nil
+}
+
+// SetFileFromDisk sets the named file as the sheet's file,
+// with its modification time read from disk,
+// in the UI goroutine of w.
+// The file is also set for the other sheets viewing the body's buffer,
+// in the UI goroutines of their windows.
+func setFileFromDisk(w *window, s *sheet, name string) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return
+	}
+	w.Send(func() { s.setFile(name, fi.ModTime()) })
+	w.server.RLock()
+	defer w.server.RUnlock()
+	for _, f := range w.server.bufferSheets(s.body.bufferURL) {
+		if f != s && f.win != nil {
+			f := f
+			f.win.Send(func() { f.setFile(name, fi.ModTime()) })
+		}
+	}
+}
+
+// A watched is the file of a sheet that is checked for changes.
+type watched struct {
+	sheet   *sheet
+	name    string
+	modTime time.Time
+	// Save is whether to autosave the sheet if it is modified.
+	save bool
+	// EOL is the line ending style with which to autosave the sheet.
+	eol edit.EOL
+}
+
+// TickWatch checks the files of the window's sheets for changes on disk
+// if watchInterval elapsed since they were last checked,
+// and autosaves the sheets that have been idle long enough.
+// The files are checked and written in a new goroutine.
+func (w *window) tickWatch() {
+	if w.server.since(w.lastWatch) < watchInterval {
+		return
+	}
+	w.lastWatch = w.server.now()
+	w.server.RLock()
+	autosave := w.server.autosave
+	w.server.RUnlock()
+
+	var ws []watched
+	for _, c := range w.columns {
+		for _, f := range c.frames[1:] {
+			s, ok := f.(*sheet)
+			if !ok || s.file == "" || s.fileChanged {
+				continue
+			}
+			var save bool
+			if autosave > 0 && !s.changed.IsZero() && w.server.since(s.changed) >= autosave && !s.body.readOnly {
+				s.changed = time.Time{}
+				save = s.filePath() == s.file
+			}
+			ws = append(ws, watched{sheet: s, name: s.file, modTime: s.fileTime, save: save, eol: s.eol})
+		}
+	}
+	if len(ws) == 0 {
+		return
+	}
+	go func() {
+		for _, f := range ws {
+			fi, err := os.Stat(f.name)
+			switch {
+			case err == nil && !fi.ModTime().Equal(f.modTime):
+				f := f
+				w.Send(func() { f.sheet.fileChangedOnDisk(f.name, f.modTime) })
+			case err == nil && f.save:
+				f.sheet.autosave(w, f.name, f.eol)
+			}
+		}
+	}()
+}
+
+// FileChangedOnDisk marks the sheet's file as changed on disk
+// if it is still the named file with the given modification time,
+// and suggests reloading it with Get.
+func (s *sheet) fileChangedOnDisk(name string, modTime time.Time) {
+	if s.file != name || !s.fileTime.Equal(modTime) || s.fileChanged {
+		return
+	}
+	s.fileChanged = true
+	s.errorf("%s changed on disk; Get to reload it", name)
+}
+
+// Autosave writes the body to the named file,
+// with line endings of the given style, if it is modified.
+// It makes blocking RPCs, so it must not be called in a UI goroutine.
+func (s *sheet) autosave(w *window, name string, eol edit.EOL) {
+	buf, err := editor.BufferInfo(s.body.bufferURL)
+	if err == nil && buf.Modified {
+		err = s.write(w, name, eol)
+	}
+	if err != nil {
+		w.Send(func() { s.errorf("autosave %s: %v", name, err) })
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestWatchFile(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	dir, err := ioutil.TempDir("", "T_watch_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("hello"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q, …)=%v", file, err)
+	}
+
+	// The file state is read in the UI goroutine,
+	// and checking it forces the files to be watched.
+	type state struct {
+		file    string
+		changed bool
+	}
+	check := func() (st state) {
+		w.Send(func() {
+			st = state{sheet0.file, sheet0.fileChanged}
+			w.lastWatch = time.Time{}
+		})
+		wait(w)
+		return st
+	}
+	poll := func(want state) bool {
+		for i := 0; i < 100; i++ {
+			if check() == want {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	w.Send(func() {
+		sheet0.setTagFileName(file)
+		sheet0.tag.exec("Get")
+	})
+	if !poll(state{file: file}) {
+		t.Fatalf("after Get, file state=%+v, want %+v", check(), state{file: file})
+	}
+
+	// Set the modification time in the future,
+	// in case the file system time stamps are coarse.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, future, future); err != nil {
+		t.Fatalf("os.Chtimes(%q, …)=%v", file, err)
+	}
+	if !poll(state{file: file, changed: true}) {
+		t.Errorf("after change, file state=%+v, want %+v", check(), state{file: file, changed: true})
+	}
+
+	w.Send(func() { sheet0.tag.exec("Get") })
+	if !poll(state{file: file}) {
+		t.Errorf("after reload, file state=%+v, want %+v", check(), state{file: file})
+	}
+}
+
+func TestAutosave(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	dir, err := ioutil.TempDir("", "T_watch_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("hello"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q, …)=%v", file, err)
+	}
+	s.uiServer.SetAutosave(time.Millisecond)
+
+	w.Send(func() {
+		sheet0.setTagFileName(file)
+		sheet0.tag.exec("Get")
+	})
+	var name string
+	for i := 0; i < 100 && name != file; i++ {
+		w.Send(func() { name = sheet0.file })
+		wait(w)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name != file {
+		t.Fatalf("after Get, sheet0.file=%q, want %q", name, file)
+	}
+
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "goodbye")); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	var text string
+	for i := 0; i < 100 && text != "goodbye"; i++ {
+		w.Send(func() { w.lastWatch = time.Time{} })
+		wait(w)
+		time.Sleep(10 * time.Millisecond)
+		d, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile(%q)=_,%v", file, err)
+		}
+		text = string(d)
+	}
+	if text != "goodbye" {
+		t.Errorf("after autosave, file=%q, want %q", text, "goodbye")
+	}
+}
+
+func TestNormalizeEOL(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	dir, err := ioutil.TempDir("", "T_watch_test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir(…)=_,%v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("hello\r\nworld\r\n"), 0666); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q, …)=%v", file, err)
+	}
+	s.uiServer.SetNormalizeEOL(true)
+
+	w.Send(func() {
+		sheet0.setTagFileName(file)
+		sheet0.tag.exec("Get")
+	})
+	var name string
+	var eol edit.EOL
+	for i := 0; i < 100 && name != file; i++ {
+		w.Send(func() { name, eol = sheet0.file, sheet0.eol })
+		wait(w)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if name != file || eol != edit.CRLF {
+		t.Fatalf("after Get, sheet0.file=%q, sheet0.eol=%v, want %q, %v", name, eol, file, edit.CRLF)
+	}
+	res, err := sheet0.body.view.Do(edit.Print(edit.All))
+	if err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	if got := res[0].Print; got != "hello\nworld\n" {
+		t.Errorf("after Get, body=%q, want %q", got, "hello\nworld\n")
+	}
+
+	if _, err := sheet0.body.view.Do(edit.Change(edit.All, "goodbye\nworld\n")); err != nil {
+		t.Fatalf("sheet0.body.view.Do(…)=_,%v", err)
+	}
+	w.Send(func() { sheet0.tag.exec("Put") })
+	const want = "goodbye\r\nworld\r\n"
+	var text string
+	for i := 0; i < 100 && text != want; i++ {
+		time.Sleep(10 * time.Millisecond)
+		d, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile(%q)=_,%v", file, err)
+		}
+		text = string(d)
+	}
+	if text != want {
+		t.Errorf("after Put, file=%q, want %q", text, want)
+	}
+}
@@ -5,7 +5,6 @@ package ui
 import (
 	"bufio"
 	"image"
-	"image/color"
 	"image/draw"
 	"io"
 	"log"
@@ -15,6 +14,7 @@ import (
 	"time"
 
 	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/cursor"
 	"golang.org/x/exp/shiny/screen"
 	"golang.org/x/image/font"
 	"golang.org/x/mobile/event/key"
@@ -22,6 +22,7 @@ import (
 	"golang.org/x/mobile/event/mouse"
 	"golang.org/x/mobile/event/paint"
 	"golang.org/x/mobile/event/size"
+	"golang.org/x/mobile/event/touch"
 )
 
 // A handler is an interactive portion of a window
@@ -68,13 +69,14 @@ type handler interface {
 	drawLast(scr screen.Screen, win screen.Window)
 }
 
+// Sizes in px are for the default DPI.
+// Except for borderWidth, they are scaled
+// by the scale factor of the window with (*window).px.
 const (
 	minFrameWidth = 20 // px
 	borderWidth   = 1  // px
 )
 
-var borderColor = color.Black
-
 const (
 	ptPerInch  = 72
 	defaultDPI = 96
@@ -86,13 +88,62 @@ type window struct {
 	screen.Window
 	face font.Face
 	dpi  float64
+	// Font is the font of sheets that do not set their own.
+	// Its unset fields are those of the default font.
+	font Font
+	// Scale is the ratio of the window's DPI to the default DPI.
+	scale float64
+	// Theme is the window's color theme.
+	// It is not modified; a new theme replaces it.
+	theme *Theme
 	image.Rectangle
 
+	// Tag is the tag across the top of the window,
+	// above the columns.
+	tag     *windowTag
 	columns []*column
 	xs      []float64
 
 	inFocus handler
 	p       image.Point
+	// Cursor is the shape of the mouse cursor over the window.
+	cursor cursor.Shape
+
+	// Running are the commands executed from the window
+	// that have not yet exited.
+	running []*command
+	// ExecEnv is the execution environment of commands.
+	execEnv ExecEnv
+
+	// Hover is the mouse resting over the window,
+	// and the tooltip shown for it.
+	hover hover
+
+	// Touches are the touches on the window,
+	// translated into mouse events.
+	touches touches
+
+	// TextDrag is the text being dragged, or nil.
+	textDrag *textDrag
+
+	// Jumps is the history of locations from which dot jumped.
+	jumps jumpList
+
+	// History is the commands executed from the tags of the window,
+	// oldest first.
+	history []string
+
+	// Recording is the macro being recorded, or nil.
+	recording *recording
+	// Playing is whether a macro is being replayed.
+	playing bool
+
+	// LastWatch is when the files of the sheets were last checked for changes.
+	lastWatch time.Time
+
+	// Closed is closed once the window is closed
+	// and all of its frames are closed.
+	closed chan struct{}
 }
 
 func newWindow(id string, s *Server, size image.Point) (*window, error) {
@@ -103,6 +154,9 @@ func newWindow(id string, s *Server, size image.Point) (*window, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.RLock()
+	theme := s.theme
+	s.RUnlock()
 	w := &window{
 		id:        id,
 		server:    s,
@@ -110,11 +164,21 @@ func newWindow(id string, s *Server, size image.Point) (*window, error) {
 		Rectangle: image.Rect(0, 0, size.X, size.Y),
 
 		// dpi is set to the true value by a size.Event.
-		dpi: defaultDPI,
+		dpi:    defaultDPI,
+		scale:  1,
+		theme:  theme,
+		closed: make(chan struct{}),
 	}
 	w.getDPI()
+	if w.tag, err = newWindowTag(w); err != nil {
+		win.Release()
+		w.face.Close()
+		return nil, err
+	}
+	w.tag.setBounds(w.tagBounds())
 	c, err := newColumn(w)
 	if err != nil {
+		w.tag.close()
 		win.Release()
 		w.face.Close()
 		return nil, err
@@ -125,12 +189,16 @@ func newWindow(id string, s *Server, size image.Point) (*window, error) {
 }
 
 // GetDPI reads and discards events until a size.Event, from which the DPI is read.
+// If the size.Event has no PixelsPerPt, the DPI remains defaultDPI.
 func (w *window) getDPI() {
 	for {
 		switch e := w.NextEvent().(type) {
 		case size.Event:
-			w.dpi = float64(e.PixelsPerPt * ptPerInch)
-			w.face = newFace(w.dpi)
+			if e.PixelsPerPt > 0 {
+				w.dpi = float64(e.PixelsPerPt * ptPerInch)
+				w.scale = w.dpi / defaultDPI
+			}
+			w.face = newFace(w.font, w.dpi)
 			return
 		}
 	}
@@ -138,6 +206,10 @@ func (w *window) getDPI() {
 
 type closeEvent struct{}
 
+// A changeEvent is sent to a window
+// when the text of one of its text boxes changes.
+type changeEvent struct{ text *textBox }
+
 func (w *window) events() {
 	events := make(chan interface{})
 	go func() {
@@ -152,40 +224,61 @@ func (w *window) events() {
 	}()
 
 	const drawTime = 33 * time.Millisecond
-	timer := time.NewTimer(drawTime)
-	defer timer.Stop()
+	tick := w.server.clock.After(drawTime)
 
 	var click int
 	var redraw bool
+	// Dirty are frames to redraw
+	// if the entire window is not redrawn.
+	var dirty []frame
 	for {
 		select {
-		case <-timer.C:
+		case <-tick:
 			if w.inFocus != nil && w.inFocus.tick(w) {
 				redraw = true
 			}
-			if !redraw {
-				timer.Reset(drawTime)
+			w.tickHover()
+			w.tickTouch()
+			w.tickWatch()
+			w.tickSpelling()
+			if w.hover.tip != "" && len(dirty) > 0 {
+				// The tooltip overlays the dirty frames.
+				redraw = true
+			}
+			if !redraw && len(dirty) == 0 {
+				tick = w.server.clock.After(drawTime)
 				break
 			}
-			w.draw(w.server.screen, w.Window)
-			if w.inFocus != nil {
-				w.inFocus.drawLast(w.server.screen, w.Window)
+			if redraw {
+				w.draw(w.server.screen, w.Window)
+				if w.inFocus != nil {
+					w.inFocus.drawLast(w.server.screen, w.Window)
+				}
+				w.drawTooltip(w.server.screen, w.Window)
+				w.drawTextDrag(w.server.screen, w.Window)
+			} else {
+				for _, f := range dirty {
+					f.draw(w.server.screen, w.Window)
+				}
 			}
 			w.Publish()
-			timer.Reset(drawTime)
+			tick = w.server.clock.After(drawTime)
 			redraw = false
+			dirty = dirty[:0]
 
 		case e, ok := <-events:
 			if !ok {
 				for _, c := range w.columns {
 					c.close()
 				}
+				w.tag.close()
 				// TODO(eaburns): Don't call this if the frame is not detached.
-				if f, ok := w.inFocus.(frame); ok {
+				if f, ok := w.inFocus.(frame); ok && f != frame(w.tag) {
 					f.close()
 				}
 				w.face.Close()
 				w.Release()
+				close(w.closed)
 				return
 			}
 			switch e := e.(type) {
@@ -201,23 +294,61 @@ func (w *window) events() {
 			case paint.Event:
 				redraw = true
 
+			case changeEvent:
+				// Only redraw the frame of the changed text,
+				// unless a dragged frame overlays the window.
+				f := w.frameOf(e.text)
+				if s, ok := f.(*sheet); ok && e.text == s.body {
+					s.changed = w.server.now()
+				}
+				if f == nil || w.dragging() {
+					redraw = true
+				} else if !containsFrame(dirty, f) {
+					dirty = append(dirty, f)
+				}
+
 			case size.Event:
+				// Some size.Events have no PixelsPerPt;
+				// they do not change the DPI.
+				if dpi := float64(e.PixelsPerPt * ptPerInch); e.PixelsPerPt > 0 && dpi != w.dpi {
+					w.setDPI(dpi)
+				}
 				w.setBoundsAfterResize(image.Rectangle{Max: e.Size()})
 
 			case key.Event:
+				// The release of a key must not dismiss
+				// a tooltip shown by its press.
+				if e.Direction != key.DirRelease && w.moveHover(w.p) {
+					redraw = true
+				}
 				if w.inFocus != nil && w.inFocus.key(w, e) {
 					redraw = true
 				}
+				w.record(e)
+
+			case touch.Event:
+				w.touch(e)
+
+			case CompositionEvent:
+				if c, ok := w.inFocus.(composer); ok && c.compose(w, e) {
+					redraw = true
+				}
 
 			case mouse.Event:
 				var dir mouse.Direction
 				w.p, dir = image.Pt(int(e.X), int(e.Y)), e.Direction
+				if w.moveHover(w.p) {
+					redraw = true
+				}
 				switch dir {
 				case mouse.DirPress:
 					click++
 				case mouse.DirRelease:
 					click--
 				}
+				if dir == mouse.DirNone && click == 0 {
+					w.server.dropIn(w, w.p)
+				}
 				if dir == mouse.DirNone && click == 0 && w.refocus() {
 					redraw = true
 				}
@@ -231,26 +362,161 @@ func (w *window) events() {
 				if dir != mouse.DirNone && w.refocus() {
 					redraw = true
 				}
+				w.record(e)
 			}
 		}
 	}
 }
 
+// FrameOf returns the window tag or the frame in one of the window's columns
+// that contains the text box, or nil if there is none.
+func (w *window) frameOf(t *textBox) frame {
+	if w.tag.text == t {
+		return w.tag
+	}
+	for _, c := range w.columns {
+		for _, f := range c.frames {
+			switch f := f.(type) {
+			case *columnTag:
+				if f.text == t {
+					return f
+				}
+			case *sheet:
+				if f.tag == t || f.body == t {
+					return f
+				}
+			case *diffView:
+				if f.tag == t {
+					return f
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Dragging returns whether the frame in focus
+// is a column or sheet being dragged,
+// or whether text is being dragged.
+func (w *window) dragging() bool {
+	if w.textDrag != nil {
+		return true
+	}
+	switch f := w.inFocus.(type) {
+	case *columnTag:
+		return f.col.win == nil
+	case *sheet:
+		return f.col == nil
+	}
+	return false
+}
+
+func containsFrame(fs []frame, f frame) bool {
+	for _, g := range fs {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDPI changes the DPI of the window,
+// for example, when it moves to a monitor with a different resolution.
+// The font face and the sizes of the window's text boxes are updated;
+// the caller must lay out the window again.
+func (w *window) setDPI(dpi float64) {
+	old := w.face
+	w.dpi = dpi
+	w.scale = dpi / defaultDPI
+	w.face = newFace(w.font, dpi)
+	w.updateFrames()
+	old.Close()
+}
+
+// SetTheme changes the color theme of the window.
+func (w *window) setTheme(th *Theme) {
+	w.theme = th
+	w.updateFrames()
+}
+
+func (w *window) updateFrames() {
+	updateFrame(w, w.tag)
+	for _, c := range w.columns {
+		for _, f := range c.frames {
+			updateFrame(w, f)
+		}
+	}
+	if s, ok := w.inFocus.(*sheet); ok && s.col == nil {
+		// The sheet is being dragged.
+		updateFrame(w, s)
+	}
+}
+
+// UpdateFrame updates a frame after it moves to the window,
+// or after a change to the window's DPI, font, or theme.
+func updateFrame(w *window, f frame) {
+	th := w.theme
+	switch f := f.(type) {
+	case *windowTag:
+		f.text.setWindow(w)
+		f.text.setColors(th.ColumnTagFG, th.ColumnTagBG)
+	case *columnTag:
+		f.text.setWindow(w)
+		f.text.setColors(th.ColumnTagFG, th.ColumnTagBG)
+	case *sheet:
+		f.tag.setWindow(w)
+		f.tag.setColors(th.TagFG, th.tagBG(f.tagColor))
+		f.body.setWindow(w)
+		f.body.setColors(th.BodyFG, th.BodyBG)
+		f.updateFace(w)
+		if f.minimap != nil {
+			f.minimap.setWindow(w)
+		}
+	case *diffView:
+		f.tag.setWindow(w)
+		f.tag.setColors(th.TagFG, th.tagBG(f.tagColor))
+	}
+}
+
+// Px returns a size in px scaled by the window's scale factor.
+func (w *window) px(n int) int { return scalePx(n, w.scale) }
+
+func scalePx(n int, scale float64) int {
+	m := int(float64(n)*scale + 0.5)
+	if m < 1 && n > 0 {
+		m = 1
+	}
+	return m
+}
+
 func (w *window) close() {
 	w.Send(closeEvent{})
 }
 
 func (w *window) refocus() bool {
 	prev := w.inFocus
-	for _, c := range w.columns {
-		if w.p.In(c.bounds()) {
-			w.inFocus = c.focus(w.p)
-			break
+	if sp := splitterAt(w, w.p); sp != nil {
+		if s, ok := prev.(*splitter); !ok || !s.same(sp) {
+			w.inFocus = sp
+		}
+	} else if w.p.In(w.tag.bounds()) {
+		w.inFocus = w.tag
+	} else {
+		for _, c := range w.columns {
+			if w.p.In(c.bounds()) {
+				w.inFocus = c.focus(w.p)
+				break
+			}
 		}
 	}
 	if prev == w.inFocus {
 		return false
 	}
+	shape := cursor.Arrow
+	if sp, ok := w.inFocus.(*splitter); ok {
+		shape = sp.cursor()
+	}
+	w.setCursor(shape)
 	if prev != nil {
 		prev.changeFocus(w, false)
 	}
@@ -260,10 +526,44 @@ func (w *window) refocus() bool {
 	return true
 }
 
+// SetCursor sets the shape of the mouse cursor,
+// if the window's backend can change it.
+func (w *window) setCursor(shape cursor.Shape) {
+	if shape == w.cursor {
+		return
+	}
+	w.cursor = shape
+	if s, ok := w.Window.(cursor.Setter); ok {
+		s.SetCursor(shape)
+	}
+}
+
 func (w *window) bounds() image.Rectangle { return w.Rectangle }
 
+// TagBounds returns the bounds of the window tag.
+func (w *window) tagBounds() image.Rectangle {
+	b := w.bounds()
+	b.Max.Y = b.Min.Y + w.tag.minHeight() - borderWidth
+	if b.Max.Y > w.Max.Y {
+		b.Max.Y = w.Max.Y
+	}
+	return b
+}
+
+// ColumnsBounds returns the bounds of the window below its tag.
+func (w *window) columnsBounds() image.Rectangle {
+	b := w.bounds()
+	b.Min.Y += w.tag.minHeight()
+	if b.Min.Y > b.Max.Y {
+		b.Min.Y = b.Max.Y
+	}
+	return b
+}
+
 func (w *window) setBounds(bounds image.Rectangle) {
 	w.Rectangle = bounds
+	w.tag.setBounds(w.tagBounds())
+	bounds = w.columnsBounds()
 	width := float64(bounds.Dx())
 	for i := len(w.columns) - 1; i >= 0; i-- {
 		c := w.columns[i]
@@ -280,6 +580,8 @@ func (w *window) setBounds(bounds image.Rectangle) {
 
 func (w *window) setBoundsAfterResize(bounds image.Rectangle) {
 	w.Rectangle = bounds
+	w.tag.setBounds(w.tagBounds())
+	bounds = w.columnsBounds()
 	width := float64(bounds.Dx())
 	for i := len(w.columns) - 1; i >= 0; i-- {
 		c := w.columns[i]
@@ -295,22 +597,32 @@ func (w *window) setBoundsAfterResize(bounds image.Rectangle) {
 }
 
 func (w *window) draw(scr screen.Screen, win screen.Window) {
+	w.tag.draw(scr, win)
+	b := w.bounds()
+	b.Min.Y, b.Max.Y = w.tag.Max.Y, w.columnsBounds().Min.Y
+	win.Fill(b, w.theme.Border, draw.Over)
+
 	for i, c := range w.columns {
 		c.draw(scr, win)
 		if i == len(w.columns)-1 {
 			continue
 		}
 		d := w.columns[i+1]
-		b := w.bounds()
+		b := c.bounds()
 		b.Min.X = c.bounds().Max.X
 		b.Max.X = d.bounds().Min.X
-		win.Fill(b, borderColor, draw.Over)
+		win.Fill(b, w.theme.Border, draw.Over)
 	}
 }
 
 // AddFrame adds the frame to the last column of the window.
 func (w *window) addFrame(f frame) {
-	c := w.columns[len(w.columns)-1]
+	w.addFrameTo(w.columns[len(w.columns)-1], f)
+}
+
+// AddFrameTo adds the frame to the bottom of a column of the window,
+// splitting the last frame of the column in half.
+func (w *window) addFrameTo(c *column, f frame) {
 	var y int
 	if len(w.columns) == 1 && len(c.frames) == 1 {
 		y = minHeight(w.columns[0].frames[0].(*columnTag).text.opts)
@@ -318,11 +630,28 @@ func (w *window) addFrame(f frame) {
 	if len(c.frames) > 1 {
 		f := c.frames[len(c.frames)-1]
 		b := f.bounds()
-		y = b.Min.Y + b.Dy()/2
+		y = b.Min.Y - c.Min.Y + b.Dy()/2
 	}
 	c.addFrame(float64(y)/float64(c.Dy()), f)
 }
 
+// AddFrameBelow adds a frame to the column of another frame,
+// splitting the other frame in half.
+// It returns false if the other frame is not in the window.
+func (w *window) addFrameBelow(f, below frame) bool {
+	for _, c := range w.columns {
+		for _, g := range c.frames {
+			if g != below {
+				continue
+			}
+			b := g.bounds()
+			y := b.Min.Y - c.Min.Y + b.Dy()/2
+			return c.addFrame(float64(y)/float64(c.Dy()), f)
+		}
+	}
+	return false
+}
+
 func (w *window) deleteFrame(f frame) {
 	for _, c := range w.columns {
 		for _, g := range c.frames {
@@ -374,30 +703,30 @@ func (w *window) addColumn(xfrac float64, c *column) bool {
 		w.columns = []*column{c}
 		w.xs = []float64{0.0}
 		c.win = w
-		c.setBounds(w.bounds())
+		c.setBounds(w.columnsBounds())
 		return true
 	}
 	x := int(float64(w.Dx()) * xfrac)
 	i, splitCol := columnAt(w, x)
 
 	// Push away from the window edges.
-	if x < minFrameWidth {
-		x = minFrameWidth
+	if x < w.px(minFrameWidth) {
+		x = w.px(minFrameWidth)
 		xfrac = float64(x) / float64(w.Dx())
 	}
-	if max := w.Dx() - minFrameWidth - borderWidth; x > max {
+	if max := w.Dx() - w.px(minFrameWidth) - borderWidth; x > max {
 		x = max
 		xfrac = float64(x) / float64(w.Dx())
 	}
 
-	if leftSize := x - splitCol.Min.X; leftSize < minFrameWidth {
-		if !slideLeft(w, i, minFrameWidth-leftSize) {
-			x += minFrameWidth - leftSize
+	if leftSize := x - splitCol.Min.X; leftSize < w.px(minFrameWidth) {
+		if !slideLeft(w, i, w.px(minFrameWidth)-leftSize) {
+			x += w.px(minFrameWidth) - leftSize
 			xfrac = float64(x) / float64(w.Dx())
 		}
 	}
-	if rightSize := splitCol.Max.X - x - borderWidth; rightSize < minFrameWidth {
-		if !slideRight(w, i, minFrameWidth-rightSize) {
+	if rightSize := splitCol.Max.X - x - borderWidth; rightSize < w.px(minFrameWidth) {
+		if !slideRight(w, i, w.px(minFrameWidth)-rightSize) {
 			return false
 		}
 	}
@@ -442,8 +771,8 @@ func slideLeft(w *window, i int, delta int) bool {
 		return false
 	}
 	x := w.columns[i].Min.X - delta
-	if sz := x - w.columns[i-1].Min.X; sz < minFrameWidth {
-		if !slideLeft(w, i-1, minFrameWidth-sz) {
+	if sz := x - w.columns[i-1].Min.X; sz < w.px(minFrameWidth) {
+		if !slideLeft(w, i-1, w.px(minFrameWidth)-sz) {
 			return false
 		}
 	}
@@ -456,8 +785,8 @@ func slideRight(w *window, i int, delta int) bool {
 		return false
 	}
 	x := w.columns[i].Max.X + delta
-	if sz := w.columns[i+1].Max.X - borderWidth - x; sz < minFrameWidth {
-		if !slideRight(w, i+1, minFrameWidth-sz) {
+	if sz := w.columns[i+1].Max.X - borderWidth - x; sz < w.px(minFrameWidth) {
+		if !slideRight(w, i+1, w.px(minFrameWidth)-sz) {
 			return false
 		}
 	}
@@ -465,8 +794,20 @@ func slideRight(w *window, i int, delta int) bool {
 	return true
 }
 
-// TODO(eaburns): take a *sheet as an optional argument for setting T_SHEET.
-func (w *window) exec(commandLine string) {
+// A command is a running command executed from a tag or body.
+type command struct {
+	name string
+	cmd  *exec.Cmd
+	// Tag is the tag showing that the command is running.
+	tag *textBox
+}
+
+// Exec executes a command line
+// with the given working directory and environment.
+// The output of the command is written to the window's output sheet
+// as it is produced.
+// While the command runs, the tag shows a busy indicator.
+func (w *window) exec(tag *textBox, dir string, env []string, commandLine string) {
 	scanner := bufio.NewScanner(strings.NewReader(commandLine))
 	scanner.Split(bufio.ScanWords)
 	var words []string
@@ -479,18 +820,40 @@ func (w *window) exec(commandLine string) {
 
 	out, in, err := os.Pipe()
 	if err != nil {
-		log.Println("failed to open pipe:", err)
+		w.logf("failed to open pipe: %v", err)
 		return
 	}
 	go pipeOutput(w, out)
 
 	cmd := exec.Command(words[0], words[1:]...)
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "T_WINDOW_PATH="+windowPath(w))
+	cmd.Dir = dir
+	cmd.Env = env
 	cmd.Stdout = in
 	cmd.Stderr = in
-	cmd.Run()
+	if err := cmd.Start(); err != nil {
+		in.Close()
+		str := err.Error() + "\n"
+		w.Send(func() { w.output(str) })
+		return
+	}
+	c := &command{name: words[0], cmd: cmd, tag: tag}
+	w.Send(func() {
+		w.running = append(w.running, c)
+		tag.busy++
+	})
+	if err := cmd.Wait(); err != nil {
+		w.logf("%s: %v", commandLine, err)
+	}
 	in.Close()
+	w.Send(func() {
+		for i := range w.running {
+			if w.running[i] == c {
+				w.running = append(w.running[:i], w.running[i+1:]...)
+				break
+			}
+		}
+		tag.busy--
+	})
 }
 
 func pipeOutput(w *window, out io.ReadCloser) {
@@ -501,7 +864,7 @@ func pipeOutput(w *window, out io.ReadCloser) {
 		case err == io.EOF:
 			return
 		case err != nil:
-			log.Println("read error:", err)
+			w.logf("failed to read command output: %v", err)
 			return
 		default:
 			str := string(buf[:n])
@@ -515,27 +878,40 @@ func pipeOutput(w *window, out io.ReadCloser) {
 // Output must be called in the window's UI goroutine.
 func (w *window) output(str string) *sheet {
 	const outSheetName = "+output"
-	var out *sheet
+	out := w.specialSheet(outSheetName)
+	if out == nil {
+		return nil
+	}
+	// Output is appended even if the sheet is read-only.
+	out.body.view.DoAsync(edit.Append(edit.End, str))
+	return out
+}
+
+// SpecialSheet returns the window's sheet with the given tag file name,
+// such as +output, creating it if the window has none.
+// It returns nil if the sheet cannot be created.
+//
+// SpecialSheet must be called in the window's UI goroutine.
+func (w *window) specialSheet(name string) *sheet {
+	var s *sheet
 	w.server.Lock()
-	for _, s := range w.server.sheets {
-		if s.win == w && s.tagFileName() == outSheetName {
-			out = s
+	for _, f := range w.server.sheets {
+		if f.win == w && f.tagFileName() == name {
+			s = f
 			break
 		}
 	}
-	if out != nil {
+	if s != nil {
 		w.server.Unlock()
-	} else {
-		var err error
-		out, err = w.server.newSheet(w, w.server.editorURL)
-		w.server.Unlock()
-		if err != nil {
-			log.Printf("failed to create %s sheet: %v", outSheetName, err)
-			return nil
-		}
-		out.setTagFileName(outSheetName)
-		w.refocus()
+		return s
 	}
-	out.body.doAsync(edit.Append(edit.End, str))
-	return out
+	s, err := w.server.newSheet(w, w.server.editorURL, nil)
+	w.server.Unlock()
+	if err != nil {
+		log.Printf("failed to create %s sheet: %v", name, err)
+		return nil
+	}
+	s.setTagFileName(name)
+	w.refocus()
+	return s
 }
@@ -6,11 +6,17 @@ import (
 	"fmt"
 	"image"
 	"path"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/eaburns/T/edit"
 	"github.com/eaburns/T/editor"
+	"github.com/eaburns/T/editor/view"
+	"github.com/eaburns/T/ui/headless"
+	"golang.org/x/image/font"
 	"golang.org/x/mobile/event/key"
 	"golang.org/x/mobile/event/lifecycle"
 	"golang.org/x/mobile/event/mouse"
@@ -38,11 +44,7 @@ func TestDraw(t *testing.T) {
 	s, w := makeTestUI()
 	defer s.close()
 	w.Send(paint.Event{})
-	tick := time.NewTicker(10 * time.Second)
-	defer tick.Stop()
-	select {
-	case <-w.Window.(*stubWindow).publish:
-	case <-tick.C:
+	if _, err := w.Window.(*headless.Window).WaitFrame(1, 10*time.Second); err != nil {
 		t.Errorf("timed out waiting for publish")
 	}
 }
@@ -115,6 +117,52 @@ func TestFocus(t *testing.T) {
 	}
 }
 
+func TestWindowTag(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	if w.tag.Min != w.Min || w.tag.Dx() != w.Dx() || w.tag.Empty() {
+		t.Errorf("window tag bounds=%v, want the top of %v", w.tag.bounds(), w.bounds())
+	}
+	for i, c := range w.columns {
+		if c.Min.Y <= w.tag.Max.Y || c.Max.Y != w.Max.Y {
+			t.Errorf("columns[%d].bounds()=%v, want below the window tag %v",
+				i, c.bounds(), w.tag.bounds())
+		}
+	}
+
+	mouseTo(w, center(w.tag))
+	wait(w)
+	if w.inFocus != handler(w.tag) {
+		t.Errorf("focus=%v, want the window tag", w.inFocus)
+	}
+
+	var n int
+	w.Send(func() {
+		w.tag.text.exec("Newcol")
+		n = len(w.columns)
+	})
+	wait(w)
+	if n != 4 {
+		t.Errorf("after Newcol, len(w.columns)=%d, want 4", n)
+	}
+
+	done := make(chan struct{})
+	s.uiServer.SetDoneHandler(func() { close(done) })
+	w.Send(func() { w.tag.text.exec("Exit") })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Exit did not call the done handler")
+	}
+	s.uiServer.RLock()
+	n = len(s.uiServer.windows)
+	s.uiServer.RUnlock()
+	if n != 0 {
+		t.Errorf("after Exit, %d windows, want 0", n)
+	}
+}
+
 // TestDeleteFrame tests shift+2click to delete a sheet or column.
 func TestDeleteFrame(t *testing.T) {
 	s, w := makeTestUI()
@@ -248,7 +296,7 @@ func TestMove_DoesNotFit(t *testing.T) {
 
 	// Shrink the window height such that each column can only fit two sheets.
 	minHeight := w.columns[0].frames[1].minHeight()
-	h := minHeight*2 + minHeight/2
+	h := w.tag.minHeight() + minHeight*2 + minHeight/2
 	w.Send(size.Event{WidthPx: 800, HeightPx: h})
 
 	// Wait for resize before using sheet dimensions.
@@ -636,6 +684,341 @@ func TestSheetBodyText(t *testing.T) {
 	}
 }
 
+func TestThumb(t *testing.T) {
+	r := image.Rect(0, 0, 10, 100)
+	tests := []struct {
+		l0   int64
+		n    int
+		size int64
+		want image.Rectangle
+	}{
+		{l0: 0, n: 0, size: 0, want: r},
+		{l0: 0, n: 10, size: 10, want: r},
+		{l0: 0, n: 5, size: 10, want: image.Rect(0, 0, 10, 50)},
+		{l0: 5, n: 5, size: 10, want: image.Rect(0, 50, 10, 100)},
+		{l0: 25, n: 50, size: 100, want: image.Rect(0, 25, 10, 75)},
+		{l0: 50, n: 0, size: 100, want: image.Rect(0, 50, 10, 50+minThumbSize)},
+		{l0: 100, n: 0, size: 100, want: image.Rect(0, 100-minThumbSize, 10, 100)},
+	}
+	for _, test := range tests {
+		if got := thumb(r, test.l0, test.n, test.size); got != test.want {
+			t.Errorf("thumb(%v, %d, %d, %d)=%v, want %v", r, test.l0, test.n, test.size, got, test.want)
+		}
+	}
+}
+
+func TestScrollBar(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var lines string
+	for i := 0; i < 100; i++ {
+		lines += strconv.Itoa(i%10) + "\n"
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, lines)); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+
+	lineHeight := sheet0.body.opts.DefaultStyle.Face.Metrics().Height.Round()
+	p := sheet0.scroll.Min.Add(image.Pt(1, 5*lineHeight+1))
+	mouseTo(w, p)
+	click(w, p, mouse.ButtonRight)
+	// The line at the click, line 5, scrolls to the top.
+	waitViewStart(t, sheet0.body, 10)
+
+	click(w, p, mouse.ButtonLeft)
+	waitViewStart(t, sheet0.body, 0)
+}
+
+func TestAutoscrollLines(t *testing.T) {
+	tests := []struct {
+		y, y0, y1, h int
+		want         int
+	}{
+		{y: 50, y0: 10, y1: 100, h: 10, want: 0},
+		{y: 10, y0: 10, y1: 100, h: 10, want: 0},
+		{y: 99, y0: 10, y1: 100, h: 10, want: 0},
+		{y: 9, y0: 10, y1: 100, h: 10, want: -1},
+		{y: 0, y0: 10, y1: 100, h: 10, want: -2},
+		{y: -25, y0: 10, y1: 100, h: 10, want: -4},
+		{y: 100, y0: 10, y1: 100, h: 10, want: 1},
+		{y: 109, y0: 10, y1: 100, h: 10, want: 1},
+		{y: 110, y0: 10, y1: 100, h: 10, want: 2},
+		{y: 135, y0: 10, y1: 100, h: 10, want: 4},
+		{y: 101, y0: 10, y1: 100, h: 0, want: 2},
+	}
+	for _, test := range tests {
+		got := autoscrollLines(test.y, test.y0, test.y1, test.h)
+		if got != test.want {
+			t.Errorf("autoscrollLines(%d, %d, %d, %d)=%d, want %d",
+				test.y, test.y0, test.y1, test.h, got, test.want)
+		}
+	}
+}
+
+func TestAutoscroll(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var lines string
+	for i := 0; i < 100; i++ {
+		lines += strconv.Itoa(i%10) + "\n"
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, lines), edit.Set(edit.Rune(0), '.')); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+
+	var p, below image.Point
+	var visible int
+	w.Send(func() {
+		p = sheet0.body.topLeft.Add(image.Pt(1, 1))
+		lineHeight := sheet0.body.opts.DefaultStyle.Face.Metrics().Height.Round()
+		below = image.Pt(p.X, sheet0.Max.Y+3*lineHeight)
+		// Each line is 2 bytes: a digit and a newline.
+		visible = 2 * (sheet0.body.opts.Size.Y/lineHeight + 1)
+	})
+	wait(w)
+	mouseTo(w, p)
+	w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: mouse.ButtonLeft, Direction: mouse.DirPress})
+	mouseTo(w, below)
+
+	// Wait for the selection to extend beyond the initially visible lines.
+	var sel string
+	for i := 0; i < 100 && len(sel) <= visible; i++ {
+		time.Sleep(10 * time.Millisecond)
+		var res []editor.EditResult
+		var err error
+		w.Send(func() { res, err = sheet0.body.doSync(edit.Print(edit.Dot)) })
+		wait(w)
+		if err != nil || res[0].Error != "" {
+			t.Fatalf("sheet0.body.doSync(Print(.))=%v,%v", res, err)
+		}
+		sel = res[0].Print
+	}
+	w.Send(mouse.Event{X: float32(below.X), Y: float32(below.Y), Button: mouse.ButtonLeft, Direction: mouse.DirRelease})
+	wait(w)
+
+	if !strings.HasPrefix(sel, "0\n1\n") || len(sel) <= visible {
+		t.Errorf("selected %q, want a prefix of the text longer than %d bytes", sel, visible)
+	}
+}
+
+func TestShowDot(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var lines string
+	for i := 0; i < 100; i++ {
+		lines += strconv.Itoa(i%10) + "\n"
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, lines), edit.Set(edit.End, '.')); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	var p image.Point
+	w.Send(func() { p = sheet0.body.topLeft.Add(image.Pt(1, 1)) })
+	wait(w)
+	mouseTo(w, p)
+
+	// Typing at dot, past the visible text, scrolls it into view.
+	w.Send(key.Event{Rune: 'x', Direction: key.DirPress})
+	var l0 int64
+	var visible bool
+	for i := 0; i < 100 && (l0 == 0 || !visible); i++ {
+		time.Sleep(10 * time.Millisecond)
+		w.Send(func() { l0, visible = sheet0.body.l0, sheet0.body.dotVisible() })
+		wait(w)
+	}
+	if !visible || l0 == 0 {
+		t.Errorf("after typing, l0=%d, dot visible=%t, want l0>0 and dot visible", l0, visible)
+	}
+}
+
+func TestWheel(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var lines string
+	for i := 0; i < 100; i++ {
+		lines += strconv.Itoa(i%10) + "\n"
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, lines)); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+	var p image.Point
+	w.Send(func() { p = sheet0.body.topLeft.Add(image.Pt(1, 1)) })
+	wait(w)
+	mouseTo(w, p)
+
+	line0 := func(want int64) int64 {
+		var got int64
+		for i := 0; i < 100 && got != want; i++ {
+			time.Sleep(10 * time.Millisecond)
+			w.Send(func() { got = sheet0.body.line0 })
+			wait(w)
+		}
+		return got
+	}
+	step := func(b mouse.Button) {
+		w.Send(mouse.Event{X: float32(p.X), Y: float32(p.Y), Button: b, Direction: mouse.DirStep})
+	}
+
+	step(mouse.ButtonWheelDown)
+	step(mouse.ButtonWheelDown)
+	if got, want := line0(1+2*wheelLines), int64(1+2*wheelLines); got != want {
+		t.Errorf("after scrolling down, line0=%d, want %d", got, want)
+	}
+	step(mouse.ButtonWheelUp)
+	if got, want := line0(1+wheelLines), int64(1+wheelLines); got != want {
+		t.Errorf("after scrolling up, line0=%d, want %d", got, want)
+	}
+}
+
+func TestFrameOf(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	w.Send(func() {
+		c := w.columns[1]
+		colTag := c.frames[0].(*columnTag)
+		sheet := c.frames[1].(*sheet)
+		if f := w.frameOf(colTag.text); f != colTag {
+			t.Errorf("w.frameOf(column tag text)=%v, want the column tag", f)
+		}
+		if f := w.frameOf(sheet.tag); f != sheet {
+			t.Errorf("w.frameOf(sheet tag)=%v, want the sheet", f)
+		}
+		if f := w.frameOf(sheet.body); f != sheet {
+			t.Errorf("w.frameOf(sheet body)=%v, want the sheet", f)
+		}
+		if f := w.frameOf(&textBox{}); f != nil {
+			t.Errorf("w.frameOf(other)=%v, want nil", f)
+		}
+	})
+	wait(w)
+}
+
+func TestCursorBlink(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var p image.Point
+	w.Send(func() { p = sheet0.body.topLeft.Add(image.Pt(1, 1)) })
+	wait(w)
+	mouseTo(w, p)
+
+	// Tick reports whether the cursor changed,
+	// and the cursor only changes after blinkDuration.
+	tick := func() (redraw, on bool) {
+		w.Send(func() {
+			sheet0.body.lastBlink = time.Now().Add(-2 * blinkDuration)
+			redraw = sheet0.body.tick(w)
+			on = sheet0.body.blinkOn
+		})
+		wait(w)
+		return redraw, on
+	}
+	_, on0 := tick()
+	if redraw, on := tick(); !redraw || on == on0 {
+		t.Errorf("blinking tick()=%t,%t, want true,%t", redraw, on, !on0)
+	}
+
+	s.uiServer.SetCursorBlink(false)
+	tick()
+	for i := 0; i < 2; i++ {
+		if redraw, on := tick(); redraw || !on {
+			t.Errorf("solid tick()=%t,%t, want false,true", redraw, on)
+		}
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	var lines string
+	for i := 0; i < 100; i++ {
+		lines += strconv.Itoa(i%10) + "\n"
+	}
+	if _, err := sheet0.body.doSync(edit.Change(edit.All, lines)); err != nil {
+		t.Fatalf("sheet0.body.doSync(…)=_,%v", err)
+	}
+
+	var gutter, scrollSep image.Rectangle
+	var bodyX int
+	var line0 int64
+	var nLines int
+	// Layout waits for the body to be laid out with the given first line.
+	layout := func(wantLine0 int64) {
+		for i := 0; i < 100; i++ {
+			w.Send(func() {
+				sheet0.draw(w.server.screen, w.Window)
+				gutter, scrollSep = sheet0.gutter, sheet0.scrollSep
+				bodyX = sheet0.body.topLeft.X
+				line0, nLines = sheet0.body.line0, len(sheet0.body.lineYs)
+			})
+			wait(w)
+			if line0 == wantLine0 && nLines > 1 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	layout(1)
+	if !gutter.Empty() || bodyX != scrollSep.Max.X {
+		t.Errorf("gutter=%v, body x=%d, want empty gutter and body x=%d", gutter, bodyX, scrollSep.Max.X)
+	}
+
+	w.Send(func() { sheet0.toggleLineNumbers() })
+	layout(1)
+	if gutter.Empty() || gutter.Min.X != scrollSep.Max.X || bodyX != gutter.Max.X+borderWidth {
+		t.Errorf("gutter=%v, body x=%d, want non-empty gutter after %d and body x=%d",
+			gutter, bodyX, scrollSep.Max.X, gutter.Max.X+borderWidth)
+	}
+	lineHeight := sheet0.body.opts.DefaultStyle.Face.Metrics().Height.Round()
+	if want := sheet0.body.opts.Size.Y / lineHeight; nLines < want-1 || nLines > want {
+		t.Errorf("%d line numbers, want about %d", nLines, want)
+	}
+
+	sheet0.body.view.Scroll(10)
+	layout(11)
+	if line0 != 11 {
+		t.Errorf("after scrolling line0=%d, want 11", line0)
+	}
+
+	w.Send(func() { sheet0.toggleLineNumbers() })
+	layout(11)
+	if !gutter.Empty() || bodyX != scrollSep.Max.X {
+		t.Errorf("gutter=%v, body x=%d, want empty gutter and body x=%d", gutter, bodyX, scrollSep.Max.X)
+	}
+}
+
+// WaitViewStart waits for the start of the text box's view
+// to be at the given rune offset.
+func waitViewStart(t *testing.T, tb *textBox, want int64) {
+	var got int64
+	for i := 0; i < 100; i++ {
+		tb.view.View(func(_ []byte, marks []view.Mark) {
+			for _, m := range marks {
+				if m.Name == view.ViewMark {
+					got = m.Where[0]
+				}
+			}
+		})
+		if got == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("view start=%d, want %d", got, want)
+}
+
 // Test_WindowOutput_NewOutputSheet simply tests that a new sheet opens on output.
 func Test_WindowOutput_NewOutputSheet(t *testing.T) {
 	s, w := makeTestUI()
@@ -768,8 +1151,300 @@ func nextBodyChangeText(s *sheet) <-chan string {
 // 	1 window
 // 	3 column, at 0.0, 0.33, and 0.66, respectively.
 //	6 sheets, 2 in each column
+func TestMoveSheet(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	winListURL := urlWithPath(s.url, "/", "windows")
+	win2, err := NewWindow(winListURL, image.Pt(800, 600))
+	if err != nil {
+		t.Fatalf("NewWindow(%s, …)=_,%v", winListURL, err)
+	}
+	w2 := s.uiServer.windows[win2.ID]
+
+	moveURL := urlWithPath(s.url, "/", "sheet", sheet0.id, "window")
+	got, err := MoveSheet(moveURL, win2.Path, 0.5, 0.5)
+	if err != nil {
+		t.Fatalf("MoveSheet(%s, %s, 0.5, 0.5)=_,%v", moveURL, win2.Path, err)
+	}
+	if got.WindowPath != win2.Path {
+		t.Errorf("MoveSheet(…).WindowPath=%q, want %q", got.WindowPath, win2.Path)
+	}
+	wait(w)
+	wait(w2)
+	if i := frameIndex(w.columns[0], sheet0); i >= 0 {
+		t.Errorf("sheet0 still in the first window at frame %d", i)
+	}
+	var inW2 bool
+	for _, c := range w2.columns {
+		inW2 = inW2 || frameIndex(c, sheet0) >= 0
+	}
+	if !inW2 {
+		t.Errorf("sheet0 not in the second window")
+	}
+	if sheet0.win != w2 || sheet0.body.win != w2 || sheet0.tag.win != w2 {
+		t.Errorf("sheet0 windows=%p,%p,%p, want %p", sheet0.win, sheet0.body.win, sheet0.tag.win, w2)
+	}
+
+	// The snarf buffer is shared by all windows.
+	sheet1 := w.columns[0].frames[1].(*sheet)
+	w.Send(func() { sheet1.body.setSnarf("snarfed") })
+	wait(w)
+	var snarf string
+	w2.Send(func() { snarf = sheet0.body.snarf() })
+	wait(w2)
+	if snarf != "snarfed" {
+		t.Errorf("snarf in the second window=%q, want %q", snarf, "snarfed")
+	}
+
+	badURL := urlWithPath(s.url, "/", "sheet", "100", "window")
+	if _, err := MoveSheet(badURL, win2.Path, 0.5, 0.5); err != ErrNotFound {
+		t.Errorf("MoveSheet(%s, …)=_,%v, want %v", badURL, err, ErrNotFound)
+	}
+	if _, err := MoveSheet(moveURL, "/window/100", 0.5, 0.5); err != ErrNotFound {
+		t.Errorf("MoveSheet(…, /window/100, …)=_,%v, want %v", err, ErrNotFound)
+	}
+}
+
+// TestDragOut tests dragging a sheet out of its window
+// and into another window.
+func TestDragOut(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet1 := w.columns[0].frames[2].(*sheet)
+
+	winListURL := urlWithPath(s.url, "/", "windows")
+	win2, err := NewWindow(winListURL, image.Pt(800, 600))
+	if err != nil {
+		t.Fatalf("NewWindow(%s, …)=_,%v", winListURL, err)
+	}
+	w2 := s.uiServer.windows[win2.ID]
+
+	// Drag sheet1 off the left edge of the window.
+	p := center(sheet1)
+	mouseTo(w, p)
+	shiftDrag(w, p, image.Pt(-100, p.Y), mouse.ButtonLeft)
+	wait(w)
+	if i := frameIndex(w.columns[0], sheet1); i < 0 {
+		t.Fatalf("sheet1 not put back in its column")
+	}
+
+	// The next window entered takes the sheet.
+	mouseTo(w2, image.Pt(400, 300))
+	wait(w2)
+	wait(w)
+	wait(w2)
+	if i := frameIndex(w.columns[0], sheet1); i >= 0 {
+		t.Errorf("sheet1 still in the first window at frame %d", i)
+	}
+	var inW2 bool
+	for _, c := range w2.columns {
+		inW2 = inW2 || frameIndex(c, sheet1) >= 0
+	}
+	if !inW2 {
+		t.Errorf("sheet1 not in the second window")
+	}
+}
+
+func TestGeometry(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	layoutURL := urlWithPath(s.url, "/", "window", w.id, "layout")
+	g, err := GetGeometry(layoutURL)
+	if err != nil {
+		t.Fatalf("GetGeometry(%s)=_,%v", layoutURL, err)
+	}
+	if g.Width != 800 || g.Height != 600 || len(g.Columns) != len(w.columns) {
+		t.Fatalf("GetGeometry(%s)=%+v, want 800x600 with %d columns", layoutURL, g, len(w.columns))
+	}
+	for i, cg := range g.Columns {
+		if cg.X != w.xs[i] || len(cg.Sheets) != len(w.columns[i].frames)-1 {
+			t.Errorf("column %d geometry=%+v, want X=%g with %d sheets",
+				i, cg, w.xs[i], len(w.columns[i].frames)-1)
+		}
+	}
+
+	// Move the first sheet of column 0 to the bottom of column 1,
+	// and move column 1 to the right.
+	sheet0 := w.columns[0].frames[1].(*sheet)
+	cols := g.Columns
+	moved := cols[0].Sheets[0]
+	moved.Y = 0.9
+	cols[0].Sheets = cols[0].Sheets[1:]
+	cols[1].Sheets = append(cols[1].Sheets, moved)
+	cols[1].X = 0.25
+	if g, err = SetGeometry(layoutURL, SetGeometryRequest{Columns: cols}); err != nil {
+		t.Fatalf("SetGeometry(%s, …)=_,%v", layoutURL, err)
+	}
+	if n := len(g.Columns[1].Sheets); n == 0 || g.Columns[1].Sheets[n-1] != moved || g.Columns[1].X != 0.25 {
+		t.Errorf("after SetGeometry column 1=%+v, want X=0.25, last sheet %+v", g.Columns[1], moved)
+	}
+	wait(w)
+	c1 := w.columns[1]
+	if y := c1.Min.Y + int(0.9*float64(c1.Dy())); sheet0.col != c1 || sheet0.Min.Y != y {
+		t.Errorf("sheet0 col=%p, Min.Y=%d, want %p, %d", sheet0.col, sheet0.Min.Y, c1, y)
+	}
+
+	// Maximize sheet0, shrinking the others in its column.
+	if _, err = SetGeometry(layoutURL, SetGeometryRequest{Maximize: sheet0.id}); err != nil {
+		t.Fatalf("SetGeometry(%s, Maximize: %s)=_,%v", layoutURL, sheet0.id, err)
+	}
+	wait(w)
+	for _, f := range w.columns[1].frames[1:] {
+		if s := f.(*sheet); s != sheet0 && s.Dy() != s.minHeight() {
+			t.Errorf("after maximize sheet %s height=%d, want %d", s.id, s.Dy(), s.minHeight())
+		}
+	}
+
+	// Minimize sheet0.
+	if _, err = SetGeometry(layoutURL, SetGeometryRequest{Minimize: sheet0.id}); err != nil {
+		t.Fatalf("SetGeometry(%s, Minimize: %s)=_,%v", layoutURL, sheet0.id, err)
+	}
+	wait(w)
+	if sheet0.Dy() != sheet0.minHeight() {
+		t.Errorf("after minimize sheet0 height=%d, want %d", sheet0.Dy(), sheet0.minHeight())
+	}
+
+	bad := []SetGeometryRequest{
+		{Columns: cols[:1]},
+		{Columns: []ColumnGeometry{cols[0], cols[1], {X: 0.5}}},
+		{Maximize: "100"},
+		{Minimize: "100"},
+	}
+	for _, req := range bad {
+		if _, err := SetGeometry(layoutURL, req); err == nil {
+			t.Errorf("SetGeometry(%s, %+v)=_,nil, want error", layoutURL, req)
+		}
+	}
+	badURL := urlWithPath(s.url, "/", "window", "100", "layout")
+	if _, err := GetGeometry(badURL); err != ErrNotFound {
+		t.Errorf("GetGeometry(%s)=_,%v, want %v", badURL, err, ErrNotFound)
+	}
+}
+
+// TestSetDPI tests changing the window's DPI,
+// as when the window moves to a monitor with a different resolution.
+func TestToggleZoom(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	w.Send(func() {
+		c := w.columns[0]
+		sheet0 := c.frames[1].(*sheet)
+		sheet1 := c.frames[2].(*sheet)
+		ys := append([]float64{}, c.ys...)
+
+		sheet0.toggleZoom()
+		if sheet1.Dy() != sheet1.minHeight() {
+			t.Errorf("after zoom, sheet1.Dy()=%d, want %d", sheet1.Dy(), sheet1.minHeight())
+		}
+		if want := c.Max.Y - sheet1.minHeight() - borderWidth; sheet0.Max.Y != want {
+			t.Errorf("after zoom, sheet0.Max.Y=%d, want %d", sheet0.Max.Y, want)
+		}
+
+		sheet0.toggleZoom()
+		if !reflect.DeepEqual(c.ys, ys) {
+			t.Errorf("after unzoom, c.ys=%v, want %v", c.ys, ys)
+		}
+	})
+	wait(w)
+}
+
+func TestToggleCollapse(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+
+	w.Send(func() {
+		c := w.columns[0]
+		sheet0 := c.frames[1].(*sheet)
+		sheet1 := c.frames[2].(*sheet)
+
+		for _, f := range []*sheet{sheet0, sheet1} {
+			h := f.Dy()
+			f.toggleCollapse()
+			if f.Dy() != f.minHeight() {
+				t.Errorf("after collapse, Dy()=%d, want %d", f.Dy(), f.minHeight())
+			}
+			// The body is empty and within the sheet.
+			if f.body.opts.Size.Y != 0 || !f.sep.In(f.bounds()) || !f.scroll.In(f.bounds()) {
+				t.Errorf("after collapse, body size=%v, sep=%v, scroll=%v, want empty within %v",
+					f.body.opts.Size, f.sep, f.scroll, f.bounds())
+			}
+			f.toggleCollapse()
+			// Frame ys are fractions of the column height;
+			// allow for rounding.
+			if d := f.Dy() - h; d < -1 || d > 1 {
+				t.Errorf("after expand, Dy()=%d, want %d", f.Dy(), h)
+			}
+		}
+	})
+	wait(w)
+}
+
+func TestSetDPI(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	var minHeight0 int
+	w.Send(func() { minHeight0 = sheet0.minHeight() })
+	wait(w)
+
+	const pxPerPt = 2.0 * defaultDPI / ptPerInch
+	w.Send(size.Event{
+		WidthPx:     800,
+		HeightPx:    600,
+		PixelsPerPt: pxPerPt,
+	})
+	wait(w)
+
+	var scale float64
+	var padding, minHeight int
+	var face, tagFace font.Face
+	w.Send(func() {
+		scale, face = w.scale, w.face
+		padding, minHeight = sheet0.body.opts.Padding, sheet0.minHeight()
+		tagFace = w.columns[0].frames[0].(*columnTag).text.opts.DefaultStyle.Face
+	})
+	wait(w)
+	if scale != 2 {
+		t.Errorf("w.scale=%g, want 2", scale)
+	}
+	if padding != 2*textPadding {
+		t.Errorf("body padding=%d, want %d", padding, 2*textPadding)
+	}
+	if minHeight <= minHeight0 {
+		t.Errorf("sheet0.minHeight()=%d, want > %d", minHeight, minHeight0)
+	}
+	if tagFace != face {
+		t.Errorf("column tag face is not the window's face")
+	}
+}
+
+func TestScalePx(t *testing.T) {
+	tests := []struct {
+		n     int
+		scale float64
+		want  int
+	}{
+		{n: 0, scale: 2, want: 0},
+		{n: 1, scale: 1, want: 1},
+		{n: 1, scale: 0.5, want: 1},
+		{n: 1, scale: 2, want: 2},
+		{n: 12, scale: 1.5, want: 18},
+		{n: 20, scale: 0.75, want: 15},
+	}
+	for _, test := range tests {
+		if got := scalePx(test.n, test.scale); got != test.want {
+			t.Errorf("scalePx(%d, %g)=%d, want %d", test.n, test.scale, got, test.want)
+		}
+	}
+}
+
 func makeTestUI() (*testServer, *window) {
-	s := newServer(new(stubScreen))
+	s := newServer(headless.NewScreen())
 	winListURL := urlWithPath(s.url, "/", "windows")
 	win, err := NewWindow(winListURL, image.Pt(800, 600))
 	if err != nil {
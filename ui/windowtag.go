@@ -0,0 +1,69 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"image"
+
+	"github.com/eaburns/T/edit"
+	"github.com/eaburns/T/ui/text"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+const windowTagText = "Newcol Dump Load Kill Exit"
+
+// A windowTag is the tag across the top of a window.
+// Commands executed from it operate on the window.
+type windowTag struct {
+	text *textBox
+	image.Rectangle
+}
+
+func newWindowTag(w *window) (*windowTag, error) {
+	text, err := newTextBox(w, *w.server.editorURL, "", text.Style{
+		Face: w.face,
+		FG:   w.theme.ColumnTagFG,
+		BG:   w.theme.ColumnTagBG,
+	})
+	if err != nil {
+		return nil, err
+	}
+	text.view.DoAsync(edit.Change(edit.All, windowTagText+" "), edit.Set(edit.End, '.'))
+	return &windowTag{text: text}, nil
+}
+
+func (t *windowTag) close() { t.text.close() }
+
+func (t *windowTag) bounds() image.Rectangle { return t.Rectangle }
+
+func (t *windowTag) setBounds(b image.Rectangle) {
+	t.text.topLeft = b.Min
+	t.text.setSize(b.Size())
+	t.Rectangle = b
+}
+
+func (t *windowTag) minHeight() int { return minHeight(t.text.opts) }
+
+// SetColumn is a no-op; the window tag is never in a column.
+func (*windowTag) setColumn(*column) {}
+
+func (t *windowTag) focus(image.Point) handler { return t }
+
+func (t *windowTag) draw(scr screen.Screen, win screen.Window) {
+	t.text.setSize(t.Size()) // Reset the text in case it changed.
+	t.text.draw(scr, win)
+}
+
+func (t *windowTag) drawLast(scr screen.Screen, win screen.Window) {}
+
+func (t *windowTag) changeFocus(win *window, inFocus bool) {
+	t.text.changeFocus(win, inFocus)
+}
+
+func (t *windowTag) tick(win *window) bool { return t.text.tick(win) }
+
+func (t *windowTag) key(w *window, event key.Event) bool { return t.text.key(w, event) }
+
+func (t *windowTag) mouse(w *window, event mouse.Event) bool { return t.text.mouse(w, event) }
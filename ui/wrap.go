@@ -0,0 +1,77 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/eaburns/T/ui/text"
+	"github.com/gorilla/mux"
+	"golang.org/x/mobile/event/paint"
+)
+
+// SetWrap sets whether the body soft wraps lines too long to fit its width,
+// or clips them, and redraws the sheet.
+// The first visible line of the body does not change,
+// and dot is kept visible if it was visible.
+// It must be called in the UI goroutine of the sheet's window.
+func (s *sheet) setWrap(wrap bool) {
+	if s.body.dotVisible() {
+		s.body.showDot = true
+	}
+	s.body.setWrap(wrap)
+	s.win.Send(paint.Event{})
+}
+
+// Wrap returns whether the body soft wraps long lines.
+func (s *sheet) wrap() bool { return s.body.opts.Wrap != text.NoWrap }
+
+// SetWrap sets whether the text soft wraps long lines.
+// It must be called in the UI goroutine of the text box's window.
+func (t *textBox) setWrap(wrap bool) {
+	t.mu.Lock()
+	t.reset = true
+	t.mu.Unlock()
+	if wrap {
+		t.opts.Wrap = text.WrapAnywhere
+	} else {
+		t.opts.Wrap = text.NoWrap
+	}
+}
+
+func (s *Server) getWrapHandler(w http.ResponseWriter, req *http.Request) {
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	result := make(chan WrapState)
+	f.win.Send(func() { result <- WrapState{Wrap: f.wrap()} })
+	s.RUnlock()
+	respond(w, <-result)
+}
+
+func (s *Server) setWrapHandler(w http.ResponseWriter, req *http.Request) {
+	var st WrapState
+	if err := json.NewDecoder(req.Body).Decode(&st); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.RLock()
+	f, ok := s.sheets[mux.Vars(req)["id"]]
+	if !ok {
+		s.RUnlock()
+		http.NotFound(w, req)
+		return
+	}
+	done := make(chan struct{})
+	f.win.Send(func() {
+		f.setWrap(st.Wrap)
+		close(done)
+	})
+	s.RUnlock()
+	<-done
+}
@@ -0,0 +1,57 @@
+// Copyright © 2016, The T Authors.
+
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eaburns/T/edit"
+)
+
+func TestWrap(t *testing.T) {
+	s, w := makeTestUI()
+	defer s.close()
+	sheet0 := w.columns[0].frames[1].(*sheet)
+
+	long := strings.Repeat("x", 500) + "\n"
+	setTextDot(t, w, sheet0.body, strings.Repeat(long, 3), edit.Span{0, 0})
+
+	wrapURL := urlWithPath(s.url, "/", "sheet", sheet0.id, "wrap")
+	if wrap, err := GetWrap(wrapURL); err != nil || !wrap {
+		t.Fatalf("GetWrap(%s)=%v,%v, want true,nil", wrapURL, wrap, err)
+	}
+	nLines := func() int {
+		var n int
+		w.Send(func() {
+			sheet0.updateText()
+			n = sheet0.body.text.NumLines()
+		})
+		wait(w)
+		return n
+	}
+	wrapped := nLines()
+
+	w.Send(func() { sheet0.tag.exec("Wrap") })
+	if wrap, err := GetWrap(wrapURL); err != nil || wrap {
+		t.Fatalf("after Wrap, GetWrap(%s)=%v,%v, want false,nil", wrapURL, wrap, err)
+	}
+	if clipped := nLines(); clipped >= wrapped {
+		t.Errorf("clipped lines=%d, wrapped lines=%d, want fewer clipped", clipped, wrapped)
+	}
+
+	if err := SetWrap(wrapURL, true); err != nil {
+		t.Fatalf("SetWrap(%s, true)=%v", wrapURL, err)
+	}
+	if wrap, err := GetWrap(wrapURL); err != nil || !wrap {
+		t.Fatalf("GetWrap(%s)=%v,%v, want true,nil", wrapURL, wrap, err)
+	}
+	if n := nLines(); n != wrapped {
+		t.Errorf("wrapped lines=%d, want %d", n, wrapped)
+	}
+
+	notFound := urlWithPath(s.url, "/", "sheet", "nope", "wrap")
+	if err := SetWrap(notFound, true); err != ErrNotFound {
+		t.Errorf("SetWrap(%s, true)=%v, want %v", notFound, err, ErrNotFound)
+	}
+}
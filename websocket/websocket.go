@@ -6,6 +6,8 @@
 // All of its methods are safe for concurrent use.
 // It automatically applies a send timeout.
 // It transparently handles the closing handshake.
+// It keeps connections alive with ping and pong messages,
+// and detects connections to unresponsive peers.
 package websocket
 
 import (
@@ -31,6 +33,20 @@ const (
 	// HandshakeTimeout is the amount of time to wait
 	// for the connection handshake to complete.
 	HandshakeTimeout = 5 * time.Second
+
+	// PingInterval is the amount of time between pings sent to the peer.
+	PingInterval = 20 * time.Second
+
+	// RecvTimeout is the amount of time to wait for any message,
+	// including a pong, from the peer
+	// before considering the connection lost.
+	RecvTimeout = 2 * PingInterval
+)
+
+// PingInterval and recvTimeout are variables for testing.
+var (
+	pingInterval = PingInterval
+	recvTimeout  = RecvTimeout
 )
 
 // ErrCloseSent is returned by Send if sending to a connection that is closing.
@@ -56,6 +72,9 @@ type Conn struct {
 	conn           *websocket.Conn
 	send           chan sendReq
 	recv           chan recvMsg
+	closing        chan struct{}
+	pingInterval   time.Duration
+	recvTimeout    time.Duration
 	sendCloseOnce  sync.Once
 	sendCloseError error
 }
@@ -86,12 +105,20 @@ func Upgrade(w http.ResponseWriter, req *http.Request) (*Conn, error) {
 
 func newConn(conn *websocket.Conn) *Conn {
 	c := &Conn{
-		conn: conn,
-		send: make(chan sendReq, 10),
-		recv: make(chan recvMsg, 10),
+		conn:         conn,
+		send:         make(chan sendReq, 10),
+		recv:         make(chan recvMsg, 10),
+		closing:      make(chan struct{}),
+		pingInterval: pingInterval,
+		recvTimeout:  recvTimeout,
 	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.recvTimeout))
+	})
+	recvDone := make(chan struct{})
 	go c.goSend()
-	go c.goRecv()
+	go c.goRecv(recvDone)
+	go c.goPing(recvDone)
 	return c
 }
 
@@ -102,6 +129,7 @@ func newConn(conn *websocket.Conn) *Conn {
 //
 // Close should not be called more than once.
 func (c *Conn) Close() error {
+	close(c.closing)
 	close(c.send)
 
 	err := c.sendClose()
@@ -156,6 +184,10 @@ func (c *Conn) goSend() {
 // otherwise the connection will not respond to ping/pong messages.
 //
 // Calling Recv on a closed connection returns io.EOF.
+// If the peer closed the connection, Recv returns io.EOF.
+// If the connection was lost,
+// for example if the peer stopped responding to pings,
+// Recv returns the error, and then io.EOF.
 func (c *Conn) Recv(msg interface{}) error {
 	r, ok := <-c.recv
 	if !ok {
@@ -175,15 +207,28 @@ type recvMsg struct {
 	err error
 }
 
-func (c *Conn) goRecv() {
+func (c *Conn) goRecv(done chan<- struct{}) {
 	defer close(c.recv)
+	defer close(done)
 
 	for {
+		// The deadline is only extended before reading,
+		// so that a slow reader of the recv channel
+		// does not look like an unresponsive peer.
+		c.conn.SetReadDeadline(time.Now().Add(c.recvTimeout))
 		messageType, p, err := c.conn.ReadMessage()
 		if messageType == websocket.TextMessage {
 			c.recv <- recvMsg{p: p, err: err}
 		}
 		if err != nil {
+			// The connection was lost, unless it is being closed.
+			if !closed(err) {
+				select {
+				case <-c.closing:
+				default:
+					c.recv <- recvMsg{err: err}
+				}
+			}
 			// If this errors, a subsequent call to Close will return the error.
 			c.sendClose()
 			// ReadMessage cannot receive messages after it returns an error.
@@ -193,6 +238,31 @@ func (c *Conn) goRecv() {
 	}
 }
 
+// Closed returns whether the error is from the peer closing the connection.
+func closed(err error) bool {
+	return websocket.IsCloseError(err,
+		websocket.CloseNormalClosure,
+		websocket.CloseGoingAway,
+		websocket.CloseNoStatusReceived)
+}
+
+// GoPing sends pings to the peer until done is closed.
+func (c *Conn) goPing(done <-chan struct{}) {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			dl := time.Now().Add(SendTimeout)
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, dl); err != nil {
+				return
+			}
+		}
+	}
+}
+
 func (c *Conn) sendClose() error {
 	c.sendCloseOnce.Do(func() {
 		dl := time.Now().Add(SendTimeout)
@@ -10,6 +10,7 @@ import (
 	"path"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestDialNotFound(t *testing.T) {
@@ -162,3 +163,72 @@ func recvUntilClose(t *testing.T) http.HandlerFunc {
 		}
 	})
 }
+
+func TestRecvTimeout(t *testing.T) {
+	defer func(p, r time.Duration) { pingInterval, recvTimeout = p, r }(pingInterval, recvTimeout)
+	pingInterval, recvTimeout = 10*time.Millisecond, 50*time.Millisecond
+
+	// The server neither reads nor responds to pings.
+	done := make(chan struct{})
+	defer close(done)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrader.Upgrade(w, r, nil)=_,%v", err)
+			return
+		}
+		<-done
+		conn.Close()
+	})
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	URL, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q)=_,%v", s.URL, err)
+	}
+	URL.Scheme = "ws"
+	conn, err := Dial(URL)
+	if err != nil {
+		t.Fatalf("Dial(%s)=_,%v", URL, err)
+	}
+	defer conn.Close()
+	if err := conn.Recv(nil); err == nil || err == io.EOF {
+		t.Errorf("conn.Recv(nil)=%v, want a timeout error", err)
+	}
+	if err := conn.Recv(nil); err != io.EOF {
+		t.Errorf("conn.Recv(nil)=%v, want %v", err, io.EOF)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	defer func(p, r time.Duration) { pingInterval, recvTimeout = p, r }(pingInterval, recvTimeout)
+	pingInterval, recvTimeout = 10*time.Millisecond, 50*time.Millisecond
+
+	handler := http.HandlerFunc(echoUntilClose(t))
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	URL, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q)=_,%v", s.URL, err)
+	}
+	URL.Scheme = "ws"
+	conn, err := Dial(URL)
+	if err != nil {
+		t.Fatalf("Dial(%s)=_,%v", URL, err)
+	}
+
+	// The connection stays open while idle for longer than recvTimeout.
+	time.Sleep(5 * recvTimeout)
+	if err := conn.Send("abc"); err != nil {
+		t.Fatalf("conn.Send(\"abc\")=%v", err)
+	}
+	var str string
+	if err := conn.Recv(&str); err != nil || str != "abc" {
+		t.Errorf("conn.Recv(&str)=%v, str=%q, want nil, \"abc\"", err, str)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("client conn.Close()=%v", err)
+	}
+}